@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/slog"
+	"google.golang.org/grpc"
+)
+
+// lifecycle запускает серверы и фоновые задачи сервиса и останавливает их
+// в порядке, обратном зависимостям: сначала серверы перестают принимать запросы
+// и дожидаются завершения текущих, затем останавливаются фоновые задачи,
+// после чего выполняются шаги остановки в порядке добавления.
+type lifecycle struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	timeout time.Duration
+	workers sync.WaitGroup
+	servers []server
+	stops   []stopStep
+	once    sync.Once
+}
+
+type server struct {
+	name     string
+	serve    func() error
+	shutdown func(ctx context.Context) error
+}
+
+type stopStep struct {
+	name string
+	stop func(ctx context.Context) error
+}
+
+// newLifecycle возвращает указатель на новый экземпляр lifecycle. Фоновые задачи
+// получают контекст, производный от ctx. Остановка серверов и шаги остановки
+// должны уложиться в timeout.
+func newLifecycle(ctx context.Context, timeout time.Duration) *lifecycle {
+	ctx, cancel := context.WithCancel(ctx)
+
+	return &lifecycle{
+		ctx:     ctx,
+		cancel:  cancel,
+		timeout: timeout,
+	}
+}
+
+// Go запускает фоновую задачу fn. fn должна вернуть управление после отмены
+// переданного ей контекста.
+func (lc *lifecycle) Go(fn func(ctx context.Context)) {
+	lc.workers.Add(1)
+	go func() {
+		defer lc.workers.Done()
+		fn(lc.ctx)
+	}()
+}
+
+// Serve добавляет сервер name, который запускается вызовом serve в Run
+// и останавливается вызовом shutdown. Ошибки http.ErrServerClosed
+// и grpc.ErrServerStopped, возвращаемые serve после остановки, не считаются ошибками.
+func (lc *lifecycle) Serve(name string, serve func() error, shutdown func(ctx context.Context) error) {
+	lc.servers = append(lc.servers, server{name: name, serve: serve, shutdown: shutdown})
+}
+
+// OnStop добавляет шаг остановки name, выполняемый после остановки серверов
+// и фоновых задач.
+func (lc *lifecycle) OnStop(name string, stop func(ctx context.Context) error) {
+	lc.stops = append(lc.stops, stopStep{name: name, stop: stop})
+}
+
+// Run запускает серверы и ожидает отмены контекста ctx или ошибки любого
+// из серверов, после чего останавливает сервис. Возвращает ошибку сервера,
+// из-за которой сервис был остановлен, и ошибки остановки.
+func (lc *lifecycle) Run(ctx context.Context) error {
+	failed := make(chan error, len(lc.servers))
+	for _, s := range lc.servers {
+		go func(s server) {
+			err := s.serve()
+			if err != nil && !errors.Is(err, http.ErrServerClosed) && !errors.Is(err, grpc.ErrServerStopped) {
+				failed <- fmt.Errorf("%s server: %w", s.name, err)
+			}
+		}(s)
+	}
+
+	var err error
+	select {
+	case <-ctx.Done():
+	case err = <-failed:
+		slog.Error("Server failed", "error", err)
+	}
+	slog.Info("Starting server graceful shutdown...")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), lc.timeout)
+	defer cancel()
+
+	return errors.Join(err, lc.shutdownServers(shutdownCtx), lc.stop(shutdownCtx))
+}
+
+// Close останавливает фоновые задачи и выполняет шаги остановки, если сервис
+// не был остановлен вызовом Run. Используется, если сервис не удалось запустить.
+func (lc *lifecycle) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), lc.timeout)
+	defer cancel()
+
+	return lc.stop(ctx)
+}
+
+// shutdownServers параллельно останавливает серверы.
+func (lc *lifecycle) shutdownServers(ctx context.Context) error {
+	var (
+		errs = make([]error, len(lc.servers))
+		wg   sync.WaitGroup
+	)
+	for i, s := range lc.servers {
+		wg.Add(1)
+		go func(i int, s server) {
+			defer wg.Done()
+
+			if err := s.shutdown(ctx); err != nil {
+				errs[i] = fmt.Errorf("%s server shutdown: %w", s.name, err)
+				slog.Error("Error while server gracefully shutdown", "server", s.name, "error", err)
+			}
+		}(i, s)
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// stop однократно отменяет корневой контекст, дожидается завершения фоновых
+// задач и выполняет шаги остановки.
+func (lc *lifecycle) stop(ctx context.Context) error {
+	var errs []error
+	lc.once.Do(func() {
+		lc.cancel()
+		if err := waitGroup(ctx, &lc.workers); err != nil {
+			errs = append(errs, fmt.Errorf("background workers: %w", err))
+			slog.Error("Background workers did not stop in time", "error", err)
+		}
+
+		for _, s := range lc.stops {
+			if err := s.stop(ctx); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", s.name, err))
+				slog.Error("Error while stopping", "step", s.name, "error", err)
+			}
+		}
+	})
+
+	return errors.Join(errs...)
+}
+
+// waitGroup ожидает wg, пока не будет отменен контекст ctx.
+func waitGroup(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
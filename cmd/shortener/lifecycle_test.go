@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLifecycle_Run(t *testing.T) {
+	var (
+		lc          = newLifecycle(context.Background(), time.Second)
+		ctx, cancel = context.WithCancel(context.Background())
+		mu          sync.Mutex
+		events      []string
+		record      = func(e string) {
+			mu.Lock()
+			defer mu.Unlock()
+			events = append(events, e)
+		}
+	)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})}
+	lc.Serve("http", func() error {
+		return srv.Serve(listener)
+	}, func(ctx context.Context) error {
+		record("http")
+
+		return srv.Shutdown(ctx)
+	})
+	lc.Go(func(ctx context.Context) {
+		<-ctx.Done()
+		record("worker")
+	})
+	lc.OnStop("queue", func(context.Context) error {
+		record("queue")
+
+		return nil
+	})
+	lc.OnStop("storage", func(context.Context) error {
+		record("storage")
+
+		return errors.New("close error")
+	})
+
+	done := make(chan error)
+	go func() {
+		done <- lc.Run(ctx)
+	}()
+	resp, err := http.Get("http://" + listener.Addr().String())
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	cancel()
+
+	err = <-done
+	assert.EqualError(t, err, "storage: close error")
+	assert.Equal(t, []string{"http", "worker", "queue", "storage"}, events, "порядок остановки")
+	assert.NoError(t, lc.Close(), "повторная остановка")
+	assert.Len(t, events, 4, "повторная остановка")
+}
+
+func TestLifecycle_RunServerError(t *testing.T) {
+	var (
+		lc       = newLifecycle(context.Background(), time.Second)
+		serveErr = errors.New("listener closed")
+		stopped  bool
+	)
+	lc.Serve("grpc", func() error {
+		return serveErr
+	}, func(context.Context) error {
+		return nil
+	})
+	lc.OnStop("storage", func(context.Context) error {
+		stopped = true
+
+		return nil
+	})
+
+	err := lc.Run(context.Background())
+	assert.ErrorIs(t, err, serveErr)
+	assert.EqualError(t, err, "grpc server: listener closed")
+	assert.True(t, stopped, "шаги остановки выполнены")
+}
+
+func TestLifecycle_ShutdownTimeout(t *testing.T) {
+	var (
+		lc          = newLifecycle(context.Background(), 50*time.Millisecond)
+		ctx, cancel = context.WithCancel(context.Background())
+		wg          = &sync.WaitGroup{}
+	)
+	cancel()
+	wg.Add(1)
+	lc.Serve("http", func() error {
+		return http.ErrServerClosed
+	}, func(ctx context.Context) error {
+		<-ctx.Done()
+
+		return ctx.Err()
+	})
+	lc.OnStop("delete queue", func(ctx context.Context) error {
+		return waitGroup(ctx, wg)
+	})
+
+	err := lc.Run(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Contains(t, err.Error(), "http server shutdown: context deadline exceeded")
+	assert.Contains(t, err.Error(), "delete queue: context deadline exceeded")
+}
@@ -18,7 +18,12 @@ import (
 
 	"github.com/ivanpodgorny/urlshortener/internal/app/interceptor"
 
+	"go.opentelemetry.io/otel"
+	"golang.org/x/exp/slog"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 
 	"github.com/ivanpodgorny/urlshortener/internal/proto"
 
@@ -26,17 +31,74 @@ import (
 	chimiddleware "github.com/go-chi/chi/v5/middleware"
 	_ "github.com/jackc/pgx/v5/stdlib"
 
+	"github.com/ivanpodgorny/urlshortener/internal/app/clientinfo"
 	"github.com/ivanpodgorny/urlshortener/internal/app/config"
+	"github.com/ivanpodgorny/urlshortener/internal/app/gateway"
+	"github.com/ivanpodgorny/urlshortener/internal/app/geoip"
 	"github.com/ivanpodgorny/urlshortener/internal/app/handler"
+	"github.com/ivanpodgorny/urlshortener/internal/app/logger"
+	"github.com/ivanpodgorny/urlshortener/internal/app/metadata"
+	"github.com/ivanpodgorny/urlshortener/internal/app/metrics"
 	"github.com/ivanpodgorny/urlshortener/internal/app/middleware"
 	"github.com/ivanpodgorny/urlshortener/internal/app/migrations"
+	"github.com/ivanpodgorny/urlshortener/internal/app/model"
+	"github.com/ivanpodgorny/urlshortener/internal/app/multiplex"
+	"github.com/ivanpodgorny/urlshortener/internal/app/probe"
 	"github.com/ivanpodgorny/urlshortener/internal/app/security"
 	"github.com/ivanpodgorny/urlshortener/internal/app/service"
 	"github.com/ivanpodgorny/urlshortener/internal/app/storage"
+	"github.com/ivanpodgorny/urlshortener/internal/app/tracing"
+	"github.com/ivanpodgorny/urlshortener/internal/app/validator"
 )
 
 const buildInfo = "Build version: %s\nBuild date: %s\nBuild commit: %s\n"
 
+// qrCodeCacheSize количество изображений QR-кодов, хранящихся в памяти.
+const qrCodeCacheSize = 1024
+
+const (
+	// metadataWorkers количество потоков загрузки метаданных страниц назначения.
+	metadataWorkers = 4
+	// metadataQueueSize количество URL в очереди на загрузку метаданных.
+	metadataQueueSize = 1024
+	// metadataMaxRedirects максимальное число перенаправлений при загрузке страницы назначения.
+	metadataMaxRedirects = 5
+)
+
+const (
+	// healthCheckPeriod периодичность поиска URL, адрес назначения которых пора проверить.
+	healthCheckPeriod = time.Minute
+	// healthCheckBatchSize количество URL, выбираемых из хранилища для проверки за один раз.
+	healthCheckBatchSize = 100
+)
+
+const (
+	// clickQueueSize количество переходов в очереди на учет.
+	clickQueueSize = 4096
+	// clickBatchSize количество различных пар URL и варианта адреса назначения,
+	// при накоплении которого переходы сохраняются, не дожидаясь clickFlushPeriod.
+	clickBatchSize = 500
+	// clickFlushPeriod периодичность сохранения накопленных переходов.
+	clickFlushPeriod = 5 * time.Second
+)
+
+// rateLimitSweepPeriod периодичность удаления заполненных корзин ограничения
+// частоты запросов из хранилища.
+const rateLimitSweepPeriod = 10 * time.Minute
+
+// shutdownTimeout максимальная длительность остановки серверов и завершения удаления URL.
+const shutdownTimeout = 15 * time.Second
+
+const (
+	// probeTimeout максимальная длительность одной проверки работоспособности сервиса.
+	probeTimeout = 2 * time.Second
+	// probePeriod периодичность обновления состояния сервиса проверки состояния gRPC.
+	probePeriod = 10 * time.Second
+	// deleteQueueMaxBacklog количество URL, ожидающих удаления, при превышении
+	// которого сервис считается не готовым обрабатывать запросы.
+	deleteQueueMaxBacklog = 10000
+)
+
 var (
 	buildVersion = "N/A"
 	buildDate    = "N/A"
@@ -64,16 +126,46 @@ func Execute() error {
 		return err
 	}
 
+	l, err := logger.New(os.Stderr, cfg.LogLevel(), cfg.LogFormat())
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(l)
+
+	otel.SetTextMapPropagator(tracing.NewPropagator())
+	if cfg.TracingExporter() != tracing.ExporterNone {
+		tp, terr := tracing.NewProvider(cfg.TracingExporter(), cfg.TracingEndpoint(), buildVersion, os.Stdout)
+		if terr != nil {
+			return terr
+		}
+		otel.SetTracerProvider(tp)
+
+		defer func() {
+			if serr := tp.Shutdown(context.Background()); serr != nil {
+				l.Error("Error while flushing traces", "error", serr)
+			}
+		}()
+	}
+
+	lc := newLifecycle(logger.WithLogger(context.Background(), l), shutdownTimeout)
+	defer func() {
+		_ = lc.Close()
+	}()
+
 	var file *os.File
 	if cfg.FileStoragePath() != "" {
 		file, err = os.OpenFile(cfg.FileStoragePath(), os.O_RDWR|os.O_CREATE, 0600)
 		if err != nil {
 			return err
 		}
+	}
 
-		defer func(file *os.File) {
-			err = file.Close()
-		}(file)
+	var auditFile *os.File
+	if cfg.AuditFilePath() != "" {
+		auditFile, err = os.OpenFile(cfg.AuditFilePath(), os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+		if err != nil {
+			return err
+		}
 	}
 
 	db, err := sql.Open("pgx", cfg.DatabaseDSN())
@@ -81,132 +173,531 @@ func Execute() error {
 		return err
 	}
 
-	defer func(db *sql.DB) {
-		err = db.Close()
-	}(db)
+	reg := metrics.NewRegistry()
 
-	var store service.Storage
-	store = storage.NewMemory(file)
+	var (
+		store       service.Storage
+		adminStore  service.AdminStorage
+		auditStore  service.AuditStorage
+		limitStore  service.RateLimitStorage
+		metaStore   service.MetadataStorage
+		healthStore service.HealthStorage
+		clickStore  service.ClickStorage
+	)
 	if cfg.DatabaseDSN() != "" {
 		if err = migrations.Up(db); err != nil {
 			return err
 		}
 
-		store = storage.NewPg(db)
+		pg := storage.NewPg(db)
+		pg.SetObserver(metrics.NewStorage(reg, "pg"))
+		metrics.RegisterDBStats(reg, db)
+		store, adminStore, auditStore, limitStore, metaStore, healthStore, clickStore = pg, pg, pg, pg, pg, pg, pg
+	} else {
+		m := storage.NewMemory(file, l)
+		m.SetObserver(metrics.NewStorage(reg, "memory"))
+		store, adminStore, auditStore, limitStore, metaStore, healthStore, clickStore = m, m, storage.NewAuditLog(auditFile), storage.NewTokenBuckets(), m, m, m
+	}
+
+	policy, err := newDestinationPolicy(lc, cfg)
+	if err != nil {
+		return err
+	}
+
+	var geo service.GeoIP
+	if cfg.GeoIPFile() != "" {
+		if geo, err = geoip.NewTable(cfg.GeoIPFile()); err != nil {
+			return err
+		}
+	}
+
+	var en service.LinkEnricher
+	if cfg.MetadataTimeout() > 0 {
+		me := service.NewMetadataEnricher(
+			metaStore,
+			metadata.NewFetcher(
+				metadata.NewClient(cfg.MetadataTimeout(), metadataMaxRedirects),
+				cfg.MetadataTimeout(),
+				cfg.MetadataMaxSize(),
+			),
+			metadataQueueSize,
+		)
+		en = me
+		lc.Go(func(ctx context.Context) {
+			me.Run(ctx, metadataWorkers)
+		})
+	}
+
+	// Переходы сохраняются при остановке фоновых задач, то есть после того, как
+	// HTTP- и gRPC-серверы дождутся завершения текущих запросов.
+	cb := service.NewClickBatcher(clickStore, clickQueueSize, clickBatchSize)
+	lc.Go(func(ctx context.Context) {
+		cb.Run(ctx, clickFlushPeriod)
+	})
+
+	hc := service.NewHealthChecker(
+		healthStore,
+		metadata.NewClient(cfg.HealthCheckTimeout(), metadataMaxRedirects),
+		service.HealthCheckOptions{
+			Interval:     cfg.HealthCheckInterval(),
+			Timeout:      cfg.HealthCheckTimeout(),
+			Concurrency:  cfg.HealthCheckConcurrency(),
+			HostInterval: cfg.HealthCheckHostInterval(),
+			BatchSize:    healthCheckBatchSize,
+		},
+	)
+	if cfg.HealthCheckInterval() > 0 {
+		lc.Go(func(ctx context.Context) {
+			hc.Run(ctx, healthCheckPeriod)
+		})
 	}
 
 	var (
 		r  = chi.NewRouter()
 		cp = security.NewHMACTokenCreatorParser(cfg.HMACKey())
 		a  = security.NewAuthenticator(
-			security.NewCookieTokenStorage(cp),
+			security.NewBearerTokenStorage(cp, security.NewCookieTokenStorage(cp, security.CookieAttributes{
+				SameSite: cfg.CookieSameSite(),
+				MaxAge:   cfg.CookieMaxAge(),
+				Secure:   cfg.CookieSecure(),
+			})),
 			&security.RequestContextUserProvider{},
 		)
 		ga = security.NewGRPCAuthenticator(cp, security.NewGRPCContextUserProvider())
 		wg = &sync.WaitGroup{}
-		ss = service.NewShortener(store)
-		sh = handler.NewShortenURL(a, ss, cfg.BaseURL(), wg)
+		au = service.NewAuditor(auditStore, cfg.AuditRetention())
+		cn = validator.NewCanonicalizer(cfg.SortQueryParams(), cfg.StripQueryParams())
+		rl = service.NewRateLimiter(limitStore, cfg.RateLimits(), cfg.RateLimitByIP())
+		ss = service.NewShortener(store, au, policy, cn, rl, geo, en, cb)
+		sh = handler.NewShortenURL(a, ss, cfg.BaseURL(), cfg.RedirectStatus(), wg)
 		dh = handler.NewDatabase(service.NewPinger(db))
+		pr = probe.NewRegistry(probeTimeout)
+		ph = handler.NewProbe(pr)
+		gh = health.NewServer()
+		gf = &probe.Flag{}
+		aa = security.NewAdminAuthorizer(a, cfg.AdminUserIDs(), cfg.AdminAPIKey())
+		ah = handler.NewAdmin(aa, service.NewAdmin(adminStore, au), cfg.BaseURL())
+		uh = handler.NewAudit(a, au)
+		qr = service.NewQRCodes(adminStore, cfg.BaseURL(), qrCodeCacheSize)
+		qh = handler.NewQRCode(a, qr)
+		hh = handler.NewLinkHealth(hc)
+		ip = clientinfo.NewResolver(cfg.TrustedProxies())
+		rm = middleware.RedirectMetrics(metrics.NewRedirects(reg))
 	)
+	metrics.RegisterDeleteQueue(reg, sh.PendingDeletes)
 
-	go func() {
-		if err = startGRPCServer(cfg, ss, ga); err != nil {
-			log.Printf("GRPC server error: %v", err)
-		}
-	}()
+	gl := gateway.NewListener()
+	gw, err := gateway.New(gl, a, otel.GetTextMapPropagator())
+	if err != nil {
+		return fmt.Errorf("grpc gateway: %w", err)
+	}
+	lc.OnStop("grpc gateway", func(context.Context) error {
+		return gw.Close()
+	})
 
+	pr.Register("grpc", probe.Readiness, gf)
+	pr.Register("delete_queue", probe.Readiness, probe.Backlog(sh.PendingDeletes, deleteQueueMaxBacklog))
+	if cfg.DatabaseDSN() != "" {
+		pr.Register("database", probe.Readiness, probe.Database(db))
+	} else if cfg.FileStoragePath() != "" {
+		pr.Register("file_storage", probe.Readiness, probe.FileWritable(cfg.FileStoragePath()))
+	}
+	lc.Go(func(ctx context.Context) {
+		probe.WatchGRPC(ctx, pr, gh, probePeriod, proto.Shortener_ServiceDesc.ServiceName)
+	})
+	lc.Go(func(ctx context.Context) {
+		au.RunRetention(ctx, time.Hour)
+	})
+	lc.Go(func(ctx context.Context) {
+		rl.RunSweep(ctx, rateLimitSweepPeriod)
+	})
+
+	r.Use(middleware.Tracing(otel.GetTracerProvider(), otel.GetTextMapPropagator()))
+	r.Use(middleware.Metrics(metrics.NewHTTP(reg)))
+	r.Use(middleware.RequestID(l))
+	r.Use(middleware.ClientInfo(ip))
+	r.Use(middleware.AccessLog())
 	r.Use(chimiddleware.Recoverer)
 	r.Use(chimiddleware.Compress(flate.BestSpeed))
 	r.Use(middleware.Decompress())
+	r.Use(middleware.CSRF(security.NewCSRFProtector(cfg.TrustedOrigins())))
 	r.Use(middleware.Authenticate(a))
 
 	r.Mount("/debug", chimiddleware.Profiler())
-	r.Post("/", sh.Create)
-	r.Get("/{id:[A-Za-z0-9_-]+}", sh.Get)
-	r.Post("/api/shorten", sh.CreateJSON)
-	r.Post("/api/shorten/batch", sh.CreateBatch)
-	r.Get("/api/user/urls", sh.GetAllByCurrentUser)
-	r.Delete("/api/user/urls", sh.DeleteBatch)
-	r.With(middleware.Internal(cfg.TrustedSubnet())).Get("/api/internal/stats", sh.GetStat)
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.RateLimit(rl, a, model.RateLimitBudgetCreate))
+		r.Post("/", sh.Create)
+		r.Post("/api/shorten", sh.CreateJSON)
+		r.Post("/api/shorten/batch", sh.CreateBatch)
+		r.Put("/api/user/urls/{id}/rules", sh.SetRules)
+		r.Put("/api/user/urls/{id}/variants", sh.SetVariants)
+		r.Put("/api/user/urls/{id}/redirect", sh.SetRedirectOptions)
+	})
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.RateLimit(rl, a, model.RateLimitBudgetRead))
+		r.With(rm).Get("/{id:[A-Za-z0-9_-]+}", sh.Get)
+		r.With(rm).Post("/{id:[A-Za-z0-9_-]+}", sh.Get)
+		r.Get("/{id:[A-Za-z0-9_-]+}+", sh.Preview)
+		r.Post("/{id:[A-Za-z0-9_-]+}+", sh.Preview)
+		r.With(rm).Get("/{id:[A-Za-z0-9_-]+}/*", sh.Get)
+		r.With(rm).Post("/{id:[A-Za-z0-9_-]+}/*", sh.Get)
+		r.Get("/api/user/urls", sh.GetAllByCurrentUser)
+		r.Get("/api/user/urls/{id}/rules", sh.GetRules)
+		r.Get("/api/user/urls/{id}/variants", sh.GetVariants)
+		r.Get("/api/user/urls/{id}/redirect", sh.GetRedirectOptions)
+		r.Get("/api/user/urls/{id}/qr", qh.Get)
+	})
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.RateLimit(rl, a, model.RateLimitBudgetDelete))
+		r.Delete("/api/user/urls", sh.DeleteBatch)
+		r.Post("/api/user/urls/restore", sh.RestoreBatch)
+	})
+	r.Get("/api/user/audit", uh.GetByCurrentUser)
+	r.With(middleware.Internal(cfg.TrustedSubnets())).Get("/api/internal/stats", sh.GetStats)
+	r.With(middleware.Internal(cfg.TrustedSubnets())).Get("/api/internal/audit", uh.GetAll)
+	r.With(middleware.Internal(cfg.TrustedSubnets())).Get("/api/internal/links/health", hh.GetSummary)
+	r.With(middleware.Internal(cfg.TrustedSubnets())).Method(http.MethodGet, "/metrics", reg)
 	r.Get("/ping", dh.Ping)
+	r.Get("/healthz", ph.Liveness)
+	r.Get("/readyz", ph.Readiness)
+	r.Route("/api/admin", func(r chi.Router) {
+		r.Use(middleware.Admin(aa))
+		r.Get("/urls", ah.FindLinks)
+		r.Get("/urls/{id}", ah.GetLink)
+		r.Post("/urls/{id}/disable", ah.DisableLink)
+		r.Post("/urls/{id}/enable", ah.EnableLink)
+		r.Get("/users/{userID}/urls", ah.GetUserLinks)
+		r.Post("/users/{userID}/ban", ah.BanUser)
+		r.Delete("/users/{userID}/ban", ah.UnbanUser)
+	})
+	r.Mount("/api/v2", gw)
+
+	httpTLS, grpcTLS, err := newTLSConfigs(lc, cfg)
+	if err != nil {
+		return fmt.Errorf("tls: %w", err)
+	}
+
+	// gws обслуживает шлюз REST API /api/v2 через соединения внутри процесса,
+	// поэтому создается без TLS с теми же interceptor, что и gs. Запросы к нему
+	// поступают только от HTTP-сервера, поэтому он останавливается шагом остановки
+	// после того, как HTTP-сервер дождется завершения текущих запросов.
+	var (
+		gm  = metrics.NewGRPC(reg)
+		gs  = newGRPCServer(cfg, ss, qr, ga, ip, rl, gm, l, gh, grpcTLS)
+		gws = newGRPCServer(cfg, ss, qr, ga, ip, rl, gm, l, gh, nil)
+	)
+	go func() {
+		if err := gws.Serve(gl); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+			l.Error("GRPC gateway server failed", "error", err)
+		}
+	}()
+	lc.OnStop("grpc gateway server", func(ctx context.Context) error {
+		return gracefulStop(ctx, gws)
+	})
+	if cfg.SinglePort() {
+		err = serveSinglePort(lc, cfg, r, gs, gh, gf, httpTLS)
+	} else {
+		err = serveSeparatePorts(lc, cfg, r, gs, gh, gf, httpTLS)
+	}
+	if err != nil {
+		return err
+	}
+
+	lc.OnStop("delete queue", func(ctx context.Context) error {
+		return waitGroup(ctx, wg)
+	})
+	lc.OnStop("database", func(context.Context) error {
+		return db.Close()
+	})
+	if auditFile != nil {
+		lc.OnStop("audit file", func(context.Context) error {
+			return auditFile.Close()
+		})
+	}
+	if file != nil {
+		lc.OnStop("storage file", func(context.Context) error {
+			return file.Close()
+		})
+	}
 
 	fmt.Printf(buildInfo, buildVersion, buildDate, buildCommit)
 
-	shutdownDone, err := startHTTPServer(cfg, r)
-	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+	sigCtx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+	defer stop()
+
+	if err = lc.Run(sigCtx); err != nil {
 		return err
 	}
 
-	<-shutdownDone
-	wg.Wait()
-
-	log.Println("Server gracefully shutdown")
+	l.Info("Server gracefully shutdown")
 
-	return err
+	return nil
 }
 
-func startHTTPServer(cfg *config.Config, r *chi.Mux) (<-chan struct{}, error) {
+// domainListReloadInterval периодичность проверки изменений файлов со списками доменов.
+const domainListReloadInterval = 30 * time.Second
+
+func newDestinationPolicy(lc *lifecycle, cfg *config.Config) (*validator.DestinationPolicy, error) {
 	var (
-		srv = &http.Server{
-			Addr:    cfg.ServerAddress(),
-			Handler: r,
-		}
-		sigCh          = make(chan os.Signal, 1)
-		shutdown       = make(chan struct{})
-		err      error = nil
+		blocklist, allowlist *validator.DomainList
+		resolver             validator.Resolver
+		err                  error
 	)
+	if cfg.DomainBlocklist() != "" {
+		if blocklist, err = validator.NewDomainList(cfg.DomainBlocklist()); err != nil {
+			return nil, err
+		}
+		lc.Go(func(ctx context.Context) {
+			blocklist.Watch(ctx, domainListReloadInterval)
+		})
+	}
 
-	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
-	go func() {
-		<-sigCh
-		log.Println("Starting server graceful shutdown...")
-		ctx, cancel := context.WithTimeout(context.Background(), time.Second*15)
-		defer cancel()
-
-		if serr := srv.Shutdown(ctx); serr != nil {
-			log.Printf("Error while server gracefully shutdown: %v", serr)
-			if serr := srv.Close(); serr != nil {
-				log.Printf("Error while server force shutdown: %v", serr)
-			}
+	if cfg.DomainAllowlist() != "" {
+		if allowlist, err = validator.NewDomainList(cfg.DomainAllowlist()); err != nil {
+			return nil, err
 		}
-		close(shutdown)
-	}()
+		lc.Go(func(ctx context.Context) {
+			allowlist.Watch(ctx, domainListReloadInterval)
+		})
+	}
 
-	if !cfg.EnableHTTPS() {
-		err = srv.ListenAndServe()
-	} else {
-		cert, cerr := security.CreateCertificate()
-		if cerr != nil {
-			return shutdown, cerr
+	if !cfg.SkipDNSCheck() {
+		resolver = net.DefaultResolver
+	}
+
+	return validator.NewDestinationPolicy(cfg.AllowedSchemes(), blocklist, allowlist, resolver), nil
+}
+
+// certificateReloadInterval периодичность проверки изменений файлов сертификата сервера.
+const certificateReloadInterval = 30 * time.Second
+
+// newTLSConfigs возвращает конфигурации TLS HTTP- и GRPC-серверов или nil для сервера,
+// в котором TLS не включен. Сертификат перечитывается при изменении файлов.
+// В режиме разработки при отсутствии файлов сертификата создается самоподписанный сертификат.
+func newTLSConfigs(lc *lifecycle, cfg *config.Config) (httpTLS, grpcTLS *tls.Config, err error) {
+	if !cfg.EnableHTTPS() && !cfg.GRPCEnableTLS() {
+		return nil, nil, nil
+	}
+
+	if cfg.TLSDevMode() {
+		slog.Warn("Using self-signed development certificate", "path", cfg.TLSCertFile())
+		hosts := []string{"localhost", "127.0.0.1", "::1", hostOf(cfg.ServerAddress()), hostOf(cfg.GRPCServerAddress())}
+		if err = security.EnsureDevCertificate(cfg.TLSCertFile(), cfg.TLSKeyFile(), hosts); err != nil {
+			return nil, nil, err
 		}
-		l, cerr := tls.Listen(
-			"tcp",
-			cfg.ServerAddress(),
-			&tls.Config{
-				Certificates: []tls.Certificate{cert},
-			},
-		)
-		if cerr != nil {
-			return shutdown, cerr
+	}
+
+	certs, err := security.NewCertificateReloader(cfg.TLSCertFile(), cfg.TLSKeyFile())
+	if err != nil {
+		return nil, nil, err
+	}
+	lc.Go(func(ctx context.Context) {
+		certs.Watch(ctx, certificateReloadInterval)
+	})
+
+	opts := security.TLSOptions{
+		MinVersion:   cfg.TLSMinVersion(),
+		CipherSuites: cfg.TLSCipherSuites(),
+	}
+	if cfg.EnableHTTPS() {
+		httpTLS = security.NewServerTLSConfig(certs, opts)
+	}
+	if cfg.GRPCEnableTLS() {
+		if cfg.TLSClientCAFile() != "" {
+			if opts.ClientCAs, err = security.LoadCertPool(cfg.TLSClientCAFile()); err != nil {
+				return nil, nil, err
+			}
 		}
-		defer func(l net.Listener) {
-			err = l.Close()
-		}(l)
+		grpcTLS = security.NewServerTLSConfig(certs, opts)
+	}
 
-		err = srv.Serve(l)
+	return httpTLS, grpcTLS, nil
+}
+
+// hostOf возвращает хост из адреса вида host:port.
+func hostOf(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return ""
 	}
 
-	return shutdown, err
+	return host
 }
 
-func startGRPCServer(cfg *config.Config, s handler.Shortener, a *security.GRPCAuthenticator) error {
-	listen, err := net.Listen("tcp", cfg.GRPCServerAddress())
+// listenHTTP начинает прием соединений HTTP-сервера. Если задана конфигурация
+// TLS tlsCfg, соединения защищаются TLS.
+func listenHTTP(cfg *config.Config, tlsCfg *tls.Config) (net.Listener, error) {
+	if tlsCfg == nil {
+		return net.Listen("tcp", cfg.ServerAddress())
+	}
+
+	return tls.Listen("tcp", cfg.ServerAddress(), tlsCfg)
+}
+
+// serveSeparatePorts добавляет в lc HTTP-сервер с обработчиком h и GRPC-сервер gs,
+// принимающие соединения на разных адресах.
+func serveSeparatePorts(
+	lc *lifecycle,
+	cfg *config.Config,
+	h http.Handler,
+	gs *grpc.Server,
+	gh *health.Server,
+	serving *probe.Flag,
+	httpTLS *tls.Config,
+) error {
+	httpListener, err := listenHTTP(cfg, httpTLS)
+	if err != nil {
+		return fmt.Errorf("http server: %w", err)
+	}
+	grpcListener, err := net.Listen("tcp", cfg.GRPCServerAddress())
 	if err != nil {
+		_ = httpListener.Close()
+
+		return fmt.Errorf("grpc server: %w", err)
+	}
+
+	srv := &http.Server{Handler: h}
+	lc.Serve("http", func() error {
+		return srv.Serve(httpListener)
+	}, func(ctx context.Context) error {
+		return shutdownHTTP(ctx, srv)
+	})
+	lc.Serve("grpc", func() error {
+		serving.Set(true)
+		defer serving.Set(false)
+
+		return gs.Serve(grpcListener)
+	}, func(ctx context.Context) error {
+		gh.Shutdown()
+
+		return gracefulStop(ctx, gs)
+	})
+
+	return nil
+}
+
+// serveSinglePort добавляет в lc HTTP-сервер, который обслуживает на адресе
+// HTTP-сервера и запросы к обработчику h, и запросы gRPC к gs. Без TLS
+// принимаются соединения HTTP/2 без шифрования (h2c).
+func serveSinglePort(
+	lc *lifecycle,
+	cfg *config.Config,
+	h http.Handler,
+	gs *grpc.Server,
+	gh *health.Server,
+	serving *probe.Flag,
+	httpTLS *tls.Config,
+) error {
+	if httpTLS != nil {
+		httpTLS.NextProtos = []string{"h2", "http/1.1"}
+	}
+
+	listener, err := listenHTTP(cfg, httpTLS)
+	if err != nil {
+		return fmt.Errorf("http server: %w", err)
+	}
+
+	var (
+		mux = multiplex.New(gs, h)
+		srv = &http.Server{Handler: mux}
+	)
+	if httpTLS == nil {
+		if srv.Handler, err = multiplex.H2C(srv, mux); err != nil {
+			_ = listener.Close()
+
+			return fmt.Errorf("http server: %w", err)
+		}
+	}
+
+	lc.Serve("http", func() error {
+		serving.Set(true)
+		defer serving.Set(false)
+
+		return srv.Serve(listener)
+	}, func(ctx context.Context) error {
+		gh.Shutdown()
+		err := shutdownHTTP(ctx, srv)
+		if err == nil {
+			err = mux.Wait(ctx)
+		}
+		// GracefulStop не поддерживает запросы, принятые через ServeHTTP,
+		// поэтому сервер останавливается после завершения текущих запросов gRPC.
+		gs.Stop()
+
 		return err
+	})
+
+	return nil
+}
+
+// shutdownHTTP останавливает HTTP-сервер, дожидаясь завершения текущих запросов.
+// Если они не завершились до отмены контекста ctx, соединения закрываются принудительно.
+func shutdownHTTP(ctx context.Context, srv *http.Server) error {
+	if err := srv.Shutdown(ctx); err != nil {
+		_ = srv.Close()
+
+		return err
+	}
+
+	return nil
+}
+
+// gracefulStop останавливает gRPC-сервер, дожидаясь завершения текущих запросов.
+// Если они не завершились до отмены контекста ctx, соединения закрываются принудительно.
+func gracefulStop(ctx context.Context, gs *grpc.Server) error {
+	done := make(chan struct{})
+	go func() {
+		gs.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		gs.Stop()
+
+		return ctx.Err()
+	}
+}
+
+func newGRPCServer(
+	cfg *config.Config,
+	s handler.Shortener,
+	qr handler.QRCodeGenerator,
+	a *security.GRPCAuthenticator,
+	ip *clientinfo.Resolver,
+	rl *service.RateLimiter,
+	gm interceptor.GRPCObserver,
+	l *slog.Logger,
+	hs healthpb.HealthServer,
+	tlsCfg *tls.Config,
+) *grpc.Server {
+	opts := []grpc.ServerOption{grpc.ChainUnaryInterceptor(
+		interceptor.Tracing(otel.GetTracerProvider(), otel.GetTextMapPropagator()),
+		interceptor.Metrics(gm),
+		interceptor.RequestID(l),
+		interceptor.ClientInfo(ip),
+		interceptor.AccessLog(),
+		interceptor.Internal(cfg.TrustedSubnets(), proto.Shortener_GetStats_FullMethodName),
+		interceptor.Authenticate(a),
+		interceptor.RateLimit(rl, a, map[string]string{
+			proto.Shortener_CreateLink_FullMethodName:      model.RateLimitBudgetCreate,
+			proto.Shortener_CreateLinkBatch_FullMethodName: model.RateLimitBudgetCreate,
+			proto.Shortener_GetURL_FullMethodName:          model.RateLimitBudgetRead,
+			proto.Shortener_GetAllURL_FullMethodName:       model.RateLimitBudgetRead,
+			proto.Shortener_DeleteURLBatch_FullMethodName:  model.RateLimitBudgetDelete,
+			proto.Shortener_GetVariantStats_FullMethodName: model.RateLimitBudgetRead,
+			proto.Shortener_GetQRCode_FullMethodName:       model.RateLimitBudgetRead,
+		}),
+	)}
+	if tlsCfg != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsCfg)))
 	}
 
-	gs := grpc.NewServer(grpc.UnaryInterceptor(interceptor.Authenticate(a)))
-	proto.RegisterShortenerServer(gs, handler.NewShortenerGRPCServer(a, s))
+	gs := grpc.NewServer(opts...)
+	proto.RegisterShortenerServer(gs, handler.NewShortenerGRPCServer(a, s, qr))
+	healthpb.RegisterHealthServer(gs, hs)
 
-	return gs.Serve(listen)
+	return gs
 }
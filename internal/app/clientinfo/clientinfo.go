@@ -0,0 +1,39 @@
+// Package clientinfo позволяет передавать через контекст данные о клиенте,
+// выполнившем запрос: транспорт и IP-адрес.
+package clientinfo
+
+import "context"
+
+type contextKey string
+
+const infoKey contextKey = "clientInfo"
+
+// Транспорты, через которые может быть выполнен запрос.
+const (
+	TransportHTTP = "http"
+	TransportGRPC = "grpc"
+)
+
+// InProcessNetwork сеть соединений, установленных внутри процесса, например
+// соединений шлюза REST API с GRPC-сервером. IP-адрес клиента для таких
+// соединений передается в метаданных запроса.
+const InProcessNetwork = "inprocess"
+
+// Info данные о клиенте, выполнившем запрос.
+type Info struct {
+	Transport string
+	IP        string
+}
+
+// WithInfo возвращает копию контекста ctx с данными о клиенте.
+func WithInfo(ctx context.Context, i Info) context.Context {
+	return context.WithValue(ctx, infoKey, i)
+}
+
+// FromContext возвращает данные о клиенте из контекста. Если данные
+// не были установлены, возвращает пустой Info.
+func FromContext(ctx context.Context) Info {
+	i, _ := ctx.Value(infoKey).(Info)
+
+	return i
+}
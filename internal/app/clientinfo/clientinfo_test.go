@@ -0,0 +1,15 @@
+package clientinfo
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithInfo(t *testing.T) {
+	info := Info{Transport: TransportHTTP, IP: "127.0.0.1"}
+
+	assert.Equal(t, Info{}, FromContext(context.Background()), "контекст без данных о клиенте")
+	assert.Equal(t, info, FromContext(WithInfo(context.Background(), info)), "контекст с данными о клиенте")
+}
@@ -0,0 +1,64 @@
+package clientinfo
+
+import (
+	"net"
+	"strings"
+)
+
+// Resolver определяет IP-адрес клиента по адресу непосредственного собеседника
+// и заголовкам X-Forwarded-For и X-Real-IP. Заголовки учитываются, только если
+// собеседник входит в список доверенных прокси, иначе их может подделать клиент.
+type Resolver struct {
+	trustedProxies Subnets
+}
+
+// NewResolver возвращает указатель на новый экземпляр Resolver.
+func NewResolver(trustedProxies Subnets) *Resolver {
+	return &Resolver{trustedProxies: trustedProxies}
+}
+
+// Resolve возвращает IP-адрес клиента. remoteAddr адрес собеседника в формате
+// host:port или host, forwardedFor и realIP значения заголовков X-Forwarded-For
+// и X-Real-IP. Цепочка X-Forwarded-For просматривается справа налево до первого
+// адреса, не входящего в список доверенных прокси.
+func (r Resolver) Resolve(remoteAddr string, forwardedFor []string, realIP string) string {
+	ip := hostIP(remoteAddr)
+	if !r.trustedProxies.Contains(ip) {
+		return ip
+	}
+
+	chain := make([]string, 0)
+	for _, val := range forwardedFor {
+		for _, addr := range strings.Split(val, ",") {
+			chain = append(chain, strings.TrimSpace(addr))
+		}
+	}
+
+	for i := len(chain) - 1; i >= 0; i-- {
+		addr := hostIP(chain[i])
+		if net.ParseIP(addr) == nil {
+			return ip
+		}
+
+		ip = addr
+		if !r.trustedProxies.Contains(ip) {
+			return ip
+		}
+	}
+
+	if len(chain) == 0 {
+		if addr := hostIP(strings.TrimSpace(realIP)); net.ParseIP(addr) != nil {
+			return addr
+		}
+	}
+
+	return ip
+}
+
+func hostIP(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+
+	return strings.Trim(addr, "[]")
+}
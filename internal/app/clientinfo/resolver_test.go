@@ -0,0 +1,81 @@
+package clientinfo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolver_Resolve(t *testing.T) {
+	proxies, err := ParseSubnets([]string{"10.0.0.0/8", "fd00::/8"})
+	require.NoError(t, err)
+	r := NewResolver(proxies)
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor []string
+		realIP       string
+		want         string
+	}{
+		{
+			name:       "прямое подключение",
+			remoteAddr: "203.0.113.1:5000",
+			want:       "203.0.113.1",
+		},
+		{
+			name:         "заголовки от недоверенного собеседника игнорируются",
+			remoteAddr:   "203.0.113.1:5000",
+			forwardedFor: []string{"192.168.0.1"},
+			realIP:       "192.168.0.1",
+			want:         "203.0.113.1",
+		},
+		{
+			name:         "X-Forwarded-For от доверенного прокси",
+			remoteAddr:   "10.0.0.1:5000",
+			forwardedFor: []string{"198.51.100.1, 203.0.113.1"},
+			want:         "203.0.113.1",
+		},
+		{
+			name:         "цепочка доверенных прокси",
+			remoteAddr:   "10.0.0.1:5000",
+			forwardedFor: []string{"198.51.100.1, 203.0.113.1", "10.0.0.2"},
+			want:         "203.0.113.1",
+		},
+		{
+			name:         "все адреса цепочки доверенные",
+			remoteAddr:   "10.0.0.1:5000",
+			forwardedFor: []string{"10.0.0.3, 10.0.0.2"},
+			want:         "10.0.0.3",
+		},
+		{
+			name:         "некорректный адрес в цепочке",
+			remoteAddr:   "10.0.0.1:5000",
+			forwardedFor: []string{"203.0.113.1, unknown"},
+			want:         "10.0.0.1",
+		},
+		{
+			name:       "X-Real-IP от доверенного прокси",
+			remoteAddr: "[fd00::1]:5000",
+			realIP:     "2001:db8::1",
+			want:       "2001:db8::1",
+		},
+		{
+			name:       "некорректный X-Real-IP",
+			remoteAddr: "10.0.0.1:5000",
+			realIP:     "unknown",
+			want:       "10.0.0.1",
+		},
+		{
+			name:       "адрес без порта",
+			remoteAddr: "203.0.113.1",
+			want:       "203.0.113.1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, r.Resolve(tt.remoteAddr, tt.forwardedFor, tt.realIP))
+		})
+	}
+}
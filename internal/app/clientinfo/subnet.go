@@ -0,0 +1,56 @@
+package clientinfo
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Subnets список подсетей IPv4 и IPv6.
+type Subnets []*net.IPNet
+
+// ParseSubnets разбирает список подсетей в нотации CIDR. Пустые значения
+// пропускаются, отдельный IP-адрес считается подсетью из одного адреса.
+func ParseSubnets(cidrs []string) (Subnets, error) {
+	subnets := make(Subnets, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		cidr = strings.TrimSpace(cidr)
+		if cidr == "" {
+			continue
+		}
+
+		if ip := net.ParseIP(cidr); ip != nil {
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			subnets = append(subnets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+
+			continue
+		}
+
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid subnet %q: %w", cidr, err)
+		}
+		subnets = append(subnets, ipNet)
+	}
+
+	return subnets, nil
+}
+
+// Contains возвращает true, если IP-адрес ip входит хотя бы в одну из подсетей.
+func (s Subnets) Contains(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+
+	for _, ipNet := range s {
+		if ipNet.Contains(parsed) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,36 @@
+package clientinfo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSubnets(t *testing.T) {
+	subnets, err := ParseSubnets([]string{"192.168.0.0/24", " 2001:db8::/32 ", "", "10.0.0.1", "::1"})
+	require.NoError(t, err)
+	assert.Len(t, subnets, 4)
+
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{ip: "192.168.0.1", want: true},
+		{ip: "192.168.1.1", want: false},
+		{ip: "2001:db8::1", want: true},
+		{ip: "2001:db9::1", want: false},
+		{ip: "10.0.0.1", want: true},
+		{ip: "10.0.0.2", want: false},
+		{ip: "::1", want: true},
+		{ip: "", want: false},
+		{ip: "host", want: false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, subnets.Contains(tt.ip), tt.ip)
+	}
+
+	_, err = ParseSubnets([]string{"192.168.0.0/24", "192.168.0.0/40"})
+	assert.Error(t, err, "некорректная подсеть")
+	assert.False(t, Subnets(nil).Contains("127.0.0.1"), "пустой список подсетей")
+}
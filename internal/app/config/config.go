@@ -2,16 +2,31 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
 	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/caarlos0/env/v7"
+
+	"github.com/ivanpodgorny/urlshortener/internal/app/clientinfo"
+	"github.com/ivanpodgorny/urlshortener/internal/app/logger"
+	"github.com/ivanpodgorny/urlshortener/internal/app/model"
+	"github.com/ivanpodgorny/urlshortener/internal/app/security"
+	"github.com/ivanpodgorny/urlshortener/internal/app/tracing"
 )
 
 // Config хранит значения параметров приложения и позволяет получить
 // их через геттеры.
 type Config struct {
-	parameters *parameters
+	parameters     *parameters
+	trustedSubnets clientinfo.Subnets
+	trustedProxies clientinfo.Subnets
 }
 
 // Builder реализует методы для загрузки значений параметров.
@@ -30,16 +45,132 @@ type parameters struct {
 	HMACKey           string `env:"HMAC_KEY" json:"hmac_key"`
 	DatabaseDSN       string `env:"DATABASE_DSN" json:"database_dsn"`
 	ConfigFile        string
-	TrustedSubnet     string `env:"TRUSTED_SUBNET" json:"trusted_subnet"`
-	EnableHTTPS       bool   `env:"ENABLE_HTTPS" json:"enable_https"`
+	TrustedSubnet     string   `env:"TRUSTED_SUBNET" json:"trusted_subnet"`
+	EnableHTTPS       bool     `env:"ENABLE_HTTPS" json:"enable_https"`
+	CookieSecure      *bool    `env:"COOKIE_SECURE" json:"cookie_secure"`
+	CookieSameSite    string   `env:"COOKIE_SAME_SITE" json:"cookie_same_site"`
+	CookieMaxAge      int      `env:"COOKIE_MAX_AGE" json:"cookie_max_age"`
+	TrustedOrigins    []string `env:"TRUSTED_ORIGINS" envSeparator:"," json:"trusted_origins"`
+	AdminUserIDs      []string `env:"ADMIN_USER_IDS" envSeparator:"," json:"admin_user_ids"`
+	AdminAPIKey       string   `env:"ADMIN_API_KEY" json:"admin_api_key"`
+	AuditFilePath     string   `env:"AUDIT_FILE_PATH" json:"audit_file_path"`
+	AuditRetention    string   `env:"AUDIT_RETENTION" json:"audit_retention"`
+	TrustedProxies    []string `env:"TRUSTED_PROXIES" envSeparator:"," json:"trusted_proxies"`
+	RateLimitCreate   string   `env:"RATE_LIMIT_CREATE" json:"rate_limit_create"`
+	RateLimitRead     string   `env:"RATE_LIMIT_READ" json:"rate_limit_read"`
+	RateLimitDelete   string   `env:"RATE_LIMIT_DELETE" json:"rate_limit_delete"`
+	RateLimitPassword string   `env:"RATE_LIMIT_PASSWORD" json:"rate_limit_password"`
+	RateLimitByIP     bool     `env:"RATE_LIMIT_BY_IP" json:"rate_limit_by_ip"`
+	AllowedSchemes    []string `env:"ALLOWED_SCHEMES" envSeparator:"," json:"allowed_schemes"`
+	DomainBlocklist   string   `env:"DOMAIN_BLOCKLIST" json:"domain_blocklist"`
+	DomainAllowlist   string   `env:"DOMAIN_ALLOWLIST" json:"domain_allowlist"`
+	SkipDNSCheck      bool     `env:"SKIP_DNS_CHECK" json:"skip_dns_check"`
+	SortQueryParams   bool     `env:"SORT_QUERY_PARAMS" json:"sort_query_params"`
+	StripQueryParams  []string `env:"STRIP_QUERY_PARAMS" envSeparator:"," json:"strip_query_params"`
+	GeoIPFile         string   `env:"GEOIP_FILE" json:"geoip_file"`
+	RedirectStatus    int      `env:"REDIRECT_STATUS" json:"redirect_status"`
+	MetadataTimeout   string   `env:"METADATA_TIMEOUT" json:"metadata_timeout"`
+	MetadataMaxSize   int      `env:"METADATA_MAX_SIZE" json:"metadata_max_size"`
+	HealthCheck       string   `env:"HEALTH_CHECK_INTERVAL" json:"health_check_interval"`
+	HealthTimeout     string   `env:"HEALTH_CHECK_TIMEOUT" json:"health_check_timeout"`
+	HealthConcurrency int      `env:"HEALTH_CHECK_CONCURRENCY" json:"health_check_concurrency"`
+	HealthHostDelay   string   `env:"HEALTH_CHECK_HOST_INTERVAL" json:"health_check_host_interval"`
+	LogLevel          string   `env:"LOG_LEVEL" json:"log_level"`
+	LogFormat         string   `env:"LOG_FORMAT" json:"log_format"`
+	TracingExporter   string   `env:"TRACING_EXPORTER" json:"tracing_exporter"`
+	TracingEndpoint   string   `env:"TRACING_ENDPOINT" json:"tracing_endpoint"`
+	TLSCertFile       string   `env:"TLS_CERT_FILE" json:"tls_cert_file"`
+	TLSKeyFile        string   `env:"TLS_KEY_FILE" json:"tls_key_file"`
+	TLSMinVersion     string   `env:"TLS_MIN_VERSION" json:"tls_min_version"`
+	TLSCipherSuites   []string `env:"TLS_CIPHER_SUITES" envSeparator:"," json:"tls_cipher_suites"`
+	TLSClientCAFile   string   `env:"TLS_CLIENT_CA_FILE" json:"tls_client_ca_file"`
+	TLSDevMode        bool     `env:"TLS_DEV_MODE" json:"tls_dev_mode"`
+	GRPCEnableTLS     bool     `env:"GRPC_ENABLE_TLS" json:"grpc_enable_tls"`
+	SinglePort        bool     `env:"SINGLE_PORT" json:"single_port"`
 }
 
 const (
 	defaultServerAddress     = "localhost:8080"
 	defaultGRPCServerAddress = "localhost:3200"
 	defaultBaseURL           = "http://localhost:8080"
+	defaultCookieSameSite    = "lax"
+	defaultCookieMaxAge      = 365 * 24 * 60 * 60
+	defaultAuditRetention    = "2160h"
+	defaultRateLimitCreate   = "60/m"
+	defaultRateLimitRead     = "600/m"
+	defaultRateLimitDelete   = "60/m"
+	defaultRateLimitPassword = "5/m"
+	defaultRedirectStatus    = http.StatusTemporaryRedirect
+	defaultMetadataTimeout   = "5s"
+	defaultMetadataMaxSize   = 1 << 20
+	defaultHealthCheck       = "24h"
+	defaultHealthTimeout     = "10s"
+	defaultHealthConcurrency = 8
+	defaultHealthHostDelay   = "1s"
+	defaultLogLevel          = "info"
+	defaultLogFormat         = logger.FormatText
+	defaultTLSMinVersion     = "1.2"
+	defaultDevCertFile       = "dev_cert.pem"
+	defaultDevKeyFile        = "dev_key.pem"
 )
 
+var rateLimitPeriods = map[string]time.Duration{
+	"s": time.Second,
+	"m": time.Minute,
+	"h": time.Hour,
+}
+
+var sameSiteModes = map[string]http.SameSite{
+	"lax":    http.SameSiteLaxMode,
+	"strict": http.SameSiteStrictMode,
+	"none":   http.SameSiteNoneMode,
+}
+
+// ErrInvalidCookieSameSite некорректное значение атрибута SameSite.
+var ErrInvalidCookieSameSite = errors.New("cookie same site must be one of: lax, strict, none")
+
+// ErrInvalidCookieMaxAge некорректное значение атрибута Max-Age.
+var ErrInvalidCookieMaxAge = errors.New("cookie max age must not be negative")
+
+// ErrInvalidAuditRetention некорректное значение срока хранения журнала аудита.
+var ErrInvalidAuditRetention = errors.New("audit retention must be a non-negative duration")
+
+// ErrInvalidTrustedSubnet некорректное значение доверенной подсети.
+var ErrInvalidTrustedSubnet = errors.New("invalid trusted subnet")
+
+// ErrInvalidTrustedProxy некорректное значение адреса доверенного прокси-сервера.
+var ErrInvalidTrustedProxy = errors.New("invalid trusted proxy")
+
+// ErrInvalidRateLimit некорректное значение ограничения частоты запросов.
+var ErrInvalidRateLimit = errors.New("rate limit must be in format <requests>/<s|m|h> or 0")
+
+// ErrInvalidRedirectStatus некорректное значение кода ответа при переходе по сокращенному URL.
+var ErrInvalidRedirectStatus = errors.New("redirect status must be one of: 301, 302, 307, 308")
+
+// ErrInvalidMetadataTimeout некорректное значение времени загрузки страницы назначения.
+var ErrInvalidMetadataTimeout = errors.New("metadata timeout must be a non-negative duration")
+
+// ErrInvalidMetadataMaxSize некорректное значение размера загружаемой страницы назначения.
+var ErrInvalidMetadataMaxSize = errors.New("metadata max size must not be negative")
+
+// ErrInvalidHealthCheck некорректное значение параметров проверки адресов назначения.
+var ErrInvalidHealthCheck = errors.New("health check intervals and timeout must be non-negative durations, concurrency must not be negative")
+
+// ErrInvalidLogLevel некорректное значение уровня журнала.
+var ErrInvalidLogLevel = errors.New("log level must be one of: debug, info, warn, error")
+
+// ErrInvalidLogFormat некорректное значение формата журнала.
+var ErrInvalidLogFormat = errors.New("log format must be one of: text, json")
+
+// ErrInvalidTracing некорректное значение параметров экспорта трассировок.
+var ErrInvalidTracing = errors.New("tracing exporter must be one of: stdout, otlp; endpoint must be an http or https URL")
+
+// ErrInvalidTLS некорректное значение параметров TLS.
+var ErrInvalidTLS = errors.New("tls requires certificate and key files unless dev mode is enabled; min version must be one of: 1.2, 1.3; cipher suites must be secure tls 1.2 suite names")
+
+// ErrInvalidSinglePort параметры GRPC-сервера несовместимы с обслуживанием gRPC на порту HTTP-сервера.
+var ErrInvalidSinglePort = errors.New("single port mode uses http server tls settings; grpc tls and client ca must not be set")
+
 // NewBuilder возвращает указатель на новый экземпляр Builder.
 func NewBuilder() *Builder {
 	b := &Builder{
@@ -48,6 +179,23 @@ func NewBuilder() *Builder {
 			ServerAddress:     defaultServerAddress,
 			GRPCServerAddress: defaultGRPCServerAddress,
 			BaseURL:           defaultBaseURL,
+			CookieSameSite:    defaultCookieSameSite,
+			CookieMaxAge:      defaultCookieMaxAge,
+			AuditRetention:    defaultAuditRetention,
+			RateLimitCreate:   defaultRateLimitCreate,
+			RateLimitRead:     defaultRateLimitRead,
+			RateLimitDelete:   defaultRateLimitDelete,
+			RateLimitPassword: defaultRateLimitPassword,
+			RedirectStatus:    defaultRedirectStatus,
+			MetadataTimeout:   defaultMetadataTimeout,
+			MetadataMaxSize:   defaultMetadataMaxSize,
+			HealthCheck:       defaultHealthCheck,
+			HealthTimeout:     defaultHealthTimeout,
+			HealthConcurrency: defaultHealthConcurrency,
+			HealthHostDelay:   defaultHealthHostDelay,
+			LogLevel:          defaultLogLevel,
+			LogFormat:         defaultLogFormat,
+			TLSMinVersion:     defaultTLSMinVersion,
 		},
 		flags: &parameters{},
 	}
@@ -112,6 +260,126 @@ func (b *Builder) LoadFlags() *Builder {
 	if b.flags.TrustedSubnet != "" {
 		b.parameters.TrustedSubnet = b.flags.TrustedSubnet
 	}
+	if b.flags.CookieSecure != nil {
+		b.parameters.CookieSecure = b.flags.CookieSecure
+	}
+	if b.flags.CookieSameSite != "" {
+		b.parameters.CookieSameSite = b.flags.CookieSameSite
+	}
+	if b.flags.CookieMaxAge != 0 {
+		b.parameters.CookieMaxAge = b.flags.CookieMaxAge
+	}
+	if len(b.flags.TrustedOrigins) != 0 {
+		b.parameters.TrustedOrigins = b.flags.TrustedOrigins
+	}
+	if len(b.flags.AdminUserIDs) != 0 {
+		b.parameters.AdminUserIDs = b.flags.AdminUserIDs
+	}
+	if b.flags.AdminAPIKey != "" {
+		b.parameters.AdminAPIKey = b.flags.AdminAPIKey
+	}
+	if b.flags.AuditFilePath != "" {
+		b.parameters.AuditFilePath = b.flags.AuditFilePath
+	}
+	if b.flags.AuditRetention != "" {
+		b.parameters.AuditRetention = b.flags.AuditRetention
+	}
+	if len(b.flags.TrustedProxies) != 0 {
+		b.parameters.TrustedProxies = b.flags.TrustedProxies
+	}
+	if b.flags.RateLimitCreate != "" {
+		b.parameters.RateLimitCreate = b.flags.RateLimitCreate
+	}
+	if b.flags.RateLimitRead != "" {
+		b.parameters.RateLimitRead = b.flags.RateLimitRead
+	}
+	if b.flags.RateLimitDelete != "" {
+		b.parameters.RateLimitDelete = b.flags.RateLimitDelete
+	}
+	if b.flags.RateLimitPassword != "" {
+		b.parameters.RateLimitPassword = b.flags.RateLimitPassword
+	}
+	if b.flags.RateLimitByIP {
+		b.parameters.RateLimitByIP = b.flags.RateLimitByIP
+	}
+	if len(b.flags.AllowedSchemes) != 0 {
+		b.parameters.AllowedSchemes = b.flags.AllowedSchemes
+	}
+	if b.flags.DomainBlocklist != "" {
+		b.parameters.DomainBlocklist = b.flags.DomainBlocklist
+	}
+	if b.flags.DomainAllowlist != "" {
+		b.parameters.DomainAllowlist = b.flags.DomainAllowlist
+	}
+	if b.flags.SkipDNSCheck {
+		b.parameters.SkipDNSCheck = b.flags.SkipDNSCheck
+	}
+	if b.flags.SortQueryParams {
+		b.parameters.SortQueryParams = b.flags.SortQueryParams
+	}
+	if len(b.flags.StripQueryParams) != 0 {
+		b.parameters.StripQueryParams = b.flags.StripQueryParams
+	}
+	if b.flags.GeoIPFile != "" {
+		b.parameters.GeoIPFile = b.flags.GeoIPFile
+	}
+	if b.flags.RedirectStatus != 0 {
+		b.parameters.RedirectStatus = b.flags.RedirectStatus
+	}
+	if b.flags.MetadataTimeout != "" {
+		b.parameters.MetadataTimeout = b.flags.MetadataTimeout
+	}
+	if b.flags.MetadataMaxSize != 0 {
+		b.parameters.MetadataMaxSize = b.flags.MetadataMaxSize
+	}
+	if b.flags.HealthCheck != "" {
+		b.parameters.HealthCheck = b.flags.HealthCheck
+	}
+	if b.flags.HealthTimeout != "" {
+		b.parameters.HealthTimeout = b.flags.HealthTimeout
+	}
+	if b.flags.HealthConcurrency != 0 {
+		b.parameters.HealthConcurrency = b.flags.HealthConcurrency
+	}
+	if b.flags.HealthHostDelay != "" {
+		b.parameters.HealthHostDelay = b.flags.HealthHostDelay
+	}
+	if b.flags.LogLevel != "" {
+		b.parameters.LogLevel = b.flags.LogLevel
+	}
+	if b.flags.LogFormat != "" {
+		b.parameters.LogFormat = b.flags.LogFormat
+	}
+	if b.flags.TracingExporter != "" {
+		b.parameters.TracingExporter = b.flags.TracingExporter
+	}
+	if b.flags.TracingEndpoint != "" {
+		b.parameters.TracingEndpoint = b.flags.TracingEndpoint
+	}
+	if b.flags.TLSCertFile != "" {
+		b.parameters.TLSCertFile = b.flags.TLSCertFile
+	}
+	if b.flags.TLSKeyFile != "" {
+		b.parameters.TLSKeyFile = b.flags.TLSKeyFile
+	}
+	if b.flags.TLSMinVersion != "" {
+		b.parameters.TLSMinVersion = b.flags.TLSMinVersion
+	}
+	if len(b.flags.TLSCipherSuites) != 0 {
+		b.parameters.TLSCipherSuites = b.flags.TLSCipherSuites
+	}
+	if b.flags.TLSClientCAFile != "" {
+		b.parameters.TLSClientCAFile = b.flags.TLSClientCAFile
+	}
+	if b.flags.TLSDevMode {
+		b.parameters.TLSDevMode = b.flags.TLSDevMode
+	}
+	if b.flags.GRPCEnableTLS {
+		b.parameters.GRPCEnableTLS = b.flags.GRPCEnableTLS
+	}
+	if b.flags.SinglePort {
+		b.parameters.SinglePort = b.flags.SinglePort
+	}
 
 	return b
 }
@@ -145,8 +413,171 @@ func (b *Builder) LoadFile() *Builder {
 }
 
 // Build возвращает Config для чтения загруженных значений параметров.
+// Если загруженные значения некорректны, возвращает ошибку валидации.
 func (b *Builder) Build() (*Config, error) {
-	return &Config{b.parameters}, b.err
+	c := &Config{parameters: b.parameters}
+	if b.err == nil {
+		b.err = b.validate()
+	}
+	if b.err == nil {
+		c.trustedSubnets, c.trustedProxies, b.err = b.parseSubnets()
+	}
+
+	return c, b.err
+}
+
+func (b *Builder) validate() error {
+	if _, ok := sameSiteModes[strings.ToLower(b.parameters.CookieSameSite)]; b.parameters.CookieSameSite != "" && !ok {
+		return ErrInvalidCookieSameSite
+	}
+	if b.parameters.CookieMaxAge < 0 {
+		return ErrInvalidCookieMaxAge
+	}
+	if d, err := parseDuration(b.parameters.AuditRetention); err != nil || d < 0 {
+		return ErrInvalidAuditRetention
+	}
+	for _, val := range []string{
+		b.parameters.RateLimitCreate,
+		b.parameters.RateLimitRead,
+		b.parameters.RateLimitDelete,
+		b.parameters.RateLimitPassword,
+	} {
+		if _, err := parseRateLimit(val); err != nil {
+			return fmt.Errorf("%w: %q", ErrInvalidRateLimit, val)
+		}
+	}
+	if b.parameters.RedirectStatus != 0 && !model.IsRedirectStatus(b.parameters.RedirectStatus) {
+		return ErrInvalidRedirectStatus
+	}
+	if d, err := parseDuration(b.parameters.MetadataTimeout); err != nil || d < 0 {
+		return ErrInvalidMetadataTimeout
+	}
+	if b.parameters.MetadataMaxSize < 0 {
+		return ErrInvalidMetadataMaxSize
+	}
+	for _, val := range []string{b.parameters.HealthCheck, b.parameters.HealthTimeout, b.parameters.HealthHostDelay} {
+		if d, err := parseDuration(val); err != nil || d < 0 {
+			return ErrInvalidHealthCheck
+		}
+	}
+	if b.parameters.HealthConcurrency < 0 {
+		return ErrInvalidHealthCheck
+	}
+	if b.parameters.LogLevel != "" && !logger.IsLevel(b.parameters.LogLevel) {
+		return ErrInvalidLogLevel
+	}
+	if b.parameters.LogFormat != "" && !logger.IsFormat(b.parameters.LogFormat) {
+		return ErrInvalidLogFormat
+	}
+	if !tracing.IsExporter(b.parameters.TracingExporter) {
+		return ErrInvalidTracing
+	}
+	if b.parameters.TracingEndpoint != "" {
+		u, err := url.Parse(b.parameters.TracingEndpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return ErrInvalidTracing
+		}
+	}
+	if err := b.validateTLS(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidTLS, err)
+	}
+	if b.parameters.SinglePort && (b.parameters.GRPCEnableTLS || b.parameters.TLSClientCAFile != "") {
+		return ErrInvalidSinglePort
+	}
+
+	return nil
+}
+
+// parseSubnets разбирает доверенные подсети и подсети доверенных прокси-серверов.
+func (b *Builder) parseSubnets() (clientinfo.Subnets, clientinfo.Subnets, error) {
+	subnets, err := clientinfo.ParseSubnets(strings.Split(b.parameters.TrustedSubnet, ","))
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidTrustedSubnet, err)
+	}
+	proxies, err := clientinfo.ParseSubnets(b.parameters.TrustedProxies)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidTrustedProxy, err)
+	}
+
+	return subnets, proxies, nil
+}
+
+func (b *Builder) validateTLS() error {
+	if b.parameters.TLSMinVersion != "" {
+		if _, err := security.ParseTLSVersion(b.parameters.TLSMinVersion); err != nil {
+			return err
+		}
+	}
+	if _, err := security.ParseCipherSuites(b.parameters.TLSCipherSuites); err != nil {
+		return err
+	}
+
+	tlsEnabled := b.parameters.EnableHTTPS || b.parameters.GRPCEnableTLS
+	if tlsEnabled && !b.parameters.TLSDevMode && (b.parameters.TLSCertFile == "" || b.parameters.TLSKeyFile == "") {
+		return errors.New("certificate and key files are required")
+	}
+
+	return nil
+}
+
+func parseDuration(val string) (time.Duration, error) {
+	if val == "" {
+		return 0, nil
+	}
+
+	return time.ParseDuration(val)
+}
+
+// parseRateLimit разбирает ограничение частоты запросов в формате
+// <количество запросов>/<s|m|h>. Пустое значение и 0 отключают ограничение.
+func parseRateLimit(val string) (model.RateLimit, error) {
+	if val == "" || val == "0" {
+		return model.RateLimit{}, nil
+	}
+
+	count, unit, ok := strings.Cut(val, "/")
+	period, known := rateLimitPeriods[unit]
+	if !ok || !known {
+		return model.RateLimit{}, ErrInvalidRateLimit
+	}
+
+	n, err := strconv.Atoi(count)
+	if err != nil || n <= 0 {
+		return model.RateLimit{}, ErrInvalidRateLimit
+	}
+
+	return model.RateLimit{
+		Rate:  float64(n) / period.Seconds(),
+		Burst: n,
+	}, nil
+}
+
+// optionalBool флаг командной строки для логического параметра,
+// незаданное значение которого отличается от false.
+type optionalBool struct {
+	val **bool
+}
+
+func (f optionalBool) String() string {
+	if f.val == nil || *f.val == nil {
+		return ""
+	}
+
+	return strconv.FormatBool(**f.val)
+}
+
+func (f optionalBool) Set(s string) error {
+	v, err := strconv.ParseBool(s)
+	if err != nil {
+		return err
+	}
+	*f.val = &v
+
+	return nil
+}
+
+func (f optionalBool) IsBoolFlag() bool {
+	return true
 }
 
 func (b *Builder) prepareFlags() {
@@ -156,7 +587,71 @@ func (b *Builder) prepareFlags() {
 	flag.StringVar(&b.flags.FileStoragePath, "f", b.parameters.FileStoragePath, "путь к файлу для хранения сокращенных URL")
 	flag.StringVar(&b.flags.DatabaseDSN, "d", b.parameters.DatabaseDSN, "адрес подключения к PostgreSQL")
 	flag.BoolVar(&b.flags.EnableHTTPS, "s", b.parameters.EnableHTTPS, "включает HTTPS в веб-сервере")
-	flag.StringVar(&b.flags.TrustedSubnet, "t", b.parameters.TrustedSubnet, "CIDR доверенной подсети")
+	flag.StringVar(&b.flags.TrustedSubnet, "t", b.parameters.TrustedSubnet, "CIDR доверенных подсетей через запятую")
+	flag.Var(optionalBool{&b.flags.CookieSecure}, "cookie-secure", "устанавливает атрибут Secure для cookie, по умолчанию — при включенном HTTPS")
+	flag.StringVar(&b.flags.CookieSameSite, "cookie-same-site", b.parameters.CookieSameSite, "значение атрибута SameSite для cookie: lax, strict или none")
+	flag.IntVar(&b.flags.CookieMaxAge, "cookie-max-age", b.parameters.CookieMaxAge, "время жизни cookie в секундах")
+	flag.Func("trusted-origins", "список доверенных Origin через запятую для защиты от CSRF", func(s string) error {
+		b.flags.TrustedOrigins = strings.Split(s, ",")
+
+		return nil
+	})
+	flag.Func("admin-user-ids", "список ID пользователей-администраторов через запятую", func(s string) error {
+		b.flags.AdminUserIDs = strings.Split(s, ",")
+
+		return nil
+	})
+	flag.StringVar(&b.flags.AdminAPIKey, "admin-api-key", b.parameters.AdminAPIKey, "API-ключ администратора")
+	flag.StringVar(&b.flags.AuditFilePath, "audit-file", b.parameters.AuditFilePath, "путь к файлу журнала аудита")
+	flag.StringVar(&b.flags.AuditRetention, "audit-retention", b.parameters.AuditRetention, "срок хранения записей журнала аудита, 0 — бессрочно")
+	flag.Func("trusted-proxies", "список IP-адресов и CIDR доверенных прокси-серверов через запятую", func(s string) error {
+		b.flags.TrustedProxies = strings.Split(s, ",")
+
+		return nil
+	})
+	flag.StringVar(&b.flags.RateLimitCreate, "rate-limit-create", b.parameters.RateLimitCreate, "ограничение частоты создания URL в формате <запросов>/<s|m|h>, 0 — без ограничения")
+	flag.StringVar(&b.flags.RateLimitRead, "rate-limit-read", b.parameters.RateLimitRead, "ограничение частоты чтения URL в формате <запросов>/<s|m|h>, 0 — без ограничения")
+	flag.StringVar(&b.flags.RateLimitDelete, "rate-limit-delete", b.parameters.RateLimitDelete, "ограничение частоты удаления URL в формате <запросов>/<s|m|h>, 0 — без ограничения")
+	flag.StringVar(&b.flags.RateLimitPassword, "rate-limit-password", b.parameters.RateLimitPassword, "ограничение частоты попыток ввода пароля к URL в формате <запросов>/<s|m|h>, 0 — без ограничения")
+	flag.BoolVar(&b.flags.RateLimitByIP, "rate-limit-by-ip", b.parameters.RateLimitByIP, "включает ограничение частоты создания, чтения и удаления URL по IP-адресу клиента; за прокси-сервером требует trusted-proxies")
+	flag.Func("allowed-schemes", "список разрешенных схем адресов назначения через запятую", func(s string) error {
+		b.flags.AllowedSchemes = strings.Split(s, ",")
+
+		return nil
+	})
+	flag.StringVar(&b.flags.DomainBlocklist, "domain-blocklist", b.parameters.DomainBlocklist, "путь к файлу со списком заблокированных доменов")
+	flag.StringVar(&b.flags.DomainAllowlist, "domain-allowlist", b.parameters.DomainAllowlist, "путь к файлу со списком разрешенных доменов")
+	flag.BoolVar(&b.flags.SkipDNSCheck, "skip-dns-check", b.parameters.SkipDNSCheck, "не проверять IP-адреса хостов адресов назначения")
+	flag.BoolVar(&b.flags.SortQueryParams, "sort-query-params", b.parameters.SortQueryParams, "сортировать параметры запроса при приведении URL к каноническому виду")
+	flag.Func("strip-query-params", "параметры запроса, удаляемые из канонического URL, через запятую, например utm_*", func(s string) error {
+		b.flags.StripQueryParams = strings.Split(s, ",")
+
+		return nil
+	})
+	flag.StringVar(&b.flags.GeoIPFile, "geoip-file", b.parameters.GeoIPFile, "путь к файлу с таблицей подсетей и кодов стран для правил условного редиректа")
+	flag.IntVar(&b.flags.RedirectStatus, "redirect-status", b.parameters.RedirectStatus, "код ответа при переходе по сокращенному URL по умолчанию: 301, 302, 307 или 308")
+	flag.StringVar(&b.flags.MetadataTimeout, "metadata-timeout", b.parameters.MetadataTimeout, "время загрузки страницы назначения для получения заголовка и тегов OpenGraph, 0 — не загружать")
+	flag.IntVar(&b.flags.MetadataMaxSize, "metadata-max-size", b.parameters.MetadataMaxSize, "максимальный размер загружаемой страницы назначения в байтах")
+	flag.StringVar(&b.flags.HealthCheck, "health-check-interval", b.parameters.HealthCheck, "периодичность проверки доступности адресов назначения, 0 — не проверять")
+	flag.StringVar(&b.flags.HealthTimeout, "health-check-timeout", b.parameters.HealthTimeout, "время ожидания ответа адреса назначения при проверке доступности")
+	flag.IntVar(&b.flags.HealthConcurrency, "health-check-concurrency", b.parameters.HealthConcurrency, "количество одновременных проверок доступности адресов назначения")
+	flag.StringVar(&b.flags.HealthHostDelay, "health-check-host-interval", b.parameters.HealthHostDelay, "минимальный промежуток между проверками адресов назначения на одном хосте")
+	flag.StringVar(&b.flags.LogLevel, "log-level", b.parameters.LogLevel, "уровень журнала: debug, info, warn или error")
+	flag.StringVar(&b.flags.LogFormat, "log-format", b.parameters.LogFormat, "формат записей журнала: text или json")
+	flag.StringVar(&b.flags.TracingExporter, "tracing-exporter", b.parameters.TracingExporter, "экспортер трассировок: stdout или otlp, пустое значение — не экспортировать")
+	flag.StringVar(&b.flags.TracingEndpoint, "tracing-endpoint", b.parameters.TracingEndpoint, "адрес OTLP/HTTP-коллектора трассировок, например http://localhost:4318")
+	flag.StringVar(&b.flags.TLSCertFile, "tls-cert", b.parameters.TLSCertFile, "путь к файлу сертификата сервера в формате PEM")
+	flag.StringVar(&b.flags.TLSKeyFile, "tls-key", b.parameters.TLSKeyFile, "путь к файлу закрытого ключа сервера в формате PEM")
+	flag.StringVar(&b.flags.TLSMinVersion, "tls-min-version", b.parameters.TLSMinVersion, "минимальная версия TLS: 1.2 или 1.3")
+	flag.Func("tls-cipher-suites", "разрешенные наборы шифров TLS 1.2 через запятую, например TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256", func(s string) error {
+		b.flags.TLSCipherSuites = strings.Split(s, ",")
+
+		return nil
+	})
+	flag.StringVar(&b.flags.TLSClientCAFile, "tls-client-ca", b.parameters.TLSClientCAFile, "путь к файлу сертификатов удостоверяющих центров клиентов GRPC-сервера, включает взаимную аутентификацию TLS")
+	flag.BoolVar(&b.flags.TLSDevMode, "tls-dev", b.parameters.TLSDevMode, "создает самоподписанный сертификат, если файлов сертификата нет; только для разработки")
+	flag.BoolVar(&b.flags.GRPCEnableTLS, "grpc-tls", b.parameters.GRPCEnableTLS, "включает TLS в GRPC-сервере")
+	flag.BoolVar(&b.flags.SinglePort, "single-port", b.parameters.SinglePort, "обслуживает gRPC на порту HTTP-сервера по HTTP/2, адрес GRPC-сервера не используется")
 	flag.StringVar(&b.flags.ConfigFile, "c", b.parameters.ConfigFile, "путь к конфигурационному файлу")
 	flag.StringVar(&b.flags.ConfigFile, "config", b.parameters.ConfigFile, "путь к конфигурационному файлу")
 }
@@ -196,7 +691,298 @@ func (c *Config) EnableHTTPS() bool {
 	return c.parameters.EnableHTTPS
 }
 
-// TrustedSubnet возвращает CIDR доверенной подсети.
+// TrustedSubnet возвращает CIDR доверенных подсетей через запятую.
 func (c *Config) TrustedSubnet() string {
 	return c.parameters.TrustedSubnet
 }
+
+// TrustedSubnets возвращает доверенные подсети, из которых разрешен доступ
+// к внутренним методам API.
+func (c *Config) TrustedSubnets() clientinfo.Subnets {
+	return c.trustedSubnets
+}
+
+// TrustedProxies возвращает подсети доверенных прокси-серверов, от которых
+// принимаются заголовки X-Forwarded-For и X-Real-IP. За прокси-сервером параметр
+// обязателен при включенном RateLimitByIP: иначе IP-адресом клиента считается
+// адрес прокси-сервера и ограничение действует на всех клиентов сразу.
+func (c *Config) TrustedProxies() clientinfo.Subnets {
+	return c.trustedProxies
+}
+
+// CookieSecure возвращает значение флага установки атрибута Secure для cookie.
+// Если значение не задано, атрибут устанавливается при включенном HTTPS.
+func (c *Config) CookieSecure() bool {
+	if c.parameters.CookieSecure == nil {
+		return c.parameters.EnableHTTPS
+	}
+
+	return *c.parameters.CookieSecure
+}
+
+// CookieSameSite возвращает значение атрибута SameSite для cookie.
+func (c *Config) CookieSameSite() http.SameSite {
+	if mode, ok := sameSiteModes[strings.ToLower(c.parameters.CookieSameSite)]; ok {
+		return mode
+	}
+
+	return http.SameSiteLaxMode
+}
+
+// CookieMaxAge возвращает время жизни cookie в секундах.
+func (c *Config) CookieMaxAge() int {
+	return c.parameters.CookieMaxAge
+}
+
+// TrustedOrigins возвращает список Origin, запросы с которых не считаются межсайтовыми.
+// Если список не задан, возвращает Origin базового URL сокращенных ссылок.
+func (c *Config) TrustedOrigins() []string {
+	if len(c.parameters.TrustedOrigins) != 0 {
+		return c.parameters.TrustedOrigins
+	}
+
+	u, err := url.Parse(c.parameters.BaseURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return []string{}
+	}
+
+	return []string{u.Scheme + "://" + u.Host}
+}
+
+// AdminUserIDs возвращает список ID пользователей-администраторов.
+func (c *Config) AdminUserIDs() []string {
+	return c.parameters.AdminUserIDs
+}
+
+// AdminAPIKey возвращает API-ключ администратора.
+func (c *Config) AdminAPIKey() string {
+	return c.parameters.AdminAPIKey
+}
+
+// AuditFilePath возвращает путь к файлу журнала аудита.
+func (c *Config) AuditFilePath() string {
+	return c.parameters.AuditFilePath
+}
+
+// AuditRetention возвращает срок хранения записей журнала аудита.
+// Нулевое значение означает бессрочное хранение.
+func (c *Config) AuditRetention() time.Duration {
+	d, _ := parseDuration(c.parameters.AuditRetention)
+
+	return d
+}
+
+// RateLimits возвращает ограничения частоты запросов для групп запросов
+// на создание, чтение и удаление URL и попыток ввода пароля к URL.
+func (c *Config) RateLimits() map[string]model.RateLimit {
+	limits := make(map[string]model.RateLimit, 4)
+	limits[model.RateLimitBudgetCreate], _ = parseRateLimit(c.parameters.RateLimitCreate)
+	limits[model.RateLimitBudgetRead], _ = parseRateLimit(c.parameters.RateLimitRead)
+	limits[model.RateLimitBudgetDelete], _ = parseRateLimit(c.parameters.RateLimitDelete)
+	limits[model.RateLimitBudgetPassword], _ = parseRateLimit(c.parameters.RateLimitPassword)
+
+	return limits
+}
+
+// RateLimitByIP возвращает значение флага ограничения частоты создания, чтения
+// и удаления URL по IP-адресу клиента в дополнение к ограничению по пользователю.
+// По умолчанию отключено: запросы с только что выданным идентификатором
+// пользователя при этом не ограничиваются.
+func (c *Config) RateLimitByIP() bool {
+	return c.parameters.RateLimitByIP
+}
+
+// AllowedSchemes возвращает список разрешенных схем адресов назначения.
+// Если список не задан, разрешены http и https.
+func (c *Config) AllowedSchemes() []string {
+	if len(c.parameters.AllowedSchemes) != 0 {
+		return c.parameters.AllowedSchemes
+	}
+
+	return []string{"http", "https"}
+}
+
+// DomainBlocklist возвращает путь к файлу со списком заблокированных доменов.
+func (c *Config) DomainBlocklist() string {
+	return c.parameters.DomainBlocklist
+}
+
+// DomainAllowlist возвращает путь к файлу со списком разрешенных доменов.
+// Если путь задан, сокращать можно только URL с доменами из списка.
+func (c *Config) DomainAllowlist() string {
+	return c.parameters.DomainAllowlist
+}
+
+// SkipDNSCheck возвращает значение флага отключения проверки IP-адресов
+// хостов адресов назначения после разрешения имен.
+func (c *Config) SkipDNSCheck() bool {
+	return c.parameters.SkipDNSCheck
+}
+
+// SortQueryParams возвращает значение флага сортировки параметров запроса
+// при приведении URL к каноническому виду.
+func (c *Config) SortQueryParams() bool {
+	return c.parameters.SortQueryParams
+}
+
+// StripQueryParams возвращает шаблоны имен параметров запроса, которые удаляются
+// при приведении URL к каноническому виду.
+func (c *Config) StripQueryParams() []string {
+	return c.parameters.StripQueryParams
+}
+
+// GeoIPFile возвращает путь к файлу с таблицей подсетей и кодов стран,
+// по которой определяется страна клиента для правил условного редиректа.
+func (c *Config) GeoIPFile() string {
+	return c.parameters.GeoIPFile
+}
+
+// RedirectStatus возвращает код ответа при переходе по сокращенному URL,
+// для которого не задан собственный код.
+func (c *Config) RedirectStatus() int {
+	return c.parameters.RedirectStatus
+}
+
+// MetadataTimeout возвращает время, за которое должна быть загружена страница назначения
+// нового URL для получения заголовка и тегов OpenGraph. Нулевое значение означает,
+// что страницы назначения не загружаются.
+func (c *Config) MetadataTimeout() time.Duration {
+	d, _ := parseDuration(c.parameters.MetadataTimeout)
+
+	return d
+}
+
+// MetadataMaxSize возвращает максимальный размер загружаемой страницы назначения в байтах.
+func (c *Config) MetadataMaxSize() int64 {
+	if c.parameters.MetadataMaxSize == 0 {
+		return defaultMetadataMaxSize
+	}
+
+	return int64(c.parameters.MetadataMaxSize)
+}
+
+// HealthCheckInterval возвращает периодичность проверки доступности адресов назначения.
+// Нулевое значение означает, что адреса назначения не проверяются.
+func (c *Config) HealthCheckInterval() time.Duration {
+	d, _ := parseDuration(c.parameters.HealthCheck)
+
+	return d
+}
+
+// HealthCheckTimeout возвращает время ожидания ответа адреса назначения при проверке доступности.
+func (c *Config) HealthCheckTimeout() time.Duration {
+	d, _ := parseDuration(c.parameters.HealthTimeout)
+	if d == 0 {
+		d, _ = parseDuration(defaultHealthTimeout)
+	}
+
+	return d
+}
+
+// HealthCheckConcurrency возвращает количество одновременных проверок доступности адресов назначения.
+func (c *Config) HealthCheckConcurrency() int {
+	if c.parameters.HealthConcurrency == 0 {
+		return defaultHealthConcurrency
+	}
+
+	return c.parameters.HealthConcurrency
+}
+
+// HealthCheckHostInterval возвращает минимальный промежуток между проверками
+// адресов назначения на одном хосте.
+func (c *Config) HealthCheckHostInterval() time.Duration {
+	d, _ := parseDuration(c.parameters.HealthHostDelay)
+
+	return d
+}
+
+// LogLevel возвращает уровень журнала.
+func (c *Config) LogLevel() string {
+	if c.parameters.LogLevel == "" {
+		return defaultLogLevel
+	}
+
+	return c.parameters.LogLevel
+}
+
+// LogFormat возвращает формат записей журнала.
+func (c *Config) LogFormat() string {
+	if c.parameters.LogFormat == "" {
+		return defaultLogFormat
+	}
+
+	return c.parameters.LogFormat
+}
+
+// TracingExporter возвращает имя экспортера трассировок или пустую строку,
+// если трассировки не экспортируются.
+func (c *Config) TracingExporter() string {
+	return c.parameters.TracingExporter
+}
+
+// TracingEndpoint возвращает адрес OTLP/HTTP-коллектора трассировок.
+func (c *Config) TracingEndpoint() string {
+	return c.parameters.TracingEndpoint
+}
+
+// TLSCertFile возвращает путь к файлу сертификата сервера. В режиме разработки
+// без заданного пути возвращает путь к самоподписанному сертификату по умолчанию.
+func (c *Config) TLSCertFile() string {
+	if c.parameters.TLSCertFile == "" && c.parameters.TLSDevMode {
+		return defaultDevCertFile
+	}
+
+	return c.parameters.TLSCertFile
+}
+
+// TLSKeyFile возвращает путь к файлу закрытого ключа сервера. В режиме разработки
+// без заданного пути возвращает путь к ключу самоподписанного сертификата по умолчанию.
+func (c *Config) TLSKeyFile() string {
+	if c.parameters.TLSKeyFile == "" && c.parameters.TLSDevMode {
+		return defaultDevKeyFile
+	}
+
+	return c.parameters.TLSKeyFile
+}
+
+// TLSMinVersion возвращает минимальную версию TLS.
+func (c *Config) TLSMinVersion() uint16 {
+	if v, err := security.ParseTLSVersion(c.parameters.TLSMinVersion); err == nil {
+		return v
+	}
+
+	v, _ := security.ParseTLSVersion(defaultTLSMinVersion)
+
+	return v
+}
+
+// TLSCipherSuites возвращает разрешенные наборы шифров TLS 1.2. Пустое значение
+// означает наборы по умолчанию.
+func (c *Config) TLSCipherSuites() []uint16 {
+	suites, _ := security.ParseCipherSuites(c.parameters.TLSCipherSuites)
+
+	return suites
+}
+
+// TLSClientCAFile возвращает путь к файлу сертификатов удостоверяющих центров
+// клиентов GRPC-сервера. Если путь задан, GRPC-сервер требует сертификат клиента.
+func (c *Config) TLSClientCAFile() string {
+	return c.parameters.TLSClientCAFile
+}
+
+// TLSDevMode возвращает значение флага режима разработки, в котором при отсутствии
+// файлов сертификата создается и сохраняется самоподписанный сертификат.
+func (c *Config) TLSDevMode() bool {
+	return c.parameters.TLSDevMode
+}
+
+// GRPCEnableTLS возвращает значение флага включения TLS в GRPC-сервере.
+func (c *Config) GRPCEnableTLS() bool {
+	return c.parameters.GRPCEnableTLS
+}
+
+// SinglePort возвращает значение флага обслуживания gRPC на порту HTTP-сервера.
+// В этом режиме TLS для gRPC определяется параметрами HTTPS.
+func (c *Config) SinglePort() bool {
+	return c.parameters.SinglePort
+}
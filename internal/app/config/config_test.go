@@ -1,12 +1,17 @@
 package config
 
 import (
+	"crypto/tls"
 	"encoding/json"
+	"net/http"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/ivanpodgorny/urlshortener/internal/app/model"
 )
 
 var flagParameters = &parameters{}
@@ -21,6 +26,15 @@ func TestBuilder_LoadEnv(t *testing.T) {
 		databaseDSN       = "dsn"
 		enableHTTPS       = "true"
 		trustedSubnet     = "192.168.0.0/24"
+		cookieSameSite    = "strict"
+		cookieMaxAge      = "3600"
+		trustedOrigins    = "https://a.example,https://b.example"
+		adminUserIDs      = "id1,id2"
+		adminAPIKey       = "admin-key"
+		auditFilePath     = "/audit"
+		auditRetention    = "24h"
+		trustedProxies    = "10.0.0.1,fd00::/8"
+		rateLimitCreate   = "10/s"
 		builder           = &Builder{
 			parameters: &parameters{},
 		}
@@ -34,6 +48,39 @@ func TestBuilder_LoadEnv(t *testing.T) {
 	require.NoError(t, os.Setenv("DATABASE_DSN", databaseDSN))
 	require.NoError(t, os.Setenv("ENABLE_HTTPS", enableHTTPS))
 	require.NoError(t, os.Setenv("TRUSTED_SUBNET", trustedSubnet))
+	require.NoError(t, os.Setenv("COOKIE_SAME_SITE", cookieSameSite))
+	require.NoError(t, os.Setenv("COOKIE_MAX_AGE", cookieMaxAge))
+	require.NoError(t, os.Setenv("TRUSTED_ORIGINS", trustedOrigins))
+	require.NoError(t, os.Setenv("ADMIN_USER_IDS", adminUserIDs))
+	require.NoError(t, os.Setenv("ADMIN_API_KEY", adminAPIKey))
+	require.NoError(t, os.Setenv("AUDIT_FILE_PATH", auditFilePath))
+	require.NoError(t, os.Setenv("AUDIT_RETENTION", auditRetention))
+	require.NoError(t, os.Setenv("TRUSTED_PROXIES", trustedProxies))
+	require.NoError(t, os.Setenv("RATE_LIMIT_CREATE", rateLimitCreate))
+	require.NoError(t, os.Setenv("RATE_LIMIT_READ", "0"))
+	require.NoError(t, os.Setenv("RATE_LIMIT_BY_IP", "true"))
+	require.NoError(t, os.Setenv("ALLOWED_SCHEMES", "https"))
+	require.NoError(t, os.Setenv("DOMAIN_BLOCKLIST", "/blocklist"))
+	require.NoError(t, os.Setenv("GEOIP_FILE", "/geoip.csv"))
+	require.NoError(t, os.Setenv("DOMAIN_ALLOWLIST", "/allowlist"))
+	require.NoError(t, os.Setenv("SKIP_DNS_CHECK", "true"))
+	require.NoError(t, os.Setenv("SORT_QUERY_PARAMS", "true"))
+	require.NoError(t, os.Setenv("STRIP_QUERY_PARAMS", "utm_*,fbclid"))
+	require.NoError(t, os.Setenv("REDIRECT_STATUS", "308"))
+	require.NoError(t, os.Setenv("METADATA_TIMEOUT", "2s"))
+	require.NoError(t, os.Setenv("METADATA_MAX_SIZE", "65536"))
+	require.NoError(t, os.Setenv("HEALTH_CHECK_INTERVAL", "1h"))
+	require.NoError(t, os.Setenv("HEALTH_CHECK_CONCURRENCY", "2"))
+	require.NoError(t, os.Setenv("HEALTH_CHECK_HOST_INTERVAL", "500ms"))
+	require.NoError(t, os.Setenv("LOG_LEVEL", "debug"))
+	require.NoError(t, os.Setenv("TRACING_EXPORTER", "otlp"))
+	require.NoError(t, os.Setenv("TRACING_ENDPOINT", "http://collector:4318"))
+	require.NoError(t, os.Setenv("TLS_CERT_FILE", "/cert.pem"))
+	require.NoError(t, os.Setenv("TLS_KEY_FILE", "/key.pem"))
+	require.NoError(t, os.Setenv("TLS_MIN_VERSION", "1.3"))
+	require.NoError(t, os.Setenv("TLS_CIPHER_SUITES", "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"))
+	require.NoError(t, os.Setenv("TLS_CLIENT_CA_FILE", "/ca.pem"))
+	require.NoError(t, os.Setenv("GRPC_ENABLE_TLS", "true"))
 
 	cfg, err := builder.LoadEnv().Build()
 	require.NoError(t, err)
@@ -45,6 +92,83 @@ func TestBuilder_LoadEnv(t *testing.T) {
 	assert.Equal(t, databaseDSN, cfg.DatabaseDSN())
 	assert.True(t, cfg.EnableHTTPS())
 	assert.Equal(t, trustedSubnet, cfg.TrustedSubnet())
+	assert.True(t, cfg.CookieSecure(), "Secure при включенном HTTPS")
+	assert.Equal(t, http.SameSiteStrictMode, cfg.CookieSameSite())
+	assert.Equal(t, 3600, cfg.CookieMaxAge())
+	assert.Equal(t, []string{"https://a.example", "https://b.example"}, cfg.TrustedOrigins())
+	assert.Equal(t, []string{"id1", "id2"}, cfg.AdminUserIDs())
+	assert.Equal(t, adminAPIKey, cfg.AdminAPIKey())
+	assert.Equal(t, auditFilePath, cfg.AuditFilePath())
+	assert.Equal(t, 24*time.Hour, cfg.AuditRetention())
+	assert.Len(t, cfg.TrustedProxies(), 2)
+	assert.True(t, cfg.TrustedProxies().Contains("10.0.0.1"))
+	assert.True(t, cfg.TrustedProxies().Contains("fd00::1"))
+	assert.Equal(t, map[string]model.RateLimit{
+		model.RateLimitBudgetCreate:   {Rate: 10, Burst: 10},
+		model.RateLimitBudgetRead:     {},
+		model.RateLimitBudgetDelete:   {},
+		model.RateLimitBudgetPassword: {},
+	}, cfg.RateLimits())
+	assert.True(t, cfg.RateLimitByIP())
+	assert.Equal(t, []string{"https"}, cfg.AllowedSchemes())
+	assert.Equal(t, "/blocklist", cfg.DomainBlocklist())
+	assert.Equal(t, "/geoip.csv", cfg.GeoIPFile())
+	assert.Equal(t, "/allowlist", cfg.DomainAllowlist())
+	assert.True(t, cfg.SkipDNSCheck())
+	assert.True(t, cfg.SortQueryParams())
+	assert.Equal(t, []string{"utm_*", "fbclid"}, cfg.StripQueryParams())
+	assert.Equal(t, http.StatusPermanentRedirect, cfg.RedirectStatus())
+	assert.Equal(t, 2*time.Second, cfg.MetadataTimeout())
+	assert.Equal(t, int64(65536), cfg.MetadataMaxSize())
+	assert.Equal(t, time.Hour, cfg.HealthCheckInterval())
+	assert.Equal(t, 10*time.Second, cfg.HealthCheckTimeout())
+	assert.Equal(t, 2, cfg.HealthCheckConcurrency())
+	assert.Equal(t, 500*time.Millisecond, cfg.HealthCheckHostInterval())
+	assert.Equal(t, "debug", cfg.LogLevel())
+	assert.Equal(t, "text", cfg.LogFormat())
+	assert.Equal(t, "otlp", cfg.TracingExporter())
+	assert.Equal(t, "http://collector:4318", cfg.TracingEndpoint())
+	assert.Equal(t, "/cert.pem", cfg.TLSCertFile())
+	assert.Equal(t, "/key.pem", cfg.TLSKeyFile())
+	assert.Equal(t, uint16(tls.VersionTLS13), cfg.TLSMinVersion())
+	assert.Equal(t, []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256, tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256}, cfg.TLSCipherSuites())
+	assert.Equal(t, "/ca.pem", cfg.TLSClientCAFile())
+	assert.False(t, cfg.TLSDevMode())
+	assert.True(t, cfg.GRPCEnableTLS())
+
+	require.NoError(t, os.Unsetenv("COOKIE_SAME_SITE"))
+	require.NoError(t, os.Unsetenv("COOKIE_MAX_AGE"))
+	require.NoError(t, os.Unsetenv("TRUSTED_ORIGINS"))
+	require.NoError(t, os.Unsetenv("ADMIN_USER_IDS"))
+	require.NoError(t, os.Unsetenv("ADMIN_API_KEY"))
+	require.NoError(t, os.Unsetenv("AUDIT_FILE_PATH"))
+	require.NoError(t, os.Unsetenv("AUDIT_RETENTION"))
+	require.NoError(t, os.Unsetenv("TRUSTED_PROXIES"))
+	require.NoError(t, os.Unsetenv("RATE_LIMIT_CREATE"))
+	require.NoError(t, os.Unsetenv("RATE_LIMIT_READ"))
+	require.NoError(t, os.Unsetenv("RATE_LIMIT_BY_IP"))
+	require.NoError(t, os.Unsetenv("ALLOWED_SCHEMES"))
+	require.NoError(t, os.Unsetenv("DOMAIN_BLOCKLIST"))
+	require.NoError(t, os.Unsetenv("GEOIP_FILE"))
+	require.NoError(t, os.Unsetenv("DOMAIN_ALLOWLIST"))
+	require.NoError(t, os.Unsetenv("SKIP_DNS_CHECK"))
+	require.NoError(t, os.Unsetenv("SORT_QUERY_PARAMS"))
+	require.NoError(t, os.Unsetenv("STRIP_QUERY_PARAMS"))
+	require.NoError(t, os.Unsetenv("REDIRECT_STATUS"))
+	require.NoError(t, os.Unsetenv("METADATA_TIMEOUT"))
+	require.NoError(t, os.Unsetenv("METADATA_MAX_SIZE"))
+	require.NoError(t, os.Unsetenv("HEALTH_CHECK_INTERVAL"))
+	require.NoError(t, os.Unsetenv("HEALTH_CHECK_CONCURRENCY"))
+	require.NoError(t, os.Unsetenv("HEALTH_CHECK_HOST_INTERVAL"))
+	require.NoError(t, os.Unsetenv("LOG_LEVEL"))
+	require.NoError(t, os.Unsetenv("TRACING_EXPORTER"))
+	require.NoError(t, os.Unsetenv("TRACING_ENDPOINT"))
+	require.NoError(t, os.Unsetenv("TLS_CERT_FILE"))
+	require.NoError(t, os.Unsetenv("TLS_KEY_FILE"))
+	require.NoError(t, os.Unsetenv("TLS_MIN_VERSION"))
+	require.NoError(t, os.Unsetenv("TLS_CIPHER_SUITES"))
+	require.NoError(t, os.Unsetenv("TLS_CLIENT_CA_FILE"))
+	require.NoError(t, os.Unsetenv("GRPC_ENABLE_TLS"))
 }
 
 func TestBuilder_LoadFile(t *testing.T) {
@@ -68,6 +192,8 @@ func TestBuilder_LoadFile(t *testing.T) {
 		DatabaseDSN:       databaseDSN,
 		EnableHTTPS:       true,
 		TrustedSubnet:     trustedSubnet,
+		TLSDevMode:        true,
+		SinglePort:        true,
 	}
 	p, err := json.Marshal(configParameters)
 	require.NoError(t, err)
@@ -93,6 +219,11 @@ func TestBuilder_LoadFile(t *testing.T) {
 	assert.Equal(t, databaseDSN, cfg.DatabaseDSN())
 	assert.True(t, cfg.EnableHTTPS())
 	assert.Equal(t, trustedSubnet, cfg.TrustedSubnet())
+	assert.True(t, cfg.TLSDevMode())
+	assert.True(t, cfg.SinglePort())
+	assert.Equal(t, defaultDevCertFile, cfg.TLSCertFile(), "сертификат режима разработки по умолчанию")
+	assert.Equal(t, defaultDevKeyFile, cfg.TLSKeyFile(), "сертификат режима разработки по умолчанию")
+	assert.Equal(t, uint16(tls.VersionTLS12), cfg.TLSMinVersion())
 
 	_ = os.Remove(f.Name())
 }
@@ -116,6 +247,11 @@ func TestBuilder_LoadFlags(t *testing.T) {
 				"-d", databaseDSN,
 				"-t", trustedSubnet,
 				"-s",
+				"-cookie-secure=false",
+				"-tls-cert", "/cert.pem",
+				"-tls-key", "/key.pem",
+				"-grpc-tls",
+				"-rate-limit-by-ip",
 			},
 		}
 	)
@@ -128,5 +264,240 @@ func TestBuilder_LoadFlags(t *testing.T) {
 	assert.Equal(t, fileStoragePath, cfg.FileStoragePath())
 	assert.Equal(t, databaseDSN, cfg.DatabaseDSN())
 	assert.True(t, cfg.EnableHTTPS())
+	assert.False(t, cfg.CookieSecure(), "Secure отключен явно при включенном HTTPS")
 	assert.Equal(t, trustedSubnet, cfg.TrustedSubnet())
+	assert.Equal(t, "/cert.pem", cfg.TLSCertFile())
+	assert.Equal(t, "/key.pem", cfg.TLSKeyFile())
+	assert.True(t, cfg.GRPCEnableTLS())
+	assert.True(t, cfg.RateLimitByIP())
+}
+
+func TestBuilder_Build(t *testing.T) {
+	tests := []struct {
+		name       string
+		parameters *parameters
+		wantErr    error
+	}{
+		{
+			name: "корректные параметры",
+			parameters: &parameters{
+				CookieSameSite: "None",
+				CookieMaxAge:   60,
+				TrustedSubnet:  "192.168.0.0/24, 2001:db8::/32",
+				TrustedProxies: []string{"10.0.0.1", "fd00::/8"},
+				RateLimitRead:  "600/m",
+			},
+		},
+		{
+			name: "некорректное значение SameSite",
+			parameters: &parameters{
+				CookieSameSite: "always",
+			},
+			wantErr: ErrInvalidCookieSameSite,
+		},
+		{
+			name: "отрицательное время жизни cookie",
+			parameters: &parameters{
+				CookieMaxAge: -1,
+			},
+			wantErr: ErrInvalidCookieMaxAge,
+		},
+		{
+			name: "некорректный срок хранения журнала аудита",
+			parameters: &parameters{
+				AuditRetention: "month",
+			},
+			wantErr: ErrInvalidAuditRetention,
+		},
+		{
+			name: "некорректная доверенная подсеть",
+			parameters: &parameters{
+				TrustedSubnet: "192.168.0.0/24,192.168.1.0/33",
+			},
+			wantErr: ErrInvalidTrustedSubnet,
+		},
+		{
+			name: "некорректный адрес доверенного прокси",
+			parameters: &parameters{
+				TrustedProxies: []string{"proxy"},
+			},
+			wantErr: ErrInvalidTrustedProxy,
+		},
+		{
+			name: "некорректный период ограничения частоты запросов",
+			parameters: &parameters{
+				RateLimitCreate: "10/d",
+			},
+			wantErr: ErrInvalidRateLimit,
+		},
+		{
+			name: "некорректное количество запросов",
+			parameters: &parameters{
+				RateLimitRead: "-1/m",
+			},
+			wantErr: ErrInvalidRateLimit,
+		},
+		{
+			name: "некорректный код ответа при переходе",
+			parameters: &parameters{
+				RedirectStatus: http.StatusOK,
+			},
+			wantErr: ErrInvalidRedirectStatus,
+		},
+		{
+			name: "некорректное время загрузки страницы назначения",
+			parameters: &parameters{
+				MetadataTimeout: "-1s",
+			},
+			wantErr: ErrInvalidMetadataTimeout,
+		},
+		{
+			name: "некорректный размер загружаемой страницы назначения",
+			parameters: &parameters{
+				MetadataMaxSize: -1,
+			},
+			wantErr: ErrInvalidMetadataMaxSize,
+		},
+		{
+			name: "некорректная периодичность проверки адресов назначения",
+			parameters: &parameters{
+				HealthCheck: "daily",
+			},
+			wantErr: ErrInvalidHealthCheck,
+		},
+		{
+			name: "некорректное количество одновременных проверок адресов назначения",
+			parameters: &parameters{
+				HealthConcurrency: -1,
+			},
+			wantErr: ErrInvalidHealthCheck,
+		},
+		{
+			name: "некорректный уровень журнала",
+			parameters: &parameters{
+				LogLevel: "trace",
+			},
+			wantErr: ErrInvalidLogLevel,
+		},
+		{
+			name: "некорректный формат журнала",
+			parameters: &parameters{
+				LogFormat: "xml",
+			},
+			wantErr: ErrInvalidLogFormat,
+		},
+		{
+			name: "неизвестный экспортер трассировок",
+			parameters: &parameters{
+				TracingExporter: "jaeger",
+			},
+			wantErr: ErrInvalidTracing,
+		},
+		{
+			name: "некорректный адрес коллектора трассировок",
+			parameters: &parameters{
+				TracingExporter: "otlp",
+				TracingEndpoint: "collector:4318",
+			},
+			wantErr: ErrInvalidTracing,
+		},
+		{
+			name: "TLS без сертификата",
+			parameters: &parameters{
+				GRPCEnableTLS: true,
+				TLSCertFile:   "/cert.pem",
+			},
+			wantErr: ErrInvalidTLS,
+		},
+		{
+			name: "TLS в режиме разработки",
+			parameters: &parameters{
+				EnableHTTPS: true,
+				TLSDevMode:  true,
+			},
+		},
+		{
+			name: "некорректная минимальная версия TLS",
+			parameters: &parameters{
+				TLSMinVersion: "1.0",
+			},
+			wantErr: ErrInvalidTLS,
+		},
+		{
+			name: "небезопасный набор шифров",
+			parameters: &parameters{
+				TLSCipherSuites: []string{"TLS_RSA_WITH_RC4_128_SHA"},
+			},
+			wantErr: ErrInvalidTLS,
+		},
+		{
+			name: "TLS GRPC-сервера на общем порту",
+			parameters: &parameters{
+				SinglePort:      true,
+				EnableHTTPS:     true,
+				TLSDevMode:      true,
+				TLSClientCAFile: "/ca.pem",
+			},
+			wantErr: ErrInvalidSinglePort,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := (&Builder{parameters: tt.parameters}).Build()
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestConfig_TrustedOrigins(t *testing.T) {
+	cfg := &Config{parameters: &parameters{BaseURL: "https://short.example/path"}}
+	assert.Equal(t, []string{"https://short.example"}, cfg.TrustedOrigins(), "Origin базового URL")
+	assert.False(t, cfg.CookieSecure(), "Secure по умолчанию выключен")
+	assert.Equal(t, http.SameSiteLaxMode, cfg.CookieSameSite(), "SameSite по умолчанию")
+	assert.Equal(t, []string{"http", "https"}, cfg.AllowedSchemes(), "разрешенные схемы по умолчанию")
+}
+
+func TestConfig_CookieSecure(t *testing.T) {
+	var (
+		on  = true
+		off = false
+	)
+	assert.True(t, (&Config{parameters: &parameters{EnableHTTPS: true}}).CookieSecure(), "по умолчанию при включенном HTTPS")
+	assert.False(t, (&Config{parameters: &parameters{EnableHTTPS: true, CookieSecure: &off}}).CookieSecure(), "отключен явно")
+	assert.True(t, (&Config{parameters: &parameters{CookieSecure: &on}}).CookieSecure(), "включен явно без HTTPS")
+
+	require.NoError(t, os.Setenv("ENABLE_HTTPS", "true"))
+	require.NoError(t, os.Setenv("TLS_DEV_MODE", "true"))
+	require.NoError(t, os.Setenv("COOKIE_SECURE", "false"))
+	defer func() {
+		_ = os.Unsetenv("ENABLE_HTTPS")
+		_ = os.Unsetenv("TLS_DEV_MODE")
+		_ = os.Unsetenv("COOKIE_SECURE")
+	}()
+	cfg, err := (&Builder{parameters: &parameters{}}).LoadEnv().Build()
+	require.NoError(t, err)
+	assert.False(t, cfg.CookieSecure(), "COOKIE_SECURE=false при включенном HTTPS")
+}
+
+func TestConfig_RateLimits(t *testing.T) {
+	cfg := &Config{parameters: &parameters{RateLimitCreate: "60/m", RateLimitRead: "3600/h", RateLimitDelete: "0", RateLimitPassword: "10/s"}}
+	assert.Equal(t, map[string]model.RateLimit{
+		model.RateLimitBudgetCreate:   {Rate: 1, Burst: 60},
+		model.RateLimitBudgetRead:     {Rate: 1, Burst: 3600},
+		model.RateLimitBudgetDelete:   {},
+		model.RateLimitBudgetPassword: {Rate: 10, Burst: 10},
+	}, cfg.RateLimits())
+}
+
+func TestConfig_TrustedSubnets(t *testing.T) {
+	cfg, err := (&Builder{parameters: &parameters{TrustedSubnet: "192.168.0.0/24,2001:db8::/32"}}).Build()
+	require.NoError(t, err)
+	subnets := cfg.TrustedSubnets()
+	assert.True(t, subnets.Contains("192.168.0.10"))
+	assert.True(t, subnets.Contains("2001:db8::1"))
+	assert.False(t, subnets.Contains("10.0.0.1"))
+
+	cfg, err = (&Builder{parameters: &parameters{}}).Build()
+	require.NoError(t, err)
+	assert.Empty(t, cfg.TrustedSubnets(), "подсети не заданы")
 }
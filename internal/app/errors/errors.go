@@ -1,6 +1,74 @@
 package errors
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"time"
+)
 
 // ErrURLIsDeleted ошибка при попытке получения удаленного URL.
 var ErrURLIsDeleted = errors.New("url is deleted")
+
+// ErrURLIsDisabled ошибка при попытке получения URL, заблокированного администратором.
+var ErrURLIsDisabled = errors.New("url is disabled")
+
+// ErrURLIsExpired ошибка при попытке получения URL с истекшим сроком действия.
+var ErrURLIsExpired = errors.New("url is expired")
+
+// ErrURLNotFound ошибка при попытке получения несуществующего URL.
+var ErrURLNotFound = errors.New("url not found")
+
+// ErrUserIsBanned ошибка при попытке создания URL заблокированным пользователем.
+var ErrUserIsBanned = errors.New("user is banned")
+
+// ErrDestinationRejected ошибка при попытке сократить URL, адрес назначения
+// которого запрещен политикой безопасности.
+var ErrDestinationRejected = errors.New("destination rejected")
+
+// ErrPasswordRequired ошибка при попытке перехода по защищенному паролем URL без пароля.
+var ErrPasswordRequired = errors.New("password required")
+
+// ErrInvalidPassword ошибка при попытке перехода по защищенному паролем URL с неверным паролем.
+var ErrInvalidPassword = errors.New("invalid password")
+
+// ErrPasswordTooLong ошибка при попытке защитить URL слишком длинным паролем.
+var ErrPasswordTooLong = errors.New("password is too long")
+
+// ErrTooManyPasswordAttempts ошибка при превышении частоты попыток ввода пароля к URL.
+var ErrTooManyPasswordAttempts = errors.New("too many password attempts")
+
+// ErrInvalidExpiry ошибка при попытке создать URL со сроком действия, истекшим к моменту создания.
+var ErrInvalidExpiry = errors.New("expiry must be in the future")
+
+// ErrInvalidRedirectRule ошибка при попытке сохранить некорректное правило условного редиректа.
+var ErrInvalidRedirectRule = errors.New("invalid redirect rule")
+
+// ErrInvalidVariant ошибка при попытке сохранить некорректные варианты адреса назначения.
+var ErrInvalidVariant = errors.New("invalid variant")
+
+// ErrInvalidRedirectOptions ошибка при попытке сохранить некорректные параметры формирования адреса назначения.
+var ErrInvalidRedirectOptions = errors.New("invalid redirect options")
+
+// ErrPathSuffixNotAllowed ошибка при переходе с суффиксом пути по URL, для которого перенос пути не включен.
+var ErrPathSuffixNotAllowed = errors.New("path suffix is not allowed")
+
+// ErrInvalidPathSuffix ошибка при переходе с суффиксом пути, содержащим сегменты "." или "..".
+var ErrInvalidPathSuffix = errors.New("invalid path suffix")
+
+// ErrInvalidQROptions ошибка при запросе QR-кода с некорректными параметрами изображения.
+var ErrInvalidQROptions = errors.New("invalid qr code options")
+
+// PasswordAttemptsError ошибка превышения частоты попыток ввода пароля к URL
+// с временем, через которое можно повторить попытку.
+type PasswordAttemptsError struct {
+	RetryAfter time.Duration
+}
+
+func (e *PasswordAttemptsError) Error() string {
+	return fmt.Sprintf("%v: retry after %s", ErrTooManyPasswordAttempts, e.RetryAfter)
+}
+
+// Unwrap возвращает ErrTooManyPasswordAttempts.
+func (e *PasswordAttemptsError) Unwrap() error {
+	return ErrTooManyPasswordAttempts
+}
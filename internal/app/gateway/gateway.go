@@ -0,0 +1,122 @@
+// Package gateway реализует REST API /api/v2, построенное по HTTP-аннотациям
+// описания GRPC-сервиса Shortener. Запросы передаются GRPC-серверу внутри процесса,
+// поэтому аутентификация, ограничение частоты запросов и проверка доступа
+// выполняются теми же interceptor, что и для GRPC-клиентов.
+package gateway
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"go.opentelemetry.io/otel/propagation"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"github.com/ivanpodgorny/urlshortener/internal/app/clientinfo"
+	"github.com/ivanpodgorny/urlshortener/internal/app/logger"
+	"github.com/ivanpodgorny/urlshortener/internal/app/security"
+	"github.com/ivanpodgorny/urlshortener/internal/proto"
+)
+
+// IdentityProvider интерфейс сервиса для получения ID аутентифицированного пользователя.
+type IdentityProvider interface {
+	UserIdentifier(ctx context.Context) (string, error)
+	IsNewIdentifier(ctx context.Context) bool
+}
+
+// Gateway обработчик REST API, передающий запросы GRPC-серверу.
+type Gateway struct {
+	mux  *runtime.ServeMux
+	conn *grpc.ClientConn
+}
+
+// New возвращает указатель на новый экземпляр Gateway, который передает запросы
+// GRPC-серверу, принимающему соединения из l. ID пользователя, аутентифицированного
+// HTTP-middleware, и признак его создания при обработке запроса, IP-адрес клиента,
+// идентификатор запроса и контекст трассировки передаются в метаданных запроса.
+// Из заголовков HTTP-запроса передается только Authorization, который grpc-gateway
+// передает всегда; GRPC-сервер его не использует.
+func New(l *Listener, a IdentityProvider, p propagation.TextMapPropagator) (*Gateway, error) {
+	conn, err := grpc.Dial(
+		l.Addr().String(),
+		grpc.WithContextDialer(l.Dial),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	mux := runtime.NewServeMux(
+		runtime.WithMarshalerOption(runtime.MIMEWildcard, &runtime.JSONPb{
+			MarshalOptions:   protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true},
+			UnmarshalOptions: protojson.UnmarshalOptions{DiscardUnknown: true},
+		}),
+		runtime.WithIncomingHeaderMatcher(skipHeader),
+		runtime.WithOutgoingHeaderMatcher(skipHeader),
+		runtime.WithMetadata(func(ctx context.Context, _ *http.Request) metadata.MD {
+			return requestMetadata(ctx, a, p)
+		}),
+		runtime.WithErrorHandler(errorHandler),
+	)
+	if err = proto.RegisterShortenerHandler(context.Background(), mux, conn); err != nil {
+		_ = conn.Close()
+
+		return nil, err
+	}
+
+	return &Gateway{
+		mux:  mux,
+		conn: conn,
+	}, nil
+}
+
+// ServeHTTP реализует http.Handler.
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.mux.ServeHTTP(w, r)
+}
+
+// Close закрывает соединение с GRPC-сервером.
+func (g *Gateway) Close() error {
+	return g.conn.Close()
+}
+
+func requestMetadata(ctx context.Context, a IdentityProvider, p propagation.TextMapPropagator) metadata.MD {
+	md := metadata.Pairs(
+		strings.ToLower(logger.RequestIDHeader), logger.RequestID(ctx),
+		"x-real-ip", clientinfo.FromContext(ctx).IP,
+	)
+	if id, err := a.UserIdentifier(ctx); err == nil {
+		md.Set(security.UserIDMetadataKey, id)
+		if a.IsNewIdentifier(ctx) {
+			md.Set(security.NewUserIDMetadataKey, "true")
+		}
+	}
+
+	carrier := propagation.MapCarrier{}
+	p.Inject(ctx, carrier)
+	for key, val := range carrier {
+		md.Set(key, val)
+	}
+
+	return md
+}
+
+func skipHeader(string) (string, bool) {
+	return "", false
+}
+
+// errorHandler отправляет ответ с ошибкой в формате grpc-gateway и переносит
+// метаданные retry-after ответа GRPC-сервера в HTTP-заголовок Retry-After.
+func errorHandler(ctx context.Context, mux *runtime.ServeMux, m runtime.Marshaler, w http.ResponseWriter, r *http.Request, err error) {
+	if md, ok := runtime.ServerMetadataFromContext(ctx); ok {
+		if val := md.TrailerMD.Get("retry-after"); len(val) != 0 {
+			w.Header().Set("Retry-After", val[0])
+		}
+	}
+
+	runtime.DefaultHTTPErrorHandler(ctx, mux, m, w, r, err)
+}
@@ -0,0 +1,160 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/propagation"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/ivanpodgorny/urlshortener/internal/app/clientinfo"
+	"github.com/ivanpodgorny/urlshortener/internal/app/logger"
+	"github.com/ivanpodgorny/urlshortener/internal/proto"
+)
+
+type shortenerServer struct {
+	proto.UnimplementedShortenerServer
+	md   metadata.MD
+	peer net.Addr
+}
+
+func (s *shortenerServer) CreateLinkBatch(ctx context.Context, r *proto.CreateLinkBatchRequest) (*proto.CreateLinkBatchResponse, error) {
+	s.md, _ = metadata.FromIncomingContext(ctx)
+	if p, ok := peer.FromContext(ctx); ok {
+		s.peer = p.Addr
+	}
+
+	resp := &proto.CreateLinkBatchResponse{}
+	for _, item := range r.GetItems() {
+		resp.Urls = append(resp.Urls, &proto.URLData{Url: item.GetUrl(), Id: "id" + item.GetCorrelationId(), CorrelationId: item.GetCorrelationId()})
+	}
+
+	return resp, nil
+}
+
+func (s *shortenerServer) GetURL(ctx context.Context, r *proto.GetURLRequest) (*proto.GetURLResponse, error) {
+	if r.GetPassword() == "" {
+		_ = grpc.SetTrailer(ctx, metadata.Pairs("retry-after", "5"))
+
+		return nil, status.Error(codes.ResourceExhausted, "rate limit exceeded")
+	}
+
+	return &proto.GetURLResponse{Url: "https://example.com/" + r.GetId()}, nil
+}
+
+type identityProvider struct {
+	id    string
+	isNew bool
+}
+
+func (p identityProvider) UserIdentifier(context.Context) (string, error) {
+	if p.id == "" {
+		return "", errors.New("user not found")
+	}
+
+	return p.id, nil
+}
+
+func (p identityProvider) IsNewIdentifier(context.Context) bool {
+	return p.isNew
+}
+
+func TestGateway(t *testing.T) {
+	var (
+		l  = NewListener()
+		gs = grpc.NewServer()
+		ss = &shortenerServer{}
+	)
+	proto.RegisterShortenerServer(gs, ss)
+	go func() {
+		_ = gs.Serve(l)
+	}()
+	defer gs.Stop()
+
+	gw, err := New(l, identityProvider{id: "userID", isNew: true}, propagation.TraceContext{})
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, gw.Close())
+	}()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := clientinfo.WithInfo(r.Context(), clientinfo.Info{Transport: clientinfo.TransportHTTP, IP: "203.0.113.1"})
+		gw.ServeHTTP(w, r.WithContext(logger.WithRequestID(ctx, "request-id")))
+	}))
+	defer ts.Close()
+
+	req, err := http.NewRequest(
+		http.MethodPost,
+		ts.URL+"/api/v2/links/batch",
+		strings.NewReader(`{"items": [{"correlation_id": "1", "url": "https://example.com"}], "unknown": true}`),
+	)
+	require.NoError(t, err)
+	req.Header.Set("Accept-Language", "ru")
+	req.Header.Set("Grpc-Metadata-Identity", "otherUserID")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.JSONEq(
+		t,
+		`{"urls": [{"url": "https://example.com", "id": "id1", "title": "", "correlation_id": "1"}]}`,
+		readBody(t, resp),
+	)
+	assert.Empty(t, resp.Header.Get("Grpc-Metadata-Content-Type"), "метаданные ответа не передаются")
+	assert.Equal(t, []string{"userID"}, ss.md.Get("identity"), "ID пользователя из HTTP-запроса")
+	assert.Equal(t, []string{"true"}, ss.md.Get("identity-new"), "ID создан при обработке HTTP-запроса")
+	assert.Equal(t, []string{"203.0.113.1"}, ss.md.Get("x-real-ip"))
+	assert.Equal(t, []string{"request-id"}, ss.md.Get("x-request-id"))
+	assert.Empty(t, ss.md.Get("grpcgateway-accept-language"), "заголовки не передаются")
+	assert.Equal(t, clientinfo.InProcessNetwork, ss.peer.Network())
+
+	resp, err = http.Post(ts.URL+"/api/v2/links/abc:resolve", "application/json", strings.NewReader(`{"password": "secret"}`))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.JSONEq(t, `{"url": "https://example.com/abc"}`, readBody(t, resp))
+
+	resp, err = http.Post(ts.URL+"/api/v2/links/abc:resolve?password=secret", "application/json", nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode, "пароль в строке запроса не принимается")
+	require.NoError(t, resp.Body.Close())
+
+	resp, err = http.Post(ts.URL+"/api/v2/links/abc:resolve", "application/json", nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.Equal(t, "5", resp.Header.Get("Retry-After"))
+	assert.Contains(t, readBody(t, resp), "rate limit exceeded")
+
+	resp, err = http.Get(ts.URL + "/api/v2/user/links")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotImplemented, resp.StatusCode, "метод не реализован")
+	require.NoError(t, resp.Body.Close())
+}
+
+func TestListener_Close(t *testing.T) {
+	l := NewListener()
+	assert.NoError(t, l.Close())
+	assert.NoError(t, l.Close(), "повторное закрытие")
+
+	_, err := l.Accept()
+	assert.ErrorIs(t, err, net.ErrClosed)
+	_, err = l.Dial(context.Background(), "")
+	assert.ErrorIs(t, err, net.ErrClosed)
+}
+
+func readBody(t *testing.T, resp *http.Response) string {
+	b, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+
+	return string(b)
+}
@@ -0,0 +1,85 @@
+package gateway
+
+import (
+	"context"
+	"net"
+	"sync"
+
+	"github.com/ivanpodgorny/urlshortener/internal/app/clientinfo"
+)
+
+// addr адрес соединений, установленных внутри процесса.
+var addr = &net.UnixAddr{Name: "gateway", Net: clientinfo.InProcessNetwork}
+
+// Listener реализует net.Listener, принимающий соединения, установленные
+// внутри процесса через Dial. Адрес таких соединений принадлежит сети
+// clientinfo.InProcessNetwork.
+type Listener struct {
+	conns  chan net.Conn
+	closed chan struct{}
+	once   sync.Once
+}
+
+// NewListener возвращает указатель на новый экземпляр Listener.
+func NewListener() *Listener {
+	return &Listener{
+		conns:  make(chan net.Conn),
+		closed: make(chan struct{}),
+	}
+}
+
+// Accept ожидает и возвращает следующее соединение.
+func (l *Listener) Accept() (net.Conn, error) {
+	select {
+	case c := <-l.conns:
+		return c, nil
+	case <-l.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close прекращает прием соединений. Установленные соединения не закрываются.
+func (l *Listener) Close() error {
+	l.once.Do(func() {
+		close(l.closed)
+	})
+
+	return nil
+}
+
+// Addr возвращает адрес Listener.
+func (l *Listener) Addr() net.Addr {
+	return addr
+}
+
+// Dial устанавливает соединение с Listener. Подходит для grpc.WithContextDialer.
+func (l *Listener) Dial(ctx context.Context, _ string) (net.Conn, error) {
+	client, server := net.Pipe()
+	select {
+	case l.conns <- conn{server}:
+		return conn{client}, nil
+	case <-l.closed:
+		_, _ = client.Close(), server.Close()
+
+		return nil, net.ErrClosed
+	case <-ctx.Done():
+		_, _ = client.Close(), server.Close()
+
+		return nil, ctx.Err()
+	}
+}
+
+// conn соединение внутри процесса с адресами сети clientinfo.InProcessNetwork.
+type conn struct {
+	net.Conn
+}
+
+// LocalAddr возвращает локальный адрес соединения.
+func (conn) LocalAddr() net.Addr {
+	return addr
+}
+
+// RemoteAddr возвращает удаленный адрес соединения.
+func (conn) RemoteAddr() net.Addr {
+	return addr
+}
@@ -0,0 +1,98 @@
+// Package geoip реализует определение страны клиента по IP-адресу.
+package geoip
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Table таблица соответствия подсетей кодам стран. Для адреса выбирается
+// подсеть с самым длинным префиксом.
+type Table struct {
+	networks []network
+}
+
+type network struct {
+	subnet  *net.IPNet
+	country string
+}
+
+// NewTable возвращает указатель на новый экземпляр Table, загруженный из файла path.
+// Каждая строка файла содержит подсеть в нотации CIDR и двухбуквенный код страны
+// через запятую, например 192.0.2.0/24,DE. Пустые строки и строки, начинающиеся с #,
+// пропускаются.
+func NewTable(path string) (*Table, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func(f *os.File) {
+		_ = f.Close()
+	}(f)
+
+	return ReadTable(f)
+}
+
+// ReadTable возвращает указатель на новый экземпляр Table, прочитанный из r
+// в формате, описанном в NewTable.
+func ReadTable(r io.Reader) (*Table, error) {
+	t := &Table{networks: make([]network, 0)}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		cidr, country, found := strings.Cut(text, ",")
+		if !found {
+			return nil, fmt.Errorf("line %d: expected <cidr>,<country>", line)
+		}
+
+		_, subnet, err := net.ParseCIDR(strings.TrimSpace(cidr))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		t.networks = append(t.networks, network{
+			subnet:  subnet,
+			country: strings.ToUpper(strings.TrimSpace(country)),
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(t.networks, func(i, j int) bool {
+		oi, _ := t.networks[i].subnet.Mask.Size()
+		oj, _ := t.networks[j].subnet.Mask.Size()
+
+		return oi > oj
+	})
+
+	return t, nil
+}
+
+// Country возвращает код страны для IP-адреса ip. Если адрес не входит
+// ни в одну из подсетей таблицы, возвращает пустую строку.
+func (t *Table) Country(_ context.Context, ip string) (string, error) {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return "", nil
+	}
+
+	for _, n := range t.networks {
+		if n.subnet.Contains(addr) {
+			return n.country, nil
+		}
+	}
+
+	return "", nil
+}
@@ -0,0 +1,64 @@
+package geoip
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTable_Country(t *testing.T) {
+	table, err := ReadTable(strings.NewReader(`
+# подсети для тестов
+192.0.2.0/24,de
+192.0.2.128/25, AT
+2001:db8::/32,FR
+`))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	tests := []struct {
+		ip   string
+		want string
+	}{
+		{ip: "192.0.2.1", want: "DE"},
+		{ip: "192.0.2.200", want: "AT"},
+		{ip: "2001:db8::1", want: "FR"},
+		{ip: "198.51.100.1", want: ""},
+		{ip: "not an ip", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			got, err := table.Country(ctx, tt.ip)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestReadTableErrors(t *testing.T) {
+	_, err := ReadTable(strings.NewReader("192.0.2.0/24"))
+	assert.Error(t, err, "нет кода страны")
+	_, err = ReadTable(strings.NewReader("192.0.2.0/33,DE"))
+	assert.Error(t, err, "некорректная подсеть")
+}
+
+func TestNewTable(t *testing.T) {
+	filename := "test_geoip"
+	require.NoError(t, os.WriteFile(filename, []byte("10.0.0.0/8,US\n"), 0600))
+	defer func() {
+		require.NoError(t, os.Remove(filename))
+	}()
+
+	table, err := NewTable(filename)
+	require.NoError(t, err)
+	country, err := table.Country(context.Background(), "10.1.2.3")
+	assert.NoError(t, err)
+	assert.Equal(t, "US", country)
+
+	_, err = NewTable("not_exists")
+	assert.Error(t, err)
+}
@@ -0,0 +1,231 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	inerr "github.com/ivanpodgorny/urlshortener/internal/app/errors"
+	"github.com/ivanpodgorny/urlshortener/internal/app/model"
+	"github.com/ivanpodgorny/urlshortener/internal/app/validator"
+)
+
+// Admin реализует хендлеры API администратора для модерации сокращенных URL.
+type Admin struct {
+	authorizer AdminIdentityProvider
+	admin      AdminService
+	baseURL    string
+}
+
+// AdminIdentityProvider интерфейс для получения ID администратора, выполнившего запрос.
+type AdminIdentityProvider interface {
+	AdminIdentifier(ctx context.Context) (string, error)
+}
+
+// AdminService интерфейс сервиса модерации сокращенных URL.
+type AdminService interface {
+	GetLink(ctx context.Context, adminID, id string) (model.Link, error)
+	FindLinksByURL(ctx context.Context, adminID, url string) ([]model.Link, error)
+	GetUserLinks(ctx context.Context, adminID, userID string) ([]model.Link, error)
+	SetLinkDisabled(ctx context.Context, adminID, id string, disabled bool) error
+	SetUserBanned(ctx context.Context, adminID, userID string, banned bool) error
+}
+
+type adminLinkData struct {
+	ID           string   `json:"id"`
+	ShortURL     string   `json:"short_url"`
+	OriginalURL  string   `json:"original_url"`
+	CanonicalURL string   `json:"canonical_url"`
+	DisplayURL   string   `json:"display_url"`
+	Warnings     []string `json:"warnings,omitempty"`
+	UserID       string   `json:"user_id"`
+	Deleted      bool     `json:"deleted"`
+	Disabled     bool     `json:"disabled"`
+}
+
+// NewAdmin возвращает указатель на новый экземпляр Admin.
+func NewAdmin(a AdminIdentityProvider, s AdminService, b string) *Admin {
+	return &Admin{
+		authorizer: a,
+		admin:      s,
+		baseURL:    b,
+	}
+}
+
+// GetLink возвращает сокращенный URL по ID независимо от его владельца в формате
+//
+//	{"id": "...", "short_url": "http://...", "original_url": "http://...", "canonical_url": "http://...", "display_url": "http://...", "user_id": "...", "deleted": false, "disabled": false}
+//
+// Если домен URL может быть подменой другого домена похожими символами, в ответ
+// добавляется поле "warnings" со списком предупреждений. То же относится к FindLinks и GetUserLinks.
+func (h Admin) GetLink(w http.ResponseWriter, r *http.Request) {
+	adminID, err := h.authorizer.AdminIdentifier(r.Context())
+	if err != nil {
+		forbidden(w)
+
+		return
+	}
+
+	l, err := h.admin.GetLink(r.Context(), adminID, chi.URLParam(r, "id"))
+	if errors.Is(err, inerr.ErrURLNotFound) {
+		http.NotFound(w, r)
+
+		return
+	}
+
+	if err != nil {
+		serverError(w)
+
+		return
+	}
+
+	responseAsJSON(w, h.prepareLink(l), http.StatusOK)
+}
+
+// FindLinks возвращает все сокращенные URL с адресом назначения, переданным
+// в параметре запроса url, в формате
+//
+//	[{"id": "...", "short_url": "http://...", "original_url": "http://...", "canonical_url": "http://...", "display_url": "http://...", "user_id": "...", "deleted": false, "disabled": false}, ...]
+func (h Admin) FindLinks(w http.ResponseWriter, r *http.Request) {
+	adminID, err := h.authorizer.AdminIdentifier(r.Context())
+	if err != nil {
+		forbidden(w)
+
+		return
+	}
+
+	u := r.URL.Query().Get("url")
+	if u == "" {
+		badRequest(w)
+
+		return
+	}
+
+	links, err := h.admin.FindLinksByURL(r.Context(), adminID, u)
+	if err != nil {
+		serverError(w)
+
+		return
+	}
+
+	responseAsJSON(w, h.prepareLinks(links), http.StatusOK)
+}
+
+// GetUserLinks возвращает все сокращенные URL пользователя, в том числе удаленные
+// и заблокированные, в формате
+//
+//	[{"id": "...", "short_url": "http://...", "original_url": "http://...", "canonical_url": "http://...", "display_url": "http://...", "user_id": "...", "deleted": false, "disabled": false}, ...]
+func (h Admin) GetUserLinks(w http.ResponseWriter, r *http.Request) {
+	adminID, err := h.authorizer.AdminIdentifier(r.Context())
+	if err != nil {
+		forbidden(w)
+
+		return
+	}
+
+	links, err := h.admin.GetUserLinks(r.Context(), adminID, chi.URLParam(r, "userID"))
+	if err != nil {
+		serverError(w)
+
+		return
+	}
+
+	responseAsJSON(w, h.prepareLinks(links), http.StatusOK)
+}
+
+// DisableLink блокирует сокращенный URL независимо от его владельца.
+// В случае успеха возвращает ответ с кодом 204.
+func (h Admin) DisableLink(w http.ResponseWriter, r *http.Request) {
+	h.setLinkDisabled(w, r, true)
+}
+
+// EnableLink разблокирует сокращенный URL независимо от его владельца.
+// В случае успеха возвращает ответ с кодом 204.
+func (h Admin) EnableLink(w http.ResponseWriter, r *http.Request) {
+	h.setLinkDisabled(w, r, false)
+}
+
+// BanUser запрещает пользователю создавать сокращенные URL.
+// В случае успеха возвращает ответ с кодом 204.
+func (h Admin) BanUser(w http.ResponseWriter, r *http.Request) {
+	h.setUserBanned(w, r, true)
+}
+
+// UnbanUser снимает с пользователя запрет на создание сокращенных URL.
+// В случае успеха возвращает ответ с кодом 204.
+func (h Admin) UnbanUser(w http.ResponseWriter, r *http.Request) {
+	h.setUserBanned(w, r, false)
+}
+
+func (h Admin) setLinkDisabled(w http.ResponseWriter, r *http.Request, disabled bool) {
+	adminID, err := h.authorizer.AdminIdentifier(r.Context())
+	if err != nil {
+		forbidden(w)
+
+		return
+	}
+
+	err = h.admin.SetLinkDisabled(r.Context(), adminID, chi.URLParam(r, "id"), disabled)
+	if errors.Is(err, inerr.ErrURLNotFound) {
+		http.NotFound(w, r)
+
+		return
+	}
+
+	if err != nil {
+		serverError(w)
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h Admin) setUserBanned(w http.ResponseWriter, r *http.Request, banned bool) {
+	adminID, err := h.authorizer.AdminIdentifier(r.Context())
+	if err != nil {
+		forbidden(w)
+
+		return
+	}
+
+	userID := chi.URLParam(r, "userID")
+	if valid, _ := validator.Validate[string](userID, validator.IsUUID); !valid {
+		badRequest(w)
+
+		return
+	}
+
+	if err = h.admin.SetUserBanned(r.Context(), adminID, userID, banned); err != nil {
+		serverError(w)
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h Admin) prepareLinks(links []model.Link) []adminLinkData {
+	resp := make([]adminLinkData, 0, len(links))
+	for _, l := range links {
+		resp = append(resp, h.prepareLink(l))
+	}
+
+	return resp
+}
+
+func (h Admin) prepareLink(l model.Link) adminLinkData {
+	return adminLinkData{
+		ID:           l.ID,
+		ShortURL:     h.baseURL + "/" + l.ID,
+		OriginalURL:  l.URL,
+		CanonicalURL: l.CanonicalURL,
+		DisplayURL:   l.DisplayURL,
+		Warnings:     validator.URLWarnings(l.CanonicalURL),
+		UserID:       l.UserID,
+		Deleted:      l.Deleted,
+		Disabled:     l.Disabled,
+	}
+}
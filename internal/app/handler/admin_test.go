@@ -0,0 +1,231 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	inerr "github.com/ivanpodgorny/urlshortener/internal/app/errors"
+	"github.com/ivanpodgorny/urlshortener/internal/app/model"
+	"github.com/ivanpodgorny/urlshortener/internal/app/validator"
+)
+
+type AdminServiceMock struct {
+	mock.Mock
+}
+
+func (m *AdminServiceMock) GetLink(_ context.Context, adminID, id string) (model.Link, error) {
+	args := m.Called(adminID, id)
+
+	return args.Get(0).(model.Link), args.Error(1)
+}
+
+func (m *AdminServiceMock) FindLinksByURL(_ context.Context, adminID, url string) ([]model.Link, error) {
+	args := m.Called(adminID, url)
+
+	return args.Get(0).([]model.Link), args.Error(1)
+}
+
+func (m *AdminServiceMock) GetUserLinks(_ context.Context, adminID, userID string) ([]model.Link, error) {
+	args := m.Called(adminID, userID)
+
+	return args.Get(0).([]model.Link), args.Error(1)
+}
+
+func (m *AdminServiceMock) SetLinkDisabled(_ context.Context, adminID, id string, disabled bool) error {
+	args := m.Called(adminID, id, disabled)
+
+	return args.Error(0)
+}
+
+func (m *AdminServiceMock) SetUserBanned(_ context.Context, adminID, userID string, banned bool) error {
+	args := m.Called(adminID, userID, banned)
+
+	return args.Error(0)
+}
+
+type AdminAuthorizerMock struct {
+	mock.Mock
+}
+
+func (m *AdminAuthorizerMock) AdminIdentifier(_ context.Context) (string, error) {
+	args := m.Called()
+
+	return args.String(0), args.Error(1)
+}
+
+func TestAdmin_GetLink(t *testing.T) {
+	var (
+		adminID    = "adminID"
+		baseURL    = "http://localhost"
+		link       = model.Link{ID: "1i-CBrzwyMkL", URL: "https://аррӏе.com", CanonicalURL: "https://xn--80ak6aa92e.com/", DisplayURL: "https://xn--80ak6aa92e.com/", UserID: "userID", Disabled: true}
+		missingID  = "missing"
+		errID      = "err"
+		service    = &AdminServiceMock{}
+		authorizer = &AdminAuthorizerMock{}
+		handler    = NewAdmin(authorizer, service, baseURL)
+	)
+	authorizer.On("AdminIdentifier").Return(adminID, nil).Times(3)
+	service.
+		On("GetLink", adminID, link.ID).Return(link, nil).Once().
+		On("GetLink", adminID, missingID).Return(model.Link{}, inerr.ErrURLNotFound).Once().
+		On("GetLink", adminID, errID).Return(model.Link{}, errors.New("")).Once()
+
+	result := sendTestRequestWithParams(http.MethodGet, "/api/admin/urls/"+link.ID, nil, map[string]string{"id": link.ID}, handler.GetLink)
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+	b, err := io.ReadAll(result.Body)
+	require.NoError(t, err)
+	require.NoError(t, result.Body.Close())
+	data := adminLinkData{}
+	require.NoError(t, json.Unmarshal(b, &data))
+	assert.Equal(t, adminLinkData{
+		ID:           link.ID,
+		ShortURL:     baseURL + "/" + link.ID,
+		OriginalURL:  link.URL,
+		CanonicalURL: link.CanonicalURL,
+		DisplayURL:   link.DisplayURL,
+		Warnings:     []string{validator.WarningConfusable},
+		UserID:       link.UserID,
+		Disabled:     true,
+	}, data)
+
+	result = sendTestRequestWithParams(http.MethodGet, "/api/admin/urls/"+missingID, nil, map[string]string{"id": missingID}, handler.GetLink)
+	assert.Equal(t, http.StatusNotFound, result.StatusCode)
+	require.NoError(t, result.Body.Close())
+	result = sendTestRequestWithParams(http.MethodGet, "/api/admin/urls/"+errID, nil, map[string]string{"id": errID}, handler.GetLink)
+	assert.Equal(t, http.StatusInternalServerError, result.StatusCode)
+	require.NoError(t, result.Body.Close())
+	authorizer.AssertExpectations(t)
+	service.AssertExpectations(t)
+}
+
+func TestAdmin_FindLinks(t *testing.T) {
+	var (
+		adminID    = "adminID"
+		url        = "https://ya.ru/"
+		links      = []model.Link{{ID: "1i-CBrzwyMkL", URL: url, UserID: "userID"}}
+		service    = &AdminServiceMock{}
+		authorizer = &AdminAuthorizerMock{}
+		handler    = NewAdmin(authorizer, service, "")
+	)
+	authorizer.On("AdminIdentifier").Return(adminID, nil).Twice()
+	service.On("FindLinksByURL", adminID, url).Return(links, nil).Once()
+
+	result := sendTestRequest(http.MethodGet, "/api/admin/urls?url="+url, nil, handler.FindLinks)
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+	b, err := io.ReadAll(result.Body)
+	require.NoError(t, err)
+	require.NoError(t, result.Body.Close())
+	data := make([]adminLinkData, 0)
+	require.NoError(t, json.Unmarshal(b, &data))
+	assert.Len(t, data, 1)
+
+	result = sendTestRequest(http.MethodGet, "/api/admin/urls", nil, handler.FindLinks)
+	assert.Equal(t, http.StatusBadRequest, result.StatusCode, "не передан url")
+	require.NoError(t, result.Body.Close())
+	authorizer.AssertExpectations(t)
+	service.AssertExpectations(t)
+}
+
+func TestAdmin_GetUserLinks(t *testing.T) {
+	var (
+		adminID    = "adminID"
+		userID     = "438c4b98-fc98-45cf-ac63-c4a86fbd4ff4"
+		service    = &AdminServiceMock{}
+		authorizer = &AdminAuthorizerMock{}
+		handler    = NewAdmin(authorizer, service, "")
+	)
+	authorizer.On("AdminIdentifier").Return(adminID, nil).Once()
+	service.On("GetUserLinks", adminID, userID).Return([]model.Link{}, nil).Once()
+
+	result := sendTestRequestWithParams(http.MethodGet, "/", nil, map[string]string{"userID": userID}, handler.GetUserLinks)
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+	b, err := io.ReadAll(result.Body)
+	require.NoError(t, err)
+	require.NoError(t, result.Body.Close())
+	assert.JSONEq(t, "[]", string(b))
+	authorizer.AssertExpectations(t)
+	service.AssertExpectations(t)
+}
+
+func TestAdmin_DisableEnableLink(t *testing.T) {
+	var (
+		adminID    = "adminID"
+		id         = "1i-CBrzwyMkL"
+		missingID  = "missing"
+		service    = &AdminServiceMock{}
+		authorizer = &AdminAuthorizerMock{}
+		handler    = NewAdmin(authorizer, service, "")
+	)
+	authorizer.On("AdminIdentifier").Return(adminID, nil).Times(3)
+	service.
+		On("SetLinkDisabled", adminID, id, true).Return(nil).Once().
+		On("SetLinkDisabled", adminID, id, false).Return(nil).Once().
+		On("SetLinkDisabled", adminID, missingID, true).Return(inerr.ErrURLNotFound).Once()
+
+	result := sendTestRequestWithParams(http.MethodPost, "/", nil, map[string]string{"id": id}, handler.DisableLink)
+	assert.Equal(t, http.StatusNoContent, result.StatusCode)
+	require.NoError(t, result.Body.Close())
+	result = sendTestRequestWithParams(http.MethodPost, "/", nil, map[string]string{"id": id}, handler.EnableLink)
+	assert.Equal(t, http.StatusNoContent, result.StatusCode)
+	require.NoError(t, result.Body.Close())
+	result = sendTestRequestWithParams(http.MethodPost, "/", nil, map[string]string{"id": missingID}, handler.DisableLink)
+	assert.Equal(t, http.StatusNotFound, result.StatusCode)
+	require.NoError(t, result.Body.Close())
+	authorizer.AssertExpectations(t)
+	service.AssertExpectations(t)
+}
+
+func TestAdmin_BanUnbanUser(t *testing.T) {
+	var (
+		adminID    = "adminID"
+		userID     = "438c4b98-fc98-45cf-ac63-c4a86fbd4ff4"
+		service    = &AdminServiceMock{}
+		authorizer = &AdminAuthorizerMock{}
+		handler    = NewAdmin(authorizer, service, "")
+	)
+	authorizer.On("AdminIdentifier").Return(adminID, nil).Times(3)
+	service.
+		On("SetUserBanned", adminID, userID, true).Return(nil).Once().
+		On("SetUserBanned", adminID, userID, false).Return(nil).Once()
+
+	result := sendTestRequestWithParams(http.MethodPost, "/", nil, map[string]string{"userID": userID}, handler.BanUser)
+	assert.Equal(t, http.StatusNoContent, result.StatusCode)
+	require.NoError(t, result.Body.Close())
+	result = sendTestRequestWithParams(http.MethodDelete, "/", nil, map[string]string{"userID": userID}, handler.UnbanUser)
+	assert.Equal(t, http.StatusNoContent, result.StatusCode)
+	require.NoError(t, result.Body.Close())
+	result = sendTestRequestWithParams(http.MethodPost, "/", nil, map[string]string{"userID": "userID"}, handler.BanUser)
+	assert.Equal(t, http.StatusBadRequest, result.StatusCode, "некорректный ID пользователя")
+	require.NoError(t, result.Body.Close())
+	authorizer.AssertExpectations(t)
+	service.AssertExpectations(t)
+}
+
+func TestAdmin_NotAdmin(t *testing.T) {
+	var (
+		authorizer = &AdminAuthorizerMock{}
+		handler    = NewAdmin(authorizer, &AdminServiceMock{}, "")
+	)
+	authorizer.On("AdminIdentifier").Return("", errors.New("")).Times(5)
+
+	for _, h := range []http.HandlerFunc{
+		handler.GetLink,
+		handler.FindLinks,
+		handler.GetUserLinks,
+		handler.DisableLink,
+		handler.BanUser,
+	} {
+		result := sendTestRequest(http.MethodGet, "/", nil, h)
+		assert.Equal(t, http.StatusForbidden, result.StatusCode)
+		require.NoError(t, result.Body.Close())
+	}
+	authorizer.AssertExpectations(t)
+}
@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/ivanpodgorny/urlshortener/internal/app/model"
+)
+
+// Audit реализует хендлеры для получения журнала аудита жизненного цикла сокращенных URL.
+type Audit struct {
+	authenticator IdentityProvider
+	auditor       AuditService
+}
+
+// AuditService интерфейс сервиса журнала аудита.
+type AuditService interface {
+	GetUserEvents(ctx context.Context, userID string, limit int) ([]model.AuditEvent, error)
+	GetEvents(ctx context.Context, f model.AuditFilter) ([]model.AuditEvent, error)
+}
+
+const defaultAuditLimit = 100
+
+// NewAudit возвращает указатель на новый экземпляр Audit.
+func NewAudit(a IdentityProvider, s AuditService) *Audit {
+	return &Audit{
+		authenticator: a,
+		auditor:       s,
+	}
+}
+
+// GetByCurrentUser возвращает записи журнала аудита по URL пользователя,
+// выполнившего запрос, начиная с самых новых, в формате
+//
+//	[{"link_id": "...", "action": "create", "user_id": "...", "owner_id": "...", "client_ip": "...", "transport": "http", "created_at": "..."}, ...]
+//
+// Количество записей ограничивается параметром запроса limit, по умолчанию 100.
+func (h Audit) GetByCurrentUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := h.authenticator.UserIdentifier(r.Context())
+	if err != nil {
+		unauthorized(w)
+
+		return
+	}
+
+	limit, ok := h.limit(r)
+	if !ok {
+		badRequest(w)
+
+		return
+	}
+
+	events, err := h.auditor.GetUserEvents(r.Context(), userID, limit)
+	if err != nil {
+		serverError(w)
+
+		return
+	}
+
+	responseAsJSON(w, events, http.StatusOK)
+}
+
+// GetAll возвращает записи журнала аудита по всем URL в формате, аналогичном
+// GetByCurrentUser. Записи можно отфильтровать по параметрам запроса
+// owner_id и link_id, количество записей ограничивается параметром limit.
+func (h Audit) GetAll(w http.ResponseWriter, r *http.Request) {
+	limit, ok := h.limit(r)
+	if !ok {
+		badRequest(w)
+
+		return
+	}
+
+	events, err := h.auditor.GetEvents(r.Context(), model.AuditFilter{
+		OwnerID: r.URL.Query().Get("owner_id"),
+		LinkID:  r.URL.Query().Get("link_id"),
+		Limit:   limit,
+	})
+	if err != nil {
+		serverError(w)
+
+		return
+	}
+
+	responseAsJSON(w, events, http.StatusOK)
+}
+
+func (h Audit) limit(r *http.Request) (int, bool) {
+	val := r.URL.Query().Get("limit")
+	if val == "" {
+		return defaultAuditLimit, true
+	}
+
+	limit, err := strconv.Atoi(val)
+	if err != nil || limit <= 0 {
+		return 0, false
+	}
+
+	return limit, true
+}
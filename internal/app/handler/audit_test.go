@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ivanpodgorny/urlshortener/internal/app/model"
+)
+
+type AuditServiceMock struct {
+	mock.Mock
+}
+
+func (m *AuditServiceMock) GetUserEvents(_ context.Context, userID string, limit int) ([]model.AuditEvent, error) {
+	args := m.Called(userID, limit)
+
+	return args.Get(0).([]model.AuditEvent), args.Error(1)
+}
+
+func (m *AuditServiceMock) GetEvents(_ context.Context, f model.AuditFilter) ([]model.AuditEvent, error) {
+	args := m.Called(f)
+
+	return args.Get(0).([]model.AuditEvent), args.Error(1)
+}
+
+func TestAudit_GetByCurrentUser(t *testing.T) {
+	var (
+		userID        = "438c4b98-fc98-45cf-ac63-c4a86fbd4ff4"
+		events        = []model.AuditEvent{{LinkID: "1i-CBrzwyMkL", Action: model.AuditActionCreate, UserID: userID, OwnerID: userID}}
+		authenticator = &AuthenticatorMock{}
+		auditor       = &AuditServiceMock{}
+		handler       = NewAudit(authenticator, auditor)
+	)
+	authenticator.
+		On("UserIdentifier").Return(userID, nil).Times(3).
+		On("UserIdentifier").Return("", errors.New("")).Once()
+	auditor.
+		On("GetUserEvents", userID, defaultAuditLimit).Return(events, nil).Once().
+		On("GetUserEvents", userID, 10).Return([]model.AuditEvent{}, errors.New("")).Once()
+
+	result := sendTestRequest(http.MethodGet, "/", nil, handler.GetByCurrentUser)
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+	b, err := io.ReadAll(result.Body)
+	require.NoError(t, err)
+	var resp []model.AuditEvent
+	require.NoError(t, json.Unmarshal(b, &resp))
+	assert.Equal(t, events, resp)
+	require.NoError(t, result.Body.Close())
+
+	result = sendTestRequest(http.MethodGet, "/?limit=10", nil, handler.GetByCurrentUser)
+	assert.Equal(t, http.StatusInternalServerError, result.StatusCode, "ошибка получения журнала")
+	require.NoError(t, result.Body.Close())
+	result = sendTestRequest(http.MethodGet, "/?limit=-1", nil, handler.GetByCurrentUser)
+	assert.Equal(t, http.StatusBadRequest, result.StatusCode, "некорректный limit")
+	require.NoError(t, result.Body.Close())
+	result = sendTestRequest(http.MethodGet, "/", nil, handler.GetByCurrentUser)
+	assert.Equal(t, http.StatusUnauthorized, result.StatusCode, "пользователь не аутентифицирован")
+	require.NoError(t, result.Body.Close())
+
+	authenticator.AssertExpectations(t)
+	auditor.AssertExpectations(t)
+}
+
+func TestAudit_GetAll(t *testing.T) {
+	var (
+		filter  = model.AuditFilter{OwnerID: "ownerID", LinkID: "linkID", Limit: 5}
+		events  = []model.AuditEvent{{LinkID: "linkID", Action: model.AuditActionDelete, OwnerID: "ownerID"}}
+		auditor = &AuditServiceMock{}
+		handler = NewAudit(&NullAuthenticator{}, auditor)
+	)
+	auditor.On("GetEvents", filter).Return(events, nil).Once()
+
+	result := sendTestRequest(http.MethodGet, "/?owner_id=ownerID&link_id=linkID&limit=5", nil, handler.GetAll)
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+	b, err := io.ReadAll(result.Body)
+	require.NoError(t, err)
+	var resp []model.AuditEvent
+	require.NoError(t, json.Unmarshal(b, &resp))
+	assert.Equal(t, events, resp)
+	require.NoError(t, result.Body.Close())
+
+	result = sendTestRequest(http.MethodGet, "/?limit=a", nil, handler.GetAll)
+	assert.Equal(t, http.StatusBadRequest, result.StatusCode, "некорректный limit")
+	require.NoError(t, result.Body.Close())
+
+	auditor.AssertExpectations(t)
+}
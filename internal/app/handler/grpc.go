@@ -2,10 +2,17 @@ package handler
 
 import (
 	"context"
+	"errors"
+	"math"
+	"strconv"
 
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 
+	inerr "github.com/ivanpodgorny/urlshortener/internal/app/errors"
+	"github.com/ivanpodgorny/urlshortener/internal/app/model"
 	"github.com/ivanpodgorny/urlshortener/internal/proto"
 )
 
@@ -14,24 +21,45 @@ type ShortenerServer struct {
 	proto.UnimplementedShortenerServer
 	authenticator IdentityProvider
 	shortener     Shortener
+	qrCodes       QRCodeGenerator
 }
 
 // NewShortenerGRPCServer возвращает указатель на новый экземпляр ShortenerServer.
-func NewShortenerGRPCServer(a IdentityProvider, s Shortener) *ShortenerServer {
+func NewShortenerGRPCServer(a IdentityProvider, s Shortener, g QRCodeGenerator) *ShortenerServer {
 	return &ShortenerServer{
 		authenticator: a,
 		shortener:     s,
+		qrCodes:       g,
 	}
 }
 
 // CreateLink обрабатывает запрос на создание сокращенного URL.
+// Если задан пароль, он потребуется для получения URL. Если задан срок действия,
+// после него URL перестанет открываться; срок действия, истекший к моменту
+// создания, приводит к ошибке с кодом InvalidArgument.
 func (s *ShortenerServer) CreateLink(ctx context.Context, request *proto.CreateLinkRequest) (*proto.CreateLinkResponse, error) {
 	userID, err := s.authenticator.UserIdentifier(ctx)
 	if err != nil {
 		return nil, status.Error(codes.PermissionDenied, "cannot get user ID")
 	}
 
-	id, inserted, err := s.shortener.Shorten(ctx, request.GetUrl(), userID)
+	opts := model.LinkOptions{Password: request.GetPassword()}
+	if request.ExpiresAt != nil {
+		if err = request.GetExpiresAt().CheckValid(); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid expires_at: %v", err)
+		}
+		opts.ExpiresAt = request.GetExpiresAt().AsTime()
+	}
+
+	id, inserted, err := s.shortener.Shorten(ctx, request.GetUrl(), userID, opts)
+	if errors.Is(err, inerr.ErrUserIsBanned) {
+		return nil, status.Error(codes.PermissionDenied, "user is banned")
+	}
+
+	if errors.Is(err, inerr.ErrDestinationRejected) || errors.Is(err, inerr.ErrPasswordTooLong) || errors.Is(err, inerr.ErrInvalidExpiry) {
+		return nil, status.Errorf(codes.InvalidArgument, "%v", err)
+	}
+
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "%v", err)
 	}
@@ -46,22 +74,39 @@ func (s *ShortenerServer) CreateLink(ctx context.Context, request *proto.CreateL
 }
 
 // CreateLinkBatch обрабатывает запрос на создание нескольких сокращенных URL.
+// URL из поля items возвращаются с переданными идентификаторами correlation_id,
+// URL из устаревшего поля urls — без них. Запрос с более чем 1000 URL отклоняется
+// с кодом InvalidArgument. URL, которые не удалось сократить, пропускаются.
 func (s *ShortenerServer) CreateLinkBatch(ctx context.Context, request *proto.CreateLinkBatchRequest) (*proto.CreateLinkBatchResponse, error) {
 	userID, err := s.authenticator.UserIdentifier(ctx)
 	if err != nil {
 		return nil, status.Error(codes.PermissionDenied, "cannot get user ID")
 	}
 
-	resp := proto.CreateLinkBatchResponse{Urls: make([]*proto.URLData, 0, len(request.GetUrls()))}
+	items := make([]*proto.BatchURL, 0, len(request.GetUrls())+len(request.GetItems()))
 	for _, u := range request.GetUrls() {
-		id, _, err := s.shortener.Shorten(ctx, u, userID)
+		items = append(items, &proto.BatchURL{Url: u})
+	}
+	items = append(items, request.GetItems()...)
+	if len(items) > maxCreateBatchSize {
+		return nil, status.Errorf(codes.InvalidArgument, "batch has too many urls, %d is max", maxCreateBatchSize)
+	}
+
+	resp := proto.CreateLinkBatchResponse{Urls: make([]*proto.URLData, 0, len(items))}
+	for _, item := range items {
+		id, _, err := s.shortener.Shorten(ctx, item.GetUrl(), userID, model.LinkOptions{})
+		if errors.Is(err, inerr.ErrUserIsBanned) {
+			return nil, status.Error(codes.PermissionDenied, "user is banned")
+		}
+
 		if err != nil {
 			continue
 		}
 
 		resp.Urls = append(resp.Urls, &proto.URLData{
-			Url: u,
-			Id:  id,
+			Url:           item.GetUrl(),
+			Id:            id,
+			CorrelationId: item.GetCorrelationId(),
 		})
 	}
 
@@ -69,28 +114,64 @@ func (s *ShortenerServer) CreateLinkBatch(ctx context.Context, request *proto.Cr
 }
 
 // GetURL обрабатывает запрос на получение оригинального URL по ID.
+// Для URL, защищенного паролем, в запросе должен быть передан пароль.
+// Для URL, удаленного пользователем, заблокированного администратором
+// или с истекшим сроком действия, возвращается ошибка с кодом FailedPrecondition.
 func (s *ShortenerServer) GetURL(ctx context.Context, request *proto.GetURLRequest) (*proto.GetURLResponse, error) {
-	u, err := s.shortener.Get(ctx, request.GetId())
+	res, err := s.shortener.Get(ctx, request.GetId(), model.RedirectRequest{Password: request.GetPassword()})
+	if errors.Is(err, inerr.ErrURLIsDeleted) {
+		return nil, status.Error(codes.FailedPrecondition, "url is deleted")
+	}
+
+	if errors.Is(err, inerr.ErrURLIsDisabled) {
+		return nil, status.Error(codes.FailedPrecondition, "url is disabled")
+	}
+
+	if errors.Is(err, inerr.ErrURLIsExpired) {
+		return nil, status.Error(codes.FailedPrecondition, "url is expired")
+	}
+
+	if errors.Is(err, inerr.ErrPasswordRequired) {
+		return nil, status.Error(codes.Unauthenticated, "password required")
+	}
+
+	if errors.Is(err, inerr.ErrInvalidPassword) {
+		return nil, status.Error(codes.PermissionDenied, "invalid password")
+	}
+
+	var attempts *inerr.PasswordAttemptsError
+	if errors.As(err, &attempts) {
+		seconds := strconv.Itoa(int(math.Ceil(attempts.RetryAfter.Seconds())))
+		_ = grpc.SetTrailer(ctx, metadata.Pairs("retry-after", seconds))
+
+		return nil, status.Errorf(codes.ResourceExhausted, "too many password attempts, retry after %s seconds", seconds)
+	}
+
 	if err != nil {
 		return nil, status.Errorf(codes.NotFound, "%v", err)
 	}
 
-	return &proto.GetURLResponse{Url: u}, nil
+	return &proto.GetURLResponse{Url: res.URL}, nil
 }
 
-// GetAllURL возвращает все сокращенные URL пользователя, выполнившего запрос.
+// GetAllURL возвращает все сокращенные URL пользователя, выполнившего запрос, в порядке создания.
 func (s *ShortenerServer) GetAllURL(ctx context.Context, _ *proto.GetAllURLRequest) (*proto.GetAllURLResponse, error) {
 	userID, err := s.authenticator.UserIdentifier(ctx)
 	if err != nil {
 		return nil, status.Error(codes.PermissionDenied, "cannot get user ID")
 	}
 
-	urls := s.shortener.GetAllUser(ctx, userID)
-	resp := proto.GetAllURLResponse{Urls: make([]*proto.URLData, 0, len(urls))}
-	for id, u := range urls {
+	links, err := s.shortener.GetUserLinks(ctx, userID)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "%v", err)
+	}
+
+	resp := proto.GetAllURLResponse{Urls: make([]*proto.URLData, 0, len(links))}
+	for _, l := range links {
 		resp.Urls = append(resp.Urls, &proto.URLData{
-			Url: u,
-			Id:  id,
+			Url:   l.DisplayURL,
+			Id:    l.ID,
+			Title: l.Title,
 		})
 	}
 
@@ -110,3 +191,114 @@ func (s *ShortenerServer) DeleteURLBatch(ctx context.Context, request *proto.Del
 
 	return &proto.DeleteURLBatchResponse{}, nil
 }
+
+// GetVariantStats возвращает варианты адреса назначения URL пользователя, выполнившего запрос,
+// с количеством переходов на каждый из них.
+func (s *ShortenerServer) GetVariantStats(ctx context.Context, request *proto.GetVariantStatsRequest) (*proto.GetVariantStatsResponse, error) {
+	userID, err := s.authenticator.UserIdentifier(ctx)
+	if err != nil {
+		return nil, status.Error(codes.PermissionDenied, "cannot get user ID")
+	}
+
+	stats, err := s.shortener.GetVariantStats(ctx, request.GetId(), userID)
+	if errors.Is(err, inerr.ErrURLNotFound) {
+		return nil, status.Error(codes.NotFound, "url not found")
+	}
+
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "%v", err)
+	}
+
+	resp := proto.GetVariantStatsResponse{
+		Sticky:   stats.Sticky,
+		Variants: make([]*proto.VariantStat, 0, len(stats.Items)),
+	}
+	for _, v := range stats.Items {
+		resp.Variants = append(resp.Variants, &proto.VariantStat{
+			Name:   v.Name,
+			Url:    v.URL,
+			Weight: int32(v.Weight),
+			Clicks: v.Clicks,
+		})
+	}
+
+	return &resp, nil
+}
+
+// GetStats возвращает статистику использования сервиса. Нулевые параметры
+// запроса заменяются значениями по умолчанию, параметры вне допустимых пределов
+// приводят к ошибке с кодом InvalidArgument. Доступ к методу ограничивается
+// доверенными подсетями на уровне interceptor.
+func (s *ShortenerServer) GetStats(ctx context.Context, request *proto.GetStatsRequest) (*proto.GetStatsResponse, error) {
+	days, top, ok := statsParams(int(request.GetDays()), int(request.GetTop()))
+	if !ok {
+		return nil, status.Error(codes.InvalidArgument, "invalid stats parameters")
+	}
+
+	stats, err := s.shortener.GetStats(ctx, days, top)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "%v", err)
+	}
+
+	resp := proto.GetStatsResponse{
+		Urls:          int32(stats.URLs),
+		Users:         int32(stats.Users),
+		Active:        int32(stats.Active),
+		Deleted:       int32(stats.Deleted),
+		Expired:       int32(stats.Expired),
+		Disabled:      int32(stats.Disabled),
+		CreatedPerDay: make([]*proto.DayCount, 0, len(stats.CreatedPerDay)),
+		TopLinks:      make([]*proto.LinkClicks, 0, len(stats.TopLinks)),
+		TopDomains:    make([]*proto.DomainCount, 0, len(stats.TopDomains)),
+		StorageSize:   stats.StorageSize,
+	}
+	for _, d := range stats.CreatedPerDay {
+		resp.CreatedPerDay = append(resp.CreatedPerDay, &proto.DayCount{Date: d.Date, Count: int32(d.Count)})
+	}
+	for _, l := range stats.TopLinks {
+		resp.TopLinks = append(resp.TopLinks, &proto.LinkClicks{Id: l.ID, Url: l.URL, Clicks: l.Clicks})
+	}
+	for _, d := range stats.TopDomains {
+		resp.TopDomains = append(resp.TopDomains, &proto.DomainCount{Domain: d.Domain, Count: int32(d.Count)})
+	}
+
+	return &resp, nil
+}
+
+// GetQRCode возвращает изображение QR-кода URL пользователя, выполнившего запрос, и его MIME-тип.
+// Незаданные параметры изображения получают значения по умолчанию.
+func (s *ShortenerServer) GetQRCode(ctx context.Context, request *proto.GetQRCodeRequest) (*proto.GetQRCodeResponse, error) {
+	userID, err := s.authenticator.UserIdentifier(ctx)
+	if err != nil {
+		return nil, status.Error(codes.PermissionDenied, "cannot get user ID")
+	}
+
+	opts := model.QROptions{
+		Format: request.GetFormat(),
+		Size:   int(request.GetSize()),
+		Level:  request.GetEcc(),
+	}
+	if request.Margin != nil {
+		margin := int(request.GetMargin())
+		opts.Margin = &margin
+	}
+
+	data, contentType, err := s.qrCodes.Generate(ctx, request.GetId(), userID, opts)
+	if errors.Is(err, inerr.ErrURLNotFound) {
+		return nil, status.Error(codes.NotFound, "url not found")
+	}
+
+	if errors.Is(err, inerr.ErrURLIsDeleted) {
+		return nil, status.Error(codes.FailedPrecondition, "url is deleted")
+	}
+
+	if errors.Is(err, inerr.ErrInvalidQROptions) {
+		return nil, status.Errorf(codes.InvalidArgument, "%v", err)
+	}
+
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "%v", err)
+	}
+
+	return &proto.GetQRCodeResponse{Data: data, ContentType: contentType}, nil
+}
@@ -3,12 +3,18 @@ package handler
 import (
 	"context"
 	"errors"
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
 
+	inerr "github.com/ivanpodgorny/urlshortener/internal/app/errors"
+	"github.com/ivanpodgorny/urlshortener/internal/app/model"
 	"github.com/ivanpodgorny/urlshortener/internal/proto"
 )
 
@@ -18,16 +24,20 @@ func TestShortenerServer_CreateLink(t *testing.T) {
 		url           = "url"
 		dupURL        = "dupURL"
 		errURL        = "errURL"
+		rejectedURL   = "rejectedURL"
+		expiredURL    = "expiredURL"
 		id            = "id"
 		dupID         = "dupID"
 		ctx           = context.Background()
 		authenticator = &AuthenticatorMock{}
 		shortener     = &ShortenerMock{}
 	)
-	authenticator.On("UserIdentifier").Return(userID, nil).Times(3)
-	shortener.On("Shorten", url, userID).Return(id, true, nil).Once()
-	shortener.On("Shorten", dupURL, userID).Return(dupID, false, nil).Once()
-	shortener.On("Shorten", errURL, userID).Return("", false, errors.New("")).Once()
+	authenticator.On("UserIdentifier").Return(userID, nil).Times(6)
+	shortener.On("Shorten", url, userID, "").Return(id, true, nil).Once()
+	shortener.On("Shorten", dupURL, userID, "").Return(dupID, false, nil).Once()
+	shortener.On("Shorten", errURL, userID, "").Return("", false, errors.New("")).Once()
+	shortener.On("Shorten", rejectedURL, userID, "").Return("", false, inerr.ErrDestinationRejected).Once()
+	shortener.On("Shorten", expiredURL, userID, "").Return("", false, inerr.ErrInvalidExpiry).Once()
 	server := ShortenerServer{
 		authenticator: authenticator,
 		shortener:     shortener,
@@ -41,6 +51,12 @@ func TestShortenerServer_CreateLink(t *testing.T) {
 	assert.Equal(t, dupID, resp.GetId())
 	_, err = server.CreateLink(ctx, &proto.CreateLinkRequest{Url: errURL})
 	testGRPCErrorCode(t, err, codes.Internal)
+	_, err = server.CreateLink(ctx, &proto.CreateLinkRequest{Url: rejectedURL})
+	testGRPCErrorCode(t, err, codes.InvalidArgument)
+	_, err = server.CreateLink(ctx, &proto.CreateLinkRequest{Url: expiredURL, ExpiresAt: timestamppb.New(time.Now().Add(-time.Minute))})
+	testGRPCErrorCode(t, err, codes.InvalidArgument)
+	_, err = server.CreateLink(ctx, &proto.CreateLinkRequest{Url: url, ExpiresAt: &timestamppb.Timestamp{Nanos: -1}})
+	testGRPCErrorCode(t, err, codes.InvalidArgument)
 	authenticator.AssertExpectations(t)
 	shortener.AssertExpectations(t)
 }
@@ -57,30 +73,53 @@ func TestShortenerServer_CreateLinkBatch(t *testing.T) {
 		shortener     = &ShortenerMock{}
 	)
 	authenticator.On("UserIdentifier").Return(userID, nil).Once()
-	shortener.On("Shorten", url, userID).Return(id, true, nil).Once()
-	shortener.On("Shorten", dupURL, userID).Return(dupID, false, nil).Once()
-	shortener.On("Shorten", errURL, userID).Return("", false, errors.New("")).Once()
+	shortener.On("Shorten", url, userID, "").Return(id, true, nil).Once()
+	shortener.On("Shorten", dupURL, userID, "").Return(dupID, false, nil).Once()
+	shortener.On("Shorten", errURL, userID, "").Return("", false, errors.New("")).Once()
 	server := ShortenerServer{
 		authenticator: authenticator,
 		shortener:     shortener,
 	}
 
-	resp, err := server.CreateLinkBatch(context.Background(), &proto.CreateLinkBatchRequest{Urls: []string{url, dupURL, errURL}})
+	resp, err := server.CreateLinkBatch(context.Background(), &proto.CreateLinkBatchRequest{
+		Urls: []string{url},
+		Items: []*proto.BatchURL{
+			{CorrelationId: "2", Url: dupURL},
+			{CorrelationId: "3", Url: errURL},
+		},
+	})
 	assert.NoError(t, err)
-	assert.ElementsMatch(t, []*proto.URLData{
+	assert.Equal(t, []*proto.URLData{
 		{
 			Url: url,
 			Id:  id,
 		},
 		{
-			Url: dupURL,
-			Id:  dupID,
+			Url:           dupURL,
+			Id:            dupID,
+			CorrelationId: "2",
 		},
 	}, resp.GetUrls())
 	authenticator.AssertExpectations(t)
 	shortener.AssertExpectations(t)
 }
 
+func TestShortenerServer_CreateLinkBatchTooLarge(t *testing.T) {
+	authenticator := &AuthenticatorMock{}
+	authenticator.On("UserIdentifier").Return("userID", nil).Once()
+	server := ShortenerServer{
+		authenticator: authenticator,
+		shortener:     &ShortenerMock{},
+	}
+
+	_, err := server.CreateLinkBatch(context.Background(), &proto.CreateLinkBatchRequest{
+		Urls:  make([]string, maxCreateBatchSize),
+		Items: []*proto.BatchURL{{CorrelationId: "1", Url: "url"}},
+	})
+	testGRPCErrorCode(t, err, codes.InvalidArgument)
+	authenticator.AssertExpectations(t)
+}
+
 func TestShortenerServer_DeleteURLBatch(t *testing.T) {
 	var (
 		userID        = "userID"
@@ -117,7 +156,10 @@ func TestShortenerServer_GetAllURL(t *testing.T) {
 		shortener     = &ShortenerMock{}
 	)
 	authenticator.On("UserIdentifier").Return(userID, nil).Once()
-	shortener.On("GetAllUser", userID).Return(map[string]string{id: url, secID: secURL}).Once()
+	shortener.On("GetUserLinks", userID).Return([]model.Link{
+		{ID: id, URL: url, DisplayURL: url, Title: "title"},
+		{ID: secID, URL: secURL, DisplayURL: secURL},
+	}, nil).Once()
 	server := ShortenerServer{
 		authenticator: authenticator,
 		shortener:     shortener,
@@ -125,10 +167,11 @@ func TestShortenerServer_GetAllURL(t *testing.T) {
 
 	resp, err := server.GetAllURL(context.Background(), &proto.GetAllURLRequest{})
 	assert.NoError(t, err)
-	assert.ElementsMatch(t, []*proto.URLData{
+	assert.Equal(t, []*proto.URLData{
 		{
-			Url: url,
-			Id:  id,
+			Url:   url,
+			Id:    id,
+			Title: "title",
 		},
 		{
 			Url: secURL,
@@ -140,24 +183,155 @@ func TestShortenerServer_GetAllURL(t *testing.T) {
 }
 
 func TestShortenerServer_GetURL(t *testing.T) {
+	var (
+		url        = "url"
+		id         = "id"
+		errID      = "errID"
+		deletedID  = "deletedID"
+		disabledID = "disabledID"
+		expiredID  = "expiredID"
+		ctx        = context.Background()
+		shortener  = &ShortenerMock{}
+	)
+	shortener.On("Get", id, model.RedirectRequest{}).Return(model.Redirect{URL: url}, nil).Once()
+	shortener.On("Get", errID, model.RedirectRequest{}).Return(model.Redirect{}, errors.New("")).Once()
+	shortener.On("Get", deletedID, model.RedirectRequest{}).Return(model.Redirect{}, inerr.ErrURLIsDeleted).Once()
+	shortener.On("Get", disabledID, model.RedirectRequest{}).Return(model.Redirect{}, inerr.ErrURLIsDisabled).Once()
+	shortener.On("Get", expiredID, model.RedirectRequest{}).Return(model.Redirect{}, inerr.ErrURLIsExpired).Once()
+	server := ShortenerServer{
+		shortener: shortener,
+	}
+
+	resp, err := server.GetURL(ctx, &proto.GetURLRequest{Id: id})
+	assert.NoError(t, err)
+	assert.Equal(t, url, resp.GetUrl())
+	_, err = server.GetURL(ctx, &proto.GetURLRequest{Id: errID})
+	testGRPCErrorCode(t, err, codes.NotFound)
+	_, err = server.GetURL(ctx, &proto.GetURLRequest{Id: deletedID})
+	testGRPCErrorCode(t, err, codes.FailedPrecondition)
+	_, err = server.GetURL(ctx, &proto.GetURLRequest{Id: disabledID})
+	testGRPCErrorCode(t, err, codes.FailedPrecondition)
+	_, err = server.GetURL(ctx, &proto.GetURLRequest{Id: expiredID})
+	testGRPCErrorCode(t, err, codes.FailedPrecondition)
+	shortener.AssertExpectations(t)
+}
+
+func TestShortenerServer_GetURLPasswordProtected(t *testing.T) {
 	var (
 		url       = "url"
 		id        = "id"
-		errID     = "errID"
+		password  = "secret"
 		ctx       = context.Background()
 		shortener = &ShortenerMock{}
 	)
-	shortener.On("Get", id).Return(url, nil).Once()
-	shortener.On("Get", errID).Return("", errors.New("")).Once()
+	shortener.
+		On("Get", id, model.RedirectRequest{}).Return(model.Redirect{}, inerr.ErrPasswordRequired).Once().
+		On("Get", id, model.RedirectRequest{Password: "wrong"}).Return(model.Redirect{}, inerr.ErrInvalidPassword).Once().
+		On("Get", id, model.RedirectRequest{Password: "other"}).Return(model.Redirect{}, &inerr.PasswordAttemptsError{RetryAfter: time.Second}).Once().
+		On("Get", id, model.RedirectRequest{Password: password}).Return(model.Redirect{URL: url}, nil).Once()
 	server := ShortenerServer{
 		shortener: shortener,
 	}
 
-	resp, err := server.GetURL(ctx, &proto.GetURLRequest{Id: id})
+	_, err := server.GetURL(ctx, &proto.GetURLRequest{Id: id})
+	testGRPCErrorCode(t, err, codes.Unauthenticated)
+	_, err = server.GetURL(ctx, &proto.GetURLRequest{Id: id, Password: "wrong"})
+	testGRPCErrorCode(t, err, codes.PermissionDenied)
+	_, err = server.GetURL(ctx, &proto.GetURLRequest{Id: id, Password: "other"})
+	testGRPCErrorCode(t, err, codes.ResourceExhausted)
+	resp, err := server.GetURL(ctx, &proto.GetURLRequest{Id: id, Password: password})
 	assert.NoError(t, err)
 	assert.Equal(t, url, resp.GetUrl())
-	_, err = server.GetURL(ctx, &proto.GetURLRequest{Id: errID})
+	shortener.AssertExpectations(t)
+}
+
+func TestShortenerServer_GetVariantStats(t *testing.T) {
+	var (
+		userID        = "userID"
+		id            = "id"
+		ctx           = context.Background()
+		authenticator = &AuthenticatorMock{}
+		shortener     = &ShortenerMock{}
+		stats         = model.VariantStats{
+			Sticky: true,
+			Items: []model.VariantStat{
+				{Variant: model.Variant{Name: "a", URL: "https://example.com/a", Weight: 70}, Clicks: 42},
+			},
+		}
+	)
+	authenticator.On("UserIdentifier").Return(userID, nil).Times(3)
+	shortener.
+		On("GetVariantStats", id, userID).Return(stats, nil).Once().
+		On("GetVariantStats", id, userID).Return(model.VariantStats{}, inerr.ErrURLNotFound).Once().
+		On("GetVariantStats", id, userID).Return(model.VariantStats{}, errors.New("")).Once()
+	server := ShortenerServer{
+		authenticator: authenticator,
+		shortener:     shortener,
+	}
+
+	resp, err := server.GetVariantStats(ctx, &proto.GetVariantStatsRequest{Id: id})
+	assert.NoError(t, err)
+	assert.True(t, resp.GetSticky())
+	require.Len(t, resp.GetVariants(), 1)
+	assert.Equal(t, "a", resp.GetVariants()[0].GetName())
+	assert.Equal(t, "https://example.com/a", resp.GetVariants()[0].GetUrl())
+	assert.Equal(t, int32(70), resp.GetVariants()[0].GetWeight())
+	assert.Equal(t, int64(42), resp.GetVariants()[0].GetClicks())
+	_, err = server.GetVariantStats(ctx, &proto.GetVariantStatsRequest{Id: id})
 	testGRPCErrorCode(t, err, codes.NotFound)
+	_, err = server.GetVariantStats(ctx, &proto.GetVariantStatsRequest{Id: id})
+	testGRPCErrorCode(t, err, codes.Internal)
+	authenticator.AssertExpectations(t)
+	shortener.AssertExpectations(t)
+}
+
+func TestShortenerServer_GetStats(t *testing.T) {
+	var (
+		ctx       = context.Background()
+		shortener = &ShortenerMock{}
+		stats     = model.Stats{
+			URLs:          3,
+			Users:         2,
+			Active:        2,
+			Deleted:       1,
+			Expired:       1,
+			Disabled:      1,
+			CreatedPerDay: []model.DayCount{{Date: "2023-05-10", Count: 3}},
+			TopLinks:      []model.LinkClicks{{ID: "id", URL: "https://ya.ru/", Clicks: 5}},
+			TopDomains:    []model.DomainCount{{Domain: "ya.ru", Count: 2}},
+			StorageSize:   1024,
+		}
+	)
+	shortener.
+		On("GetStats", 7, defaultStatsTop).Return(stats, nil).Once().
+		On("GetStats", defaultStatsDays, defaultStatsTop).Return(model.Stats{}, errors.New("")).Once()
+	server := ShortenerServer{
+		shortener: shortener,
+	}
+
+	resp, err := server.GetStats(ctx, &proto.GetStatsRequest{Days: 7})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(3), resp.GetUrls())
+	assert.Equal(t, int32(2), resp.GetUsers())
+	assert.Equal(t, int32(2), resp.GetActive())
+	assert.Equal(t, int32(1), resp.GetDeleted())
+	assert.Equal(t, int32(1), resp.GetExpired())
+	assert.Equal(t, int32(1), resp.GetDisabled())
+	assert.Equal(t, int64(1024), resp.GetStorageSize())
+	require.Len(t, resp.GetCreatedPerDay(), 1)
+	assert.Equal(t, "2023-05-10", resp.GetCreatedPerDay()[0].GetDate())
+	assert.Equal(t, int32(3), resp.GetCreatedPerDay()[0].GetCount())
+	require.Len(t, resp.GetTopLinks(), 1)
+	assert.Equal(t, "id", resp.GetTopLinks()[0].GetId())
+	assert.Equal(t, int64(5), resp.GetTopLinks()[0].GetClicks())
+	require.Len(t, resp.GetTopDomains(), 1)
+	assert.Equal(t, "ya.ru", resp.GetTopDomains()[0].GetDomain())
+	_, err = server.GetStats(ctx, &proto.GetStatsRequest{})
+	testGRPCErrorCode(t, err, codes.Internal)
+	_, err = server.GetStats(ctx, &proto.GetStatsRequest{Days: maxStatsDays + 1})
+	testGRPCErrorCode(t, err, codes.InvalidArgument)
+	_, err = server.GetStats(ctx, &proto.GetStatsRequest{Top: -1})
+	testGRPCErrorCode(t, err, codes.InvalidArgument)
 	shortener.AssertExpectations(t)
 }
 
@@ -166,7 +340,7 @@ func TestGRPCUserAuthenticationErrors(t *testing.T) {
 		ctx           = context.Background()
 		authenticator = &AuthenticatorMock{}
 	)
-	authenticator.On("UserIdentifier").Return("", errors.New("")).Times(4)
+	authenticator.On("UserIdentifier").Return("", errors.New("")).Times(6)
 	server := ShortenerServer{
 		authenticator: authenticator,
 	}
@@ -179,8 +353,49 @@ func TestGRPCUserAuthenticationErrors(t *testing.T) {
 	testGRPCErrorCode(t, err, codes.PermissionDenied)
 	_, err = server.DeleteURLBatch(ctx, &proto.DeleteURLBatchRequest{})
 	testGRPCErrorCode(t, err, codes.PermissionDenied)
+	_, err = server.GetVariantStats(ctx, &proto.GetVariantStatsRequest{})
+	testGRPCErrorCode(t, err, codes.PermissionDenied)
+	_, err = server.GetQRCode(ctx, &proto.GetQRCodeRequest{})
+	testGRPCErrorCode(t, err, codes.PermissionDenied)
+
+	authenticator.AssertExpectations(t)
+}
+
+func TestGRPCGetQRCode(t *testing.T) {
+	var (
+		ctx           = context.Background()
+		id            = "1i-CBrzwyMkL"
+		userID        = "438c4b98-fc98-45cf-ac63-c4a86fbd4ff4"
+		png           = []byte{0x89, 'P', 'N', 'G'}
+		margin        = 0
+		opts          = model.QROptions{Format: model.QRFormatPNG, Size: 300, Margin: &margin, Level: "Q"}
+		request       = &proto.GetQRCodeRequest{Id: id, Format: "png", Size: 300, Margin: new(int32), Ecc: "Q"}
+		authenticator = &AuthenticatorMock{}
+		generator     = &QRCodeGeneratorMock{}
+		invalidErr    = fmt.Errorf("%w: unknown format", inerr.ErrInvalidQROptions)
+	)
+	authenticator.On("UserIdentifier").Return(userID, nil).Times(5)
+	generator.
+		On("Generate", id, userID, opts).Return(png, "image/png", nil).Once().
+		On("Generate", id, userID, model.QROptions{}).Return([]byte{}, "", inerr.ErrURLNotFound).Once().
+		On("Generate", id, userID, model.QROptions{}).Return([]byte{}, "", inerr.ErrURLIsDeleted).Once().
+		On("Generate", id, userID, model.QROptions{}).Return([]byte{}, "", invalidErr).Once().
+		On("Generate", id, userID, model.QROptions{}).Return([]byte{}, "", errors.New("")).Once()
+	server := ShortenerServer{
+		authenticator: authenticator,
+		qrCodes:       generator,
+	}
 
+	resp, err := server.GetQRCode(ctx, request)
+	assert.NoError(t, err)
+	assert.Equal(t, png, resp.GetData())
+	assert.Equal(t, "image/png", resp.GetContentType())
+	for _, code := range []codes.Code{codes.NotFound, codes.FailedPrecondition, codes.InvalidArgument, codes.Internal} {
+		_, err = server.GetQRCode(ctx, &proto.GetQRCodeRequest{Id: id})
+		testGRPCErrorCode(t, err, code)
+	}
 	authenticator.AssertExpectations(t)
+	generator.AssertExpectations(t)
 }
 
 func testGRPCErrorCode(t *testing.T, err error, code codes.Code) {
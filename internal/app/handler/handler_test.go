@@ -1,9 +1,12 @@
 package handler
 
 import (
+	"context"
 	"io"
 	"net/http"
 	"net/http/httptest"
+
+	"github.com/go-chi/chi/v5"
 )
 
 func sendTestRequest(method string, target string, body io.Reader, handler http.HandlerFunc) *http.Response {
@@ -14,6 +17,19 @@ func sendTestRequest(method string, target string, body io.Reader, handler http.
 	return w.Result()
 }
 
+func sendTestRequestWithParams(method string, target string, body io.Reader, params map[string]string, handler http.HandlerFunc) *http.Response {
+	request := httptest.NewRequest(method, target, body)
+	rctx := chi.NewRouteContext()
+	for k, v := range params {
+		rctx.URLParams.Add(k, v)
+	}
+	request = request.WithContext(context.WithValue(request.Context(), chi.RouteCtxKey, rctx))
+	w := httptest.NewRecorder()
+	handler(w, request)
+
+	return w.Result()
+}
+
 func sendBenchmarkRequest(method string, target string, body io.Reader, handler http.HandlerFunc) {
 	handler(httptest.NewRecorder(), httptest.NewRequest(method, target, body))
 }
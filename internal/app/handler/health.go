@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/ivanpodgorny/urlshortener/internal/app/model"
+)
+
+// Значения параметра запроса health списка URL пользователя.
+const (
+	healthFilterBroken    = "broken"
+	healthFilterHealthy   = "healthy"
+	healthFilterUnchecked = "unchecked"
+)
+
+// LinkHealth реализует хендлеры для работы с результатами проверки адресов назначения.
+type LinkHealth struct {
+	checker HealthSummarizer
+}
+
+// HealthSummarizer интерфейс сервиса проверки адресов назначения.
+type HealthSummarizer interface {
+	Summary(ctx context.Context) (model.HealthSummary, error)
+}
+
+// NewLinkHealth возвращает указатель на новый экземпляр LinkHealth.
+func NewLinkHealth(c HealthSummarizer) *LinkHealth {
+	return &LinkHealth{
+		checker: c,
+	}
+}
+
+// GetSummary возвращает сводку проверок адресов назначения неудаленных URL в формате
+//
+//	{"total": 10, "unchecked": 1, "healthy": 6, "broken": 3, "statuses": {"200": 6, "404": 2, "0": 1}}
+//
+// В statuses код 0 означает, что ответ от адреса назначения не получен.
+func (h LinkHealth) GetSummary(w http.ResponseWriter, r *http.Request) {
+	s, err := h.checker.Summary(r.Context())
+	if err != nil {
+		serverError(w)
+
+		return
+	}
+
+	responseAsJSON(w, s, http.StatusOK)
+}
+
+// isHealthFilter проверяет значение параметра запроса health. Пустая строка — фильтр не задан.
+func isHealthFilter(filter string) bool {
+	switch filter {
+	case "", healthFilterBroken, healthFilterHealthy, healthFilterUnchecked:
+		return true
+	}
+
+	return false
+}
+
+// matchesHealthFilter возвращает true, если результат проверки h соответствует фильтру filter.
+func matchesHealthFilter(h model.LinkHealth, filter string) bool {
+	switch filter {
+	case healthFilterBroken:
+		return h.Broken()
+	case healthFilterHealthy:
+		return h.Checked() && !h.Broken()
+	case healthFilterUnchecked:
+		return !h.Checked()
+	}
+
+	return true
+}
@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ivanpodgorny/urlshortener/internal/app/model"
+)
+
+type HealthSummarizerMock struct {
+	mock.Mock
+}
+
+func (m *HealthSummarizerMock) Summary(context.Context) (model.HealthSummary, error) {
+	args := m.Called()
+
+	return args.Get(0).(model.HealthSummary), args.Error(1)
+}
+
+func TestLinkHealth_GetSummary(t *testing.T) {
+	var (
+		summary = model.HealthSummary{
+			Total:     4,
+			Unchecked: 1,
+			Healthy:   2,
+			Broken:    1,
+			Statuses:  map[int]int{200: 2, 0: 1},
+		}
+		checker = &HealthSummarizerMock{}
+	)
+	checker.
+		On("Summary").Return(summary, nil).Once().
+		On("Summary").Return(model.HealthSummary{}, errors.New("")).Once()
+	handler := NewLinkHealth(checker)
+
+	result := sendTestRequest(http.MethodGet, "/", nil, handler.GetSummary)
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+	assert.Equal(t, "application/json", result.Header.Get("Content-Type"))
+	b, err := io.ReadAll(result.Body)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"total":4,"unchecked":1,"healthy":2,"broken":1,"statuses":{"0":1,"200":2}}`, string(b))
+	require.NoError(t, result.Body.Close())
+
+	result = sendTestRequest(http.MethodGet, "/", nil, handler.GetSummary)
+	assert.Equal(t, http.StatusInternalServerError, result.StatusCode, "ошибка сервиса")
+	require.NoError(t, result.Body.Close())
+	checker.AssertExpectations(t)
+}
@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// reservedParams параметры запроса, которые обрабатываются сервисом
+// и не переносятся в адрес назначения.
+var reservedParams = []string{"password", previewParam}
+
+// pathSuffix возвращает суффикс пути запроса /{id}/extra/path без ведущего "/"
+// в декодированном виде.
+func pathSuffix(r *http.Request) string {
+	_, suffix, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+
+	return suffix
+}
+
+// passthroughQuery возвращает параметры запроса, которые можно перенести в адрес назначения.
+// Если таких параметров нет, возвращает nil.
+func passthroughQuery(r *http.Request) url.Values {
+	query := r.URL.Query()
+	for _, p := range reservedParams {
+		query.Del(p)
+	}
+
+	if len(query) == 0 {
+		return nil
+	}
+
+	return query
+}
@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"html/template"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ivanpodgorny/urlshortener/internal/app/logger"
+)
+
+// passwordHeader HTTP-заголовок, в котором передается пароль защищенного URL.
+const passwordHeader = "X-Link-Password"
+
+var passwordForm = template.Must(template.New("password").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Password required</title>
+</head>
+<body>
+<form method="post">
+<p>This link is password protected.</p>
+{{if .}}<p>{{.}}</p>
+{{end}}<input type="password" name="password" autofocus required>
+<button type="submit">Open</button>
+</form>
+</body>
+</html>
+`))
+
+// passwordFromRequest возвращает пароль защищенного URL из заголовка X-Link-Password,
+// параметра запроса или поля формы password.
+func passwordFromRequest(r *http.Request) string {
+	if password := r.Header.Get(passwordHeader); password != "" {
+		return password
+	}
+
+	return r.FormValue("password")
+}
+
+// renderPasswordForm отправляет форму ввода пароля защищенного URL с сообщением message.
+func renderPasswordForm(w http.ResponseWriter, r *http.Request, message string, code int) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	if err := passwordForm.Execute(w, message); err != nil {
+		logger.FromContext(r.Context()).ErrorCtx(r.Context(), "Error while rendering password form", "error", err)
+	}
+}
+
+func retryAfter(w http.ResponseWriter, d time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(d.Seconds()))))
+}
@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"html/template"
+	"net/http"
+
+	"github.com/ivanpodgorny/urlshortener/internal/app/logger"
+	"github.com/ivanpodgorny/urlshortener/internal/app/model"
+)
+
+// previewParam параметр запроса, который включает предпросмотр перехода вместо редиректа.
+const previewParam = "preview"
+
+var previewPage = template.Must(template.New("preview").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex, nofollow">
+<title>Link preview</title>
+</head>
+<body>
+<p>This link leads to:</p>
+{{if .Title}}<h1>{{.Title}}</h1>
+{{end}}<p><a href="{{.URL}}" rel="noopener noreferrer nofollow">{{.DisplayURL}}</a></p>
+{{if not .CreatedAt.IsZero}}<p>Created <time datetime="{{.CreatedAt.UTC.Format "2006-01-02T15:04:05Z07:00"}}">{{.CreatedAt.UTC.Format "January 2, 2006"}}</time></p>
+{{end}}</body>
+</html>
+`))
+
+// isPreviewRequest возвращает true, если в запросе перехода по URL запрошен предпросмотр.
+func isPreviewRequest(r *http.Request) bool {
+	return r.URL.Query().Get(previewParam) == "1"
+}
+
+// renderPreview отправляет страницу предпросмотра перехода по URL.
+func renderPreview(w http.ResponseWriter, r *http.Request, p model.Preview) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Robots-Tag", "noindex, nofollow")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.WriteHeader(http.StatusOK)
+	if err := previewPage.Execute(w, p); err != nil {
+		logger.FromContext(r.Context()).ErrorCtx(r.Context(), "Error while rendering preview page", "error", err)
+	}
+}
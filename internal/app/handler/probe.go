@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/ivanpodgorny/urlshortener/internal/app/model"
+)
+
+// Probe реализует хендлеры проверки работоспособности и готовности сервиса.
+type Probe struct {
+	prober Prober
+}
+
+// Prober интерфейс сервиса проверки работоспособности и готовности сервиса.
+type Prober interface {
+	Live(ctx context.Context) model.ProbeReport
+	Ready(ctx context.Context) model.ProbeReport
+}
+
+// NewProbe возвращает указатель на новый экземпляр Probe.
+func NewProbe(p Prober) *Probe {
+	return &Probe{
+		prober: p,
+	}
+}
+
+// Liveness проверяет, что сервис работоспособен.
+// Результат возвращается в формате
+//
+//	{"status": "ok", "checks": {"grpc": {"status": "ok"}}}
+//
+// с кодом 200, если все проверки пройдены, иначе с кодом 503.
+func (h Probe) Liveness(w http.ResponseWriter, r *http.Request) {
+	respondProbe(w, h.prober.Live(r.Context()))
+}
+
+// Readiness проверяет, что сервис и все его зависимости готовы обрабатывать запросы.
+// Результат возвращается в формате
+//
+//	{"status": "fail", "checks": {"database": {"status": "fail", "error": "..."}, "grpc": {"status": "ok"}}}
+//
+// с кодом 200, если все проверки пройдены, иначе с кодом 503.
+func (h Probe) Readiness(w http.ResponseWriter, r *http.Request) {
+	respondProbe(w, h.prober.Ready(r.Context()))
+}
+
+func respondProbe(w http.ResponseWriter, report model.ProbeReport) {
+	code := http.StatusOK
+	if report.Status != model.ProbeStatusOK {
+		code = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Cache-Control", "no-store")
+
+	responseAsJSON(w, report, code)
+}
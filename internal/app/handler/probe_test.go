@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ivanpodgorny/urlshortener/internal/app/model"
+)
+
+type ProberMock struct {
+	mock.Mock
+}
+
+func (m *ProberMock) Live(_ context.Context) model.ProbeReport {
+	args := m.Called()
+
+	return args.Get(0).(model.ProbeReport)
+}
+
+func (m *ProberMock) Ready(_ context.Context) model.ProbeReport {
+	args := m.Called()
+
+	return args.Get(0).(model.ProbeReport)
+}
+
+func TestProbe(t *testing.T) {
+	var (
+		ok = model.ProbeReport{
+			Status: model.ProbeStatusOK,
+			Checks: map[string]model.ProbeResult{"grpc": {Status: model.ProbeStatusOK}},
+		}
+		fail = model.ProbeReport{
+			Status: model.ProbeStatusFail,
+			Checks: map[string]model.ProbeResult{
+				"grpc":     {Status: model.ProbeStatusOK},
+				"database": {Status: model.ProbeStatusFail, Error: "connection refused"},
+			},
+		}
+		prober = &ProberMock{}
+		h      = NewProbe(prober)
+	)
+	prober.
+		On("Live").Return(ok).Once().
+		On("Ready").Return(fail).Once()
+
+	tests := []struct {
+		name           string
+		handler        http.HandlerFunc
+		wantStatusCode int
+		wantBody       string
+	}{
+		{
+			name:           "сервис работоспособен",
+			handler:        h.Liveness,
+			wantStatusCode: http.StatusOK,
+			wantBody:       `{"status":"ok","checks":{"grpc":{"status":"ok"}}}`,
+		},
+		{
+			name:           "зависимость недоступна",
+			handler:        h.Readiness,
+			wantStatusCode: http.StatusServiceUnavailable,
+			wantBody:       `{"status":"fail","checks":{"database":{"status":"fail","error":"connection refused"},"grpc":{"status":"ok"}}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := sendTestRequest(http.MethodGet, "/", nil, tt.handler)
+			body, err := io.ReadAll(result.Body)
+			require.NoError(t, err)
+			require.NoError(t, result.Body.Close())
+			assert.Equal(t, tt.wantStatusCode, result.StatusCode)
+			assert.Equal(t, "application/json", result.Header.Get("Content-Type"))
+			assert.Equal(t, "no-store", result.Header.Get("Cache-Control"))
+			assert.JSONEq(t, tt.wantBody, string(body))
+		})
+	}
+	prober.AssertExpectations(t)
+}
@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	inerr "github.com/ivanpodgorny/urlshortener/internal/app/errors"
+	"github.com/ivanpodgorny/urlshortener/internal/app/model"
+)
+
+// qrCodeMaxAge время кеширования изображения QR-кода клиентом. Сокращенный URL
+// не меняется, поэтому изображение можно кешировать долго.
+const qrCodeMaxAge = "86400"
+
+// QRCode обработчик запросов изображений QR-кодов сокращенных URL.
+type QRCode struct {
+	authenticator IdentityProvider
+	generator     QRCodeGenerator
+}
+
+// QRCodeGenerator интерфейс сервиса генерации QR-кодов.
+type QRCodeGenerator interface {
+	Generate(ctx context.Context, id, userID string, opts model.QROptions) ([]byte, string, error)
+}
+
+// NewQRCode возвращает указатель на новый экземпляр QRCode.
+func NewQRCode(a IdentityProvider, g QRCodeGenerator) *QRCode {
+	return &QRCode{
+		authenticator: a,
+		generator:     g,
+	}
+}
+
+// Get возвращает изображение QR-кода сокращенного URL пользователя, выполнившего запрос.
+// Параметры изображения передаются в строке запроса:
+//   - format — формат изображения: png (по умолчанию) или svg;
+//   - size — сторона изображения в пикселях;
+//   - margin — ширина свободной зоны вокруг QR-кода в модулях;
+//   - ecc — уровень коррекции ошибок: L, M (по умолчанию), Q или H.
+//
+// Если параметры некорректны, возвращает ответ с кодом 400 и телом
+//
+//	{"error": "<описание>"}
+//
+// Если URL не найден или принадлежит другому пользователю, возвращает ответ с кодом 404,
+// если URL удален — с кодом 410.
+func (h QRCode) Get(w http.ResponseWriter, r *http.Request) {
+	userID, err := h.authenticator.UserIdentifier(r.Context())
+	if err != nil {
+		unauthorized(w)
+
+		return
+	}
+
+	opts, err := qrOptionsFromRequest(r)
+	if err != nil {
+		badRequest(w)
+
+		return
+	}
+
+	data, contentType, err := h.generator.Generate(r.Context(), chi.URLParam(r, "id"), userID, opts)
+	if errors.Is(err, inerr.ErrURLNotFound) {
+		http.NotFound(w, r)
+
+		return
+	}
+
+	if errors.Is(err, inerr.ErrURLIsDeleted) {
+		w.WriteHeader(http.StatusGone)
+
+		return
+	}
+
+	if errors.Is(err, inerr.ErrInvalidQROptions) {
+		responseAsJSON(w, struct {
+			Error string `json:"error"`
+		}{
+			Error: err.Error(),
+		}, http.StatusBadRequest)
+
+		return
+	}
+
+	if err != nil {
+		serverError(w)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "private, max-age="+qrCodeMaxAge)
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(data); err != nil {
+		serverError(w)
+	}
+}
+
+// qrOptionsFromRequest возвращает параметры изображения QR-кода из строки запроса.
+// Отсутствующие параметры получают нулевые значения.
+func qrOptionsFromRequest(r *http.Request) (model.QROptions, error) {
+	q := r.URL.Query()
+	opts := model.QROptions{
+		Format: q.Get("format"),
+		Level:  q.Get("ecc"),
+	}
+
+	if s := q.Get("size"); s != "" {
+		size, err := strconv.Atoi(s)
+		if err != nil {
+			return opts, err
+		}
+		opts.Size = size
+	}
+
+	if s := q.Get("margin"); s != "" {
+		margin, err := strconv.Atoi(s)
+		if err != nil {
+			return opts, err
+		}
+		opts.Margin = &margin
+	}
+
+	return opts, nil
+}
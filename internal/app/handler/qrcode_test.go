@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	inerr "github.com/ivanpodgorny/urlshortener/internal/app/errors"
+	"github.com/ivanpodgorny/urlshortener/internal/app/model"
+)
+
+type QRCodeGeneratorMock struct {
+	mock.Mock
+}
+
+func (m *QRCodeGeneratorMock) Generate(_ context.Context, id, userID string, opts model.QROptions) ([]byte, string, error) {
+	args := m.Called(id, userID, opts)
+
+	return args.Get(0).([]byte), args.String(1), args.Error(2)
+}
+
+func TestQRCodeHandler_Get(t *testing.T) {
+	var (
+		urlID         = "1i-CBrzwyMkL"
+		userID        = "438c4b98-fc98-45cf-ac63-c4a86fbd4ff4"
+		svg           = []byte("<svg/>")
+		margin        = 2
+		opts          = model.QROptions{Format: model.QRFormatSVG, Size: 512, Margin: &margin, Level: "H"}
+		params        = map[string]string{"id": urlID}
+		generator     = &QRCodeGeneratorMock{}
+		authenticator = &AuthenticatorMock{}
+		invalidErr    = fmt.Errorf("%w: margin must be between 0 and 16", inerr.ErrInvalidQROptions)
+	)
+
+	authenticator.
+		On("UserIdentifier").Return(userID, nil).Times(7).
+		On("UserIdentifier").Return("", errors.New("")).Once()
+	generator.
+		On("Generate", urlID, userID, opts).Return(svg, "image/svg+xml", nil).Once().
+		On("Generate", urlID, userID, model.QROptions{}).Return([]byte{}, "", inerr.ErrURLNotFound).Once().
+		On("Generate", urlID, userID, model.QROptions{}).Return([]byte{}, "", inerr.ErrURLIsDeleted).Once().
+		On("Generate", urlID, userID, model.QROptions{}).Return([]byte{}, "", invalidErr).Once().
+		On("Generate", urlID, userID, model.QROptions{}).Return([]byte{}, "", errors.New("")).Once()
+	handler := NewQRCode(authenticator, generator)
+
+	result := sendTestRequestWithParams(http.MethodGet, "/?format=svg&size=512&margin=2&ecc=H", nil, params, handler.Get)
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+	assert.Equal(t, "image/svg+xml", result.Header.Get("Content-Type"))
+	assert.Equal(t, "private, max-age=86400", result.Header.Get("Cache-Control"))
+	b, err := io.ReadAll(result.Body)
+	require.NoError(t, err)
+	assert.Equal(t, svg, b)
+	require.NoError(t, result.Body.Close())
+
+	for _, tt := range []struct {
+		name   string
+		target string
+		status int
+	}{
+		{name: "URL не найден", target: "/", status: http.StatusNotFound},
+		{name: "URL удален", target: "/", status: http.StatusGone},
+		{name: "некорректные параметры", target: "/", status: http.StatusBadRequest},
+		{name: "ошибка генерации", target: "/", status: http.StatusInternalServerError},
+		{name: "нечисловой размер", target: "/?size=big", status: http.StatusBadRequest},
+		{name: "нечисловая свободная зона", target: "/?margin=x", status: http.StatusBadRequest},
+		{name: "пользователь не аутентифицирован", target: "/", status: http.StatusUnauthorized},
+	} {
+		result := sendTestRequestWithParams(http.MethodGet, tt.target, nil, params, handler.Get)
+		assert.Equal(t, tt.status, result.StatusCode, tt.name)
+		require.NoError(t, result.Body.Close())
+	}
+
+	authenticator.AssertExpectations(t)
+	generator.AssertExpectations(t)
+}
@@ -13,6 +13,10 @@ func unauthorized(w http.ResponseWriter) {
 	http.Error(w, "401 unauthorized", http.StatusUnauthorized)
 }
 
+func forbidden(w http.ResponseWriter) {
+	http.Error(w, "403 forbidden", http.StatusForbidden)
+}
+
 func serverError(w http.ResponseWriter) {
 	http.Error(w, "500 internal server error", http.StatusInternalServerError)
 }
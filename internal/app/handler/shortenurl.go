@@ -4,23 +4,29 @@ import (
 	"context"
 	"errors"
 	"io"
-	"log"
 	"net/http"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 
+	"github.com/ivanpodgorny/urlshortener/internal/app/clientinfo"
 	inerr "github.com/ivanpodgorny/urlshortener/internal/app/errors"
+	"github.com/ivanpodgorny/urlshortener/internal/app/logger"
+	"github.com/ivanpodgorny/urlshortener/internal/app/model"
 	"github.com/ivanpodgorny/urlshortener/internal/app/validator"
 )
 
 // ShortenURL реализует хендлеры для работы с сокращенными URL.
 type ShortenURL struct {
-	authenticator IdentityProvider
-	shortener     Shortener
-	wg            *sync.WaitGroup
-	baseURL       string
+	authenticator  IdentityProvider
+	shortener      Shortener
+	wg             *sync.WaitGroup
+	pending        *atomic.Int64
+	baseURL        string
+	redirectStatus int
 }
 
 // IdentityProvider интерфейс для получения ID пользователя, выполнившего запрос.
@@ -30,27 +36,62 @@ type IdentityProvider interface {
 
 // Shortener интерфейс сервиса сокращения и получения URL.
 type Shortener interface {
-	Shorten(ctx context.Context, url string, userID string) (string, bool, error)
-	Get(ctx context.Context, id string) (string, error)
-	GetAllUser(ctx context.Context, userID string) map[string]string
+	Shorten(ctx context.Context, url string, userID string, opts model.LinkOptions) (string, bool, error)
+	Get(ctx context.Context, id string, req model.RedirectRequest) (model.Redirect, error)
+	Preview(ctx context.Context, id string, req model.RedirectRequest) (model.Preview, error)
+	GetUserLinks(ctx context.Context, userID string) ([]model.Link, error)
 	DeleteBatch(ctx context.Context, urlIDs []string, userID string) error
-	GetStat(context.Context) (urlCount int, usersCount int, err error)
+	RestoreBatch(ctx context.Context, urlIDs []string, userID string) error
+	GetStats(ctx context.Context, days, top int) (model.Stats, error)
+	GetRules(ctx context.Context, id, userID string) ([]model.RedirectRule, error)
+	SetRules(ctx context.Context, id, userID string, rules []model.RedirectRule) error
+	GetVariantStats(ctx context.Context, id, userID string) (model.VariantStats, error)
+	SetVariants(ctx context.Context, id, userID string, variants model.Variants) error
+	GetRedirectOptions(ctx context.Context, id, userID string) (model.RedirectOptions, error)
+	SetRedirectOptions(ctx context.Context, id, userID string, opts model.RedirectOptions) error
 }
 
 const deleteBatchSize = 250
 
+// maxCreateBatchSize максимальное количество URL в запросе на создание нескольких сокращенных URL.
+const maxCreateBatchSize = 1000
+
 // NewShortenURL возвращает указатель на новый экземпляр ShortenURL.
-func NewShortenURL(a IdentityProvider, s Shortener, b string, wg *sync.WaitGroup) *ShortenURL {
+// Переход по URL, для которого не задан собственный код ответа, выполняется
+// с кодом code. Если code равен 0, используется код 307.
+func NewShortenURL(a IdentityProvider, s Shortener, b string, code int, wg *sync.WaitGroup) *ShortenURL {
 	return &ShortenURL{
-		authenticator: a,
-		shortener:     s,
-		baseURL:       b,
-		wg:            wg,
+		authenticator:  a,
+		shortener:      s,
+		baseURL:        b,
+		redirectStatus: code,
+		wg:             wg,
+		pending:        &atomic.Int64{},
+	}
+}
+
+// PendingDeletes возвращает количество URL, принятых на удаление, но еще не удаленных.
+func (h ShortenURL) PendingDeletes() int64 {
+	if h.pending == nil {
+		return 0
+	}
+
+	return h.pending.Load()
+}
+
+// addPending изменяет количество URL, ожидающих удаления, на delta.
+func (h ShortenURL) addPending(delta int) {
+	if h.pending != nil {
+		h.pending.Add(int64(delta))
 	}
 }
 
 // Create обрабатывает запрос на создание сокращенного URL.
 // Оригинальный URL передается в теле запроса. В теле ответа приходит сокращенный URL.
+// Если пользователь заблокирован администратором, возвращает ответ с кодом 403.
+// Если адрес назначения запрещен политикой безопасности, возвращает ответ с кодом 400
+// и причиной отказа в теле. Пароль для перехода по URL можно передать в HTTP-заголовке
+// X-Link-Password.
 func (h ShortenURL) Create(w http.ResponseWriter, r *http.Request) {
 	userID, err := h.authenticator.UserIdentifier(r.Context())
 	if err != nil {
@@ -66,7 +107,27 @@ func (h ShortenURL) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	id, inserted, err := h.shortener.Shorten(r.Context(), string(b), userID)
+	opts := model.LinkOptions{Password: r.Header.Get(passwordHeader)}
+	id, inserted, err := h.shortener.Shorten(r.Context(), string(b), userID, opts)
+	if errors.Is(err, inerr.ErrUserIsBanned) {
+		forbidden(w)
+
+		return
+	}
+
+	if errors.Is(err, inerr.ErrPasswordTooLong) {
+		badRequest(w)
+
+		return
+	}
+
+	var rejection *validator.RejectionError
+	if errors.As(err, &rejection) {
+		http.Error(w, "400 bad request: "+rejection.Error(), http.StatusBadRequest)
+
+		return
+	}
+
 	if err != nil {
 		serverError(w)
 
@@ -84,13 +145,24 @@ func (h ShortenURL) Create(w http.ResponseWriter, r *http.Request) {
 // CreateJSON обрабатывает запрос на создание сокращенного URL.
 // Оригинальный URL передается в теле запроса в формате JSON
 //
-//	{"url":"<some_url>"}
+//	{"url":"<some_url>", "password": "<пароль>", "title": "<заголовок>", "expires_at": "2006-01-02T15:04:05Z"}
 //
+// Поле password необязательно: если оно задано, для перехода по URL потребуется пароль.
+// Необязательное поле expires_at задает время в формате RFC 3339, после которого переход
+// по URL невозможен; если это время уже наступило, возвращает ответ с кодом 400.
+// Необязательный заголовок страницы назначения показывается на странице предпросмотра перехода.
 // В теле ответа приходит JSON формата
 //
 //	{"result":"<shorten_url>"}
 //
-// с сокращенным URL.
+// с сокращенным URL. Если домен URL может быть подменой другого домена похожими
+// символами, в ответ добавляется список предупреждений
+//
+//	{"result":"<shorten_url>", "warnings": ["mixed_script", ...]}
+//
+// Если адрес назначения запрещен политикой безопасности, возвращает ответ с кодом 400 и телом
+//
+//	{"error": "<описание>", "reason": "<причина отказа>"}
 func (h ShortenURL) CreateJSON(w http.ResponseWriter, r *http.Request) {
 	userID, err := h.authenticator.UserIdentifier(r.Context())
 	if err != nil {
@@ -100,7 +172,10 @@ func (h ShortenURL) CreateJSON(w http.ResponseWriter, r *http.Request) {
 	}
 
 	req := struct {
-		URL string `json:"url"`
+		URL       string    `json:"url"`
+		Password  string    `json:"password"`
+		Title     string    `json:"title"`
+		ExpiresAt time.Time `json:"expires_at"`
 	}{}
 	err = readJSONBody(&req, r)
 	if err != nil || !h.validateURL(req.URL) {
@@ -109,7 +184,36 @@ func (h ShortenURL) CreateJSON(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	id, inserted, err := h.shortener.Shorten(r.Context(), req.URL, userID)
+	id, inserted, err := h.shortener.Shorten(r.Context(), req.URL, userID, model.LinkOptions{
+		Password:  req.Password,
+		Title:     req.Title,
+		ExpiresAt: req.ExpiresAt,
+	})
+	if errors.Is(err, inerr.ErrUserIsBanned) {
+		forbidden(w)
+
+		return
+	}
+
+	if errors.Is(err, inerr.ErrPasswordTooLong) || errors.Is(err, inerr.ErrInvalidExpiry) {
+		badRequest(w)
+
+		return
+	}
+
+	var rejection *validator.RejectionError
+	if errors.As(err, &rejection) {
+		responseAsJSON(w, struct {
+			Error  string `json:"error"`
+			Reason string `json:"reason"`
+		}{
+			Error:  rejection.Error(),
+			Reason: rejection.Reason,
+		}, http.StatusBadRequest)
+
+		return
+	}
+
 	if err != nil {
 		serverError(w)
 
@@ -124,9 +228,11 @@ func (h ShortenURL) CreateJSON(w http.ResponseWriter, r *http.Request) {
 	responseAsJSON(
 		w,
 		struct {
-			URL string `json:"result"`
+			URL      string   `json:"result"`
+			Warnings []string `json:"warnings,omitempty"`
 		}{
-			URL: h.prepareShortenURL(id),
+			URL:      h.prepareShortenURL(id),
+			Warnings: validator.URLWarnings(req.URL),
 		},
 		status,
 	)
@@ -141,7 +247,8 @@ func (h ShortenURL) CreateJSON(w http.ResponseWriter, r *http.Request) {
 //
 //	[{"correlation_id": "<строковый идентификатор>", "short_url": "<сокращённый URL>"}, ... ]
 //
-// с сокращенными URL.
+// с сокращенными URL. URL, адрес назначения которых запрещен политикой безопасности,
+// пропускаются.
 func (h ShortenURL) CreateBatch(w http.ResponseWriter, r *http.Request) {
 	userID, err := h.authenticator.UserIdentifier(r.Context())
 	if err != nil {
@@ -161,7 +268,7 @@ func (h ShortenURL) CreateBatch(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if valid, _ := validator.Validate[[]origBatchItem](req, validator.Size[origBatchItem](1000)); !valid {
+	if valid, _ := validator.Validate[[]origBatchItem](req, validator.Size[origBatchItem](maxCreateBatchSize)); !valid {
 		badRequest(w)
 
 		return
@@ -177,7 +284,13 @@ func (h ShortenURL) CreateBatch(w http.ResponseWriter, r *http.Request) {
 			continue
 		}
 
-		id, _, err := h.shortener.Shorten(r.Context(), u.URL, userID)
+		id, _, err := h.shortener.Shorten(r.Context(), u.URL, userID, model.LinkOptions{})
+		if errors.Is(err, inerr.ErrUserIsBanned) {
+			forbidden(w)
+
+			return
+		}
+
 		if err != nil {
 			continue
 		}
@@ -192,28 +305,123 @@ func (h ShortenURL) CreateBatch(w http.ResponseWriter, r *http.Request) {
 }
 
 // Get обрабатывает запрос на получение оригинального URL из сокращенного.
-// Возвращает ответ с кодом 307 и оригинальным URL в HTTP-заголовке Location.
-// Если URL был удален пользователем, возвращает ответ с кодом 410.
+// Возвращает ответ с оригинальным URL в каноническом виде в HTTP-заголовке Location и кодом,
+// заданным для URL, или кодом по умолчанию, если для URL код не задан.
+// Если у URL есть правила условного редиректа, адрес назначения выбирается по заголовкам
+// User-Agent и Accept-Language и стране клиента. Если у URL есть варианты адреса назначения,
+// переход выполняется на один из них, а закрепляемый вариант сохраняется в cookie.
+// Если для URL включен перенос запроса, параметры запроса и суффикс пути /{id}/extra/path
+// переносятся в адрес назначения. Переход с суффиксом пути по URL, для которого перенос пути
+// не включен, возвращает ответ с кодом 404, с некорректным суффиксом — с кодом 400.
+// Если URL был удален пользователем, заблокирован администратором или срок его действия
+// истек, возвращает ответ с кодом 410.
+// Для URL, защищенного паролем, пароль передается в HTTP-заголовке X-Link-Password,
+// параметре запроса password или полем password формы, отправленной методом POST.
+// Без пароля возвращает форму ввода пароля с кодом 401, с неверным паролем — с кодом 403,
+// при превышении частоты попыток — с кодом 429 и заголовком Retry-After.
+// С параметром запроса preview=1 вместо перехода возвращает страницу предпросмотра, как Preview.
 func (h ShortenURL) Get(w http.ResponseWriter, r *http.Request) {
-	u, err := h.shortener.Get(r.Context(), chi.URLParam(r, "id"))
-	if errors.Is(err, inerr.ErrURLIsDeleted) {
-		w.WriteHeader(http.StatusGone)
+	if isPreviewRequest(r) {
+		h.Preview(w, r)
 
 		return
 	}
 
+	id := chi.URLParam(r, "id")
+	res, err := h.shortener.Get(r.Context(), id, redirectRequest(r, id))
 	if err != nil {
-		http.NotFound(w, r)
+		redirectError(w, r, err)
 
 		return
 	}
 
-	redirect(w, u, http.StatusTemporaryRedirect)
+	if res.Sticky && res.Variant != "" {
+		setVariantCookie(w, r, id, res.Variant)
+	}
+
+	code := res.Status
+	if code == 0 {
+		code = h.defaultRedirectStatus()
+	}
+
+	redirect(w, res.URL, code)
 }
 
-// GetAllByCurrentUser возвращает все сокращенные URL пользователя, выполнившего запрос, в формате
+// Preview обрабатывает запрос на предпросмотр перехода по сокращенному URL /{id}+.
+// Возвращает HTML-страницу с адресом назначения, заголовком страницы назначения
+// и датой создания URL без перехода на адрес назначения. Переход на вариант адреса
+// назначения не учитывается. Ошибки обрабатываются так же, как в Get.
+func (h ShortenURL) Preview(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	p, err := h.shortener.Preview(r.Context(), id, redirectRequest(r, id))
+	if err != nil {
+		redirectError(w, r, err)
+
+		return
+	}
+
+	renderPreview(w, r, p)
+}
+
+// redirectRequest возвращает параметры запроса на переход по URL с ID id.
+func redirectRequest(r *http.Request, id string) model.RedirectRequest {
+	return model.RedirectRequest{
+		Password:       passwordFromRequest(r),
+		UserAgent:      r.UserAgent(),
+		AcceptLanguage: r.Header.Get("Accept-Language"),
+		Variant:        variantFromRequest(r, id),
+		Path:           pathSuffix(r),
+		Query:          passthroughQuery(r),
+	}
+}
+
+// redirectError отправляет ответ на запрос перехода по URL, завершившийся ошибкой err.
+func redirectError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, inerr.ErrURLIsDeleted) || errors.Is(err, inerr.ErrURLIsDisabled) || errors.Is(err, inerr.ErrURLIsExpired) {
+		w.WriteHeader(http.StatusGone)
+
+		return
+	}
+
+	if errors.Is(err, inerr.ErrPasswordRequired) {
+		renderPasswordForm(w, r, "", http.StatusUnauthorized)
+
+		return
+	}
+
+	if errors.Is(err, inerr.ErrInvalidPassword) {
+		renderPasswordForm(w, r, "Invalid password.", http.StatusForbidden)
+
+		return
+	}
+
+	var attempts *inerr.PasswordAttemptsError
+	if errors.As(err, &attempts) {
+		retryAfter(w, attempts.RetryAfter)
+		renderPasswordForm(w, r, "Too many attempts. Try again later.", http.StatusTooManyRequests)
+
+		return
+	}
+
+	if errors.Is(err, inerr.ErrInvalidPathSuffix) {
+		badRequest(w)
+
+		return
+	}
+
+	http.NotFound(w, r)
+}
+
+// GetAllByCurrentUser возвращает все сокращенные URL пользователя, выполнившего запрос,
+// в порядке создания в формате
+//
+//	[{"short_url": "http://...", "original_url": "http://...", "title": "...", "health": {"status": 404, "checked_at": "..."}}, ...]
 //
-//	[{"short_url": "http://...", "original_url": "http://..."}, ...]
+// Поле title содержит заголовок, заданный при создании URL или загруженный со страницы
+// назначения, и отсутствует, если заголовка нет. Поле health содержит результат последней
+// проверки доступности адреса назначения и отсутствует, если адрес не проверялся.
+// Параметр запроса health оставляет только URL с недоступным (broken), доступным (healthy)
+// или непроверенным (unchecked) адресом назначения.
 func (h ShortenURL) GetAllByCurrentUser(w http.ResponseWriter, r *http.Request) {
 	userID, err := h.authenticator.UserIdentifier(r.Context())
 	if err != nil {
@@ -222,17 +430,42 @@ func (h ShortenURL) GetAllByCurrentUser(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	health := r.URL.Query().Get("health")
+	if !isHealthFilter(health) {
+		badRequest(w)
+
+		return
+	}
+
+	links, err := h.shortener.GetUserLinks(r.Context(), userID)
+	if err != nil {
+		serverError(w)
+
+		return
+	}
+
 	type urlData struct {
-		ShortURL    string `json:"short_url"`
-		OriginalURL string `json:"original_url"`
-	}
-	urls := h.shortener.GetAllUser(r.Context(), userID)
-	resp := make([]urlData, 0, len(urls))
-	for id, u := range urls {
-		resp = append(resp, urlData{
-			ShortURL:    h.prepareShortenURL(id),
-			OriginalURL: u,
-		})
+		ShortURL    string            `json:"short_url"`
+		OriginalURL string            `json:"original_url"`
+		Title       string            `json:"title,omitempty"`
+		Health      *model.LinkHealth `json:"health,omitempty"`
+	}
+	resp := make([]urlData, 0, len(links))
+	for _, l := range links {
+		if !matchesHealthFilter(l.Health, health) {
+			continue
+		}
+
+		d := urlData{
+			ShortURL:    h.prepareShortenURL(l.ID),
+			OriginalURL: l.DisplayURL,
+			Title:       l.Title,
+		}
+		if l.Health.Checked() {
+			lh := l.Health
+			d.Health = &lh
+		}
+		resp = append(resp, d)
 	}
 
 	if len(resp) == 0 {
@@ -264,6 +497,10 @@ func (h ShortenURL) DeleteBatch(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var (
+		info = clientinfo.FromContext(r.Context())
+		log  = logger.FromContext(r.Context())
+	)
 	idsCount := len(urlIDs)
 	for i := 0; i < idsCount; i += deleteBatchSize {
 		end := i + deleteBatchSize
@@ -272,14 +509,17 @@ func (h ShortenURL) DeleteBatch(w http.ResponseWriter, r *http.Request) {
 		}
 
 		h.wg.Add(1)
+		h.addPending(end - i)
 		go func(chunk []string) {
 			defer h.wg.Done()
+			defer h.addPending(-len(chunk))
 
-			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			ctx := logger.WithLogger(clientinfo.WithInfo(context.Background(), info), log)
+			ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 			defer cancel()
 
-			if err = h.shortener.DeleteBatch(ctx, chunk, userID); err != nil {
-				log.Printf("Error while deleting url: %v", err)
+			if err := h.shortener.DeleteBatch(ctx, chunk, userID); err != nil {
+				log.ErrorCtx(ctx, "Error while deleting url", "error", err)
 			}
 		}(urlIDs[i:end])
 	}
@@ -287,27 +527,393 @@ func (h ShortenURL) DeleteBatch(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusAccepted)
 }
 
-// GetStat возвращает статистику использования сервиса в фомате
+// RestoreBatch принимает список идентификаторов удаленных сокращённых URL для восстановления в формате
+//
+//	["a", "b", "c", "d", ...]
+//
+// Восстанавливаются только URL пользователя, выполнившего запрос.
+// В случае успешного восстановления возвращает ответ с кодом 204.
+func (h ShortenURL) RestoreBatch(w http.ResponseWriter, r *http.Request) {
+	userID, err := h.authenticator.UserIdentifier(r.Context())
+	if err != nil {
+		unauthorized(w)
+
+		return
+	}
+
+	urlIDs := make([]string, 0)
+	if err = readJSONBody(&urlIDs, r); err != nil {
+		badRequest(w)
+
+		return
+	}
+
+	if valid, _ := validator.Validate[[]string](urlIDs, validator.Size[string](deleteBatchSize)); !valid {
+		badRequest(w)
+
+		return
+	}
+
+	if err = h.shortener.RestoreBatch(r.Context(), urlIDs, userID); err != nil {
+		serverError(w)
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetRules возвращает правила условного редиректа URL пользователя, выполнившего запрос, в формате
+//
+//	[{"platforms": ["ios"], "languages": ["de"], "countries": ["DE"], "not_before": "2006-01-02T15:04:05Z", "not_after": "...", "url": "http://..."}, ...]
+//
+// Если URL не найден или принадлежит другому пользователю, возвращает ответ с кодом 404.
+func (h ShortenURL) GetRules(w http.ResponseWriter, r *http.Request) {
+	userID, err := h.authenticator.UserIdentifier(r.Context())
+	if err != nil {
+		unauthorized(w)
+
+		return
+	}
+
+	rules, err := h.shortener.GetRules(r.Context(), chi.URLParam(r, "id"), userID)
+	if errors.Is(err, inerr.ErrURLNotFound) {
+		http.NotFound(w, r)
+
+		return
+	}
+
+	if err != nil {
+		serverError(w)
+
+		return
+	}
+
+	if rules == nil {
+		rules = []model.RedirectRule{}
+	}
+
+	responseAsJSON(w, rules, http.StatusOK)
+}
+
+// SetRules заменяет правила условного редиректа URL пользователя, выполнившего запрос.
+// Правила передаются в теле запроса в формате GetRules и проверяются в заданном порядке:
+// переход выполняется по адресу первого правила, всем условиям которого соответствует клиент,
+// а если такого нет — по основному адресу URL. Пустой список удаляет правила.
+// В случае успеха возвращает ответ с кодом 204. Если правило некорректно или адрес
+// назначения запрещен политикой безопасности, возвращает ответ с кодом 400 и телом
+//
+//	{"error": "<описание>", "reason": "<причина отказа адреса назначения>"}
+func (h ShortenURL) SetRules(w http.ResponseWriter, r *http.Request) {
+	userID, err := h.authenticator.UserIdentifier(r.Context())
+	if err != nil {
+		unauthorized(w)
+
+		return
+	}
+
+	rules := make([]model.RedirectRule, 0)
+	if err = readJSONBody(&rules, r); err != nil {
+		badRequest(w)
+
+		return
+	}
+
+	err = h.shortener.SetRules(r.Context(), chi.URLParam(r, "id"), userID, rules)
+	if errors.Is(err, inerr.ErrURLNotFound) {
+		http.NotFound(w, r)
+
+		return
+	}
+
+	type errorData struct {
+		Error  string `json:"error"`
+		Reason string `json:"reason,omitempty"`
+	}
+	var rejection *validator.RejectionError
+	if errors.As(err, &rejection) {
+		responseAsJSON(w, errorData{Error: rejection.Error(), Reason: rejection.Reason}, http.StatusBadRequest)
+
+		return
+	}
+
+	if errors.Is(err, inerr.ErrInvalidRedirectRule) {
+		responseAsJSON(w, errorData{Error: err.Error()}, http.StatusBadRequest)
+
+		return
+	}
+
+	if err != nil {
+		serverError(w)
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetVariants возвращает варианты адреса назначения URL пользователя, выполнившего запрос,
+// с количеством переходов на каждый из них в формате
+//
+//	{"sticky": true, "variants": [{"name": "a", "url": "http://...", "weight": 70, "clicks": 42}, ...]}
+//
+// Если URL не найден или принадлежит другому пользователю, возвращает ответ с кодом 404.
+func (h ShortenURL) GetVariants(w http.ResponseWriter, r *http.Request) {
+	userID, err := h.authenticator.UserIdentifier(r.Context())
+	if err != nil {
+		unauthorized(w)
+
+		return
+	}
+
+	stats, err := h.shortener.GetVariantStats(r.Context(), chi.URLParam(r, "id"), userID)
+	if errors.Is(err, inerr.ErrURLNotFound) {
+		http.NotFound(w, r)
+
+		return
+	}
+
+	if err != nil {
+		serverError(w)
+
+		return
+	}
+
+	if stats.Items == nil {
+		stats.Items = []model.VariantStat{}
+	}
+
+	responseAsJSON(w, stats, http.StatusOK)
+}
+
+// SetVariants заменяет варианты адреса назначения URL пользователя, выполнившего запрос.
+// Варианты передаются в теле запроса в формате
+//
+//	{"sticky": true, "variants": [{"name": "a", "url": "http://...", "weight": 70}, ...]}
+//
+// При каждом переходе, для которого не сработало правило условного редиректа, вариант
+// выбирается случайно пропорционально весу. Если sticky равен true, выбранный вариант
+// закрепляется за клиентом. Переходы считаются по имени варианта, вариантам без имени
+// присваивается порядковый номер. Пустой список удаляет варианты.
+// В случае успеха возвращает ответ с кодом 204. Если варианты некорректны или адрес
+// назначения запрещен политикой безопасности, возвращает ответ с кодом 400 и телом
+//
+//	{"error": "<описание>", "reason": "<причина отказа адреса назначения>"}
+func (h ShortenURL) SetVariants(w http.ResponseWriter, r *http.Request) {
+	userID, err := h.authenticator.UserIdentifier(r.Context())
+	if err != nil {
+		unauthorized(w)
+
+		return
+	}
+
+	variants := model.Variants{}
+	if err = readJSONBody(&variants, r); err != nil {
+		badRequest(w)
+
+		return
+	}
+
+	err = h.shortener.SetVariants(r.Context(), chi.URLParam(r, "id"), userID, variants)
+	if errors.Is(err, inerr.ErrURLNotFound) {
+		http.NotFound(w, r)
+
+		return
+	}
+
+	type errorData struct {
+		Error  string `json:"error"`
+		Reason string `json:"reason,omitempty"`
+	}
+	var rejection *validator.RejectionError
+	if errors.As(err, &rejection) {
+		responseAsJSON(w, errorData{Error: rejection.Error(), Reason: rejection.Reason}, http.StatusBadRequest)
+
+		return
+	}
+
+	if errors.Is(err, inerr.ErrInvalidVariant) {
+		responseAsJSON(w, errorData{Error: err.Error()}, http.StatusBadRequest)
+
+		return
+	}
+
+	if err != nil {
+		serverError(w)
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetRedirectOptions возвращает параметры формирования адреса назначения URL пользователя,
+// выполнившего запрос, в формате
+//
+//	{"query": "merge", "path": true, "template": true, "status": 308}
+//
+// Если URL не найден или принадлежит другому пользователю, возвращает ответ с кодом 404.
+func (h ShortenURL) GetRedirectOptions(w http.ResponseWriter, r *http.Request) {
+	userID, err := h.authenticator.UserIdentifier(r.Context())
+	if err != nil {
+		unauthorized(w)
+
+		return
+	}
+
+	opts, err := h.shortener.GetRedirectOptions(r.Context(), chi.URLParam(r, "id"), userID)
+	if errors.Is(err, inerr.ErrURLNotFound) {
+		http.NotFound(w, r)
+
+		return
+	}
+
+	if err != nil {
+		serverError(w)
+
+		return
+	}
+
+	responseAsJSON(w, opts, http.StatusOK)
+}
+
+// SetRedirectOptions заменяет параметры формирования адреса назначения URL пользователя,
+// выполнившего запрос. Параметры передаются в теле запроса в формате GetRedirectOptions:
+//   - query — перенос параметров запроса: "merge" добавляет параметры, которых нет
+//     в адресе назначения, "override" заменяет одноименные параметры адреса назначения;
+//   - path — разрешает переход /{id}/extra/path, суффикс добавляется к пути адреса назначения;
+//   - template — подставляет в плейсхолдеры {id}, {path} и {query.NAME} адреса назначения
+//     ID сокращенного URL, суффикс пути и значения параметров запроса;
+//   - status — код ответа при переходе: 301, 302, 307 или 308, без поля используется
+//     код по умолчанию.
+//
+// В случае успеха возвращает ответ с кодом 204. Если параметры некорректны, возвращает
+// ответ с кодом 400 и телом
+//
+//	{"error": "<описание>"}
+func (h ShortenURL) SetRedirectOptions(w http.ResponseWriter, r *http.Request) {
+	userID, err := h.authenticator.UserIdentifier(r.Context())
+	if err != nil {
+		unauthorized(w)
+
+		return
+	}
+
+	opts := model.RedirectOptions{}
+	if err = readJSONBody(&opts, r); err != nil {
+		badRequest(w)
+
+		return
+	}
+
+	err = h.shortener.SetRedirectOptions(r.Context(), chi.URLParam(r, "id"), userID, opts)
+	if errors.Is(err, inerr.ErrURLNotFound) {
+		http.NotFound(w, r)
+
+		return
+	}
+
+	if errors.Is(err, inerr.ErrInvalidRedirectOptions) {
+		responseAsJSON(w, struct {
+			Error string `json:"error"`
+		}{
+			Error: err.Error(),
+		}, http.StatusBadRequest)
+
+		return
+	}
+
+	if err != nil {
+		serverError(w)
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Параметры статистики использования сервиса по умолчанию и их максимальные значения.
+const (
+	defaultStatsDays = 30
+	maxStatsDays     = 365
+	defaultStatsTop  = 10
+	maxStatsTop      = 100
+)
+
+// GetStats возвращает статистику использования сервиса в фомате
 //
 //	{
-//	    "urls": <int>, (количество сокращённых URL в сервисе)
-//	    "users": <int> (количество пользователей в сервисе)
+//	    "urls": <int>, (количество неудаленных URL)
+//	    "users": <int>, (количество пользователей, у которых есть неудаленные URL)
+//	    "active": <int>, (количество неудаленных URL, не заблокированных администратором, с неистекшим сроком действия)
+//	    "deleted": <int>, (количество удаленных URL)
+//	    "expired": <int>, (количество неудаленных URL с истекшим сроком действия)
+//	    "disabled": <int>, (количество URL, заблокированных администратором)
+//	    "created_per_day": [{"date": "2006-01-02", "count": <int>}, ...],
+//	    "top_links": [{"id": "a", "url": "https://...", "clicks": <int>}, ...],
+//	    "top_domains": [{"domain": "example.com", "count": <int>}, ...],
+//	    "storage_size": <int> (размер хранилища в байтах)
 //	}
-func (h ShortenURL) GetStat(w http.ResponseWriter, r *http.Request) {
-	urlCount, usersCount, err := h.shortener.GetStat(r.Context())
+//
+// Параметр запроса days задает количество дней в created_per_day (по умолчанию 30,
+// не более 365), top — количество URL и доменов в рейтингах (по умолчанию 10, не более 100).
+// При некорректных параметрах возвращает ответ с кодом 400.
+func (h ShortenURL) GetStats(w http.ResponseWriter, r *http.Request) {
+	days, top, ok := statsQuery(r)
+	if !ok {
+		badRequest(w)
+
+		return
+	}
+
+	stats, err := h.shortener.GetStats(r.Context(), days, top)
 	if err != nil {
 		serverError(w)
 
 		return
 	}
 
-	responseAsJSON(w, struct {
-		URLCount   int `json:"urls"`
-		UsersCount int `json:"users"`
-	}{
-		URLCount:   urlCount,
-		UsersCount: usersCount,
-	}, http.StatusOK)
+	responseAsJSON(w, stats, http.StatusOK)
+}
+
+// statsQuery возвращает параметры статистики из строки запроса.
+func statsQuery(r *http.Request) (days int, top int, ok bool) {
+	for name, val := range map[string]*int{"days": &days, "top": &top} {
+		if s := r.URL.Query().Get(name); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 1 {
+				return 0, 0, false
+			}
+			*val = n
+		}
+	}
+
+	return statsParams(days, top)
+}
+
+// statsParams заменяет нулевые параметры статистики значениями по умолчанию.
+// Возвращает false, если параметры вне допустимых пределов.
+func statsParams(days, top int) (int, int, bool) {
+	if days == 0 {
+		days = defaultStatsDays
+	}
+	if top == 0 {
+		top = defaultStatsTop
+	}
+
+	if days < 1 || days > maxStatsDays || top < 1 || top > maxStatsTop {
+		return 0, 0, false
+	}
+
+	return days, top, true
+}
+
+func (h ShortenURL) defaultRedirectStatus() int {
+	if h.redirectStatus == 0 {
+		return http.StatusTemporaryRedirect
+	}
+
+	return h.redirectStatus
 }
 
 func (h ShortenURL) validateURL(u string) bool {
@@ -5,40 +5,53 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 
 	inerr "github.com/ivanpodgorny/urlshortener/internal/app/errors"
+	"github.com/ivanpodgorny/urlshortener/internal/app/model"
 	"github.com/ivanpodgorny/urlshortener/internal/app/security"
+	"github.com/ivanpodgorny/urlshortener/internal/app/validator"
 )
 
 type ShortenerMock struct {
 	mock.Mock
 }
 
-func (m *ShortenerMock) Shorten(_ context.Context, url, userID string) (string, bool, error) {
-	args := m.Called(url, userID)
+func (m *ShortenerMock) Shorten(_ context.Context, url, userID string, opts model.LinkOptions) (string, bool, error) {
+	args := m.Called(url, userID, opts.Password)
 
 	return args.String(0), args.Bool(1), args.Error(2)
 }
 
-func (m *ShortenerMock) Get(_ context.Context, id string) (string, error) {
-	args := m.Called(id)
+func (m *ShortenerMock) Get(_ context.Context, id string, req model.RedirectRequest) (model.Redirect, error) {
+	args := m.Called(id, req)
 
-	return args.String(0), args.Error(1)
+	return args.Get(0).(model.Redirect), args.Error(1)
+}
+
+func (m *ShortenerMock) Preview(_ context.Context, id string, req model.RedirectRequest) (model.Preview, error) {
+	args := m.Called(id, req)
+
+	return args.Get(0).(model.Preview), args.Error(1)
 }
 
-func (m *ShortenerMock) GetAllUser(_ context.Context, userID string) map[string]string {
+func (m *ShortenerMock) GetUserLinks(_ context.Context, userID string) ([]model.Link, error) {
 	args := m.Called(userID)
 
-	return args.Get(0).(map[string]string)
+	return args.Get(0).([]model.Link), args.Error(1)
 }
 
 func (m *ShortenerMock) DeleteBatch(_ context.Context, urlIDs []string, userID string) error {
@@ -47,34 +60,113 @@ func (m *ShortenerMock) DeleteBatch(_ context.Context, urlIDs []string, userID s
 	return args.Error(0)
 }
 
-func (m *ShortenerMock) GetStat(_ context.Context) (int, int, error) {
-	args := m.Called()
+func (m *ShortenerMock) RestoreBatch(_ context.Context, urlIDs []string, userID string) error {
+	args := m.Called(urlIDs, userID)
+
+	return args.Error(0)
+}
+
+func (m *ShortenerMock) GetStats(_ context.Context, days, top int) (model.Stats, error) {
+	args := m.Called(days, top)
+
+	return args.Get(0).(model.Stats), args.Error(1)
+}
+
+func (m *ShortenerMock) GetRules(_ context.Context, id, userID string) ([]model.RedirectRule, error) {
+	args := m.Called(id, userID)
+
+	return args.Get(0).([]model.RedirectRule), args.Error(1)
+}
+
+func (m *ShortenerMock) SetRules(_ context.Context, id, userID string, rules []model.RedirectRule) error {
+	args := m.Called(id, userID, rules)
+
+	return args.Error(0)
+}
+
+func (m *ShortenerMock) GetVariantStats(_ context.Context, id, userID string) (model.VariantStats, error) {
+	args := m.Called(id, userID)
+
+	return args.Get(0).(model.VariantStats), args.Error(1)
+}
+
+func (m *ShortenerMock) SetVariants(_ context.Context, id, userID string, variants model.Variants) error {
+	args := m.Called(id, userID, variants)
+
+	return args.Error(0)
+}
+
+func (m *ShortenerMock) GetRedirectOptions(_ context.Context, id, userID string) (model.RedirectOptions, error) {
+	args := m.Called(id, userID)
 
-	return args.Int(0), args.Int(1), args.Error(2)
+	return args.Get(0).(model.RedirectOptions), args.Error(1)
+}
+
+func (m *ShortenerMock) SetRedirectOptions(_ context.Context, id, userID string, opts model.RedirectOptions) error {
+	args := m.Called(id, userID, opts)
+
+	return args.Error(0)
 }
 
 type BenchmarkShortener struct {
 	UserURLs map[string]string
 }
 
-func (BenchmarkShortener) Shorten(_ context.Context, _ string, _ string) (string, bool, error) {
+func (BenchmarkShortener) Shorten(_ context.Context, _ string, _ string, _ model.LinkOptions) (string, bool, error) {
 	return "", true, nil
 }
 
-func (BenchmarkShortener) Get(_ context.Context, _ string) (string, error) {
-	return "", nil
+func (BenchmarkShortener) Get(_ context.Context, _ string, _ model.RedirectRequest) (model.Redirect, error) {
+	return model.Redirect{}, nil
+}
+
+func (BenchmarkShortener) Preview(_ context.Context, _ string, _ model.RedirectRequest) (model.Preview, error) {
+	return model.Preview{URL: "https://ya.ru/", DisplayURL: "https://ya.ru/"}, nil
 }
 
-func (s BenchmarkShortener) GetAllUser(_ context.Context, _ string) map[string]string {
-	return s.UserURLs
+func (s BenchmarkShortener) GetUserLinks(_ context.Context, userID string) ([]model.Link, error) {
+	links := make([]model.Link, 0, len(s.UserURLs))
+	for id, u := range s.UserURLs {
+		links = append(links, model.Link{ID: id, URL: u, DisplayURL: u, UserID: userID})
+	}
+
+	return links, nil
 }
 
 func (BenchmarkShortener) DeleteBatch(_ context.Context, _ []string, _ string) error {
 	return nil
 }
 
-func (BenchmarkShortener) GetStat(_ context.Context) (int, int, error) {
-	return 0, 0, nil
+func (BenchmarkShortener) RestoreBatch(_ context.Context, _ []string, _ string) error {
+	return nil
+}
+
+func (BenchmarkShortener) GetStats(_ context.Context, _, _ int) (model.Stats, error) {
+	return model.Stats{}, nil
+}
+
+func (BenchmarkShortener) GetRules(_ context.Context, _, _ string) ([]model.RedirectRule, error) {
+	return nil, nil
+}
+
+func (BenchmarkShortener) SetRules(_ context.Context, _, _ string, _ []model.RedirectRule) error {
+	return nil
+}
+
+func (BenchmarkShortener) GetVariantStats(_ context.Context, _, _ string) (model.VariantStats, error) {
+	return model.VariantStats{}, nil
+}
+
+func (BenchmarkShortener) SetVariants(_ context.Context, _, _ string, _ model.Variants) error {
+	return nil
+}
+
+func (BenchmarkShortener) GetRedirectOptions(_ context.Context, _, _ string) (model.RedirectOptions, error) {
+	return model.RedirectOptions{}, nil
+}
+
+func (BenchmarkShortener) SetRedirectOptions(_ context.Context, _, _ string, _ model.RedirectOptions) error {
+	return nil
 }
 
 type AuthenticatorMock struct {
@@ -123,7 +215,7 @@ func TestShortenURLHandler_CreateSuccess(t *testing.T) {
 	)
 
 	authenticator.On("UserIdentifier").Return(userID, nil).Once()
-	shortener.On("Shorten", url, userID).Return(urlID, true, nil).Once()
+	shortener.On("Shorten", url, userID, "").Return(urlID, true, nil).Once()
 	handler := ShortenURL{
 		shortener:     shortener,
 		baseURL:       baseURL,
@@ -150,7 +242,7 @@ func TestShortenURLHandler_CreateConflict(t *testing.T) {
 	)
 
 	authenticator.On("UserIdentifier").Return(userID, nil).Once()
-	shortener.On("Shorten", url, userID).Return("", false, nil).Once()
+	shortener.On("Shorten", url, userID, "").Return("", false, nil).Once()
 	handler := ShortenURL{
 		shortener:     shortener,
 		authenticator: authenticator,
@@ -172,7 +264,7 @@ func TestShortenURLHandler_CreateWithErrors(t *testing.T) {
 	)
 
 	authenticator.On("UserIdentifier").Return(userID, nil).Times(3)
-	shortener.On("Shorten", url, userID).Return("", false, errors.New("")).Once()
+	shortener.On("Shorten", url, userID, "").Return("", false, errors.New("")).Once()
 	handler := ShortenURL{
 		shortener:     shortener,
 		authenticator: authenticator,
@@ -210,6 +302,69 @@ func TestShortenURLHandler_CreateWithErrors(t *testing.T) {
 	shortener.AssertExpectations(t)
 }
 
+func TestShortenURLHandler_CreateBannedUser(t *testing.T) {
+	var (
+		url           = "https://ya.ru/"
+		userID        = "438c4b98-fc98-45cf-ac63-c4a86fbd4ff4"
+		shortener     = &ShortenerMock{}
+		authenticator = &AuthenticatorMock{}
+	)
+
+	authenticator.On("UserIdentifier").Return(userID, nil).Twice()
+	shortener.On("Shorten", url, userID, "").Return("", false, inerr.ErrUserIsBanned).Twice()
+	handler := ShortenURL{
+		shortener:     shortener,
+		authenticator: authenticator,
+	}
+
+	result := sendTestRequest(http.MethodPost, "/", bytes.NewBuffer([]byte(url)), handler.Create)
+	assert.Equal(t, http.StatusForbidden, result.StatusCode)
+	require.NoError(t, result.Body.Close())
+	result = sendTestRequest(http.MethodPost, "/api/shorten", bytes.NewBuffer([]byte(`{"url":"`+url+`"}`)), handler.CreateJSON)
+	assert.Equal(t, http.StatusForbidden, result.StatusCode)
+	require.NoError(t, result.Body.Close())
+	authenticator.AssertExpectations(t)
+	shortener.AssertExpectations(t)
+}
+
+func TestShortenURLHandler_CreateRejectedDestination(t *testing.T) {
+	var (
+		url           = "http://127.0.0.1/"
+		userID        = "438c4b98-fc98-45cf-ac63-c4a86fbd4ff4"
+		rejection     = validator.NewRejectionError(validator.RejectionPrivateAddress, "host 127.0.0.1 points to private address 127.0.0.1")
+		shortener     = &ShortenerMock{}
+		authenticator = &AuthenticatorMock{}
+	)
+
+	authenticator.On("UserIdentifier").Return(userID, nil).Twice()
+	shortener.On("Shorten", url, userID, "").Return("", false, rejection).Twice()
+	handler := ShortenURL{
+		shortener:     shortener,
+		authenticator: authenticator,
+	}
+
+	result := sendTestRequest(http.MethodPost, "/", bytes.NewBuffer([]byte(url)), handler.Create)
+	assert.Equal(t, http.StatusBadRequest, result.StatusCode)
+	b, err := io.ReadAll(result.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(b), validator.RejectionPrivateAddress, "причина отказа в теле ответа")
+	require.NoError(t, result.Body.Close())
+
+	result = sendTestRequest(http.MethodPost, "/api/shorten", bytes.NewBuffer([]byte(`{"url":"`+url+`"}`)), handler.CreateJSON)
+	assert.Equal(t, http.StatusBadRequest, result.StatusCode)
+	b, err = io.ReadAll(result.Body)
+	require.NoError(t, err)
+	resp := struct {
+		Reason string `json:"reason"`
+	}{}
+	require.NoError(t, json.Unmarshal(b, &resp))
+	assert.Equal(t, validator.RejectionPrivateAddress, resp.Reason, "причина отказа в теле ответа")
+	require.NoError(t, result.Body.Close())
+
+	authenticator.AssertExpectations(t)
+	shortener.AssertExpectations(t)
+}
+
 func TestShortenURLHandler_CreateJSONSuccess(t *testing.T) {
 	var (
 		urlID         = "1i-CBrzwyMkL"
@@ -221,7 +376,7 @@ func TestShortenURLHandler_CreateJSONSuccess(t *testing.T) {
 	)
 
 	authenticator.On("UserIdentifier").Return(userID, nil).Once()
-	shortener.On("Shorten", url, userID).Return(urlID, true, nil).Once()
+	shortener.On("Shorten", url, userID, "").Return(urlID, true, nil).Once()
 	handler := ShortenURL{
 		shortener:     shortener,
 		baseURL:       baseURL,
@@ -245,6 +400,98 @@ func TestShortenURLHandler_CreateJSONSuccess(t *testing.T) {
 	shortener.AssertExpectations(t)
 }
 
+func TestShortenURLHandler_CreateWithPassword(t *testing.T) {
+	var (
+		urlID         = "1i-CBrzwyMkL"
+		url           = "https://ya.ru/"
+		password      = "secret"
+		userID        = "438c4b98-fc98-45cf-ac63-c4a86fbd4ff4"
+		shortener     = &ShortenerMock{}
+		authenticator = &AuthenticatorMock{}
+	)
+
+	authenticator.On("UserIdentifier").Return(userID, nil).Times(3)
+	shortener.
+		On("Shorten", url, userID, password).Return(urlID, true, nil).Twice().
+		On("Shorten", url, userID, "long").Return("", false, inerr.ErrPasswordTooLong).Once()
+	handler := ShortenURL{
+		shortener:     shortener,
+		authenticator: authenticator,
+	}
+
+	request := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(url))
+	request.Header.Set("X-Link-Password", password)
+	w := httptest.NewRecorder()
+	handler.Create(w, request)
+	assert.Equal(t, http.StatusCreated, w.Code, "пароль в заголовке")
+
+	result := sendTestRequest(http.MethodPost, "/", strings.NewReader(`{"url":"`+url+`","password":"`+password+`"}`), handler.CreateJSON)
+	assert.Equal(t, http.StatusCreated, result.StatusCode, "пароль в JSON")
+	require.NoError(t, result.Body.Close())
+
+	result = sendTestRequest(http.MethodPost, "/", strings.NewReader(`{"url":"`+url+`","password":"long"}`), handler.CreateJSON)
+	assert.Equal(t, http.StatusBadRequest, result.StatusCode, "слишком длинный пароль")
+	require.NoError(t, result.Body.Close())
+	authenticator.AssertExpectations(t)
+	shortener.AssertExpectations(t)
+}
+
+func TestShortenURLHandler_CreateJSONExpiry(t *testing.T) {
+	var (
+		url           = "https://ya.ru/"
+		userID        = "438c4b98-fc98-45cf-ac63-c4a86fbd4ff4"
+		shortener     = &ShortenerMock{}
+		authenticator = &AuthenticatorMock{}
+	)
+
+	authenticator.On("UserIdentifier").Return(userID, nil).Twice()
+	shortener.On("Shorten", url, userID, "").Return("", false, inerr.ErrInvalidExpiry).Once()
+	handler := ShortenURL{
+		shortener:     shortener,
+		authenticator: authenticator,
+	}
+
+	result := sendTestRequest(http.MethodPost, "/", strings.NewReader(`{"url":"`+url+`","expires_at":"2020-01-01T00:00:00Z"}`), handler.CreateJSON)
+	assert.Equal(t, http.StatusBadRequest, result.StatusCode, "срок действия уже истек")
+	require.NoError(t, result.Body.Close())
+
+	result = sendTestRequest(http.MethodPost, "/", strings.NewReader(`{"url":"`+url+`","expires_at":"tomorrow"}`), handler.CreateJSON)
+	assert.Equal(t, http.StatusBadRequest, result.StatusCode, "некорректный формат срока действия")
+	require.NoError(t, result.Body.Close())
+	authenticator.AssertExpectations(t)
+	shortener.AssertExpectations(t)
+}
+
+func TestShortenURLHandler_CreateJSONHomographWarning(t *testing.T) {
+	var (
+		urlID         = "1i-CBrzwyMkL"
+		url           = "https://paypаl.com/"
+		userID        = "438c4b98-fc98-45cf-ac63-c4a86fbd4ff4"
+		shortener     = &ShortenerMock{}
+		authenticator = &AuthenticatorMock{}
+	)
+
+	authenticator.On("UserIdentifier").Return(userID, nil).Once()
+	shortener.On("Shorten", url, userID, "").Return(urlID, true, nil).Once()
+	handler := ShortenURL{
+		shortener:     shortener,
+		authenticator: authenticator,
+	}
+
+	result := sendTestRequest(http.MethodPost, "/", bytes.NewBuffer([]byte(`{"url":"`+url+`"}`)), handler.CreateJSON)
+	assert.Equal(t, http.StatusCreated, result.StatusCode)
+	b, err := io.ReadAll(result.Body)
+	require.NoError(t, err)
+	require.NoError(t, result.Body.Close())
+	resp := struct {
+		Warnings []string `json:"warnings"`
+	}{}
+	require.NoError(t, json.Unmarshal(b, &resp))
+	assert.Equal(t, []string{validator.WarningMixedScript}, resp.Warnings)
+	authenticator.AssertExpectations(t)
+	shortener.AssertExpectations(t)
+}
+
 func TestShortenURLHandler_CreateJSONConflict(t *testing.T) {
 	var (
 		url           = "https://ya.ru/"
@@ -254,7 +501,7 @@ func TestShortenURLHandler_CreateJSONConflict(t *testing.T) {
 	)
 
 	authenticator.On("UserIdentifier").Return(userID, nil).Once()
-	shortener.On("Shorten", url, userID).Return("", false, nil).Once()
+	shortener.On("Shorten", url, userID, "").Return("", false, nil).Once()
 	handler := ShortenURL{
 		shortener:     shortener,
 		authenticator: authenticator,
@@ -277,7 +524,7 @@ func TestShortenURLHandler_CreateJSONWithErrors(t *testing.T) {
 	)
 
 	authenticator.On("UserIdentifier").Return(userID, nil).Times(3)
-	shortener.On("Shorten", url, userID).Return("", false, errors.New("")).Once()
+	shortener.On("Shorten", url, userID, "").Return("", false, errors.New("")).Once()
 	handler := ShortenURL{
 		shortener:     shortener,
 		authenticator: authenticator,
@@ -333,8 +580,8 @@ func TestShortenURLHandler_CreateBatchSuccess(t *testing.T) {
 	)
 
 	authenticator.On("UserIdentifier").Return(userID, nil).Once()
-	shortener.On("Shorten", url1, userID).Return(urlID1, true, nil).Once()
-	shortener.On("Shorten", url2, userID).Return(urlID2, true, nil).Once()
+	shortener.On("Shorten", url1, userID, "").Return(urlID1, true, nil).Once()
+	shortener.On("Shorten", url2, userID, "").Return(urlID2, true, nil).Once()
 	handler := ShortenURL{
 		shortener:     shortener,
 		baseURL:       baseURL,
@@ -401,7 +648,7 @@ func TestShortenURLHandler_GetSuccess(t *testing.T) {
 		shortener = &ShortenerMock{}
 	)
 
-	shortener.On("Get", "").Return(url, nil).Once()
+	shortener.On("Get", "", model.RedirectRequest{}).Return(model.Redirect{URL: url}, nil).Once()
 	handler := ShortenURL{
 		shortener: shortener,
 	}
@@ -420,7 +667,7 @@ func TestShortenURLHandler_GetDeleted(t *testing.T) {
 		shortener = &ShortenerMock{}
 	)
 
-	shortener.On("Get", "").Return("", inerr.ErrURLIsDeleted).Once()
+	shortener.On("Get", "", model.RedirectRequest{}).Return(model.Redirect{}, inerr.ErrURLIsDeleted).Once()
 	handler := ShortenURL{
 		shortener: shortener,
 	}
@@ -432,13 +679,47 @@ func TestShortenURLHandler_GetDeleted(t *testing.T) {
 	shortener.AssertExpectations(t)
 }
 
+func TestShortenURLHandler_GetDisabled(t *testing.T) {
+	var (
+		urlID     = "1i-CBrzwyMkL"
+		shortener = &ShortenerMock{}
+	)
+
+	shortener.On("Get", "", model.RedirectRequest{}).Return(model.Redirect{}, inerr.ErrURLIsDisabled).Once()
+	handler := ShortenURL{
+		shortener: shortener,
+	}
+
+	result := sendTestRequest(http.MethodGet, "/"+urlID, nil, handler.Get)
+	assert.Equal(t, http.StatusGone, result.StatusCode)
+	require.NoError(t, result.Body.Close())
+	shortener.AssertExpectations(t)
+}
+
+func TestShortenURLHandler_GetExpired(t *testing.T) {
+	var (
+		urlID     = "1i-CBrzwyMkL"
+		shortener = &ShortenerMock{}
+	)
+
+	shortener.On("Get", "", model.RedirectRequest{}).Return(model.Redirect{}, inerr.ErrURLIsExpired).Once()
+	handler := ShortenURL{
+		shortener: shortener,
+	}
+
+	result := sendTestRequest(http.MethodGet, "/"+urlID, nil, handler.Get)
+	assert.Equal(t, http.StatusGone, result.StatusCode)
+	require.NoError(t, result.Body.Close())
+	shortener.AssertExpectations(t)
+}
+
 func TestShortenURLHandler_GetWithErrors(t *testing.T) {
 	var (
 		urlID     = "1i-CBrzwyMkL"
 		shortener = &ShortenerMock{}
 	)
 
-	shortener.On("Get", "").Return("", errors.New("")).Once()
+	shortener.On("Get", "", model.RedirectRequest{}).Return(model.Redirect{}, errors.New("")).Once()
 	handler := ShortenURL{
 		shortener: shortener,
 	}
@@ -450,11 +731,61 @@ func TestShortenURLHandler_GetWithErrors(t *testing.T) {
 	shortener.AssertExpectations(t)
 }
 
+func TestShortenURLHandler_GetPasswordProtected(t *testing.T) {
+	var (
+		url       = "https://ya.ru/"
+		urlID     = "1i-CBrzwyMkL"
+		password  = "secret"
+		shortener = &ShortenerMock{}
+	)
+
+	shortener.
+		On("Get", "", model.RedirectRequest{}).Return(model.Redirect{}, inerr.ErrPasswordRequired).Once().
+		On("Get", "", model.RedirectRequest{Password: "wrong"}).Return(model.Redirect{}, inerr.ErrInvalidPassword).Once().
+		On("Get", "", model.RedirectRequest{Password: password}).Return(model.Redirect{URL: url}, nil).Twice().
+		On("Get", "", model.RedirectRequest{Password: "other"}).Return(model.Redirect{}, &inerr.PasswordAttemptsError{RetryAfter: 1500 * time.Millisecond}).Once()
+	handler := ShortenURL{
+		shortener: shortener,
+	}
+
+	result := sendTestRequest(http.MethodGet, "/"+urlID, nil, handler.Get)
+	assert.Equal(t, http.StatusUnauthorized, result.StatusCode, "переход без пароля")
+	assert.Equal(t, "text/html; charset=utf-8", result.Header.Get("Content-Type"), "переход без пароля")
+	b, err := io.ReadAll(result.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(b), `<form method="post">`, "переход без пароля")
+	require.NoError(t, result.Body.Close())
+
+	result = sendTestRequest(http.MethodGet, "/"+urlID+"?password=wrong", nil, handler.Get)
+	assert.Equal(t, http.StatusForbidden, result.StatusCode, "неверный пароль в параметре запроса")
+	require.NoError(t, result.Body.Close())
+
+	request := httptest.NewRequest(http.MethodGet, "/"+urlID, nil)
+	request.Header.Set("X-Link-Password", password)
+	w := httptest.NewRecorder()
+	handler.Get(w, request)
+	assert.Equal(t, http.StatusTemporaryRedirect, w.Code, "пароль в заголовке")
+	assert.Equal(t, url, w.Header().Get("Location"), "пароль в заголовке")
+
+	request = httptest.NewRequest(http.MethodPost, "/"+urlID, strings.NewReader("password="+password))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = httptest.NewRecorder()
+	handler.Get(w, request)
+	assert.Equal(t, http.StatusTemporaryRedirect, w.Code, "пароль в форме")
+	assert.Equal(t, url, w.Header().Get("Location"), "пароль в форме")
+
+	result = sendTestRequest(http.MethodGet, "/"+urlID+"?password=other", nil, handler.Get)
+	assert.Equal(t, http.StatusTooManyRequests, result.StatusCode, "превышена частота попыток")
+	assert.Equal(t, "2", result.Header.Get("Retry-After"), "превышена частота попыток")
+	require.NoError(t, result.Body.Close())
+	shortener.AssertExpectations(t)
+}
+
 func TestShortenURL_GetAllByCurrentUserSuccess(t *testing.T) {
 	var (
 		urlID         = "1i-CBrzwyMkL"
 		url           = "https://ya.ru/"
-		urls          = map[string]string{urlID: url}
+		links         = []model.Link{{ID: urlID, URL: url, DisplayURL: url, Title: "Яндекс"}}
 		userID        = "438c4b98-fc98-45cf-ac63-c4a86fbd4ff4"
 		baseURL       = "http://localhost"
 		shortener     = &ShortenerMock{}
@@ -462,7 +793,7 @@ func TestShortenURL_GetAllByCurrentUserSuccess(t *testing.T) {
 	)
 
 	authenticator.On("UserIdentifier").Return(userID, nil).Once()
-	shortener.On("GetAllUser", userID).Return(urls, nil).Once()
+	shortener.On("GetUserLinks", userID).Return(links, nil).Once()
 	handler := ShortenURL{
 		shortener:     shortener,
 		baseURL:       baseURL,
@@ -477,28 +808,77 @@ func TestShortenURL_GetAllByCurrentUserSuccess(t *testing.T) {
 	resp := make([]struct {
 		ShortURL    string `json:"short_url"`
 		OriginalURL string `json:"original_url"`
+		Title       string `json:"title"`
 	}, 0)
 	err = json.Unmarshal(b, &resp)
 	require.NoError(t, err)
 	urlData := resp[0]
 	assert.Equal(t, baseURL+"/"+urlID, urlData.ShortURL)
 	assert.Equal(t, url, urlData.OriginalURL)
+	assert.Equal(t, "Яндекс", urlData.Title)
 	err = result.Body.Close()
 	require.NoError(t, err)
 	authenticator.AssertExpectations(t)
 	shortener.AssertExpectations(t)
 }
 
+func TestShortenURL_GetAllByCurrentUserHealth(t *testing.T) {
+	var (
+		userID    = "438c4b98-fc98-45cf-ac63-c4a86fbd4ff4"
+		baseURL   = "http://localhost"
+		checkedAt = time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+		links     = []model.Link{
+			{ID: "ok", DisplayURL: "https://ya.ru/", Health: model.LinkHealth{Status: http.StatusOK, CheckedAt: checkedAt}},
+			{ID: "gone", DisplayURL: "https://ya.ru/gone", Health: model.LinkHealth{Status: http.StatusNotFound, CheckedAt: checkedAt}},
+			{ID: "new", DisplayURL: "https://ya.ru/new"},
+		}
+		shortener     = &ShortenerMock{}
+		authenticator = &AuthenticatorMock{}
+	)
+
+	authenticator.On("UserIdentifier").Return(userID, nil).Times(5)
+	shortener.On("GetUserLinks", userID).Return(links, nil).Times(4)
+	handler := ShortenURL{
+		shortener:     shortener,
+		baseURL:       baseURL,
+		authenticator: authenticator,
+	}
+
+	for filter, want := range map[string]string{
+		"broken":    `[{"short_url":"http://localhost/gone","original_url":"https://ya.ru/gone","health":{"status":404,"checked_at":"2023-05-01T12:00:00Z"}}]`,
+		"healthy":   `[{"short_url":"http://localhost/ok","original_url":"https://ya.ru/","health":{"status":200,"checked_at":"2023-05-01T12:00:00Z"}}]`,
+		"unchecked": `[{"short_url":"http://localhost/new","original_url":"https://ya.ru/new"}]`,
+	} {
+		result := sendTestRequest(http.MethodGet, "/?health="+filter, nil, handler.GetAllByCurrentUser)
+		assert.Equal(t, http.StatusOK, result.StatusCode, filter)
+		b, err := io.ReadAll(result.Body)
+		require.NoError(t, err)
+		assert.JSONEq(t, want, string(b), filter)
+		require.NoError(t, result.Body.Close())
+	}
+
+	result := sendTestRequest(http.MethodGet, "/?health=", nil, handler.GetAllByCurrentUser)
+	resp := make([]json.RawMessage, 0)
+	require.NoError(t, json.NewDecoder(result.Body).Decode(&resp))
+	assert.Len(t, resp, 3, "без фильтра")
+	require.NoError(t, result.Body.Close())
+
+	result = sendTestRequest(http.MethodGet, "/?health=dead", nil, handler.GetAllByCurrentUser)
+	assert.Equal(t, http.StatusBadRequest, result.StatusCode, "некорректный фильтр")
+	require.NoError(t, result.Body.Close())
+	authenticator.AssertExpectations(t)
+	shortener.AssertExpectations(t)
+}
+
 func TestShortenURL_GetAllByCurrentUserNoContent(t *testing.T) {
 	var (
-		urls          = map[string]string{}
 		userID        = "438c4b98-fc98-45cf-ac63-c4a86fbd4ff4"
 		shortener     = &ShortenerMock{}
 		authenticator = &AuthenticatorMock{}
 	)
 
 	authenticator.On("UserIdentifier").Return(userID, nil).Once()
-	shortener.On("GetAllUser", userID).Return(urls, nil).Once()
+	shortener.On("GetUserLinks", userID).Return([]model.Link{}, nil).Once()
 	handler := ShortenURL{
 		shortener:     shortener,
 		authenticator: authenticator,
@@ -520,65 +900,128 @@ func TestShortenURLHandler_DeleteBatch(t *testing.T) {
 		authenticator = &AuthenticatorMock{}
 	)
 
+	release := make(chan struct{})
 	authenticator.On("UserIdentifier").Return(userID, nil).Once()
-	shortener.On("DeleteBatch", []string{urlID}, userID).Return(nil).Once()
+	shortener.On("DeleteBatch", []string{urlID}, userID).Return(nil).Once().Run(func(mock.Arguments) {
+		<-release
+	})
 	handler := ShortenURL{
 		shortener:     shortener,
 		authenticator: authenticator,
 		wg:            &sync.WaitGroup{},
+		pending:       &atomic.Int64{},
 	}
 
 	result := sendTestRequest(http.MethodDelete, "/", bytes.NewBuffer([]byte(`["`+urlID+`"]`)), handler.DeleteBatch)
 	assert.Equal(t, http.StatusAccepted, result.StatusCode)
 	err := result.Body.Close()
 	require.NoError(t, err)
+	assert.Equal(t, int64(1), handler.PendingDeletes(), "URL ожидает удаления")
+
+	close(release)
+	handler.wg.Wait()
+	assert.Equal(t, int64(0), handler.PendingDeletes(), "URL удален")
+
+	authenticator.AssertExpectations(t)
+	shortener.AssertExpectations(t)
+}
+
+func TestShortenURLHandler_RestoreBatch(t *testing.T) {
+	var (
+		urlID         = "1i-CBrzwyMkL"
+		userID        = "438c4b98-fc98-45cf-ac63-c4a86fbd4ff4"
+		shortener     = &ShortenerMock{}
+		authenticator = &AuthenticatorMock{}
+	)
 
-	// Останавливаем тест, чтобы успел записаться вызов DeleteBatch внутри goroutine.
-	time.Sleep(100 * time.Millisecond)
+	authenticator.On("UserIdentifier").Return(userID, nil).Times(3)
+	shortener.
+		On("RestoreBatch", []string{urlID}, userID).Return(nil).Once().
+		On("RestoreBatch", []string{urlID}, userID).Return(errors.New("")).Once()
+	handler := ShortenURL{
+		shortener:     shortener,
+		authenticator: authenticator,
+	}
+
+	result := sendTestRequest(http.MethodPost, "/", bytes.NewBuffer([]byte(`["`+urlID+`"]`)), handler.RestoreBatch)
+	assert.Equal(t, http.StatusNoContent, result.StatusCode, "восстановление URL")
+	require.NoError(t, result.Body.Close())
+	result = sendTestRequest(http.MethodPost, "/", bytes.NewBuffer([]byte(`["`+urlID+`"]`)), handler.RestoreBatch)
+	assert.Equal(t, http.StatusInternalServerError, result.StatusCode, "ошибка восстановления URL")
+	require.NoError(t, result.Body.Close())
+	result = sendTestRequest(http.MethodPost, "/", bytes.NewBuffer([]byte(`{}`)), handler.RestoreBatch)
+	assert.Equal(t, http.StatusBadRequest, result.StatusCode, "некорректный запрос")
+	require.NoError(t, result.Body.Close())
 
 	authenticator.AssertExpectations(t)
 	shortener.AssertExpectations(t)
 }
 
-func TestShortenURLHandler_GetStatSuccess(t *testing.T) {
+func TestShortenURLHandler_GetStatsSuccess(t *testing.T) {
 	var (
-		urlCount   = 2
-		usersCount = 1
-		shortener  = &ShortenerMock{}
+		stats = model.Stats{
+			URLs:          3,
+			Users:         1,
+			Active:        1,
+			Deleted:       3,
+			Expired:       1,
+			Disabled:      1,
+			CreatedPerDay: []model.DayCount{{Date: "2023-05-01", Count: 5}},
+			TopLinks:      []model.LinkClicks{{ID: "a", URL: "https://ya.ru/", Clicks: 7}},
+			TopDomains:    []model.DomainCount{{Domain: "ya.ru", Count: 2}},
+			StorageSize:   4096,
+		}
+		shortener = &ShortenerMock{}
 	)
 
-	shortener.On("GetStat").Return(urlCount, usersCount, nil).Once()
+	shortener.
+		On("GetStats", defaultStatsDays, defaultStatsTop).Return(stats, nil).Once().
+		On("GetStats", 7, 3).Return(stats, nil).Once()
 	handler := ShortenURL{
 		shortener: shortener,
 	}
 
-	result := sendTestRequest(http.MethodPost, "/", nil, handler.GetStat)
+	result := sendTestRequest(http.MethodGet, "/", nil, handler.GetStats)
 	assert.Equal(t, http.StatusOK, result.StatusCode)
 	assert.Equal(t, "application/json", result.Header.Get("Content-Type"))
 	b, err := io.ReadAll(result.Body)
 	require.NoError(t, err)
-	resp := struct {
-		URLCount   int `json:"urls"`
-		UsersCount int `json:"users"`
-	}{}
-	err = json.Unmarshal(b, &resp)
-	require.NoError(t, err)
-	assert.Equal(t, urlCount, resp.URLCount)
-	assert.Equal(t, usersCount, resp.UsersCount)
+	assert.JSONEq(t, `{
+		"urls": 3,
+		"users": 1,
+		"active": 1,
+		"deleted": 3,
+		"expired": 1,
+		"disabled": 1,
+		"created_per_day": [{"date": "2023-05-01", "count": 5}],
+		"top_links": [{"id": "a", "url": "https://ya.ru/", "clicks": 7}],
+		"top_domains": [{"domain": "ya.ru", "count": 2}],
+		"storage_size": 4096
+	}`, string(b))
+	require.NoError(t, result.Body.Close())
+
+	result = sendTestRequest(http.MethodGet, "/?days=7&top=3", nil, handler.GetStats)
+	assert.Equal(t, http.StatusOK, result.StatusCode)
 	require.NoError(t, result.Body.Close())
 	shortener.AssertExpectations(t)
 }
 
-func TestShortenURLHandler_GetStatError(t *testing.T) {
+func TestShortenURLHandler_GetStatsError(t *testing.T) {
 	shortener := &ShortenerMock{}
-	shortener.On("GetStat").Return(0, 0, errors.New("")).Once()
+	shortener.On("GetStats", defaultStatsDays, defaultStatsTop).Return(model.Stats{}, errors.New("")).Once()
 	handler := ShortenURL{
 		shortener: shortener,
 	}
 
-	result := sendTestRequest(http.MethodPost, "/", nil, handler.GetStat)
+	result := sendTestRequest(http.MethodGet, "/", nil, handler.GetStats)
 	assert.Equal(t, http.StatusInternalServerError, result.StatusCode)
 	require.NoError(t, result.Body.Close())
+
+	for _, target := range []string{"/?days=0", "/?days=366", "/?top=-1", "/?top=101", "/?days=a"} {
+		result = sendTestRequest(http.MethodGet, target, nil, handler.GetStats)
+		assert.Equal(t, http.StatusBadRequest, result.StatusCode, target)
+		require.NoError(t, result.Body.Close())
+	}
 	shortener.AssertExpectations(t)
 }
 
@@ -644,6 +1087,11 @@ func TestShortenURLHandler_isURL(t *testing.T) {
 			url:  "/path/test",
 			want: false,
 		},
+		{
+			name: "IRI",
+			url:  "https://пример.рф/путь/скидка-100%",
+			want: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -728,10 +1176,358 @@ func BenchmarkShortenURLHandler_DeleteBatch(b *testing.B) {
 	}
 }
 
-func BenchmarkShortenURLHandler_GetStat(b *testing.B) {
+func BenchmarkShortenURLHandler_GetStats(b *testing.B) {
 	handler := CreateBenchmarkShortenURLHandler(b)
 
 	for i := 0; i < b.N; i++ {
-		sendBenchmarkRequest(http.MethodGet, "/", nil, handler.GetStat)
+		sendBenchmarkRequest(http.MethodGet, "/", nil, handler.GetStats)
+	}
+}
+
+func TestShortenURLHandler_GetWithRedirectRules(t *testing.T) {
+	var (
+		url       = "https://apps.apple.com/app/id1"
+		userAgent = "Mozilla/5.0 (iPhone; CPU iPhone OS 16_5 like Mac OS X)"
+		shortener = &ShortenerMock{}
+	)
+
+	shortener.On("Get", "", model.RedirectRequest{UserAgent: userAgent, AcceptLanguage: "de-DE"}).Return(model.Redirect{URL: url}, nil).Once()
+	handler := ShortenURL{
+		shortener: shortener,
+	}
+
+	request := httptest.NewRequest(http.MethodGet, "/1i-CBrzwyMkL", nil)
+	request.Header.Set("User-Agent", userAgent)
+	request.Header.Set("Accept-Language", "de-DE")
+	w := httptest.NewRecorder()
+	handler.Get(w, request)
+	assert.Equal(t, http.StatusTemporaryRedirect, w.Code)
+	assert.Equal(t, url, w.Header().Get("Location"))
+	shortener.AssertExpectations(t)
+}
+
+func TestShortenURLHandler_Rules(t *testing.T) {
+	var (
+		urlID         = "1i-CBrzwyMkL"
+		userID        = "438c4b98-fc98-45cf-ac63-c4a86fbd4ff4"
+		rules         = []model.RedirectRule{{Platforms: []string{"ios"}, URL: "https://apps.apple.com/app/id1"}}
+		body          = `[{"platforms":["ios"],"url":"https://apps.apple.com/app/id1"}]`
+		params        = map[string]string{"id": urlID}
+		shortener     = &ShortenerMock{}
+		authenticator = &AuthenticatorMock{}
+	)
+
+	authenticator.On("UserIdentifier").Return(userID, nil).Times(8)
+	shortener.
+		On("SetRules", urlID, userID, rules).Return(nil).Once().
+		On("SetRules", urlID, userID, rules).Return(inerr.ErrURLNotFound).Once().
+		On("SetRules", urlID, userID, rules).Return(fmt.Errorf("%w: rule 0: unknown platform", inerr.ErrInvalidRedirectRule)).Once().
+		On("SetRules", urlID, userID, rules).Return(validator.NewRejectionError(validator.RejectionPrivateAddress, "")).Once().
+		On("SetRules", urlID, userID, rules).Return(errors.New("")).Once().
+		On("GetRules", urlID, userID).Return(rules, nil).Once().
+		On("GetRules", urlID, userID).Return([]model.RedirectRule(nil), nil).Once().
+		On("GetRules", urlID, userID).Return([]model.RedirectRule(nil), inerr.ErrURLNotFound).Once()
+	handler := ShortenURL{
+		shortener:     shortener,
+		authenticator: authenticator,
+	}
+
+	for _, tt := range []struct {
+		name   string
+		status int
+	}{
+		{name: "сохранение правил", status: http.StatusNoContent},
+		{name: "правила чужого URL", status: http.StatusNotFound},
+		{name: "некорректное правило", status: http.StatusBadRequest},
+		{name: "запрещенный адрес назначения", status: http.StatusBadRequest},
+		{name: "ошибка сохранения", status: http.StatusInternalServerError},
+	} {
+		result := sendTestRequestWithParams(http.MethodPut, "/", strings.NewReader(body), params, handler.SetRules)
+		assert.Equal(t, tt.status, result.StatusCode, tt.name)
+		require.NoError(t, result.Body.Close())
+	}
+
+	result := sendTestRequestWithParams(http.MethodGet, "/", nil, params, handler.GetRules)
+	assert.Equal(t, http.StatusOK, result.StatusCode, "получение правил")
+	b, err := io.ReadAll(result.Body)
+	require.NoError(t, err)
+	assert.JSONEq(t, body, string(b), "получение правил")
+	require.NoError(t, result.Body.Close())
+
+	result = sendTestRequestWithParams(http.MethodGet, "/", nil, params, handler.GetRules)
+	b, err = io.ReadAll(result.Body)
+	require.NoError(t, err)
+	assert.JSONEq(t, "[]", string(b), "URL без правил")
+	require.NoError(t, result.Body.Close())
+
+	result = sendTestRequestWithParams(http.MethodGet, "/", nil, params, handler.GetRules)
+	assert.Equal(t, http.StatusNotFound, result.StatusCode, "получение правил чужого URL")
+	require.NoError(t, result.Body.Close())
+	authenticator.AssertExpectations(t)
+	shortener.AssertExpectations(t)
+}
+
+func TestShortenURLHandler_GetWithVariants(t *testing.T) {
+	var (
+		urlID     = "1i-CBrzwyMkL"
+		url       = "https://example.com/b"
+		params    = map[string]string{"id": urlID}
+		shortener = &ShortenerMock{}
+	)
+
+	shortener.
+		On("Get", urlID, model.RedirectRequest{}).Return(model.Redirect{URL: url, Variant: "b"}, nil).Once().
+		On("Get", urlID, model.RedirectRequest{}).Return(model.Redirect{URL: url, Variant: "b", Sticky: true}, nil).Once().
+		On("Get", urlID, model.RedirectRequest{Variant: "b"}).Return(model.Redirect{URL: url, Variant: "b", Sticky: true}, nil).Once()
+	handler := ShortenURL{
+		shortener: shortener,
+	}
+
+	result := sendTestRequestWithParams(http.MethodGet, "/"+urlID, nil, params, handler.Get)
+	assert.Equal(t, http.StatusTemporaryRedirect, result.StatusCode)
+	assert.Equal(t, url, result.Header.Get("Location"))
+	assert.Empty(t, result.Cookies(), "вариант не закрепляется")
+	require.NoError(t, result.Body.Close())
+
+	result = sendTestRequestWithParams(http.MethodGet, "/"+urlID, nil, params, handler.Get)
+	assert.Equal(t, url, result.Header.Get("Location"))
+	cookies := result.Cookies()
+	require.Len(t, cookies, 1, "вариант закрепляется")
+	assert.Equal(t, "variant_"+urlID, cookies[0].Name)
+	assert.Equal(t, "b", cookies[0].Value)
+	assert.Equal(t, "/"+urlID, cookies[0].Path)
+	assert.True(t, cookies[0].HttpOnly)
+	require.NoError(t, result.Body.Close())
+
+	request := httptest.NewRequest(http.MethodGet, "/"+urlID, nil)
+	request.AddCookie(cookies[0])
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", urlID)
+	request = request.WithContext(context.WithValue(request.Context(), chi.RouteCtxKey, rctx))
+	w := httptest.NewRecorder()
+	handler.Get(w, request)
+	assert.Equal(t, http.StatusTemporaryRedirect, w.Code, "закрепленный вариант передается в сервис")
+	shortener.AssertExpectations(t)
+}
+
+func TestShortenURLHandler_Variants(t *testing.T) {
+	var (
+		urlID         = "1i-CBrzwyMkL"
+		userID        = "438c4b98-fc98-45cf-ac63-c4a86fbd4ff4"
+		variants      = model.Variants{Sticky: true, Items: []model.Variant{{Name: "a", URL: "https://example.com/a", Weight: 70}}}
+		body          = `{"sticky":true,"variants":[{"name":"a","url":"https://example.com/a","weight":70}]}`
+		params        = map[string]string{"id": urlID}
+		shortener     = &ShortenerMock{}
+		authenticator = &AuthenticatorMock{}
+		stats         = model.VariantStats{
+			Sticky: true,
+			Items:  []model.VariantStat{{Variant: variants.Items[0], Clicks: 42}},
+		}
+	)
+
+	authenticator.On("UserIdentifier").Return(userID, nil).Times(8)
+	shortener.
+		On("SetVariants", urlID, userID, variants).Return(nil).Once().
+		On("SetVariants", urlID, userID, variants).Return(inerr.ErrURLNotFound).Once().
+		On("SetVariants", urlID, userID, variants).Return(fmt.Errorf("%w: variant 0: url is required", inerr.ErrInvalidVariant)).Once().
+		On("SetVariants", urlID, userID, variants).Return(validator.NewRejectionError(validator.RejectionPrivateAddress, "")).Once().
+		On("SetVariants", urlID, userID, variants).Return(errors.New("")).Once().
+		On("GetVariantStats", urlID, userID).Return(stats, nil).Once().
+		On("GetVariantStats", urlID, userID).Return(model.VariantStats{}, nil).Once().
+		On("GetVariantStats", urlID, userID).Return(model.VariantStats{}, inerr.ErrURLNotFound).Once()
+	handler := ShortenURL{
+		shortener:     shortener,
+		authenticator: authenticator,
+	}
+
+	for _, tt := range []struct {
+		name   string
+		status int
+	}{
+		{name: "сохранение вариантов", status: http.StatusNoContent},
+		{name: "варианты чужого URL", status: http.StatusNotFound},
+		{name: "некорректные варианты", status: http.StatusBadRequest},
+		{name: "запрещенный адрес назначения", status: http.StatusBadRequest},
+		{name: "ошибка сохранения", status: http.StatusInternalServerError},
+	} {
+		result := sendTestRequestWithParams(http.MethodPut, "/", strings.NewReader(body), params, handler.SetVariants)
+		assert.Equal(t, tt.status, result.StatusCode, tt.name)
+		require.NoError(t, result.Body.Close())
+	}
+
+	result := sendTestRequestWithParams(http.MethodGet, "/", nil, params, handler.GetVariants)
+	assert.Equal(t, http.StatusOK, result.StatusCode, "получение вариантов")
+	b, err := io.ReadAll(result.Body)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"sticky":true,"variants":[{"name":"a","url":"https://example.com/a","weight":70,"clicks":42}]}`, string(b), "получение вариантов")
+	require.NoError(t, result.Body.Close())
+
+	result = sendTestRequestWithParams(http.MethodGet, "/", nil, params, handler.GetVariants)
+	b, err = io.ReadAll(result.Body)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"sticky":false,"variants":[]}`, string(b), "URL без вариантов")
+	require.NoError(t, result.Body.Close())
+
+	result = sendTestRequestWithParams(http.MethodGet, "/", nil, params, handler.GetVariants)
+	assert.Equal(t, http.StatusNotFound, result.StatusCode, "получение вариантов чужого URL")
+	require.NoError(t, result.Body.Close())
+	authenticator.AssertExpectations(t)
+	shortener.AssertExpectations(t)
+}
+
+func TestShortenURLHandler_GetWithPassthrough(t *testing.T) {
+	var (
+		urlID     = "1i-CBrzwyMkL"
+		url       = "https://example.com/docs/read%20me?utm_source=newsletter"
+		shortener = &ShortenerMock{}
+	)
+
+	shortener.
+		On("Get", "", model.RedirectRequest{
+			Path:  "docs/read me",
+			Query: map[string][]string{"utm_source": {"newsletter"}},
+		}).Return(model.Redirect{URL: url}, nil).Once().
+		On("Get", "", model.RedirectRequest{Path: "a/../b"}).Return(model.Redirect{}, inerr.ErrInvalidPathSuffix).Once().
+		On("Get", "", model.RedirectRequest{Path: "extra"}).Return(model.Redirect{}, inerr.ErrPathSuffixNotAllowed).Once()
+	handler := ShortenURL{
+		shortener: shortener,
 	}
+
+	result := sendTestRequest(http.MethodGet, "/"+urlID+"/docs/read%20me?utm_source=newsletter&password=", nil, handler.Get)
+	assert.Equal(t, http.StatusTemporaryRedirect, result.StatusCode, "перенос суффикса и параметров")
+	assert.Equal(t, url, result.Header.Get("Location"), "перенос суффикса и параметров")
+	require.NoError(t, result.Body.Close())
+
+	result = sendTestRequest(http.MethodGet, "/"+urlID+"/a/%2E%2E/b", nil, handler.Get)
+	assert.Equal(t, http.StatusBadRequest, result.StatusCode, "некорректный суффикс")
+	require.NoError(t, result.Body.Close())
+
+	result = sendTestRequest(http.MethodGet, "/"+urlID+"/extra", nil, handler.Get)
+	assert.Equal(t, http.StatusNotFound, result.StatusCode, "перенос пути не включен")
+	require.NoError(t, result.Body.Close())
+	shortener.AssertExpectations(t)
+}
+
+func TestShortenURLHandler_RedirectOptions(t *testing.T) {
+	var (
+		urlID         = "1i-CBrzwyMkL"
+		userID        = "438c4b98-fc98-45cf-ac63-c4a86fbd4ff4"
+		opts          = model.RedirectOptions{Query: model.QueryPassthroughMerge, Path: true}
+		body          = `{"query":"merge","path":true}`
+		params        = map[string]string{"id": urlID}
+		shortener     = &ShortenerMock{}
+		authenticator = &AuthenticatorMock{}
+	)
+
+	authenticator.On("UserIdentifier").Return(userID, nil).Times(6)
+	shortener.
+		On("SetRedirectOptions", urlID, userID, opts).Return(nil).Once().
+		On("SetRedirectOptions", urlID, userID, opts).Return(inerr.ErrURLNotFound).Once().
+		On("SetRedirectOptions", urlID, userID, opts).Return(fmt.Errorf("%w: unknown mode", inerr.ErrInvalidRedirectOptions)).Once().
+		On("SetRedirectOptions", urlID, userID, opts).Return(errors.New("")).Once().
+		On("GetRedirectOptions", urlID, userID).Return(opts, nil).Once().
+		On("GetRedirectOptions", urlID, userID).Return(model.RedirectOptions{}, inerr.ErrURLNotFound).Once()
+	handler := ShortenURL{
+		shortener:     shortener,
+		authenticator: authenticator,
+	}
+
+	for _, tt := range []struct {
+		name   string
+		status int
+	}{
+		{name: "сохранение параметров", status: http.StatusNoContent},
+		{name: "параметры чужого URL", status: http.StatusNotFound},
+		{name: "некорректные параметры", status: http.StatusBadRequest},
+		{name: "ошибка сохранения", status: http.StatusInternalServerError},
+	} {
+		result := sendTestRequestWithParams(http.MethodPut, "/", strings.NewReader(body), params, handler.SetRedirectOptions)
+		assert.Equal(t, tt.status, result.StatusCode, tt.name)
+		require.NoError(t, result.Body.Close())
+	}
+
+	result := sendTestRequestWithParams(http.MethodGet, "/", nil, params, handler.GetRedirectOptions)
+	assert.Equal(t, http.StatusOK, result.StatusCode, "получение параметров")
+	b, err := io.ReadAll(result.Body)
+	require.NoError(t, err)
+	assert.JSONEq(t, body, string(b), "получение параметров")
+	require.NoError(t, result.Body.Close())
+
+	result = sendTestRequestWithParams(http.MethodGet, "/", nil, params, handler.GetRedirectOptions)
+	assert.Equal(t, http.StatusNotFound, result.StatusCode, "получение параметров чужого URL")
+	require.NoError(t, result.Body.Close())
+	authenticator.AssertExpectations(t)
+	shortener.AssertExpectations(t)
+}
+
+func TestShortenURLHandler_GetRedirectStatus(t *testing.T) {
+	var (
+		url       = "https://ya.ru/"
+		urlID     = "1i-CBrzwyMkL"
+		shortener = &ShortenerMock{}
+	)
+
+	shortener.
+		On("Get", "", model.RedirectRequest{}).Return(model.Redirect{URL: url}, nil).Once().
+		On("Get", "", model.RedirectRequest{}).Return(model.Redirect{URL: url, Status: http.StatusPermanentRedirect}, nil).Once()
+	handler := NewShortenURL(&AuthenticatorMock{}, shortener, "http://localhost", http.StatusMovedPermanently, &sync.WaitGroup{})
+
+	result := sendTestRequest(http.MethodGet, "/"+urlID, nil, handler.Get)
+	assert.Equal(t, http.StatusMovedPermanently, result.StatusCode, "код ответа по умолчанию")
+	assert.Equal(t, url, result.Header.Get("Location"))
+	require.NoError(t, result.Body.Close())
+
+	result = sendTestRequest(http.MethodGet, "/"+urlID, nil, handler.Get)
+	assert.Equal(t, http.StatusPermanentRedirect, result.StatusCode, "код ответа URL")
+	require.NoError(t, result.Body.Close())
+	shortener.AssertExpectations(t)
+}
+
+func TestShortenURLHandler_Preview(t *testing.T) {
+	var (
+		urlID     = "1i-CBrzwyMkL"
+		shortener = &ShortenerMock{}
+		preview   = model.Preview{
+			URL:        "https://xn--e1afmkfd.xn--p1ai/?q=%3Cb%3E",
+			DisplayURL: "https://пример.рф/?q=<b>",
+			Title:      "Пример & <тест>",
+			CreatedAt:  time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC),
+		}
+	)
+
+	shortener.
+		On("Preview", urlID, model.RedirectRequest{}).Return(preview, nil).Twice().
+		On("Preview", urlID, model.RedirectRequest{Path: "docs"}).Return(model.Preview{}, inerr.ErrPathSuffixNotAllowed).Once().
+		On("Preview", urlID, model.RedirectRequest{}).Return(model.Preview{}, inerr.ErrPasswordRequired).Once()
+	handler := ShortenURL{
+		shortener: shortener,
+	}
+	r := chi.NewRouter()
+	r.Get("/{id:[A-Za-z0-9_-]+}", handler.Get)
+	r.Get("/{id:[A-Za-z0-9_-]+}+", handler.Preview)
+	r.Get("/{id:[A-Za-z0-9_-]+}/*", handler.Get)
+
+	for _, target := range []string{"/" + urlID + "+", "/" + urlID + "?preview=1"} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		assert.Equal(t, http.StatusOK, w.Code, target)
+		assert.Empty(t, w.Header().Get("Location"), target)
+		assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"), target)
+		assert.Equal(t, "no-store", w.Header().Get("Cache-Control"), target)
+		assert.Equal(t, "noindex, nofollow", w.Header().Get("X-Robots-Tag"), target)
+		body := w.Body.String()
+		assert.Contains(t, body, `<a href="https://xn--e1afmkfd.xn--p1ai/?q=%3Cb%3E" rel="noopener noreferrer nofollow">https://пример.рф/?q=&lt;b&gt;</a>`, target)
+		assert.Contains(t, body, "<h1>Пример &amp; &lt;тест&gt;</h1>", target)
+		assert.Contains(t, body, `<time datetime="2023-05-01T12:00:00Z">May 1, 2023</time>`, target)
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/"+urlID+"/docs?preview=1", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code, "перенос пути не включен")
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/"+urlID+"+", nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code, "предпросмотр URL с паролем")
+	assert.Contains(t, w.Body.String(), `<form method="post">`, "предпросмотр URL с паролем")
+	shortener.AssertExpectations(t)
 }
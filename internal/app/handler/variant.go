@@ -0,0 +1,36 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+)
+
+// variantCookiePrefix префикс имени cookie, в которой сохраняется вариант адреса
+// назначения, закрепленный за клиентом. Имя cookie включает ID сокращенного URL.
+const variantCookiePrefix = "variant_"
+
+// variantCookieMaxAge время, в течение которого за клиентом закреплен вариант адреса назначения.
+const variantCookieMaxAge = 90 * 24 * time.Hour
+
+// variantFromRequest возвращает имя варианта адреса назначения URL id, закрепленного за клиентом.
+func variantFromRequest(r *http.Request, id string) string {
+	c, err := r.Cookie(variantCookiePrefix + id)
+	if err != nil {
+		return ""
+	}
+
+	return c.Value
+}
+
+// setVariantCookie закрепляет за клиентом вариант адреса назначения variant URL id.
+func setVariantCookie(w http.ResponseWriter, r *http.Request, id, variant string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     variantCookiePrefix + id,
+		Value:    variant,
+		Path:     "/" + id,
+		MaxAge:   int(variantCookieMaxAge.Seconds()),
+		Secure:   r.TLS != nil,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
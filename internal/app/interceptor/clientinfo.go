@@ -0,0 +1,51 @@
+package interceptor
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+
+	"github.com/ivanpodgorny/urlshortener/internal/app/clientinfo"
+)
+
+// IPResolver интерфейс для определения IP-адреса клиента.
+type IPResolver interface {
+	Resolve(remoteAddr string, forwardedFor []string, realIP string) string
+}
+
+// ClientInfo возвращает interceptor, сохраняющий в контекст запроса
+// транспорт и IP-адрес клиента. Адрес прокси-сервера берется из метаданных
+// x-forwarded-for и x-real-ip. Для соединений сети clientinfo.InProcessNetwork
+// (шлюз REST API) IP-адрес клиента берется из метаданных x-real-ip, а транспортом
+// считается HTTP.
+func ClientInfo(resolver IPResolver) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		var (
+			remoteAddr, realIP string
+			forwardedFor       []string
+			inProcess          bool
+		)
+		if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+			remoteAddr = p.Addr.String()
+			inProcess = p.Addr.Network() == clientinfo.InProcessNetwork
+		}
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			forwardedFor = md.Get("x-forwarded-for")
+			if val := md.Get("x-real-ip"); len(val) != 0 {
+				realIP = val[0]
+			}
+		}
+
+		info := clientinfo.Info{
+			Transport: clientinfo.TransportGRPC,
+			IP:        resolver.Resolve(remoteAddr, forwardedFor, realIP),
+		}
+		if inProcess {
+			info = clientinfo.Info{Transport: clientinfo.TransportHTTP, IP: realIP}
+		}
+
+		return handler(clientinfo.WithInfo(ctx, info), req)
+	}
+}
@@ -0,0 +1,45 @@
+package interceptor
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+
+	"github.com/ivanpodgorny/urlshortener/internal/app/clientinfo"
+)
+
+func TestClientInfo(t *testing.T) {
+	var (
+		info clientinfo.Info
+		ctx  = peer.NewContext(context.Background(), &peer.Peer{
+			Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 5000},
+		})
+		handler = func(ctx context.Context, _ interface{}) (interface{}, error) {
+			info = clientinfo.FromContext(ctx)
+
+			return nil, nil
+		}
+	)
+	ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("x-real-ip", "203.0.113.1"))
+	proxies, err := clientinfo.ParseSubnets([]string{"10.0.0.0/8"})
+	require.NoError(t, err)
+
+	_, err = ClientInfo(clientinfo.NewResolver(nil))(ctx, nil, &grpc.UnaryServerInfo{}, handler)
+	require.NoError(t, err)
+	assert.Equal(t, clientinfo.Info{Transport: clientinfo.TransportGRPC, IP: "10.0.0.1"}, info, "прокси не доверенный")
+
+	_, err = ClientInfo(clientinfo.NewResolver(proxies))(ctx, nil, &grpc.UnaryServerInfo{}, handler)
+	require.NoError(t, err)
+	assert.Equal(t, clientinfo.Info{Transport: clientinfo.TransportGRPC, IP: "203.0.113.1"}, info, "прокси доверенный")
+
+	ctx = peer.NewContext(ctx, &peer.Peer{Addr: &net.UnixAddr{Name: "gateway", Net: clientinfo.InProcessNetwork}})
+	_, err = ClientInfo(clientinfo.NewResolver(nil))(ctx, nil, &grpc.UnaryServerInfo{}, handler)
+	require.NoError(t, err)
+	assert.Equal(t, clientinfo.Info{Transport: clientinfo.TransportHTTP, IP: "203.0.113.1"}, info, "шлюз REST API")
+}
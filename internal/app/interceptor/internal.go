@@ -0,0 +1,36 @@
+package interceptor
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/ivanpodgorny/urlshortener/internal/app/clientinfo"
+)
+
+// TrustedNetwork интерфейс для проверки вхождения IP-адреса в доверенные подсети.
+type TrustedNetwork interface {
+	Contains(ip string) bool
+}
+
+// Internal возвращает interceptor, проверяющий, что IP-адрес клиента входит
+// в доверенные подсети, для внутренних методов methods, заданных полными именами
+// вида /package.Service/Method. Остальные методы не проверяются.
+// IP-адрес клиента берется из контекста, поэтому interceptor должен применяться
+// после ClientInfo.
+func Internal(trusted TrustedNetwork, methods ...string) grpc.UnaryServerInterceptor {
+	internal := make(map[string]struct{}, len(methods))
+	for _, m := range methods {
+		internal[m] = struct{}{}
+	}
+
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if _, ok := internal[info.FullMethod]; ok && !trusted.Contains(clientinfo.FromContext(ctx).IP) {
+			return nil, status.Error(codes.PermissionDenied, "access is allowed only from trusted subnets")
+		}
+
+		return handler(ctx, req)
+	}
+}
@@ -0,0 +1,71 @@
+package interceptor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/ivanpodgorny/urlshortener/internal/app/clientinfo"
+)
+
+func TestInternal(t *testing.T) {
+	var (
+		internalMethod = "/shortener.Shortener/GetStats"
+		publicMethod   = "/shortener.Shortener/GetURL"
+		handler        = func(context.Context, interface{}) (interface{}, error) {
+			return "ok", nil
+		}
+	)
+	subnets, err := clientinfo.ParseSubnets([]string{"192.168.0.0/24", "2001:db8::/32"})
+	require.NoError(t, err)
+	i := Internal(subnets, internalMethod)
+
+	tests := []struct {
+		name     string
+		method   string
+		ip       string
+		wantCode codes.Code
+	}{
+		{
+			name:     "IPv4-адрес входит в подсеть",
+			method:   internalMethod,
+			ip:       "192.168.0.1",
+			wantCode: codes.OK,
+		},
+		{
+			name:     "IPv6-адрес входит в подсеть",
+			method:   internalMethod,
+			ip:       "2001:db8::1",
+			wantCode: codes.OK,
+		},
+		{
+			name:     "адрес не входит в подсеть",
+			method:   internalMethod,
+			ip:       "10.0.0.1",
+			wantCode: codes.PermissionDenied,
+		},
+		{
+			name:     "адрес клиента не определен",
+			method:   internalMethod,
+			wantCode: codes.PermissionDenied,
+		},
+		{
+			name:     "публичный метод",
+			method:   publicMethod,
+			ip:       "10.0.0.1",
+			wantCode: codes.OK,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := clientinfo.WithInfo(context.Background(), clientinfo.Info{Transport: clientinfo.TransportGRPC, IP: tt.ip})
+			_, err := i(ctx, nil, &grpc.UnaryServerInfo{FullMethod: tt.method}, handler)
+			assert.Equal(t, tt.wantCode, status.Code(err))
+		})
+	}
+}
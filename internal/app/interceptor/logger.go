@@ -0,0 +1,59 @@
+package interceptor
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/ivanpodgorny/urlshortener/internal/app/clientinfo"
+	"github.com/ivanpodgorny/urlshortener/internal/app/logger"
+)
+
+// RequestID возвращает interceptor, сохраняющий в контекст запроса идентификатор
+// запроса и журнал l, добавляющий его к каждой записи. Идентификатор берется
+// из метаданных x-request-id, если он корректен, иначе генерируется новый.
+// Идентификатор возвращается клиенту в заголовке x-request-id ответа.
+func RequestID(l *slog.Logger) grpc.UnaryServerInterceptor {
+	key := strings.ToLower(logger.RequestIDHeader)
+
+	return func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		var id string
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if val := md.Get(key); len(val) != 0 {
+				id = val[0]
+			}
+		}
+		if !logger.IsValidRequestID(id) {
+			id = logger.NewRequestID()
+		}
+
+		_ = grpc.SetHeader(ctx, metadata.Pairs(key, id))
+
+		return handler(logger.WithRequestID(logger.WithLogger(ctx, l), id), req)
+	}
+}
+
+// AccessLog возвращает interceptor, записывающий в журнал из контекста запроса
+// полное имя метода, код ответа, длительность обработки и IP-адрес клиента.
+// Должен применяться после RequestID и ClientInfo.
+func AccessLog() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		logger.FromContext(ctx).InfoCtx(
+			ctx,
+			"gRPC request",
+			slog.String("method", info.FullMethod),
+			slog.String("code", status.Code(err).String()),
+			slog.Duration("duration", time.Since(start)),
+			slog.String("ip", clientinfo.FromContext(ctx).IP),
+		)
+
+		return resp, err
+	}
+}
@@ -0,0 +1,44 @@
+package interceptor
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/ivanpodgorny/urlshortener/internal/app/logger"
+)
+
+func TestRequestID(t *testing.T) {
+	var (
+		b       = &bytes.Buffer{}
+		id      string
+		info    = &grpc.UnaryServerInfo{FullMethod: "/shortener.Shortener/GetURL"}
+		handler = func(ctx context.Context, _ interface{}) (interface{}, error) {
+			id = logger.RequestID(ctx)
+
+			return nil, status.Error(codes.NotFound, "not found")
+		}
+		l, err = logger.New(b, "info", logger.FormatText)
+	)
+	require.NoError(t, err)
+	chain := func(ctx context.Context) {
+		_, _ = RequestID(l)(ctx, nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return AccessLog()(ctx, req, info, handler)
+		})
+	}
+
+	chain(metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-request-id", "client-id")))
+	assert.Equal(t, "client-id", id, "идентификатор клиента")
+	assert.Contains(t, b.String(), "msg=\"gRPC request\" request_id=client-id method=/shortener.Shortener/GetURL code=NotFound")
+
+	chain(context.Background())
+	assert.NotEqual(t, "client-id", id)
+	assert.True(t, logger.IsValidRequestID(id), "идентификатор сгенерирован")
+}
@@ -0,0 +1,27 @@
+package interceptor
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// GRPCObserver интерфейс учета обработанных gRPC-запросов.
+type GRPCObserver interface {
+	ObserveGRPCRequest(method, code string, d time.Duration)
+}
+
+// Metrics возвращает interceptor, учитывающий полное имя метода, код ответа
+// и длительность обработки каждого запроса. Чтобы учитывались отказы других
+// interceptor, должен применяться первым.
+func Metrics(o GRPCObserver) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		o.ObserveGRPCRequest(info.FullMethod, status.Code(err).String(), time.Since(start))
+
+		return resp, err
+	}
+}
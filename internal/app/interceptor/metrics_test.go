@@ -0,0 +1,45 @@
+package interceptor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type GRPCObserverMock struct {
+	mock.Mock
+}
+
+func (m *GRPCObserverMock) ObserveGRPCRequest(method, code string, _ time.Duration) {
+	m.Called(method, code)
+}
+
+func TestMetrics(t *testing.T) {
+	var (
+		method   = "/shortener.Shortener/GetURL"
+		observer = &GRPCObserverMock{}
+		i        = Metrics(observer)
+		info     = &grpc.UnaryServerInfo{FullMethod: method}
+	)
+	observer.
+		On("ObserveGRPCRequest", method, "OK").Once().
+		On("ObserveGRPCRequest", method, "NotFound").Once()
+
+	resp, err := i(context.Background(), nil, info, func(context.Context, interface{}) (interface{}, error) {
+		return "ok", nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "ok", resp)
+
+	_, err = i(context.Background(), nil, info, func(context.Context, interface{}) (interface{}, error) {
+		return nil, status.Error(codes.NotFound, "")
+	})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	observer.AssertExpectations(t)
+}
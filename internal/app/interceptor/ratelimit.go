@@ -0,0 +1,60 @@
+package interceptor
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/ivanpodgorny/urlshortener/internal/app/clientinfo"
+	"github.com/ivanpodgorny/urlshortener/internal/app/logger"
+)
+
+// RateLimiter интерфейс сервиса ограничения частоты запросов.
+type RateLimiter interface {
+	Allow(ctx context.Context, budget, userID, ip string) (bool, time.Duration, error)
+}
+
+// IdentityProvider интерфейс для получения ID пользователя, выполнившего запрос.
+type IdentityProvider interface {
+	UserIdentifier(ctx context.Context) (string, error)
+	IsNewIdentifier(ctx context.Context) bool
+}
+
+// RateLimit возвращает interceptor, ограничивающий частоту запросов по ID
+// пользователя и IP-адресу клиента; для ID, созданного при обработке запроса,
+// проверяется только ограничение по IP-адресу. budgets сопоставляет полным именам методов
+// вида /package.Service/Method группы запросов, остальные методы не ограничиваются.
+// При превышении ограничения возвращает ошибку с кодом ResourceExhausted
+// и метаданными retry-after. Должен применяться после Authenticate и ClientInfo.
+func RateLimit(l RateLimiter, p IdentityProvider, budgets map[string]string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		budget, ok := budgets[info.FullMethod]
+		if !ok {
+			return handler(ctx, req)
+		}
+
+		userID, _ := p.UserIdentifier(ctx)
+		if p.IsNewIdentifier(ctx) {
+			userID = ""
+		}
+		allowed, retryAfter, err := l.Allow(ctx, budget, userID, clientinfo.FromContext(ctx).IP)
+		if err != nil {
+			logger.FromContext(ctx).ErrorCtx(ctx, "Error while checking rate limit", "error", err)
+		}
+
+		if !allowed {
+			seconds := strconv.Itoa(int(math.Ceil(retryAfter.Seconds())))
+			_ = grpc.SetTrailer(ctx, metadata.Pairs("retry-after", seconds))
+
+			return nil, status.Errorf(codes.ResourceExhausted, "rate limit exceeded, retry after %s seconds", seconds)
+		}
+
+		return handler(ctx, req)
+	}
+}
@@ -0,0 +1,77 @@
+package interceptor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/ivanpodgorny/urlshortener/internal/app/clientinfo"
+)
+
+type RateLimiterMock struct {
+	mock.Mock
+}
+
+func (m *RateLimiterMock) Allow(_ context.Context, budget, userID, ip string) (bool, time.Duration, error) {
+	args := m.Called(budget, userID, ip)
+
+	return args.Bool(0), args.Get(1).(time.Duration), args.Error(2)
+}
+
+type IdentityProviderMock struct {
+	mock.Mock
+}
+
+func (m *IdentityProviderMock) UserIdentifier(_ context.Context) (string, error) {
+	args := m.Called()
+
+	return args.String(0), args.Error(1)
+}
+
+func (m *IdentityProviderMock) IsNewIdentifier(_ context.Context) bool {
+	return m.Called().Bool(0)
+}
+
+func TestRateLimit(t *testing.T) {
+	var (
+		method   = "/shortener.Shortener/CreateLink"
+		budget   = "create"
+		userID   = "userID"
+		ip       = "127.0.0.1"
+		limiter  = &RateLimiterMock{}
+		provider = &IdentityProviderMock{}
+		ctx      = clientinfo.WithInfo(context.Background(), clientinfo.Info{Transport: clientinfo.TransportGRPC, IP: ip})
+		handler  = func(context.Context, interface{}) (interface{}, error) {
+			return "ok", nil
+		}
+		i = RateLimit(limiter, provider, map[string]string{method: budget})
+	)
+	limiter.
+		On("Allow", budget, userID, ip).Return(true, time.Duration(0), nil).Once().
+		On("Allow", budget, userID, ip).Return(false, time.Second, nil).Once().
+		On("Allow", budget, "", ip).Return(true, time.Duration(0), nil).Once()
+	provider.
+		On("UserIdentifier").Return(userID, nil).Times(3).
+		On("IsNewIdentifier").Return(false).Twice().
+		On("IsNewIdentifier").Return(true).Once()
+
+	resp, err := i(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+	assert.NoError(t, err, "ограничение не превышено")
+	assert.Equal(t, "ok", resp)
+	_, err = i(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err), "ограничение превышено")
+	_, err = i(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+	assert.NoError(t, err, "новый пользователь проверяется только по IP-адресу")
+	resp, err = i(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/shortener.Shortener/GetURL"}, handler)
+	assert.NoError(t, err, "метод без ограничения")
+	assert.Equal(t, "ok", resp)
+
+	limiter.AssertExpectations(t)
+	provider.AssertExpectations(t)
+}
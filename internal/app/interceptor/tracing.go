@@ -0,0 +1,89 @@
+package interceptor
+
+import (
+	"context"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// tracerName имя трассировщика gRPC-сервера.
+const tracerName = "github.com/ivanpodgorny/urlshortener/internal/app/interceptor"
+
+// Tracing возвращает interceptor, создающий для каждого запроса серверный спан
+// трассировщика из tp. Контекст трассировки клиента извлекается из метаданных
+// запроса распространителем p. Спан называется по полному имени метода,
+// ответы с кодами, означающими ошибку сервера, отмечаются как ошибки.
+// Должен применяться первым.
+func Tracing(tp trace.TracerProvider, p propagation.TextMapPropagator) grpc.UnaryServerInterceptor {
+	tracer := tp.Tracer(tracerName)
+
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		ctx = p.Extract(ctx, metadataCarrier(md))
+
+		name := strings.TrimPrefix(info.FullMethod, "/")
+		attrs := []attribute.KeyValue{semconv.RPCSystemGRPC}
+		if service, method, ok := strings.Cut(name, "/"); ok {
+			attrs = append(attrs, semconv.RPCServiceKey.String(service), semconv.RPCMethodKey.String(method))
+		}
+
+		ctx, span := tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(attrs...))
+		defer span.End()
+
+		resp, err := handler(ctx, req)
+		code := status.Code(err)
+		span.SetAttributes(semconv.RPCGRPCStatusCodeKey.Int(int(code)))
+		if isServerError(code) {
+			span.SetStatus(otelcodes.Error, status.Convert(err).Message())
+		}
+
+		return resp, err
+	}
+}
+
+// isServerError возвращает true, если код ответа code означает ошибку сервера.
+func isServerError(code codes.Code) bool {
+	switch code {
+	case codes.Unknown, codes.DeadlineExceeded, codes.Unimplemented, codes.Internal,
+		codes.Unavailable, codes.DataLoss:
+		return true
+	}
+
+	return false
+}
+
+// metadataCarrier адаптер метаданных gRPC для распространителя контекста трассировки.
+type metadataCarrier metadata.MD
+
+// Get возвращает первое значение ключа key.
+func (c metadataCarrier) Get(key string) string {
+	if val := metadata.MD(c).Get(key); len(val) != 0 {
+		return val[0]
+	}
+
+	return ""
+}
+
+// Set устанавливает значение value ключа key.
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+// Keys возвращает все ключи метаданных.
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+
+	return keys
+}
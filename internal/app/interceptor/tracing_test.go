@@ -0,0 +1,63 @@
+package interceptor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestTracing(t *testing.T) {
+	var (
+		recorder = tracetest.NewSpanRecorder()
+		tp       = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+		info     = &grpc.UnaryServerInfo{FullMethod: "/shortener.Shortener/GetURL"}
+		inner    trace.SpanContext
+		code     codes.Code
+		handler  = func(ctx context.Context, _ interface{}) (interface{}, error) {
+			inner = trace.SpanContextFromContext(ctx)
+
+			return nil, status.Error(code, "error")
+		}
+	)
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(
+		"traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+	))
+
+	code = codes.NotFound
+	_, err := Tracing(tp, propagation.TraceContext{})(ctx, nil, info, handler)
+	require.Error(t, err)
+	first := inner
+	code = codes.Internal
+	_, err = Tracing(tp, propagation.TraceContext{})(context.Background(), nil, info, handler)
+	require.Error(t, err)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+
+	assert.Equal(t, "shortener.Shortener/GetURL", spans[0].Name())
+	assert.Equal(t, trace.SpanKindServer, spans[0].SpanKind())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spans[0].SpanContext().TraceID().String(), "контекст трассировки клиента")
+	assert.Equal(t, spans[0].SpanContext().SpanID(), first.SpanID(), "спан передается обработчику")
+	assert.Subset(t, spans[0].Attributes(), []attribute.KeyValue{
+		attribute.String("rpc.system", "grpc"),
+		attribute.String("rpc.service", "shortener.Shortener"),
+		attribute.String("rpc.method", "GetURL"),
+		attribute.Int("rpc.grpc.status_code", int(codes.NotFound)),
+	})
+	assert.Equal(t, otelcodes.Unset, spans[0].Status().Code, "ошибка клиента")
+
+	assert.False(t, spans[1].Parent().IsValid(), "новая трассировка")
+	assert.Equal(t, otelcodes.Error, spans[1].Status().Code, "ошибка сервера")
+}
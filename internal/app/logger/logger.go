@@ -0,0 +1,131 @@
+// Package logger создает структурированный журнал сервиса и передает его
+// через контекст вместе с идентификатором запроса.
+package logger
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strings"
+
+	"golang.org/x/exp/slog"
+)
+
+// Форматы записей журнала.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// RequestIDHeader HTTP-заголовок и ключ метаданных gRPC с идентификатором запроса.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength максимальная длина идентификатора запроса, полученного от клиента.
+const maxRequestIDLength = 128
+
+// ErrInvalidLevel некорректный уровень журнала.
+var ErrInvalidLevel = errors.New("log level must be one of: debug, info, warn, error")
+
+// ErrInvalidFormat некорректный формат журнала.
+var ErrInvalidFormat = errors.New("log format must be one of: text, json")
+
+type (
+	loggerKey    struct{}
+	requestIDKey struct{}
+)
+
+// New возвращает журнал, который записывает в w записи уровня level и выше
+// в формате format.
+func New(w io.Writer, level, format string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil || !IsLevel(level) {
+		return nil, ErrInvalidLevel
+	}
+
+	opts := slog.HandlerOptions{Level: lvl}
+	switch format {
+	case FormatText:
+		return slog.New(opts.NewTextHandler(w)), nil
+	case FormatJSON:
+		return slog.New(opts.NewJSONHandler(w)), nil
+	}
+
+	return nil, ErrInvalidFormat
+}
+
+// IsLevel возвращает true, если level — допустимый уровень журнала: debug, info, warn или error.
+func IsLevel(level string) bool {
+	switch strings.ToLower(level) {
+	case "debug", "info", "warn", "error":
+		return true
+	}
+
+	return false
+}
+
+// IsFormat возвращает true, если format — допустимый формат журнала: text или json.
+func IsFormat(format string) bool {
+	return format == FormatText || format == FormatJSON
+}
+
+// WithLogger возвращает копию ctx с журналом l.
+func WithLogger(ctx context.Context, l *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// FromContext возвращает журнал из контекста ctx. Если в контексте нет журнала,
+// возвращает журнал по умолчанию.
+func FromContext(ctx context.Context) *slog.Logger {
+	if l, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return l
+	}
+
+	return slog.Default()
+}
+
+// WithRequestID возвращает копию ctx с идентификатором запроса id
+// и журналом, добавляющим id к каждой записи.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	ctx = context.WithValue(ctx, requestIDKey{}, id)
+
+	return WithLogger(ctx, FromContext(ctx).With(slog.String("request_id", id)))
+}
+
+// RequestID возвращает идентификатор запроса из контекста ctx или пустую строку.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+
+	return id
+}
+
+// NewRequestID возвращает новый случайный идентификатор запроса.
+func NewRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+
+	return hex.EncodeToString(b)
+}
+
+// IsValidRequestID возвращает true, если идентификатор запроса id, полученный от клиента,
+// можно использовать: он не пустой, не длиннее 128 символов и состоит из букв
+// латинского алфавита, цифр и символов "-", "_", ".", ":".
+func IsValidRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
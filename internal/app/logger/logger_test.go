@@ -0,0 +1,69 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/slog"
+)
+
+func TestNew(t *testing.T) {
+	b := &bytes.Buffer{}
+	l, err := New(b, "warn", FormatJSON)
+	require.NoError(t, err)
+
+	l.Info("skipped")
+	l.Warn("written", "key", "value")
+	entry := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal(b.Bytes(), &entry), "одна запись в формате JSON")
+	assert.Equal(t, "WARN", entry["level"])
+	assert.Equal(t, "written", entry["msg"])
+	assert.Equal(t, "value", entry["key"])
+
+	b.Reset()
+	l, err = New(b, "DEBUG", FormatText)
+	require.NoError(t, err)
+	l.Debug("written")
+	assert.Contains(t, b.String(), "level=DEBUG msg=written")
+
+	_, err = New(b, "trace", FormatText)
+	assert.ErrorIs(t, err, ErrInvalidLevel)
+	_, err = New(b, "info+2", FormatText)
+	assert.ErrorIs(t, err, ErrInvalidLevel)
+	_, err = New(b, "info", "xml")
+	assert.ErrorIs(t, err, ErrInvalidFormat)
+}
+
+func TestWithRequestID(t *testing.T) {
+	ctx := context.Background()
+	assert.Same(t, slog.Default(), FromContext(ctx), "журнал по умолчанию")
+	assert.Empty(t, RequestID(ctx))
+
+	b := &bytes.Buffer{}
+	l, err := New(b, "info", FormatText)
+	require.NoError(t, err)
+	ctx = WithRequestID(WithLogger(ctx, l), "abc")
+	assert.Equal(t, "abc", RequestID(ctx))
+
+	FromContext(ctx).Info("written")
+	assert.Contains(t, b.String(), "request_id=abc")
+}
+
+func TestIsValidRequestID(t *testing.T) {
+	id := NewRequestID()
+	assert.Len(t, id, 32)
+	assert.NotEqual(t, id, NewRequestID())
+	assert.True(t, IsValidRequestID(id))
+	assert.True(t, IsValidRequestID("req-1_2.3:4"))
+	assert.True(t, IsValidRequestID(strings.Repeat("a", 128)))
+
+	assert.False(t, IsValidRequestID(""))
+	assert.False(t, IsValidRequestID(strings.Repeat("a", 129)))
+	assert.False(t, IsValidRequestID("a b"))
+	assert.False(t, IsValidRequestID("a\nlevel=ERROR"))
+}
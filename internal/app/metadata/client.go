@@ -0,0 +1,73 @@
+package metadata
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"github.com/ivanpodgorny/urlshortener/internal/app/validator"
+)
+
+// maxHeaderBytes максимальный размер заголовков ответа.
+const maxHeaderBytes = 64 << 10
+
+// ErrForbiddenAddress ошибка при попытке соединиться с адресом локальной или частной сети.
+var ErrForbiddenAddress = errors.New("connection to private address is forbidden")
+
+// ErrTooManyRedirects ошибка при превышении допустимого числа перенаправлений.
+var ErrTooManyRedirects = errors.New("too many redirects")
+
+// NewClient возвращает HTTP-клиент для загрузки страниц по адресам, которые задают
+// пользователи. Адрес проверяется при установке каждого соединения, поэтому
+// соединиться с локальной или частной сетью не получится ни через перенаправление,
+// ни подменой DNS-записи после проверки адреса назначения. Прокси-серверы из окружения
+// не используются, установка соединения и ожидание заголовков ответа ограничены
+// timeout, перенаправлений выполняется не более maxRedirects.
+func NewClient(timeout time.Duration, maxRedirects int) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: checkAddress,
+	}
+
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext:            dialer.DialContext,
+			TLSHandshakeTimeout:    timeout,
+			ResponseHeaderTimeout:  timeout,
+			MaxResponseHeaderBytes: maxHeaderBytes,
+			ForceAttemptHTTP2:      true,
+			MaxIdleConns:           10,
+			IdleConnTimeout:        90 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return ErrTooManyRedirects
+			}
+
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return fmt.Errorf("%w: %q", ErrUnsupportedScheme, req.URL.Scheme)
+			}
+
+			return nil
+		},
+	}
+}
+
+// checkAddress запрещает соединения с адресами локальных и частных сетей.
+// Вызывается после разрешения имени хоста, перед установкой соединения.
+func checkAddress(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil || validator.IsPrivateIP(ip) {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, host)
+	}
+
+	return nil
+}
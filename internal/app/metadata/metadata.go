@@ -0,0 +1,224 @@
+// Package metadata загружает страницы назначения сокращенных URL и извлекает
+// из них заголовок и теги OpenGraph.
+package metadata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"golang.org/x/net/html/charset"
+
+	"github.com/ivanpodgorny/urlshortener/internal/app/model"
+)
+
+// maxFieldLength максимальная длина значения метаданных в символах.
+const maxFieldLength = 1000
+
+const userAgent = "Mozilla/5.0 (compatible; urlshortener-metadata/1.0)"
+
+// ErrUnsupportedScheme ошибка при попытке загрузить страницу не по HTTP или HTTPS.
+var ErrUnsupportedScheme = errors.New("unsupported url scheme")
+
+// ErrUnexpectedStatus ошибка при ответе сервера с кодом, отличным от 2xx.
+var ErrUnexpectedStatus = errors.New("unexpected response status")
+
+// ErrNotHTML ошибка при ответе сервера документом, отличным от HTML.
+var ErrNotHTML = errors.New("response is not html")
+
+// HTTPClient интерфейс HTTP-клиента. Реализуется *http.Client.
+type HTTPClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Fetcher загружает страницы назначения и извлекает из них метаданные.
+type Fetcher struct {
+	client   HTTPClient
+	timeout  time.Duration
+	maxBytes int64
+}
+
+// NewFetcher возвращает указатель на новый экземпляр Fetcher. Загрузка страницы
+// прерывается через timeout, из тела ответа читается не более maxBytes байт.
+// Для загрузки страниц по адресам, которые задают пользователи, c должен быть
+// защищен от SSRF, например создан NewClient.
+func NewFetcher(c HTTPClient, timeout time.Duration, maxBytes int64) *Fetcher {
+	return &Fetcher{
+		client:   c,
+		timeout:  timeout,
+		maxBytes: maxBytes,
+	}
+}
+
+// Fetch загружает страницу rawURL и возвращает ее метаданные. Относительный адрес
+// изображения приводится к абсолютному относительно адреса страницы после перенаправлений.
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (model.Metadata, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return model.Metadata{}, err
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return model.Metadata{}, fmt.Errorf("%w: %q", ErrUnsupportedScheme, u.Scheme)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, f.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return model.Metadata{}, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml;q=0.9")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return model.Metadata{}, err
+	}
+
+	defer func(body io.ReadCloser) {
+		_ = body.Close()
+	}(resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return model.Metadata{}, fmt.Errorf("%w: %d", ErrUnexpectedStatus, resp.StatusCode)
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return model.Metadata{}, fmt.Errorf("%w: %q", ErrNotHTML, contentType)
+	}
+
+	body, err := charset.NewReader(io.LimitReader(resp.Body, f.maxBytes), contentType)
+	if err != nil {
+		return model.Metadata{}, err
+	}
+
+	md := Parse(body)
+	if md.Image != "" {
+		md.Image = resolveImage(resp.Request.URL, md.Image)
+	}
+
+	return md, nil
+}
+
+// Parse извлекает метаданные из заголовка HTML-документа r в кодировке UTF-8.
+// Разбор заканчивается на начале тела документа.
+func Parse(r io.Reader) model.Metadata {
+	var (
+		md          = model.Metadata{}
+		description string
+		z           = html.NewTokenizer(r)
+		inTitle     bool
+		title       strings.Builder
+	)
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return finish(md, title.String(), description)
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			switch atom.Lookup(name) {
+			case atom.Body:
+				return finish(md, title.String(), description)
+			case atom.Title:
+				inTitle = title.Len() == 0
+			case atom.Meta:
+				if !hasAttr {
+					continue
+				}
+
+				key, content := metaAttributes(z)
+				switch key {
+				case "og:title":
+					md.OGTitle = normalize(content)
+				case "og:description":
+					md.Description = normalize(content)
+				case "og:image", "og:image:url", "og:image:secure_url":
+					if md.Image == "" {
+						md.Image = strings.TrimSpace(content)
+					}
+				case "og:site_name":
+					md.SiteName = normalize(content)
+				case "description":
+					description = normalize(content)
+				}
+			}
+		case html.EndTagToken:
+			if name, _ := z.TagName(); atom.Lookup(name) == atom.Title {
+				inTitle = false
+			}
+		case html.TextToken:
+			if inTitle && title.Len() < maxFieldLength*4 {
+				title.Write(z.Text())
+			}
+		}
+	}
+}
+
+func finish(md model.Metadata, title, description string) model.Metadata {
+	md.Title = normalize(title)
+	if md.Description == "" {
+		md.Description = description
+	}
+
+	return md
+}
+
+// metaAttributes возвращает имя и значение метатега. Имя берется из атрибута
+// property, который используют теги OpenGraph, или из атрибута name.
+func metaAttributes(z *html.Tokenizer) (key, content string) {
+	for {
+		name, val, more := z.TagAttr()
+		switch string(name) {
+		case "property":
+			key = strings.ToLower(strings.TrimSpace(string(val)))
+		case "name":
+			if key == "" {
+				key = strings.ToLower(strings.TrimSpace(string(val)))
+			}
+		case "content":
+			content = string(val)
+		}
+
+		if !more {
+			return key, content
+		}
+	}
+}
+
+// normalize заменяет последовательности пробельных символов одним пробелом
+// и обрезает значение до maxFieldLength символов.
+func normalize(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if r := []rune(s); len(r) > maxFieldLength {
+		s = strings.TrimSpace(string(r[:maxFieldLength]))
+	}
+
+	return s
+}
+
+// resolveImage возвращает абсолютный адрес изображения ref страницы base.
+// Адреса со схемой, отличной от HTTP и HTTPS, отбрасываются.
+func resolveImage(base *url.URL, ref string) string {
+	u, err := url.Parse(ref)
+	if err != nil {
+		return ""
+	}
+
+	u = base.ResolveReference(u)
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return ""
+	}
+
+	return u.String()
+}
@@ -0,0 +1,144 @@
+package metadata
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ivanpodgorny/urlshortener/internal/app/model"
+)
+
+const page = `<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <title>
+    Яндекс &mdash;   быстрый поиск
+  </title>
+  <meta name="description" content="Найдётся всё">
+  <meta property="og:title" content="Яндекс">
+  <meta property="og:site_name" content="Yandex">
+  <meta property="og:image" content="/logo.png">
+</head>
+<body>
+  <title>Не заголовок</title>
+  <meta property="og:description" content="Не описание">
+</body>
+</html>`
+
+func TestParse(t *testing.T) {
+	md := Parse(strings.NewReader(page))
+	assert.Equal(t, model.Metadata{
+		Title:       "Яндекс — быстрый поиск",
+		OGTitle:     "Яндекс",
+		Description: "Найдётся всё",
+		Image:       "/logo.png",
+		SiteName:    "Yandex",
+	}, md)
+
+	md = Parse(strings.NewReader(`<meta name="description" content="a"><meta property="og:description" content="b">`))
+	assert.Equal(t, "b", md.Description, "og:description важнее description")
+
+	md = Parse(strings.NewReader("<title>" + strings.Repeat("a ", maxFieldLength) + "</title>"))
+	assert.Len(t, md.Title, maxFieldLength-1, "длина заголовка ограничена")
+
+	assert.Equal(t, model.Metadata{}, Parse(strings.NewReader("")))
+}
+
+func TestFetcher_Fetch(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		assert.Contains(t, r.Header.Get("User-Agent"), "urlshortener")
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write([]byte(page))
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/page", http.StatusFound)
+	})
+	mux.HandleFunc("/cp1251", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=windows-1251")
+		// "Привет" в кодировке windows-1251.
+		_, _ = w.Write([]byte("<title>\xcf\xf0\xe8\xe2\xe5\xf2</title>"))
+	})
+	mux.HandleFunc("/image", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+	})
+	mux.HandleFunc("/large", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(strings.Repeat(" ", 2048) + "<title>Далеко</title>"))
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	var (
+		ctx = context.Background()
+		f   = NewFetcher(srv.Client(), 100*time.Millisecond, 1024)
+	)
+
+	md, err := f.Fetch(ctx, srv.URL+"/moved")
+	require.NoError(t, err)
+	assert.Equal(t, "Яндекс — быстрый поиск", md.Title)
+	assert.Equal(t, srv.URL+"/logo.png", md.Image, "адрес изображения относительно страницы после перенаправления")
+
+	md, err = f.Fetch(ctx, srv.URL+"/cp1251")
+	require.NoError(t, err)
+	assert.Equal(t, "Привет", md.Title)
+
+	md, err = f.Fetch(ctx, srv.URL+"/large")
+	require.NoError(t, err)
+	assert.Empty(t, md.Title, "заголовок после ограничения размера не читается")
+
+	_, err = f.Fetch(ctx, srv.URL+"/image")
+	assert.ErrorIs(t, err, ErrNotHTML)
+
+	_, err = f.Fetch(ctx, srv.URL+"/missing")
+	assert.ErrorIs(t, err, ErrUnexpectedStatus)
+
+	_, err = f.Fetch(ctx, srv.URL+"/slow")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	_, err = f.Fetch(ctx, "ftp://example.com/")
+	assert.ErrorIs(t, err, ErrUnsupportedScheme)
+}
+
+func TestNewClient(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("соединение с локальным адресом установлено")
+	}))
+	defer srv.Close()
+
+	resp, err := NewClient(time.Second, 3).Get(srv.URL)
+	if resp != nil {
+		require.NoError(t, resp.Body.Close())
+	}
+	assert.ErrorIs(t, err, ErrForbiddenAddress)
+
+	for address, forbidden := range map[string]bool{
+		"127.0.0.1:80":     true,
+		"10.1.2.3:443":     true,
+		"[::1]:80":         true,
+		"169.254.0.1:80":   true,
+		"100.64.0.1:80":    true,
+		"0.0.0.0:80":       true,
+		"93.158.134.3:443": false,
+	} {
+		err := checkAddress("tcp", address, nil)
+		if forbidden {
+			assert.ErrorIs(t, err, ErrForbiddenAddress, address)
+		} else {
+			assert.NoError(t, err, address)
+		}
+	}
+}
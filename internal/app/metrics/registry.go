@@ -0,0 +1,324 @@
+// Package metrics реализует счетчики, гистограммы и датчики и их вывод
+// в текстовом формате Prometheus без внешних зависимостей.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets границы интервалов гистограммы длительности запросов в секундах.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// contentType тип содержимого текстового формата Prometheus.
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// labelSeparator разделитель значений меток в ключе временного ряда.
+const labelSeparator = "\xff"
+
+// Registry набор метрик. Метрики выводятся в порядке регистрации.
+type Registry struct {
+	mu      sync.Mutex
+	metrics []metric
+	names   map[string]bool
+}
+
+type metric interface {
+	write(w *bufio.Writer)
+}
+
+// NewRegistry возвращает указатель на новый экземпляр Registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		names: map[string]bool{},
+	}
+}
+
+// Counter регистрирует счетчик name с метками labels.
+// Паникует, если метрика с таким именем уже зарегистрирована.
+func (r *Registry) Counter(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{
+		desc:   desc{name: name, help: help, kind: "counter", labels: labels},
+		values: map[string]*counterValue{},
+	}
+	r.register(name, c)
+
+	return c
+}
+
+// Histogram регистрирует гистограмму name с границами интервалов buckets
+// по возрастанию и метками labels. Паникует, если метрика с таким именем
+// уже зарегистрирована.
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	h := &HistogramVec{
+		desc:    desc{name: name, help: help, kind: "histogram", labels: labels},
+		buckets: buckets,
+		values:  map[string]*histogramValue{},
+	}
+	r.register(name, h)
+
+	return h
+}
+
+// GaugeFunc регистрирует датчик name, значение которого возвращает f в момент вывода.
+func (r *Registry) GaugeFunc(name, help string, f func() float64) {
+	r.register(name, &funcMetric{desc: desc{name: name, help: help, kind: "gauge"}, f: f})
+}
+
+// CounterFunc регистрирует счетчик name, значение которого возвращает f в момент вывода.
+// Используется для счетчиков, которые ведутся вне Registry.
+func (r *Registry) CounterFunc(name, help string, f func() float64) {
+	r.register(name, &funcMetric{desc: desc{name: name, help: help, kind: "counter"}, f: f})
+}
+
+func (r *Registry) register(name string, m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.names[name] {
+		panic("metrics: duplicate metric " + name)
+	}
+	r.names[name] = true
+	r.metrics = append(r.metrics, m)
+}
+
+// WriteTo выводит все метрики в текстовом формате Prometheus.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	metrics := append([]metric(nil), r.metrics...)
+	r.mu.Unlock()
+
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	for _, m := range metrics {
+		m.write(bw)
+	}
+	err := bw.Flush()
+
+	return cw.n, err
+}
+
+// ServeHTTP отдает все метрики в текстовом формате Prometheus.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", contentType)
+	_, _ = r.WriteTo(w)
+}
+
+// CounterVec счетчик, разделенный по значениям меток.
+type CounterVec struct {
+	desc
+	mu     sync.Mutex
+	values map[string]*counterValue
+}
+
+type counterValue struct {
+	labels []string
+	value  float64
+}
+
+// Inc увеличивает на единицу счетчик со значениями меток labelValues.
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add увеличивает на v счетчик со значениями меток labelValues.
+// Количество значений меток должно совпадать с количеством меток счетчика.
+func (c *CounterVec) Add(v float64, labelValues ...string) {
+	key := c.key(labelValues)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cv, ok := c.values[key]
+	if !ok {
+		cv = &counterValue{labels: append([]string(nil), labelValues...)}
+		c.values[key] = cv
+	}
+	cv.value += v
+}
+
+func (c *CounterVec) write(w *bufio.Writer) {
+	c.writeHeader(w)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, key := range sortedKeys(c.values) {
+		cv := c.values[key]
+		writeSample(w, c.name, c.labels, cv.labels, "", "", cv.value)
+	}
+}
+
+// HistogramVec гистограмма, разделенная по значениям меток.
+type HistogramVec struct {
+	desc
+	buckets []float64
+	mu      sync.Mutex
+	values  map[string]*histogramValue
+}
+
+type histogramValue struct {
+	labels []string
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// Observe добавляет значение v в гистограмму со значениями меток labelValues.
+// Количество значений меток должно совпадать с количеством меток гистограммы.
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+	key := h.key(labelValues)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	hv, ok := h.values[key]
+	if !ok {
+		hv = &histogramValue{
+			labels: append([]string(nil), labelValues...),
+			counts: make([]uint64, len(h.buckets)),
+		}
+		h.values[key] = hv
+	}
+	for i, upper := range h.buckets {
+		if v <= upper {
+			hv.counts[i]++
+		}
+	}
+	hv.count++
+	hv.sum += v
+}
+
+func (h *HistogramVec) write(w *bufio.Writer) {
+	h.writeHeader(w)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, key := range sortedKeys(h.values) {
+		hv := h.values[key]
+		for i, upper := range h.buckets {
+			writeSample(w, h.name+"_bucket", h.labels, hv.labels, "le", formatFloat(upper), float64(hv.counts[i]))
+		}
+		writeSample(w, h.name+"_bucket", h.labels, hv.labels, "le", "+Inf", float64(hv.count))
+		writeSample(w, h.name+"_sum", h.labels, hv.labels, "", "", hv.sum)
+		writeSample(w, h.name+"_count", h.labels, hv.labels, "", "", float64(hv.count))
+	}
+}
+
+type funcMetric struct {
+	desc
+	f func() float64
+}
+
+func (m *funcMetric) write(w *bufio.Writer) {
+	m.writeHeader(w)
+	writeSample(w, m.name, nil, nil, "", "", m.f())
+}
+
+// desc описание метрики.
+type desc struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+}
+
+func (d desc) key(labelValues []string) string {
+	if len(labelValues) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", d.name, len(d.labels), len(labelValues)))
+	}
+
+	return strings.Join(labelValues, labelSeparator)
+}
+
+func (d desc) writeHeader(w *bufio.Writer) {
+	_, _ = fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.name, escapeHelp(d.help), d.name, d.kind)
+}
+
+// writeSample выводит значение временного ряда name с метками names и значениями
+// values. Если extraName не пустая строка, к меткам добавляется метка extraName.
+func writeSample(w *bufio.Writer, name string, names, values []string, extraName, extraValue string, v float64) {
+	_, _ = w.WriteString(name)
+	if len(names) > 0 || extraName != "" {
+		_ = w.WriteByte('{')
+		for i, n := range names {
+			if i > 0 {
+				_ = w.WriteByte(',')
+			}
+			writeLabel(w, n, values[i])
+		}
+		if extraName != "" {
+			if len(names) > 0 {
+				_ = w.WriteByte(',')
+			}
+			writeLabel(w, extraName, extraValue)
+		}
+		_ = w.WriteByte('}')
+	}
+	_ = w.WriteByte(' ')
+	_, _ = w.WriteString(formatFloat(v))
+	_ = w.WriteByte('\n')
+}
+
+func writeLabel(w *bufio.Writer, name, value string) {
+	_, _ = w.WriteString(name)
+	_, _ = w.WriteString(`="`)
+	_, _ = w.WriteString(escapeLabelValue(value))
+	_ = w.WriteByte('"')
+}
+
+var (
+	helpReplacer       = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelValueReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string {
+	return helpReplacer.Replace(s)
+}
+
+func escapeLabelValue(s string) string {
+	return labelValueReplacer.Replace(s)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+
+	return n, err
+}
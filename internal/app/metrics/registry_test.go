@@ -0,0 +1,87 @@
+package metrics
+
+import (
+	"database/sql"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry_WriteTo(t *testing.T) {
+	r := NewRegistry()
+	c := r.Counter("requests_total", "Total requests.\nSecond line.", "method", "path")
+	h := r.Histogram("duration_seconds", "Duration.", []float64{0.1, 1}, "method")
+	r.GaugeFunc("queue_depth", "Queue depth.", func() float64 { return 3 })
+
+	c.Inc("GET", "/b")
+	c.Add(2, "GET", `/a"\`)
+	h.Observe(0.05, "GET")
+	h.Observe(0.5, "GET")
+	h.Observe(5, "GET")
+
+	b := &strings.Builder{}
+	n, err := r.WriteTo(b)
+	require.NoError(t, err)
+	assert.Equal(t, int64(b.Len()), n)
+	assert.Equal(t, `# HELP requests_total Total requests.\nSecond line.
+# TYPE requests_total counter
+requests_total{method="GET",path="/a\"\\"} 2
+requests_total{method="GET",path="/b"} 1
+# HELP duration_seconds Duration.
+# TYPE duration_seconds histogram
+duration_seconds_bucket{method="GET",le="0.1"} 1
+duration_seconds_bucket{method="GET",le="1"} 2
+duration_seconds_bucket{method="GET",le="+Inf"} 3
+duration_seconds_sum{method="GET"} 5.55
+duration_seconds_count{method="GET"} 3
+# HELP queue_depth Queue depth.
+# TYPE queue_depth gauge
+queue_depth 3
+`, b.String())
+
+	assert.Panics(t, func() { r.Counter("requests_total", "") }, "повторная регистрация")
+	assert.Panics(t, func() { c.Inc("GET") }, "неверное количество значений меток")
+}
+
+func TestRegistry_ServeHTTP(t *testing.T) {
+	r := NewRegistry()
+	NewHTTP(r).ObserveHTTPRequest(http.MethodGet, "/{id}", http.StatusTemporaryRedirect, 30*time.Millisecond)
+	NewGRPC(r).ObserveGRPCRequest("/shortener.Shortener/GetURL", "OK", time.Millisecond)
+	NewRedirects(r).ObserveRedirect("hit")
+	NewStorage(r, "memory").ObserveStorageOperation("get", time.Millisecond)
+	RegisterDBStats(r, dbStatsStub{})
+	RegisterDeleteQueue(r, func() int64 { return 7 })
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	result := w.Result()
+	b, err := io.ReadAll(result.Body)
+	require.NoError(t, err)
+	require.NoError(t, result.Body.Close())
+
+	assert.Equal(t, contentType, result.Header.Get("Content-Type"))
+	for _, line := range []string{
+		`shortener_http_requests_total{method="GET",route="/{id}",status="307"} 1`,
+		`shortener_http_request_duration_seconds_bucket{method="GET",route="/{id}",le="0.05"} 1`,
+		`shortener_grpc_requests_total{method="/shortener.Shortener/GetURL",code="OK"} 1`,
+		`shortener_redirects_total{outcome="hit"} 1`,
+		`shortener_storage_operation_duration_seconds_count{backend="memory",operation="get"} 1`,
+		`shortener_db_open_connections 4`,
+		`shortener_db_wait_duration_seconds_total 1.5`,
+		`shortener_delete_queue_depth 7`,
+	} {
+		assert.Contains(t, string(b), line+"\n")
+	}
+}
+
+type dbStatsStub struct{}
+
+func (dbStatsStub) Stats() sql.DBStats {
+	return sql.DBStats{OpenConnections: 4, InUse: 1, Idle: 3, WaitDuration: 1500 * time.Millisecond}
+}
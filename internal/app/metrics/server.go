@@ -0,0 +1,154 @@
+package metrics
+
+import (
+	"database/sql"
+	"strconv"
+	"time"
+)
+
+// namespace префикс имен метрик сервиса.
+const namespace = "shortener_"
+
+// HTTP метрики запросов к HTTP-серверу.
+type HTTP struct {
+	requests *CounterVec
+	duration *HistogramVec
+}
+
+// NewHTTP регистрирует в r метрики запросов к HTTP-серверу и возвращает
+// указатель на новый экземпляр HTTP.
+func NewHTTP(r *Registry) *HTTP {
+	return &HTTP{
+		requests: r.Counter(
+			namespace+"http_requests_total",
+			"Total number of HTTP requests by method, route and status.",
+			"method", "route", "status",
+		),
+		duration: r.Histogram(
+			namespace+"http_request_duration_seconds",
+			"HTTP request duration in seconds by method and route.",
+			DefaultBuckets,
+			"method", "route",
+		),
+	}
+}
+
+// ObserveHTTPRequest учитывает HTTP-запрос method к маршруту route,
+// обработанный за d с кодом ответа status.
+func (m *HTTP) ObserveHTTPRequest(method, route string, status int, d time.Duration) {
+	m.requests.Inc(method, route, strconv.Itoa(status))
+	m.duration.Observe(d.Seconds(), method, route)
+}
+
+// GRPC метрики запросов к gRPC-серверу.
+type GRPC struct {
+	requests *CounterVec
+	duration *HistogramVec
+}
+
+// NewGRPC регистрирует в r метрики запросов к gRPC-серверу и возвращает
+// указатель на новый экземпляр GRPC.
+func NewGRPC(r *Registry) *GRPC {
+	return &GRPC{
+		requests: r.Counter(
+			namespace+"grpc_requests_total",
+			"Total number of gRPC requests by method and code.",
+			"method", "code",
+		),
+		duration: r.Histogram(
+			namespace+"grpc_request_duration_seconds",
+			"gRPC request duration in seconds by method.",
+			DefaultBuckets,
+			"method",
+		),
+	}
+}
+
+// ObserveGRPCRequest учитывает вызов метода method, обработанный за d с кодом code.
+func (m *GRPC) ObserveGRPCRequest(method, code string, d time.Duration) {
+	m.requests.Inc(method, code)
+	m.duration.Observe(d.Seconds(), method)
+}
+
+// Redirects метрики переходов по сокращенным URL.
+type Redirects struct {
+	redirects *CounterVec
+}
+
+// NewRedirects регистрирует в r счетчик переходов по сокращенным URL и возвращает
+// указатель на новый экземпляр Redirects.
+func NewRedirects(r *Registry) *Redirects {
+	return &Redirects{
+		redirects: r.Counter(
+			namespace+"redirects_total",
+			"Total number of short link redirects by outcome: hit, preview, password, miss, gone or denied.",
+			"outcome",
+		),
+	}
+}
+
+// ObserveRedirect учитывает переход по сокращенному URL с результатом outcome.
+func (m *Redirects) ObserveRedirect(outcome string) {
+	m.redirects.Inc(outcome)
+}
+
+// Storage метрики операций хранилища.
+type Storage struct {
+	backend  string
+	duration *HistogramVec
+}
+
+// NewStorage регистрирует в r метрики операций хранилища backend и возвращает
+// указатель на новый экземпляр Storage.
+func NewStorage(r *Registry, backend string) *Storage {
+	return &Storage{
+		backend: backend,
+		duration: r.Histogram(
+			namespace+"storage_operation_duration_seconds",
+			"Storage operation duration in seconds by backend and operation.",
+			DefaultBuckets,
+			"backend", "operation",
+		),
+	}
+}
+
+// ObserveStorageOperation учитывает операцию хранилища op длительностью d.
+func (m *Storage) ObserveStorageOperation(op string, d time.Duration) {
+	m.duration.Observe(d.Seconds(), m.backend, op)
+}
+
+// DBStatser интерфейс получения статистики пула соединений с базой данных.
+// Реализуется *sql.DB.
+type DBStatser interface {
+	Stats() sql.DBStats
+}
+
+// RegisterDBStats регистрирует в r метрики пула соединений с базой данных db.
+func RegisterDBStats(r *Registry, db DBStatser) {
+	r.GaugeFunc(namespace+"db_max_open_connections", "Maximum number of open connections to the database.", func() float64 {
+		return float64(db.Stats().MaxOpenConnections)
+	})
+	r.GaugeFunc(namespace+"db_open_connections", "Number of open connections to the database.", func() float64 {
+		return float64(db.Stats().OpenConnections)
+	})
+	r.GaugeFunc(namespace+"db_in_use_connections", "Number of connections to the database currently in use.", func() float64 {
+		return float64(db.Stats().InUse)
+	})
+	r.GaugeFunc(namespace+"db_idle_connections", "Number of idle connections to the database.", func() float64 {
+		return float64(db.Stats().Idle)
+	})
+	r.CounterFunc(namespace+"db_wait_count_total", "Total number of waits for a database connection.", func() float64 {
+		return float64(db.Stats().WaitCount)
+	})
+	r.CounterFunc(namespace+"db_wait_duration_seconds_total", "Total time in seconds spent waiting for a database connection.", func() float64 {
+		return db.Stats().WaitDuration.Seconds()
+	})
+}
+
+// RegisterDeleteQueue регистрирует в r датчик количества URL, ожидающих удаления,
+// значение которого возвращает depth.
+func RegisterDeleteQueue(r *Registry, depth func() int64) {
+	r.GaugeFunc(namespace+"delete_queue_depth", "Number of short links waiting to be deleted.", func() float64 {
+		return float64(depth())
+	})
+}
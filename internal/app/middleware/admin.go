@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"net/http"
+)
+
+// AdminAuthorizer интерфейс сервиса проверки прав администратора.
+type AdminAuthorizer interface {
+	Authorize(*http.Request) (*http.Request, bool)
+}
+
+// Admin возвращает middleware, пропускающий только запросы администраторов.
+// Остальные запросы завершаются со статусом 403 Forbidden.
+func Admin(a AdminAuthorizer) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r, ok := a.Authorize(r)
+			if !ok {
+				w.WriteHeader(http.StatusForbidden)
+
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
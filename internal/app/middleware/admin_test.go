@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type AdminAuthorizerMock struct {
+	mock.Mock
+}
+
+func (m *AdminAuthorizerMock) Authorize(r *http.Request) (*http.Request, bool) {
+	args := m.Called(r)
+
+	return r, args.Bool(0)
+}
+
+func TestAdmin(t *testing.T) {
+	tests := []struct {
+		name           string
+		authorized     bool
+		wantStatusCode int
+	}{
+		{
+			name:           "запрос администратора",
+			authorized:     true,
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "запрос пользователя",
+			authorized:     false,
+			wantStatusCode: http.StatusForbidden,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				r          = chi.NewRouter()
+				path       = "/"
+				authorizer = &AdminAuthorizerMock{}
+			)
+
+			authorizer.On("Authorize", mock.AnythingOfType("*http.Request")).Return(tt.authorized).Once()
+			r.Use(Admin(authorizer))
+			r.Get(path, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+
+			ts := httptest.NewServer(r)
+			defer ts.Close()
+
+			req, err := http.NewRequest(http.MethodGet, ts.URL+path, nil)
+			require.NoError(t, err)
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			require.NoError(t, resp.Body.Close())
+			assert.Equal(t, tt.wantStatusCode, resp.StatusCode)
+			authorizer.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/ivanpodgorny/urlshortener/internal/app/clientinfo"
+)
+
+// IPResolver интерфейс для определения IP-адреса клиента.
+type IPResolver interface {
+	Resolve(remoteAddr string, forwardedFor []string, realIP string) string
+}
+
+// ClientInfo возвращает middleware, сохраняющий в контекст запроса
+// транспорт и IP-адрес клиента.
+func ClientInfo(resolver IPResolver) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := clientinfo.WithInfo(r.Context(), clientinfo.Info{
+				Transport: clientinfo.TransportHTTP,
+				IP:        resolver.Resolve(r.RemoteAddr, r.Header.Values("X-Forwarded-For"), r.Header.Get("X-Real-IP")),
+			})
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ivanpodgorny/urlshortener/internal/app/clientinfo"
+)
+
+func TestClientInfo(t *testing.T) {
+	tests := []struct {
+		name    string
+		proxies []string
+		want    string
+	}{
+		{
+			name: "прокси не доверенный",
+			want: "127.0.0.1",
+		},
+		{
+			name:    "прокси доверенный",
+			proxies: []string{"127.0.0.1"},
+			want:    "203.0.113.1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				r    = chi.NewRouter()
+				path = "/"
+				info clientinfo.Info
+			)
+
+			proxies, err := clientinfo.ParseSubnets(tt.proxies)
+			require.NoError(t, err)
+			r.Use(ClientInfo(clientinfo.NewResolver(proxies)))
+			r.Get(path, func(w http.ResponseWriter, r *http.Request) {
+				info = clientinfo.FromContext(r.Context())
+				w.WriteHeader(http.StatusOK)
+			})
+
+			ts := httptest.NewServer(r)
+			defer ts.Close()
+
+			req, err := http.NewRequest(http.MethodGet, ts.URL+path, nil)
+			require.NoError(t, err)
+			req.Header.Set("X-Forwarded-For", "203.0.113.1")
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			require.NoError(t, resp.Body.Close())
+			assert.Equal(t, clientinfo.Info{Transport: clientinfo.TransportHTTP, IP: tt.want}, info)
+		})
+	}
+}
@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"net/http"
+)
+
+// CSRFVerifier интерфейс сервиса проверки запроса на межсайтовую подделку.
+type CSRFVerifier interface {
+	Verify(*http.Request) bool
+}
+
+// CSRF возвращает middleware для защиты от межсайтовой подделки запросов.
+// Если запрос не прошел проверку, middleware остановит обработку запроса
+// со статусом 403 Forbidden.
+func CSRF(v CSRFVerifier) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !v.Verify(r) {
+				w.WriteHeader(http.StatusForbidden)
+
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
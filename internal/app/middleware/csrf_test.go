@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type CSRFVerifierMock struct {
+	mock.Mock
+}
+
+func (m *CSRFVerifierMock) Verify(r *http.Request) bool {
+	args := m.Called(r)
+
+	return args.Bool(0)
+}
+
+func TestCSRF(t *testing.T) {
+	tests := []struct {
+		name           string
+		verified       bool
+		wantStatusCode int
+	}{
+		{
+			name:           "запрос прошел проверку",
+			verified:       true,
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "межсайтовый запрос",
+			verified:       false,
+			wantStatusCode: http.StatusForbidden,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				r        = chi.NewRouter()
+				path     = "/"
+				verifier = &CSRFVerifierMock{}
+			)
+
+			verifier.On("Verify", mock.AnythingOfType("*http.Request")).Return(tt.verified).Once()
+			r.Use(CSRF(verifier))
+			r.Post(path, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+
+			ts := httptest.NewServer(r)
+			defer ts.Close()
+
+			req, err := http.NewRequest(http.MethodPost, ts.URL+path, nil)
+			require.NoError(t, err)
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			require.NoError(t, resp.Body.Close())
+			assert.Equal(t, tt.wantStatusCode, resp.StatusCode)
+			verifier.AssertExpectations(t)
+		})
+	}
+}
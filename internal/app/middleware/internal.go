@@ -1,18 +1,23 @@
 package middleware
 
 import (
-	"net"
 	"net/http"
+
+	"github.com/ivanpodgorny/urlshortener/internal/app/clientinfo"
 )
 
-// Internal возвращает middleware, проверяющий, что переданный в заголовке запроса
-// X-Real-IP IP-адрес клиента входит в доверенную подсеть trustedSubnet.
-func Internal(trustedSubnet string) func(next http.Handler) http.Handler {
+// TrustedNetwork интерфейс для проверки вхождения IP-адреса в доверенные подсети.
+type TrustedNetwork interface {
+	Contains(ip string) bool
+}
+
+// Internal возвращает middleware, проверяющий, что IP-адрес клиента входит
+// в доверенные подсети. IP-адрес клиента берется из контекста запроса, поэтому
+// middleware должен применяться после ClientInfo.
+func Internal(trusted TrustedNetwork) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			ip := net.ParseIP(r.Header.Get("X-Real-IP"))
-			_, ipNet, err := net.ParseCIDR(trustedSubnet)
-			if ip == nil || err != nil || !ipNet.Contains(ip) {
+			if !trusted.Contains(clientinfo.FromContext(r.Context()).IP) {
 				w.WriteHeader(http.StatusForbidden)
 
 				return
@@ -8,44 +8,72 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/ivanpodgorny/urlshortener/internal/app/clientinfo"
 )
 
 func TestInternal(t *testing.T) {
 	tests := []struct {
 		name           string
-		trustedSubnet  string
+		trustedSubnets []string
+		trustedProxies []string
+		realIP         string
 		wantStatusCode int
 	}{
 		{
-			name:           "пустое значение trustedSubnet",
-			trustedSubnet:  "",
+			name:           "доверенные подсети не заданы",
 			wantStatusCode: http.StatusForbidden,
 		},
 		{
 			name:           "адрес входит в подсеть",
-			trustedSubnet:  "127.0.0.0/24",
+			trustedSubnets: []string{"192.168.0.0/24", "127.0.0.0/24"},
 			wantStatusCode: http.StatusOK,
 		},
 		{
 			name:           "адрес не входит в подсеть",
-			trustedSubnet:  "192.168.0.0/24",
+			trustedSubnets: []string{"192.168.0.0/24"},
+			wantStatusCode: http.StatusForbidden,
+		},
+		{
+			name:           "X-Real-IP от недоверенного собеседника игнорируется",
+			trustedSubnets: []string{"192.168.0.0/24"},
+			realIP:         "192.168.0.1",
+			wantStatusCode: http.StatusForbidden,
+		},
+		{
+			name:           "X-Real-IP от доверенного прокси",
+			trustedSubnets: []string{"192.168.0.0/24"},
+			trustedProxies: []string{"127.0.0.1"},
+			realIP:         "192.168.0.1",
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "клиент за доверенным прокси не входит в подсеть",
+			trustedSubnets: []string{"127.0.0.0/24"},
+			trustedProxies: []string{"127.0.0.1"},
+			realIP:         "192.168.0.1",
 			wantStatusCode: http.StatusForbidden,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			sendInternalTestRequest(t, tt.trustedSubnet, tt.wantStatusCode)
+			subnets, err := clientinfo.ParseSubnets(tt.trustedSubnets)
+			require.NoError(t, err)
+			proxies, err := clientinfo.ParseSubnets(tt.trustedProxies)
+			require.NoError(t, err)
+			sendInternalTestRequest(t, subnets, proxies, tt.realIP, tt.wantStatusCode)
 		})
 	}
 }
 
-func sendInternalTestRequest(t *testing.T, trustedSubnet string, wantStatusCode int) {
+func sendInternalTestRequest(t *testing.T, subnets, proxies clientinfo.Subnets, realIP string, wantStatusCode int) {
 	var (
 		r    = chi.NewRouter()
 		path = "/"
 	)
 
-	r.Use(Internal(trustedSubnet))
+	r.Use(ClientInfo(clientinfo.NewResolver(proxies)))
+	r.Use(Internal(subnets))
 	r.Get(path, func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 	})
@@ -55,7 +83,9 @@ func sendInternalTestRequest(t *testing.T, trustedSubnet string, wantStatusCode
 
 	req, err := http.NewRequest(http.MethodGet, ts.URL+path, nil)
 	require.NoError(t, err)
-	req.Header.Set("X-Real-IP", "127.0.0.1")
+	if realIP != "" {
+		req.Header.Set("X-Real-IP", realIP)
+	}
 	resp, err := http.DefaultClient.Do(req)
 	require.NoError(t, err)
 	require.NoError(t, resp.Body.Close())
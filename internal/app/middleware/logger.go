@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	"golang.org/x/exp/slog"
+
+	"github.com/ivanpodgorny/urlshortener/internal/app/clientinfo"
+	"github.com/ivanpodgorny/urlshortener/internal/app/logger"
+)
+
+// RequestID возвращает middleware, сохраняющий в контекст запроса идентификатор
+// запроса и журнал l, добавляющий его к каждой записи. Идентификатор берется
+// из заголовка X-Request-ID, если он корректен, иначе генерируется новый.
+// Идентификатор возвращается клиенту в заголовке X-Request-ID ответа.
+func RequestID(l *slog.Logger) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(logger.RequestIDHeader)
+			if !logger.IsValidRequestID(id) {
+				id = logger.NewRequestID()
+			}
+
+			w.Header().Set(logger.RequestIDHeader, id)
+			ctx := logger.WithRequestID(logger.WithLogger(r.Context(), l), id)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// AccessLog возвращает middleware, записывающий в журнал из контекста запроса
+// метод, путь, шаблон маршрута, код и размер ответа, длительность обработки
+// и IP-адрес клиента. Должен применяться после RequestID и ClientInfo
+// и раньше chimiddleware.Recoverer.
+func AccessLog() func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var (
+				start = time.Now()
+				ww    = chimiddleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			)
+			next.ServeHTTP(ww, r)
+
+			route := unmatchedRoute
+			if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+				route = rctx.RoutePattern()
+			}
+
+			logger.FromContext(r.Context()).InfoCtx(
+				r.Context(),
+				"HTTP request",
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.String("route", route),
+				slog.Int("status", responseStatus(ww)),
+				slog.Int("bytes", ww.BytesWritten()),
+				slog.Duration("duration", time.Since(start)),
+				slog.String("ip", clientinfo.FromContext(r.Context()).IP),
+			)
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ivanpodgorny/urlshortener/internal/app/clientinfo"
+	"github.com/ivanpodgorny/urlshortener/internal/app/logger"
+)
+
+func TestRequestID(t *testing.T) {
+	var (
+		b      = &bytes.Buffer{}
+		id     string
+		r      = chi.NewRouter()
+		l, err = logger.New(b, "info", logger.FormatText)
+	)
+	require.NoError(t, err)
+	r.Use(RequestID(l))
+	r.Use(ClientInfo(clientinfo.NewResolver(nil)))
+	r.Use(AccessLog())
+	r.Get("/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id = logger.RequestID(r.Context())
+		w.WriteHeader(http.StatusTemporaryRedirect)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/abc", nil)
+	req.Header.Set(logger.RequestIDHeader, "client-id")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.NoError(t, w.Result().Body.Close())
+	assert.Equal(t, "client-id", id, "идентификатор клиента")
+	assert.Equal(t, "client-id", w.Header().Get(logger.RequestIDHeader))
+	assert.Contains(t, b.String(), "msg=\"HTTP request\" request_id=client-id method=GET path=/abc route=/{id} status=307")
+
+	req = httptest.NewRequest(http.MethodGet, "/abc", nil)
+	req.Header.Set(logger.RequestIDHeader, "bad id")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.NoError(t, w.Result().Body.Close())
+	assert.NotEqual(t, "bad id", id, "некорректный идентификатор заменяется новым")
+	assert.True(t, logger.IsValidRequestID(id))
+	assert.Equal(t, id, w.Header().Get(logger.RequestIDHeader))
+}
@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+)
+
+// unmatchedRoute значение маршрута для запросов, не соответствующих ни одному маршруту.
+const unmatchedRoute = "unmatched"
+
+// Результаты перехода по сокращенному URL.
+const (
+	RedirectHit      = "hit"
+	RedirectPreview  = "preview"
+	RedirectPassword = "password"
+	RedirectMiss     = "miss"
+	RedirectGone     = "gone"
+	RedirectDenied   = "denied"
+)
+
+// HTTPObserver интерфейс учета обработанных HTTP-запросов.
+type HTTPObserver interface {
+	ObserveHTTPRequest(method, route string, status int, d time.Duration)
+}
+
+// RedirectObserver интерфейс учета переходов по сокращенным URL.
+type RedirectObserver interface {
+	ObserveRedirect(outcome string)
+}
+
+// Metrics возвращает middleware, учитывающий метод, шаблон маршрута chi, код ответа
+// и длительность обработки каждого запроса. Чтобы учитывались ответы, которые
+// отправляет chimiddleware.Recoverer, должен применяться раньше него.
+func Metrics(o HTTPObserver) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var (
+				start = time.Now()
+				ww    = chimiddleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			)
+			next.ServeHTTP(ww, r)
+
+			route := unmatchedRoute
+			if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+				route = rctx.RoutePattern()
+			}
+
+			o.ObserveHTTPRequest(r.Method, route, responseStatus(ww), time.Since(start))
+		})
+	}
+}
+
+// RedirectMetrics возвращает middleware, учитывающий результат перехода по сокращенному URL
+// по коду ответа: перенаправление — hit, 200 (страница предпросмотра) — preview,
+// 401 и 403 (форма ввода пароля) — password, 404 — miss, 410 — gone, остальные коды — denied.
+func RedirectMetrics(o RedirectObserver) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ww := chimiddleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r)
+
+			status := responseStatus(ww)
+			switch {
+			case status >= http.StatusMultipleChoices && status < http.StatusBadRequest:
+				o.ObserveRedirect(RedirectHit)
+			case status == http.StatusOK:
+				o.ObserveRedirect(RedirectPreview)
+			case status == http.StatusUnauthorized || status == http.StatusForbidden:
+				o.ObserveRedirect(RedirectPassword)
+			case status == http.StatusNotFound:
+				o.ObserveRedirect(RedirectMiss)
+			case status == http.StatusGone:
+				o.ObserveRedirect(RedirectGone)
+			default:
+				o.ObserveRedirect(RedirectDenied)
+			}
+		})
+	}
+}
+
+// responseStatus возвращает код отправленного ответа. Если хендлер не вызвал
+// WriteHeader и не записал тело, код ответа — 200.
+func responseStatus(ww chimiddleware.WrapResponseWriter) int {
+	if ww.Status() == 0 {
+		return http.StatusOK
+	}
+
+	return ww.Status()
+}
@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type HTTPObserverMock struct {
+	mock.Mock
+}
+
+func (m *HTTPObserverMock) ObserveHTTPRequest(method, route string, status int, _ time.Duration) {
+	m.Called(method, route, status)
+}
+
+type RedirectObserverMock struct {
+	mock.Mock
+}
+
+func (m *RedirectObserverMock) ObserveRedirect(outcome string) {
+	m.Called(outcome)
+}
+
+func TestMetrics(t *testing.T) {
+	var (
+		observer = &HTTPObserverMock{}
+		r        = chi.NewRouter()
+	)
+	observer.
+		On("ObserveHTTPRequest", http.MethodGet, "/{id}", http.StatusTemporaryRedirect).Once().
+		On("ObserveHTTPRequest", http.MethodPost, "/api/shorten", http.StatusOK).Once().
+		On("ObserveHTTPRequest", http.MethodGet, unmatchedRoute, http.StatusNotFound).Once()
+	r.Use(Metrics(observer))
+	r.Get("/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTemporaryRedirect)
+	})
+	r.Post("/api/shorten", func(w http.ResponseWriter, r *http.Request) {})
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/abc", nil),
+		httptest.NewRequest(http.MethodPost, "/api/shorten", nil),
+		httptest.NewRequest(http.MethodGet, "/a/b/c", nil),
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		require.NoError(t, w.Result().Body.Close())
+	}
+	observer.AssertExpectations(t)
+}
+
+func TestRedirectMetrics(t *testing.T) {
+	observer := &RedirectObserverMock{}
+	observer.
+		On("ObserveRedirect", RedirectHit).Once().
+		On("ObserveRedirect", RedirectPreview).Once().
+		On("ObserveRedirect", RedirectPassword).Twice().
+		On("ObserveRedirect", RedirectMiss).Once().
+		On("ObserveRedirect", RedirectGone).Once().
+		On("ObserveRedirect", RedirectDenied).Once()
+
+	for _, status := range []int{
+		http.StatusFound,
+		http.StatusOK,
+		http.StatusUnauthorized,
+		http.StatusForbidden,
+		http.StatusNotFound,
+		http.StatusGone,
+		http.StatusTooManyRequests,
+	} {
+		status := status
+		h := RedirectMetrics(observer)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+		}))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/abc", nil))
+		assert.Equal(t, status, w.Code)
+	}
+	observer.AssertExpectations(t)
+}
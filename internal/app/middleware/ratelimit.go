@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ivanpodgorny/urlshortener/internal/app/clientinfo"
+	"github.com/ivanpodgorny/urlshortener/internal/app/logger"
+)
+
+// RateLimiter интерфейс сервиса ограничения частоты запросов.
+type RateLimiter interface {
+	Allow(ctx context.Context, budget, userID, ip string) (bool, time.Duration, error)
+}
+
+// IdentityProvider интерфейс для получения ID пользователя, выполнившего запрос.
+type IdentityProvider interface {
+	UserIdentifier(ctx context.Context) (string, error)
+	IsNewIdentifier(ctx context.Context) bool
+}
+
+// RateLimit возвращает middleware, ограничивающий частоту запросов группы budget
+// по ID пользователя и IP-адресу клиента. Для ID, созданного при обработке запроса,
+// проверяется только ограничение по IP-адресу. При превышении ограничения запрос
+// завершается со статусом 429 Too Many Requests и заголовком Retry-After.
+// Должен применяться после Authenticate и ClientInfo. Если проверить ограничение
+// не удалось, запрос пропускается.
+func RateLimit(l RateLimiter, p IdentityProvider, budget string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, _ := p.UserIdentifier(r.Context())
+			if p.IsNewIdentifier(r.Context()) {
+				userID = ""
+			}
+			allowed, retryAfter, err := l.Allow(r.Context(), budget, userID, clientinfo.FromContext(r.Context()).IP)
+			if err != nil {
+				logger.FromContext(r.Context()).ErrorCtx(r.Context(), "Error while checking rate limit", "error", err)
+			}
+
+			if !allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+				w.WriteHeader(http.StatusTooManyRequests)
+
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ivanpodgorny/urlshortener/internal/app/clientinfo"
+)
+
+type RateLimiterMock struct {
+	mock.Mock
+}
+
+func (m *RateLimiterMock) Allow(_ context.Context, budget, userID, ip string) (bool, time.Duration, error) {
+	args := m.Called(budget, userID, ip)
+
+	return args.Bool(0), args.Get(1).(time.Duration), args.Error(2)
+}
+
+type IdentityProviderMock struct {
+	mock.Mock
+}
+
+func (m *IdentityProviderMock) UserIdentifier(_ context.Context) (string, error) {
+	args := m.Called()
+
+	return args.String(0), args.Error(1)
+}
+
+func (m *IdentityProviderMock) IsNewIdentifier(_ context.Context) bool {
+	return m.Called().Bool(0)
+}
+
+func TestRateLimit(t *testing.T) {
+	var (
+		budget = "create"
+		userID = "userID"
+		ip     = "127.0.0.1"
+	)
+	tests := []struct {
+		name           string
+		isNew          bool
+		allowed        bool
+		retryAfter     time.Duration
+		err            error
+		wantStatusCode int
+		wantRetryAfter string
+	}{
+		{
+			name:           "ограничение не превышено",
+			allowed:        true,
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "ограничение превышено",
+			retryAfter:     1500 * time.Millisecond,
+			wantStatusCode: http.StatusTooManyRequests,
+			wantRetryAfter: "2",
+		},
+		{
+			name:           "новый пользователь проверяется только по IP-адресу",
+			isNew:          true,
+			allowed:        true,
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "ошибка проверки ограничения",
+			allowed:        true,
+			err:            errors.New(""),
+			wantStatusCode: http.StatusOK,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				r        = chi.NewRouter()
+				path     = "/"
+				limiter  = &RateLimiterMock{}
+				provider = &IdentityProviderMock{}
+			)
+			wantUserID := userID
+			if tt.isNew {
+				wantUserID = ""
+			}
+			limiter.On("Allow", budget, wantUserID, ip).Return(tt.allowed, tt.retryAfter, tt.err).Once()
+			provider.
+				On("UserIdentifier").Return(userID, nil).Once().
+				On("IsNewIdentifier").Return(tt.isNew).Once()
+
+			r.Use(ClientInfo(clientinfo.NewResolver(nil)))
+			r.Use(RateLimit(limiter, provider, budget))
+			r.Post(path, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+
+			ts := httptest.NewServer(r)
+			defer ts.Close()
+
+			resp, err := http.Post(ts.URL+path, "text/plain", nil)
+			require.NoError(t, err)
+			require.NoError(t, resp.Body.Close())
+			assert.Equal(t, tt.wantStatusCode, resp.StatusCode)
+			assert.Equal(t, tt.wantRetryAfter, resp.Header.Get("Retry-After"))
+			limiter.AssertExpectations(t)
+			provider.AssertExpectations(t)
+		})
+	}
+}
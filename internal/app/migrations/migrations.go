@@ -26,6 +26,86 @@ func Up(db *sql.DB) error {
 				Name: "Add deleted column to urls table",
 				Func: addDeletedColumnToUrlsTable,
 			},
+			&migrator.MigrationNoTx{
+				Name: "Add disabled column to urls table",
+				Func: addDisabledColumnToUrlsTable,
+			},
+			&migrator.MigrationNoTx{
+				Name: "Create banned_users table",
+				Func: createBannedUsersTable,
+			},
+			&migrator.MigrationNoTx{
+				Name: "Create admin_actions table",
+				Func: createAdminActionsTable,
+			},
+			&migrator.MigrationNoTx{
+				Name: "Create audit_events table",
+				Func: createAuditEventsTable,
+			},
+			&migrator.MigrationNoTx{
+				Name: "Add indexes to audit_events table",
+				Func: addIndexesToAuditEventsTable,
+			},
+			&migrator.MigrationNoTx{
+				Name: "Create rate_limits table",
+				Func: createRateLimitsTable,
+			},
+			&migrator.Migration{
+				Name: "Add canonical_url column to urls table",
+				Func: addCanonicalURLColumnToUrlsTable,
+			},
+			&migrator.Migration{
+				Name: "Add display_url column to urls table",
+				Func: addDisplayURLColumnToUrlsTable,
+			},
+			&migrator.Migration{
+				Name: "Add password_hash column to urls table",
+				Func: addPasswordHashColumnToUrlsTable,
+			},
+			&migrator.MigrationNoTx{
+				Name: "Add redirect_rules column to urls table",
+				Func: addRedirectRulesColumnToUrlsTable,
+			},
+			&migrator.Migration{
+				Name: "Add user_id to canonical_url unique index",
+				Func: addUserIDToCanonicalURLUniqueIndex,
+			},
+			&migrator.MigrationNoTx{
+				Name: "Add variants column to urls table",
+				Func: addVariantsColumnToUrlsTable,
+			},
+			&migrator.MigrationNoTx{
+				Name: "Create variant_clicks table",
+				Func: createVariantClicksTable,
+			},
+			&migrator.MigrationNoTx{
+				Name: "Add redirect_options column to urls table",
+				Func: addRedirectOptionsColumnToUrlsTable,
+			},
+			&migrator.MigrationNoTx{
+				Name: "Add title column to urls table",
+				Func: addTitleColumnToUrlsTable,
+			},
+			&migrator.MigrationNoTx{
+				Name: "Add created_at column to urls table",
+				Func: addCreatedAtColumnToUrlsTable,
+			},
+			&migrator.MigrationNoTx{
+				Name: "Add metadata column to urls table",
+				Func: addMetadataColumnToUrlsTable,
+			},
+			&migrator.MigrationNoTx{
+				Name: "Add health columns to urls table",
+				Func: addHealthColumnsToUrlsTable,
+			},
+			&migrator.MigrationNoTx{
+				Name: "Create health checked at index",
+				Func: createHealthCheckedAtIndex,
+			},
+			&migrator.Migration{
+				Name: "Add expires_at column to urls table",
+				Func: addExpiresAtColumnToUrlsTable,
+			},
 		),
 	)
 	if err != nil {
@@ -66,3 +146,242 @@ func addDeletedColumnToUrlsTable(db *sql.DB) error {
 
 	return err
 }
+
+func addDisabledColumnToUrlsTable(db *sql.DB) error {
+	_, err := db.Exec("alter table urls add disabled bool default false not null")
+
+	return err
+}
+
+func createBannedUsersTable(db *sql.DB) error {
+	_, err := db.Exec(`
+create table banned_users
+(
+    user_id    uuid        not null primary key,
+    created_at timestamptz not null default now()
+)
+	`)
+
+	return err
+}
+
+func createAdminActionsTable(db *sql.DB) error {
+	_, err := db.Exec(`
+create table admin_actions
+(
+    id         serial        not null primary key,
+    admin_id   varchar(255)  not null,
+    action     varchar(64)   not null,
+    target     varchar(2000) not null,
+    created_at timestamptz   not null default now()
+)
+	`)
+
+	return err
+}
+
+func createAuditEventsTable(db *sql.DB) error {
+	_, err := db.Exec(`
+create table audit_events
+(
+    id         serial       not null primary key,
+    link_id    varchar(16)  not null,
+    action     varchar(16)  not null,
+    user_id    varchar(255) not null,
+    owner_id   varchar(255) not null,
+    client_ip  varchar(45)  not null,
+    transport  varchar(16)  not null,
+    created_at timestamptz  not null default now()
+)
+	`)
+
+	return err
+}
+
+func addIndexesToAuditEventsTable(db *sql.DB) error {
+	if _, err := db.Exec("create index audit_events_owner_id_index on audit_events (owner_id)"); err != nil {
+		return err
+	}
+
+	_, err := db.Exec("create index audit_events_created_at_index on audit_events (created_at)")
+
+	return err
+}
+
+func createRateLimitsTable(db *sql.DB) error {
+	_, err := db.Exec(`
+create table rate_limits
+(
+    key        varchar(320)     not null primary key,
+    tokens     double precision not null,
+    allowed    boolean          not null,
+    burst      integer          not null,
+    rate       double precision not null,
+    updated_at timestamptz      not null
+)
+	`)
+
+	return err
+}
+
+// addCanonicalURLColumnToUrlsTable добавляет канонический вид URL. Канонический вид
+// IRI после экранирования может быть в несколько раз длиннее исходного, поэтому
+// его длина не ограничивается. Уникальность проверяется по хешу: значение
+// в btree-индексе не может превышать размер страницы.
+//
+// Для URL, сохраненных ранее, канонический вид не вычисляется: столбец заполняется
+// исходным URL. Такие URL не объединяются с дубликатами, отличающимися от них
+// только записью, и новые URL, совпадающие с ними после канонизации, сохраняются
+// отдельно.
+func addCanonicalURLColumnToUrlsTable(tx *sql.Tx) error {
+	for _, query := range []string{
+		"alter table urls add canonical_url text",
+		"update urls set canonical_url = url",
+		"alter table urls alter canonical_url set not null",
+		"alter table urls drop constraint urls_url_key",
+		"create unique index urls_canonical_url_md5_index on urls (md5(canonical_url))",
+	} {
+		if _, err := tx.Exec(query); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addDisplayURLColumnToUrlsTable добавляет отображаемый вид URL.
+func addDisplayURLColumnToUrlsTable(tx *sql.Tx) error {
+	for _, query := range []string{
+		"alter table urls add display_url text",
+		"update urls set display_url = url",
+		"alter table urls alter display_url set not null",
+	} {
+		if _, err := tx.Exec(query); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addPasswordHashColumnToUrlsTable добавляет хеш пароля URL. URL с паролем
+// не считаются дубликатами, поэтому уникальность канонического вида
+// проверяется только для URL без пароля.
+func addPasswordHashColumnToUrlsTable(tx *sql.Tx) error {
+	for _, query := range []string{
+		"alter table urls add password_hash text not null default ''",
+		"drop index urls_canonical_url_md5_index",
+		"create unique index urls_canonical_url_md5_index on urls (md5(canonical_url)) where password_hash = ''",
+	} {
+		if _, err := tx.Exec(query); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func addRedirectRulesColumnToUrlsTable(db *sql.DB) error {
+	_, err := db.Exec("alter table urls add redirect_rules jsonb not null default '[]'")
+
+	return err
+}
+
+// addUserIDToCanonicalURLUniqueIndex ограничивает проверку уникальности
+// канонического вида URL одним пользователем: у URL есть правила редиректа
+// и другие параметры, которые задает только его владелец, поэтому URL другого
+// пользователя не считается дубликатом.
+func addUserIDToCanonicalURLUniqueIndex(tx *sql.Tx) error {
+	for _, query := range []string{
+		"drop index urls_canonical_url_md5_index",
+		"create unique index urls_canonical_url_md5_index on urls (user_id, md5(canonical_url)) where password_hash = ''",
+	} {
+		if _, err := tx.Exec(query); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func addVariantsColumnToUrlsTable(db *sql.DB) error {
+	_, err := db.Exec("alter table urls add variants jsonb not null default '{}'")
+
+	return err
+}
+
+func createVariantClicksTable(db *sql.DB) error {
+	_, err := db.Exec(`
+create table variant_clicks
+(
+    url_id  varchar(255) not null,
+    variant varchar(64)  not null,
+    clicks  bigint       not null default 0,
+    primary key (url_id, variant)
+)
+	`)
+
+	return err
+}
+
+func addRedirectOptionsColumnToUrlsTable(db *sql.DB) error {
+	_, err := db.Exec("alter table urls add redirect_options jsonb not null default '{}'")
+
+	return err
+}
+
+func addTitleColumnToUrlsTable(db *sql.DB) error {
+	_, err := db.Exec("alter table urls add title text not null default ''")
+
+	return err
+}
+
+// addCreatedAtColumnToUrlsTable добавляет время создания URL. Для URL, сохраненных
+// ранее, время создания неизвестно, поэтому столбец допускает null.
+func addCreatedAtColumnToUrlsTable(db *sql.DB) error {
+	_, err := db.Exec("alter table urls add created_at timestamptz")
+
+	return err
+}
+
+func addMetadataColumnToUrlsTable(db *sql.DB) error {
+	_, err := db.Exec("alter table urls add metadata jsonb not null default '{}'")
+
+	return err
+}
+
+// addHealthColumnsToUrlsTable добавляет результат последней проверки доступности
+// адреса назначения. Null в health_checked_at — адрес назначения не проверялся.
+func addHealthColumnsToUrlsTable(db *sql.DB) error {
+	_, err := db.Exec(`
+alter table urls
+    add health_status     int  not null default 0,
+    add health_error      text not null default '',
+    add health_checked_at timestamptz
+	`)
+
+	return err
+}
+
+func createHealthCheckedAtIndex(db *sql.DB) error {
+	_, err := db.Exec("create index urls_health_checked_at_idx on urls (health_checked_at nulls first) where deleted = false")
+
+	return err
+}
+
+// addExpiresAtColumnToUrlsTable добавляет время истечения срока действия URL.
+// Null — бессрочный URL. URL со сроком действия не считаются дубликатами,
+// поэтому уникальность канонического вида проверяется только для бессрочных URL.
+func addExpiresAtColumnToUrlsTable(tx *sql.Tx) error {
+	for _, query := range []string{
+		"alter table urls add expires_at timestamptz",
+		"drop index urls_canonical_url_md5_index",
+		"create unique index urls_canonical_url_md5_index on urls (user_id, md5(canonical_url)) where password_hash = '' and expires_at is null",
+	} {
+		if _, err := tx.Exec(query); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
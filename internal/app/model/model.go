@@ -0,0 +1,390 @@
+// Package model содержит типы данных, общие для хранилищ, сервисов и хендлеров.
+package model
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Link сокращенный URL со служебными атрибутами.
+type Link struct {
+	ID           string
+	URL          string
+	CanonicalURL string
+	DisplayURL   string
+	PasswordHash string
+	Title        string
+	UserID       string
+	Deleted      bool
+	Disabled     bool
+	// Rules правила условного редиректа в порядке проверки.
+	Rules []RedirectRule
+	// Variants варианты адреса назначения для A/B-тестирования.
+	Variants Variants
+	// RedirectOptions параметры формирования адреса назначения при переходе.
+	RedirectOptions RedirectOptions
+	// Metadata метаданные страницы назначения, загруженные после создания URL.
+	Metadata Metadata
+	// Health результат последней проверки доступности адреса назначения.
+	Health LinkHealth
+	// CreatedAt время создания. Нулевое значение для URL, сохраненных до появления поля.
+	CreatedAt time.Time
+	// ExpiresAt время истечения срока действия. Нулевое значение — бессрочный URL.
+	ExpiresAt time.Time
+}
+
+// Expired возвращает true, если срок действия URL истек к моменту now.
+func (l Link) Expired(now time.Time) bool {
+	return !l.ExpiresAt.IsZero() && !now.Before(l.ExpiresAt)
+}
+
+// Режимы переноса параметров запроса сокращенного URL в адрес назначения.
+const (
+	// QueryPassthroughNone параметры запроса не переносятся.
+	QueryPassthroughNone = ""
+	// QueryPassthroughMerge параметры запроса добавляются, если в адресе назначения
+	// нет параметра с таким же именем.
+	QueryPassthroughMerge = "merge"
+	// QueryPassthroughOverride параметры запроса заменяют одноименные параметры адреса назначения.
+	QueryPassthroughOverride = "override"
+)
+
+// RedirectOptions параметры формирования адреса назначения при переходе по сокращенному URL.
+type RedirectOptions struct {
+	// Query режим переноса параметров запроса.
+	Query string `json:"query,omitempty"`
+	// Path разрешает переход с суффиксом пути /{id}/extra/path, который добавляется к пути адреса назначения.
+	Path bool `json:"path,omitempty"`
+	// Template включает подстановку значений в плейсхолдеры {id}, {path} и {query.NAME} адреса назначения.
+	Template bool `json:"template,omitempty"`
+	// Status код ответа при переходе. Ноль — код по умолчанию, заданный в конфигурации сервера.
+	Status int `json:"status,omitempty"`
+}
+
+// IsRedirectStatus возвращает true, если code — допустимый код ответа при переходе
+// по сокращенному URL: 301, 302, 307 или 308.
+func IsRedirectStatus(code int) bool {
+	switch code {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+
+	return false
+}
+
+// RedirectRule правило условного редиректа. Правило применяется, если клиент
+// соответствует всем заданным условиям, пустое условие не ограничивает выбор.
+type RedirectRule struct {
+	// Platforms платформы клиента, определяемые по заголовку User-Agent.
+	Platforms []string `json:"platforms,omitempty"`
+	// Languages языковые теги из заголовка Accept-Language.
+	// Тег без региона соответствует всем региональным вариантам языка.
+	Languages []string `json:"languages,omitempty"`
+	// Countries двухбуквенные коды стран ISO 3166-1.
+	Countries []string `json:"countries,omitempty"`
+	// NotBefore и NotAfter ограничивают время действия правила.
+	NotBefore *time.Time `json:"not_before,omitempty"`
+	NotAfter  *time.Time `json:"not_after,omitempty"`
+	// URL адрес назначения.
+	URL string `json:"url"`
+}
+
+// Variant вариант адреса назначения для A/B-тестирования.
+type Variant struct {
+	// Name имя варианта, по которому считаются переходы.
+	Name string `json:"name"`
+	// URL адрес назначения.
+	URL string `json:"url"`
+	// Weight доля переходов на вариант относительно суммы весов всех вариантов.
+	Weight int `json:"weight"`
+}
+
+// Variants варианты адреса назначения URL. Если список пуст, переход выполняется
+// по основному адресу URL.
+type Variants struct {
+	// Sticky закрепляет выбранный вариант за клиентом.
+	Sticky bool      `json:"sticky"`
+	Items  []Variant `json:"variants"`
+}
+
+// VariantStat вариант адреса назначения с количеством переходов на него.
+type VariantStat struct {
+	Variant
+	Clicks int64 `json:"clicks"`
+}
+
+// VariantStats варианты адреса назначения URL с количеством переходов.
+type VariantStats struct {
+	Sticky bool          `json:"sticky"`
+	Items  []VariantStat `json:"variants"`
+}
+
+// VariantClicks количество переходов на вариант адреса назначения URL.
+type VariantClicks struct {
+	// URLID ID URL.
+	URLID string
+	// Variant имя варианта. Пустая строка соответствует основному адресу назначения.
+	Variant string
+	// Clicks количество переходов.
+	Clicks int64
+}
+
+// RedirectRequest параметры запроса на переход по сокращенному URL.
+type RedirectRequest struct {
+	Password       string
+	UserAgent      string
+	AcceptLanguage string
+	// Variant имя варианта адреса назначения, ранее закрепленного за клиентом.
+	Variant string
+	// Path суффикс пути после ID сокращенного URL без ведущего "/".
+	Path string
+	// Query параметры запроса, которые можно перенести в адрес назначения.
+	Query url.Values
+}
+
+// Redirect адрес назначения, выбранный для перехода по сокращенному URL.
+type Redirect struct {
+	URL string
+	// Variant имя выбранного варианта адреса назначения. Пустая строка,
+	// если у URL нет вариантов или сработало правило условного редиректа.
+	Variant string
+	// Sticky признак того, что выбранный вариант нужно закрепить за клиентом.
+	Sticky bool
+	// Status код ответа при переходе. Ноль — код по умолчанию.
+	Status int
+}
+
+// Metadata метаданные страницы назначения: заголовок и теги OpenGraph.
+// Если тег og:description отсутствует, Description содержит значение тега description.
+type Metadata struct {
+	Title       string `json:"title,omitempty"`
+	OGTitle     string `json:"og_title,omitempty"`
+	Description string `json:"description,omitempty"`
+	Image       string `json:"image,omitempty"`
+	SiteName    string `json:"site_name,omitempty"`
+}
+
+// LinkHealth результат проверки доступности адреса назначения.
+type LinkHealth struct {
+	// Status код ответа адреса назначения. Ноль, если ответ не получен.
+	Status int `json:"status"`
+	// Error причина, по которой ответ не получен.
+	Error string `json:"error,omitempty"`
+	// CheckedAt время проверки. Нулевое значение, если адрес назначения не проверялся.
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// Checked возвращает true, если адрес назначения проверялся.
+func (h LinkHealth) Checked() bool {
+	return !h.CheckedAt.IsZero()
+}
+
+// Broken возвращает true, если при последней проверке адрес назначения был недоступен.
+func (h LinkHealth) Broken() bool {
+	return h.Checked() && IsBrokenStatus(h.Status)
+}
+
+// IsBrokenStatus возвращает true, если code — признак недоступного адреса назначения:
+// ответ не получен, страница не найдена или удалена, ошибка сервера. Остальные коды 4xx,
+// например 401 и 429, означают, что страница существует, но доступ к ней ограничен.
+func IsBrokenStatus(code int) bool {
+	return code == 0 || code == http.StatusNotFound || code == http.StatusGone || code >= http.StatusInternalServerError
+}
+
+// Результаты проверки работоспособности сервиса.
+const (
+	ProbeStatusOK   = "ok"
+	ProbeStatusFail = "fail"
+)
+
+// ProbeReport результат проверки работоспособности сервиса.
+type ProbeReport struct {
+	// Status ProbeStatusOK, если все проверки пройдены, иначе ProbeStatusFail.
+	Status string `json:"status"`
+	// Checks результаты проверок по их названиям.
+	Checks map[string]ProbeResult `json:"checks"`
+}
+
+// ProbeResult результат проверки одной зависимости сервиса.
+type ProbeResult struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// HealthSummary сводка проверок доступности адресов назначения неудаленных URL.
+type HealthSummary struct {
+	// Total количество URL.
+	Total int `json:"total"`
+	// Unchecked количество URL, адрес назначения которых еще не проверялся.
+	Unchecked int `json:"unchecked"`
+	// Healthy количество URL с доступным адресом назначения.
+	Healthy int `json:"healthy"`
+	// Broken количество URL с недоступным адресом назначения.
+	Broken int `json:"broken"`
+	// Statuses количество URL по кодам ответа последней проверки, 0 — ответ не получен.
+	Statuses map[int]int `json:"statuses"`
+}
+
+// Preview сведения о сокращенном URL для страницы предпросмотра перехода.
+type Preview struct {
+	// URL адрес назначения, на который был бы выполнен переход.
+	URL string
+	// DisplayURL отображаемый вид адреса назначения.
+	DisplayURL string
+	Title      string
+	CreatedAt  time.Time
+}
+
+// LinkOptions дополнительные параметры создаваемого сокращенного URL.
+type LinkOptions struct {
+	// Password пароль для перехода по URL. Пустая строка — URL без пароля.
+	Password string
+	// Title заголовок страницы назначения.
+	Title string
+	// ExpiresAt время истечения срока действия URL. Нулевое значение — бессрочный URL.
+	ExpiresAt time.Time
+}
+
+// Форматы изображения QR-кода.
+const (
+	QRFormatPNG = "png"
+	QRFormatSVG = "svg"
+)
+
+// QROptions параметры изображения QR-кода сокращенного URL. Нулевые значения
+// заменяются значениями по умолчанию.
+type QROptions struct {
+	// Format формат изображения: png или svg.
+	Format string
+	// Size сторона изображения в пикселях.
+	Size int
+	// Margin ширина свободной зоны вокруг QR-кода в модулях, nil — значение по умолчанию.
+	Margin *int
+	// Level уровень коррекции ошибок: L, M, Q или H.
+	Level string
+}
+
+// AdminAction запись журнала действий администратора.
+type AdminAction struct {
+	AdminID   string
+	Action    string
+	Target    string
+	CreatedAt time.Time
+}
+
+// Действия с сокращенным URL, сохраняемые в журнал аудита.
+const (
+	AuditActionCreate  = "create"
+	AuditActionDelete  = "delete"
+	AuditActionRestore = "restore"
+	AuditActionUpdate  = "update"
+)
+
+// AuditEvent запись журнала аудита жизненного цикла сокращенного URL.
+type AuditEvent struct {
+	LinkID    string    `json:"link_id"`
+	Action    string    `json:"action"`
+	UserID    string    `json:"user_id"`
+	OwnerID   string    `json:"owner_id"`
+	ClientIP  string    `json:"client_ip"`
+	Transport string    `json:"transport"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AuditFilter параметры выборки записей журнала аудита.
+// Пустые значения полей не ограничивают выборку.
+type AuditFilter struct {
+	OwnerID string
+	LinkID  string
+	Limit   int
+}
+
+// Группы запросов с отдельными ограничениями частоты.
+const (
+	RateLimitBudgetCreate = "create"
+	RateLimitBudgetRead   = "read"
+	RateLimitBudgetDelete = "delete"
+	// RateLimitBudgetPassword ограничивает попытки ввода пароля к каждому URL.
+	RateLimitBudgetPassword = "password"
+)
+
+// RateLimit ограничение частоты запросов по алгоритму token bucket:
+// корзина вмещает Burst токенов и пополняется на Rate токенов в секунду.
+// Нулевое значение означает отсутствие ограничения.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// IsZero возвращает true, если ограничение не задано.
+func (l RateLimit) IsZero() bool {
+	return l.Rate <= 0 || l.Burst <= 0
+}
+
+// StatsOptions параметры расчета статистики использования сервиса.
+type StatsOptions struct {
+	// Since начало периода, за который считается количество созданных URL по дням.
+	Since time.Time
+	// Top количество URL и доменов в рейтингах.
+	Top int
+}
+
+// Stats статистика использования сервиса.
+type Stats struct {
+	// URLs количество неудаленных URL.
+	URLs int `json:"urls"`
+	// Users количество пользователей, у которых есть неудаленные URL.
+	Users int `json:"users"`
+	// Active количество неудаленных URL, не заблокированных администратором,
+	// срок действия которых не истек.
+	Active int `json:"active"`
+	// Deleted количество удаленных URL.
+	Deleted int `json:"deleted"`
+	// Expired количество неудаленных URL с истекшим сроком действия.
+	Expired int `json:"expired"`
+	// Disabled количество неудаленных URL, заблокированных администратором.
+	Disabled int `json:"disabled"`
+	// CreatedPerDay количество URL, созданных за каждый день периода, по UTC.
+	CreatedPerDay []DayCount `json:"created_per_day"`
+	// TopLinks неудаленные URL с наибольшим количеством переходов.
+	TopLinks []LinkClicks `json:"top_links"`
+	// TopDomains домены адресов назначения с наибольшим количеством неудаленных URL.
+	TopDomains []DomainCount `json:"top_domains"`
+	// StorageSize размер хранилища в байтах.
+	StorageSize int64 `json:"storage_size"`
+}
+
+// DayFormat формат даты в статистике по дням.
+const DayFormat = "2006-01-02"
+
+// Domain возвращает домен адреса назначения u в нижнем регистре
+// или пустую строку, если адрес некорректен.
+func Domain(u string) string {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return ""
+	}
+
+	return strings.ToLower(parsed.Hostname())
+}
+
+// DayCount количество URL, созданных за день Date в формате 2006-01-02.
+type DayCount struct {
+	Date  string `json:"date"`
+	Count int    `json:"count"`
+}
+
+// LinkClicks URL с количеством переходов по нему.
+type LinkClicks struct {
+	ID     string `json:"id"`
+	URL    string `json:"url"`
+	Clicks int64  `json:"clicks"`
+}
+
+// DomainCount домен адреса назначения с количеством URL.
+type DomainCount struct {
+	Domain string `json:"domain"`
+	Count  int    `json:"count"`
+}
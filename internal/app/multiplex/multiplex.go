@@ -0,0 +1,81 @@
+// Package multiplex позволяет обслуживать gRPC и HTTP API на одном порту,
+// направляя запросы по типу содержимого.
+package multiplex
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// grpcContentType тип содержимого запросов gRPC. Может иметь суффикс
+// с форматом сообщений, например application/grpc+proto.
+const grpcContentType = "application/grpc"
+
+// Handler направляет запросы gRPC в один обработчик, а остальные запросы — в другой.
+type Handler struct {
+	grpc     http.Handler
+	http     http.Handler
+	inflight sync.WaitGroup
+}
+
+// New возвращает указатель на новый экземпляр Handler, который передает запросы
+// gRPC обработчику grpcHandler, например *grpc.Server, а остальные — httpHandler.
+func New(grpcHandler, httpHandler http.Handler) *Handler {
+	return &Handler{
+		grpc: grpcHandler,
+		http: httpHandler,
+	}
+}
+
+// ServeHTTP реализует http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !IsGRPCRequest(r) {
+		h.http.ServeHTTP(w, r)
+
+		return
+	}
+
+	h.inflight.Add(1)
+	defer h.inflight.Done()
+	h.grpc.ServeHTTP(w, r)
+}
+
+// Wait ожидает завершения обрабатываемых запросов gRPC, пока не будет
+// отменен контекст ctx. Вызывается после остановки приема новых запросов.
+func (h *Handler) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		h.inflight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// IsGRPCRequest возвращает true, если r — запрос gRPC: запрос HTTP/2
+// с типом содержимого application/grpc.
+func IsGRPCRequest(r *http.Request) bool {
+	return r.ProtoMajor == 2 && strings.HasPrefix(r.Header.Get("Content-Type"), grpcContentType)
+}
+
+// H2C возвращает обработчик, который принимает HTTP/2 без TLS (h2c) и передает
+// запросы h, и настраивает srv так, чтобы srv.Shutdown корректно завершал
+// соединения HTTP/2.
+func H2C(srv *http.Server, h http.Handler) (http.Handler, error) {
+	h2s := &http2.Server{}
+	if err := http2.ConfigureServer(srv, h2s); err != nil {
+		return nil, err
+	}
+
+	return h2c.NewHandler(h, h2s), nil
+}
@@ -0,0 +1,147 @@
+package multiplex
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestHandler_H2C(t *testing.T) {
+	var (
+		gs  = newGRPCServer()
+		srv = &http.Server{}
+	)
+	h, err := H2C(srv, New(gs, protoHandler()))
+	require.NoError(t, err)
+	srv.Handler = h
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() {
+		_ = srv.Serve(listener)
+	}()
+	defer func() {
+		_ = srv.Close()
+	}()
+	addr := listener.Addr().String()
+
+	resp, err := http.Get("http://" + addr + "/")
+	require.NoError(t, err)
+	assert.Equal(t, "HTTP/1.1", readBody(t, resp), "HTTP/1.1")
+
+	h2 := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, addr)
+		},
+	}}
+	resp, err = h2.Get("http://" + addr + "/")
+	require.NoError(t, err)
+	assert.Equal(t, "HTTP/2.0", readBody(t, resp), "HTTP/2 без TLS")
+
+	conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer func() {
+		_ = conn.Close()
+	}()
+	checkHealth(t, conn)
+}
+
+func TestHandler_TLS(t *testing.T) {
+	ts := httptest.NewUnstartedServer(New(newGRPCServer(), protoHandler()))
+	ts.EnableHTTP2 = true
+	ts.StartTLS()
+	defer ts.Close()
+
+	resp, err := ts.Client().Get(ts.URL)
+	require.NoError(t, err)
+	assert.Equal(t, "HTTP/2.0", readBody(t, resp), "HTTP/2 с TLS")
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ts.Certificate())
+	conn, err := grpc.Dial(ts.Listener.Addr().String(), grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{RootCAs: pool})))
+	require.NoError(t, err)
+	defer func() {
+		_ = conn.Close()
+	}()
+	checkHealth(t, conn)
+}
+
+func TestHandler_Wait(t *testing.T) {
+	var (
+		started = make(chan struct{})
+		release = make(chan struct{})
+		h       = New(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-release
+		}), protoHandler())
+	)
+
+	r := httptest.NewRequest(http.MethodPost, "/grpc.health.v1.Health/Check", nil)
+	r.ProtoMajor = 2
+	r.Header.Set("Content-Type", "application/grpc+proto")
+	go h.ServeHTTP(httptest.NewRecorder(), r)
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, h.Wait(ctx), context.DeadlineExceeded, "запрос gRPC выполняется")
+
+	close(release)
+	assert.NoError(t, h.Wait(context.Background()), "запрос gRPC завершен")
+}
+
+func TestIsGRPCRequest(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/", nil)
+	r.Header.Set("Content-Type", "application/grpc")
+	assert.False(t, IsGRPCRequest(r), "HTTP/1.1")
+	r.ProtoMajor = 2
+	assert.True(t, IsGRPCRequest(r))
+	r.Header.Set("Content-Type", "application/json")
+	assert.False(t, IsGRPCRequest(r), "другой тип содержимого")
+}
+
+func newGRPCServer() *grpc.Server {
+	gs := grpc.NewServer()
+	healthpb.RegisterHealthServer(gs, health.NewServer())
+
+	return gs
+}
+
+func protoHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, r.Proto)
+	})
+}
+
+func readBody(t *testing.T, resp *http.Response) string {
+	b, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+
+	return string(b)
+}
+
+func checkHealth(t *testing.T, conn *grpc.ClientConn) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.GetStatus())
+}
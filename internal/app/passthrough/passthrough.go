@@ -0,0 +1,211 @@
+// Package passthrough реализует перенос параметров запроса и суффикса пути
+// сокращенного URL в адрес назначения и подстановку значений в шаблон адреса назначения.
+package passthrough
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	inerr "github.com/ivanpodgorny/urlshortener/internal/app/errors"
+	"github.com/ivanpodgorny/urlshortener/internal/app/model"
+)
+
+// Плейсхолдеры шаблона адреса назначения. Значение параметра запроса NAME
+// подставляется плейсхолдером {query.NAME}.
+const (
+	PlaceholderID    = "id"
+	PlaceholderPath  = "path"
+	placeholderQuery = "query."
+)
+
+// placeholder находит плейсхолдеры в исходном и экранированном при приведении
+// URL к каноническому виду формате: {path} и %7Bpath%7D.
+var placeholder = regexp.MustCompile(`\{(id|path|query\.[A-Za-z0-9_.~-]+)\}|%7[Bb](id|path|query\.[A-Za-z0-9_.~-]+)%7[Dd]`)
+
+// Params параметры запроса на переход по сокращенному URL, которые переносятся в адрес назначения.
+type Params struct {
+	// ID идентификатор сокращенного URL.
+	ID string
+	// Path суффикс пути после ID сокращенного URL без ведущего "/" в декодированном виде.
+	Path string
+	// Query параметры запроса.
+	Query url.Values
+}
+
+// Validate проверяет параметры переноса запроса и код ответа при переходе. Если параметры
+// некорректны, возвращает ошибку, оборачивающую errors.ErrInvalidRedirectOptions.
+func Validate(opts model.RedirectOptions) error {
+	switch opts.Query {
+	case model.QueryPassthroughNone, model.QueryPassthroughMerge, model.QueryPassthroughOverride:
+	default:
+		return fmt.Errorf("%w: unknown query passthrough mode %q", inerr.ErrInvalidRedirectOptions, opts.Query)
+	}
+
+	if opts.Status != 0 && !model.IsRedirectStatus(opts.Status) {
+		return fmt.Errorf("%w: unsupported redirect status %d", inerr.ErrInvalidRedirectOptions, opts.Status)
+	}
+
+	return nil
+}
+
+// Apply возвращает адрес назначения dest с подставленными в шаблон значениями,
+// суффиксом пути и параметрами запроса p в соответствии с параметрами opts.
+// Значения экранируются в зависимости от части URL, в которую они подставляются.
+// Схема и хост адреса назначения не изменяются.
+// Если суффикс пути передан, а его перенос не включен, возвращает ошибку
+// errors.ErrPathSuffixNotAllowed, если суффикс содержит сегменты "." или "..",
+// возвращает ошибку errors.ErrInvalidPathSuffix.
+func Apply(dest string, opts model.RedirectOptions, p Params) (string, error) {
+	if p.Path != "" && !opts.Path {
+		return "", inerr.ErrPathSuffixNotAllowed
+	}
+
+	if err := checkPath(p.Path); err != nil {
+		return "", err
+	}
+
+	origin, path, query, fragment := split(dest)
+	pathUsed := false
+	if opts.Template {
+		// Если суффикс пути подставлен в шаблон, он не добавляется в конец пути.
+		pathUsed = usesPath(path)
+		path = substitute(path, p, url.PathEscape, escapeSegments)
+		query = substitute(query, p, url.QueryEscape, url.QueryEscape)
+		fragment = substitute(fragment, p, url.PathEscape, escapeSegments)
+	}
+
+	if opts.Path && p.Path != "" && !pathUsed {
+		path = strings.TrimSuffix(path, "/") + "/" + escapeSegments(p.Path)
+	}
+
+	if opts.Query != model.QueryPassthroughNone && len(p.Query) > 0 {
+		query = mergeQuery(query, p.Query, opts.Query)
+	}
+
+	u := origin + path
+	if query != "" {
+		u += "?" + query
+	}
+	if fragment != "" {
+		u += "#" + fragment
+	}
+
+	return u, nil
+}
+
+// split разделяет URL на схему с хостом, путь, строку запроса и фрагмент.
+func split(u string) (origin, path, query, fragment string) {
+	u, fragment, _ = strings.Cut(u, "#")
+	u, query, _ = strings.Cut(u, "?")
+
+	start := 0
+	if i := strings.Index(u, "://"); i >= 0 {
+		start = i + len("://")
+	}
+	if i := strings.Index(u[start:], "/"); i >= 0 {
+		return u[:start+i], u[start+i:], query, fragment
+	}
+
+	return u, "", query, fragment
+}
+
+func usesPath(s string) bool {
+	for _, m := range placeholder.FindAllStringSubmatch(s, -1) {
+		if m[1] == PlaceholderPath || m[2] == PlaceholderPath {
+			return true
+		}
+	}
+
+	return false
+}
+
+// substitute заменяет плейсхолдеры в части URL s. Значения параметров запроса
+// экранируются функцией escapeValue, суффикс пути — функцией escapeSuffix.
+// Отсутствующие параметры заменяются пустой строкой.
+func substitute(s string, p Params, escapeValue, escapeSuffix func(string) string) string {
+	return placeholder.ReplaceAllStringFunc(s, func(m string) string {
+		sub := placeholder.FindStringSubmatch(m)
+		name := sub[1]
+		if name == "" {
+			name = sub[2]
+		}
+
+		switch {
+		case name == PlaceholderID:
+			return escapeValue(p.ID)
+		case name == PlaceholderPath:
+			return escapeSuffix(p.Path)
+		default:
+			return escapeValue(p.Query.Get(strings.TrimPrefix(name, placeholderQuery)))
+		}
+	})
+}
+
+// mergeQuery добавляет параметры incoming к строке запроса адреса назначения rawQuery.
+// Порядок и экранирование параметров адреса назначения сохраняются.
+// В режиме model.QueryPassthroughMerge параметры адреса назначения имеют приоритет,
+// в режиме model.QueryPassthroughOverride заменяются одноименными параметрами incoming.
+func mergeQuery(rawQuery string, incoming url.Values, mode string) string {
+	var (
+		pairs    = make([]string, 0)
+		existing = map[string]bool{}
+	)
+	for _, pair := range strings.Split(rawQuery, "&") {
+		if pair == "" {
+			continue
+		}
+
+		key, _, _ := strings.Cut(pair, "=")
+		if k, err := url.QueryUnescape(key); err == nil {
+			key = k
+		}
+
+		if _, ok := incoming[key]; ok && mode == model.QueryPassthroughOverride {
+			continue
+		}
+
+		existing[key] = true
+		pairs = append(pairs, pair)
+	}
+
+	keys := make([]string, 0, len(incoming))
+	for k := range incoming {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if existing[k] {
+			continue
+		}
+
+		for _, v := range incoming[k] {
+			pairs = append(pairs, url.QueryEscape(k)+"="+url.QueryEscape(v))
+		}
+	}
+
+	return strings.Join(pairs, "&")
+}
+
+func checkPath(path string) error {
+	for _, segment := range strings.Split(path, "/") {
+		if segment == "." || segment == ".." {
+			return inerr.ErrInvalidPathSuffix
+		}
+	}
+
+	return nil
+}
+
+// escapeSegments экранирует каждый сегмент пути s, сохраняя разделители "/".
+func escapeSegments(s string) string {
+	segments := strings.Split(s, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,106 @@
+package passthrough
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	inerr "github.com/ivanpodgorny/urlshortener/internal/app/errors"
+	"github.com/ivanpodgorny/urlshortener/internal/app/model"
+)
+
+func TestApply(t *testing.T) {
+	query := url.Values{"utm_source": {"newsletter"}, "q": {"a b&c"}}
+	tests := []struct {
+		name string
+		dest string
+		opts model.RedirectOptions
+		p    Params
+		want string
+	}{
+		{
+			name: "перенос выключен",
+			dest: "https://example.com/a?x=1",
+			p:    Params{Query: query},
+			want: "https://example.com/a?x=1",
+		},
+		{
+			name: "объединение параметров",
+			dest: "https://example.com/a?q=orig&x=1#top",
+			opts: model.RedirectOptions{Query: model.QueryPassthroughMerge},
+			p:    Params{Query: query},
+			want: "https://example.com/a?q=orig&x=1&utm_source=newsletter#top",
+		},
+		{
+			name: "замена параметров",
+			dest: "https://example.com/a?q=orig&x=1",
+			opts: model.RedirectOptions{Query: model.QueryPassthroughOverride},
+			p:    Params{Query: query},
+			want: "https://example.com/a?x=1&q=a+b%26c&utm_source=newsletter",
+		},
+		{
+			name: "адрес без пути и запроса",
+			dest: "https://example.com",
+			opts: model.RedirectOptions{Query: model.QueryPassthroughMerge, Path: true},
+			p:    Params{Path: "docs/read me", Query: url.Values{"x": {"1"}}},
+			want: "https://example.com/docs/read%20me?x=1",
+		},
+		{
+			name: "суффикс пути",
+			dest: "https://example.com/base/?x=1",
+			opts: model.RedirectOptions{Path: true},
+			p:    Params{Path: "extra/path"},
+			want: "https://example.com/base/extra/path?x=1",
+		},
+		{
+			name: "шаблон в экранированном виде",
+			dest: "https://example.com/u/%7Bquery.user%7D/%7Bpath%7D?src=%7bquery.utm_source%7d&id=%7Bid%7D#%7Bpath%7D",
+			opts: model.RedirectOptions{Path: true, Template: true},
+			p:    Params{ID: "abc", Path: "x/y z", Query: url.Values{"user": {"a/b"}, "utm_source": {"a&b"}}},
+			want: "https://example.com/u/a%2Fb/x/y%20z?src=a%26b&id=abc#x/y%20z",
+		},
+		{
+			name: "шаблон в исходном виде и отсутствующий параметр",
+			dest: "https://example.com/{query.missing}?ref={id}",
+			opts: model.RedirectOptions{Template: true},
+			p:    Params{ID: "abc"},
+			want: "https://example.com/?ref=abc",
+		},
+		{
+			name: "шаблоны выключены",
+			dest: "https://example.com/%7Bid%7D",
+			p:    Params{ID: "abc"},
+			want: "https://example.com/%7Bid%7D",
+		},
+		{
+			name: "плейсхолдер в хосте не подставляется",
+			dest: "https://{query.host}/",
+			opts: model.RedirectOptions{Template: true},
+			p:    Params{Query: url.Values{"host": {"evil.example"}}},
+			want: "https://{query.host}/",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Apply(tt.dest, tt.opts, tt.p)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestApplyErrors(t *testing.T) {
+	_, err := Apply("https://example.com/", model.RedirectOptions{}, Params{Path: "extra"})
+	assert.ErrorIs(t, err, inerr.ErrPathSuffixNotAllowed)
+	_, err = Apply("https://example.com/", model.RedirectOptions{Path: true}, Params{Path: "a/../b"})
+	assert.ErrorIs(t, err, inerr.ErrInvalidPathSuffix)
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, Validate(model.RedirectOptions{Query: model.QueryPassthroughOverride, Path: true}))
+	assert.NoError(t, Validate(model.RedirectOptions{Status: http.StatusPermanentRedirect}))
+	assert.ErrorIs(t, Validate(model.RedirectOptions{Query: "replace"}), inerr.ErrInvalidRedirectOptions)
+	assert.ErrorIs(t, Validate(model.RedirectOptions{Status: http.StatusOK}), inerr.ErrInvalidRedirectOptions)
+}
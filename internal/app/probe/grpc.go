@@ -0,0 +1,43 @@
+package probe
+
+import (
+	"context"
+	"time"
+
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/ivanpodgorny/urlshortener/internal/app/model"
+)
+
+// HealthServer интерфейс сервиса проверки состояния gRPC (grpc.health.v1.Health).
+type HealthServer interface {
+	SetServingStatus(service string, status healthpb.HealthCheckResponse_ServingStatus)
+	Shutdown()
+}
+
+// WatchGRPC с периодичностью period выполняет проверки готовности r и сообщает
+// их результат сервису проверки состояния s для сервера в целом и для каждого
+// сервиса из services. После отмены ctx переводит все сервисы в состояние NOT_SERVING.
+func WatchGRPC(ctx context.Context, r *Registry, s HealthServer, period time.Duration, services ...string) {
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+
+	for {
+		status := healthpb.HealthCheckResponse_SERVING
+		if r.Ready(ctx).Status != model.ProbeStatusOK {
+			status = healthpb.HealthCheckResponse_NOT_SERVING
+		}
+		s.SetServingStatus("", status)
+		for _, service := range services {
+			s.SetServingStatus(service, status)
+		}
+
+		select {
+		case <-ctx.Done():
+			s.Shutdown()
+
+			return
+		case <-ticker.C:
+		}
+	}
+}
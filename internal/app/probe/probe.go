@@ -0,0 +1,201 @@
+// Package probe реализует проверки работоспособности (liveness) и готовности
+// (readiness) сервиса и его зависимостей.
+package probe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ivanpodgorny/urlshortener/internal/app/model"
+)
+
+// Kind вид проверки.
+type Kind int
+
+const (
+	// Liveness проверка того, что процесс работоспособен. Ее провал означает,
+	// что сервис нужно перезапустить.
+	Liveness Kind = iota
+	// Readiness проверка того, что сервис готов обрабатывать запросы.
+	Readiness
+)
+
+// ErrNotServing компонент сервиса не принимает запросы.
+var ErrNotServing = errors.New("not serving")
+
+// Checker интерфейс проверки компонента сервиса.
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// CheckerFunc функция, реализующая Checker.
+type CheckerFunc func(ctx context.Context) error
+
+// Check вызывает f(ctx).
+func (f CheckerFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+// Registry набор проверок сервиса.
+type Registry struct {
+	mu      sync.RWMutex
+	timeout time.Duration
+	checks  []check
+}
+
+type check struct {
+	name    string
+	kind    Kind
+	checker Checker
+}
+
+// NewRegistry возвращает указатель на новый экземпляр Registry. Каждая проверка
+// прерывается, если не завершилась за timeout.
+func NewRegistry(timeout time.Duration) *Registry {
+	return &Registry{
+		timeout: timeout,
+	}
+}
+
+// Register добавляет проверку c вида kind с названием name.
+// Паникует, если проверка с таким названием уже добавлена.
+func (r *Registry) Register(name string, kind Kind, c Checker) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, ch := range r.checks {
+		if ch.name == name {
+			panic(fmt.Sprintf("probe: check %q is already registered", name))
+		}
+	}
+	r.checks = append(r.checks, check{name: name, kind: kind, checker: c})
+}
+
+// Live выполняет проверки вида Liveness.
+func (r *Registry) Live(ctx context.Context) model.ProbeReport {
+	return r.run(ctx, Liveness)
+}
+
+// Ready выполняет все проверки: сервис не готов обрабатывать запросы,
+// если не пройдена хотя бы одна из них.
+func (r *Registry) Ready(ctx context.Context) model.ProbeReport {
+	return r.run(ctx, Readiness)
+}
+
+// run параллельно выполняет проверки вида не старше kind.
+func (r *Registry) run(ctx context.Context, kind Kind) model.ProbeReport {
+	r.mu.RLock()
+	checks := make([]check, 0, len(r.checks))
+	for _, ch := range r.checks {
+		if ch.kind <= kind {
+			checks = append(checks, ch)
+		}
+	}
+	r.mu.RUnlock()
+
+	var (
+		report = model.ProbeReport{Status: model.ProbeStatusOK, Checks: make(map[string]model.ProbeResult, len(checks))}
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+	)
+	for _, ch := range checks {
+		wg.Add(1)
+		go func(ch check) {
+			defer wg.Done()
+
+			result := model.ProbeResult{Status: model.ProbeStatusOK}
+			if err := r.check(ctx, ch.checker); err != nil {
+				result = model.ProbeResult{Status: model.ProbeStatusFail, Error: err.Error()}
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[ch.name] = result
+			if result.Status != model.ProbeStatusOK {
+				report.Status = model.ProbeStatusFail
+			}
+		}(ch)
+	}
+	wg.Wait()
+
+	return report
+}
+
+// check выполняет проверку c с ограничением по времени.
+func (r *Registry) check(ctx context.Context, c Checker) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Check(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Pinger интерфейс соединения с БД.
+type Pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+// Database возвращает проверку доступности БД.
+func Database(db Pinger) Checker {
+	return CheckerFunc(func(ctx context.Context) error {
+		return db.PingContext(ctx)
+	})
+}
+
+// FileWritable возвращает проверку того, что в файл path можно записывать данные.
+func FileWritable(path string) Checker {
+	return CheckerFunc(func(context.Context) error {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+		if err != nil {
+			return err
+		}
+
+		return f.Close()
+	})
+}
+
+// Backlog возвращает проверку того, что очередь, размер которой возвращает depth,
+// содержит не больше max элементов.
+func Backlog(depth func() int64, max int64) Checker {
+	return CheckerFunc(func(context.Context) error {
+		if d := depth(); d > max {
+			return fmt.Errorf("backlog %d exceeds %d", d, max)
+		}
+
+		return nil
+	})
+}
+
+// Flag проверка, результат которой задается извне, например, признак того,
+// что сервер принимает запросы. Нулевое значение — проверка не пройдена.
+type Flag struct {
+	ok atomic.Bool
+}
+
+// Set задает результат проверки.
+func (f *Flag) Set(ok bool) {
+	f.ok.Store(ok)
+}
+
+// Check возвращает ErrNotServing, если флаг не установлен.
+func (f *Flag) Check(context.Context) error {
+	if !f.ok.Load() {
+		return ErrNotServing
+	}
+
+	return nil
+}
@@ -0,0 +1,132 @@
+package probe
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/ivanpodgorny/urlshortener/internal/app/model"
+)
+
+func TestRegistry(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		r       = NewRegistry(50 * time.Millisecond)
+		serving = &Flag{}
+	)
+	r.Register("grpc", Liveness, serving)
+	r.Register("database", Readiness, CheckerFunc(func(context.Context) error {
+		return errors.New("connection refused")
+	}))
+	r.Register("slow", Readiness, CheckerFunc(func(context.Context) error {
+		time.Sleep(time.Second)
+
+		return nil
+	}))
+
+	assert.Equal(t, model.ProbeReport{
+		Status: model.ProbeStatusFail,
+		Checks: map[string]model.ProbeResult{"grpc": {Status: model.ProbeStatusFail, Error: ErrNotServing.Error()}},
+	}, r.Live(ctx), "сервер не запущен")
+
+	serving.Set(true)
+	assert.Equal(t, model.ProbeReport{
+		Status: model.ProbeStatusOK,
+		Checks: map[string]model.ProbeResult{"grpc": {Status: model.ProbeStatusOK}},
+	}, r.Live(ctx), "только проверки работоспособности")
+
+	start := time.Now()
+	assert.Equal(t, model.ProbeReport{
+		Status: model.ProbeStatusFail,
+		Checks: map[string]model.ProbeResult{
+			"grpc":     {Status: model.ProbeStatusOK},
+			"database": {Status: model.ProbeStatusFail, Error: "connection refused"},
+			"slow":     {Status: model.ProbeStatusFail, Error: context.DeadlineExceeded.Error()},
+		},
+	}, r.Ready(ctx), "все проверки")
+	assert.Less(t, time.Since(start), time.Second, "ограничение времени проверки")
+
+	assert.Panics(t, func() { r.Register("grpc", Readiness, serving) }, "повторная регистрация")
+}
+
+func TestCheckers(t *testing.T) {
+	var (
+		ctx  = context.Background()
+		dir  = t.TempDir()
+		path = filepath.Join(dir, "storage")
+	)
+
+	assert.Error(t, FileWritable(path).Check(ctx), "файл не существует")
+	require.NoError(t, os.WriteFile(path, nil, 0600))
+	assert.NoError(t, FileWritable(path).Check(ctx))
+	require.NoError(t, os.Chmod(path, 0400))
+	if os.Geteuid() != 0 {
+		assert.Error(t, FileWritable(path).Check(ctx), "файл только для чтения")
+	}
+
+	var depth int64 = 10
+	backlog := Backlog(func() int64 { return depth }, 10)
+	assert.NoError(t, backlog.Check(ctx))
+	depth = 11
+	assert.EqualError(t, backlog.Check(ctx), "backlog 11 exceeds 10")
+
+	assert.NoError(t, Database(pingerStub{}).Check(ctx))
+	assert.Error(t, Database(pingerStub{err: errors.New("")}).Check(ctx))
+}
+
+func TestWatchGRPC(t *testing.T) {
+	var (
+		ctx, cancel = context.WithCancel(context.Background())
+		r           = NewRegistry(time.Second)
+		serving     = &Flag{}
+		s           = &HealthServerMock{}
+		wg          = sync.WaitGroup{}
+	)
+	r.Register("grpc", Liveness, serving)
+	s.
+		On("SetServingStatus", "", healthpb.HealthCheckResponse_NOT_SERVING).
+		On("SetServingStatus", "shortener.Shortener", healthpb.HealthCheckResponse_NOT_SERVING).
+		Run(func(mock.Arguments) { serving.Set(true) }).
+		On("SetServingStatus", "", healthpb.HealthCheckResponse_SERVING).
+		On("SetServingStatus", "shortener.Shortener", healthpb.HealthCheckResponse_SERVING).
+		Run(func(mock.Arguments) { cancel() }).
+		On("Shutdown").Once()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		WatchGRPC(ctx, r, s, 10*time.Millisecond, "shortener.Shortener")
+	}()
+	wg.Wait()
+
+	s.AssertExpectations(t)
+}
+
+type pingerStub struct {
+	err error
+}
+
+func (p pingerStub) PingContext(context.Context) error {
+	return p.err
+}
+
+type HealthServerMock struct {
+	mock.Mock
+}
+
+func (m *HealthServerMock) SetServingStatus(service string, status healthpb.HealthCheckResponse_ServingStatus) {
+	m.Called(service, status)
+}
+
+func (m *HealthServerMock) Shutdown() {
+	m.Called()
+}
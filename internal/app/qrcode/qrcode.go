@@ -0,0 +1,628 @@
+// Package qrcode реализует кодирование данных в QR-код (ISO/IEC 18004) в байтовом режиме
+// и вывод QR-кода в форматах PNG и SVG.
+package qrcode
+
+import (
+	"errors"
+	"strings"
+)
+
+// Level уровень коррекции ошибок QR-кода.
+type Level int
+
+// Уровни коррекции ошибок: доля кодовых слов, которые можно восстановить.
+const (
+	// LevelLow около 7%.
+	LevelLow Level = iota
+	// LevelMedium около 15%.
+	LevelMedium
+	// LevelQuartile около 25%.
+	LevelQuartile
+	// LevelHigh около 30%.
+	LevelHigh
+)
+
+const (
+	minVersion = 1
+	maxVersion = 40
+)
+
+// ErrDataTooLong ошибка при попытке закодировать данные, которые не помещаются в QR-код
+// максимальной версии с заданным уровнем коррекции ошибок.
+var ErrDataTooLong = errors.New("data too long for qr code")
+
+// ErrUnknownLevel ошибка при разборе неизвестного уровня коррекции ошибок.
+var ErrUnknownLevel = errors.New("unknown error correction level")
+
+// ParseLevel возвращает уровень коррекции ошибок по его обозначению: L, M, Q или H.
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToUpper(s) {
+	case "L":
+		return LevelLow, nil
+	case "M":
+		return LevelMedium, nil
+	case "Q":
+		return LevelQuartile, nil
+	case "H":
+		return LevelHigh, nil
+	}
+
+	return 0, ErrUnknownLevel
+}
+
+// String возвращает обозначение уровня коррекции ошибок.
+func (l Level) String() string {
+	return [...]string{"L", "M", "Q", "H"}[l]
+}
+
+// formatBits биты уровня коррекции ошибок в информации о формате.
+func (l Level) formatBits() int {
+	return [...]int{1, 0, 3, 2}[l]
+}
+
+// eccCodewordsPerBlock количество кодовых слов коррекции ошибок в блоке по уровням и версиям.
+var eccCodewordsPerBlock = [4][maxVersion + 1]int{
+	{-1, 7, 10, 15, 20, 26, 18, 20, 24, 30, 18, 20, 24, 26, 30, 22, 24, 28, 30, 28, 28, 28, 28, 30, 30, 26, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	{-1, 10, 16, 26, 18, 24, 16, 18, 22, 22, 26, 30, 22, 22, 24, 24, 28, 28, 26, 26, 26, 26, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28},
+	{-1, 13, 22, 18, 26, 18, 24, 18, 22, 20, 24, 28, 26, 24, 20, 30, 24, 28, 28, 26, 30, 28, 30, 30, 30, 30, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	{-1, 17, 28, 22, 16, 22, 28, 26, 26, 24, 28, 24, 28, 22, 24, 24, 30, 28, 28, 26, 28, 30, 24, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+}
+
+// eccBlocks количество блоков коррекции ошибок по уровням и версиям.
+var eccBlocks = [4][maxVersion + 1]int{
+	{-1, 1, 1, 1, 1, 1, 2, 2, 2, 2, 4, 4, 4, 4, 4, 6, 6, 6, 6, 7, 8, 8, 9, 9, 10, 12, 12, 12, 13, 14, 15, 16, 17, 18, 19, 19, 20, 21, 22, 24, 25},
+	{-1, 1, 1, 1, 2, 2, 4, 4, 4, 5, 5, 5, 8, 9, 9, 10, 10, 11, 13, 14, 16, 17, 17, 18, 20, 21, 23, 25, 26, 28, 29, 31, 33, 35, 37, 38, 40, 43, 45, 47, 49},
+	{-1, 1, 1, 2, 2, 4, 4, 6, 6, 8, 8, 8, 10, 12, 16, 12, 17, 16, 18, 21, 20, 23, 23, 25, 27, 29, 34, 34, 35, 38, 40, 43, 45, 48, 51, 53, 56, 59, 62, 65, 68},
+	{-1, 1, 1, 2, 4, 4, 4, 5, 6, 8, 8, 11, 11, 16, 16, 18, 16, 19, 21, 25, 25, 25, 34, 30, 32, 35, 37, 40, 42, 45, 48, 51, 54, 57, 60, 63, 66, 70, 74, 77, 81},
+}
+
+// Code QR-код: квадратная матрица модулей.
+type Code struct {
+	version  int
+	level    Level
+	size     int
+	modules  [][]bool
+	function [][]bool
+}
+
+// Encode кодирует data в QR-код минимальной версии, в которую данные помещаются
+// с уровнем коррекции ошибок level. Маска выбирается по наименьшему штрафу.
+// Если данные не помещаются в QR-код версии 40, возвращает ошибку ErrDataTooLong.
+func Encode(data []byte, level Level) (*Code, error) {
+	version := minVersion
+	for ; ; version++ {
+		if version > maxVersion {
+			return nil, ErrDataTooLong
+		}
+
+		if 4+charCountBits(version)+len(data)*8 <= dataCodewords(version, level)*8 {
+			break
+		}
+	}
+
+	c := newCode(version, level)
+	c.drawFunctionPatterns()
+	c.drawCodewords(c.addECCAndInterleave(c.encodeData(data)))
+
+	bestMask, minPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		c.applyMask(mask)
+		c.drawFormatBits(mask)
+		if p := c.penalty(); minPenalty < 0 || p < minPenalty {
+			bestMask, minPenalty = mask, p
+		}
+		c.applyMask(mask)
+	}
+	c.applyMask(bestMask)
+	c.drawFormatBits(bestMask)
+
+	return c, nil
+}
+
+// Version возвращает версию QR-кода от 1 до 40.
+func (c *Code) Version() int {
+	return c.version
+}
+
+// Level возвращает уровень коррекции ошибок QR-кода.
+func (c *Code) Level() Level {
+	return c.level
+}
+
+// Size возвращает количество модулей по стороне QR-кода без свободной зоны.
+func (c *Code) Size() int {
+	return c.size
+}
+
+// Dark возвращает true, если модуль в столбце x и строке y темный.
+// Для координат за пределами QR-кода возвращает false.
+func (c *Code) Dark(x, y int) bool {
+	return x >= 0 && x < c.size && y >= 0 && y < c.size && c.modules[y][x]
+}
+
+func newCode(version int, level Level) *Code {
+	size := version*4 + 17
+	c := &Code{
+		version:  version,
+		level:    level,
+		size:     size,
+		modules:  make([][]bool, size),
+		function: make([][]bool, size),
+	}
+	for i := range c.modules {
+		c.modules[i] = make([]bool, size)
+		c.function[i] = make([]bool, size)
+	}
+
+	return c
+}
+
+// charCountBits возвращает длину поля количества символов в байтовом режиме.
+func charCountBits(version int) int {
+	if version <= 9 {
+		return 8
+	}
+
+	return 16
+}
+
+// rawDataModules возвращает количество модулей, доступных для данных и кодов коррекции
+// ошибок, с учетом всех служебных узоров.
+func rawDataModules(version int) int {
+	result := (16*version+128)*version + 64
+	if version >= 2 {
+		numAlign := version/7 + 2
+		result -= (25*numAlign-10)*numAlign - 55
+		if version >= 7 {
+			result -= 36
+		}
+	}
+
+	return result
+}
+
+// dataCodewords возвращает количество кодовых слов данных без кодов коррекции ошибок.
+func dataCodewords(version int, level Level) int {
+	return rawDataModules(version)/8 - eccCodewordsPerBlock[level][version]*eccBlocks[level][version]
+}
+
+// alignmentPositions возвращает координаты центров выравнивающих узоров по одной оси.
+func alignmentPositions(version int) []int {
+	if version == 1 {
+		return nil
+	}
+
+	numAlign := version/7 + 2
+	step := (version*8 + numAlign*3 + 5) / (numAlign*4 - 4) * 2
+	result := make([]int, numAlign)
+	result[0] = 6
+	for i, pos := numAlign-1, version*4+17-7; i >= 1; i, pos = i-1, pos-step {
+		result[i] = pos
+	}
+
+	return result
+}
+
+func (c *Code) setFunction(x, y int, dark bool) {
+	c.modules[y][x] = dark
+	c.function[y][x] = true
+}
+
+func (c *Code) drawFunctionPatterns() {
+	for i := 0; i < c.size; i++ {
+		c.setFunction(6, i, i%2 == 0)
+		c.setFunction(i, 6, i%2 == 0)
+	}
+
+	c.drawFinderPattern(3, 3)
+	c.drawFinderPattern(c.size-4, 3)
+	c.drawFinderPattern(3, c.size-4)
+
+	positions := alignmentPositions(c.version)
+	last := len(positions) - 1
+	for i := range positions {
+		for j := range positions {
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			c.drawAlignmentPattern(positions[i], positions[j])
+		}
+	}
+
+	// Информация о формате резервируется до выбора маски.
+	c.drawFormatBits(0)
+	c.drawVersion()
+}
+
+func (c *Code) drawFinderPattern(x, y int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			xx, yy := x+dx, y+dy
+			if xx < 0 || xx >= c.size || yy < 0 || yy >= c.size {
+				continue
+			}
+
+			dist := maxInt(abs(dx), abs(dy))
+			c.setFunction(xx, yy, dist != 2 && dist != 4)
+		}
+	}
+}
+
+func (c *Code) drawAlignmentPattern(x, y int) {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			c.setFunction(x+dx, y+dy, maxInt(abs(dx), abs(dy)) != 1)
+		}
+	}
+}
+
+// drawFormatBits рисует обе копии информации о формате: уровень коррекции ошибок
+// и маску, защищенные кодом БЧХ.
+func (c *Code) drawFormatBits(mask int) {
+	data := c.level.formatBits()<<3 | mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+
+	for i := 0; i <= 5; i++ {
+		c.setFunction(8, i, bit(bits, i))
+	}
+	c.setFunction(8, 7, bit(bits, 6))
+	c.setFunction(8, 8, bit(bits, 7))
+	c.setFunction(7, 8, bit(bits, 8))
+	for i := 9; i < 15; i++ {
+		c.setFunction(14-i, 8, bit(bits, i))
+	}
+
+	for i := 0; i < 8; i++ {
+		c.setFunction(c.size-1-i, 8, bit(bits, i))
+	}
+	for i := 8; i < 15; i++ {
+		c.setFunction(8, c.size-15+i, bit(bits, i))
+	}
+	c.setFunction(8, c.size-8, true)
+}
+
+// drawVersion рисует обе копии информации о версии для версий 7 и выше.
+func (c *Code) drawVersion() {
+	if c.version < 7 {
+		return
+	}
+
+	rem := c.version
+	for i := 0; i < 12; i++ {
+		rem = (rem << 1) ^ ((rem >> 11) * 0x1F25)
+	}
+	bits := c.version<<12 | rem
+
+	for i := 0; i < 18; i++ {
+		a, b := c.size-11+i%3, i/3
+		c.setFunction(a, b, bit(bits, i))
+		c.setFunction(b, a, bit(bits, i))
+	}
+}
+
+// encodeData возвращает кодовые слова данных: режим, количество символов,
+// данные, терминатор и байты заполнения.
+func (c *Code) encodeData(data []byte) []byte {
+	capacity := dataCodewords(c.version, c.level) * 8
+	bb := &bitBuffer{}
+	bb.append(0b0100, 4)
+	bb.append(len(data), charCountBits(c.version))
+	for _, b := range data {
+		bb.append(int(b), 8)
+	}
+
+	bb.append(0, minInt(4, capacity-bb.len()))
+	bb.append(0, (8-bb.len()%8)%8)
+	for pad := 0xEC; bb.len() < capacity; pad ^= 0xEC ^ 0x11 {
+		bb.append(pad, 8)
+	}
+
+	return bb.bytes()
+}
+
+// addECCAndInterleave разбивает данные на блоки, добавляет к каждому блоку коды
+// Рида — Соломона и чередует кодовые слова блоков.
+func (c *Code) addECCAndInterleave(data []byte) []byte {
+	var (
+		numBlocks      = eccBlocks[c.level][c.version]
+		blockECCLen    = eccCodewordsPerBlock[c.level][c.version]
+		rawCodewords   = rawDataModules(c.version) / 8
+		numShortBlocks = numBlocks - rawCodewords%numBlocks
+		shortBlockLen  = rawCodewords / numBlocks
+		divisor        = reedSolomonDivisor(blockECCLen)
+		blocks         = make([][]byte, 0, numBlocks)
+	)
+	for i, k := 0, 0; i < numBlocks; i++ {
+		n := shortBlockLen - blockECCLen
+		if i >= numShortBlocks {
+			n++
+		}
+
+		block := make([]byte, 0, shortBlockLen+1)
+		block = append(block, data[k:k+n]...)
+		k += n
+		ecc := reedSolomonRemainder(block, divisor)
+		if i < numShortBlocks {
+			block = append(block, 0)
+		}
+		blocks = append(blocks, append(block, ecc...))
+	}
+
+	result := make([]byte, 0, rawCodewords)
+	for i := range blocks[0] {
+		for j, block := range blocks {
+			// Короткие блоки дополнены фиктивным байтом, который не записывается.
+			if i != shortBlockLen-blockECCLen || j >= numShortBlocks {
+				result = append(result, block[i])
+			}
+		}
+	}
+
+	return result
+}
+
+// drawCodewords размещает кодовые слова зигзагом по парам столбцов снизу вверх и сверху вниз.
+func (c *Code) drawCodewords(data []byte) {
+	i := 0
+	for right := c.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+
+		for vert := 0; vert < c.size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = c.size - 1 - vert
+				}
+
+				if !c.function[y][x] && i < len(data)*8 {
+					c.modules[y][x] = bit(int(data[i>>3]), 7-i&7)
+					i++
+				}
+			}
+		}
+	}
+}
+
+// applyMask инвертирует модули данных по маске. Повторное применение отменяет маску.
+func (c *Code) applyMask(mask int) {
+	for y := 0; y < c.size; y++ {
+		for x := 0; x < c.size; x++ {
+			if c.function[y][x] {
+				continue
+			}
+
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			c.modules[y][x] = c.modules[y][x] != invert
+		}
+	}
+}
+
+// Веса штрафов за нежелательные узоры при выборе маски.
+const (
+	penaltyRun     = 3
+	penaltyBlock   = 3
+	penaltyFinder  = 40
+	penaltyBalance = 10
+)
+
+// penalty возвращает штраф за серии модулей одного цвета, блоки 2x2, узоры, похожие
+// на поисковые, и дисбаланс темных и светлых модулей.
+func (c *Code) penalty() int {
+	result := 0
+	for _, column := range []bool{false, true} {
+		for i := 0; i < c.size; i++ {
+			var (
+				runColor = false
+				runLen   = 0
+				history  = runHistory{size: c.size}
+			)
+			for j := 0; j < c.size; j++ {
+				dark := c.modules[i][j]
+				if column {
+					dark = c.modules[j][i]
+				}
+
+				if dark == runColor {
+					runLen++
+					if runLen == 5 {
+						result += penaltyRun
+					} else if runLen > 5 {
+						result++
+					}
+
+					continue
+				}
+
+				history.add(runLen)
+				if !runColor {
+					result += history.countPatterns() * penaltyFinder
+				}
+				runColor, runLen = dark, 1
+			}
+			result += history.terminateAndCount(runColor, runLen) * penaltyFinder
+		}
+	}
+
+	dark := 0
+	for y := 0; y < c.size; y++ {
+		for x := 0; x < c.size; x++ {
+			if c.modules[y][x] {
+				dark++
+			}
+
+			if x < c.size-1 && y < c.size-1 {
+				color := c.modules[y][x]
+				if color == c.modules[y][x+1] && color == c.modules[y+1][x] && color == c.modules[y+1][x+1] {
+					result += penaltyBlock
+				}
+			}
+		}
+	}
+
+	total := c.size * c.size
+	k := (abs(dark*20-total*10)+total-1)/total - 1
+
+	return result + k*penaltyBalance
+}
+
+// runHistory длины последних серий модулей в строке или столбце для поиска узоров 1:1:3:1:1.
+type runHistory struct {
+	runs [7]int
+	size int
+}
+
+func (h *runHistory) add(runLen int) {
+	if h.runs[0] == 0 {
+		// Свободная зона перед первой серией.
+		runLen += h.size
+	}
+	copy(h.runs[1:], h.runs[:len(h.runs)-1])
+	h.runs[0] = runLen
+}
+
+func (h *runHistory) countPatterns() int {
+	n := h.runs[1]
+	core := n > 0 && h.runs[2] == n && h.runs[3] == n*3 && h.runs[4] == n && h.runs[5] == n
+	count := 0
+	if core && h.runs[0] >= n*4 && h.runs[6] >= n {
+		count++
+	}
+	if core && h.runs[6] >= n*4 && h.runs[0] >= n {
+		count++
+	}
+
+	return count
+}
+
+func (h *runHistory) terminateAndCount(runColor bool, runLen int) int {
+	if runColor {
+		h.add(runLen)
+		runLen = 0
+	}
+	// Свободная зона после последней серии.
+	h.add(runLen + h.size)
+
+	return h.countPatterns()
+}
+
+// reedSolomonDivisor возвращает порождающий многочлен кода Рида — Соломона степени degree.
+func reedSolomonDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range result {
+			result[j] = gfMultiply(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMultiply(root, 0x02)
+	}
+
+	return result
+}
+
+// reedSolomonRemainder возвращает коды коррекции ошибок для данных data.
+func reedSolomonRemainder(data, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i, d := range divisor {
+			result[i] ^= gfMultiply(d, factor)
+		}
+	}
+
+	return result
+}
+
+// gfMultiply умножает элементы поля Галуа GF(2^8) по модулю x^8 + x^4 + x^3 + x^2 + 1.
+func gfMultiply(x, y byte) byte {
+	z := 0
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11D)
+		z ^= int((y>>i)&1) * int(x)
+	}
+
+	return byte(z)
+}
+
+type bitBuffer struct {
+	bits []bool
+}
+
+func (b *bitBuffer) append(val, n int) {
+	for i := n - 1; i >= 0; i-- {
+		b.bits = append(b.bits, bit(val, i))
+	}
+}
+
+func (b *bitBuffer) len() int {
+	return len(b.bits)
+}
+
+func (b *bitBuffer) bytes() []byte {
+	result := make([]byte, len(b.bits)/8)
+	for i, v := range b.bits {
+		if v {
+			result[i>>3] |= 1 << (7 - i&7)
+		}
+	}
+
+	return result
+}
+
+func bit(x, i int) bool {
+	return (x>>i)&1 != 0
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+
+	return x
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+
+	return b
+}
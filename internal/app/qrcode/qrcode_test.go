@@ -0,0 +1,271 @@
+package qrcode
+
+import (
+	"bytes"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCapacity(t *testing.T) {
+	// Емкость в байтовом режиме по таблице ISO/IEC 18004 для уровней L, M, Q, H.
+	capacities := map[int][4]int{
+		1:  {17, 14, 11, 7},
+		2:  {32, 26, 20, 14},
+		5:  {106, 84, 60, 44},
+		7:  {154, 122, 86, 64},
+		10: {271, 213, 151, 119},
+		20: {858, 666, 482, 382},
+		40: {2953, 2331, 1663, 1273},
+	}
+	for version, levels := range capacities {
+		for level, capacity := range levels {
+			got := (dataCodewords(version, Level(level))*8 - 4 - charCountBits(version)) / 8
+			assert.Equal(t, capacity, got, "версия %d, уровень %s", version, Level(level))
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		data    string
+		level   Level
+		version int
+	}{
+		{data: "", level: LevelLow, version: 1},
+		{data: strings.Repeat("a", 14), level: LevelMedium, version: 1},
+		{data: strings.Repeat("a", 15), level: LevelMedium, version: 2},
+		{data: "http://localhost:8080/1i-CBrzwyMkL", level: LevelMedium, version: 3},
+		{data: "https://пример.рф/1i-CBrzwyMkL", level: LevelHigh, version: 5},
+		{data: strings.Repeat("0123456789", 30), level: LevelQuartile, version: 16},
+		{data: strings.Repeat("x", 1273), level: LevelHigh, version: 40},
+	}
+	for _, tt := range tests {
+		c, err := Encode([]byte(tt.data), tt.level)
+		require.NoError(t, err)
+		assert.Equal(t, tt.version, c.Version(), "версия для %d байт", len(tt.data))
+		assert.Equal(t, tt.version*4+17, c.Size())
+		assert.Equal(t, tt.level, c.Level())
+
+		level, data := decode(t, c)
+		assert.Equal(t, tt.level, level, "уровень коррекции ошибок в информации о формате")
+		assert.Equal(t, tt.data, string(data), "декодированные данные")
+	}
+
+	_, err := Encode(bytes.Repeat([]byte("x"), 1274), LevelHigh)
+	assert.ErrorIs(t, err, ErrDataTooLong)
+}
+
+func TestParseLevel(t *testing.T) {
+	for s, want := range map[string]Level{"l": LevelLow, "M": LevelMedium, "q": LevelQuartile, "H": LevelHigh} {
+		level, err := ParseLevel(s)
+		assert.NoError(t, err)
+		assert.Equal(t, want, level)
+	}
+
+	_, err := ParseLevel("X")
+	assert.ErrorIs(t, err, ErrUnknownLevel)
+}
+
+func TestCode_PNG(t *testing.T) {
+	c, err := Encode([]byte("http://localhost:8080/1i-CBrzwyMkL"), LevelMedium)
+	require.NoError(t, err)
+
+	data, err := c.PNG(300, 4)
+	require.NoError(t, err)
+	img, err := png.Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, 300, img.Bounds().Dx())
+	assert.Equal(t, 300, img.Bounds().Dy())
+
+	// 29 модулей и свободная зона по 4 модуля: 300 / 37 = 8 пикселей на модуль,
+	// QR-код занимает 232 пикселя и отступает от края на 34 пикселя.
+	scale, offset := 8, 34
+	for y := 0; y < c.Size(); y++ {
+		for x := 0; x < c.Size(); x++ {
+			r, _, _, _ := img.At(offset+x*scale+scale/2, offset+y*scale+scale/2).RGBA()
+			assert.Equal(t, c.Dark(x, y), r == 0, "модуль %d, %d", x, y)
+		}
+	}
+	r, _, _, _ := img.At(offset-1, offset).RGBA()
+	assert.NotZero(t, r, "свободная зона")
+
+	_, err = c.PNG(36, 4)
+	assert.ErrorIs(t, err, ErrSizeTooSmall)
+}
+
+func TestCode_SVG(t *testing.T) {
+	c, err := Encode([]byte("http://localhost:8080/1i-CBrzwyMkL"), LevelMedium)
+	require.NoError(t, err)
+
+	svg := string(c.SVG(256, 2))
+	assert.Contains(t, svg, `width="256" height="256" viewBox="0 0 33 33"`)
+	assert.Contains(t, svg, `d="M2,2h1v1h-1z`, "левый верхний модуль поискового узора")
+	dark := 0
+	for y := 0; y < c.Size(); y++ {
+		for x := 0; x < c.Size(); x++ {
+			if c.Dark(x, y) {
+				dark++
+			}
+		}
+	}
+	assert.Equal(t, dark, strings.Count(svg, "h1v1h-1z"))
+}
+
+// decode читает QR-код: информацию о формате, данные и коды коррекции ошибок,
+// проверяет синдромы кода Рида — Соломона каждого блока и возвращает уровень
+// коррекции ошибок и данные в байтовом режиме.
+func decode(t *testing.T, c *Code) (Level, []byte) {
+	t.Helper()
+
+	size := c.Size()
+	version := (size - 17) / 4
+	first, second := 0, 0
+	for i := 0; i < 15; i++ {
+		var x1, y1, x2, y2 int
+		switch {
+		case i < 6:
+			x1, y1 = 8, i
+		case i < 8:
+			x1, y1 = 8, i+1
+		case i == 8:
+			x1, y1 = 7, 8
+		default:
+			x1, y1 = 14-i, 8
+		}
+		if i < 8 {
+			x2, y2 = size-1-i, 8
+		} else {
+			x2, y2 = 8, size-15+i
+		}
+		if c.Dark(x1, y1) {
+			first |= 1 << i
+		}
+		if c.Dark(x2, y2) {
+			second |= 1 << i
+		}
+	}
+	require.Equal(t, first, second, "копии информации о формате")
+	require.True(t, c.Dark(8, size-8), "темный модуль")
+
+	format := -1
+	for data := 0; data < 32; data++ {
+		if (data<<10|bchRemainder(data<<10, 0x537, 10))^0x5412 == first {
+			format = data
+		}
+	}
+	require.NotEqual(t, -1, format, "информация о формате")
+	level := map[int]Level{1: LevelLow, 0: LevelMedium, 3: LevelQuartile, 2: LevelHigh}[format>>3]
+	mask := format & 7
+
+	// Служебные модули определяются по эталонному QR-коду той же версии.
+	ref := newCode(version, level)
+	ref.drawFunctionPatterns()
+	unmasked := newCode(version, level)
+	unmasked.function = ref.function
+	for y := range c.modules {
+		copy(unmasked.modules[y], c.modules[y])
+	}
+	unmasked.applyMask(mask)
+
+	var codewords []byte
+	current, n := 0, 0
+	for right := size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < size; vert++ {
+			for j := 0; j < 2; j++ {
+				x, y := right-j, vert
+				if (right+1)&2 == 0 {
+					y = size - 1 - vert
+				}
+				if ref.function[y][x] {
+					continue
+				}
+				current <<= 1
+				if unmasked.modules[y][x] {
+					current |= 1
+				}
+				if n++; n%8 == 0 {
+					codewords = append(codewords, byte(current))
+					current = 0
+				}
+			}
+		}
+	}
+	require.Len(t, codewords, rawDataModules(version)/8)
+
+	var (
+		numBlocks  = eccBlocks[level][version]
+		eccLen     = eccCodewordsPerBlock[level][version]
+		numShort   = numBlocks - len(codewords)%numBlocks
+		shortData  = len(codewords)/numBlocks - eccLen
+		blocksData = make([][]byte, numBlocks)
+		blocksECC  = make([][]byte, numBlocks)
+		pos        = 0
+	)
+	for i := 0; i <= shortData; i++ {
+		for b := 0; b < numBlocks; b++ {
+			if i < shortData || b >= numShort {
+				blocksData[b] = append(blocksData[b], codewords[pos])
+				pos++
+			}
+		}
+	}
+	for i := 0; i < eccLen; i++ {
+		for b := 0; b < numBlocks; b++ {
+			blocksECC[b] = append(blocksECC[b], codewords[pos])
+			pos++
+		}
+	}
+
+	var data []byte
+	for b := range blocksData {
+		block := append(append([]byte{}, blocksData[b]...), blocksECC[b]...)
+		root := byte(1)
+		for i := 0; i < eccLen; i++ {
+			syndrome := byte(0)
+			for _, cw := range block {
+				syndrome = gfMultiply(syndrome, root) ^ cw
+			}
+			require.Zero(t, syndrome, "синдром %d блока %d", i, b)
+			root = gfMultiply(root, 0x02)
+		}
+		data = append(data, blocksData[b]...)
+	}
+
+	bitAt := func(i int) int {
+		return int(data[i/8]>>(7-i%8)) & 1
+	}
+	readBits := func(from, n int) int {
+		v := 0
+		for i := from; i < from+n; i++ {
+			v = v<<1 | bitAt(i)
+		}
+
+		return v
+	}
+	require.Equal(t, 0b0100, readBits(0, 4), "байтовый режим")
+	count := readBits(4, charCountBits(version))
+	result := make([]byte, count)
+	for i := range result {
+		result[i] = byte(readBits(4+charCountBits(version)+i*8, 8))
+	}
+
+	return level, result
+}
+
+// bchRemainder возвращает остаток от деления многочлена val на poly степени degree над GF(2).
+func bchRemainder(val, poly, degree int) int {
+	for i := 14; i >= degree; i-- {
+		if val&(1<<i) != 0 {
+			val ^= poly << (i - degree)
+		}
+	}
+
+	return val
+}
@@ -0,0 +1,84 @@
+package qrcode
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+)
+
+// ErrSizeTooSmall ошибка при попытке вывести QR-код в изображение, в котором
+// на модуль приходится меньше одного пикселя.
+var ErrSizeTooSmall = errors.New("image size too small for qr code")
+
+// PNG возвращает QR-код в формате PNG в виде квадратного изображения со стороной size
+// пикселей и свободной зоной шириной не менее margin модулей. Модули выравниваются
+// по целым пикселям, остаток размера добавляется к свободной зоне.
+// Если на модуль приходится меньше одного пикселя, возвращает ошибку ErrSizeTooSmall.
+func (c *Code) PNG(size, margin int) ([]byte, error) {
+	scale, offset, err := c.layout(size, margin)
+	if err != nil {
+		return nil, err
+	}
+
+	img := image.NewPaletted(image.Rect(0, 0, size, size), color.Palette{color.White, color.Black})
+	for y := 0; y < c.size; y++ {
+		for x := 0; x < c.size; x++ {
+			if !c.modules[y][x] {
+				continue
+			}
+
+			for py := offset + y*scale; py < offset+(y+1)*scale; py++ {
+				row := img.Pix[py*img.Stride:]
+				for px := offset + x*scale; px < offset+(x+1)*scale; px++ {
+					row[px] = 1
+				}
+			}
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	if err = (&png.Encoder{CompressionLevel: png.BestCompression}).Encode(buf, img); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// SVG возвращает QR-код в формате SVG с шириной и высотой size пикселей и свободной
+// зоной шириной margin модулей. Изображение масштабируется без потери качества,
+// поэтому модули не выравниваются по пикселям.
+func (c *Code) SVG(size, margin int) []byte {
+	dim := c.size + 2*margin
+	buf := &bytes.Buffer{}
+	buf.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	fmt.Fprintf(
+		buf,
+		`<svg xmlns="http://www.w3.org/2000/svg" version="1.1" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`+"\n",
+		size, size, dim, dim,
+	)
+	fmt.Fprintf(buf, `<rect width="%d" height="%d" fill="#fff"/>`+"\n", dim, dim)
+	buf.WriteString(`<path fill="#000" d="`)
+	for y := 0; y < c.size; y++ {
+		for x := 0; x < c.size; x++ {
+			if c.modules[y][x] {
+				fmt.Fprintf(buf, "M%d,%dh1v1h-1z", x+margin, y+margin)
+			}
+		}
+	}
+	buf.WriteString(`"/>` + "\n</svg>\n")
+
+	return buf.Bytes()
+}
+
+// layout возвращает размер модуля в пикселях и отступ QR-кода от края изображения.
+func (c *Code) layout(size, margin int) (scale, offset int, err error) {
+	scale = size / (c.size + 2*margin)
+	if scale < 1 {
+		return 0, 0, ErrSizeTooSmall
+	}
+
+	return scale, (size - scale*c.size) / 2, nil
+}
@@ -0,0 +1,242 @@
+// Package rules реализует выбор адреса назначения сокращенного URL по правилам
+// условного редиректа: платформе клиента, языку, стране и времени перехода,
+// а также по весам вариантов адреса назначения для A/B-тестирования.
+package rules
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/text/language"
+
+	inerr "github.com/ivanpodgorny/urlshortener/internal/app/errors"
+	"github.com/ivanpodgorny/urlshortener/internal/app/model"
+)
+
+// Платформы клиента, определяемые по заголовку User-Agent.
+const (
+	PlatformIOS     = "ios"
+	PlatformAndroid = "android"
+	PlatformWindows = "windows"
+	PlatformMacOS   = "macos"
+	PlatformLinux   = "linux"
+	PlatformOther   = "other"
+)
+
+// MaxRules максимальное количество правил условного редиректа одного URL.
+const MaxRules = 20
+
+// platformMarkers подстроки User-Agent, по которым определяется платформа.
+// Порядок важен: User-Agent iOS содержит "like Mac OS X", Android — "Linux".
+var platformMarkers = []struct {
+	platform string
+	markers  []string
+}{
+	{platform: PlatformIOS, markers: []string{"iphone", "ipad", "ipod"}},
+	{platform: PlatformAndroid, markers: []string{"android"}},
+	{platform: PlatformWindows, markers: []string{"windows"}},
+	{platform: PlatformMacOS, markers: []string{"macintosh", "mac os x"}},
+	{platform: PlatformLinux, markers: []string{"linux", "x11", "cros"}},
+}
+
+// Visitor атрибуты клиента, по которым выбирается правило.
+type Visitor struct {
+	Platform  string
+	Languages []string
+	Country   string
+	Time      time.Time
+}
+
+// NewVisitor возвращает атрибуты клиента по заголовкам User-Agent и Accept-Language,
+// коду страны и времени перехода.
+func NewVisitor(userAgent, acceptLanguage, country string, t time.Time) Visitor {
+	return Visitor{
+		Platform:  Platform(userAgent),
+		Languages: Languages(acceptLanguage),
+		Country:   strings.ToUpper(country),
+		Time:      t,
+	}
+}
+
+// Platform возвращает платформу клиента по заголовку User-Agent.
+// Если платформу определить не удается, возвращает PlatformOther.
+func Platform(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+	for _, p := range platformMarkers {
+		for _, m := range p.markers {
+			if strings.Contains(ua, m) {
+				return p.platform
+			}
+		}
+	}
+
+	return PlatformOther
+}
+
+// Languages возвращает языковые теги из заголовка Accept-Language в нижнем регистре
+// в порядке убывания приоритета. Если заголовок не удается разобрать, возвращает nil.
+func Languages(acceptLanguage string) []string {
+	tags, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil {
+		return nil
+	}
+
+	langs := make([]string, 0, len(tags))
+	for _, t := range tags {
+		// Тег * разбирается как mul и не соответствует ни одному языку правил.
+		if lang := strings.ToLower(t.String()); t != language.Und && lang != "mul" {
+			langs = append(langs, lang)
+		}
+	}
+
+	return langs
+}
+
+// Evaluate возвращает адрес назначения первого правила, которому соответствует клиент v.
+// Если ни одно правило не подходит, во втором параметре возвращает false.
+func Evaluate(rules []model.RedirectRule, v Visitor) (string, bool) {
+	for _, r := range rules {
+		if Match(r, v) {
+			return r.URL, true
+		}
+	}
+
+	return "", false
+}
+
+// Match возвращает true, если клиент v соответствует всем условиям правила r.
+func Match(r model.RedirectRule, v Visitor) bool {
+	if len(r.Platforms) > 0 && !contains(r.Platforms, v.Platform) {
+		return false
+	}
+
+	if len(r.Countries) > 0 && !contains(r.Countries, v.Country) {
+		return false
+	}
+
+	if len(r.Languages) > 0 && !matchLanguage(r.Languages, v.Languages) {
+		return false
+	}
+
+	if r.NotBefore != nil && v.Time.Before(*r.NotBefore) {
+		return false
+	}
+
+	if r.NotAfter != nil && !v.Time.Before(*r.NotAfter) {
+		return false
+	}
+
+	return true
+}
+
+// NeedsCountry возвращает true, если хотя бы одно из правил проверяет страну клиента.
+func NeedsCountry(rules []model.RedirectRule) bool {
+	for _, r := range rules {
+		if len(r.Countries) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Normalize проверяет правила и приводит их условия к виду, в котором они
+// сравниваются с атрибутами клиента. Адреса назначения не проверяются.
+// Если правило некорректно, возвращает ошибку, оборачивающую errors.ErrInvalidRedirectRule.
+func Normalize(rules []model.RedirectRule) ([]model.RedirectRule, error) {
+	if len(rules) > MaxRules {
+		return nil, fmt.Errorf("%w: too many rules, maximum is %d", inerr.ErrInvalidRedirectRule, MaxRules)
+	}
+
+	normalized := make([]model.RedirectRule, 0, len(rules))
+	for i, r := range rules {
+		n, err := normalizeRule(r)
+		if err != nil {
+			return nil, fmt.Errorf("%w: rule %d: %v", inerr.ErrInvalidRedirectRule, i, err)
+		}
+
+		normalized = append(normalized, n)
+	}
+
+	return normalized, nil
+}
+
+func normalizeRule(r model.RedirectRule) (model.RedirectRule, error) {
+	n := model.RedirectRule{
+		NotBefore: r.NotBefore,
+		NotAfter:  r.NotAfter,
+		URL:       strings.TrimSpace(r.URL),
+	}
+	if n.URL == "" {
+		return n, fmt.Errorf("url is required")
+	}
+
+	if n.NotBefore != nil && n.NotAfter != nil && !n.NotBefore.Before(*n.NotAfter) {
+		return n, fmt.Errorf("not_before must be earlier than not_after")
+	}
+
+	for _, p := range r.Platforms {
+		p = strings.ToLower(strings.TrimSpace(p))
+		if !isPlatform(p) {
+			return n, fmt.Errorf("unknown platform %q", p)
+		}
+		n.Platforms = append(n.Platforms, p)
+	}
+
+	for _, l := range r.Languages {
+		tag, err := language.Parse(strings.TrimSpace(l))
+		if err != nil || tag == language.Und {
+			return n, fmt.Errorf("invalid language tag %q", l)
+		}
+		n.Languages = append(n.Languages, strings.ToLower(tag.String()))
+	}
+
+	for _, c := range r.Countries {
+		c = strings.ToUpper(strings.TrimSpace(c))
+		if len(c) != 2 || c[0] < 'A' || c[0] > 'Z' || c[1] < 'A' || c[1] > 'Z' {
+			return n, fmt.Errorf("invalid country code %q", c)
+		}
+		n.Countries = append(n.Countries, c)
+	}
+
+	return n, nil
+}
+
+func isPlatform(p string) bool {
+	if p == PlatformOther {
+		return true
+	}
+
+	for _, m := range platformMarkers {
+		if m.platform == p {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchLanguage возвращает true, если один из языков клиента совпадает с одним
+// из тегов правила или является его региональным вариантом.
+func matchLanguage(ruleLangs, visitorLangs []string) bool {
+	for _, v := range visitorLangs {
+		for _, r := range ruleLangs {
+			if v == r || strings.HasPrefix(v, r+"-") {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func contains(values []string, v string) bool {
+	for _, val := range values {
+		if val == v {
+			return true
+		}
+	}
+
+	return false
+}
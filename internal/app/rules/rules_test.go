@@ -0,0 +1,191 @@
+package rules
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	inerr "github.com/ivanpodgorny/urlshortener/internal/app/errors"
+	"github.com/ivanpodgorny/urlshortener/internal/app/model"
+)
+
+func TestPlatform(t *testing.T) {
+	tests := []struct {
+		userAgent string
+		want      string
+	}{
+		{
+			userAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 16_5 like Mac OS X) AppleWebKit/605.1.15 Mobile/15E148",
+			want:      PlatformIOS,
+		},
+		{
+			userAgent: "Mozilla/5.0 (iPad; CPU OS 16_5 like Mac OS X) AppleWebKit/605.1.15",
+			want:      PlatformIOS,
+		},
+		{
+			userAgent: "Mozilla/5.0 (Linux; Android 13; Pixel 7) AppleWebKit/537.36 Chrome/114.0 Mobile Safari/537.36",
+			want:      PlatformAndroid,
+		},
+		{
+			userAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 Chrome/114.0 Safari/537.36",
+			want:      PlatformWindows,
+		},
+		{
+			userAgent: "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 Version/16.5 Safari/605.1.15",
+			want:      PlatformMacOS,
+		},
+		{
+			userAgent: "Mozilla/5.0 (X11; Ubuntu; Linux x86_64; rv:109.0) Gecko/20100101 Firefox/114.0",
+			want:      PlatformLinux,
+		},
+		{
+			userAgent: "curl/8.1.2",
+			want:      PlatformOther,
+		},
+		{
+			userAgent: "",
+			want:      PlatformOther,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			assert.Equal(t, tt.want, Platform(tt.userAgent))
+		})
+	}
+}
+
+func TestLanguages(t *testing.T) {
+	assert.Equal(t, []string{"de-de", "de", "en"}, Languages("en;q=0.5, de-DE, de;q=0.8, fr;q=0"))
+	assert.Empty(t, Languages(""))
+	assert.Empty(t, Languages("*"))
+}
+
+func TestEvaluate(t *testing.T) {
+	var (
+		now       = time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+		past      = now.Add(-time.Hour)
+		future    = now.Add(time.Hour)
+		appStore  = "https://apps.apple.com/app/id1"
+		playStore = "https://play.google.com/store/apps/details?id=app"
+		german    = "https://example.com/de"
+		promo     = "https://example.com/promo"
+		rules     = []model.RedirectRule{
+			{Platforms: []string{PlatformIOS}, URL: appStore},
+			{Platforms: []string{PlatformAndroid}, Countries: []string{"DE", "AT"}, URL: playStore + "&hl=de"},
+			{Platforms: []string{PlatformAndroid}, URL: playStore},
+			{Languages: []string{"de"}, URL: german},
+			{NotBefore: &past, NotAfter: &future, Countries: []string{"FR"}, URL: promo},
+		}
+	)
+	tests := []struct {
+		name    string
+		visitor Visitor
+		want    string
+		found   bool
+	}{
+		{
+			name:    "платформа",
+			visitor: Visitor{Platform: PlatformIOS, Languages: []string{"de"}, Time: now},
+			want:    appStore,
+			found:   true,
+		},
+		{
+			name:    "платформа и страна",
+			visitor: Visitor{Platform: PlatformAndroid, Country: "AT", Time: now},
+			want:    playStore + "&hl=de",
+			found:   true,
+		},
+		{
+			name:    "платформа без совпадения страны",
+			visitor: Visitor{Platform: PlatformAndroid, Country: "US", Time: now},
+			want:    playStore,
+			found:   true,
+		},
+		{
+			name:    "региональный вариант языка",
+			visitor: Visitor{Platform: PlatformWindows, Languages: []string{"en-us", "de-ch"}, Time: now},
+			want:    german,
+			found:   true,
+		},
+		{
+			name:    "время действия правила",
+			visitor: Visitor{Platform: PlatformLinux, Country: "FR", Time: now},
+			want:    promo,
+			found:   true,
+		},
+		{
+			name:    "правило еще не действует",
+			visitor: Visitor{Platform: PlatformLinux, Country: "FR", Time: past.Add(-time.Second)},
+		},
+		{
+			name:    "правило уже не действует",
+			visitor: Visitor{Platform: PlatformLinux, Country: "FR", Time: future},
+		},
+		{
+			name:    "нет подходящих правил",
+			visitor: Visitor{Platform: PlatformOther, Time: now},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, found := Evaluate(rules, tt.visitor)
+			assert.Equal(t, tt.found, found)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	assert.True(t, NeedsCountry(rules))
+	assert.False(t, NeedsCountry(rules[:1]))
+}
+
+func TestNewVisitor(t *testing.T) {
+	now := time.Now()
+	v := NewVisitor("Mozilla/5.0 (iPhone; CPU iPhone OS 16_5 like Mac OS X)", "ru-RU,ru;q=0.9", "ru", now)
+	assert.Equal(t, Visitor{Platform: PlatformIOS, Languages: []string{"ru-ru", "ru"}, Country: "RU", Time: now}, v)
+}
+
+func TestNormalize(t *testing.T) {
+	var (
+		from = time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+		to   = from.Add(24 * time.Hour)
+	)
+	got, err := Normalize([]model.RedirectRule{{
+		Platforms: []string{" iOS "},
+		Languages: []string{"EN-us", "de"},
+		Countries: []string{"de"},
+		NotBefore: &from,
+		NotAfter:  &to,
+		URL:       " https://example.com/ ",
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, []model.RedirectRule{{
+		Platforms: []string{PlatformIOS},
+		Languages: []string{"en-us", "de"},
+		Countries: []string{"DE"},
+		NotBefore: &from,
+		NotAfter:  &to,
+		URL:       "https://example.com/",
+	}}, got)
+
+	invalid := []struct {
+		name string
+		rule model.RedirectRule
+	}{
+		{name: "без адреса назначения", rule: model.RedirectRule{Platforms: []string{PlatformIOS}}},
+		{name: "неизвестная платформа", rule: model.RedirectRule{Platforms: []string{"symbian"}, URL: "https://example.com/"}},
+		{name: "некорректный язык", rule: model.RedirectRule{Languages: []string{"not a tag"}, URL: "https://example.com/"}},
+		{name: "некорректная страна", rule: model.RedirectRule{Countries: []string{"DEU"}, URL: "https://example.com/"}},
+		{name: "пустой интервал времени", rule: model.RedirectRule{NotBefore: &to, NotAfter: &from, URL: "https://example.com/"}},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Normalize([]model.RedirectRule{tt.rule})
+			assert.ErrorIs(t, err, inerr.ErrInvalidRedirectRule)
+		})
+	}
+
+	_, err = Normalize(make([]model.RedirectRule, MaxRules+1))
+	assert.ErrorIs(t, err, inerr.ErrInvalidRedirectRule, "слишком много правил")
+}
@@ -0,0 +1,121 @@
+package rules
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	inerr "github.com/ivanpodgorny/urlshortener/internal/app/errors"
+	"github.com/ivanpodgorny/urlshortener/internal/app/model"
+)
+
+const (
+	// MaxVariants максимальное количество вариантов адреса назначения одного URL.
+	MaxVariants = 10
+	// MaxVariantWeight максимальный вес варианта адреса назначения.
+	MaxVariantWeight = 10000
+	// MaxVariantNameLength максимальная длина имени варианта адреса назначения.
+	MaxVariantNameLength = 64
+)
+
+// ChooseVariant выбирает вариант адреса назначения случайным образом пропорционально
+// весам. Если previous — имя варианта с ненулевым весом, возвращает этот вариант:
+// так вариант, закрепленный за клиентом, не меняется между переходами.
+// rnd возвращает случайное число в интервале [0, n). Если ни у одного варианта
+// нет веса, во втором параметре возвращает false.
+func ChooseVariant(variants []model.Variant, previous string, rnd func(n int) int) (model.Variant, bool) {
+	total := 0
+	for _, v := range variants {
+		if v.Weight <= 0 {
+			continue
+		}
+
+		if previous != "" && v.Name == previous {
+			return v, true
+		}
+		total += v.Weight
+	}
+
+	if total == 0 {
+		return model.Variant{}, false
+	}
+
+	n := rnd(total)
+	for _, v := range variants {
+		if v.Weight <= 0 {
+			continue
+		}
+
+		if n < v.Weight {
+			return v, true
+		}
+		n -= v.Weight
+	}
+
+	return model.Variant{}, false
+}
+
+// NormalizeVariants проверяет варианты адреса назначения и задает имена вариантам
+// без имени по их порядковому номеру. Адреса назначения не проверяются.
+// Если варианты некорректны, возвращает ошибку, оборачивающую errors.ErrInvalidVariant.
+func NormalizeVariants(variants model.Variants) (model.Variants, error) {
+	if len(variants.Items) > MaxVariants {
+		return model.Variants{}, fmt.Errorf("%w: too many variants, maximum is %d", inerr.ErrInvalidVariant, MaxVariants)
+	}
+
+	var (
+		normalized = model.Variants{Sticky: variants.Sticky, Items: make([]model.Variant, 0, len(variants.Items))}
+		names      = make(map[string]bool, len(variants.Items))
+		total      = 0
+	)
+	for i, v := range variants.Items {
+		n := model.Variant{
+			Name:   strings.TrimSpace(v.Name),
+			URL:    strings.TrimSpace(v.URL),
+			Weight: v.Weight,
+		}
+		if n.Name == "" {
+			n.Name = strconv.Itoa(i + 1)
+		}
+
+		if err := checkVariant(n); err != nil {
+			return model.Variants{}, fmt.Errorf("%w: variant %d: %v", inerr.ErrInvalidVariant, i, err)
+		}
+
+		if names[n.Name] {
+			return model.Variants{}, fmt.Errorf("%w: duplicate variant name %q", inerr.ErrInvalidVariant, n.Name)
+		}
+		names[n.Name] = true
+		total += n.Weight
+		normalized.Items = append(normalized.Items, n)
+	}
+
+	if len(normalized.Items) > 0 && total == 0 {
+		return model.Variants{}, fmt.Errorf("%w: at least one variant must have a positive weight", inerr.ErrInvalidVariant)
+	}
+
+	return normalized, nil
+}
+
+func checkVariant(v model.Variant) error {
+	if v.URL == "" {
+		return fmt.Errorf("url is required")
+	}
+
+	if v.Weight < 0 || v.Weight > MaxVariantWeight {
+		return fmt.Errorf("weight must be between 0 and %d", MaxVariantWeight)
+	}
+
+	if len(v.Name) > MaxVariantNameLength {
+		return fmt.Errorf("name is longer than %d characters", MaxVariantNameLength)
+	}
+
+	// Имя варианта сохраняется в cookie, поэтому допускаются только безопасные символы.
+	for _, c := range v.Name {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			return fmt.Errorf("invalid variant name %q", v.Name)
+		}
+	}
+
+	return nil
+}
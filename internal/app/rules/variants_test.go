@@ -0,0 +1,98 @@
+package rules
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	inerr "github.com/ivanpodgorny/urlshortener/internal/app/errors"
+	"github.com/ivanpodgorny/urlshortener/internal/app/model"
+)
+
+func TestChooseVariant(t *testing.T) {
+	variants := []model.Variant{
+		{Name: "a", URL: "https://example.com/a", Weight: 70},
+		{Name: "off", URL: "https://example.com/off", Weight: 0},
+		{Name: "b", URL: "https://example.com/b", Weight: 30},
+	}
+	tests := []struct {
+		name     string
+		previous string
+		n        int
+		want     string
+	}{
+		{name: "начало интервала первого варианта", n: 0, want: "a"},
+		{name: "конец интервала первого варианта", n: 69, want: "a"},
+		{name: "интервал второго варианта", n: 70, want: "b"},
+		{name: "конец интервала второго варианта", n: 99, want: "b"},
+		{name: "закрепленный вариант", previous: "b", n: 0, want: "b"},
+		{name: "закрепленный вариант без веса", previous: "off", n: 0, want: "a"},
+		{name: "неизвестный закрепленный вариант", previous: "c", n: 99, want: "b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ChooseVariant(variants, tt.previous, func(total int) int {
+				assert.Equal(t, 100, total)
+
+				return tt.n
+			})
+			assert.True(t, ok)
+			assert.Equal(t, tt.want, got.Name)
+		})
+	}
+
+	_, ok := ChooseVariant(variants[1:2], "", func(int) int {
+		t.Fatal("нет вариантов с весом")
+
+		return 0
+	})
+	assert.False(t, ok)
+}
+
+func TestNormalizeVariants(t *testing.T) {
+	got, err := NormalizeVariants(model.Variants{
+		Sticky: true,
+		Items: []model.Variant{
+			{Name: " landing-a ", URL: " https://example.com/a ", Weight: 1},
+			{URL: "https://example.com/b", Weight: 0},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, model.Variants{
+		Sticky: true,
+		Items: []model.Variant{
+			{Name: "landing-a", URL: "https://example.com/a", Weight: 1},
+			{Name: "2", URL: "https://example.com/b", Weight: 0},
+		},
+	}, got)
+
+	got, err = NormalizeVariants(model.Variants{})
+	assert.NoError(t, err, "пустой список удаляет варианты")
+	assert.Empty(t, got.Items)
+
+	invalid := []struct {
+		name     string
+		variants []model.Variant
+	}{
+		{name: "без адреса назначения", variants: []model.Variant{{Name: "a", Weight: 1}}},
+		{name: "отрицательный вес", variants: []model.Variant{{URL: "https://example.com/", Weight: -1}}},
+		{name: "слишком большой вес", variants: []model.Variant{{URL: "https://example.com/", Weight: MaxVariantWeight + 1}}},
+		{name: "некорректное имя", variants: []model.Variant{{Name: "a;b", URL: "https://example.com/", Weight: 1}}},
+		{name: "все веса нулевые", variants: []model.Variant{{URL: "https://example.com/", Weight: 0}}},
+		{
+			name: "повторяющееся имя",
+			variants: []model.Variant{
+				{Name: "2", URL: "https://example.com/a", Weight: 1},
+				{URL: "https://example.com/b", Weight: 1},
+			},
+		},
+		{name: "слишком много вариантов", variants: make([]model.Variant, MaxVariants+1)},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NormalizeVariants(model.Variants{Items: tt.variants})
+			assert.ErrorIs(t, err, inerr.ErrInvalidVariant)
+		})
+	}
+}
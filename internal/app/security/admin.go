@@ -0,0 +1,85 @@
+package security
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+)
+
+// AdminKeyHeader заголовок запроса, в котором передается API-ключ администратора.
+const AdminKeyHeader = "X-Admin-Key"
+
+const (
+	adminIDKey          userIDContextKey = "currentAdminID"
+	apiKeyAdminIdentity                  = "api-key"
+)
+
+// ErrNotAdmin ошибка получения данных администратора.
+var ErrNotAdmin = errors.New("user is not admin")
+
+// IdentityProvider интерфейс для получения ID пользователя, выполнившего запрос.
+type IdentityProvider interface {
+	UserIdentifier(ctx context.Context) (string, error)
+}
+
+// AdminAuthorizer реализует методы для проверки прав администратора.
+// Администратором считается пользователь, ID которого входит в список
+// администраторов, или клиент, передавший API-ключ администратора
+// в заголовке X-Admin-Key.
+type AdminAuthorizer struct {
+	identityProvider IdentityProvider
+	adminIDs         map[string]struct{}
+	apiKey           string
+}
+
+// NewAdminAuthorizer возвращает указатель на новый экземпляр AdminAuthorizer.
+// Если apiKey пустой, аутентификация по API-ключу отключена.
+func NewAdminAuthorizer(p IdentityProvider, adminIDs []string, apiKey string) *AdminAuthorizer {
+	a := &AdminAuthorizer{
+		identityProvider: p,
+		adminIDs:         make(map[string]struct{}, len(adminIDs)),
+		apiKey:           apiKey,
+	}
+	for _, id := range adminIDs {
+		a.adminIDs[id] = struct{}{}
+	}
+
+	return a
+}
+
+// Authorize проверяет права администратора у выполнившего запрос клиента
+// и устанавливает его идентификатор в контекст запроса.
+// Если клиент не является администратором, во втором параметре вернется false.
+func (a AdminAuthorizer) Authorize(r *http.Request) (*http.Request, bool) {
+	key := r.Header.Get(AdminKeyHeader)
+	if a.apiKey != "" && key != "" && subtle.ConstantTimeCompare([]byte(key), []byte(a.apiKey)) == 1 {
+		return a.setIdentifier(apiKeyAdminIdentity, r), true
+	}
+
+	id, err := a.identityProvider.UserIdentifier(r.Context())
+	if err != nil {
+		return r, false
+	}
+
+	if _, ok := a.adminIDs[id]; !ok {
+		return r, false
+	}
+
+	return a.setIdentifier(id, r), true
+}
+
+// AdminIdentifier возвращает идентификатор администратора, выполнившего запрос.
+// Для клиентов, аутентифицированных по API-ключу, возвращает "api-key".
+func (a AdminAuthorizer) AdminIdentifier(ctx context.Context) (string, error) {
+	val := ctx.Value(adminIDKey)
+	if val == nil {
+		return "", ErrNotAdmin
+	}
+
+	return val.(string), nil
+}
+
+func (a AdminAuthorizer) setIdentifier(id string, r *http.Request) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), adminIDKey, id))
+}
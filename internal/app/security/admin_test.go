@@ -0,0 +1,49 @@
+package security
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminAuthorizer(t *testing.T) {
+	var (
+		adminID      = "438c4b98-fc98-45cf-ac63-c4a86fbd4ff4"
+		userID       = "02872d15-5047-406c-a989-ee1b07465169"
+		apiKey       = "secret"
+		userProvider = RequestContextUserProvider{}
+		authorizer   = NewAdminAuthorizer(
+			NewAuthenticator(NewCookieTokenStorage(NewHMACTokenCreatorParser(""), CookieAttributes{}), userProvider),
+			[]string{adminID},
+			apiKey,
+		)
+	)
+
+	request := userProvider.SetIdentifier(adminID, httptest.NewRequest("", "/", nil))
+	request, ok := authorizer.Authorize(request)
+	assert.True(t, ok, "администратор из списка")
+	id, err := authorizer.AdminIdentifier(request.Context())
+	require.NoError(t, err)
+	assert.Equal(t, adminID, id)
+
+	request = userProvider.SetIdentifier(userID, httptest.NewRequest("", "/", nil))
+	request, ok = authorizer.Authorize(request)
+	assert.False(t, ok, "пользователь не из списка")
+	_, err = authorizer.AdminIdentifier(request.Context())
+	assert.ErrorIs(t, err, ErrNotAdmin)
+
+	request = httptest.NewRequest("", "/", nil)
+	request.Header.Set(AdminKeyHeader, apiKey)
+	request, ok = authorizer.Authorize(request)
+	assert.True(t, ok, "API-ключ администратора")
+	id, err = authorizer.AdminIdentifier(request.Context())
+	require.NoError(t, err)
+	assert.Equal(t, apiKeyAdminIdentity, id)
+
+	request = httptest.NewRequest("", "/", nil)
+	request.Header.Set(AdminKeyHeader, "wrong")
+	_, ok = authorizer.Authorize(request)
+	assert.False(t, ok, "неверный API-ключ")
+}
@@ -28,6 +28,7 @@ func (a Authenticator) Authenticate(w http.ResponseWriter, r *http.Request) *htt
 	if !ok {
 		id = a.generateID()
 		a.storage.Set(id, w)
+		r = r.WithContext(withNewIdentifier(r.Context()))
 	}
 
 	return a.userProvider.SetIdentifier(id, r)
@@ -38,6 +39,12 @@ func (a Authenticator) UserIdentifier(ctx context.Context) (string, error) {
 	return a.userProvider.Identifier(ctx)
 }
 
+// IsNewIdentifier возвращает true, если идентификатор пользователя сгенерирован
+// при обработке текущего запроса.
+func (a Authenticator) IsNewIdentifier(ctx context.Context) bool {
+	return isNewIdentifier(ctx)
+}
+
 func (a Authenticator) generateID() string {
 	return GenerateUUID()
 }
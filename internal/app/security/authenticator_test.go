@@ -1,6 +1,7 @@
 package security
 
 import (
+	"net/http"
 	"net/http/httptest"
 	"testing"
 
@@ -12,7 +13,11 @@ import (
 func TestAuthenticator(t *testing.T) {
 	var (
 		authenticator = NewAuthenticator(
-			NewCookieTokenStorage(NewHMACTokenCreatorParser("")),
+			NewCookieTokenStorage(NewHMACTokenCreatorParser(""), CookieAttributes{
+				SameSite: http.SameSiteStrictMode,
+				MaxAge:   3600,
+				Secure:   true,
+			}),
 			RequestContextUserProvider{},
 		)
 		request  = httptest.NewRequest("", "/", nil)
@@ -25,13 +30,45 @@ func TestAuthenticator(t *testing.T) {
 	request = authenticator.Authenticate(recorder, request)
 	id, err := authenticator.UserIdentifier(request.Context())
 	assert.NoError(t, err, "создание нового токена для пользователя")
+	assert.True(t, authenticator.IsNewIdentifier(request.Context()), "новый идентификатор")
 
 	resp := recorder.Result()
 	defer require.NoError(t, resp.Body.Close())
 
-	request.AddCookie(resp.Cookies()[0])
+	cookie := resp.Cookies()[0]
+	assert.True(t, cookie.HttpOnly, "атрибуты cookie")
+	assert.True(t, cookie.Secure, "атрибуты cookie")
+	assert.Equal(t, http.SameSiteStrictMode, cookie.SameSite, "атрибуты cookie")
+	assert.Equal(t, 3600, cookie.MaxAge, "атрибуты cookie")
+
+	request = httptest.NewRequest("", "/", nil)
+	request.AddCookie(cookie)
 	request = authenticator.Authenticate(recorder, request)
 	idFromCookies, err := authenticator.UserIdentifier(request.Context())
 	assert.NoError(t, err, "получение существующего токена пользователя")
 	assert.Equal(t, id, idFromCookies, "получение существующего токена пользователя")
+	assert.False(t, authenticator.IsNewIdentifier(request.Context()), "существующий идентификатор")
+}
+
+func TestAuthenticatorWithBearerToken(t *testing.T) {
+	var (
+		creatorParser = NewHMACTokenCreatorParser("")
+		authenticator = NewAuthenticator(
+			NewBearerTokenStorage(creatorParser, NewCookieTokenStorage(creatorParser, CookieAttributes{})),
+			RequestContextUserProvider{},
+		)
+		userID   = "438c4b98-fc98-45cf-ac63-c4a86fbd4ff4"
+		request  = httptest.NewRequest("", "/", nil)
+		recorder = httptest.NewRecorder()
+	)
+
+	request.Header.Set("Authorization", "Bearer "+creatorParser.Create(userID))
+	request = authenticator.Authenticate(recorder, request)
+	id, err := authenticator.UserIdentifier(request.Context())
+	assert.NoError(t, err, "получение пользователя из заголовка Authorization")
+	assert.Equal(t, userID, id, "получение пользователя из заголовка Authorization")
+
+	resp := recorder.Result()
+	defer require.NoError(t, resp.Body.Close())
+	assert.Empty(t, resp.Cookies(), "cookie не устанавливается при наличии токена")
 }
@@ -0,0 +1,131 @@
+package security
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCSRFProtector_Verify(t *testing.T) {
+	tests := []struct {
+		name    string
+		method  string
+		cookie  bool
+		headers map[string]string
+		want    bool
+	}{
+		{
+			name:   "безопасный метод",
+			method: http.MethodGet,
+			cookie: true,
+			headers: map[string]string{
+				"Origin": "https://evil.example",
+			},
+			want: true,
+		},
+		{
+			name:   "запрос без cookie",
+			method: http.MethodPost,
+			headers: map[string]string{
+				"Origin": "https://evil.example",
+			},
+			want: true,
+		},
+		{
+			name:   "запрос с токеном в заголовке Authorization",
+			method: http.MethodDelete,
+			cookie: true,
+			headers: map[string]string{
+				"Origin":        "https://evil.example",
+				"Authorization": "Bearer token",
+			},
+			want: true,
+		},
+		{
+			name:   "запрос не из браузера",
+			method: http.MethodPost,
+			cookie: true,
+			want:   true,
+		},
+		{
+			name:   "запрос с доверенного Origin",
+			method: http.MethodPost,
+			cookie: true,
+			headers: map[string]string{
+				"Origin":         "https://app.example",
+				"Sec-Fetch-Site": "cross-site",
+			},
+			want: true,
+		},
+		{
+			name:   "запрос с того же Origin",
+			method: http.MethodPost,
+			cookie: true,
+			headers: map[string]string{
+				"Origin": "http://example.com",
+			},
+			want: true,
+		},
+		{
+			name:   "Sec-Fetch-Site: same-origin",
+			method: http.MethodPost,
+			cookie: true,
+			headers: map[string]string{
+				"Sec-Fetch-Site": "same-origin",
+			},
+			want: true,
+		},
+		{
+			name:   "Sec-Fetch-Site: cross-site",
+			method: http.MethodDelete,
+			cookie: true,
+			headers: map[string]string{
+				"Sec-Fetch-Site": "cross-site",
+			},
+			want: false,
+		},
+		{
+			name:   "межсайтовый Origin",
+			method: http.MethodDelete,
+			cookie: true,
+			headers: map[string]string{
+				"Origin": "https://evil.example",
+			},
+			want: false,
+		},
+		{
+			name:   "межсайтовый Referer",
+			method: http.MethodPost,
+			cookie: true,
+			headers: map[string]string{
+				"Referer": "https://evil.example/page",
+			},
+			want: false,
+		},
+		{
+			name:   "Origin: null",
+			method: http.MethodPost,
+			cookie: true,
+			headers: map[string]string{
+				"Origin": "null",
+			},
+			want: false,
+		},
+	}
+	p := NewCSRFProtector([]string{"https://app.example/"})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "http://example.com/api/user/urls", nil)
+			if tt.cookie {
+				r.AddCookie(&http.Cookie{Name: userIDCookie, Value: "token"})
+			}
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+
+			assert.Equal(t, tt.want, p.Verify(r))
+		})
+	}
+}
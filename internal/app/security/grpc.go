@@ -2,6 +2,11 @@ package security
 
 import (
 	"context"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+
+	"github.com/ivanpodgorny/urlshortener/internal/app/clientinfo"
 )
 
 // GRPCAuthenticator реализует методы для аутентификации и получения данных
@@ -21,11 +26,18 @@ func NewGRPCAuthenticator(cp TokenCreatorParser, p UserProvider[context.Context,
 
 // Authenticate получает идентификатор пользователя из токена, сохраненного в TokenStorage,
 // и устанавливает его в UserProvider. Если токена не существует, генерирует новый идентификатор,
-// формирует из него токен и сохраняет в TokenStorage.
+// формирует из него токен и сохраняет в TokenStorage. Идентификатор считается новым,
+// если он сгенерирован или передан в метаданных с признаком NewUserIDMetadataKey
+// по соединению внутри процесса; от остальных клиентов признак не принимается.
 func (a GRPCAuthenticator) Authenticate(ctx context.Context) context.Context {
 	id, err := a.userProvider.Identifier(ctx)
 	if err != nil {
 		id = GenerateUUID()
+		ctx = withNewIdentifier(ctx)
+	} else if isInProcessPeer(ctx) {
+		if md, ok := metadata.FromIncomingContext(ctx); ok && len(md.Get(NewUserIDMetadataKey)) != 0 {
+			ctx = withNewIdentifier(ctx)
+		}
 	}
 	ctx = a.userProvider.SetIdentifier(id, ctx)
 
@@ -36,3 +48,16 @@ func (a GRPCAuthenticator) Authenticate(ctx context.Context) context.Context {
 func (a GRPCAuthenticator) UserIdentifier(ctx context.Context) (string, error) {
 	return a.userProvider.Identifier(ctx)
 }
+
+// IsNewIdentifier возвращает true, если идентификатор пользователя сгенерирован
+// при обработке текущего запроса.
+func (a GRPCAuthenticator) IsNewIdentifier(ctx context.Context) bool {
+	return isNewIdentifier(ctx)
+}
+
+// isInProcessPeer возвращает true, если GRPC-запрос получен по соединению внутри процесса.
+func isInProcessPeer(ctx context.Context) bool {
+	p, ok := peer.FromContext(ctx)
+
+	return ok && p.Addr != nil && p.Addr.Network() == clientinfo.InProcessNetwork
+}
@@ -12,8 +12,10 @@ import (
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/test/bufconn"
 
+	"github.com/ivanpodgorny/urlshortener/internal/app/clientinfo"
 	"github.com/ivanpodgorny/urlshortener/internal/proto"
 )
 
@@ -41,10 +43,20 @@ func TestGRPCAuthenticator(t *testing.T) {
 	ctx = authenticator.Authenticate(ctx)
 	id, err := authenticator.UserIdentifier(ctx)
 	assert.NoError(t, err, "создание нового токена для пользователя")
+	assert.True(t, authenticator.IsNewIdentifier(ctx), "новый идентификатор")
 	ctx = authenticator.Authenticate(ctx)
 	existingID, err := authenticator.UserIdentifier(ctx)
 	assert.NoError(t, err, "получение существующего токена пользователя")
 	assert.Equal(t, id, existingID, "получение существующего токена пользователя")
+
+	ctx = authenticator.Authenticate(metadata.NewIncomingContext(context.Background(), metadata.Pairs(UserIDMetadataKey, id)))
+	assert.False(t, authenticator.IsNewIdentifier(ctx), "существующий идентификатор")
+	md := metadata.Pairs(UserIDMetadataKey, id, NewUserIDMetadataKey, "true")
+	ctx = authenticator.Authenticate(metadata.NewIncomingContext(context.Background(), md))
+	assert.False(t, authenticator.IsNewIdentifier(ctx), "признак от внешнего клиента не принимается")
+	ctx = peer.NewContext(context.Background(), &peer.Peer{Addr: &net.UnixAddr{Name: "gateway", Net: clientinfo.InProcessNetwork}})
+	ctx = authenticator.Authenticate(metadata.NewIncomingContext(ctx, md))
+	assert.True(t, authenticator.IsNewIdentifier(ctx), "признак по соединению внутри процесса")
 }
 
 func TestGRPCAuthenticator_Authenticate(t *testing.T) {
@@ -0,0 +1,23 @@
+package security
+
+import (
+	"golang.org/x/crypto/bcrypt"
+)
+
+// MaxPasswordLength максимальная длина пароля в байтах, которую поддерживает bcrypt.
+const MaxPasswordLength = 72
+
+// HashPassword возвращает bcrypt-хеш пароля. Пароль не может быть длиннее MaxPasswordLength байт.
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+
+	return string(hash), nil
+}
+
+// CheckPassword проверяет, что пароль соответствует bcrypt-хешу hash.
+func CheckPassword(hash, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
@@ -0,0 +1,21 @@
+package security
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHashPassword(t *testing.T) {
+	hash, err := HashPassword("пароль")
+	require.NoError(t, err)
+	assert.NotContains(t, hash, "пароль", "пароль не хранится в открытом виде")
+	assert.True(t, CheckPassword(hash, "пароль"), "верный пароль")
+	assert.False(t, CheckPassword(hash, "Пароль"), "неверный пароль")
+	assert.False(t, CheckPassword("", "пароль"), "пустой хеш")
+
+	_, err = HashPassword(strings.Repeat("a", 73))
+	assert.Error(t, err, "слишком длинный пароль")
+}
@@ -1,40 +1,275 @@
 package security
 
 import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rand"
-	"crypto/rsa"
 	"crypto/tls"
 	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
 	"math/big"
+	"net"
+	"os"
+	"strings"
+	"sync"
 	"time"
+
+	"github.com/ivanpodgorny/urlshortener/internal/app/logger"
 )
 
-// CertificateSerialNumber серийный номер сертификата.
-var CertificateSerialNumber = big.NewInt(1)
+// devCertificateValidity срок действия самоподписанного сертификата режима разработки.
+const devCertificateValidity = 365 * 24 * time.Hour
+
+// ErrInvalidTLSVersion неизвестная версия TLS.
+var ErrInvalidTLSVersion = errors.New("tls version must be one of: 1.2, 1.3")
+
+// ErrInvalidCipherSuite неизвестный или небезопасный набор шифров.
+var ErrInvalidCipherSuite = errors.New("unknown or insecure tls cipher suite")
+
+// ErrNoClientCA в файле не найдено ни одного сертификата удостоверяющего центра.
+var ErrNoClientCA = errors.New("no certificates found in client ca file")
+
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// ParseTLSVersion возвращает идентификатор версии TLS по ее номеру: 1.2 или 1.3.
+func ParseTLSVersion(version string) (uint16, error) {
+	v, ok := tlsVersions[version]
+	if !ok {
+		return 0, ErrInvalidTLSVersion
+	}
+
+	return v, nil
+}
+
+// ParseCipherSuites возвращает идентификаторы наборов шифров TLS 1.2 по их
+// названиям, например TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256. Допускаются
+// только наборы, которые Go считает безопасными.
+func ParseCipherSuites(names []string) ([]uint16, error) {
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		id, ok := cipherSuiteID(strings.TrimSpace(name))
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidCipherSuite, name)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+func cipherSuiteID(name string) (uint16, bool) {
+	for _, s := range tls.CipherSuites() {
+		if s.Name == name {
+			return s.ID, true
+		}
+	}
+
+	return 0, false
+}
+
+// TLSOptions параметры TLS сервера.
+type TLSOptions struct {
+	// MinVersion минимальная версия TLS. Нулевое значение — TLS 1.2.
+	MinVersion uint16
+	// CipherSuites разрешенные наборы шифров TLS 1.2. Пустое значение —
+	// наборы по умолчанию. На TLS 1.3 не влияет.
+	CipherSuites []uint16
+	// ClientCAs удостоверяющие центры сертификатов клиентов. Если задано,
+	// сервер принимает только клиентов с сертификатом, подписанным одним из них.
+	ClientCAs *x509.CertPool
+}
+
+// NewServerTLSConfig возвращает конфигурацию TLS сервера, получающего
+// сертификат из c при каждом подключении.
+func NewServerTLSConfig(c *CertificateReloader, opts TLSOptions) *tls.Config {
+	cfg := &tls.Config{
+		GetCertificate: c.GetCertificate,
+		MinVersion:     opts.MinVersion,
+		CipherSuites:   opts.CipherSuites,
+	}
+	if cfg.MinVersion == 0 {
+		cfg.MinVersion = tls.VersionTLS12
+	}
+	if opts.ClientCAs != nil {
+		cfg.ClientCAs = opts.ClientCAs
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return cfg
+}
+
+// LoadCertPool загружает сертификаты удостоверяющих центров в формате PEM из файла path.
+func LoadCertPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, ErrNoClientCA
+	}
+
+	return pool, nil
+}
+
+// CertificateReloader хранит сертификат сервера, загруженный из файлов,
+// и перечитывает его при изменении файлов без перезапуска сервера.
+type CertificateReloader struct {
+	certFile string
+	keyFile  string
+	cert     *tls.Certificate
+	modTime  time.Time
+	mu       sync.RWMutex
+}
+
+// NewCertificateReloader возвращает указатель на новый экземпляр CertificateReloader
+// с сертификатом и закрытым ключом в формате PEM из файлов certFile и keyFile.
+func NewCertificateReloader(certFile, keyFile string) (*CertificateReloader, error) {
+	r := &CertificateReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Reload перечитывает сертификат, если файл сертификата или ключа изменился
+// с момента предыдущей загрузки. При ошибке остается ранее загруженный сертификат.
+func (r *CertificateReloader) Reload() error {
+	modTime, err := latestModTime(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
 
-// CreateCertificate создает TLS сертификат для веб-сервера.
-func CreateCertificate() (cert tls.Certificate, err error) {
-	x509Cert := &x509.Certificate{
-		SerialNumber: CertificateSerialNumber,
-		NotBefore:    time.Now(),
-		NotAfter:     time.Now().AddDate(10, 0, 0),
-		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
-		KeyUsage:     x509.KeyUsageDigitalSignature,
+	r.mu.RLock()
+	unchanged := modTime.Equal(r.modTime)
+	r.mu.RUnlock()
+	if unchanged {
+		return nil
 	}
 
-	privateKey, err := rsa.GenerateKey(rand.Reader, 4096)
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
 	if err != nil {
-		return tls.Certificate{}, err
+		return err
 	}
 
-	certBytes, err := x509.CreateCertificate(rand.Reader, x509Cert, x509Cert, &privateKey.PublicKey, privateKey)
+	r.mu.Lock()
+	r.cert, r.modTime = &cert, modTime
+	r.mu.Unlock()
+
+	return nil
+}
+
+// Watch проверяет изменение файлов сертификата с периодичностью interval
+// и перечитывает их, пока не будет отменен контекст ctx.
+func (r *CertificateReloader) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.Reload(); err != nil {
+				logger.FromContext(ctx).ErrorCtx(ctx, "Error while reloading TLS certificate", "path", r.certFile, "error", err)
+			}
+		}
+	}
+}
+
+// GetCertificate возвращает текущий сертификат. Используется в tls.Config.GetCertificate.
+func (r *CertificateReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.cert, nil
+}
+
+func latestModTime(paths ...string) (time.Time, error) {
+	var latest time.Time
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+
+	return latest, nil
+}
+
+// EnsureDevCertificate создает самоподписанный сертификат для хостов hosts
+// и сохраняет его и закрытый ключ в формате PEM в файлы certFile и keyFile,
+// если хотя бы одного из файлов нет. Существующий сертификат не изменяется,
+// поэтому клиенты, которые ему доверяют, продолжают работать после перезапуска.
+// Предназначен только для разработки.
+func EnsureDevCertificate(certFile, keyFile string, hosts []string) error {
+	_, certErr := os.Stat(certFile)
+	_, keyErr := os.Stat(keyFile)
+	if certErr == nil && keyErr == nil {
+		return nil
+	}
+
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serialNumber,
+		Subject:               pkix.Name{Organization: []string{"urlshortener development"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(devCertificateValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else if host != "" {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	certBytes, err := x509.CreateCertificate(rand.Reader, template, template, &privateKey.PublicKey, privateKey)
 	if err != nil {
-		return tls.Certificate{}, err
+		return err
 	}
 
-	cert.Certificate = append(cert.Certificate, certBytes)
-	cert.PrivateKey = privateKey
-	cert.Leaf = x509Cert
+	keyBytes, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return err
+	}
+
+	if err = writePEM(keyFile, "PRIVATE KEY", keyBytes, 0600); err != nil {
+		return err
+	}
+
+	return writePEM(certFile, "CERTIFICATE", certBytes, 0644)
+}
 
-	return cert, nil
+func writePEM(path, blockType string, data []byte, perm os.FileMode) error {
+	return os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: data}), perm)
 }
@@ -0,0 +1,122 @@
+package security
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTLSVersion(t *testing.T) {
+	v, err := ParseTLSVersion("1.3")
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS13), v)
+	_, err = ParseTLSVersion("1.1")
+	assert.ErrorIs(t, err, ErrInvalidTLSVersion)
+}
+
+func TestParseCipherSuites(t *testing.T) {
+	ids, err := ParseCipherSuites([]string{"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384", " TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256"})
+	assert.NoError(t, err)
+	assert.Equal(t, []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384, tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256}, ids)
+	_, err = ParseCipherSuites([]string{"TLS_RSA_WITH_RC4_128_SHA"})
+	assert.ErrorIs(t, err, ErrInvalidCipherSuite, "небезопасный набор шифров")
+	_, err = ParseCipherSuites([]string{"AES"})
+	assert.ErrorIs(t, err, ErrInvalidCipherSuite, "неизвестный набор шифров")
+}
+
+func TestEnsureDevCertificate(t *testing.T) {
+	var (
+		dir      = t.TempDir()
+		certFile = filepath.Join(dir, "cert.pem")
+		keyFile  = filepath.Join(dir, "key.pem")
+	)
+
+	require.NoError(t, EnsureDevCertificate(certFile, keyFile, []string{"localhost", "127.0.0.1", ""}))
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	require.NoError(t, err)
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	require.NoError(t, err)
+	assert.Equal(t, []string{"localhost"}, leaf.DNSNames)
+	require.Len(t, leaf.IPAddresses, 1)
+	assert.Equal(t, "127.0.0.1", leaf.IPAddresses[0].String())
+	assert.Greater(t, leaf.SerialNumber.BitLen(), 64, "случайный серийный номер")
+	info, err := os.Stat(keyFile)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm(), "права на закрытый ключ")
+
+	before, err := os.ReadFile(certFile)
+	require.NoError(t, err)
+	require.NoError(t, EnsureDevCertificate(certFile, keyFile, []string{"localhost"}))
+	after, err := os.ReadFile(certFile)
+	require.NoError(t, err)
+	assert.Equal(t, before, after, "существующий сертификат не пересоздается")
+}
+
+func TestCertificateReloader(t *testing.T) {
+	var (
+		dir      = t.TempDir()
+		certFile = filepath.Join(dir, "cert.pem")
+		keyFile  = filepath.Join(dir, "key.pem")
+	)
+
+	_, err := NewCertificateReloader(certFile, keyFile)
+	assert.Error(t, err, "файлов нет")
+
+	require.NoError(t, EnsureDevCertificate(certFile, keyFile, []string{"localhost"}))
+	r, err := NewCertificateReloader(certFile, keyFile)
+	require.NoError(t, err)
+	first, err := r.GetCertificate(nil)
+	require.NoError(t, err)
+
+	require.NoError(t, os.Remove(certFile))
+	require.NoError(t, os.Remove(keyFile))
+	require.NoError(t, EnsureDevCertificate(certFile, keyFile, []string{"example.com"}))
+	modTime := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(certFile, modTime, modTime))
+	require.NoError(t, r.Reload())
+	second, err := r.GetCertificate(nil)
+	require.NoError(t, err)
+	assert.NotEqual(t, first.Certificate[0], second.Certificate[0], "сертификат перечитан")
+
+	require.NoError(t, os.WriteFile(keyFile, []byte("broken"), 0600))
+	require.NoError(t, os.Chtimes(keyFile, modTime.Add(time.Minute), modTime.Add(time.Minute)))
+	assert.Error(t, r.Reload())
+	current, err := r.GetCertificate(nil)
+	require.NoError(t, err)
+	assert.Equal(t, second, current, "при ошибке остается прежний сертификат")
+}
+
+func TestNewServerTLSConfig(t *testing.T) {
+	var (
+		dir      = t.TempDir()
+		certFile = filepath.Join(dir, "cert.pem")
+		keyFile  = filepath.Join(dir, "key.pem")
+	)
+	require.NoError(t, EnsureDevCertificate(certFile, keyFile, []string{"localhost"}))
+	r, err := NewCertificateReloader(certFile, keyFile)
+	require.NoError(t, err)
+
+	cfg := NewServerTLSConfig(r, TLSOptions{})
+	assert.Equal(t, uint16(tls.VersionTLS12), cfg.MinVersion)
+	assert.Equal(t, tls.NoClientCert, cfg.ClientAuth)
+
+	_, err = LoadCertPool(keyFile)
+	assert.ErrorIs(t, err, ErrNoClientCA)
+	pool, err := LoadCertPool(certFile)
+	require.NoError(t, err)
+	cfg = NewServerTLSConfig(r, TLSOptions{
+		MinVersion:   tls.VersionTLS13,
+		CipherSuites: []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256},
+		ClientCAs:    pool,
+	})
+	assert.Equal(t, uint16(tls.VersionTLS13), cfg.MinVersion)
+	assert.Equal(t, []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256}, cfg.CipherSuites)
+	assert.Equal(t, tls.RequireAndVerifyClientCert, cfg.ClientAuth)
+	assert.Same(t, pool, cfg.ClientCAs)
+}
@@ -16,8 +16,17 @@ type userIDContextKey string
 const (
 	userIDCookie                  = "identity"
 	userIDKey    userIDContextKey = "currentUserID"
+	newUserIDKey userIDContextKey = "newUserID"
 )
 
+// UserIDMetadataKey ключ метаданных GRPC-запроса с ID пользователя.
+const UserIDMetadataKey = userIDCookie
+
+// NewUserIDMetadataKey ключ метаданных GRPC-запроса с признаком того, что ID
+// пользователя создан при обработке этого запроса. Принимается только
+// по соединениям внутри процесса сети clientinfo.InProcessNetwork.
+const NewUserIDMetadataKey = "identity-new"
+
 // ErrIncorrectHMACSignature ошибка проверки HMAC подписи.
 var ErrIncorrectHMACSignature = errors.New("incorrect hmac signature")
 
@@ -42,14 +51,33 @@ type TokenCreatorParser interface {
 	Parse(token string) (string, error)
 }
 
+// CookieAttributes атрибуты cookie, в которой передается аутентификационный токен.
+type CookieAttributes struct {
+	SameSite http.SameSite
+	MaxAge   int
+	Secure   bool
+}
+
 // CookieTokenStorage реализует методы для передачи и получения токена через Cookie.
 type CookieTokenStorage struct {
 	creatorParser TokenCreatorParser
+	attributes    CookieAttributes
+}
+
+// BearerTokenStorage реализует методы для получения токена из заголовка
+// Authorization в формате "Bearer <token>". Если заголовок не передан,
+// токен получается из вложенного TokenStorage.
+type BearerTokenStorage struct {
+	creatorParser TokenCreatorParser
+	fallback      TokenStorage[*http.Request, http.ResponseWriter]
 }
 
 // NewCookieTokenStorage возвращает указатель на новый экземпляр CookieTokenStorage.
-func NewCookieTokenStorage(p TokenCreatorParser) *CookieTokenStorage {
-	return &CookieTokenStorage{creatorParser: p}
+func NewCookieTokenStorage(p TokenCreatorParser, a CookieAttributes) *CookieTokenStorage {
+	return &CookieTokenStorage{
+		creatorParser: p,
+		attributes:    a,
+	}
 }
 
 // Get получает аутентификационный токен из Cookie.
@@ -70,12 +98,62 @@ func (s *CookieTokenStorage) Get(r *http.Request) (string, bool) {
 // Set устанавливает аутентификационный токен в Cookie.
 func (s *CookieTokenStorage) Set(id string, w http.ResponseWriter) {
 	http.SetCookie(w, &http.Cookie{
-		Name:  userIDCookie,
-		Value: s.creatorParser.Create(id),
-		Path:  "/",
+		Name:     userIDCookie,
+		Value:    s.creatorParser.Create(id),
+		Path:     "/",
+		MaxAge:   s.attributes.MaxAge,
+		Secure:   s.attributes.Secure,
+		HttpOnly: true,
+		SameSite: s.attributes.SameSite,
 	})
 }
 
+// NewBearerTokenStorage возвращает указатель на новый экземпляр BearerTokenStorage.
+func NewBearerTokenStorage(p TokenCreatorParser, s TokenStorage[*http.Request, http.ResponseWriter]) *BearerTokenStorage {
+	return &BearerTokenStorage{
+		creatorParser: p,
+		fallback:      s,
+	}
+}
+
+// Get получает аутентификационный токен из заголовка Authorization.
+// Если заголовок не передан, получает токен из вложенного TokenStorage.
+func (s *BearerTokenStorage) Get(r *http.Request) (string, bool) {
+	token, ok := BearerToken(r)
+	if !ok {
+		return s.fallback.Get(r)
+	}
+
+	id, err := s.creatorParser.Parse(token)
+	if err != nil {
+		return "", false
+	}
+
+	return id, true
+}
+
+// Set устанавливает аутентификационный токен во вложенный TokenStorage.
+func (s *BearerTokenStorage) Set(id string, w http.ResponseWriter) {
+	s.fallback.Set(id, w)
+}
+
+// BearerToken возвращает токен из заголовка Authorization в формате "Bearer <token>".
+func BearerToken(r *http.Request) (string, bool) {
+	scheme, token, found := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
+	}
+
+	return token, true
+}
+
+// HasIdentityCookie проверяет, передана ли в запросе cookie с аутентификационным токеном.
+func HasIdentityCookie(r *http.Request) bool {
+	_, err := r.Cookie(userIDCookie)
+
+	return err == nil
+}
+
 // RequestContextUserProvider реализует методы для получения данных пользвателя из контекста запроса.
 type RequestContextUserProvider struct {
 }
@@ -160,3 +238,16 @@ func (cp *HMACTokenCreatorParser) Parse(token string) (string, error) {
 
 	return "", ErrIncorrectHMACSignature
 }
+
+// withNewIdentifier отмечает в контексте запроса, что ID пользователя создан
+// при обработке этого запроса.
+func withNewIdentifier(ctx context.Context) context.Context {
+	return context.WithValue(ctx, newUserIDKey, true)
+}
+
+// isNewIdentifier возвращает true, если ID пользователя создан при обработке запроса.
+func isNewIdentifier(ctx context.Context) bool {
+	val, _ := ctx.Value(newUserIDKey).(bool)
+
+	return val
+}
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/ivanpodgorny/urlshortener/internal/app/logger"
+	"github.com/ivanpodgorny/urlshortener/internal/app/model"
+)
+
+// Admin реализует методы модерации сокращенных URL администратором.
+// Каждое действие администратора перед выполнением сохраняется в журнал,
+// действие не выполняется, если запись в журнал не удалась.
+type Admin struct {
+	storage AdminStorage
+	auditor AuditRecorder
+}
+
+// AdminStorage интерфейс хранилища, используемого для модерации.
+type AdminStorage interface {
+	GetLink(ctx context.Context, id string) (model.Link, error)
+	FindLinksByURL(ctx context.Context, url string) ([]model.Link, error)
+	GetUserLinks(ctx context.Context, userID string) ([]model.Link, error)
+	SetDisabled(ctx context.Context, id string, disabled bool) error
+	SetUserBanned(ctx context.Context, userID string, banned bool) error
+	AddAdminAction(ctx context.Context, a model.AdminAction) error
+}
+
+// Действия администратора, сохраняемые в журнал.
+const (
+	AdminActionGetLink      = "get_link"
+	AdminActionFindLinks    = "find_links"
+	AdminActionGetUserLinks = "get_user_links"
+	AdminActionDisableLink  = "disable_link"
+	AdminActionEnableLink   = "enable_link"
+	AdminActionBanUser      = "ban_user"
+	AdminActionUnbanUser    = "unban_user"
+)
+
+// NewAdmin возвращает указатель на новый экземпляр Admin.
+// Изменения URL сохраняются также в журнал аудита жизненного цикла URL a.
+func NewAdmin(s AdminStorage, a AuditRecorder) *Admin {
+	return &Admin{
+		storage: s,
+		auditor: a,
+	}
+}
+
+// GetLink возвращает URL с ID id независимо от его владельца и состояния.
+func (a Admin) GetLink(ctx context.Context, adminID, id string) (model.Link, error) {
+	if err := a.log(ctx, adminID, AdminActionGetLink, id); err != nil {
+		return model.Link{}, err
+	}
+
+	return a.storage.GetLink(ctx, id)
+}
+
+// FindLinksByURL возвращает все сокращенные URL с адресом назначения url.
+func (a Admin) FindLinksByURL(ctx context.Context, adminID, url string) ([]model.Link, error) {
+	if err := a.log(ctx, adminID, AdminActionFindLinks, url); err != nil {
+		return nil, err
+	}
+
+	return a.storage.FindLinksByURL(ctx, url)
+}
+
+// GetUserLinks возвращает все URL пользователя userID, в том числе удаленные и заблокированные.
+func (a Admin) GetUserLinks(ctx context.Context, adminID, userID string) ([]model.Link, error) {
+	if err := a.log(ctx, adminID, AdminActionGetUserLinks, userID); err != nil {
+		return nil, err
+	}
+
+	return a.storage.GetUserLinks(ctx, userID)
+}
+
+// SetLinkDisabled блокирует или разблокирует URL с ID id независимо от его владельца.
+func (a Admin) SetLinkDisabled(ctx context.Context, adminID, id string, disabled bool) error {
+	action := AdminActionEnableLink
+	if disabled {
+		action = AdminActionDisableLink
+	}
+
+	if err := a.log(ctx, adminID, action, id); err != nil {
+		return err
+	}
+
+	l, err := a.storage.GetLink(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err = a.storage.SetDisabled(ctx, id, disabled); err != nil {
+		return err
+	}
+
+	if err = a.auditor.Record(ctx, model.AuditActionUpdate, adminID, l.UserID, id); err != nil {
+		logger.FromContext(ctx).ErrorCtx(ctx, "Error while writing audit event", "error", err)
+	}
+
+	return nil
+}
+
+// SetUserBanned блокирует или разблокирует пользователю userID возможность создавать URL.
+func (a Admin) SetUserBanned(ctx context.Context, adminID, userID string, banned bool) error {
+	action := AdminActionUnbanUser
+	if banned {
+		action = AdminActionBanUser
+	}
+
+	if err := a.log(ctx, adminID, action, userID); err != nil {
+		return err
+	}
+
+	return a.storage.SetUserBanned(ctx, userID, banned)
+}
+
+func (a Admin) log(ctx context.Context, adminID, action, target string) error {
+	return a.storage.AddAdminAction(ctx, model.AdminAction{
+		AdminID:   adminID,
+		Action:    action,
+		Target:    target,
+		CreatedAt: time.Now().UTC(),
+	})
+}
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/ivanpodgorny/urlshortener/internal/app/model"
+)
+
+type AdminStorageMock struct {
+	mock.Mock
+}
+
+func (m *AdminStorageMock) GetLink(_ context.Context, id string) (model.Link, error) {
+	args := m.Called(id)
+
+	return args.Get(0).(model.Link), args.Error(1)
+}
+
+func (m *AdminStorageMock) FindLinksByURL(_ context.Context, url string) ([]model.Link, error) {
+	args := m.Called(url)
+
+	return args.Get(0).([]model.Link), args.Error(1)
+}
+
+func (m *AdminStorageMock) GetUserLinks(_ context.Context, userID string) ([]model.Link, error) {
+	args := m.Called(userID)
+
+	return args.Get(0).([]model.Link), args.Error(1)
+}
+
+func (m *AdminStorageMock) SetDisabled(_ context.Context, id string, disabled bool) error {
+	args := m.Called(id, disabled)
+
+	return args.Error(0)
+}
+
+func (m *AdminStorageMock) SetUserBanned(_ context.Context, userID string, banned bool) error {
+	args := m.Called(userID, banned)
+
+	return args.Error(0)
+}
+
+func (m *AdminStorageMock) AddAdminAction(_ context.Context, a model.AdminAction) error {
+	args := m.Called(a.AdminID, a.Action, a.Target)
+
+	return args.Error(0)
+}
+
+func TestAdmin(t *testing.T) {
+	var (
+		adminID = "adminID"
+		userID  = "438c4b98-fc98-45cf-ac63-c4a86fbd4ff4"
+		url     = "https://ya.ru/"
+		link    = model.Link{ID: "1i-CBrzwyMkL", URL: url, UserID: userID}
+		links   = []model.Link{link}
+		ctx     = context.Background()
+		storage = &AdminStorageMock{}
+		auditor = &AuditRecorderMock{}
+		admin   = NewAdmin(storage, auditor)
+	)
+	storage.
+		On("AddAdminAction", adminID, AdminActionGetLink, link.ID).Return(nil).Once().
+		On("GetLink", link.ID).Return(link, nil).Times(3).
+		On("AddAdminAction", adminID, AdminActionFindLinks, url).Return(nil).Once().
+		On("FindLinksByURL", url).Return(links, nil).Once().
+		On("AddAdminAction", adminID, AdminActionGetUserLinks, userID).Return(nil).Once().
+		On("GetUserLinks", userID).Return(links, nil).Once().
+		On("AddAdminAction", adminID, AdminActionDisableLink, link.ID).Return(nil).Once().
+		On("SetDisabled", link.ID, true).Return(nil).Once().
+		On("AddAdminAction", adminID, AdminActionEnableLink, link.ID).Return(nil).Once().
+		On("SetDisabled", link.ID, false).Return(nil).Once().
+		On("AddAdminAction", adminID, AdminActionBanUser, userID).Return(nil).Once().
+		On("SetUserBanned", userID, true).Return(nil).Once().
+		On("AddAdminAction", adminID, AdminActionUnbanUser, userID).Return(nil).Once().
+		On("SetUserBanned", userID, false).Return(nil).Once()
+	auditor.On("Record", model.AuditActionUpdate, adminID, userID, []string{link.ID}).Return(nil).Twice()
+
+	l, err := admin.GetLink(ctx, adminID, link.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, link, l)
+	found, err := admin.FindLinksByURL(ctx, adminID, url)
+	assert.NoError(t, err)
+	assert.Equal(t, links, found)
+	userLinks, err := admin.GetUserLinks(ctx, adminID, userID)
+	assert.NoError(t, err)
+	assert.Equal(t, links, userLinks)
+	assert.NoError(t, admin.SetLinkDisabled(ctx, adminID, link.ID, true))
+	assert.NoError(t, admin.SetLinkDisabled(ctx, adminID, link.ID, false))
+	assert.NoError(t, admin.SetUserBanned(ctx, adminID, userID, true))
+	assert.NoError(t, admin.SetUserBanned(ctx, adminID, userID, false))
+	storage.AssertExpectations(t)
+	auditor.AssertExpectations(t)
+}
+
+func TestAdminActionIsNotPerformedWithoutAuditRecord(t *testing.T) {
+	var (
+		adminID = "adminID"
+		id      = "1i-CBrzwyMkL"
+		ctx     = context.Background()
+		storage = &AdminStorageMock{}
+		admin   = NewAdmin(storage, &AuditRecorderMock{})
+	)
+	storage.On("AddAdminAction", adminID, AdminActionDisableLink, id).Return(errors.New("")).Once()
+
+	assert.Error(t, admin.SetLinkDisabled(ctx, adminID, id, true))
+	storage.AssertExpectations(t)
+	storage.AssertNotCalled(t, "SetDisabled", id, true)
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/ivanpodgorny/urlshortener/internal/app/clientinfo"
+	"github.com/ivanpodgorny/urlshortener/internal/app/logger"
+	"github.com/ivanpodgorny/urlshortener/internal/app/model"
+)
+
+// Auditor реализует методы для ведения журнала аудита жизненного цикла
+// сокращенных URL.
+type Auditor struct {
+	storage   AuditStorage
+	retention time.Duration
+}
+
+// AuditStorage интерфейс хранилища журнала аудита.
+type AuditStorage interface {
+	AddAuditEvents(ctx context.Context, events []model.AuditEvent) error
+	GetAuditEvents(ctx context.Context, f model.AuditFilter) ([]model.AuditEvent, error)
+	DeleteAuditEventsBefore(ctx context.Context, t time.Time) error
+}
+
+// AuditRecorder интерфейс сервиса записи событий в журнал аудита.
+type AuditRecorder interface {
+	Record(ctx context.Context, action, userID, ownerID string, linkIDs ...string) error
+}
+
+// NewAuditor возвращает указатель на новый экземпляр Auditor.
+// Записи журнала старше retention удаляются, если retention больше нуля.
+func NewAuditor(s AuditStorage, retention time.Duration) *Auditor {
+	return &Auditor{
+		storage:   s,
+		retention: retention,
+	}
+}
+
+// Record сохраняет в журнал аудита действие action, выполненное пользователем
+// userID над URL linkIDs, принадлежащими пользователю ownerID.
+// Транспорт и IP-адрес клиента берутся из контекста.
+func (a Auditor) Record(ctx context.Context, action, userID, ownerID string, linkIDs ...string) error {
+	if len(linkIDs) == 0 {
+		return nil
+	}
+
+	var (
+		info   = clientinfo.FromContext(ctx)
+		now    = time.Now().UTC()
+		events = make([]model.AuditEvent, 0, len(linkIDs))
+	)
+	for _, id := range linkIDs {
+		events = append(events, model.AuditEvent{
+			LinkID:    id,
+			Action:    action,
+			UserID:    userID,
+			OwnerID:   ownerID,
+			ClientIP:  info.IP,
+			Transport: info.Transport,
+			CreatedAt: now,
+		})
+	}
+
+	return a.storage.AddAuditEvents(ctx, events)
+}
+
+// GetUserEvents возвращает не более limit записей журнала аудита по URL
+// пользователя userID, начиная с самых новых. Если limit не больше нуля,
+// возвращает все записи.
+func (a Auditor) GetUserEvents(ctx context.Context, userID string, limit int) ([]model.AuditEvent, error) {
+	return a.storage.GetAuditEvents(ctx, model.AuditFilter{
+		OwnerID: userID,
+		Limit:   limit,
+	})
+}
+
+// GetEvents возвращает записи журнала аудита по всем URL, удовлетворяющие
+// фильтру f, начиная с самых новых.
+func (a Auditor) GetEvents(ctx context.Context, f model.AuditFilter) ([]model.AuditEvent, error) {
+	return a.storage.GetAuditEvents(ctx, f)
+}
+
+// DeleteExpired удаляет записи журнала аудита, срок хранения которых истек.
+// Если срок хранения не задан, записи хранятся бессрочно.
+func (a Auditor) DeleteExpired(ctx context.Context) error {
+	if a.retention <= 0 {
+		return nil
+	}
+
+	return a.storage.DeleteAuditEventsBefore(ctx, time.Now().UTC().Add(-a.retention))
+}
+
+// RunRetention удаляет записи журнала аудита с истекшим сроком хранения
+// с периодичностью interval, пока не будет отменен контекст ctx.
+func (a Auditor) RunRetention(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := a.DeleteExpired(ctx); err != nil {
+			logger.FromContext(ctx).ErrorCtx(ctx, "Error while deleting expired audit events", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/ivanpodgorny/urlshortener/internal/app/clientinfo"
+	"github.com/ivanpodgorny/urlshortener/internal/app/model"
+)
+
+type AuditStorageMock struct {
+	mock.Mock
+}
+
+func (m *AuditStorageMock) AddAuditEvents(_ context.Context, events []model.AuditEvent) error {
+	args := m.Called(events)
+
+	return args.Error(0)
+}
+
+func (m *AuditStorageMock) GetAuditEvents(_ context.Context, f model.AuditFilter) ([]model.AuditEvent, error) {
+	args := m.Called(f)
+
+	return args.Get(0).([]model.AuditEvent), args.Error(1)
+}
+
+func (m *AuditStorageMock) DeleteAuditEventsBefore(_ context.Context, t time.Time) error {
+	args := m.Called(t)
+
+	return args.Error(0)
+}
+
+func TestAuditor_Record(t *testing.T) {
+	var (
+		userID  = "userID"
+		ownerID = "ownerID"
+		ids     = []string{"id1", "id2"}
+		ctx     = clientinfo.WithInfo(context.Background(), clientinfo.Info{
+			Transport: clientinfo.TransportGRPC,
+			IP:        "127.0.0.1",
+		})
+		storage = &AuditStorageMock{}
+		auditor = NewAuditor(storage, 0)
+	)
+	storage.On("AddAuditEvents", mock.MatchedBy(func(events []model.AuditEvent) bool {
+		if len(events) != len(ids) {
+			return false
+		}
+
+		for i, e := range events {
+			if e.LinkID != ids[i] || e.Action != model.AuditActionDelete || e.UserID != userID ||
+				e.OwnerID != ownerID || e.ClientIP != "127.0.0.1" || e.Transport != clientinfo.TransportGRPC ||
+				e.CreatedAt.IsZero() {
+				return false
+			}
+		}
+
+		return true
+	})).Return(nil).Once()
+
+	assert.NoError(t, auditor.Record(ctx, model.AuditActionDelete, userID, ownerID, ids...))
+	assert.NoError(t, auditor.Record(ctx, model.AuditActionDelete, userID, ownerID), "пустой список URL")
+	storage.AssertExpectations(t)
+}
+
+func TestAuditor_GetEvents(t *testing.T) {
+	var (
+		userID  = "userID"
+		events  = []model.AuditEvent{{LinkID: "id1", OwnerID: userID}}
+		filter  = model.AuditFilter{LinkID: "id1"}
+		ctx     = context.Background()
+		storage = &AuditStorageMock{}
+		auditor = NewAuditor(storage, 0)
+	)
+	storage.
+		On("GetAuditEvents", model.AuditFilter{OwnerID: userID, Limit: 10}).Return(events, nil).Once().
+		On("GetAuditEvents", filter).Return(events, nil).Once()
+
+	got, err := auditor.GetUserEvents(ctx, userID, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, events, got)
+	got, err = auditor.GetEvents(ctx, filter)
+	assert.NoError(t, err)
+	assert.Equal(t, events, got)
+	storage.AssertExpectations(t)
+}
+
+func TestAuditor_DeleteExpired(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		storage = &AuditStorageMock{}
+	)
+	storage.On("DeleteAuditEventsBefore", mock.MatchedBy(func(t time.Time) bool {
+		return time.Since(t) >= time.Hour && time.Since(t) < 2*time.Hour
+	})).Return(nil).Once()
+
+	assert.NoError(t, NewAuditor(storage, time.Hour).DeleteExpired(ctx))
+	assert.NoError(t, NewAuditor(storage, 0).DeleteExpired(ctx), "бессрочное хранение")
+	storage.AssertExpectations(t)
+}
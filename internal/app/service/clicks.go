@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"golang.org/x/exp/slog"
+
+	"github.com/ivanpodgorny/urlshortener/internal/app/logger"
+	"github.com/ivanpodgorny/urlshortener/internal/app/model"
+)
+
+// ClickBatcher учитывает переходы по URL в фоне: переходы из очереди суммируются
+// по URL и вариантам адреса назначения и сохраняются пакетами, поэтому запись
+// в хранилище не выполняется при каждом переходе.
+type ClickBatcher struct {
+	storage   ClickStorage
+	queue     chan clickKey
+	batchSize int
+}
+
+// ClickStorage интерфейс хранилища счетчиков переходов.
+type ClickStorage interface {
+	AddVariantClicks(ctx context.Context, clicks []model.VariantClicks) error
+}
+
+type clickKey struct {
+	urlID   string
+	variant string
+}
+
+// NewClickBatcher возвращает указатель на новый экземпляр ClickBatcher.
+// В очереди на учет ожидает не более queueSize переходов, пакет сохраняется,
+// когда в нем накопилось batchSize различных пар URL и варианта.
+func NewClickBatcher(s ClickStorage, queueSize, batchSize int) *ClickBatcher {
+	return &ClickBatcher{
+		storage:   s,
+		queue:     make(chan clickKey, queueSize),
+		batchSize: batchSize,
+	}
+}
+
+// Count ставит переход на вариант variant URL id в очередь на учет. Не блокирует
+// вызывающего: если очередь заполнена, переход не учитывается и возвращается false.
+func (b *ClickBatcher) Count(id, variant string) bool {
+	select {
+	case b.queue <- clickKey{urlID: id, variant: variant}:
+		return true
+	default:
+		slog.Warn("Click queue is full, skipping click", "url_id", id)
+
+		return false
+	}
+}
+
+// Run суммирует переходы из очереди и сохраняет их пакетом каждые interval
+// или при заполнении пакета, пока не будет отменен контекст ctx. После отмены
+// контекста сохраняет переходы, оставшиеся в очереди, и возвращает управление.
+func (b *ClickBatcher) Run(ctx context.Context, interval time.Duration) {
+	var (
+		ticker  = time.NewTicker(interval)
+		pending = map[clickKey]int64{}
+	)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			b.drain(pending)
+			// Контекст ctx уже отменен, поэтому последний пакет сохраняется
+			// с новым контекстом. Время остановки ограничивает вызывающий.
+			b.flush(context.Background(), pending)
+
+			return
+		case k := <-b.queue:
+			pending[k]++
+			if len(pending) >= b.batchSize {
+				b.flush(ctx, pending)
+			}
+		case <-ticker.C:
+			b.flush(ctx, pending)
+		}
+	}
+}
+
+// drain переносит в pending переходы, оставшиеся в очереди.
+func (b *ClickBatcher) drain(pending map[clickKey]int64) {
+	for {
+		select {
+		case k := <-b.queue:
+			pending[k]++
+		default:
+			return
+		}
+	}
+}
+
+// flush сохраняет переходы из pending и очищает его. Если сохранить переходы
+// не удалось, они не учитываются.
+func (b *ClickBatcher) flush(ctx context.Context, pending map[clickKey]int64) {
+	if len(pending) == 0 {
+		return
+	}
+
+	clicks := make([]model.VariantClicks, 0, len(pending))
+	for k, count := range pending {
+		clicks = append(clicks, model.VariantClicks{URLID: k.urlID, Variant: k.variant, Clicks: count})
+		delete(pending, k)
+	}
+	sort.Slice(clicks, func(i, j int) bool {
+		if clicks[i].URLID != clicks[j].URLID {
+			return clicks[i].URLID < clicks[j].URLID
+		}
+
+		return clicks[i].Variant < clicks[j].Variant
+	})
+
+	if err := b.storage.AddVariantClicks(ctx, clicks); err != nil {
+		logger.FromContext(ctx).ErrorCtx(ctx, "Error while saving clicks", "clicks", len(clicks), "error", err)
+	}
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/ivanpodgorny/urlshortener/internal/app/model"
+)
+
+type ClickStorageMock struct {
+	mock.Mock
+}
+
+func (m *ClickStorageMock) AddVariantClicks(_ context.Context, clicks []model.VariantClicks) error {
+	args := m.Called(clicks)
+
+	return args.Error(0)
+}
+
+func TestClickBatcher_Run(t *testing.T) {
+	var (
+		storage = &ClickStorageMock{}
+		stored  = make(chan struct{})
+	)
+	storage.
+		On("AddVariantClicks", []model.VariantClicks{
+			{URLID: "id1", Variant: "", Clicks: 2},
+			{URLID: "id1", Variant: "b", Clicks: 1},
+		}).Return(nil).Once().Run(func(mock.Arguments) {
+		close(stored)
+	}).
+		On("AddVariantClicks", []model.VariantClicks{
+			{URLID: "id2", Variant: "", Clicks: 1},
+		}).Return(errors.New("")).Once()
+	b := NewClickBatcher(storage, 4, 2)
+
+	assert.True(t, b.Count("id1", ""))
+	assert.True(t, b.Count("id1", ""))
+	assert.True(t, b.Count("id1", "b"))
+	assert.True(t, b.Count("id2", ""))
+	assert.False(t, b.Count("id3", ""), "очередь заполнена")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		b.Run(ctx, time.Hour)
+		close(done)
+	}()
+
+	select {
+	case <-stored:
+	case <-time.After(time.Second):
+		t.Fatal("заполненный пакет не сохранен")
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run не завершился после отмены контекста")
+	}
+	storage.AssertExpectations(t)
+}
+
+func TestClickBatcher_RunInterval(t *testing.T) {
+	var (
+		storage = &ClickStorageMock{}
+		stored  = make(chan struct{})
+	)
+	storage.On("AddVariantClicks", []model.VariantClicks{{URLID: "id1", Variant: "a", Clicks: 1}}).
+		Return(nil).Once().Run(func(mock.Arguments) {
+		close(stored)
+	})
+	b := NewClickBatcher(storage, 1, 100)
+	assert.True(t, b.Count("id1", "a"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go b.Run(ctx, 10*time.Millisecond)
+
+	select {
+	case <-stored:
+	case <-time.After(time.Second):
+		t.Fatal("переходы не сохранены по таймеру")
+	}
+	storage.AssertExpectations(t)
+}
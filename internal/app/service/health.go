@@ -0,0 +1,300 @@
+package service
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/ivanpodgorny/urlshortener/internal/app/logger"
+	"github.com/ivanpodgorny/urlshortener/internal/app/model"
+)
+
+// HealthChecker периодически проверяет доступность адресов назначения URL
+// и сохраняет результат последней проверки.
+type HealthChecker struct {
+	storage HealthStorage
+	client  HTTPClient
+	opts    HealthCheckOptions
+	hosts   *hostLimiter
+}
+
+// HealthStorage интерфейс хранилища результатов проверки адресов назначения.
+type HealthStorage interface {
+	GetLinksToCheck(ctx context.Context, checkedBefore time.Time, limit int) ([]model.Link, error)
+	SetHealth(ctx context.Context, id string, h model.LinkHealth) error
+	CountHealthStatuses(ctx context.Context) (total int, statuses map[int]int, err error)
+}
+
+// HTTPClient интерфейс HTTP-клиента. Реализуется *http.Client.
+type HTTPClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// HealthCheckOptions параметры проверки адресов назначения.
+type HealthCheckOptions struct {
+	// Interval периодичность повторной проверки адреса назначения.
+	Interval time.Duration
+	// Timeout время ожидания ответа на один запрос.
+	Timeout time.Duration
+	// Concurrency количество одновременных проверок.
+	Concurrency int
+	// HostInterval минимальный промежуток между запросами к одному хосту.
+	HostInterval time.Duration
+	// BatchSize количество URL, которые выбираются из хранилища за один раз.
+	BatchSize int
+}
+
+const healthCheckUserAgent = "Mozilla/5.0 (compatible; urlshortener-healthcheck/1.0)"
+
+// NewHealthChecker возвращает указатель на новый экземпляр HealthChecker.
+// Для проверки адресов, которые задают пользователи, c должен быть защищен
+// от SSRF, например создан metadata.NewClient.
+func NewHealthChecker(s HealthStorage, c HTTPClient, opts HealthCheckOptions) *HealthChecker {
+	if opts.Concurrency < 1 {
+		opts.Concurrency = 1
+	}
+	if opts.BatchSize < 1 {
+		opts.BatchSize = 1
+	}
+
+	return &HealthChecker{
+		storage: s,
+		client:  c,
+		opts:    opts,
+		hosts:   newHostLimiter(opts.HostInterval),
+	}
+}
+
+// Run проверяет адреса назначения, которые пора проверить, с периодичностью period,
+// пока не будет отменен контекст ctx.
+func (c *HealthChecker) Run(ctx context.Context, period time.Duration) {
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+
+	for {
+		if _, err := c.CheckDue(ctx); err != nil && ctx.Err() == nil {
+			logger.FromContext(ctx).ErrorCtx(ctx, "Error while checking link health", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// CheckDue проверяет адреса назначения неудаленных URL, которые не проверялись
+// или проверялись раньше, чем HealthCheckOptions.Interval назад. Возвращает
+// количество проверенных URL.
+func (c *HealthChecker) CheckDue(ctx context.Context) (int, error) {
+	var (
+		checked       int
+		checkedBefore = time.Now().UTC().Add(-c.opts.Interval)
+	)
+	for ctx.Err() == nil {
+		links, err := c.storage.GetLinksToCheck(ctx, checkedBefore, c.opts.BatchSize)
+		if err != nil {
+			return checked, err
+		}
+
+		// Если не удалось сохранить ни одного результата, следующая выборка
+		// вернула бы те же URL.
+		n := c.checkBatch(ctx, links)
+		checked += n
+		if len(links) < c.opts.BatchSize || n == 0 {
+			break
+		}
+	}
+
+	return checked, ctx.Err()
+}
+
+// checkBatch проверяет адреса назначения URL links в HealthCheckOptions.Concurrency
+// потоков и сохраняет результаты. Возвращает количество сохраненных результатов.
+func (c *HealthChecker) checkBatch(ctx context.Context, links []model.Link) int {
+	var (
+		wg      = &sync.WaitGroup{}
+		mu      = &sync.Mutex{}
+		jobs    = make(chan model.Link)
+		checked int
+	)
+	for i := 0; i < c.opts.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for l := range jobs {
+				h := c.Check(ctx, l)
+				if ctx.Err() != nil {
+					continue
+				}
+
+				if err := c.storage.SetHealth(ctx, l.ID, h); err != nil {
+					logger.FromContext(ctx).ErrorCtx(ctx, "Error while saving link health", "url_id", l.ID, "error", err)
+
+					continue
+				}
+
+				mu.Lock()
+				checked++
+				mu.Unlock()
+			}
+		}()
+	}
+
+send:
+	for _, l := range links {
+		select {
+		case <-ctx.Done():
+			break send
+		case jobs <- l:
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	return checked
+}
+
+// Check проверяет доступность адреса назначения URL l. Сначала выполняется запрос HEAD,
+// а если он завершился ошибкой или ответом 4xx или 5xx, — запрос GET, поскольку
+// не все серверы поддерживают HEAD.
+func (c *HealthChecker) Check(ctx context.Context, l model.Link) model.LinkHealth {
+	dest := destinationURL(l)
+	status, err := c.probe(ctx, http.MethodHead, dest)
+	if err != nil || status >= http.StatusBadRequest {
+		status, err = c.probe(ctx, http.MethodGet, dest)
+	}
+
+	h := model.LinkHealth{Status: status, CheckedAt: time.Now().UTC()}
+	if err != nil {
+		h.Error = err.Error()
+	}
+
+	return h
+}
+
+// probe выполняет запрос method к адресу dest и возвращает код ответа.
+// Тело ответа не читается.
+func (c *HealthChecker) probe(ctx context.Context, method, dest string) (int, error) {
+	u, err := url.Parse(dest)
+	if err != nil {
+		return 0, err
+	}
+
+	if err = c.hosts.wait(ctx, u.Hostname()); err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.opts.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("User-Agent", healthCheckUserAgent)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+
+	defer func(body io.ReadCloser) {
+		_ = body.Close()
+	}(resp.Body)
+
+	return resp.StatusCode, nil
+}
+
+// Summary возвращает сводку проверок адресов назначения неудаленных URL.
+func (c *HealthChecker) Summary(ctx context.Context) (model.HealthSummary, error) {
+	total, statuses, err := c.storage.CountHealthStatuses(ctx)
+	if err != nil {
+		return model.HealthSummary{}, err
+	}
+
+	s := model.HealthSummary{Total: total, Unchecked: total, Statuses: statuses}
+	for status, count := range statuses {
+		s.Unchecked -= count
+		if model.IsBrokenStatus(status) {
+			s.Broken += count
+		} else {
+			s.Healthy += count
+		}
+	}
+
+	return s, nil
+}
+
+// destinationURL возвращает адрес назначения URL l для загрузки: канонический вид,
+// а для URL, сохраненных до его появления, — исходный.
+func destinationURL(l model.Link) string {
+	if l.CanonicalURL != "" {
+		return l.CanonicalURL
+	}
+
+	return l.URL
+}
+
+// hostLimiter ограничивает частоту запросов к одному хосту.
+type hostLimiter struct {
+	interval time.Duration
+	next     map[string]time.Time
+	mu       sync.Mutex
+}
+
+// maxTrackedHosts количество хостов, после которого из hostLimiter удаляются
+// хосты, к которым уже можно выполнить запрос.
+const maxTrackedHosts = 1024
+
+func newHostLimiter(interval time.Duration) *hostLimiter {
+	return &hostLimiter{
+		interval: interval,
+		next:     map[string]time.Time{},
+	}
+}
+
+// wait ожидает, пока с предыдущего запроса к хосту host не пройдет интервал,
+// и резервирует время следующего запроса. Возвращает ошибку, если контекст ctx
+// отменен раньше.
+func (l *hostLimiter) wait(ctx context.Context, host string) error {
+	if l.interval <= 0 {
+		return nil
+	}
+
+	now := time.Now()
+	l.mu.Lock()
+	if len(l.next) >= maxTrackedHosts {
+		for h, t := range l.next {
+			if !t.After(now) {
+				delete(l.next, h)
+			}
+		}
+	}
+	at := l.next[host]
+	if at.Before(now) {
+		at = now
+	}
+	l.next[host] = at.Add(l.interval)
+	l.mu.Unlock()
+
+	delay := at.Sub(now)
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
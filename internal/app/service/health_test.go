@@ -0,0 +1,186 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ivanpodgorny/urlshortener/internal/app/model"
+)
+
+type HealthStorageMock struct {
+	mock.Mock
+}
+
+func (m *HealthStorageMock) GetLinksToCheck(_ context.Context, _ time.Time, limit int) ([]model.Link, error) {
+	args := m.Called(limit)
+
+	return args.Get(0).([]model.Link), args.Error(1)
+}
+
+func (m *HealthStorageMock) SetHealth(_ context.Context, id string, h model.LinkHealth) error {
+	args := m.Called(id, h.Status)
+
+	return args.Error(0)
+}
+
+func (m *HealthStorageMock) CountHealthStatuses(context.Context) (int, map[int]int, error) {
+	args := m.Called()
+
+	return args.Int(0), args.Get(1).(map[int]int), args.Error(2)
+}
+
+func TestHealthChecker_Check(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		methods = map[string][]string{}
+	)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		methods["/ok"] = append(methods["/ok"], r.Method)
+		mu.Unlock()
+	})
+	mux.HandleFunc("/no-head", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		methods["/no-head"] = append(methods["/no-head"], r.Method)
+		mu.Unlock()
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/missing", http.StatusFound)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	var (
+		ctx = context.Background()
+		c   = NewHealthChecker(&HealthStorageMock{}, srv.Client(), HealthCheckOptions{Timeout: time.Second})
+	)
+
+	h := c.Check(ctx, model.Link{URL: "ok", CanonicalURL: srv.URL + "/ok"})
+	assert.Equal(t, http.StatusOK, h.Status)
+	assert.Empty(t, h.Error)
+	assert.False(t, h.CheckedAt.IsZero())
+	assert.Equal(t, []string{http.MethodHead}, methods["/ok"], "при успешном HEAD запрос GET не выполняется")
+
+	h = c.Check(ctx, model.Link{URL: srv.URL + "/no-head"})
+	assert.Equal(t, http.StatusOK, h.Status)
+	assert.Equal(t, []string{http.MethodHead, http.MethodGet}, methods["/no-head"], "GET, если HEAD не поддерживается")
+
+	h = c.Check(ctx, model.Link{URL: srv.URL + "/moved"})
+	assert.Equal(t, http.StatusNotFound, h.Status, "код ответа после перенаправления")
+	assert.True(t, h.Broken())
+
+	h = c.Check(ctx, model.Link{URL: "http://127.0.0.1:1/"})
+	assert.Equal(t, 0, h.Status)
+	assert.NotEmpty(t, h.Error, "ответ не получен")
+	assert.True(t, h.Broken())
+}
+
+func TestHealthChecker_CheckDue(t *testing.T) {
+	var (
+		active  int32
+		maxSeen int32
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&active, 1)
+		defer atomic.AddInt32(&active, -1)
+		for {
+			m := atomic.LoadInt32(&maxSeen)
+			if n <= m || atomic.CompareAndSwapInt32(&maxSeen, m, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		if r.URL.Path == "/broken" {
+			w.WriteHeader(http.StatusGone)
+		}
+	}))
+	defer srv.Close()
+
+	var (
+		storage = &HealthStorageMock{}
+		batch   = []model.Link{
+			{ID: "id1", URL: srv.URL + "/1"},
+			{ID: "id2", URL: srv.URL + "/2"},
+			{ID: "id3", URL: srv.URL + "/broken"},
+		}
+	)
+	storage.
+		On("GetLinksToCheck", 3).Return(batch, nil).Once().
+		On("GetLinksToCheck", 3).Return(batch[:1], nil).Once()
+	storage.
+		On("SetHealth", "id1", http.StatusOK).Return(nil).Twice().
+		On("SetHealth", "id2", http.StatusOK).Return(nil).Once().
+		On("SetHealth", "id3", http.StatusGone).Return(errors.New("")).Once()
+	c := NewHealthChecker(storage, srv.Client(), HealthCheckOptions{
+		Interval:    time.Hour,
+		Timeout:     time.Second,
+		Concurrency: 2,
+		BatchSize:   3,
+	})
+
+	checked, err := c.CheckDue(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 3, checked, "ошибка сохранения результата не учитывается")
+	assert.LessOrEqual(t, atomic.LoadInt32(&maxSeen), int32(2), "ограничение количества одновременных проверок")
+	storage.AssertExpectations(t)
+
+	storage = &HealthStorageMock{}
+	storage.On("GetLinksToCheck", 1).Return([]model.Link(nil), errors.New("")).Once()
+	_, err = NewHealthChecker(storage, srv.Client(), HealthCheckOptions{}).CheckDue(context.Background())
+	assert.Error(t, err)
+	storage.AssertExpectations(t)
+}
+
+func TestHealthChecker_Summary(t *testing.T) {
+	storage := &HealthStorageMock{}
+	storage.
+		On("CountHealthStatuses").Return(10, map[int]int{200: 4, 301: 1, 403: 1, 404: 2, 0: 1}, nil).Once().
+		On("CountHealthStatuses").Return(0, map[int]int(nil), errors.New("")).Once()
+	c := NewHealthChecker(storage, nil, HealthCheckOptions{})
+
+	s, err := c.Summary(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, model.HealthSummary{
+		Total:     10,
+		Unchecked: 1,
+		Healthy:   6,
+		Broken:    3,
+		Statuses:  map[int]int{200: 4, 301: 1, 403: 1, 404: 2, 0: 1},
+	}, s)
+
+	_, err = c.Summary(context.Background())
+	assert.Error(t, err)
+	storage.AssertExpectations(t)
+}
+
+func TestHostLimiter(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		l     = newHostLimiter(50 * time.Millisecond)
+		start = time.Now()
+	)
+	require.NoError(t, l.wait(ctx, "a.ru"))
+	require.NoError(t, l.wait(ctx, "b.ru"))
+	assert.Less(t, time.Since(start), 50*time.Millisecond, "разные хосты не ограничивают друг друга")
+
+	require.NoError(t, l.wait(ctx, "a.ru"))
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond, "повторный запрос к хосту ожидает интервал")
+
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+	assert.ErrorIs(t, l.wait(ctx, "a.ru"), context.Canceled)
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"sync"
+
+	"golang.org/x/exp/slog"
+
+	"github.com/ivanpodgorny/urlshortener/internal/app/logger"
+	"github.com/ivanpodgorny/urlshortener/internal/app/model"
+)
+
+// MetadataEnricher загружает метаданные страниц назначения новых URL в фоне
+// и сохраняет их вместе с URL. Если у URL нет заголовка, заголовком становится
+// заголовок страницы назначения.
+type MetadataEnricher struct {
+	storage MetadataStorage
+	fetcher MetadataFetcher
+	queue   chan model.Link
+}
+
+// MetadataStorage интерфейс хранилища метаданных страниц назначения.
+type MetadataStorage interface {
+	SetMetadata(ctx context.Context, id string, md model.Metadata, title string) error
+}
+
+// MetadataFetcher интерфейс загрузки метаданных страницы назначения.
+type MetadataFetcher interface {
+	Fetch(ctx context.Context, url string) (model.Metadata, error)
+}
+
+// NewMetadataEnricher возвращает указатель на новый экземпляр MetadataEnricher.
+// В очереди на загрузку метаданных ожидает не более queueSize URL.
+func NewMetadataEnricher(s MetadataStorage, f MetadataFetcher, queueSize int) *MetadataEnricher {
+	return &MetadataEnricher{
+		storage: s,
+		fetcher: f,
+		queue:   make(chan model.Link, queueSize),
+	}
+}
+
+// Enqueue ставит URL l в очередь на загрузку метаданных. Не блокирует вызывающего:
+// если очередь заполнена, URL пропускается и возвращается false.
+func (e *MetadataEnricher) Enqueue(l model.Link) bool {
+	select {
+	case e.queue <- l:
+		return true
+	default:
+		slog.Warn("Metadata queue is full, skipping link", "url_id", l.ID)
+
+		return false
+	}
+}
+
+// Run загружает метаданные URL из очереди в workers потоков, пока не будет
+// отменен контекст ctx. Возвращает управление после завершения всех потоков.
+func (e *MetadataEnricher) Run(ctx context.Context, workers int) {
+	wg := &sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case l := <-e.queue:
+					if err := e.Enrich(ctx, l); err != nil {
+						logger.FromContext(ctx).ErrorCtx(ctx, "Error while fetching metadata", "url_id", l.ID, "error", err)
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// Enrich загружает метаданные страницы назначения URL l и сохраняет их.
+// Заголовком URL становится заголовок страницы, а если его нет — значение тега og:title.
+func (e *MetadataEnricher) Enrich(ctx context.Context, l model.Link) error {
+	md, err := e.fetcher.Fetch(ctx, destinationURL(l))
+	if err != nil {
+		return err
+	}
+
+	title := md.Title
+	if title == "" {
+		title = md.OGTitle
+	}
+
+	return e.storage.SetMetadata(ctx, l.ID, md, normalizeTitle(title))
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/ivanpodgorny/urlshortener/internal/app/model"
+)
+
+type MetadataStorageMock struct {
+	mock.Mock
+}
+
+func (m *MetadataStorageMock) SetMetadata(_ context.Context, id string, md model.Metadata, title string) error {
+	args := m.Called(id, md, title)
+
+	return args.Error(0)
+}
+
+type MetadataFetcherMock struct {
+	mock.Mock
+}
+
+func (m *MetadataFetcherMock) Fetch(_ context.Context, url string) (model.Metadata, error) {
+	args := m.Called(url)
+
+	return args.Get(0).(model.Metadata), args.Error(1)
+}
+
+func TestMetadataEnricher_Enrich(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		url     = "https://ya.ru/"
+		md      = model.Metadata{Title: "  Яндекс\n — поиск ", OGTitle: "Яндекс"}
+		ogOnly  = model.Metadata{OGTitle: "Яндекс"}
+		storage = &MetadataStorageMock{}
+		fetcher = &MetadataFetcherMock{}
+	)
+	fetcher.
+		On("Fetch", url).Return(md, nil).Once().
+		On("Fetch", url).Return(ogOnly, nil).Once().
+		On("Fetch", url).Return(model.Metadata{}, errors.New("")).Once()
+	storage.
+		On("SetMetadata", "id1", md, "Яндекс — поиск").Return(nil).Once().
+		On("SetMetadata", "id2", ogOnly, "Яндекс").Return(nil).Once()
+	e := NewMetadataEnricher(storage, fetcher, 1)
+
+	assert.NoError(t, e.Enrich(ctx, model.Link{ID: "id1", URL: "ya.ru", CanonicalURL: url}), "загружается канонический URL")
+	assert.NoError(t, e.Enrich(ctx, model.Link{ID: "id2", URL: url}), "заголовок из og:title")
+	assert.Error(t, e.Enrich(ctx, model.Link{ID: "id3", URL: url}), "ошибка загрузки")
+	fetcher.AssertExpectations(t)
+	storage.AssertExpectations(t)
+}
+
+func TestMetadataEnricher_Run(t *testing.T) {
+	var (
+		url     = "https://ya.ru/"
+		md      = model.Metadata{Title: "Яндекс"}
+		storage = &MetadataStorageMock{}
+		fetcher = &MetadataFetcherMock{}
+		stored  = make(chan struct{})
+	)
+	fetcher.On("Fetch", url).Return(md, nil).Once()
+	storage.On("SetMetadata", "id1", md, md.Title).Return(nil).Once().Run(func(mock.Arguments) {
+		close(stored)
+	})
+	e := NewMetadataEnricher(storage, fetcher, 1)
+
+	assert.True(t, e.Enqueue(model.Link{ID: "id1", URL: url}))
+	assert.False(t, e.Enqueue(model.Link{ID: "id2", URL: url}), "очередь заполнена")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		e.Run(ctx, 2)
+		close(done)
+	}()
+
+	select {
+	case <-stored:
+	case <-time.After(time.Second):
+		t.Fatal("метаданные не сохранены")
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run не завершился после отмены контекста")
+	}
+	fetcher.AssertExpectations(t)
+	storage.AssertExpectations(t)
+}
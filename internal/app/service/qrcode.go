@@ -0,0 +1,202 @@
+package service
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	inerr "github.com/ivanpodgorny/urlshortener/internal/app/errors"
+	"github.com/ivanpodgorny/urlshortener/internal/app/model"
+	"github.com/ivanpodgorny/urlshortener/internal/app/qrcode"
+)
+
+// Ограничения и значения по умолчанию параметров изображения QR-кода.
+const (
+	DefaultQRSize   = 256
+	MaxQRSize       = 2048
+	DefaultQRMargin = 4
+	MaxQRMargin     = 16
+	DefaultQRLevel  = "M"
+)
+
+var qrContentTypes = map[string]string{
+	model.QRFormatPNG: "image/png",
+	model.QRFormatSVG: "image/svg+xml",
+}
+
+// QRCodes реализует генерацию QR-кодов сокращенных URL. Изображение зависит только
+// от ID URL и параметров изображения, поэтому готовые изображения кешируются в памяти.
+type QRCodes struct {
+	storage QRCodeStorage
+	baseURL string
+	cache   *lruCache
+}
+
+// QRCodeStorage интерфейс хранилища для получения URL по ID независимо от его состояния.
+type QRCodeStorage interface {
+	GetLink(ctx context.Context, id string) (model.Link, error)
+}
+
+// NewQRCodes возвращает указатель на новый экземпляр QRCodes. QR-коды кодируют
+// сокращенные URL с базовым URL b, в кеше хранится не более cacheSize изображений.
+// Если cacheSize не больше 0, изображения не кешируются.
+func NewQRCodes(s QRCodeStorage, b string, cacheSize int) *QRCodes {
+	return &QRCodes{
+		storage: s,
+		baseURL: b,
+		cache:   newLRUCache(cacheSize),
+	}
+}
+
+// Generate возвращает изображение QR-кода сокращенного URL с ID id пользователя userID
+// и MIME-тип изображения. Нулевые значения параметров opts заменяются значениями
+// по умолчанию: PNG размером DefaultQRSize пикселей со свободной зоной DefaultQRMargin
+// модулей и уровнем коррекции ошибок DefaultQRLevel.
+// Если параметры некорректны, возвращает ошибку, оборачивающую errors.ErrInvalidQROptions.
+// Если URL не найден или принадлежит другому пользователю, возвращает ошибку errors.ErrURLNotFound,
+// если URL удален — errors.ErrURLIsDeleted.
+func (q *QRCodes) Generate(ctx context.Context, id, userID string, opts model.QROptions) ([]byte, string, error) {
+	opts, level, err := normalizeQROptions(opts)
+	if err != nil {
+		return nil, "", err
+	}
+
+	l, err := q.storage.GetLink(ctx, id)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if l.UserID != userID {
+		return nil, "", inerr.ErrURLNotFound
+	}
+
+	if l.Deleted {
+		return nil, "", inerr.ErrURLIsDeleted
+	}
+
+	margin := *opts.Margin
+	key := fmt.Sprintf("%s/%s/%d/%d/%s", id, opts.Format, opts.Size, margin, opts.Level)
+	if data, ok := q.cache.get(key); ok {
+		return data, qrContentTypes[opts.Format], nil
+	}
+
+	code, err := qrcode.Encode([]byte(q.baseURL+"/"+id), level)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var data []byte
+	switch opts.Format {
+	case model.QRFormatSVG:
+		data = code.SVG(opts.Size, margin)
+	default:
+		data, err = code.PNG(opts.Size, margin)
+		if errors.Is(err, qrcode.ErrSizeTooSmall) {
+			return nil, "", fmt.Errorf("%w: size %d is too small for %d modules", inerr.ErrInvalidQROptions, opts.Size, code.Size()+2*margin)
+		}
+
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	q.cache.add(key, data)
+
+	return data, qrContentTypes[opts.Format], nil
+}
+
+func normalizeQROptions(opts model.QROptions) (model.QROptions, qrcode.Level, error) {
+	opts.Format = strings.ToLower(opts.Format)
+	if opts.Format == "" {
+		opts.Format = model.QRFormatPNG
+	}
+	if _, ok := qrContentTypes[opts.Format]; !ok {
+		return opts, 0, fmt.Errorf("%w: unknown format %q", inerr.ErrInvalidQROptions, opts.Format)
+	}
+
+	if opts.Size == 0 {
+		opts.Size = DefaultQRSize
+	}
+	if opts.Size < 0 || opts.Size > MaxQRSize {
+		return opts, 0, fmt.Errorf("%w: size must be between 1 and %d", inerr.ErrInvalidQROptions, MaxQRSize)
+	}
+
+	margin := DefaultQRMargin
+	if opts.Margin != nil {
+		margin = *opts.Margin
+	}
+	if margin < 0 || margin > MaxQRMargin {
+		return opts, 0, fmt.Errorf("%w: margin must be between 0 and %d", inerr.ErrInvalidQROptions, MaxQRMargin)
+	}
+	opts.Margin = &margin
+
+	if opts.Level == "" {
+		opts.Level = DefaultQRLevel
+	}
+	level, err := qrcode.ParseLevel(opts.Level)
+	if err != nil {
+		return opts, 0, fmt.Errorf("%w: %v", inerr.ErrInvalidQROptions, err)
+	}
+	opts.Level = level.String()
+
+	return opts, level, nil
+}
+
+// lruCache потокобезопасный кеш, вытесняющий давно не использованные значения.
+type lruCache struct {
+	capacity int
+	items    map[string]*list.Element
+	order    *list.List
+	mu       sync.Mutex
+}
+
+type lruEntry struct {
+	key string
+	val []byte
+}
+
+func newLRUCache(capacity int) *lruCache {
+	return &lruCache{
+		capacity: capacity,
+		items:    map[string]*list.Element{},
+		order:    list.New(),
+	}
+}
+
+func (c *lruCache) get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(e)
+
+	return e.Value.(*lruEntry).val, true
+}
+
+func (c *lruCache) add(key string, val []byte) {
+	if c.capacity <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.items[key]; ok {
+		e.Value.(*lruEntry).val = val
+		c.order.MoveToFront(e)
+
+		return
+	}
+
+	c.items[key] = c.order.PushFront(&lruEntry{key: key, val: val})
+	if c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*lruEntry).key)
+	}
+}
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	inerr "github.com/ivanpodgorny/urlshortener/internal/app/errors"
+	"github.com/ivanpodgorny/urlshortener/internal/app/model"
+)
+
+type QRCodeStorageMock struct {
+	mock.Mock
+}
+
+func (m *QRCodeStorageMock) GetLink(_ context.Context, id string) (model.Link, error) {
+	args := m.Called(id)
+
+	return args.Get(0).(model.Link), args.Error(1)
+}
+
+func TestQRCodes_Generate(t *testing.T) {
+	var (
+		userID  = "438c4b98-fc98-45cf-ac63-c4a86fbd4ff4"
+		link    = model.Link{ID: "1i-CBrzwyMkL", URL: "https://ya.ru/", UserID: userID}
+		deleted = model.Link{ID: "deleted", URL: "https://ya.ru/", UserID: userID, Deleted: true}
+		ctx     = context.Background()
+		storage = &QRCodeStorageMock{}
+	)
+	storage.
+		On("GetLink", link.ID).Return(link, nil).Times(4).
+		On("GetLink", deleted.ID).Return(deleted, nil).Once().
+		On("GetLink", "notFound").Return(model.Link{}, inerr.ErrURLNotFound).Once()
+	q := NewQRCodes(storage, "http://localhost:8080", 10)
+
+	data, contentType, err := q.Generate(ctx, link.ID, userID, model.QROptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "image/png", contentType)
+	img, err := png.Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, DefaultQRSize, img.Bounds().Dx())
+
+	cached, _, err := q.Generate(ctx, link.ID, userID, model.QROptions{Format: "PNG", Size: DefaultQRSize, Margin: intPtr(DefaultQRMargin), Level: "m"})
+	assert.NoError(t, err)
+	assert.Equal(t, data, cached, "одинаковые параметры после нормализации")
+
+	data, contentType, err = q.Generate(ctx, link.ID, userID, model.QROptions{Format: model.QRFormatSVG, Size: 512, Margin: intPtr(0), Level: "H"})
+	assert.NoError(t, err)
+	assert.Equal(t, "image/svg+xml", contentType)
+	assert.Contains(t, string(data), `width="512" height="512" viewBox="0 0 33 33"`)
+
+	_, _, err = q.Generate(ctx, link.ID, "otherUser", model.QROptions{})
+	assert.ErrorIs(t, err, inerr.ErrURLNotFound)
+
+	_, _, err = q.Generate(ctx, deleted.ID, userID, model.QROptions{})
+	assert.ErrorIs(t, err, inerr.ErrURLIsDeleted)
+
+	_, _, err = q.Generate(ctx, "notFound", userID, model.QROptions{})
+	assert.ErrorIs(t, err, inerr.ErrURLNotFound)
+
+	storage.AssertExpectations(t)
+}
+
+func TestQRCodes_GenerateInvalidOptions(t *testing.T) {
+	var (
+		storage = &QRCodeStorageMock{}
+		link    = model.Link{ID: "1i-CBrzwyMkL", URL: "https://ya.ru/", UserID: "userID"}
+	)
+	storage.On("GetLink", link.ID).Return(link, nil).Once()
+	q := NewQRCodes(storage, "http://localhost:8080", 0)
+
+	for _, opts := range []model.QROptions{
+		{Format: "gif"},
+		{Size: -1},
+		{Size: MaxQRSize + 1},
+		{Margin: intPtr(-1)},
+		{Margin: intPtr(MaxQRMargin + 1)},
+		{Level: "X"},
+	} {
+		_, _, err := q.Generate(context.Background(), link.ID, link.UserID, opts)
+		assert.ErrorIs(t, err, inerr.ErrInvalidQROptions, "%+v", opts)
+	}
+
+	_, _, err := q.Generate(context.Background(), link.ID, link.UserID, model.QROptions{Size: 20})
+	assert.ErrorIs(t, err, inerr.ErrInvalidQROptions, "размер меньше числа модулей")
+
+	storage.AssertExpectations(t)
+}
+
+func TestLRUCache(t *testing.T) {
+	c := newLRUCache(2)
+	c.add("a", []byte("a"))
+	c.add("b", []byte("b"))
+	_, ok := c.get("a")
+	assert.True(t, ok)
+
+	c.add("c", []byte("c"))
+	_, ok = c.get("b")
+	assert.False(t, ok, "вытесняется давно не использованное значение")
+	val, ok := c.get("a")
+	assert.True(t, ok)
+	assert.Equal(t, []byte("a"), val)
+
+	disabled := newLRUCache(0)
+	disabled.add("a", []byte("a"))
+	_, ok = disabled.get("a")
+	assert.False(t, ok)
+}
+
+func intPtr(v int) *int {
+	return &v
+}
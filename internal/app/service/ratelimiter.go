@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/ivanpodgorny/urlshortener/internal/app/logger"
+	"github.com/ivanpodgorny/urlshortener/internal/app/model"
+)
+
+// RateLimiter реализует ограничение частоты запросов по алгоритму token bucket
+// отдельно для каждого пользователя и каждого IP-адреса клиента.
+type RateLimiter struct {
+	storage RateLimitStorage
+	limits  map[string]model.RateLimit
+	byIP    bool
+}
+
+// RateLimitStorage интерфейс хранилища состояния ограничений частоты запросов.
+type RateLimitStorage interface {
+	TakeToken(ctx context.Context, key string, l model.RateLimit, now time.Time) (bool, time.Duration, error)
+	DeleteFullBuckets(ctx context.Context, now time.Time) error
+}
+
+// NewRateLimiter возвращает указатель на новый экземпляр RateLimiter.
+// limits задает ограничения для групп запросов model.RateLimitBudget*,
+// byIP включает проверку ограничений Allow по IP-адресу клиента.
+func NewRateLimiter(s RateLimitStorage, limits map[string]model.RateLimit, byIP bool) *RateLimiter {
+	return &RateLimiter{
+		storage: s,
+		limits:  limits,
+		byIP:    byIP,
+	}
+}
+
+// Allow проверяет, не превышено ли ограничение частоты запросов группы budget
+// для пользователя userID и IP-адреса ip. Если ограничение превышено хотя бы
+// для одного из них, возвращает false и время, через которое можно повторить запрос.
+// Пустые userID и ip не проверяются, ip не проверяется при отключенной проверке по IP-адресу.
+func (r RateLimiter) Allow(ctx context.Context, budget, userID, ip string) (bool, time.Duration, error) {
+	if !r.byIP {
+		ip = ""
+	}
+
+	return r.allow(ctx, budget, r.key(budget, "user", userID), r.key(budget, "ip", ip))
+}
+
+// AllowLink проверяет, не превышено ли ограничение частоты запросов группы budget
+// к URL с идентификатором linkID независимо от пользователя и IP-адреса клиента.
+func (r RateLimiter) AllowLink(ctx context.Context, budget, linkID string) (bool, time.Duration, error) {
+	return r.allow(ctx, budget, r.key(budget, "link", linkID))
+}
+
+// RunSweep удаляет из хранилища заполненные корзины, которые не отличаются
+// от новых, с периодичностью interval, пока не будет отменен контекст ctx.
+func (r RateLimiter) RunSweep(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := r.storage.DeleteFullBuckets(ctx, time.Now()); err != nil {
+			logger.FromContext(ctx).ErrorCtx(ctx, "Error while deleting full rate limit buckets", "error", err)
+		}
+	}
+}
+
+func (r RateLimiter) allow(ctx context.Context, budget string, keys ...string) (bool, time.Duration, error) {
+	l, ok := r.limits[budget]
+	if !ok || l.IsZero() {
+		return true, 0, nil
+	}
+
+	var (
+		now     = time.Now()
+		allowed = true
+		wait    time.Duration
+	)
+	for _, key := range keys {
+		if key == "" {
+			continue
+		}
+
+		ok, retryAfter, err := r.storage.TakeToken(ctx, key, l, now)
+		if err != nil {
+			return true, 0, err
+		}
+
+		if !ok {
+			allowed = false
+			if retryAfter > wait {
+				wait = retryAfter
+			}
+		}
+	}
+
+	return allowed, wait, nil
+}
+
+func (r RateLimiter) key(budget, kind, val string) string {
+	if val == "" {
+		return ""
+	}
+
+	return budget + ":" + kind + ":" + val
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/ivanpodgorny/urlshortener/internal/app/model"
+)
+
+type RateLimitStorageMock struct {
+	mock.Mock
+}
+
+func (m *RateLimitStorageMock) TakeToken(_ context.Context, key string, l model.RateLimit, _ time.Time) (bool, time.Duration, error) {
+	args := m.Called(key, l)
+
+	return args.Bool(0), args.Get(1).(time.Duration), args.Error(2)
+}
+
+func (m *RateLimitStorageMock) DeleteFullBuckets(_ context.Context, _ time.Time) error {
+	return m.Called().Error(0)
+}
+
+func TestRateLimiter_Allow(t *testing.T) {
+	var (
+		userID  = "userID"
+		ip      = "127.0.0.1"
+		limit   = model.RateLimit{Rate: 1, Burst: 10}
+		ctx     = context.Background()
+		storage = &RateLimitStorageMock{}
+		limiter = NewRateLimiter(storage, map[string]model.RateLimit{
+			model.RateLimitBudgetCreate: limit,
+			model.RateLimitBudgetRead:   {},
+		}, true)
+	)
+	storage.
+		On("TakeToken", "create:user:"+userID, limit).Return(true, time.Duration(0), nil).Once().
+		On("TakeToken", "create:ip:"+ip, limit).Return(true, time.Duration(0), nil).Once().
+		On("TakeToken", "create:user:"+userID, limit).Return(false, time.Second, nil).Once().
+		On("TakeToken", "create:ip:"+ip, limit).Return(false, 2*time.Second, nil).Once().
+		On("TakeToken", "create:ip:"+ip, limit).Return(false, time.Second, nil).Once().
+		On("TakeToken", "create:user:"+userID, limit).Return(false, time.Duration(0), errors.New("")).Once()
+
+	allowed, retryAfter, err := limiter.Allow(ctx, model.RateLimitBudgetCreate, userID, ip)
+	assert.NoError(t, err)
+	assert.True(t, allowed, "ограничение не превышено")
+	assert.Zero(t, retryAfter)
+
+	allowed, retryAfter, err = limiter.Allow(ctx, model.RateLimitBudgetCreate, userID, ip)
+	assert.NoError(t, err)
+	assert.False(t, allowed, "ограничение превышено")
+	assert.Equal(t, 2*time.Second, retryAfter, "наибольшее время ожидания")
+
+	allowed, _, err = limiter.Allow(ctx, model.RateLimitBudgetCreate, "", ip)
+	assert.NoError(t, err)
+	assert.False(t, allowed, "проверка только по IP-адресу")
+
+	allowed, _, err = limiter.Allow(ctx, model.RateLimitBudgetCreate, userID, ip)
+	assert.Error(t, err, "ошибка хранилища")
+	assert.True(t, allowed, "при ошибке хранилища запрос пропускается")
+
+	allowed, _, err = limiter.Allow(ctx, model.RateLimitBudgetRead, userID, ip)
+	assert.NoError(t, err)
+	assert.True(t, allowed, "ограничение отключено")
+	allowed, _, err = limiter.Allow(ctx, model.RateLimitBudgetDelete, userID, ip)
+	assert.NoError(t, err)
+	assert.True(t, allowed, "ограничение не задано")
+
+	storage.AssertExpectations(t)
+}
+
+func TestRateLimiter_AllowWithoutIP(t *testing.T) {
+	var (
+		userID  = "userID"
+		ip      = "127.0.0.1"
+		limit   = model.RateLimit{Rate: 1, Burst: 10}
+		ctx     = context.Background()
+		storage = &RateLimitStorageMock{}
+		limiter = NewRateLimiter(storage, map[string]model.RateLimit{model.RateLimitBudgetCreate: limit}, false)
+	)
+	storage.On("TakeToken", "create:user:"+userID, limit).Return(false, time.Second, nil).Once()
+
+	allowed, retryAfter, err := limiter.Allow(ctx, model.RateLimitBudgetCreate, userID, ip)
+	assert.NoError(t, err)
+	assert.False(t, allowed, "проверка только по пользователю")
+	assert.Equal(t, time.Second, retryAfter)
+
+	allowed, _, err = limiter.Allow(ctx, model.RateLimitBudgetCreate, "", ip)
+	assert.NoError(t, err)
+	assert.True(t, allowed, "проверка по IP-адресу отключена")
+	storage.AssertExpectations(t)
+}
+
+func TestRateLimiter_AllowLink(t *testing.T) {
+	var (
+		linkID  = "linkID"
+		limit   = model.RateLimit{Rate: 0.1, Burst: 5}
+		ctx     = context.Background()
+		storage = &RateLimitStorageMock{}
+		limiter = NewRateLimiter(storage, map[string]model.RateLimit{model.RateLimitBudgetPassword: limit}, false)
+	)
+	storage.
+		On("TakeToken", "password:link:"+linkID, limit).Return(true, time.Duration(0), nil).Once().
+		On("TakeToken", "password:link:"+linkID, limit).Return(false, 10*time.Second, nil).Once()
+
+	allowed, _, err := limiter.AllowLink(ctx, model.RateLimitBudgetPassword, linkID)
+	assert.NoError(t, err)
+	assert.True(t, allowed, "ограничение не превышено")
+	allowed, retryAfter, err := limiter.AllowLink(ctx, model.RateLimitBudgetPassword, linkID)
+	assert.NoError(t, err)
+	assert.False(t, allowed, "ограничение превышено")
+	assert.Equal(t, 10*time.Second, retryAfter)
+	allowed, _, err = limiter.AllowLink(ctx, model.RateLimitBudgetCreate, linkID)
+	assert.NoError(t, err)
+	assert.True(t, allowed, "ограничение для группы не задано")
+	storage.AssertExpectations(t)
+}
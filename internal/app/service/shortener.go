@@ -2,66 +2,549 @@ package service
 
 import (
 	"context"
+	"math/rand"
+	"strings"
+	"time"
 
+	"github.com/ivanpodgorny/urlshortener/internal/app/clientinfo"
+	inerr "github.com/ivanpodgorny/urlshortener/internal/app/errors"
+	"github.com/ivanpodgorny/urlshortener/internal/app/logger"
+	"github.com/ivanpodgorny/urlshortener/internal/app/model"
+	"github.com/ivanpodgorny/urlshortener/internal/app/passthrough"
+	"github.com/ivanpodgorny/urlshortener/internal/app/rules"
 	"github.com/ivanpodgorny/urlshortener/internal/app/security"
 )
 
+// MaxTitleLength максимальная длина заголовка страницы назначения в символах.
+// Более длинный заголовок обрезается.
+const MaxTitleLength = 300
+
 // Shortener реализует методы для сокращения и получения URL.
 type Shortener struct {
-	storage Storage
+	storage       Storage
+	auditor       AuditRecorder
+	policy        DestinationChecker
+	canonicalizer Canonicalizer
+	limiter       LinkRateLimiter
+	geoIP         GeoIP
+	enricher      LinkEnricher
+	clicks        ClickCounter
+	random        func(n int) int
 }
 
 // Storage интерфейс хранилища сокращенных URL.
 type Storage interface {
-	Add(ctx context.Context, id string, url string, userID string) (string, error)
-	Get(ctx context.Context, id string) (string, error)
-	GetAllUser(ctx context.Context, userID string) map[string]string
-	DeleteBatch(ctx context.Context, urlIDs []string, userID string) error
-	GetStat(context.Context) (urlCount int, usersCount int, err error)
+	Add(ctx context.Context, l model.Link) (string, error)
+	Get(ctx context.Context, id string) (model.Link, error)
+	GetUserLinks(ctx context.Context, userID string) ([]model.Link, error)
+	DeleteBatch(ctx context.Context, urlIDs []string, userID string) ([]string, error)
+	RestoreBatch(ctx context.Context, urlIDs []string, userID string) ([]string, error)
+	GetStats(ctx context.Context, opts model.StatsOptions) (model.Stats, error)
+	IsUserBanned(ctx context.Context, userID string) (bool, error)
+	GetRules(ctx context.Context, id, userID string) ([]model.RedirectRule, error)
+	SetRules(ctx context.Context, id, userID string, rules []model.RedirectRule) error
+	GetVariants(ctx context.Context, id, userID string) (model.Variants, error)
+	SetVariants(ctx context.Context, id, userID string, variants model.Variants) error
+	GetVariantClicks(ctx context.Context, id, userID string) (map[string]int64, error)
+	GetRedirectOptions(ctx context.Context, id, userID string) (model.RedirectOptions, error)
+	SetRedirectOptions(ctx context.Context, id, userID string, opts model.RedirectOptions) error
+}
+
+// DestinationChecker интерфейс проверки безопасности адреса назначения сокращаемого URL.
+type DestinationChecker interface {
+	Check(ctx context.Context, url string) error
+}
+
+// Canonicalizer интерфейс приведения URL к каноническому виду, который используется
+// для поиска дубликатов и редиректа, и к отображаемому виду для списков URL.
+type Canonicalizer interface {
+	Canonicalize(url string) (string, error)
+	Display(canonicalURL string) string
+}
+
+// LinkRateLimiter интерфейс ограничения частоты запросов к отдельному URL.
+type LinkRateLimiter interface {
+	AllowLink(ctx context.Context, budget, linkID string) (bool, time.Duration, error)
+}
+
+// GeoIP интерфейс определения страны клиента по IP-адресу.
+type GeoIP interface {
+	Country(ctx context.Context, ip string) (string, error)
+}
+
+// LinkEnricher интерфейс фоновой загрузки метаданных страниц назначения новых URL.
+type LinkEnricher interface {
+	Enqueue(l model.Link) bool
+}
+
+// ClickCounter интерфейс фонового учета переходов по URL.
+type ClickCounter interface {
+	Count(id, variant string) bool
 }
 
 // NewShortener возвращает указатель на новый экземпляр Shortener.
-func NewShortener(s Storage) *Shortener {
-	return &Shortener{storage: s}
+// Создание, удаление и восстановление URL сохраняются в журнал аудита a,
+// адреса назначения новых URL проверяются политикой p, дубликаты определяются
+// по каноническому виду URL, который возвращает c. Частота попыток ввода пароля
+// к URL ограничивается l. Страна клиента для правил условного редиректа
+// определяется g. Если g равен nil, правила, проверяющие страну, не применяются.
+// Новые URL передаются e для загрузки метаданных страниц назначения, если e не равен nil.
+// Переходы по URL учитываются k в фоне. Если k равен nil, переходы не учитываются.
+func NewShortener(
+	s Storage,
+	a AuditRecorder,
+	p DestinationChecker,
+	c Canonicalizer,
+	l LinkRateLimiter,
+	g GeoIP,
+	e LinkEnricher,
+	k ClickCounter,
+) *Shortener {
+	return &Shortener{
+		storage:       s,
+		auditor:       a,
+		policy:        p,
+		canonicalizer: c,
+		limiter:       l,
+		geoIP:         g,
+		enricher:      e,
+		clicks:        k,
+		random:        rand.Intn,
+	}
 }
 
 // Shorten принимает строку URL, генерирует для нее случайный текстовый ID,
-// сохраняет ID и URL в Storage и возвращает сгенерированный ID.
-// Если URL уже сохранен в Storage, новая запись не добавляется и во втором параметре вернется false.
+// сохраняет ID, URL, его канонический и отображаемый вид в Storage и возвращает сгенерированный ID.
+// Если URL с таким же каноническим видом уже сохранен в Storage пользователем userID,
+// новая запись не добавляется, возвращается ID существующей и во втором параметре вернется false.
 // Если сгенерированный ID уже существует в Storage, возвращает ошибку.
-func (s Shortener) Shorten(ctx context.Context, url string, userID string) (string, bool, error) {
+// Если пользователь заблокирован администратором, возвращает ошибку errors.ErrUserIsBanned.
+// Если адрес назначения запрещен политикой безопасности, возвращает ошибку,
+// оборачивающую errors.ErrDestinationRejected.
+// Если в opts задан пароль, URL сохраняется с его хешем и не считается дубликатом
+// других URL. Если пароль длиннее security.MaxPasswordLength, возвращает ошибку
+// errors.ErrPasswordTooLong. Заголовок страницы назначения из opts сохраняется
+// в одну строку длиной не более MaxTitleLength символов. Если в opts задан срок
+// действия, URL также не считается дубликатом других URL; если срок уже истек,
+// возвращает ошибку errors.ErrInvalidExpiry.
+func (s Shortener) Shorten(ctx context.Context, url string, userID string, opts model.LinkOptions) (string, bool, error) {
+	ctx, span := tracer.Start(ctx, "Shortener.Shorten")
+	defer span.End()
+
+	if len(opts.Password) > security.MaxPasswordLength {
+		return "", false, inerr.ErrPasswordTooLong
+	}
+
+	now := time.Now().UTC()
+	if !opts.ExpiresAt.IsZero() && !opts.ExpiresAt.After(now) {
+		return "", false, inerr.ErrInvalidExpiry
+	}
+
+	banned, err := s.storage.IsUserBanned(ctx, userID)
+	if err != nil {
+		return "", false, err
+	}
+
+	if banned {
+		return "", false, inerr.ErrUserIsBanned
+	}
+
+	canonicalURL, err := s.canonicalizer.Canonicalize(url)
+	if err != nil {
+		return "", false, err
+	}
+
+	if err = s.policy.Check(ctx, canonicalURL); err != nil {
+		return "", false, err
+	}
+
 	id, err := security.GenerateRandomString(16)
 	if err != nil {
 		return "", false, err
 	}
 
-	storedID, err := s.storage.Add(ctx, id, url, userID)
+	l := model.Link{
+		ID:           id,
+		URL:          url,
+		CanonicalURL: canonicalURL,
+		DisplayURL:   s.canonicalizer.Display(canonicalURL),
+		Title:        normalizeTitle(opts.Title),
+		UserID:       userID,
+		CreatedAt:    now,
+	}
+	if !opts.ExpiresAt.IsZero() {
+		l.ExpiresAt = opts.ExpiresAt.UTC()
+	}
+	if opts.Password != "" {
+		if l.PasswordHash, err = security.HashPassword(opts.Password); err != nil {
+			return "", false, err
+		}
+	}
+
+	storedID, err := s.storage.Add(ctx, l)
 	if err != nil {
 		return "", false, err
 	}
 
-	return storedID, storedID == id, nil
+	inserted := storedID == id
+	if inserted {
+		s.audit(ctx, model.AuditActionCreate, userID, id)
+		if s.enricher != nil {
+			s.enricher.Enqueue(l)
+		}
+	}
+
+	return storedID, inserted, nil
+}
+
+// Get принимает текстовый ID и возвращает канонический вид URL, сохраненного в Storage с этим ID.
+// Если у URL есть правила условного редиректа, возвращает адрес назначения первого правила,
+// которому соответствует клиент. Если ни одно правило не подошло, а у URL есть варианты
+// адреса назначения, возвращает вариант, выбранный случайно пропорционально весам.
+// Если варианты закрепляются за клиентом, повторно выбирается вариант req.Variant.
+// Переход ставится в очередь на учет в счетчике выбранного варианта, переход на основной
+// адрес — в счетчике с пустым именем варианта. Код ответа при переходе возвращается, если он задан для URL.
+// Суффикс пути, параметры запроса и значения плейсхолдеров из req переносятся в адрес
+// назначения в соответствии с параметрами формирования адреса назначения URL. Если
+// суффикс пути передан, а его перенос не включен, возвращает ошибку errors.ErrPathSuffixNotAllowed,
+// если суффикс некорректен — errors.ErrInvalidPathSuffix.
+// Если URL защищен паролем, а пароль в req пустой, возвращает ошибку errors.ErrPasswordRequired,
+// если пароль неверный — errors.ErrInvalidPassword. При превышении частоты попыток ввода
+// пароля возвращает *errors.PasswordAttemptsError.
+func (s Shortener) Get(ctx context.Context, id string, req model.RedirectRequest) (model.Redirect, error) {
+	ctx, span := tracer.Start(ctx, "Shortener.Get")
+	defer span.End()
+
+	_, res, err := s.resolve(ctx, id, req)
+	if err != nil {
+		return model.Redirect{}, err
+	}
+
+	if s.clicks != nil {
+		s.clicks.Count(id, res.Variant)
+	}
+
+	return res, nil
 }
 
-// Get принимает текстовый ID и возвращает URL, сохраненный в Storage с этим ID.
-func (s Shortener) Get(ctx context.Context, id string) (string, error) {
-	return s.storage.Get(ctx, id)
+// Preview возвращает сведения для страницы предпросмотра перехода по URL с ID id:
+// адрес назначения, выбранный так же, как в Get, заголовок страницы назначения
+// и время создания URL. Переход не учитывается.
+// Возвращает те же ошибки, что и Get.
+func (s Shortener) Preview(ctx context.Context, id string, req model.RedirectRequest) (model.Preview, error) {
+	ctx, span := tracer.Start(ctx, "Shortener.Preview")
+	defer span.End()
+
+	l, res, err := s.resolve(ctx, id, req)
+	if err != nil {
+		return model.Preview{}, err
+	}
+
+	return model.Preview{
+		URL:        res.URL,
+		DisplayURL: s.canonicalizer.Display(res.URL),
+		Title:      l.Title,
+		CreatedAt:  l.CreatedAt,
+	}, nil
 }
 
-// GetAllUser принимает идентификатор пользователя
-// и возвращает сокращенные им URL и их ID в формате
-//
-//	{ID: URL, ...}
-func (s Shortener) GetAllUser(ctx context.Context, userID string) map[string]string {
-	return s.storage.GetAllUser(ctx, userID)
+// resolve возвращает URL с ID id и адрес назначения, выбранный для перехода по нему.
+func (s Shortener) resolve(ctx context.Context, id string, req model.RedirectRequest) (model.Link, model.Redirect, error) {
+	l, err := s.storage.Get(ctx, id)
+	if err != nil {
+		return model.Link{}, model.Redirect{}, err
+	}
+
+	if err = s.checkPassword(ctx, l, req.Password); err != nil {
+		return model.Link{}, model.Redirect{}, err
+	}
+
+	res := model.Redirect{URL: l.CanonicalURL}
+	if u, ok := s.evaluateRules(ctx, l, req); ok {
+		res = model.Redirect{URL: u}
+	} else if v, ok := s.chooseVariant(l, req); ok {
+		res = model.Redirect{URL: v.URL, Variant: v.Name, Sticky: l.Variants.Sticky}
+	}
+	res.Status = l.RedirectOptions.Status
+
+	res.URL, err = passthrough.Apply(res.URL, l.RedirectOptions, passthrough.Params{
+		ID:    l.ID,
+		Path:  req.Path,
+		Query: req.Query,
+	})
+	if err != nil {
+		return model.Link{}, model.Redirect{}, err
+	}
+
+	return l, res, nil
+}
+
+func (s Shortener) chooseVariant(l model.Link, req model.RedirectRequest) (model.Variant, bool) {
+	previous := ""
+	if l.Variants.Sticky {
+		previous = req.Variant
+	}
+
+	return rules.ChooseVariant(l.Variants.Items, previous, s.random)
+}
+
+func (s Shortener) evaluateRules(ctx context.Context, l model.Link, req model.RedirectRequest) (string, bool) {
+	if len(l.Rules) == 0 {
+		return "", false
+	}
+
+	var (
+		country = ""
+		err     error
+	)
+	if s.geoIP != nil && rules.NeedsCountry(l.Rules) {
+		if country, err = s.geoIP.Country(ctx, clientinfo.FromContext(ctx).IP); err != nil {
+			logger.FromContext(ctx).ErrorCtx(ctx, "Error while resolving client country", "error", err)
+		}
+	}
+
+	return rules.Evaluate(l.Rules, rules.NewVisitor(req.UserAgent, req.AcceptLanguage, country, time.Now()))
+}
+
+func (s Shortener) checkPassword(ctx context.Context, l model.Link, password string) error {
+	if l.PasswordHash == "" {
+		return nil
+	}
+
+	if password == "" {
+		return inerr.ErrPasswordRequired
+	}
+
+	allowed, retryAfter, err := s.limiter.AllowLink(ctx, model.RateLimitBudgetPassword, l.ID)
+	if err != nil {
+		logger.FromContext(ctx).ErrorCtx(ctx, "Error while checking password attempts limit", "error", err)
+	} else if !allowed {
+		return &inerr.PasswordAttemptsError{RetryAfter: retryAfter}
+	}
+
+	if !security.CheckPassword(l.PasswordHash, password) {
+		return inerr.ErrInvalidPassword
+	}
+
+	return nil
+}
+
+// GetRules возвращает правила условного редиректа URL пользователя userID.
+// Если URL не найден или принадлежит другому пользователю, возвращает ошибку errors.ErrURLNotFound.
+func (s Shortener) GetRules(ctx context.Context, id, userID string) ([]model.RedirectRule, error) {
+	ctx, span := tracer.Start(ctx, "Shortener.GetRules")
+	defer span.End()
+
+	return s.storage.GetRules(ctx, id, userID)
+}
+
+// SetRules заменяет правила условного редиректа URL пользователя userID. Правила
+// проверяются в заданном порядке, адреса назначения приводятся к каноническому виду
+// и проверяются политикой безопасности так же, как при сокращении URL.
+// Если правило некорректно, возвращает ошибку, оборачивающую errors.ErrInvalidRedirectRule,
+// если адрес назначения запрещен — оборачивающую errors.ErrDestinationRejected.
+// Если URL не найден или принадлежит другому пользователю, возвращает ошибку errors.ErrURLNotFound.
+func (s Shortener) SetRules(ctx context.Context, id, userID string, redirectRules []model.RedirectRule) error {
+	ctx, span := tracer.Start(ctx, "Shortener.SetRules")
+	defer span.End()
+
+	normalized, err := rules.Normalize(redirectRules)
+	if err != nil {
+		return err
+	}
+
+	for i := range normalized {
+		if normalized[i].URL, err = s.canonicalizer.Canonicalize(normalized[i].URL); err != nil {
+			return err
+		}
+
+		if err = s.policy.Check(ctx, normalized[i].URL); err != nil {
+			return err
+		}
+	}
+
+	if err = s.storage.SetRules(ctx, id, userID, normalized); err != nil {
+		return err
+	}
+	s.audit(ctx, model.AuditActionUpdate, userID, id)
+
+	return nil
+}
+
+// GetVariantStats возвращает варианты адреса назначения URL пользователя userID
+// с количеством переходов на каждый из них.
+// Если URL не найден или принадлежит другому пользователю, возвращает ошибку errors.ErrURLNotFound.
+func (s Shortener) GetVariantStats(ctx context.Context, id, userID string) (model.VariantStats, error) {
+	ctx, span := tracer.Start(ctx, "Shortener.GetVariantStats")
+	defer span.End()
+
+	variants, err := s.storage.GetVariants(ctx, id, userID)
+	if err != nil {
+		return model.VariantStats{}, err
+	}
+
+	clicks, err := s.storage.GetVariantClicks(ctx, id, userID)
+	if err != nil {
+		return model.VariantStats{}, err
+	}
+
+	stats := model.VariantStats{Sticky: variants.Sticky, Items: make([]model.VariantStat, 0, len(variants.Items))}
+	for _, v := range variants.Items {
+		stats.Items = append(stats.Items, model.VariantStat{Variant: v, Clicks: clicks[v.Name]})
+	}
+
+	return stats, nil
+}
+
+// SetVariants заменяет варианты адреса назначения URL пользователя userID. Адреса назначения
+// приводятся к каноническому виду и проверяются политикой безопасности так же, как при
+// сокращении URL. Переходы считаются по именам вариантов, поэтому счетчики варианта
+// сохраняются, пока не изменится его имя. Пустой список удаляет варианты.
+// Если варианты некорректны, возвращает ошибку, оборачивающую errors.ErrInvalidVariant,
+// если адрес назначения запрещен — оборачивающую errors.ErrDestinationRejected.
+// Если URL не найден или принадлежит другому пользователю, возвращает ошибку errors.ErrURLNotFound.
+func (s Shortener) SetVariants(ctx context.Context, id, userID string, variants model.Variants) error {
+	ctx, span := tracer.Start(ctx, "Shortener.SetVariants")
+	defer span.End()
+
+	normalized, err := rules.NormalizeVariants(variants)
+	if err != nil {
+		return err
+	}
+
+	for i := range normalized.Items {
+		if normalized.Items[i].URL, err = s.canonicalizer.Canonicalize(normalized.Items[i].URL); err != nil {
+			return err
+		}
+
+		if err = s.policy.Check(ctx, normalized.Items[i].URL); err != nil {
+			return err
+		}
+	}
+
+	if err = s.storage.SetVariants(ctx, id, userID, normalized); err != nil {
+		return err
+	}
+	s.audit(ctx, model.AuditActionUpdate, userID, id)
+
+	return nil
+}
+
+// GetRedirectOptions возвращает параметры формирования адреса назначения URL пользователя userID.
+// Если URL не найден или принадлежит другому пользователю, возвращает ошибку errors.ErrURLNotFound.
+func (s Shortener) GetRedirectOptions(ctx context.Context, id, userID string) (model.RedirectOptions, error) {
+	ctx, span := tracer.Start(ctx, "Shortener.GetRedirectOptions")
+	defer span.End()
+
+	return s.storage.GetRedirectOptions(ctx, id, userID)
+}
+
+// SetRedirectOptions заменяет параметры формирования адреса назначения и код ответа при переходе
+// по URL пользователя userID.
+// Если параметры некорректны, возвращает ошибку, оборачивающую errors.ErrInvalidRedirectOptions.
+// Если URL не найден или принадлежит другому пользователю, возвращает ошибку errors.ErrURLNotFound.
+func (s Shortener) SetRedirectOptions(ctx context.Context, id, userID string, opts model.RedirectOptions) error {
+	ctx, span := tracer.Start(ctx, "Shortener.SetRedirectOptions")
+	defer span.End()
+
+	if err := passthrough.Validate(opts); err != nil {
+		return err
+	}
+
+	if err := s.storage.SetRedirectOptions(ctx, id, userID, opts); err != nil {
+		return err
+	}
+	s.audit(ctx, model.AuditActionUpdate, userID, id)
+
+	return nil
+}
+
+// GetUserLinks возвращает сокращенные пользователем userID URL, кроме удаленных,
+// в порядке создания.
+func (s Shortener) GetUserLinks(ctx context.Context, userID string) ([]model.Link, error) {
+	ctx, span := tracer.Start(ctx, "Shortener.GetUserLinks")
+	defer span.End()
+
+	links, err := s.storage.GetUserLinks(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	active := make([]model.Link, 0, len(links))
+	for _, l := range links {
+		if !l.Deleted {
+			active = append(active, l)
+		}
+	}
+
+	return active, nil
 }
 
 // DeleteBatch принимает массив идентификаторов URL и выполняет их удаление из Storage.
+// Удаляются только URL, принадлежащие пользователю userID.
 func (s Shortener) DeleteBatch(ctx context.Context, urlIDs []string, userID string) error {
-	return s.storage.DeleteBatch(ctx, urlIDs, userID)
+	ctx, span := tracer.Start(ctx, "Shortener.DeleteBatch")
+	defer span.End()
+
+	deleted, err := s.storage.DeleteBatch(ctx, urlIDs, userID)
+	s.audit(ctx, model.AuditActionDelete, userID, deleted...)
+
+	return err
+}
+
+// RestoreBatch принимает массив идентификаторов удаленных URL и выполняет их восстановление.
+// Восстанавливаются только URL, принадлежащие пользователю userID.
+func (s Shortener) RestoreBatch(ctx context.Context, urlIDs []string, userID string) error {
+	ctx, span := tracer.Start(ctx, "Shortener.RestoreBatch")
+	defer span.End()
+
+	restored, err := s.storage.RestoreBatch(ctx, urlIDs, userID)
+	s.audit(ctx, model.AuditActionRestore, userID, restored...)
+
+	return err
 }
 
-// GetStat возвращает количество сокращённых URL в сервисе и количество пользователей в сервисе.
-func (s Shortener) GetStat(ctx context.Context) (int, int, error) {
-	return s.storage.GetStat(ctx)
+// GetStats возвращает статистику использования сервиса: количество URL, созданных
+// за каждый из последних days дней, включая текущий, по UTC, и не более top URL
+// и доменов адресов назначения в рейтингах. Дни без созданных URL включаются
+// в статистику с нулевым количеством.
+func (s Shortener) GetStats(ctx context.Context, days, top int) (model.Stats, error) {
+	ctx, span := tracer.Start(ctx, "Shortener.GetStats")
+	defer span.End()
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	since := today.AddDate(0, 0, 1-days)
+	stats, err := s.storage.GetStats(ctx, model.StatsOptions{Since: since, Top: top})
+	if err != nil {
+		return model.Stats{}, err
+	}
+
+	created := make(map[string]int, len(stats.CreatedPerDay))
+	for _, d := range stats.CreatedPerDay {
+		created[d.Date] = d.Count
+	}
+	stats.CreatedPerDay = make([]model.DayCount, 0, days)
+	for day := since; !day.After(today); day = day.AddDate(0, 0, 1) {
+		date := day.Format(model.DayFormat)
+		stats.CreatedPerDay = append(stats.CreatedPerDay, model.DayCount{Date: date, Count: created[date]})
+	}
+
+	return stats, nil
+}
+
+// normalizeTitle заменяет последовательности пробельных символов в заголовке одним пробелом
+// и обрезает его до MaxTitleLength символов.
+func normalizeTitle(title string) string {
+	title = strings.Join(strings.Fields(title), " ")
+	if r := []rune(title); len(r) > MaxTitleLength {
+		title = strings.TrimSpace(string(r[:MaxTitleLength]))
+	}
+
+	return title
+}
+
+func (s Shortener) audit(ctx context.Context, action, userID string, urlIDs ...string) {
+	if err := s.auditor.Record(ctx, action, userID, userID, urlIDs...); err != nil {
+		logger.FromContext(ctx).ErrorCtx(ctx, "Error while writing audit event", "error", err)
+	}
 }
@@ -3,86 +3,251 @@ package service
 import (
 	"context"
 	"errors"
+	"net/http"
+	"net/url"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 
+	"github.com/ivanpodgorny/urlshortener/internal/app/clientinfo"
 	inerr "github.com/ivanpodgorny/urlshortener/internal/app/errors"
+	"github.com/ivanpodgorny/urlshortener/internal/app/model"
+	"github.com/ivanpodgorny/urlshortener/internal/app/security"
 )
 
 type StorageMock struct {
 	mock.Mock
 }
 
-func (m *StorageMock) Add(_ context.Context, id string, url string, userID string) (string, error) {
-	args := m.Called(url, userID)
+func (m *StorageMock) Add(_ context.Context, l model.Link) (string, error) {
+	args := m.Called(l.URL, l.CanonicalURL, l.DisplayURL, l.UserID, l.PasswordHash != "")
 
-	return id, args.Error(0)
+	return l.ID, args.Error(0)
 }
 
-func (m *StorageMock) Get(_ context.Context, id string) (string, error) {
+func (m *StorageMock) Get(_ context.Context, id string) (model.Link, error) {
 	args := m.Called(id)
 
-	return args.String(0), args.Error(1)
+	return args.Get(0).(model.Link), args.Error(1)
 }
 
-func (m *StorageMock) GetAllUser(_ context.Context, userID string) map[string]string {
+func (m *StorageMock) GetUserLinks(_ context.Context, userID string) ([]model.Link, error) {
 	args := m.Called(userID)
 
-	return args.Get(0).(map[string]string)
+	return args.Get(0).([]model.Link), args.Error(1)
 }
 
-func (m *StorageMock) DeleteBatch(_ context.Context, urlIDs []string, userID string) error {
+func (m *StorageMock) DeleteBatch(_ context.Context, urlIDs []string, userID string) ([]string, error) {
 	args := m.Called(urlIDs, userID)
 
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *StorageMock) RestoreBatch(_ context.Context, urlIDs []string, userID string) ([]string, error) {
+	args := m.Called(urlIDs, userID)
+
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *StorageMock) GetStats(_ context.Context, opts model.StatsOptions) (model.Stats, error) {
+	args := m.Called(opts)
+
+	return args.Get(0).(model.Stats), args.Error(1)
+}
+
+func (m *StorageMock) IsUserBanned(_ context.Context, userID string) (bool, error) {
+	args := m.Called(userID)
+
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *StorageMock) GetRules(_ context.Context, id, userID string) ([]model.RedirectRule, error) {
+	args := m.Called(id, userID)
+
+	return args.Get(0).([]model.RedirectRule), args.Error(1)
+}
+
+func (m *StorageMock) SetRules(_ context.Context, id, userID string, rules []model.RedirectRule) error {
+	args := m.Called(id, userID, rules)
+
+	return args.Error(0)
+}
+
+func (m *StorageMock) GetVariants(_ context.Context, id, userID string) (model.Variants, error) {
+	args := m.Called(id, userID)
+
+	return args.Get(0).(model.Variants), args.Error(1)
+}
+
+func (m *StorageMock) SetVariants(_ context.Context, id, userID string, variants model.Variants) error {
+	args := m.Called(id, userID, variants)
+
+	return args.Error(0)
+}
+
+func (m *StorageMock) GetVariantClicks(_ context.Context, id, userID string) (map[string]int64, error) {
+	args := m.Called(id, userID)
+
+	return args.Get(0).(map[string]int64), args.Error(1)
+}
+
+func (m *StorageMock) GetRedirectOptions(_ context.Context, id, userID string) (model.RedirectOptions, error) {
+	args := m.Called(id, userID)
+
+	return args.Get(0).(model.RedirectOptions), args.Error(1)
+}
+
+func (m *StorageMock) SetRedirectOptions(_ context.Context, id, userID string, opts model.RedirectOptions) error {
+	args := m.Called(id, userID, opts)
+
+	return args.Error(0)
+}
+
+type ClickCounterMock struct {
+	mock.Mock
+}
+
+func (m *ClickCounterMock) Count(id, variant string) bool {
+	args := m.Called(id, variant)
+
+	return args.Bool(0)
+}
+
+type AuditRecorderMock struct {
+	mock.Mock
+}
+
+func (m *AuditRecorderMock) Record(_ context.Context, action, userID, ownerID string, linkIDs ...string) error {
+	args := m.Called(action, userID, ownerID, linkIDs)
+
 	return args.Error(0)
 }
 
-func (m *StorageMock) GetStat(_ context.Context) (int, int, error) {
-	args := m.Called()
+type DestinationCheckerMock struct {
+	mock.Mock
+}
+
+func (m *DestinationCheckerMock) Check(_ context.Context, url string) error {
+	args := m.Called(url)
 
-	return args.Int(0), args.Int(1), args.Error(2)
+	return args.Error(0)
+}
+
+type CanonicalizerMock struct {
+	mock.Mock
+}
+
+func (m *CanonicalizerMock) Canonicalize(url string) (string, error) {
+	args := m.Called(url)
+
+	return args.String(0), args.Error(1)
+}
+
+func (m *CanonicalizerMock) Display(canonicalURL string) string {
+	args := m.Called(canonicalURL)
+
+	return args.String(0)
+}
+
+type LinkEnricherMock struct {
+	mock.Mock
+}
+
+func (m *LinkEnricherMock) Enqueue(l model.Link) bool {
+	args := m.Called(l.CanonicalURL, l.UserID)
+
+	return args.Bool(0)
+}
+
+type LinkRateLimiterMock struct {
+	mock.Mock
+}
+
+func (m *LinkRateLimiterMock) AllowLink(_ context.Context, budget, linkID string) (bool, time.Duration, error) {
+	args := m.Called(budget, linkID)
+
+	return args.Bool(0), args.Get(1).(time.Duration), args.Error(2)
+}
+
+type GeoIPMock struct {
+	mock.Mock
+}
+
+func (m *GeoIPMock) Country(_ context.Context, ip string) (string, error) {
+	args := m.Called(ip)
+
+	return args.String(0), args.Error(1)
 }
 
 func TestShortener(t *testing.T) {
 	var (
-		userID     = "438c4b98-fc98-45cf-ac63-c4a86fbd4ff4"
-		url        = "https://ya.ru/"
-		urlID      = "1i-CBrzwyMkL"
-		urlIDs     = []string{urlID}
-		urls       = map[string]string{urlID: url}
-		urlCount   = 2
-		usersCount = 1
-		ctx        = context.Background()
-		storage    = &StorageMock{}
+		userID   = "438c4b98-fc98-45cf-ac63-c4a86fbd4ff4"
+		url      = "https://ya.ru/"
+		urlID    = "1i-CBrzwyMkL"
+		urlIDs   = []string{urlID}
+		link     = model.Link{ID: urlID, URL: url, UserID: userID}
+		deleted  = model.Link{ID: "deleted", URL: url, UserID: userID, Deleted: true}
+		today    = time.Now().UTC().Truncate(24 * time.Hour)
+		stats    = model.Stats{URLs: 2, Users: 1, CreatedPerDay: []model.DayCount{{Date: today.Format(model.DayFormat), Count: 2}}}
+		ctx      = context.Background()
+		storage  = &StorageMock{}
+		auditor  = &AuditRecorderMock{}
+		enricher = &LinkEnricherMock{}
+		clicks   = &ClickCounterMock{}
 	)
 
 	storage.
-		On("Add", url, userID).Return(nil).Once().
-		On("Get", urlID).Return(url, nil).Once().
-		On("GetAllUser", userID).Return(urls).Once().
-		On("DeleteBatch", urlIDs, userID).Return(nil).Once().
-		On("GetStat").Return(urlCount, usersCount, nil).Once()
-	shortener := Shortener{
-		storage: storage,
-	}
+		On("IsUserBanned", userID).Return(false, nil).Once().
+		On("Add", url, url, url, userID, false).Return(nil).Once().
+		On("Get", urlID).Return(model.Link{ID: urlID, CanonicalURL: url}, nil).Once().
+		On("GetUserLinks", userID).Return([]model.Link{link, deleted}, nil).Once().
+		On("DeleteBatch", urlIDs, userID).Return(urlIDs, nil).Once().
+		On("RestoreBatch", urlIDs, userID).Return(urlIDs, nil).Once().
+		On("GetStats", model.StatsOptions{Since: today.AddDate(0, 0, -2), Top: 5}).Return(stats, nil).Once()
+	auditor.
+		On("Record", model.AuditActionCreate, userID, userID, mock.AnythingOfType("[]string")).Return(nil).Once().
+		On("Record", model.AuditActionDelete, userID, userID, urlIDs).Return(nil).Once().
+		On("Record", model.AuditActionRestore, userID, userID, urlIDs).Return(errors.New("")).Once()
+	policy := &DestinationCheckerMock{}
+	policy.On("Check", url).Return(nil).Once()
+	canonicalizer := &CanonicalizerMock{}
+	canonicalizer.
+		On("Canonicalize", url).Return(url, nil).Once().
+		On("Display", url).Return(url).Once()
+	enricher.On("Enqueue", url, userID).Return(true).Once()
+	clicks.On("Count", urlID, "").Return(true).Once()
+	shortener := NewShortener(storage, auditor, policy, canonicalizer, &LinkRateLimiterMock{}, nil, enricher, clicks)
 
-	_, inserted, err := shortener.Shorten(ctx, url, userID)
+	_, inserted, err := shortener.Shorten(ctx, url, userID, model.LinkOptions{})
 	assert.NoError(t, err)
 	assert.True(t, inserted)
-	savedURL, err := shortener.Get(ctx, urlID)
+	savedURL, err := shortener.Get(ctx, urlID, model.RedirectRequest{})
 	assert.NoError(t, err)
-	assert.Equal(t, url, savedURL)
-	userURLs := shortener.GetAllUser(ctx, userID)
-	assert.Equal(t, urls, userURLs)
+	assert.Equal(t, url, savedURL.URL)
+	userLinks, err := shortener.GetUserLinks(ctx, userID)
+	assert.NoError(t, err)
+	assert.Equal(t, []model.Link{link}, userLinks, "удаленные URL не возвращаются")
 	err = shortener.DeleteBatch(ctx, urlIDs, userID)
 	assert.NoError(t, err)
-	getURLCount, getUsersCount, err := shortener.GetStat(ctx)
+	err = shortener.RestoreBatch(ctx, urlIDs, userID)
+	assert.NoError(t, err, "ошибка записи в журнал аудита не прерывает восстановление")
+	gotStats, err := shortener.GetStats(ctx, 3, 5)
 	assert.NoError(t, err)
-	assert.Equal(t, urlCount, getURLCount)
-	assert.Equal(t, usersCount, getUsersCount)
+	assert.Equal(t, 2, gotStats.URLs)
+	assert.Equal(t, 1, gotStats.Users)
+	assert.Equal(t, []model.DayCount{
+		{Date: today.AddDate(0, 0, -2).Format(model.DayFormat)},
+		{Date: today.AddDate(0, 0, -1).Format(model.DayFormat)},
+		{Date: today.Format(model.DayFormat), Count: 2},
+	}, gotStats.CreatedPerDay, "дни без созданных URL заполняются нулями")
 	storage.AssertExpectations(t)
+	auditor.AssertExpectations(t)
+	enricher.AssertExpectations(t)
+	clicks.AssertExpectations(t)
 }
 
 func TestShortenerReturnsError(t *testing.T) {
@@ -93,24 +258,515 @@ func TestShortenerReturnsError(t *testing.T) {
 		userID  = "438c4b98-fc98-45cf-ac63-c4a86fbd4ff4"
 		ctx     = context.Background()
 		storage = &StorageMock{}
+		auditor = &AuditRecorderMock{}
 	)
 
 	storage.
-		On("Add", url, userID).Return(errors.New("")).Once().
-		On("Get", urlID).Return("", errors.New("")).Once().
-		On("DeleteBatch", urlIDs, userID).Return(inerr.ErrURLIsDeleted).Once().
-		On("GetStat").Return(0, 0, errors.New("")).Once()
-	shortener := Shortener{
-		storage: storage,
-	}
+		On("IsUserBanned", userID).Return(false, nil).Once().
+		On("Add", url, url, url, userID, false).Return(errors.New("")).Once().
+		On("Get", urlID).Return(model.Link{}, errors.New("")).Once().
+		On("DeleteBatch", urlIDs, userID).Return([]string{}, inerr.ErrURLIsDeleted).Once().
+		On("GetStats", mock.AnythingOfType("model.StatsOptions")).Return(model.Stats{}, errors.New("")).Once()
+	auditor.On("Record", model.AuditActionDelete, userID, userID, []string{}).Return(nil).Once()
+	policy := &DestinationCheckerMock{}
+	policy.On("Check", url).Return(nil).Once()
+	canonicalizer := &CanonicalizerMock{}
+	canonicalizer.
+		On("Canonicalize", url).Return(url, nil).Once().
+		On("Display", url).Return(url).Once()
+	shortener := NewShortener(storage, auditor, policy, canonicalizer, &LinkRateLimiterMock{}, nil, nil, nil)
 
-	_, _, err := shortener.Shorten(ctx, url, userID)
+	_, _, err := shortener.Shorten(ctx, url, userID, model.LinkOptions{})
 	assert.Error(t, err)
-	_, err = shortener.Get(ctx, urlID)
+	_, err = shortener.Get(ctx, urlID, model.RedirectRequest{})
 	assert.Error(t, err)
 	err = shortener.DeleteBatch(ctx, urlIDs, userID)
 	assert.ErrorIs(t, err, inerr.ErrURLIsDeleted)
-	_, _, err = shortener.GetStat(ctx)
+	_, err = shortener.GetStats(ctx, 30, 10)
 	assert.Error(t, err)
 	storage.AssertExpectations(t)
 }
+
+func TestShortenerBannedUser(t *testing.T) {
+	var (
+		url     = "https://ya.ru/"
+		userID  = "438c4b98-fc98-45cf-ac63-c4a86fbd4ff4"
+		ctx     = context.Background()
+		storage = &StorageMock{}
+	)
+	storage.
+		On("IsUserBanned", userID).Return(true, nil).Once().
+		On("IsUserBanned", userID).Return(false, errors.New("")).Once()
+	shortener := NewShortener(storage, &AuditRecorderMock{}, &DestinationCheckerMock{}, &CanonicalizerMock{}, &LinkRateLimiterMock{}, nil, nil, nil)
+
+	_, _, err := shortener.Shorten(ctx, url, userID, model.LinkOptions{})
+	assert.ErrorIs(t, err, inerr.ErrUserIsBanned, "заблокированный пользователь")
+	_, _, err = shortener.Shorten(ctx, url, userID, model.LinkOptions{})
+	assert.Error(t, err, "ошибка проверки блокировки")
+	storage.AssertExpectations(t)
+}
+
+func TestShortenerRejectedDestination(t *testing.T) {
+	var (
+		url     = "http://127.0.0.1/"
+		userID  = "438c4b98-fc98-45cf-ac63-c4a86fbd4ff4"
+		ctx     = context.Background()
+		storage = &StorageMock{}
+		policy  = &DestinationCheckerMock{}
+	)
+	storage.On("IsUserBanned", userID).Return(false, nil).Once()
+	policy.On("Check", url).Return(inerr.ErrDestinationRejected).Once()
+	canonicalizer := &CanonicalizerMock{}
+	canonicalizer.On("Canonicalize", url).Return(url, nil).Once()
+	shortener := NewShortener(storage, &AuditRecorderMock{}, policy, canonicalizer, &LinkRateLimiterMock{}, nil, nil, nil)
+
+	_, _, err := shortener.Shorten(ctx, url, userID, model.LinkOptions{})
+	assert.ErrorIs(t, err, inerr.ErrDestinationRejected)
+	storage.AssertExpectations(t)
+	storage.AssertNotCalled(t, "Add", url, url, url, userID, false)
+	policy.AssertExpectations(t)
+}
+
+func TestShortenerCanonicalURL(t *testing.T) {
+	var (
+		url           = "HTTP://Пример.рф:80/a?b=1&a=2&utm_source=x"
+		canonicalURL  = "http://xn--e1afmkfd.xn--p1ai/a?a=2&b=1"
+		displayURL    = "http://пример.рф/a?a=2&b=1"
+		invalidURL    = "http://example.com:port/"
+		userID        = "438c4b98-fc98-45cf-ac63-c4a86fbd4ff4"
+		ctx           = context.Background()
+		storage       = &StorageMock{}
+		auditor       = &AuditRecorderMock{}
+		policy        = &DestinationCheckerMock{}
+		canonicalizer = &CanonicalizerMock{}
+	)
+	storage.
+		On("IsUserBanned", userID).Return(false, nil).Twice().
+		On("Add", url, canonicalURL, displayURL, userID, false).Return(nil).Once()
+	auditor.On("Record", model.AuditActionCreate, userID, userID, mock.AnythingOfType("[]string")).Return(nil).Once()
+	policy.On("Check", canonicalURL).Return(nil).Once()
+	canonicalizer.
+		On("Canonicalize", url).Return(canonicalURL, nil).Once().
+		On("Display", canonicalURL).Return(displayURL).Once().
+		On("Canonicalize", invalidURL).Return("", inerr.ErrDestinationRejected).Once()
+	shortener := NewShortener(storage, auditor, policy, canonicalizer, &LinkRateLimiterMock{}, nil, nil, nil)
+
+	_, inserted, err := shortener.Shorten(ctx, url, userID, model.LinkOptions{})
+	assert.NoError(t, err, "сохранение исходного, канонического и отображаемого URL")
+	assert.True(t, inserted, "сохранение исходного, канонического и отображаемого URL")
+	_, _, err = shortener.Shorten(ctx, invalidURL, userID, model.LinkOptions{})
+	assert.ErrorIs(t, err, inerr.ErrDestinationRejected, "ошибка приведения к каноническому виду")
+	storage.AssertExpectations(t)
+	auditor.AssertExpectations(t)
+	policy.AssertExpectations(t)
+	canonicalizer.AssertExpectations(t)
+}
+
+func TestShortenerExpiry(t *testing.T) {
+	var (
+		url           = "https://ya.ru/"
+		userID        = "438c4b98-fc98-45cf-ac63-c4a86fbd4ff4"
+		ctx           = context.Background()
+		storage       = &StorageMock{}
+		auditor       = &AuditRecorderMock{}
+		policy        = &DestinationCheckerMock{}
+		canonicalizer = &CanonicalizerMock{}
+	)
+	storage.
+		On("IsUserBanned", userID).Return(false, nil).Once().
+		On("Add", url, url, url, userID, false).Return(nil).Once()
+	auditor.On("Record", model.AuditActionCreate, userID, userID, mock.AnythingOfType("[]string")).Return(nil).Once()
+	policy.On("Check", url).Return(nil).Once()
+	canonicalizer.
+		On("Canonicalize", url).Return(url, nil).Once().
+		On("Display", url).Return(url).Once()
+	shortener := NewShortener(storage, auditor, policy, canonicalizer, &LinkRateLimiterMock{}, nil, nil, nil)
+
+	_, _, err := shortener.Shorten(ctx, url, userID, model.LinkOptions{ExpiresAt: time.Now().Add(-time.Minute)})
+	assert.ErrorIs(t, err, inerr.ErrInvalidExpiry, "срок действия уже истек")
+	_, inserted, err := shortener.Shorten(ctx, url, userID, model.LinkOptions{ExpiresAt: time.Now().Add(time.Hour)})
+	assert.NoError(t, err, "сохранение URL со сроком действия")
+	assert.True(t, inserted, "сохранение URL со сроком действия")
+	storage.AssertExpectations(t)
+	canonicalizer.AssertExpectations(t)
+}
+
+func TestShortenerPasswordProtected(t *testing.T) {
+	var (
+		url           = "https://ya.ru/"
+		urlID         = "1i-CBrzwyMkL"
+		password      = "secret"
+		userID        = "438c4b98-fc98-45cf-ac63-c4a86fbd4ff4"
+		ctx           = context.Background()
+		storage       = &StorageMock{}
+		auditor       = &AuditRecorderMock{}
+		policy        = &DestinationCheckerMock{}
+		canonicalizer = &CanonicalizerMock{}
+		limiter       = &LinkRateLimiterMock{}
+		clicks        = &ClickCounterMock{}
+	)
+	hash, err := security.HashPassword(password)
+	require.NoError(t, err)
+	link := model.Link{ID: urlID, CanonicalURL: url, PasswordHash: hash}
+
+	storage.
+		On("IsUserBanned", userID).Return(false, nil).Once().
+		On("Add", url, url, url, userID, true).Return(nil).Once().
+		On("Get", urlID).Return(link, nil).Times(5)
+	clicks.On("Count", urlID, "").Return(true).Twice()
+	auditor.On("Record", model.AuditActionCreate, userID, userID, mock.AnythingOfType("[]string")).Return(nil).Once()
+	policy.On("Check", url).Return(nil).Once()
+	canonicalizer.
+		On("Canonicalize", url).Return(url, nil).Once().
+		On("Display", url).Return(url).Once()
+	limiter.
+		On("AllowLink", model.RateLimitBudgetPassword, urlID).Return(true, time.Duration(0), nil).Twice().
+		On("AllowLink", model.RateLimitBudgetPassword, urlID).Return(false, time.Minute, nil).Once().
+		On("AllowLink", model.RateLimitBudgetPassword, urlID).Return(false, time.Duration(0), errors.New("")).Once()
+	shortener := NewShortener(storage, auditor, policy, canonicalizer, limiter, nil, nil, clicks)
+
+	_, _, err = shortener.Shorten(ctx, url, userID, model.LinkOptions{Password: strings.Repeat("a", security.MaxPasswordLength+1)})
+	assert.ErrorIs(t, err, inerr.ErrPasswordTooLong, "слишком длинный пароль")
+	_, inserted, err := shortener.Shorten(ctx, url, userID, model.LinkOptions{Password: password})
+	assert.NoError(t, err, "сохранение URL с паролем")
+	assert.True(t, inserted, "сохранение URL с паролем")
+
+	_, err = shortener.Get(ctx, urlID, model.RedirectRequest{})
+	assert.ErrorIs(t, err, inerr.ErrPasswordRequired, "переход без пароля")
+	_, err = shortener.Get(ctx, urlID, model.RedirectRequest{Password: "wrong"})
+	assert.ErrorIs(t, err, inerr.ErrInvalidPassword, "неверный пароль")
+	stored, err := shortener.Get(ctx, urlID, model.RedirectRequest{Password: password})
+	assert.NoError(t, err, "верный пароль")
+	assert.Equal(t, url, stored.URL, "верный пароль")
+	_, err = shortener.Get(ctx, urlID, model.RedirectRequest{Password: password})
+	attemptsErr := &inerr.PasswordAttemptsError{}
+	require.ErrorAs(t, err, &attemptsErr, "превышена частота попыток")
+	assert.Equal(t, time.Minute, attemptsErr.RetryAfter, "превышена частота попыток")
+	stored, err = shortener.Get(ctx, urlID, model.RedirectRequest{Password: password})
+	assert.NoError(t, err, "ошибка ограничителя частоты не блокирует переход")
+	assert.Equal(t, url, stored.URL, "ошибка ограничителя частоты не блокирует переход")
+	storage.AssertExpectations(t)
+	limiter.AssertExpectations(t)
+	clicks.AssertExpectations(t)
+}
+
+func TestShortenerRedirectRules(t *testing.T) {
+	var (
+		url      = "https://example.com/"
+		urlID    = "1i-CBrzwyMkL"
+		ip       = "192.0.2.1"
+		appStore = "https://apps.apple.com/app/id1"
+		german   = "https://example.com/de"
+		iPhone   = "Mozilla/5.0 (iPhone; CPU iPhone OS 16_5 like Mac OS X)"
+		ctx      = clientinfo.WithInfo(context.Background(), clientinfo.Info{IP: ip})
+		storage  = &StorageMock{}
+		geoIP    = &GeoIPMock{}
+		clicks   = &ClickCounterMock{}
+		link     = model.Link{
+			ID:           urlID,
+			CanonicalURL: url,
+			Rules: []model.RedirectRule{
+				{Platforms: []string{"ios"}, URL: appStore},
+				{Countries: []string{"DE"}, URL: german},
+			},
+		}
+	)
+	storage.
+		On("Get", urlID).Return(link, nil).Times(4)
+	clicks.On("Count", urlID, "").Return(true).Times(4)
+	geoIP.
+		On("Country", ip).Return("DE", nil).Twice().
+		On("Country", ip).Return("", errors.New("")).Once()
+	shortener := NewShortener(storage, &AuditRecorderMock{}, &DestinationCheckerMock{}, &CanonicalizerMock{}, &LinkRateLimiterMock{}, geoIP, nil, clicks)
+
+	u, err := shortener.Get(ctx, urlID, model.RedirectRequest{UserAgent: iPhone})
+	assert.NoError(t, err)
+	assert.Equal(t, appStore, u.URL, "правило для платформы")
+	u, err = shortener.Get(ctx, urlID, model.RedirectRequest{UserAgent: "curl/8.1.2"})
+	assert.NoError(t, err)
+	assert.Equal(t, german, u.URL, "правило для страны")
+	u, err = shortener.Get(ctx, urlID, model.RedirectRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, url, u.URL, "ошибка определения страны, адрес назначения по умолчанию")
+
+	shortener = NewShortener(storage, &AuditRecorderMock{}, &DestinationCheckerMock{}, &CanonicalizerMock{}, &LinkRateLimiterMock{}, nil, nil, clicks)
+	u, err = shortener.Get(ctx, urlID, model.RedirectRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, url, u.URL, "без GeoIP правила для страны не применяются")
+	storage.AssertExpectations(t)
+	geoIP.AssertExpectations(t)
+	clicks.AssertExpectations(t)
+}
+
+func TestShortenerSetRules(t *testing.T) {
+	var (
+		urlID         = "1i-CBrzwyMkL"
+		userID        = "438c4b98-fc98-45cf-ac63-c4a86fbd4ff4"
+		ruleURL       = "HTTPS://Apps.Apple.com/app/id1"
+		canonicalURL  = "https://apps.apple.com/app/id1"
+		rejectedURL   = "http://127.0.0.1/"
+		ctx           = context.Background()
+		storage       = &StorageMock{}
+		auditor       = &AuditRecorderMock{}
+		policy        = &DestinationCheckerMock{}
+		canonicalizer = &CanonicalizerMock{}
+		stored        = []model.RedirectRule{{Platforms: []string{"ios"}, URL: canonicalURL}}
+	)
+	storage.
+		On("SetRules", urlID, userID, stored).Return(nil).Once().
+		On("SetRules", urlID, "userID2", stored).Return(inerr.ErrURLNotFound).Once().
+		On("GetRules", urlID, userID).Return(stored, nil).Once()
+	auditor.On("Record", model.AuditActionUpdate, userID, userID, []string{urlID}).Return(nil).Once()
+	canonicalizer.
+		On("Canonicalize", ruleURL).Return(canonicalURL, nil).Twice().
+		On("Canonicalize", rejectedURL).Return(rejectedURL, nil).Once()
+	policy.
+		On("Check", canonicalURL).Return(nil).Twice().
+		On("Check", rejectedURL).Return(inerr.ErrDestinationRejected).Once()
+	shortener := NewShortener(storage, auditor, policy, canonicalizer, &LinkRateLimiterMock{}, nil, nil, nil)
+
+	err := shortener.SetRules(ctx, urlID, userID, []model.RedirectRule{{Platforms: []string{"iOS"}, URL: ruleURL}})
+	assert.NoError(t, err, "сохранение правил")
+	err = shortener.SetRules(ctx, urlID, "userID2", []model.RedirectRule{{Platforms: []string{"ios"}, URL: ruleURL}})
+	assert.ErrorIs(t, err, inerr.ErrURLNotFound, "правила чужого URL")
+	err = shortener.SetRules(ctx, urlID, userID, []model.RedirectRule{{Platforms: []string{"symbian"}, URL: ruleURL}})
+	assert.ErrorIs(t, err, inerr.ErrInvalidRedirectRule, "некорректное правило")
+	err = shortener.SetRules(ctx, urlID, userID, []model.RedirectRule{{URL: rejectedURL}})
+	assert.ErrorIs(t, err, inerr.ErrDestinationRejected, "запрещенный адрес назначения")
+	rules, err := shortener.GetRules(ctx, urlID, userID)
+	assert.NoError(t, err, "получение правил")
+	assert.Equal(t, stored, rules, "получение правил")
+	storage.AssertExpectations(t)
+	auditor.AssertExpectations(t)
+	policy.AssertExpectations(t)
+}
+
+func TestShortenerVariants(t *testing.T) {
+	var (
+		url      = "https://example.com/"
+		urlID    = "1i-CBrzwyMkL"
+		variantA = model.Variant{Name: "a", URL: "https://example.com/a", Weight: 70}
+		variantB = model.Variant{Name: "b", URL: "https://example.com/b", Weight: 30}
+		ctx      = context.Background()
+		storage  = &StorageMock{}
+		link     = model.Link{
+			ID:           urlID,
+			CanonicalURL: url,
+			Rules:        []model.RedirectRule{{Platforms: []string{"ios"}, URL: "https://apps.apple.com/app/id1"}},
+			Variants:     model.Variants{Items: []model.Variant{variantA, variantB}},
+		}
+		sticky = link
+		clicks = &ClickCounterMock{}
+	)
+	sticky.Variants.Sticky = true
+	storage.
+		On("Get", urlID).Return(link, nil).Times(3).
+		On("Get", urlID).Return(sticky, nil).Twice()
+	clicks.
+		On("Count", urlID, "").Return(true).Once().
+		On("Count", urlID, "a").Return(true).Once().
+		On("Count", urlID, "b").Return(true).Once().
+		On("Count", urlID, "b").Return(false).Once()
+	shortener := NewShortener(storage, &AuditRecorderMock{}, &DestinationCheckerMock{}, &CanonicalizerMock{}, &LinkRateLimiterMock{}, nil, nil, clicks)
+	shortener.random = func(n int) int {
+		assert.Equal(t, 100, n)
+
+		return 75
+	}
+
+	r, err := shortener.Get(ctx, urlID, model.RedirectRequest{UserAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 16_5 like Mac OS X)"})
+	assert.NoError(t, err)
+	assert.Equal(t, model.Redirect{URL: "https://apps.apple.com/app/id1"}, r, "правило важнее вариантов")
+	r, err = shortener.Get(ctx, urlID, model.RedirectRequest{Variant: "a"})
+	assert.NoError(t, err)
+	assert.Equal(t, model.Redirect{URL: variantB.URL, Variant: "b"}, r, "выбор по весу, вариант не закрепляется")
+	r, err = shortener.Get(ctx, urlID, model.RedirectRequest{})
+	assert.NoError(t, err, "заполненная очередь учета переходов не блокирует переход")
+	assert.Equal(t, model.Redirect{URL: variantB.URL, Variant: "b"}, r, "заполненная очередь учета переходов не блокирует переход")
+	r, err = shortener.Get(ctx, urlID, model.RedirectRequest{Variant: "a"})
+	assert.NoError(t, err)
+	assert.Equal(t, model.Redirect{URL: variantA.URL, Variant: "a", Sticky: true}, r, "закрепленный вариант")
+
+	shortener.random = func(int) int { return 0 }
+	clicks.On("Count", urlID, "a").Return(true).Once()
+	r, err = shortener.Get(ctx, urlID, model.RedirectRequest{Variant: "c"})
+	assert.NoError(t, err)
+	assert.Equal(t, model.Redirect{URL: variantA.URL, Variant: "a", Sticky: true}, r, "неизвестный закрепленный вариант")
+	storage.AssertExpectations(t)
+	clicks.AssertExpectations(t)
+}
+
+func TestShortenerSetVariants(t *testing.T) {
+	var (
+		urlID         = "1i-CBrzwyMkL"
+		userID        = "438c4b98-fc98-45cf-ac63-c4a86fbd4ff4"
+		variantURL    = "HTTPS://Example.com/a"
+		canonicalURL  = "https://example.com/a"
+		rejectedURL   = "http://127.0.0.1/"
+		ctx           = context.Background()
+		storage       = &StorageMock{}
+		auditor       = &AuditRecorderMock{}
+		policy        = &DestinationCheckerMock{}
+		canonicalizer = &CanonicalizerMock{}
+		stored        = model.Variants{Sticky: true, Items: []model.Variant{{Name: "1", URL: canonicalURL, Weight: 1}}}
+	)
+	storage.
+		On("SetVariants", urlID, userID, stored).Return(nil).Once().
+		On("SetVariants", urlID, "userID2", stored).Return(inerr.ErrURLNotFound).Once()
+	auditor.On("Record", model.AuditActionUpdate, userID, userID, []string{urlID}).Return(nil).Once()
+	canonicalizer.
+		On("Canonicalize", variantURL).Return(canonicalURL, nil).Twice().
+		On("Canonicalize", rejectedURL).Return(rejectedURL, nil).Once()
+	policy.
+		On("Check", canonicalURL).Return(nil).Twice().
+		On("Check", rejectedURL).Return(inerr.ErrDestinationRejected).Once()
+	shortener := NewShortener(storage, auditor, policy, canonicalizer, &LinkRateLimiterMock{}, nil, nil, nil)
+
+	variants := model.Variants{Sticky: true, Items: []model.Variant{{URL: variantURL, Weight: 1}}}
+	assert.NoError(t, shortener.SetVariants(ctx, urlID, userID, variants), "сохранение вариантов")
+	assert.ErrorIs(t, shortener.SetVariants(ctx, urlID, "userID2", variants), inerr.ErrURLNotFound, "варианты чужого URL")
+	err := shortener.SetVariants(ctx, urlID, userID, model.Variants{Items: []model.Variant{{URL: variantURL}}})
+	assert.ErrorIs(t, err, inerr.ErrInvalidVariant, "некорректные варианты")
+	err = shortener.SetVariants(ctx, urlID, userID, model.Variants{Items: []model.Variant{{URL: rejectedURL, Weight: 1}}})
+	assert.ErrorIs(t, err, inerr.ErrDestinationRejected, "запрещенный адрес назначения")
+	storage.AssertExpectations(t)
+	auditor.AssertExpectations(t)
+	policy.AssertExpectations(t)
+}
+
+func TestShortenerGetVariantStats(t *testing.T) {
+	var (
+		urlID    = "1i-CBrzwyMkL"
+		userID   = "438c4b98-fc98-45cf-ac63-c4a86fbd4ff4"
+		variantA = model.Variant{Name: "a", URL: "https://example.com/a", Weight: 1}
+		variantB = model.Variant{Name: "b", URL: "https://example.com/b", Weight: 1}
+		ctx      = context.Background()
+		storage  = &StorageMock{}
+	)
+	storage.
+		On("GetVariants", urlID, userID).Return(model.Variants{Sticky: true, Items: []model.Variant{variantA, variantB}}, nil).Once().
+		On("GetVariantClicks", urlID, userID).Return(map[string]int64{"a": 3, "removed": 5}, nil).Once().
+		On("GetVariants", urlID, "userID2").Return(model.Variants{}, inerr.ErrURLNotFound).Once()
+	shortener := NewShortener(storage, &AuditRecorderMock{}, &DestinationCheckerMock{}, &CanonicalizerMock{}, &LinkRateLimiterMock{}, nil, nil, nil)
+
+	stats, err := shortener.GetVariantStats(ctx, urlID, userID)
+	assert.NoError(t, err)
+	assert.Equal(t, model.VariantStats{
+		Sticky: true,
+		Items: []model.VariantStat{
+			{Variant: variantA, Clicks: 3},
+			{Variant: variantB, Clicks: 0},
+		},
+	}, stats)
+	_, err = shortener.GetVariantStats(ctx, urlID, "userID2")
+	assert.ErrorIs(t, err, inerr.ErrURLNotFound, "статистика чужого URL")
+	storage.AssertExpectations(t)
+}
+
+func TestShortenerPassthrough(t *testing.T) {
+	var (
+		urlID    = "1i-CBrzwyMkL"
+		variantA = model.Variant{Name: "a", URL: "https://example.com/a?x=1", Weight: 1}
+		query    = url.Values{"utm_source": {"newsletter"}}
+		ctx      = context.Background()
+		storage  = &StorageMock{}
+		link     = model.Link{
+			ID:              urlID,
+			CanonicalURL:    "https://example.com/",
+			Variants:        model.Variants{Items: []model.Variant{variantA}},
+			RedirectOptions: model.RedirectOptions{Query: model.QueryPassthroughMerge, Path: true},
+		}
+		plain  = model.Link{ID: urlID, CanonicalURL: "https://example.com/"}
+		clicks = &ClickCounterMock{}
+	)
+	storage.
+		On("Get", urlID).Return(link, nil).Twice().
+		On("Get", urlID).Return(plain, nil).Once()
+	clicks.On("Count", urlID, "a").Return(true).Once()
+	shortener := NewShortener(storage, &AuditRecorderMock{}, &DestinationCheckerMock{}, &CanonicalizerMock{}, &LinkRateLimiterMock{}, nil, nil, clicks)
+
+	r, err := shortener.Get(ctx, urlID, model.RedirectRequest{Path: "docs", Query: query})
+	assert.NoError(t, err)
+	assert.Equal(t, model.Redirect{URL: "https://example.com/a/docs?x=1&utm_source=newsletter", Variant: "a"}, r)
+	_, err = shortener.Get(ctx, urlID, model.RedirectRequest{Path: "../admin"})
+	assert.ErrorIs(t, err, inerr.ErrInvalidPathSuffix, "некорректный суффикс, переход не учитывается")
+	_, err = shortener.Get(ctx, urlID, model.RedirectRequest{Path: "docs"})
+	assert.ErrorIs(t, err, inerr.ErrPathSuffixNotAllowed, "перенос пути не включен")
+	storage.AssertExpectations(t)
+	clicks.AssertExpectations(t)
+}
+
+func TestShortenerSetRedirectOptions(t *testing.T) {
+	var (
+		urlID   = "1i-CBrzwyMkL"
+		userID  = "438c4b98-fc98-45cf-ac63-c4a86fbd4ff4"
+		opts    = model.RedirectOptions{Query: model.QueryPassthroughOverride, Template: true}
+		ctx     = context.Background()
+		storage = &StorageMock{}
+		auditor = &AuditRecorderMock{}
+	)
+	storage.
+		On("SetRedirectOptions", urlID, userID, opts).Return(nil).Once().
+		On("SetRedirectOptions", urlID, "userID2", opts).Return(inerr.ErrURLNotFound).Once().
+		On("GetRedirectOptions", urlID, userID).Return(opts, nil).Once()
+	auditor.On("Record", model.AuditActionUpdate, userID, userID, []string{urlID}).Return(nil).Once()
+	shortener := NewShortener(storage, auditor, &DestinationCheckerMock{}, &CanonicalizerMock{}, &LinkRateLimiterMock{}, nil, nil, nil)
+
+	assert.NoError(t, shortener.SetRedirectOptions(ctx, urlID, userID, opts))
+	assert.ErrorIs(t, shortener.SetRedirectOptions(ctx, urlID, "userID2", opts), inerr.ErrURLNotFound)
+	err := shortener.SetRedirectOptions(ctx, urlID, userID, model.RedirectOptions{Query: "append"})
+	assert.ErrorIs(t, err, inerr.ErrInvalidRedirectOptions)
+	stored, err := shortener.GetRedirectOptions(ctx, urlID, userID)
+	assert.NoError(t, err)
+	assert.Equal(t, opts, stored)
+	storage.AssertExpectations(t)
+	auditor.AssertExpectations(t)
+}
+
+func TestShortenerPreview(t *testing.T) {
+	var (
+		urlID         = "1i-CBrzwyMkL"
+		variantA      = model.Variant{Name: "a", URL: "https://xn--e1afmkfd.xn--p1ai/a", Weight: 1}
+		createdAt     = time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+		ctx           = context.Background()
+		storage       = &StorageMock{}
+		canonicalizer = &CanonicalizerMock{}
+		link          = model.Link{
+			ID:              urlID,
+			CanonicalURL:    "https://example.com/",
+			Title:           "Пример",
+			Variants:        model.Variants{Items: []model.Variant{variantA}},
+			RedirectOptions: model.RedirectOptions{Status: http.StatusPermanentRedirect},
+			CreatedAt:       createdAt,
+		}
+		clicks = &ClickCounterMock{}
+	)
+	storage.
+		On("Get", urlID).Return(link, nil).Twice().
+		On("Get", "unknown").Return(model.Link{}, inerr.ErrURLNotFound).Once()
+	canonicalizer.On("Display", variantA.URL).Return("https://пример.рф/a").Once()
+	clicks.On("Count", urlID, "a").Return(true).Once()
+	shortener := NewShortener(storage, &AuditRecorderMock{}, &DestinationCheckerMock{}, canonicalizer, &LinkRateLimiterMock{}, nil, nil, clicks)
+
+	p, err := shortener.Preview(ctx, urlID, model.RedirectRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, model.Preview{
+		URL:        variantA.URL,
+		DisplayURL: "https://пример.рф/a",
+		Title:      "Пример",
+		CreatedAt:  createdAt,
+	}, p, "предпросмотр не учитывает переход на вариант")
+	r, err := shortener.Get(ctx, urlID, model.RedirectRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, model.Redirect{URL: variantA.URL, Variant: "a", Status: http.StatusPermanentRedirect}, r, "код ответа URL")
+	_, err = shortener.Preview(ctx, "unknown", model.RedirectRequest{})
+	assert.ErrorIs(t, err, inerr.ErrURLNotFound)
+	storage.AssertExpectations(t)
+	canonicalizer.AssertExpectations(t)
+	clicks.AssertExpectations(t)
+}
+
+func TestNormalizeTitle(t *testing.T) {
+	assert.Equal(t, "Заголовок страницы", normalizeTitle("  Заголовок\n\tстраницы "))
+	assert.Equal(t, strings.Repeat("я", MaxTitleLength), normalizeTitle(strings.Repeat("я", MaxTitleLength+1)))
+}
@@ -0,0 +1,7 @@
+package service
+
+import "go.opentelemetry.io/otel"
+
+// tracer трассировщик вызовов сервисов. Спаны передаются глобальному
+// поставщику трассировщиков OpenTelemetry.
+var tracer = otel.Tracer("github.com/ivanpodgorny/urlshortener/internal/app/service")
@@ -0,0 +1,36 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/ivanpodgorny/urlshortener/internal/app/model"
+)
+
+func TestShortener_Tracing(t *testing.T) {
+	var (
+		recorder = tracetest.NewSpanRecorder()
+		tp       = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+		storage  = &StorageMock{}
+	)
+	otel.SetTracerProvider(tp)
+	storage.On("GetStats", mock.AnythingOfType("model.StatsOptions")).Return(model.Stats{}, nil).Once()
+	shortener := NewShortener(storage, &AuditRecorderMock{}, &DestinationCheckerMock{}, &CanonicalizerMock{}, &LinkRateLimiterMock{}, nil, nil, nil)
+
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "parent")
+	_, err := shortener.GetStats(ctx, 1, 1)
+	require.NoError(t, err)
+	parent.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	assert.Equal(t, "Shortener.GetStats", spans[0].Name())
+	assert.Equal(t, parent.SpanContext().SpanID(), spans[0].Parent().SpanID(), "дочерний спан")
+}
@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ivanpodgorny/urlshortener/internal/app/model"
+)
+
+// AuditLog реализует интерфейс service.AuditStorage для хранения журнала аудита
+// в памяти. Если передать в конструктор файловый дескриптор, записи также
+// дописываются в конец открытого файла в формате JSON Lines. Файл перезаписывается
+// только при удалении устаревших записей.
+type AuditLog struct {
+	events     []model.AuditEvent
+	persistent *os.File
+	mu         sync.RWMutex
+}
+
+// NewAuditLog возвращает указатель на новый экземпляр AuditLog.
+func NewAuditLog(file *os.File) *AuditLog {
+	l := AuditLog{
+		events:     make([]model.AuditEvent, 0),
+		persistent: file,
+	}
+	l.loadDataInMemory()
+
+	return &l
+}
+
+// AddAuditEvents сохраняет записи в журнал аудита.
+func (l *AuditLog) AddAuditEvents(_ context.Context, events []model.AuditEvent) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, e := range events {
+		if err := l.saveToPersistent(e); err != nil {
+			return err
+		}
+
+		l.events = append(l.events, e)
+	}
+
+	return nil
+}
+
+// GetAuditEvents возвращает записи журнала аудита, удовлетворяющие фильтру f,
+// начиная с самых новых.
+func (l *AuditLog) GetAuditEvents(_ context.Context, f model.AuditFilter) ([]model.AuditEvent, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	events := make([]model.AuditEvent, 0)
+	for i := len(l.events) - 1; i >= 0; i-- {
+		e := l.events[i]
+		if (f.OwnerID != "" && e.OwnerID != f.OwnerID) || (f.LinkID != "" && e.LinkID != f.LinkID) {
+			continue
+		}
+
+		events = append(events, e)
+		if f.Limit > 0 && len(events) == f.Limit {
+			break
+		}
+	}
+
+	return events, nil
+}
+
+// DeleteAuditEventsBefore удаляет записи журнала аудита, созданные раньше t.
+func (l *AuditLog) DeleteAuditEventsBefore(_ context.Context, t time.Time) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	events := make([]model.AuditEvent, 0, len(l.events))
+	for _, e := range l.events {
+		if !e.CreatedAt.Before(t) {
+			events = append(events, e)
+		}
+	}
+
+	if len(events) == len(l.events) {
+		return nil
+	}
+	l.events = events
+
+	return l.renewPersistent()
+}
+
+func (l *AuditLog) loadDataInMemory() {
+	if l.persistent == nil {
+		return
+	}
+
+	scanner := bufio.NewScanner(l.persistent)
+	for scanner.Scan() {
+		e := model.AuditEvent{}
+		if err := json.Unmarshal(scanner.Bytes(), &e); err == nil {
+			l.events = append(l.events, e)
+		}
+	}
+}
+
+func (l *AuditLog) renewPersistent() error {
+	if l.persistent == nil {
+		return nil
+	}
+
+	if _, err := l.persistent.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := l.persistent.Truncate(0); err != nil {
+		return err
+	}
+
+	for _, e := range l.events {
+		if err := l.saveToPersistent(e); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (l *AuditLog) saveToPersistent(e model.AuditEvent) error {
+	if l.persistent == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	_, err = l.persistent.Write(append(data, '\n'))
+
+	return err
+}
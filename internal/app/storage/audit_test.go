@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ivanpodgorny/urlshortener/internal/app/model"
+)
+
+func TestAuditLog(t *testing.T) {
+	var (
+		filename = "test_audit"
+		now      = time.Now().UTC().Truncate(time.Second)
+		old      = model.AuditEvent{LinkID: "id1", Action: model.AuditActionCreate, OwnerID: "user1", CreatedAt: now.Add(-2 * time.Hour)}
+		created  = model.AuditEvent{LinkID: "id2", Action: model.AuditActionCreate, OwnerID: "user2", CreatedAt: now}
+		deleted  = model.AuditEvent{LinkID: "id1", Action: model.AuditActionDelete, OwnerID: "user1", CreatedAt: now}
+		ctx      = context.Background()
+	)
+
+	file, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	require.NoError(t, err, "не удалось создать файл")
+	l := NewAuditLog(file)
+	require.NoError(t, l.AddAuditEvents(ctx, []model.AuditEvent{old, created}))
+	require.NoError(t, l.AddAuditEvents(ctx, []model.AuditEvent{deleted}))
+
+	events, err := l.GetAuditEvents(ctx, model.AuditFilter{})
+	assert.NoError(t, err)
+	assert.Equal(t, []model.AuditEvent{deleted, created, old}, events, "все записи, начиная с новых")
+	events, err = l.GetAuditEvents(ctx, model.AuditFilter{OwnerID: "user1"})
+	assert.NoError(t, err)
+	assert.Equal(t, []model.AuditEvent{deleted, old}, events, "фильтр по владельцу")
+	events, err = l.GetAuditEvents(ctx, model.AuditFilter{LinkID: "id2"})
+	assert.NoError(t, err)
+	assert.Equal(t, []model.AuditEvent{created}, events, "фильтр по URL")
+	events, err = l.GetAuditEvents(ctx, model.AuditFilter{Limit: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, []model.AuditEvent{deleted}, events, "ограничение количества")
+
+	require.NoError(t, l.DeleteAuditEventsBefore(ctx, now.Add(-time.Hour)))
+	require.NoError(t, file.Close(), "не удалось закрыть файл")
+
+	file, err = os.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	require.NoError(t, err, "не удалось открыть файл")
+	l = NewAuditLog(file)
+	events, err = l.GetAuditEvents(ctx, model.AuditFilter{})
+	assert.NoError(t, err)
+	assert.Equal(t, []model.AuditEvent{deleted, created}, events, "записи, сохраненные в файл")
+
+	require.NoError(t, file.Close(), "не удалось закрыть файл")
+	require.NoError(t, os.Remove(filename))
+}
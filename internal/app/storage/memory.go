@@ -3,29 +3,72 @@ package storage
 import (
 	"bufio"
 	"context"
+	"encoding/json"
 	"errors"
 	"io"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
+
+	"golang.org/x/exp/slog"
 
 	inerr "github.com/ivanpodgorny/urlshortener/internal/app/errors"
+	"github.com/ivanpodgorny/urlshortener/internal/app/model"
 )
 
 // Memory реализует интерфейс service.Storage для хранения url в памяти.
 // Если передать в конструктор файловый дескриптор, будет также сохранять
 // url в открытый файл.
 type Memory struct {
-	urls       map[string]string
-	userData   map[string][]string
-	persistent *os.File
-	mu         sync.RWMutex
+	urls         map[string]string
+	canonical    map[string]string
+	display      map[string]string
+	passwords    map[string]string
+	titles       map[string]string
+	created      map[string]time.Time
+	expires      map[string]time.Time
+	rules        map[string][]model.RedirectRule
+	variants     map[string]model.Variants
+	options      map[string]model.RedirectOptions
+	metadata     map[string]model.Metadata
+	health       map[string]model.LinkHealth
+	clicks       map[string]map[string]int64
+	byCanonical  map[string]string
+	userData     map[string][]string
+	owners       map[string]string
+	deleted      map[string]bool
+	disabled     map[string]bool
+	banned       map[string]bool
+	adminActions []model.AdminAction
+	persistent   *os.File
+	observer     OperationObserver
+	logger       *slog.Logger
+	mu           sync.RWMutex
 }
 
 const (
-	deletedFlag     = "deleted"
-	urlSectionName  = "url"
-	userSectionName = "user"
+	deletedFlag            = "deleted"
+	urlSectionName         = "url"
+	canonicalSectionName   = "canonical"
+	displaySectionName     = "display"
+	passwordSectionName    = "password"
+	titleSectionName       = "title"
+	createdSectionName     = "created"
+	expiresSectionName     = "expires"
+	rulesSectionName       = "rules"
+	variantsSectionName    = "variants"
+	clickSectionName       = "click"
+	clicksSectionName      = "clicks"
+	optionsSectionName     = "redirect"
+	metadataSectionName    = "metadata"
+	userSectionName        = "user"
+	deletedSectionName     = "deleted"
+	disabledSectionName    = "disabled"
+	bannedSectionName      = "banned"
+	adminActionSectionName = "admin"
 )
 
 // ErrKeyExists URL с данным id eже существует.
@@ -34,100 +77,675 @@ var ErrKeyExists = errors.New("key exists")
 // ErrKeyNotFound н найден URL с данным id.
 var ErrKeyNotFound = errors.New("key not found")
 
-// NewMemory возвращает указатель на новый экземпляр Memory.
-func NewMemory(file *os.File) *Memory {
+// NewMemory возвращает указатель на новый экземпляр Memory. В журнал l записываются
+// ошибки чтения file; если l равен nil, используется журнал по умолчанию.
+func NewMemory(file *os.File, l *slog.Logger) *Memory {
+	if l == nil {
+		l = slog.Default()
+	}
+
 	s := Memory{
-		urls:       map[string]string{},
-		userData:   map[string][]string{},
-		persistent: file,
+		urls:        map[string]string{},
+		canonical:   map[string]string{},
+		display:     map[string]string{},
+		passwords:   map[string]string{},
+		titles:      map[string]string{},
+		created:     map[string]time.Time{},
+		expires:     map[string]time.Time{},
+		rules:       map[string][]model.RedirectRule{},
+		variants:    map[string]model.Variants{},
+		options:     map[string]model.RedirectOptions{},
+		metadata:    map[string]model.Metadata{},
+		health:      map[string]model.LinkHealth{},
+		clicks:      map[string]map[string]int64{},
+		byCanonical: map[string]string{},
+		userData:    map[string][]string{},
+		owners:      map[string]string{},
+		deleted:     map[string]bool{},
+		disabled:    map[string]bool{},
+		banned:      map[string]bool{},
+		persistent:  file,
+		logger:      l,
 	}
 	s.loadDataInMemory()
 
 	return &s
 }
 
-// Add сохраняет URL.
-func (m *Memory) Add(_ context.Context, id string, url string, userID string) (string, error) {
+// SetObserver задает получателя длительности операций хранилища.
+// Должен вызываться до начала работы с хранилищем.
+func (m *Memory) SetObserver(o OperationObserver) {
+	m.observer = o
+}
+
+// Add сохраняет URL в исходном, каноническом и отображаемом виде. Если бессрочный URL без пароля
+// с таким же каноническим видом уже сохранен тем же пользователем, новая запись не добавляется
+// и возвращается id существующего URL.
+func (m *Memory) Add(_ context.Context, l model.Link) (string, error) {
+	defer track(m.observer, "add")()
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if _, exist := m.urls[id]; exist {
+	if _, exist := m.urls[l.ID]; exist {
 		return "", ErrKeyExists
 	}
 
-	if err := m.saveToPersistent(urlSectionName, id, url); err != nil {
+	storedID, exist := m.byCanonical[canonicalKey(l.UserID, l.CanonicalURL)]
+	if exist && l.PasswordHash == "" && l.ExpiresAt.IsZero() {
+		return storedID, nil
+	}
+
+	if err := m.saveToPersistent(urlSectionName, l.ID, l.URL); err != nil {
+		return "", err
+	}
+	if err := m.saveToPersistent(canonicalSectionName, l.ID, l.CanonicalURL); err != nil {
 		return "", err
 	}
-	if err := m.saveToPersistent(userSectionName, userID, id); err != nil {
+	if err := m.saveToPersistent(displaySectionName, l.ID, l.DisplayURL); err != nil {
 		return "", err
 	}
-	m.urls[id] = url
-	m.userData[userID] = append(m.userData[userID], id)
+	if l.PasswordHash != "" {
+		if err := m.saveToPersistent(passwordSectionName, l.ID, l.PasswordHash); err != nil {
+			return "", err
+		}
+	}
+	if l.Title != "" {
+		if err := m.saveToPersistent(titleSectionName, l.ID, l.Title); err != nil {
+			return "", err
+		}
+	}
+	if !l.CreatedAt.IsZero() {
+		if err := m.saveToPersistent(createdSectionName, l.ID, l.CreatedAt.Format(time.RFC3339Nano)); err != nil {
+			return "", err
+		}
+	}
+	if !l.ExpiresAt.IsZero() {
+		if err := m.saveToPersistent(expiresSectionName, l.ID, l.ExpiresAt.Format(time.RFC3339Nano)); err != nil {
+			return "", err
+		}
+	}
+	if err := m.saveToPersistent(userSectionName, l.UserID, l.ID); err != nil {
+		return "", err
+	}
+	m.urls[l.ID] = l.URL
+	m.canonical[l.ID] = l.CanonicalURL
+	m.display[l.ID] = l.DisplayURL
+	m.setTitle(l.ID, l.Title)
+	m.setTime(m.created, l.ID, l.CreatedAt)
+	m.setTime(m.expires, l.ID, l.ExpiresAt)
+	if l.PasswordHash != "" {
+		m.passwords[l.ID] = l.PasswordHash
+	}
+	if l.PasswordHash == "" && l.ExpiresAt.IsZero() {
+		m.byCanonical[canonicalKey(l.UserID, l.CanonicalURL)] = l.ID
+	}
+	m.userData[l.UserID] = append(m.userData[l.UserID], l.ID)
+	m.owners[l.ID] = l.UserID
 
-	return id, nil
+	return l.ID, nil
 }
 
 // Get возвращает сохраненный URL по id. Если URL был помечен удаленным, возвращает
-// ошибку errors.ErrURLIsDeleted.
-func (m *Memory) Get(_ context.Context, id string) (string, error) {
+// ошибку errors.ErrURLIsDeleted, если заблокирован администратором — errors.ErrURLIsDisabled,
+// если срок действия URL истек — errors.ErrURLIsExpired.
+func (m *Memory) Get(_ context.Context, id string) (model.Link, error) {
+	defer track(m.observer, "get")()
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if _, ok := m.urls[id]; !ok {
+		return model.Link{}, ErrKeyNotFound
+	}
+
+	if m.deleted[id] {
+		return model.Link{}, inerr.ErrURLIsDeleted
+	}
+
+	if m.disabled[id] {
+		return model.Link{}, inerr.ErrURLIsDisabled
+	}
+
+	l := m.link(id)
+	if l.Expired(time.Now()) {
+		return model.Link{}, inerr.ErrURLIsExpired
+	}
+
+	return l, nil
+}
+
+// DeleteBatch удаляет URL с заданными id. Возвращает id URL, которые были удалены.
+func (m *Memory) DeleteBatch(_ context.Context, urlIDs []string, userID string) ([]string, error) {
+	defer track(m.observer, "delete_batch")()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	deleted := make([]string, 0, len(urlIDs))
+	for _, urlID := range urlIDs {
+		if !m.belongsToUser(urlID, userID) || m.deleted[urlID] {
+			continue
+		}
+
+		m.deleted[urlID] = true
+		deleted = append(deleted, urlID)
+	}
+
+	return deleted, m.renewPersistent()
+}
+
+// RestoreBatch восстанавливает удаленные URL с заданными id. Возвращает id URL,
+// которые были восстановлены.
+func (m *Memory) RestoreBatch(_ context.Context, urlIDs []string, userID string) ([]string, error) {
+	defer track(m.observer, "restore_batch")()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	restored := make([]string, 0, len(urlIDs))
+	for _, urlID := range urlIDs {
+		if !m.belongsToUser(urlID, userID) || !m.deleted[urlID] || m.urls[urlID] == "" {
+			continue
+		}
+
+		delete(m.deleted, urlID)
+		restored = append(restored, urlID)
+	}
+
+	return restored, m.renewPersistent()
+}
+
+// GetStats возвращает статистику использования сервиса с параметрами opts.
+// Размер хранилища — размер файла, в который сохраняются данные, или 0,
+// если данные хранятся только в памяти.
+func (m *Memory) GetStats(_ context.Context, opts model.StatsOptions) (model.Stats, error) {
+	defer track(m.observer, "get_stats")()
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var (
+		stats   = model.Stats{}
+		now     = time.Now()
+		users   = map[string]struct{}{}
+		days    = map[string]int{}
+		domains = map[string]int{}
+		links   = make([]model.LinkClicks, 0)
+	)
+	for id := range m.urls {
+		if created := m.created[id]; !created.IsZero() && !created.Before(opts.Since) {
+			days[created.UTC().Format(model.DayFormat)]++
+		}
+
+		if m.deleted[id] {
+			stats.Deleted++
+
+			continue
+		}
+
+		stats.URLs++
+		expired := m.link(id).Expired(now)
+		if expired {
+			stats.Expired++
+		}
+		if m.disabled[id] {
+			stats.Disabled++
+		} else if !expired {
+			stats.Active++
+		}
+		if userID := m.owners[id]; userID != "" {
+			users[userID] = struct{}{}
+		}
+		if domain := model.Domain(m.canonical[id]); domain != "" {
+			domains[domain]++
+		}
+
+		var clicks int64
+		for _, c := range m.clicks[id] {
+			clicks += c
+		}
+		if clicks > 0 {
+			links = append(links, model.LinkClicks{ID: id, URL: m.urls[id], Clicks: clicks})
+		}
+	}
+	stats.Users = len(users)
+
+	stats.CreatedPerDay = make([]model.DayCount, 0, len(days))
+	for date, count := range days {
+		stats.CreatedPerDay = append(stats.CreatedPerDay, model.DayCount{Date: date, Count: count})
+	}
+	sort.Slice(stats.CreatedPerDay, func(i, j int) bool {
+		return stats.CreatedPerDay[i].Date < stats.CreatedPerDay[j].Date
+	})
+
+	sort.Slice(links, func(i, j int) bool {
+		if links[i].Clicks != links[j].Clicks {
+			return links[i].Clicks > links[j].Clicks
+		}
+
+		return links[i].ID < links[j].ID
+	})
+	if len(links) > opts.Top {
+		links = links[:opts.Top]
+	}
+	stats.TopLinks = links
+
+	stats.TopDomains = make([]model.DomainCount, 0, len(domains))
+	for domain, count := range domains {
+		stats.TopDomains = append(stats.TopDomains, model.DomainCount{Domain: domain, Count: count})
+	}
+	sort.Slice(stats.TopDomains, func(i, j int) bool {
+		if stats.TopDomains[i].Count != stats.TopDomains[j].Count {
+			return stats.TopDomains[i].Count > stats.TopDomains[j].Count
+		}
+
+		return stats.TopDomains[i].Domain < stats.TopDomains[j].Domain
+	})
+	if len(stats.TopDomains) > opts.Top {
+		stats.TopDomains = stats.TopDomains[:opts.Top]
+	}
+
+	if m.persistent != nil {
+		info, err := m.persistent.Stat()
+		if err != nil {
+			return model.Stats{}, err
+		}
+		stats.StorageSize = info.Size()
+	}
+
+	return stats, nil
+}
+
+// IsUserBanned проверяет, заблокирован ли пользователь администратором.
+func (m *Memory) IsUserBanned(_ context.Context, userID string) (bool, error) {
+	defer track(m.observer, "is_user_banned")()
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.banned[userID], nil
+}
+
+// GetLink возвращает сохраненный URL по id вместе с его атрибутами,
+// в том числе удаленный или заблокированный.
+func (m *Memory) GetLink(_ context.Context, id string) (model.Link, error) {
+	defer track(m.observer, "get_link")()
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if _, ok := m.urls[id]; !ok {
+		return model.Link{}, inerr.ErrURLNotFound
+	}
+
+	return m.link(id), nil
+}
+
+// FindLinksByURL возвращает все сохраненные URL, исходный или канонический вид
+// которых совпадает с заданным адресом назначения.
+func (m *Memory) FindLinksByURL(_ context.Context, url string) ([]model.Link, error) {
+	defer track(m.observer, "find_links_by_u_r_l")()
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	links := make([]model.Link, 0)
+	for id, u := range m.urls {
+		if u == url || m.canonical[id] == url {
+			links = append(links, m.link(id))
+		}
+	}
+	sort.Slice(links, func(i, j int) bool {
+		return links[i].ID < links[j].ID
+	})
+
+	return links, nil
+}
+
+// GetUserLinks возвращает все URL пользователя, в том числе удаленные и заблокированные.
+func (m *Memory) GetUserLinks(_ context.Context, userID string) ([]model.Link, error) {
+	defer track(m.observer, "get_user_links")()
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	links := make([]model.Link, 0, len(m.userData[userID]))
+	for _, id := range m.userData[userID] {
+		links = append(links, m.link(id))
+	}
+
+	return links, nil
+}
+
+// GetRules возвращает правила условного редиректа URL пользователя userID.
+// Если URL не найден или принадлежит другому пользователю, возвращает ошибку errors.ErrURLNotFound.
+func (m *Memory) GetRules(_ context.Context, id, userID string) ([]model.RedirectRule, error) {
+	defer track(m.observer, "get_rules")()
+
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	url, ok := m.urls[id]
-	if !ok {
-		return "", ErrKeyNotFound
+	if _, ok := m.urls[id]; !ok || m.owners[id] != userID {
+		return nil, inerr.ErrURLNotFound
 	}
 
-	if url == deletedFlag {
-		return "", inerr.ErrURLIsDeleted
+	return m.rules[id], nil
+}
+
+// SetRules заменяет правила условного редиректа URL пользователя userID.
+// Если URL не найден или принадлежит другому пользователю, возвращает ошибку errors.ErrURLNotFound.
+func (m *Memory) SetRules(_ context.Context, id, userID string, rules []model.RedirectRule) error {
+	defer track(m.observer, "set_rules")()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.urls[id]; !ok || m.owners[id] != userID {
+		return inerr.ErrURLNotFound
+	}
+
+	data, err := json.Marshal(rules)
+	if err != nil {
+		return err
 	}
 
-	return url, nil
+	if err := m.saveToPersistent(rulesSectionName, id, string(data)); err != nil {
+		return err
+	}
+	m.setRules(id, rules)
+
+	return nil
 }
 
-// GetAllUser возвращает все сохраненные URL пользователя.
-func (m *Memory) GetAllUser(ctx context.Context, userID string) map[string]string {
+// GetVariants возвращает варианты адреса назначения URL пользователя userID.
+// Если URL не найден или принадлежит другому пользователю, возвращает ошибку errors.ErrURLNotFound.
+func (m *Memory) GetVariants(_ context.Context, id, userID string) (model.Variants, error) {
+	defer track(m.observer, "get_variants")()
+
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	data := map[string]string{}
-	ids, ok := m.userData[userID]
-	if !ok {
-		return data
+	if _, ok := m.urls[id]; !ok || m.owners[id] != userID {
+		return model.Variants{}, inerr.ErrURLNotFound
 	}
 
+	return m.variants[id], nil
+}
+
+// SetVariants заменяет варианты адреса назначения URL пользователя userID.
+// Счетчики переходов на варианты сохраняются.
+// Если URL не найден или принадлежит другому пользователю, возвращает ошибку errors.ErrURLNotFound.
+func (m *Memory) SetVariants(_ context.Context, id, userID string, variants model.Variants) error {
+	defer track(m.observer, "set_variants")()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.urls[id]; !ok || m.owners[id] != userID {
+		return inerr.ErrURLNotFound
+	}
+
+	data, err := json.Marshal(variants)
+	if err != nil {
+		return err
+	}
+
+	if err := m.saveToPersistent(variantsSectionName, id, string(data)); err != nil {
+		return err
+	}
+	m.setVariants(id, variants)
+
+	return nil
+}
+
+// AddVariantClicks увеличивает счетчики переходов на варианты адреса назначения URL
+// на количество переходов из clicks. Пустое имя варианта соответствует основному
+// адресу назначения.
+func (m *Memory) AddVariantClicks(_ context.Context, clicks []model.VariantClicks) error {
+	defer track(m.observer, "add_variant_clicks")()
+
+	var (
+		ids     []string
+		byURLID = map[string]map[string]int64{}
+	)
+	for _, c := range clicks {
+		if byURLID[c.URLID] == nil {
+			byURLID[c.URLID] = map[string]int64{}
+			ids = append(ids, c.URLID)
+		}
+		byURLID[c.URLID][c.Variant] += c.Clicks
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	for _, id := range ids {
-		url, err := m.Get(ctx, id)
-		if err == nil {
-			data[id] = url
+		data, err := json.Marshal(byURLID[id])
+		if err != nil {
+			return err
+		}
+
+		if err = m.saveToPersistent(clicksSectionName, id, string(data)); err != nil {
+			return err
+		}
+		for variant, count := range byURLID[id] {
+			m.addClicks(id, variant, count)
 		}
 	}
 
-	return data
+	return nil
 }
 
-// DeleteBatch удаляет URL с заданными id.
-func (m *Memory) DeleteBatch(_ context.Context, urlIDs []string, userID string) error {
+// GetVariantClicks возвращает количество переходов на варианты адреса назначения
+// URL пользователя userID в формате {имя варианта: количество}.
+// Если URL не найден или принадлежит другому пользователю, возвращает ошибку errors.ErrURLNotFound.
+func (m *Memory) GetVariantClicks(_ context.Context, id, userID string) (map[string]int64, error) {
+	defer track(m.observer, "get_variant_clicks")()
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if _, ok := m.urls[id]; !ok || m.owners[id] != userID {
+		return nil, inerr.ErrURLNotFound
+	}
+
+	clicks := make(map[string]int64, len(m.clicks[id]))
+	for name, count := range m.clicks[id] {
+		clicks[name] = count
+	}
+
+	return clicks, nil
+}
+
+// GetRedirectOptions возвращает параметры формирования адреса назначения URL пользователя userID.
+// Если URL не найден или принадлежит другому пользователю, возвращает ошибку errors.ErrURLNotFound.
+func (m *Memory) GetRedirectOptions(_ context.Context, id, userID string) (model.RedirectOptions, error) {
+	defer track(m.observer, "get_redirect_options")()
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if _, ok := m.urls[id]; !ok || m.owners[id] != userID {
+		return model.RedirectOptions{}, inerr.ErrURLNotFound
+	}
+
+	return m.options[id], nil
+}
+
+// SetRedirectOptions заменяет параметры формирования адреса назначения URL пользователя userID.
+// Если URL не найден или принадлежит другому пользователю, возвращает ошибку errors.ErrURLNotFound.
+func (m *Memory) SetRedirectOptions(_ context.Context, id, userID string, opts model.RedirectOptions) error {
+	defer track(m.observer, "set_redirect_options")()
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	for _, urlID := range urlIDs {
-		if !m.belongsToUser(urlID, userID) {
+	if _, ok := m.urls[id]; !ok || m.owners[id] != userID {
+		return inerr.ErrURLNotFound
+	}
+
+	data, err := json.Marshal(opts)
+	if err != nil {
+		return err
+	}
+
+	if err := m.saveToPersistent(optionsSectionName, id, string(data)); err != nil {
+		return err
+	}
+	m.setRedirectOptions(id, opts)
+
+	return nil
+}
+
+// SetMetadata сохраняет метаданные страницы назначения URL id. Если у URL нет заголовка,
+// заголовком становится title. Если URL не найден, возвращает ошибку errors.ErrURLNotFound.
+func (m *Memory) SetMetadata(_ context.Context, id string, md model.Metadata, title string) error {
+	defer track(m.observer, "set_metadata")()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.urls[id]; !ok {
+		return inerr.ErrURLNotFound
+	}
+
+	m.setMetadata(id, md)
+	if m.titles[id] == "" {
+		m.setTitle(id, title)
+	}
+
+	return m.renewPersistent()
+}
+
+// GetLinksToCheck возвращает не более limit неудаленных URL, адрес назначения которых
+// не проверялся или проверялся раньше checkedBefore, начиная с давно не проверявшихся.
+func (m *Memory) GetLinksToCheck(_ context.Context, checkedBefore time.Time, limit int) ([]model.Link, error) {
+	defer track(m.observer, "get_links_to_check")()
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	links := make([]model.Link, 0)
+	for id := range m.urls {
+		if m.deleted[id] || !m.health[id].CheckedAt.Before(checkedBefore) {
 			continue
 		}
 
-		m.urls[urlID] = deletedFlag
+		links = append(links, m.link(id))
 	}
+	sort.Slice(links, func(i, j int) bool {
+		if !links[i].Health.CheckedAt.Equal(links[j].Health.CheckedAt) {
+			return links[i].Health.CheckedAt.Before(links[j].Health.CheckedAt)
+		}
 
-	return m.renewPersistent()
+		return links[i].ID < links[j].ID
+	})
+	if len(links) > limit {
+		links = links[:limit]
+	}
+
+	return links, nil
+}
+
+// SetHealth сохраняет результат проверки доступности адреса назначения URL id.
+// Результат хранится только в памяти и в файл не записывается, чтобы периодические
+// проверки не увеличивали его размер: после перезапуска URL проверяются заново.
+// Если URL не найден, возвращает ошибку errors.ErrURLNotFound.
+func (m *Memory) SetHealth(_ context.Context, id string, h model.LinkHealth) error {
+	defer track(m.observer, "set_health")()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.urls[id]; !ok {
+		return inerr.ErrURLNotFound
+	}
+
+	m.health[id] = h
+
+	return nil
 }
 
-// GetStat возвращает количество сокращённых URL в сервисе и количество пользователей в сервисе.
-func (m *Memory) GetStat(_ context.Context) (int, int, error) {
+// CountHealthStatuses возвращает количество неудаленных URL и количество URL
+// по кодам ответа последней проверки адреса назначения. Непроверенные URL
+// в statuses не учитываются.
+func (m *Memory) CountHealthStatuses(_ context.Context) (int, map[int]int, error) {
+	defer track(m.observer, "count_health_statuses")()
+
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	return len(m.urls), len(m.userData), nil
+	var (
+		total    int
+		statuses = map[int]int{}
+	)
+	for id := range m.urls {
+		if m.deleted[id] {
+			continue
+		}
+
+		total++
+		if h := m.health[id]; h.Checked() {
+			statuses[h.Status]++
+		}
+	}
+
+	return total, statuses, nil
+}
+
+// SetDisabled блокирует или разблокирует URL независимо от его владельца.
+func (m *Memory) SetDisabled(_ context.Context, id string, disabled bool) error {
+	defer track(m.observer, "set_disabled")()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.urls[id]; !ok {
+		return inerr.ErrURLNotFound
+	}
+
+	if err := m.saveToPersistent(disabledSectionName, id, strconv.FormatBool(disabled)); err != nil {
+		return err
+	}
+	m.setFlag(m.disabled, id, disabled)
+
+	return nil
+}
+
+// SetUserBanned блокирует или разблокирует пользователю возможность создавать URL.
+func (m *Memory) SetUserBanned(_ context.Context, userID string, banned bool) error {
+	defer track(m.observer, "set_user_banned")()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.saveToPersistent(bannedSectionName, userID, strconv.FormatBool(banned)); err != nil {
+		return err
+	}
+	m.setFlag(m.banned, userID, banned)
+
+	return nil
+}
+
+// AddAdminAction сохраняет запись в журнал действий администратора.
+func (m *Memory) AddAdminAction(_ context.Context, a model.AdminAction) error {
+	defer track(m.observer, "add_admin_action")()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	data, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+
+	if err := m.saveToPersistent(adminActionSectionName, a.AdminID, string(data)); err != nil {
+		return err
+	}
+	m.adminActions = append(m.adminActions, a)
+
+	return nil
 }
 
 func (m *Memory) loadDataInMemory() {
@@ -135,14 +753,113 @@ func (m *Memory) loadDataInMemory() {
 		return
 	}
 
-	scanner := bufio.NewScanner(m.persistent)
+	var (
+		scanner = bufio.NewScanner(m.persistent)
+		line    = 0
+	)
 	for scanner.Scan() {
-		sectionAndKeyVal := strings.Split(scanner.Text(), ",")
-		switch sectionAndKeyVal[0] {
+		line++
+		sectionAndKeyVal := strings.SplitN(scanner.Text(), ",", 3)
+		if len(sectionAndKeyVal) < 3 {
+			m.logger.Warn("Skipping malformed storage file record", "line", line)
+
+			continue
+		}
+
+		var (
+			section  = sectionAndKeyVal[0]
+			key, val = sectionAndKeyVal[1], sectionAndKeyVal[2]
+			err      error
+		)
+		switch section {
 		case urlSectionName:
-			m.urls[sectionAndKeyVal[1]] = sectionAndKeyVal[2]
+			if val == deletedFlag {
+				m.deleted[key] = true
+				val = ""
+			}
+			m.urls[key] = val
+		case canonicalSectionName:
+			m.canonical[key] = val
+		case displaySectionName:
+			m.display[key] = val
+		case passwordSectionName:
+			m.passwords[key] = val
+		case titleSectionName:
+			m.setTitle(key, val)
+		case createdSectionName:
+			t := time.Time{}
+			if t, err = time.Parse(time.RFC3339Nano, val); err == nil {
+				m.setTime(m.created, key, t)
+			}
+		case expiresSectionName:
+			t := time.Time{}
+			if t, err = time.Parse(time.RFC3339Nano, val); err == nil {
+				m.setTime(m.expires, key, t)
+			}
+		case rulesSectionName:
+			rules := make([]model.RedirectRule, 0)
+			if err = json.Unmarshal([]byte(val), &rules); err == nil {
+				m.setRules(key, rules)
+			}
+		case variantsSectionName:
+			variants := model.Variants{}
+			if err = json.Unmarshal([]byte(val), &variants); err == nil {
+				m.setVariants(key, variants)
+			}
+		case optionsSectionName:
+			opts := model.RedirectOptions{}
+			if err = json.Unmarshal([]byte(val), &opts); err == nil {
+				m.setRedirectOptions(key, opts)
+			}
+		case metadataSectionName:
+			md := model.Metadata{}
+			if err = json.Unmarshal([]byte(val), &md); err == nil {
+				m.setMetadata(key, md)
+			}
+		case clickSectionName:
+			// Отдельные переходы, записанные предыдущими версиями сервиса.
+			m.addClicks(key, val, 1)
+		case clicksSectionName:
+			clicks := map[string]int64{}
+			if err = json.Unmarshal([]byte(val), &clicks); err == nil {
+				for name, count := range clicks {
+					m.addClicks(key, name, count)
+				}
+			}
 		case userSectionName:
-			m.userData[sectionAndKeyVal[1]] = append(m.userData[sectionAndKeyVal[1]], sectionAndKeyVal[2])
+			m.userData[key] = append(m.userData[key], val)
+			m.owners[val] = key
+		case deletedSectionName:
+			m.deleted[key] = true
+		case disabledSectionName:
+			m.setFlag(m.disabled, key, val == strconv.FormatBool(true))
+		case bannedSectionName:
+			m.setFlag(m.banned, key, val == strconv.FormatBool(true))
+		case adminActionSectionName:
+			a := model.AdminAction{}
+			if err = json.Unmarshal([]byte(val), &a); err == nil {
+				m.adminActions = append(m.adminActions, a)
+			}
+		}
+		if err != nil {
+			m.logger.Warn("Skipping unreadable storage file record", "line", line, "section", section, "error", err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		m.logger.Error("Error while reading storage file", "error", err)
+	}
+
+	// URL, сохраненные до появления канонического и отображаемого вида,
+	// используются в исходном виде.
+	for id, url := range m.urls {
+		if _, ok := m.canonical[id]; !ok {
+			m.canonical[id] = url
+		}
+		if _, ok := m.display[id]; !ok {
+			m.display[id] = url
+		}
+		if c := m.canonical[id]; c != "" && m.passwords[id] == "" && m.expires[id].IsZero() {
+			m.byCanonical[canonicalKey(m.owners[id], c)] = id
 		}
 	}
 }
@@ -163,6 +880,77 @@ func (m *Memory) renewPersistent() error {
 		if err := m.saveToPersistent(urlSectionName, id, url); err != nil {
 			return err
 		}
+		if err := m.saveToPersistent(canonicalSectionName, id, m.canonical[id]); err != nil {
+			return err
+		}
+		if err := m.saveToPersistent(displaySectionName, id, m.display[id]); err != nil {
+			return err
+		}
+		if hash, ok := m.passwords[id]; ok {
+			if err := m.saveToPersistent(passwordSectionName, id, hash); err != nil {
+				return err
+			}
+		}
+		if title, ok := m.titles[id]; ok {
+			if err := m.saveToPersistent(titleSectionName, id, title); err != nil {
+				return err
+			}
+		}
+		if created, ok := m.created[id]; ok {
+			if err := m.saveToPersistent(createdSectionName, id, created.Format(time.RFC3339Nano)); err != nil {
+				return err
+			}
+		}
+		if expires, ok := m.expires[id]; ok {
+			if err := m.saveToPersistent(expiresSectionName, id, expires.Format(time.RFC3339Nano)); err != nil {
+				return err
+			}
+		}
+		if rules, ok := m.rules[id]; ok {
+			data, err := json.Marshal(rules)
+			if err != nil {
+				return err
+			}
+			if err := m.saveToPersistent(rulesSectionName, id, string(data)); err != nil {
+				return err
+			}
+		}
+		if variants, ok := m.variants[id]; ok {
+			data, err := json.Marshal(variants)
+			if err != nil {
+				return err
+			}
+			if err := m.saveToPersistent(variantsSectionName, id, string(data)); err != nil {
+				return err
+			}
+		}
+		if opts, ok := m.options[id]; ok {
+			data, err := json.Marshal(opts)
+			if err != nil {
+				return err
+			}
+			if err := m.saveToPersistent(optionsSectionName, id, string(data)); err != nil {
+				return err
+			}
+		}
+		if md, ok := m.metadata[id]; ok {
+			data, err := json.Marshal(md)
+			if err != nil {
+				return err
+			}
+			if err := m.saveToPersistent(metadataSectionName, id, string(data)); err != nil {
+				return err
+			}
+		}
+		if clicks, ok := m.clicks[id]; ok {
+			data, err := json.Marshal(clicks)
+			if err != nil {
+				return err
+			}
+			if err := m.saveToPersistent(clicksSectionName, id, string(data)); err != nil {
+				return err
+			}
+		}
 	}
 	for userID, ids := range m.userData {
 		for _, id := range ids {
@@ -171,6 +959,29 @@ func (m *Memory) renewPersistent() error {
 			}
 		}
 	}
+	for _, flags := range []struct {
+		section string
+		values  map[string]bool
+	}{
+		{section: deletedSectionName, values: m.deleted},
+		{section: disabledSectionName, values: m.disabled},
+		{section: bannedSectionName, values: m.banned},
+	} {
+		for key := range flags.values {
+			if err := m.saveToPersistent(flags.section, key, strconv.FormatBool(true)); err != nil {
+				return err
+			}
+		}
+	}
+	for _, a := range m.adminActions {
+		data, err := json.Marshal(a)
+		if err != nil {
+			return err
+		}
+		if err := m.saveToPersistent(adminActionSectionName, a.AdminID, string(data)); err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
@@ -194,3 +1005,107 @@ func (m *Memory) belongsToUser(urlID, userID string) bool {
 
 	return false
 }
+
+func (m *Memory) link(id string) model.Link {
+	return model.Link{
+		ID:              id,
+		URL:             m.urls[id],
+		CanonicalURL:    m.canonical[id],
+		DisplayURL:      m.display[id],
+		PasswordHash:    m.passwords[id],
+		Title:           m.titles[id],
+		Rules:           m.rules[id],
+		Variants:        m.variants[id],
+		RedirectOptions: m.options[id],
+		Metadata:        m.metadata[id],
+		Health:          m.health[id],
+		UserID:          m.owners[id],
+		Deleted:         m.deleted[id],
+		Disabled:        m.disabled[id],
+		CreatedAt:       m.created[id],
+		ExpiresAt:       m.expires[id],
+	}
+}
+
+// canonicalKey возвращает ключ для поиска URL без пароля по каноническому виду
+// среди URL пользователя userID.
+func canonicalKey(userID, canonicalURL string) string {
+	return userID + " " + canonicalURL
+}
+
+func (m *Memory) setTitle(id, title string) {
+	if title == "" {
+		delete(m.titles, id)
+
+		return
+	}
+
+	m.titles[id] = title
+}
+
+func (m *Memory) setTime(times map[string]time.Time, id string, t time.Time) {
+	if t.IsZero() {
+		delete(times, id)
+
+		return
+	}
+
+	times[id] = t
+}
+
+func (m *Memory) setRules(id string, rules []model.RedirectRule) {
+	if len(rules) == 0 {
+		delete(m.rules, id)
+
+		return
+	}
+
+	m.rules[id] = rules
+}
+
+func (m *Memory) setVariants(id string, variants model.Variants) {
+	if len(variants.Items) == 0 {
+		delete(m.variants, id)
+
+		return
+	}
+
+	m.variants[id] = variants
+}
+
+func (m *Memory) setRedirectOptions(id string, opts model.RedirectOptions) {
+	if opts == (model.RedirectOptions{}) {
+		delete(m.options, id)
+
+		return
+	}
+
+	m.options[id] = opts
+}
+
+func (m *Memory) setMetadata(id string, md model.Metadata) {
+	if md == (model.Metadata{}) {
+		delete(m.metadata, id)
+
+		return
+	}
+
+	m.metadata[id] = md
+}
+
+func (m *Memory) addClicks(id, variant string, count int64) {
+	if m.clicks[id] == nil {
+		m.clicks[id] = map[string]int64{}
+	}
+	m.clicks[id][variant] += count
+}
+
+func (m *Memory) setFlag(flags map[string]bool, key string, val bool) {
+	if val {
+		flags[key] = true
+
+		return
+	}
+
+	delete(flags, key)
+}
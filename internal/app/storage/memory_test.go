@@ -1,14 +1,18 @@
 package storage
 
 import (
+	"bytes"
 	"context"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	inerr "github.com/ivanpodgorny/urlshortener/internal/app/errors"
+	"github.com/ivanpodgorny/urlshortener/internal/app/logger"
+	"github.com/ivanpodgorny/urlshortener/internal/app/model"
 )
 
 func TestMemoryWithFile(t *testing.T) {
@@ -18,35 +22,40 @@ func TestMemoryWithFile(t *testing.T) {
 		wrongID           = "id2"
 		idToDelete        = "id3"
 		url               = "https://ya.ru/"
+		urlToDelete       = "https://www.google.com/"
 		userID            = "userID1"
 		userWithoutURLsID = "userID2"
+		title             = "Яндекс, поиск"
+		createdAt         = time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
 		ctx               = context.Background()
 	)
 
 	s, file := createFileStorage(t, filename)
 
-	insertedID, err := s.Add(ctx, id, url, userID)
+	insertedID, err := s.Add(ctx, model.Link{ID: id, URL: url, CanonicalURL: url, DisplayURL: url, Title: title, UserID: userID, CreatedAt: createdAt})
 	assert.NoError(t, err, "добавление новой записи")
 	assert.Equal(t, id, insertedID, "добавление новой записи")
-	_, err = s.Add(ctx, id, url, userID)
+	_, err = s.Add(ctx, model.Link{ID: id, URL: url, CanonicalURL: url, DisplayURL: url, UserID: userID})
 	assert.Error(t, err, "добавление записи c существующим id")
 	stored, err := s.Get(ctx, id)
 	assert.NoError(t, err, "получение записи")
-	assert.Equal(t, url, stored, "получение записи")
+	assert.Equal(t, url, stored.CanonicalURL, "получение записи")
 	_, err = s.Get(ctx, wrongID)
 	assert.Error(t, err, "получение несуществующей записи")
-	urls := s.GetAllUser(ctx, userID)
+	urls := userDisplayURLs(t, s, userID)
 	assert.Equal(t, map[string]string{id: url}, urls, "получение URL пользователя")
-	urls = s.GetAllUser(ctx, userWithoutURLsID)
+	urls = userDisplayURLs(t, s, userWithoutURLsID)
 	assert.Equal(t, map[string]string{}, urls, "получение URL пользователя, не добавлявшего URL")
-	_, _ = s.Add(ctx, idToDelete, url, userID)
-	err = s.DeleteBatch(ctx, []string{idToDelete}, userWithoutURLsID)
+	_, _ = s.Add(ctx, model.Link{ID: idToDelete, URL: urlToDelete, CanonicalURL: urlToDelete, DisplayURL: urlToDelete, UserID: userID})
+	deleted, err := s.DeleteBatch(ctx, []string{idToDelete}, userWithoutURLsID)
 	assert.NoError(t, err, "попытка удаления чужой записи")
+	assert.Empty(t, deleted, "попытка удаления чужой записи")
 	notDeletedURL, err := s.Get(ctx, idToDelete)
 	assert.NoError(t, err, "попытка удаления чужой записи")
-	assert.Equal(t, url, notDeletedURL, "попытка удаления чужой записи")
-	err = s.DeleteBatch(ctx, []string{idToDelete}, userID)
+	assert.Equal(t, urlToDelete, notDeletedURL.CanonicalURL, "попытка удаления чужой записи")
+	deleted, err = s.DeleteBatch(ctx, []string{idToDelete}, userID)
 	assert.NoError(t, err, "удаление записи")
+	assert.Equal(t, []string{idToDelete}, deleted, "удаление записи")
 	_, err = s.Get(ctx, idToDelete)
 	assert.ErrorIs(t, err, inerr.ErrURLIsDeleted, "получение удаленной записи")
 
@@ -55,8 +64,10 @@ func TestMemoryWithFile(t *testing.T) {
 
 	stored, err = s.Get(context.Background(), id)
 	assert.NoError(t, err, "получение записи, сохраненной в файл")
-	assert.Equal(t, url, stored, "получение записи, сохраненной в файл")
-	urls = s.GetAllUser(context.Background(), userID)
+	assert.Equal(t, url, stored.CanonicalURL, "получение записи, сохраненной в файл")
+	assert.Equal(t, title, stored.Title, "получение заголовка, сохраненного в файл")
+	assert.Equal(t, createdAt, stored.CreatedAt, "получение времени создания, сохраненного в файл")
+	urls = userDisplayURLs(t, s, userID)
 	assert.Equal(t, map[string]string{id: url}, urls, "получение URL пользователя")
 	_, err = s.Get(ctx, idToDelete)
 	assert.ErrorIs(t, err, inerr.ErrURLIsDeleted, "получение удаленной записи")
@@ -73,56 +84,629 @@ func TestMemoryOnly(t *testing.T) {
 		userID            = "userID1"
 		userWithoutURLsID = "userID2"
 		ctx               = context.Background()
-		s                 = NewMemory(nil)
+		s                 = NewMemory(nil, nil)
 	)
 
-	insertedID, err := s.Add(ctx, id, url, userID)
+	insertedID, err := s.Add(ctx, model.Link{ID: id, URL: url, CanonicalURL: url, DisplayURL: url, UserID: userID})
 	assert.NoError(t, err, "добавление новой записи")
 	assert.Equal(t, id, insertedID, "добавление новой записи")
-	_, err = s.Add(ctx, id, url, userID)
+	_, err = s.Add(ctx, model.Link{ID: id, URL: url, CanonicalURL: url, DisplayURL: url, UserID: userID})
 	assert.Error(t, err, "добавление записи c существующим id")
+	storedID, err := s.Add(ctx, model.Link{ID: wrongID, URL: "HTTPS://YA.RU:443", CanonicalURL: url, DisplayURL: url, UserID: userID})
+	assert.NoError(t, err, "добавление записи с существующим каноническим URL")
+	assert.Equal(t, id, storedID, "добавление записи с существующим каноническим URL")
 	stored, err := s.Get(ctx, id)
 	assert.NoError(t, err, "получение записи")
-	assert.Equal(t, url, stored, "получение записи")
+	assert.Equal(t, url, stored.CanonicalURL, "получение записи")
 	_, err = s.Get(ctx, wrongID)
 	assert.Error(t, err, "получение несуществующей записи")
-	urls := s.GetAllUser(ctx, userID)
+	urls := userDisplayURLs(t, s, userID)
 	assert.Equal(t, map[string]string{id: url}, urls, "получение URL пользователя")
-	urls = s.GetAllUser(ctx, userWithoutURLsID)
+	urls = userDisplayURLs(t, s, userWithoutURLsID)
 	assert.Equal(t, map[string]string{}, urls, "получение URL пользователя, не добавлявшего URL")
-	err = s.DeleteBatch(ctx, []string{id}, userWithoutURLsID)
+	deleted, err := s.DeleteBatch(ctx, []string{id}, userWithoutURLsID)
 	assert.NoError(t, err, "попытка удаления чужой записи")
+	assert.Empty(t, deleted, "попытка удаления чужой записи")
 	notDeletedURL, err := s.Get(ctx, id)
 	assert.NoError(t, err, "попытка удаления чужой записи")
-	assert.Equal(t, url, notDeletedURL, "попытка удаления чужой записи")
-	err = s.DeleteBatch(ctx, []string{id}, userID)
+	assert.Equal(t, url, notDeletedURL.CanonicalURL, "попытка удаления чужой записи")
+	deleted, err = s.DeleteBatch(ctx, []string{id}, userID)
 	assert.NoError(t, err, "удаление записи")
+	assert.Equal(t, []string{id}, deleted, "удаление записи")
 	_, err = s.Get(ctx, id)
 	assert.ErrorIs(t, err, inerr.ErrURLIsDeleted, "получение удаленной записи")
+	deleted, err = s.DeleteBatch(ctx, []string{id}, userID)
+	assert.NoError(t, err, "повторное удаление записи")
+	assert.Empty(t, deleted, "повторное удаление записи")
+	restored, err := s.RestoreBatch(ctx, []string{id}, userWithoutURLsID)
+	assert.NoError(t, err, "попытка восстановления чужой записи")
+	assert.Empty(t, restored, "попытка восстановления чужой записи")
+	restored, err = s.RestoreBatch(ctx, []string{id}, userID)
+	assert.NoError(t, err, "восстановление записи")
+	assert.Equal(t, []string{id}, restored, "восстановление записи")
+	stored, err = s.Get(ctx, id)
+	assert.NoError(t, err, "получение восстановленной записи")
+	assert.Equal(t, url, stored.CanonicalURL, "получение восстановленной записи")
 }
 
-func TestMemory_GetStat(t *testing.T) {
+func TestMemory_GetStats(t *testing.T) {
 	var (
-		ctx = context.Background()
-		s   = NewMemory(nil)
+		filename = "test_stats"
+		ctx      = context.Background()
+		today    = time.Date(2023, 5, 10, 12, 0, 0, 0, time.UTC)
 	)
 
-	_, err := s.Add(ctx, "id1", "https://ya.ru/", "userID1")
+	s, file := createFileStorage(t, filename)
+	for _, l := range []model.Link{
+		{ID: "id1", URL: "https://ya.ru/", CanonicalURL: "https://ya.ru/", UserID: "userID1", CreatedAt: today},
+		{ID: "id2", URL: "https://YA.ru/a", CanonicalURL: "https://ya.ru/a", UserID: "userID1", CreatedAt: today.AddDate(0, 0, -1)},
+		{ID: "id3", URL: "https://google.com/", CanonicalURL: "https://google.com/", UserID: "userID2", CreatedAt: today.AddDate(0, 0, -1)},
+		{ID: "id4", URL: "https://practicum.yandex.ru/", CanonicalURL: "https://practicum.yandex.ru/", UserID: "userID3", CreatedAt: today},
+		{ID: "id5", URL: "https://old.ru/", CanonicalURL: "https://old.ru/", UserID: "userID1", CreatedAt: today.AddDate(0, 0, -10), ExpiresAt: today},
+	} {
+		_, err := s.Add(ctx, l)
+		require.NoError(t, err)
+	}
+	_, err := s.DeleteBatch(ctx, []string{"id4"}, "userID3")
+	require.NoError(t, err)
+	require.NoError(t, s.SetDisabled(ctx, "id3", true))
+	require.NoError(t, s.AddVariantClicks(ctx, []model.VariantClicks{
+		{URLID: "id2", Variant: "", Clicks: 1},
+		{URLID: "id2", Variant: "b", Clicks: 1},
+		{URLID: "id1", Variant: "", Clicks: 1},
+		{URLID: "id3", Variant: "", Clicks: 1},
+		{URLID: "id4", Variant: "", Clicks: 1},
+	}))
+
+	stats, err := s.GetStats(ctx, model.StatsOptions{Since: today.AddDate(0, 0, -1).Truncate(24 * time.Hour), Top: 2})
+	assert.NoError(t, err)
+	info, err := file.Stat()
+	require.NoError(t, err)
+	assert.Equal(t, model.Stats{
+		URLs:     4,
+		Users:    2,
+		Active:   2,
+		Deleted:  1,
+		Expired:  1,
+		Disabled: 1,
+		CreatedPerDay: []model.DayCount{
+			{Date: "2023-05-09", Count: 2},
+			{Date: "2023-05-10", Count: 2},
+		},
+		TopLinks: []model.LinkClicks{
+			{ID: "id2", URL: "https://YA.ru/a", Clicks: 2},
+			{ID: "id1", URL: "https://ya.ru/", Clicks: 1},
+		},
+		TopDomains: []model.DomainCount{
+			{Domain: "ya.ru", Count: 2},
+			{Domain: "google.com", Count: 1},
+		},
+		StorageSize: info.Size(),
+	}, stats)
+	assert.NotZero(t, stats.StorageSize)
+
+	require.NoError(t, file.Close(), "не удалось закрыть файл")
+	require.NoError(t, os.Remove(filename))
+
+	stats, err = NewMemory(nil, nil).GetStats(ctx, model.StatsOptions{Top: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, model.Stats{CreatedPerDay: []model.DayCount{}, TopLinks: []model.LinkClicks{}, TopDomains: []model.DomainCount{}}, stats, "пустое хранилище")
+}
+
+func TestMemory_Admin(t *testing.T) {
+	var (
+		filename     = "test_admin"
+		id           = "id1"
+		deletedID    = "id2"
+		url          = "https://ya.ru/?a=1,2"
+		canonicalURL = "https://ya.ru/?a=1%2C2"
+		deletedURL   = "HTTPS://YA.RU:443/?a=1,2"
+		userID       = "userID1"
+		adminID      = "adminID"
+		ctx          = context.Background()
+	)
+
+	s, file := createFileStorage(t, filename)
+	_, err := s.Add(ctx, model.Link{ID: id, URL: url, CanonicalURL: canonicalURL, DisplayURL: url, UserID: userID})
+	require.NoError(t, err)
+	_, err = s.Add(ctx, model.Link{ID: deletedID, URL: deletedURL, CanonicalURL: url, DisplayURL: url, UserID: userID})
+	require.NoError(t, err)
+	_, err = s.DeleteBatch(ctx, []string{deletedID}, userID)
+	require.NoError(t, err)
+
+	assert.NoError(t, s.SetDisabled(ctx, id, true), "блокировка URL")
+	_, err = s.Get(ctx, id)
+	assert.ErrorIs(t, err, inerr.ErrURLIsDisabled, "получение заблокированного URL")
+	assert.Equal(t, map[string]string{id: url}, userDisplayURLs(t, s, userID), "заблокированный URL в списке пользователя")
+	assert.ErrorIs(t, s.SetDisabled(ctx, "missing", true), inerr.ErrURLNotFound, "блокировка несуществующего URL")
+	_, err = s.GetLink(ctx, "missing")
+	assert.ErrorIs(t, err, inerr.ErrURLNotFound, "получение несуществующего URL")
+
+	assert.NoError(t, s.SetUserBanned(ctx, userID, true), "блокировка пользователя")
+	banned, err := s.IsUserBanned(ctx, userID)
+	assert.NoError(t, err)
+	assert.True(t, banned, "блокировка пользователя")
+	assert.NoError(t, s.AddAdminAction(ctx, model.AdminAction{AdminID: adminID, Action: "ban_user", Target: userID}))
+
+	require.NoError(t, file.Close(), "не удалось закрыть файл")
+	s, file = createFileStorage(t, filename)
+
+	wantLinks := []model.Link{
+		{ID: id, URL: url, CanonicalURL: canonicalURL, DisplayURL: url, UserID: userID, Disabled: true},
+		{ID: deletedID, URL: deletedURL, CanonicalURL: url, DisplayURL: url, UserID: userID, Deleted: true},
+	}
+	link, err := s.GetLink(ctx, id)
+	assert.NoError(t, err, "получение URL, сохраненного в файл")
+	assert.Equal(t, wantLinks[0], link, "получение URL, сохраненного в файл")
+	links, err := s.FindLinksByURL(ctx, url)
+	assert.NoError(t, err, "поиск по исходному и каноническому адресу назначения")
+	assert.Equal(t, wantLinks, links, "поиск по исходному и каноническому адресу назначения")
+	links, err = s.GetUserLinks(ctx, userID)
+	assert.NoError(t, err, "получение всех URL пользователя")
+	assert.Equal(t, wantLinks, links, "получение всех URL пользователя")
+	banned, err = s.IsUserBanned(ctx, userID)
+	assert.NoError(t, err)
+	assert.True(t, banned, "блокировка пользователя, сохраненная в файл")
+	assert.Len(t, s.adminActions, 1, "журнал действий администратора, сохраненный в файл")
+
+	assert.NoError(t, s.SetDisabled(ctx, id, false), "разблокировка URL")
+	assert.NoError(t, s.SetUserBanned(ctx, userID, false), "разблокировка пользователя")
+	stored, err := s.Get(ctx, id)
+	assert.NoError(t, err, "получение разблокированного URL")
+	assert.Equal(t, canonicalURL, stored.CanonicalURL, "получение разблокированного URL в каноническом виде")
+	banned, err = s.IsUserBanned(ctx, userID)
+	assert.NoError(t, err)
+	assert.False(t, banned, "разблокировка пользователя")
+
+	require.NoError(t, file.Close(), "не удалось закрыть файл")
+	require.NoError(t, os.Remove(filename))
+}
+
+func TestMemory_URLForms(t *testing.T) {
+	var (
+		filename     = "test_url_forms"
+		id           = "id1"
+		legacyID     = "id2"
+		url          = "https://Пример.рф/п"
+		canonicalURL = "https://xn--e1afmkfd.xn--p1ai/%D0%BF"
+		displayURL   = "https://пример.рф/п"
+		legacyURL    = "https://ya.ru/"
+		userID       = "userID1"
+		ctx          = context.Background()
+	)
+
+	require.NoError(t, os.WriteFile(filename, []byte("url,"+legacyID+","+legacyURL+"\nuser,"+userID+","+legacyID+"\n"), 0600))
+	s, file := createFileStorage(t, filename)
+
+	storedID, err := s.Add(ctx, model.Link{ID: id, URL: legacyURL, CanonicalURL: legacyURL, DisplayURL: legacyURL, UserID: userID})
+	assert.NoError(t, err, "добавление URL, сохраненного до появления канонического вида")
+	assert.Equal(t, legacyID, storedID, "добавление URL, сохраненного до появления канонического вида")
+	_, err = s.Add(ctx, model.Link{ID: id, URL: url, CanonicalURL: canonicalURL, DisplayURL: displayURL, UserID: userID})
+	require.NoError(t, err)
+
+	require.NoError(t, file.Close(), "не удалось закрыть файл")
+	s, file = createFileStorage(t, filename)
+
+	stored, err := s.Get(ctx, id)
+	assert.NoError(t, err, "получение канонического вида")
+	assert.Equal(t, canonicalURL, stored.CanonicalURL, "получение канонического вида")
+	stored, err = s.Get(ctx, legacyID)
+	assert.NoError(t, err, "получение URL, сохраненного до появления канонического вида")
+	assert.Equal(t, legacyURL, stored.CanonicalURL, "получение URL, сохраненного до появления канонического вида")
+	assert.Equal(
+		t,
+		map[string]string{id: displayURL, legacyID: legacyURL},
+		userDisplayURLs(t, s, userID),
+		"получение отображаемого вида URL пользователя",
+	)
+	link, err := s.GetLink(ctx, id)
+	assert.NoError(t, err)
+	assert.Equal(t, model.Link{ID: id, URL: url, CanonicalURL: canonicalURL, DisplayURL: displayURL, UserID: userID}, link)
+
+	require.NoError(t, file.Close(), "не удалось закрыть файл")
+	require.NoError(t, os.Remove(filename))
+}
+
+func TestMemory_DuplicateOwner(t *testing.T) {
+	var (
+		filename = "test_duplicate_owner"
+		url      = "https://ya.ru/"
+		userID   = "userID1"
+		otherID  = "userID2"
+		ctx      = context.Background()
+	)
+
+	s, file := createFileStorage(t, filename)
+	_, err := s.Add(ctx, model.Link{ID: "id1", URL: url, CanonicalURL: url, DisplayURL: url, UserID: userID})
+	require.NoError(t, err)
+	require.NoError(t, s.SetRules(ctx, "id1", userID, []model.RedirectRule{{Platforms: []string{"ios"}, URL: "https://apps.apple.com/"}}))
+	storedID, err := s.Add(ctx, model.Link{ID: "id2", URL: url, CanonicalURL: url, DisplayURL: url, UserID: otherID})
+	assert.NoError(t, err)
+	assert.Equal(t, "id2", storedID, "URL другого пользователя не считается дубликатом")
+	storedID, err = s.Add(ctx, model.Link{ID: "id3", URL: url, CanonicalURL: url, DisplayURL: url, UserID: userID})
+	assert.NoError(t, err)
+	assert.Equal(t, "id1", storedID, "дубликат URL того же пользователя")
+
+	require.NoError(t, file.Close(), "не удалось закрыть файл")
+	s, file = createFileStorage(t, filename)
+
+	storedID, err = s.Add(ctx, model.Link{ID: "id4", URL: url, CanonicalURL: url, DisplayURL: url, UserID: otherID})
+	assert.NoError(t, err)
+	assert.Equal(t, "id2", storedID, "дубликат URL после загрузки из файла")
+	link, err := s.Get(ctx, storedID)
+	assert.NoError(t, err)
+	assert.Empty(t, link.Rules, "правила другого пользователя не применяются")
+
+	require.NoError(t, file.Close(), "не удалось закрыть файл")
+	require.NoError(t, os.Remove(filename))
+}
+
+func TestMemory_Expiry(t *testing.T) {
+	var (
+		filename  = "test_expiry"
+		url       = "https://ya.ru/"
+		userID    = "userID1"
+		ctx       = context.Background()
+		expiresAt = time.Now().UTC().Add(time.Hour).Truncate(time.Second)
+	)
+
+	s, file := createFileStorage(t, filename)
+	storedID, err := s.Add(ctx, model.Link{ID: "id1", URL: url, CanonicalURL: url, DisplayURL: url, UserID: userID, ExpiresAt: expiresAt})
+	assert.NoError(t, err)
+	assert.Equal(t, "id1", storedID)
+	storedID, err = s.Add(ctx, model.Link{ID: "id2", URL: url, CanonicalURL: url, DisplayURL: url, UserID: userID})
+	assert.NoError(t, err)
+	assert.Equal(t, "id2", storedID, "бессрочный URL не совпадает с URL со сроком действия")
+	storedID, err = s.Add(ctx, model.Link{ID: "id3", URL: url, CanonicalURL: url, DisplayURL: url, UserID: userID, ExpiresAt: expiresAt})
+	assert.NoError(t, err)
+	assert.Equal(t, "id3", storedID, "URL со сроком действия не считается дубликатом")
+	_, err = s.Add(ctx, model.Link{ID: "id4", URL: url, CanonicalURL: url, DisplayURL: url, UserID: userID, ExpiresAt: time.Now().Add(-time.Minute)})
+	require.NoError(t, err)
+
+	require.NoError(t, file.Close(), "не удалось закрыть файл")
+	s, file = createFileStorage(t, filename)
+
+	link, err := s.Get(ctx, "id1")
+	assert.NoError(t, err)
+	assert.Equal(t, expiresAt, link.ExpiresAt, "срок действия из файла")
+	_, err = s.Get(ctx, "id4")
+	assert.ErrorIs(t, err, inerr.ErrURLIsExpired, "получение URL с истекшим сроком действия")
+	storedID, err = s.Add(ctx, model.Link{ID: "id5", URL: url, CanonicalURL: url, DisplayURL: url, UserID: userID})
+	assert.NoError(t, err)
+	assert.Equal(t, "id2", storedID, "дубликат бессрочного URL после загрузки из файла")
+
+	require.NoError(t, file.Close(), "не удалось закрыть файл")
+	require.NoError(t, os.Remove(filename))
+}
+
+func TestMemory_PasswordProtected(t *testing.T) {
+	var (
+		filename    = "test_password"
+		id          = "id1"
+		protectedID = "id2"
+		url         = "https://ya.ru/"
+		hash        = "$2a$10$hash"
+		userID      = "userID1"
+		ctx         = context.Background()
+	)
+
+	s, file := createFileStorage(t, filename)
+	storedID, err := s.Add(ctx, model.Link{ID: protectedID, URL: url, CanonicalURL: url, DisplayURL: url, PasswordHash: hash, UserID: userID})
+	assert.NoError(t, err)
+	assert.Equal(t, protectedID, storedID, "добавление URL с паролем")
+	storedID, err = s.Add(ctx, model.Link{ID: id, URL: url, CanonicalURL: url, DisplayURL: url, UserID: userID})
+	assert.NoError(t, err)
+	assert.Equal(t, id, storedID, "URL без пароля не совпадает с URL с паролем")
+	storedID, err = s.Add(ctx, model.Link{ID: "id3", URL: url, CanonicalURL: url, DisplayURL: url, PasswordHash: hash, UserID: userID})
+	assert.NoError(t, err)
+	assert.Equal(t, "id3", storedID, "URL с паролем не считается дубликатом")
+
+	require.NoError(t, file.Close(), "не удалось закрыть файл")
+	s, file = createFileStorage(t, filename)
+
+	stored, err := s.Get(ctx, protectedID)
+	assert.NoError(t, err)
+	assert.Equal(t, hash, stored.PasswordHash, "получение хеша пароля из файла")
+	storedID, err = s.Add(ctx, model.Link{ID: "id4", URL: url, CanonicalURL: url, DisplayURL: url, UserID: userID})
+	assert.NoError(t, err)
+	assert.Equal(t, id, storedID, "дубликат URL без пароля после загрузки из файла")
+
+	require.NoError(t, file.Close(), "не удалось закрыть файл")
+	require.NoError(t, os.Remove(filename))
+}
+
+func TestMemory_Rules(t *testing.T) {
+	var (
+		filename = "test_rules"
+		id       = "id1"
+		url      = "https://example.com/"
+		userID   = "userID1"
+		ctx      = context.Background()
+		rules    = []model.RedirectRule{
+			{Platforms: []string{"ios"}, URL: "https://apps.apple.com/app/id1"},
+			{Countries: []string{"DE"}, Languages: []string{"de"}, URL: "https://example.com/de"},
+		}
+	)
+
+	s, file := createFileStorage(t, filename)
+	_, err := s.Add(ctx, model.Link{ID: id, URL: url, CanonicalURL: url, DisplayURL: url, UserID: userID})
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, s.SetRules(ctx, id, "userID2", rules), inerr.ErrURLNotFound, "правила чужого URL")
+	assert.ErrorIs(t, s.SetRules(ctx, "id2", userID, rules), inerr.ErrURLNotFound, "правила несуществующего URL")
+	_, err = s.GetRules(ctx, id, "userID2")
+	assert.ErrorIs(t, err, inerr.ErrURLNotFound, "получение правил чужого URL")
+	assert.NoError(t, s.SetRules(ctx, id, userID, rules), "сохранение правил")
+
+	require.NoError(t, file.Close(), "не удалось закрыть файл")
+	s, file = createFileStorage(t, filename)
+
+	stored, err := s.GetRules(ctx, id, userID)
+	assert.NoError(t, err)
+	assert.Equal(t, rules, stored, "получение правил из файла")
+	link, err := s.Get(ctx, id)
+	assert.NoError(t, err)
+	assert.Equal(t, rules, link.Rules, "правила в атрибутах URL")
+
+	assert.NoError(t, s.SetRules(ctx, id, userID, nil), "удаление правил")
+	_, err = s.DeleteBatch(ctx, []string{id}, userID)
+	require.NoError(t, err)
+	require.NoError(t, file.Close(), "не удалось закрыть файл")
+	s, file = createFileStorage(t, filename)
+
+	stored, err = s.GetRules(ctx, id, userID)
+	assert.NoError(t, err)
+	assert.Empty(t, stored, "правила удалены")
+
+	require.NoError(t, file.Close(), "не удалось закрыть файл")
+	require.NoError(t, os.Remove(filename))
+}
+
+func TestMemory_Variants(t *testing.T) {
+	var (
+		filename = "test_variants"
+		id       = "id1"
+		url      = "https://example.com/"
+		userID   = "userID1"
+		ctx      = context.Background()
+		variants = model.Variants{
+			Sticky: true,
+			Items: []model.Variant{
+				{Name: "a", URL: "https://example.com/a", Weight: 70},
+				{Name: "b", URL: "https://example.com/b", Weight: 30},
+			},
+		}
+	)
+
+	s, file := createFileStorage(t, filename)
+	_, err := s.Add(ctx, model.Link{ID: id, URL: url, CanonicalURL: url, DisplayURL: url, UserID: userID})
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, s.SetVariants(ctx, id, "userID2", variants), inerr.ErrURLNotFound, "варианты чужого URL")
+	_, err = s.GetVariants(ctx, id, "userID2")
+	assert.ErrorIs(t, err, inerr.ErrURLNotFound, "получение вариантов чужого URL")
+	_, err = s.GetVariantClicks(ctx, "id2", userID)
+	assert.ErrorIs(t, err, inerr.ErrURLNotFound, "переходы несуществующего URL")
+	assert.NoError(t, s.SetVariants(ctx, id, userID, variants), "сохранение вариантов")
+	assert.NoError(t, s.AddVariantClicks(ctx, []model.VariantClicks{
+		{URLID: id, Variant: "a", Clicks: 1},
+		{URLID: id, Variant: "b", Clicks: 1},
+	}))
+	assert.NoError(t, s.AddVariantClicks(ctx, []model.VariantClicks{{URLID: id, Variant: "a", Clicks: 1}}))
+
+	require.NoError(t, file.Close(), "не удалось закрыть файл")
+	s, file = createFileStorage(t, filename)
+
+	stored, err := s.GetVariants(ctx, id, userID)
+	assert.NoError(t, err)
+	assert.Equal(t, variants, stored, "получение вариантов из файла")
+	link, err := s.Get(ctx, id)
+	assert.NoError(t, err)
+	assert.Equal(t, variants, link.Variants, "варианты в атрибутах URL")
+	clicks, err := s.GetVariantClicks(ctx, id, userID)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int64{"a": 2, "b": 1}, clicks, "переходы из файла")
+
+	assert.NoError(t, s.SetVariants(ctx, id, userID, model.Variants{}), "удаление вариантов")
+	assert.NoError(t, s.AddVariantClicks(ctx, []model.VariantClicks{{URLID: id, Variant: "b", Clicks: 1}}))
+	_, err = s.DeleteBatch(ctx, []string{id}, userID)
 	require.NoError(t, err)
-	_, err = s.Add(ctx, "id2", "https://google.com/", "userID1")
+	require.NoError(t, file.Close(), "не удалось закрыть файл")
+	s, file = createFileStorage(t, filename)
+
+	stored, err = s.GetVariants(ctx, id, userID)
+	assert.NoError(t, err)
+	assert.Empty(t, stored.Items, "варианты удалены")
+	clicks, err = s.GetVariantClicks(ctx, id, userID)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int64{"a": 2, "b": 2}, clicks, "переходы после перезаписи файла")
+
+	require.NoError(t, file.Close(), "не удалось закрыть файл")
+	require.NoError(t, os.Remove(filename))
+}
+
+func TestMemory_RedirectOptions(t *testing.T) {
+	var (
+		filename = "test_redirect_options"
+		id       = "id1"
+		url      = "https://example.com/"
+		userID   = "userID1"
+		ctx      = context.Background()
+		opts     = model.RedirectOptions{Query: model.QueryPassthroughMerge, Path: true, Template: true}
+	)
+
+	s, file := createFileStorage(t, filename)
+	_, err := s.Add(ctx, model.Link{ID: id, URL: url, CanonicalURL: url, DisplayURL: url, UserID: userID})
 	require.NoError(t, err)
-	_, err = s.Add(ctx, "id3", "https://practicum.yandex.ru/", "userID2")
+
+	assert.ErrorIs(t, s.SetRedirectOptions(ctx, id, "userID2", opts), inerr.ErrURLNotFound, "параметры чужого URL")
+	_, err = s.GetRedirectOptions(ctx, "id2", userID)
+	assert.ErrorIs(t, err, inerr.ErrURLNotFound, "параметры несуществующего URL")
+	assert.NoError(t, s.SetRedirectOptions(ctx, id, userID, opts), "сохранение параметров")
+
+	require.NoError(t, file.Close(), "не удалось закрыть файл")
+	s, file = createFileStorage(t, filename)
+
+	stored, err := s.GetRedirectOptions(ctx, id, userID)
+	assert.NoError(t, err)
+	assert.Equal(t, opts, stored, "получение параметров из файла")
+	link, err := s.Get(ctx, id)
+	assert.NoError(t, err)
+	assert.Equal(t, opts, link.RedirectOptions, "параметры в атрибутах URL")
+
+	_, err = s.DeleteBatch(ctx, []string{id}, userID)
 	require.NoError(t, err)
+	require.NoError(t, file.Close(), "не удалось закрыть файл")
+	s, file = createFileStorage(t, filename)
 
-	urlCount, usersCount, err := s.GetStat(ctx)
+	stored, err = s.GetRedirectOptions(ctx, id, userID)
 	assert.NoError(t, err)
-	assert.Equal(t, 3, urlCount)
-	assert.Equal(t, 2, usersCount)
+	assert.Equal(t, opts, stored, "параметры после перезаписи файла")
+	assert.NoError(t, s.SetRedirectOptions(ctx, id, userID, model.RedirectOptions{}), "сброс параметров")
+	stored, err = s.GetRedirectOptions(ctx, id, userID)
+	assert.NoError(t, err)
+	assert.Zero(t, stored, "сброс параметров")
+
+	require.NoError(t, file.Close(), "не удалось закрыть файл")
+	require.NoError(t, os.Remove(filename))
+}
+
+func TestMemory_SetMetadata(t *testing.T) {
+	var (
+		filename = "test_metadata"
+		url      = "https://example.com/"
+		ctx      = context.Background()
+		md       = model.Metadata{Title: "Example", Description: "Example domain", SiteName: "IANA"}
+	)
+
+	s, file := createFileStorage(t, filename)
+	_, err := s.Add(ctx, model.Link{ID: "id1", URL: url, CanonicalURL: url, DisplayURL: url, UserID: "userID"})
+	require.NoError(t, err)
+	_, err = s.Add(ctx, model.Link{ID: "id2", URL: url, Title: "Мой заголовок", UserID: "userID", PasswordHash: "hash"})
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, s.SetMetadata(ctx, "id3", md, md.Title), inerr.ErrURLNotFound, "метаданные несуществующего URL")
+	assert.NoError(t, s.SetMetadata(ctx, "id1", md, md.Title))
+	assert.NoError(t, s.SetMetadata(ctx, "id2", md, md.Title))
+
+	require.NoError(t, file.Close(), "не удалось закрыть файл")
+	s, file = createFileStorage(t, filename)
+
+	link, err := s.GetLink(ctx, "id1")
+	assert.NoError(t, err)
+	assert.Equal(t, md, link.Metadata, "метаданные из файла")
+	assert.Equal(t, md.Title, link.Title, "заголовок из метаданных")
+	link, err = s.GetLink(ctx, "id2")
+	assert.NoError(t, err)
+	assert.Equal(t, "Мой заголовок", link.Title, "заголовок пользователя не заменяется")
+
+	require.NoError(t, file.Close(), "не удалось закрыть файл")
+	require.NoError(t, os.Remove(filename))
+}
+
+func TestMemory_Health(t *testing.T) {
+	var (
+		filename = "test_health"
+		url      = "https://example.com/"
+		ctx      = context.Background()
+		now      = time.Now().UTC().Truncate(time.Second)
+		ok       = model.LinkHealth{Status: 200, CheckedAt: now.Add(-time.Hour)}
+		broken   = model.LinkHealth{Status: 404, CheckedAt: now.Add(-2 * time.Hour)}
+	)
+
+	s, file := createFileStorage(t, filename)
+	for _, id := range []string{"id1", "id2", "id3", "id4"} {
+		_, err := s.Add(ctx, model.Link{ID: id, URL: url + id, CanonicalURL: url + id, DisplayURL: url + id, UserID: "userID"})
+		require.NoError(t, err)
+	}
+	_, err := s.DeleteBatch(ctx, []string{"id4"}, "userID")
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, s.SetHealth(ctx, "id5", ok), inerr.ErrURLNotFound, "проверка несуществующего URL")
+	assert.NoError(t, s.SetHealth(ctx, "id1", ok))
+	assert.NoError(t, s.SetHealth(ctx, "id2", broken))
+
+	link, err := s.GetLink(ctx, "id2")
+	assert.NoError(t, err)
+	assert.Equal(t, broken, link.Health, "результат проверки")
+
+	links, err := s.GetLinksToCheck(ctx, now.Add(-30*time.Minute), 10)
+	assert.NoError(t, err)
+	ids := make([]string, 0, len(links))
+	for _, l := range links {
+		ids = append(ids, l.ID)
+	}
+	assert.Equal(t, []string{"id3", "id2", "id1"}, ids, "сначала непроверенные, удаленные пропускаются")
+
+	links, err = s.GetLinksToCheck(ctx, now.Add(-90*time.Minute), 1)
+	assert.NoError(t, err)
+	assert.Len(t, links, 1, "ограничение количества")
+
+	total, statuses, err := s.CountHealthStatuses(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, total)
+	assert.Equal(t, map[int]int{200: 1, 404: 1}, statuses)
+
+	require.NoError(t, file.Close(), "не удалось закрыть файл")
+	s, file = createFileStorage(t, filename)
+
+	link, err = s.GetLink(ctx, "id2")
+	assert.NoError(t, err)
+	assert.Zero(t, link.Health, "результат проверки не сохраняется в файл")
+	links, err = s.GetLinksToCheck(ctx, now.Add(-30*time.Minute), 10)
+	assert.NoError(t, err)
+	assert.Len(t, links, 3, "после перезапуска URL проверяются заново")
+
+	require.NoError(t, file.Close(), "не удалось закрыть файл")
+	require.NoError(t, os.Remove(filename))
+}
+
+func TestMemory_LoadMalformedFile(t *testing.T) {
+	var (
+		filename = "test_malformed"
+		buf      = &bytes.Buffer{}
+	)
+	require.NoError(t, os.WriteFile(filename, []byte("url,id1,https://ya.ru/\nbroken\nrules,id1,{\n"), 0600))
+	defer func() {
+		require.NoError(t, os.Remove(filename))
+	}()
+	l, err := logger.New(buf, "info", logger.FormatText)
+	require.NoError(t, err)
+
+	file, err := os.OpenFile(filename, os.O_RDWR|os.O_APPEND, 0600)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, file.Close())
+	}()
+
+	s := NewMemory(file, l)
+	stored, err := s.Get(context.Background(), "id1")
+	assert.NoError(t, err, "корректные записи загружаются")
+	assert.Equal(t, "https://ya.ru/", stored.URL)
+	assert.Contains(t, buf.String(), "Skipping malformed storage file record", "некорректная строка")
+	assert.Contains(t, buf.String(), "line=2")
+	assert.Contains(t, buf.String(), "Skipping unreadable storage file record", "некорректное значение")
+	assert.Contains(t, buf.String(), "section=rules")
+}
+
+// userDisplayURLs возвращает отображаемый вид неудаленных URL пользователя.
+func userDisplayURLs(t *testing.T, s *Memory, userID string) map[string]string {
+	links, err := s.GetUserLinks(context.Background(), userID)
+	require.NoError(t, err)
+
+	urls := map[string]string{}
+	for _, l := range links {
+		if !l.Deleted {
+			urls[l.ID] = l.DisplayURL
+		}
+	}
+
+	return urls
 }
 
 func createFileStorage(t *testing.T, filename string) (*Memory, *os.File) {
 	file, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
 	require.NoError(t, err, "не удалось создать файл")
 
-	return NewMemory(file), file
+	return NewMemory(file, nil), file
 }
@@ -0,0 +1,22 @@
+package storage
+
+import "time"
+
+// OperationObserver интерфейс учета длительности операций хранилища.
+type OperationObserver interface {
+	ObserveStorageOperation(op string, d time.Duration)
+}
+
+// track начинает отсчет длительности операции op и возвращает функцию,
+// которая передает длительность в o. Если o равен nil, длительность не учитывается.
+func track(o OperationObserver, op string) func() {
+	if o == nil {
+		return func() {}
+	}
+
+	start := time.Now()
+
+	return func() {
+		o.ObserveStorageOperation(op, time.Since(start))
+	}
+}
@@ -0,0 +1,40 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/ivanpodgorny/urlshortener/internal/app/model"
+)
+
+type OperationObserverMock struct {
+	mock.Mock
+}
+
+func (m *OperationObserverMock) ObserveStorageOperation(op string, _ time.Duration) {
+	m.Called(op)
+}
+
+func TestMemory_SetObserver(t *testing.T) {
+	var (
+		ctx      = context.Background()
+		observer = &OperationObserverMock{}
+		s        = NewMemory(nil, nil)
+	)
+	observer.
+		On("ObserveStorageOperation", "add").Once().
+		On("ObserveStorageOperation", "get_link").Once()
+
+	_, err := s.Add(ctx, model.Link{ID: "id", URL: "https://ya.ru/"})
+	assert.NoError(t, err, "операции до установки получателя не учитываются")
+	s.SetObserver(observer)
+	_, err = s.Add(ctx, model.Link{ID: "id2", URL: "https://ya.ru/2"})
+	assert.NoError(t, err)
+	_, err = s.GetLink(ctx, "id")
+	assert.NoError(t, err)
+	observer.AssertExpectations(t)
+}
@@ -3,18 +3,25 @@ package storage
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5/pgconn"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
 
 	inerr "github.com/ivanpodgorny/urlshortener/internal/app/errors"
+	"github.com/ivanpodgorny/urlshortener/internal/app/model"
 )
 
 // Pg реализует интерфейс service.Storage для хранения url в PostgreSQL.
 type Pg struct {
-	db *sql.DB
+	db       *sql.DB
+	observer OperationObserver
 }
 
 // NewPg возвращает указатель на новый экземпляр Pg.
@@ -22,104 +29,959 @@ func NewPg(db *sql.DB) *Pg {
 	return &Pg{db: db}
 }
 
-// Add сохраняет URL. Если URL был сохранен ранее, возвращает его id.
-func (p *Pg) Add(ctx context.Context, id string, url string, userID string) (string, error) {
-	_, err := p.db.ExecContext(ctx, "insert into urls (user_id, url_id, url) values ($1, $2, $3)", userID, id, url)
+// SetObserver задает получателя длительности операций хранилища.
+// Должен вызываться до начала работы с хранилищем.
+func (p *Pg) SetObserver(o OperationObserver) {
+	p.observer = o
+}
+
+// operation начинает операцию хранилища op: создает дочерний спан ctx
+// и начинает отсчет ее длительности. Возвращает контекст со спаном и функцию,
+// которую нужно вызвать по завершении операции.
+func (p *Pg) operation(ctx context.Context, op string) (context.Context, func()) {
+	ctx, span := tracer.Start(
+		ctx,
+		"pg."+op,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemPostgreSQL, semconv.DBOperationKey.String(op)),
+	)
+	done := track(p.observer, op)
+
+	return ctx, func() {
+		done()
+		span.End()
+	}
+}
+
+// linkColumns столбцы таблицы urls в порядке полей, которые считывает scanLink.
+const linkColumns = "url_id, url, canonical_url, display_url, password_hash, title, redirect_rules, variants, redirect_options, metadata, user_id, deleted, disabled, created_at, health_status, health_error, health_checked_at, expires_at"
+
+// Add сохраняет URL в исходном, каноническом и отображаемом виде. Если бессрочный URL без пароля
+// с таким же каноническим видом был сохранен ранее тем же пользователем, возвращает его id.
+func (p *Pg) Add(ctx context.Context, l model.Link) (string, error) {
+	ctx, end := p.operation(ctx, "add")
+	defer end()
+
+	var (
+		createdAt = sql.NullTime{Time: l.CreatedAt, Valid: !l.CreatedAt.IsZero()}
+		expiresAt = sql.NullTime{Time: l.ExpiresAt, Valid: !l.ExpiresAt.IsZero()}
+	)
+	_, err := p.db.ExecContext(
+		ctx,
+		"insert into urls (user_id, url_id, url, canonical_url, display_url, password_hash, title, created_at, expires_at) values ($1, $2, $3, $4, $5, $6, $7, $8, $9)",
+		l.UserID,
+		l.ID,
+		l.URL,
+		l.CanonicalURL,
+		l.DisplayURL,
+		l.PasswordHash,
+		l.Title,
+		createdAt,
+		expiresAt,
+	)
 
-	if err != nil && err.(*pgconn.PgError).Code == pgerrcode.UniqueViolation {
+	if err != nil && err.(*pgconn.PgError).Code == pgerrcode.UniqueViolation && l.PasswordHash == "" && l.ExpiresAt.IsZero() {
 		storedID := ""
-		err = p.db.QueryRowContext(ctx, "select url_id from urls where url = $1", url).Scan(&storedID)
+		err = p.db.
+			QueryRowContext(
+				ctx,
+				"select url_id from urls where user_id = $1 and md5(canonical_url) = md5($2) and canonical_url = $2 and password_hash = '' and expires_at is null",
+				l.UserID,
+				l.CanonicalURL,
+			).
+			Scan(&storedID)
 
 		return storedID, err
 	}
 
-	return id, err
+	return l.ID, err
 }
 
 // Get возвращает сохраненный URL по id. Если URL был помечен удаленным, возвращает
-// ошибку errors.ErrURLIsDeleted.
-func (p *Pg) Get(ctx context.Context, id string) (string, error) {
-	var (
-		url     = ""
-		deleted = false
+// ошибку errors.ErrURLIsDeleted, если заблокирован администратором — errors.ErrURLIsDisabled,
+// если срок действия URL истек — errors.ErrURLIsExpired.
+func (p *Pg) Get(ctx context.Context, id string) (model.Link, error) {
+	ctx, end := p.operation(ctx, "get")
+	defer end()
+
+	l, err := scanLink(p.db.QueryRowContext(ctx, "select "+linkColumns+" from urls where url_id = $1", id))
+	if err != nil {
+		return l, err
+	}
+
+	if l.Deleted {
+		return l, inerr.ErrURLIsDeleted
+	}
+
+	if l.Disabled {
+		return l, inerr.ErrURLIsDisabled
+	}
+
+	if l.Expired(time.Now()) {
+		return l, inerr.ErrURLIsExpired
+	}
+
+	return l, nil
+}
+
+// DeleteBatch удаляет URL с заданными id. Возвращает id URL, которые были удалены.
+func (p *Pg) DeleteBatch(ctx context.Context, urlIDs []string, userID string) ([]string, error) {
+	ctx, end := p.operation(ctx, "delete_batch")
+	defer end()
+
+	return p.setDeleted(ctx, urlIDs, userID, true)
+}
+
+// RestoreBatch восстанавливает удаленные URL с заданными id. Возвращает id URL,
+// которые были восстановлены.
+func (p *Pg) RestoreBatch(ctx context.Context, urlIDs []string, userID string) ([]string, error) {
+	ctx, end := p.operation(ctx, "restore_batch")
+	defer end()
+
+	return p.setDeleted(ctx, urlIDs, userID, false)
+}
+
+// GetStats возвращает статистику использования сервиса с параметрами opts.
+// Размер хранилища — размер базы данных.
+func (p *Pg) GetStats(ctx context.Context, opts model.StatsOptions) (model.Stats, error) {
+	ctx, end := p.operation(ctx, "get_stats")
+	defer end()
+
+	stats := model.Stats{}
+	err := p.db.QueryRowContext(ctx, `
+select count(*) filter (where deleted = false),
+       count(distinct nullif(user_id, '')) filter (where deleted = false),
+       count(*) filter (where deleted = false and disabled = false and (expires_at is null or expires_at > now())),
+       count(*) filter (where deleted = true),
+       count(*) filter (where deleted = false and expires_at <= now()),
+       count(*) filter (where deleted = false and disabled = true),
+       pg_database_size(current_database())
+from urls
+	`).Scan(&stats.URLs, &stats.Users, &stats.Active, &stats.Deleted, &stats.Expired, &stats.Disabled, &stats.StorageSize)
+	if err != nil {
+		return model.Stats{}, err
+	}
+
+	if stats.CreatedPerDay, err = p.createdPerDay(ctx, opts.Since); err != nil {
+		return model.Stats{}, err
+	}
+	if stats.TopLinks, err = p.topLinks(ctx, opts.Top); err != nil {
+		return model.Stats{}, err
+	}
+	if stats.TopDomains, err = p.topDomains(ctx, opts.Top); err != nil {
+		return model.Stats{}, err
+	}
+
+	return stats, nil
+}
+
+// createdPerDay возвращает количество URL, созданных за каждый день начиная с since, по UTC.
+func (p *Pg) createdPerDay(ctx context.Context, since time.Time) ([]model.DayCount, error) {
+	rows, err := p.db.QueryContext(ctx, `
+select to_char(created_at at time zone 'UTC', 'YYYY-MM-DD') as day, count(*)
+from urls
+where created_at >= $1
+group by day
+order by day
+	`, since)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	days := make([]model.DayCount, 0)
+	for rows.Next() {
+		var d model.DayCount
+		if err = rows.Scan(&d.Date, &d.Count); err != nil {
+			return nil, err
+		}
+		days = append(days, d)
+	}
+
+	return days, rows.Err()
+}
+
+// topLinks возвращает не более top неудаленных URL с наибольшим количеством переходов.
+func (p *Pg) topLinks(ctx context.Context, top int) ([]model.LinkClicks, error) {
+	rows, err := p.db.QueryContext(ctx, `
+select u.url_id, u.url, sum(c.clicks) as total
+from urls u
+         join variant_clicks c on c.url_id = u.url_id
+where u.deleted = false
+group by u.url_id, u.url
+having sum(c.clicks) > 0
+order by total desc, u.url_id collate "C"
+limit $1
+	`, top)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	links := make([]model.LinkClicks, 0)
+	for rows.Next() {
+		var l model.LinkClicks
+		if err = rows.Scan(&l.ID, &l.URL, &l.Clicks); err != nil {
+			return nil, err
+		}
+		links = append(links, l)
+	}
+
+	return links, rows.Err()
+}
+
+// topDomains возвращает не более top доменов адресов назначения с наибольшим
+// количеством неудаленных URL. Домен выделяется из канонического вида адреса
+// так же, как в model.Domain.
+func (p *Pg) topDomains(ctx context.Context, top int) ([]model.DomainCount, error) {
+	rows, err := p.db.QueryContext(ctx, `
+select domain, count(*) as total
+from (select lower(trim(both '[]' from substring(
+        coalesce(nullif(canonical_url, ''), url)
+        from '^[^:/?#]+://(?:[^@/?#]*@)?(\[[^]]*\]|[^/:?#]*)'))) as domain
+      from urls
+      where deleted = false) d
+where domain <> ''
+group by domain
+order by total desc, domain collate "C"
+limit $1
+	`, top)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	domains := make([]model.DomainCount, 0)
+	for rows.Next() {
+		var d model.DomainCount
+		if err = rows.Scan(&d.Domain, &d.Count); err != nil {
+			return nil, err
+		}
+		domains = append(domains, d)
+	}
+
+	return domains, rows.Err()
+}
+
+// IsUserBanned проверяет, заблокирован ли пользователь администратором.
+func (p *Pg) IsUserBanned(ctx context.Context, userID string) (banned bool, err error) {
+	ctx, end := p.operation(ctx, "is_user_banned")
+	defer end()
+
+	err = p.db.
+		QueryRowContext(ctx, "select exists(select 1 from banned_users where user_id = $1)", userID).
+		Scan(&banned)
+
+	return banned, err
+}
+
+// GetLink возвращает сохраненный URL по id вместе с его атрибутами,
+// в том числе удаленный или заблокированный.
+func (p *Pg) GetLink(ctx context.Context, id string) (model.Link, error) {
+	ctx, end := p.operation(ctx, "get_link")
+	defer end()
+
+	l, err := scanLink(p.db.QueryRowContext(ctx, "select "+linkColumns+" from urls where url_id = $1", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return l, inerr.ErrURLNotFound
+	}
+
+	return l, err
+}
+
+// FindLinksByURL возвращает все сохраненные URL, исходный или канонический вид
+// которых совпадает с заданным адресом назначения.
+func (p *Pg) FindLinksByURL(ctx context.Context, url string) ([]model.Link, error) {
+	ctx, end := p.operation(ctx, "find_links_by_u_r_l")
+	defer end()
+
+	return p.queryLinks(
+		ctx,
+		"select "+linkColumns+" from urls where url = $1 or canonical_url = $1 order by url_id",
+		url,
 	)
-	if err := p.db.QueryRowContext(ctx, "select url, deleted from urls where url_id = $1", id).Scan(&url, &deleted); err != nil {
-		return url, err
+}
+
+// GetUserLinks возвращает все URL пользователя, в том числе удаленные и заблокированные.
+func (p *Pg) GetUserLinks(ctx context.Context, userID string) ([]model.Link, error) {
+	ctx, end := p.operation(ctx, "get_user_links")
+	defer end()
+
+	return p.queryLinks(ctx, "select "+linkColumns+" from urls where user_id = $1 order by id", userID)
+}
+
+// GetRules возвращает правила условного редиректа URL пользователя userID.
+// Если URL не найден или принадлежит другому пользователю, возвращает ошибку errors.ErrURLNotFound.
+func (p *Pg) GetRules(ctx context.Context, id, userID string) ([]model.RedirectRule, error) {
+	ctx, end := p.operation(ctx, "get_rules")
+	defer end()
+
+	var data []byte
+	err := p.db.
+		QueryRowContext(ctx, "select redirect_rules from urls where url_id = $1 and user_id = $2", id, userID).
+		Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, inerr.ErrURLNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return unmarshalRules(data)
+}
+
+// SetRules заменяет правила условного редиректа URL пользователя userID.
+// Если URL не найден или принадлежит другому пользователю, возвращает ошибку errors.ErrURLNotFound.
+func (p *Pg) SetRules(ctx context.Context, id, userID string, rules []model.RedirectRule) error {
+	ctx, end := p.operation(ctx, "set_rules")
+	defer end()
+
+	if rules == nil {
+		rules = []model.RedirectRule{}
+	}
+
+	data, err := json.Marshal(rules)
+	if err != nil {
+		return err
+	}
+
+	res, err := p.db.ExecContext(ctx, "update urls set redirect_rules = $3 where url_id = $1 and user_id = $2", id, userID, data)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return inerr.ErrURLNotFound
+	}
+
+	return nil
+}
+
+// GetVariants возвращает варианты адреса назначения URL пользователя userID.
+// Если URL не найден или принадлежит другому пользователю, возвращает ошибку errors.ErrURLNotFound.
+func (p *Pg) GetVariants(ctx context.Context, id, userID string) (model.Variants, error) {
+	ctx, end := p.operation(ctx, "get_variants")
+	defer end()
+
+	var data []byte
+	err := p.db.
+		QueryRowContext(ctx, "select variants from urls where url_id = $1 and user_id = $2", id, userID).
+		Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.Variants{}, inerr.ErrURLNotFound
+	}
+
+	if err != nil {
+		return model.Variants{}, err
+	}
+
+	return unmarshalVariants(data)
+}
+
+// SetVariants заменяет варианты адреса назначения URL пользователя userID.
+// Счетчики переходов на варианты сохраняются.
+// Если URL не найден или принадлежит другому пользователю, возвращает ошибку errors.ErrURLNotFound.
+func (p *Pg) SetVariants(ctx context.Context, id, userID string, variants model.Variants) error {
+	ctx, end := p.operation(ctx, "set_variants")
+	defer end()
+
+	if variants.Items == nil {
+		variants.Items = []model.Variant{}
+	}
+
+	data, err := json.Marshal(variants)
+	if err != nil {
+		return err
 	}
 
-	if deleted {
-		return url, inerr.ErrURLIsDeleted
+	res, err := p.db.ExecContext(ctx, "update urls set variants = $3 where url_id = $1 and user_id = $2", id, userID, data)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return inerr.ErrURLNotFound
+	}
+
+	return nil
+}
+
+// GetRedirectOptions возвращает параметры формирования адреса назначения URL пользователя userID.
+// Если URL не найден или принадлежит другому пользователю, возвращает ошибку errors.ErrURLNotFound.
+func (p *Pg) GetRedirectOptions(ctx context.Context, id, userID string) (model.RedirectOptions, error) {
+	ctx, end := p.operation(ctx, "get_redirect_options")
+	defer end()
+
+	var data []byte
+	err := p.db.
+		QueryRowContext(ctx, "select redirect_options from urls where url_id = $1 and user_id = $2", id, userID).
+		Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.RedirectOptions{}, inerr.ErrURLNotFound
+	}
+
+	if err != nil {
+		return model.RedirectOptions{}, err
+	}
+
+	opts := model.RedirectOptions{}
+
+	return opts, json.Unmarshal(data, &opts)
+}
+
+// SetRedirectOptions заменяет параметры формирования адреса назначения URL пользователя userID.
+// Если URL не найден или принадлежит другому пользователю, возвращает ошибку errors.ErrURLNotFound.
+func (p *Pg) SetRedirectOptions(ctx context.Context, id, userID string, opts model.RedirectOptions) error {
+	ctx, end := p.operation(ctx, "set_redirect_options")
+	defer end()
+
+	data, err := json.Marshal(opts)
+	if err != nil {
+		return err
+	}
+
+	res, err := p.db.ExecContext(ctx, "update urls set redirect_options = $3 where url_id = $1 and user_id = $2", id, userID, data)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
 	}
 
-	return url, nil
+	if affected == 0 {
+		return inerr.ErrURLNotFound
+	}
+
+	return nil
 }
 
-// GetAllUser возвращает все сохраненные URL пользователя.
-func (p *Pg) GetAllUser(ctx context.Context, userID string) map[string]string {
-	data := map[string]string{}
-	rows, err := p.db.QueryContext(ctx, "select url_id, url from urls where user_id = $1 and deleted = false", userID)
+// SetMetadata сохраняет метаданные страницы назначения URL id. Если у URL нет заголовка,
+// заголовком становится title. Если URL не найден, возвращает ошибку errors.ErrURLNotFound.
+func (p *Pg) SetMetadata(ctx context.Context, id string, md model.Metadata, title string) error {
+	ctx, end := p.operation(ctx, "set_metadata")
+	defer end()
+
+	data, err := json.Marshal(md)
+	if err != nil {
+		return err
+	}
+
+	res, err := p.db.ExecContext(
+		ctx,
+		"update urls set metadata = $2, title = case when title = '' then $3 else title end where url_id = $1",
+		id,
+		data,
+		title,
+	)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
 	if err != nil {
-		return data
+		return err
+	}
+
+	if affected == 0 {
+		return inerr.ErrURLNotFound
+	}
+
+	return nil
+}
+
+// GetLinksToCheck возвращает не более limit неудаленных URL, адрес назначения которых
+// не проверялся или проверялся раньше checkedBefore, начиная с давно не проверявшихся.
+func (p *Pg) GetLinksToCheck(ctx context.Context, checkedBefore time.Time, limit int) ([]model.Link, error) {
+	ctx, end := p.operation(ctx, "get_links_to_check")
+	defer end()
+
+	return p.queryLinks(
+		ctx,
+		"select "+linkColumns+" from urls where deleted = false and (health_checked_at is null or health_checked_at < $1) "+
+			"order by health_checked_at nulls first, id limit $2",
+		checkedBefore,
+		limit,
+	)
+}
+
+// SetHealth сохраняет результат проверки доступности адреса назначения URL id.
+// Если URL не найден, возвращает ошибку errors.ErrURLNotFound.
+func (p *Pg) SetHealth(ctx context.Context, id string, h model.LinkHealth) error {
+	ctx, end := p.operation(ctx, "set_health")
+	defer end()
+
+	res, err := p.db.ExecContext(
+		ctx,
+		"update urls set health_status = $2, health_error = $3, health_checked_at = $4 where url_id = $1",
+		id,
+		h.Status,
+		h.Error,
+		h.CheckedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return inerr.ErrURLNotFound
+	}
+
+	return nil
+}
+
+// CountHealthStatuses возвращает количество неудаленных URL и количество URL
+// по кодам ответа последней проверки адреса назначения. Непроверенные URL
+// в statuses не учитываются.
+func (p *Pg) CountHealthStatuses(ctx context.Context) (total int, statuses map[int]int, err error) {
+	ctx, end := p.operation(ctx, "count_health_statuses")
+	defer end()
+
+	rows, err := p.db.QueryContext(ctx, `
+select health_checked_at is not null, health_status, count(*)
+from urls
+where deleted = false
+group by 1, 2
+	`)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	statuses = map[int]int{}
+	for rows.Next() {
+		var (
+			checked bool
+			status  int
+			count   int
+		)
+		if err = rows.Scan(&checked, &status, &count); err != nil {
+			return 0, nil, err
+		}
+
+		total += count
+		if checked {
+			statuses[status] += count
+		}
+	}
+
+	return total, statuses, rows.Err()
+}
+
+// AddVariantClicks увеличивает счетчики переходов на варианты адреса назначения URL
+// на количество переходов из clicks одним запросом. Пустое имя варианта
+// соответствует основному адресу назначения.
+func (p *Pg) AddVariantClicks(ctx context.Context, clicks []model.VariantClicks) error {
+	if len(clicks) == 0 {
+		return nil
+	}
+
+	ctx, end := p.operation(ctx, "add_variant_clicks")
+	defer end()
+
+	var (
+		params       = make([]any, 0, len(clicks)*3)
+		placeholders = strings.Builder{}
+	)
+	for i, c := range clicks {
+		if i != 0 {
+			placeholders.WriteString(",")
+		}
+		placeholders.WriteString(fmt.Sprintf("($%d, $%d, $%d)", i*3+1, i*3+2, i*3+3))
+		params = append(params, c.URLID, c.Variant, c.Clicks)
+	}
+
+	_, err := p.db.ExecContext(ctx, `
+insert into variant_clicks (url_id, variant, clicks)
+values `+placeholders.String()+`
+on conflict (url_id, variant) do update set clicks = variant_clicks.clicks + excluded.clicks
+	`, params...)
+
+	return err
+}
+
+// GetVariantClicks возвращает количество переходов на варианты адреса назначения
+// URL пользователя userID в формате {имя варианта: количество}.
+// Если URL не найден или принадлежит другому пользователю, возвращает ошибку errors.ErrURLNotFound.
+func (p *Pg) GetVariantClicks(ctx context.Context, id, userID string) (map[string]int64, error) {
+	ctx, end := p.operation(ctx, "get_variant_clicks")
+	defer end()
+
+	rows, err := p.db.QueryContext(ctx, `
+select c.variant, c.clicks
+from urls u
+         left join variant_clicks c on c.url_id = u.url_id
+where u.url_id = $1
+  and u.user_id = $2
+	`, id, userID)
+	if err != nil {
+		return nil, err
 	}
 
 	defer func(rows *sql.Rows) {
 		_ = rows.Close()
 	}(rows)
 
+	var clicks map[string]int64
 	for rows.Next() {
 		var (
-			id  = ""
-			url = ""
+			variant sql.NullString
+			count   sql.NullInt64
 		)
-		err = rows.Scan(&id, &url)
+		if err = rows.Scan(&variant, &count); err != nil {
+			return nil, err
+		}
+
+		if clicks == nil {
+			clicks = map[string]int64{}
+		}
+		if variant.Valid {
+			clicks[variant.String] = count.Int64
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if clicks == nil {
+		return nil, inerr.ErrURLNotFound
+	}
+
+	return clicks, nil
+}
+
+// SetDisabled блокирует или разблокирует URL независимо от его владельца.
+func (p *Pg) SetDisabled(ctx context.Context, id string, disabled bool) error {
+	ctx, end := p.operation(ctx, "set_disabled")
+	defer end()
+
+	res, err := p.db.ExecContext(ctx, "update urls set disabled = $2 where url_id = $1", id, disabled)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return inerr.ErrURLNotFound
+	}
+
+	return nil
+}
+
+// SetUserBanned блокирует или разблокирует пользователю возможность создавать URL.
+func (p *Pg) SetUserBanned(ctx context.Context, userID string, banned bool) (err error) {
+	ctx, end := p.operation(ctx, "set_user_banned")
+	defer end()
+
+	if banned {
+		_, err = p.db.ExecContext(ctx, "insert into banned_users (user_id) values ($1) on conflict do nothing", userID)
+	} else {
+		_, err = p.db.ExecContext(ctx, "delete from banned_users where user_id = $1", userID)
+	}
+
+	return err
+}
+
+// AddAdminAction сохраняет запись в журнал действий администратора.
+func (p *Pg) AddAdminAction(ctx context.Context, a model.AdminAction) error {
+	ctx, end := p.operation(ctx, "add_admin_action")
+	defer end()
+
+	_, err := p.db.ExecContext(
+		ctx,
+		"insert into admin_actions (admin_id, action, target, created_at) values ($1, $2, $3, $4)",
+		a.AdminID,
+		a.Action,
+		a.Target,
+		a.CreatedAt,
+	)
+
+	return err
+}
+
+// AddAuditEvents сохраняет записи в журнал аудита.
+func (p *Pg) AddAuditEvents(ctx context.Context, events []model.AuditEvent) error {
+	ctx, end := p.operation(ctx, "add_audit_events")
+	defer end()
+
+	if len(events) == 0 {
+		return nil
+	}
+
+	var (
+		columns      = 7
+		params       = make([]any, 0, len(events)*columns)
+		placeholders = strings.Builder{}
+	)
+	for i, e := range events {
+		if i != 0 {
+			placeholders.WriteString(",")
+		}
+		placeholders.WriteString("(")
+		for j := 1; j <= columns; j++ {
+			if j != 1 {
+				placeholders.WriteString(",")
+			}
+			placeholders.WriteString(fmt.Sprintf("$%d", i*columns+j))
+		}
+		placeholders.WriteString(")")
+		params = append(params, e.LinkID, e.Action, e.UserID, e.OwnerID, e.ClientIP, e.Transport, e.CreatedAt)
+	}
+
+	_, err := p.db.ExecContext(
+		ctx,
+		"insert into audit_events (link_id, action, user_id, owner_id, client_ip, transport, created_at) values "+
+			placeholders.String(),
+		params...,
+	)
+
+	return err
+}
+
+// GetAuditEvents возвращает записи журнала аудита, удовлетворяющие фильтру f,
+// начиная с самых новых.
+func (p *Pg) GetAuditEvents(ctx context.Context, f model.AuditFilter) ([]model.AuditEvent, error) {
+	ctx, end := p.operation(ctx, "get_audit_events")
+	defer end()
+
+	rows, err := p.db.QueryContext(ctx, `
+select link_id, action, user_id, owner_id, client_ip, transport, created_at
+from audit_events
+where ($1 = '' or owner_id = $1)
+  and ($2 = '' or link_id = $2)
+order by created_at desc, id desc
+limit nullif($3, 0)
+	`, f.OwnerID, f.LinkID, f.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	events := make([]model.AuditEvent, 0)
+	for rows.Next() {
+		e := model.AuditEvent{}
+		if err = rows.Scan(&e.LinkID, &e.Action, &e.UserID, &e.OwnerID, &e.ClientIP, &e.Transport, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
+
+// DeleteAuditEventsBefore удаляет записи журнала аудита, созданные раньше t.
+func (p *Pg) DeleteAuditEventsBefore(ctx context.Context, t time.Time) error {
+	ctx, end := p.operation(ctx, "delete_audit_events_before")
+	defer end()
+
+	_, err := p.db.ExecContext(ctx, "delete from audit_events where created_at < $1", t)
+
+	return err
+}
+
+func (p *Pg) queryLinks(ctx context.Context, query string, args ...any) ([]model.Link, error) {
+	rows, err := p.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	links := make([]model.Link, 0)
+	for rows.Next() {
+		l, err := scanLink(rows)
 		if err != nil {
-			continue
+			return nil, err
 		}
 
-		data[id] = url
+		links = append(links, l)
 	}
 
-	if err := rows.Err(); err != nil {
-		return map[string]string{}
+	return links, rows.Err()
+}
+
+func scanLink(row interface{ Scan(dest ...any) error }) (model.Link, error) {
+	var (
+		l         = model.Link{}
+		rules     []byte
+		variants  []byte
+		options   []byte
+		metadata  []byte
+		createdAt sql.NullTime
+		checkedAt sql.NullTime
+		expiresAt sql.NullTime
+	)
+	err := row.Scan(
+		&l.ID, &l.URL, &l.CanonicalURL, &l.DisplayURL, &l.PasswordHash, &l.Title,
+		&rules, &variants, &options, &metadata, &l.UserID, &l.Deleted, &l.Disabled, &createdAt,
+		&l.Health.Status, &l.Health.Error, &checkedAt, &expiresAt,
+	)
+	if err != nil {
+		return l, err
+	}
+	l.CreatedAt = createdAt.Time
+	l.Health.CheckedAt = checkedAt.Time
+	l.ExpiresAt = expiresAt.Time
+
+	if l.Rules, err = unmarshalRules(rules); err != nil {
+		return l, err
+	}
+
+	if l.Variants, err = unmarshalVariants(variants); err != nil {
+		return l, err
+	}
+
+	if err = json.Unmarshal(metadata, &l.Metadata); err != nil {
+		return l, err
+	}
+
+	return l, json.Unmarshal(options, &l.RedirectOptions)
+}
+
+func unmarshalRules(data []byte) ([]model.RedirectRule, error) {
+	rules := make([]model.RedirectRule, 0)
+	if err := json.Unmarshal(data, &rules); err != nil || len(rules) == 0 {
+		return nil, err
 	}
 
-	return data
+	return rules, nil
 }
 
-// DeleteBatch удаляет URL с заданными id.
-func (p *Pg) DeleteBatch(ctx context.Context, urlIDs []string, userID string) error {
+func unmarshalVariants(data []byte) (model.Variants, error) {
+	variants := model.Variants{}
+	if err := json.Unmarshal(data, &variants); err != nil || len(variants.Items) == 0 {
+		return model.Variants{}, err
+	}
+
+	return variants, nil
+}
+
+func (p *Pg) setDeleted(ctx context.Context, urlIDs []string, userID string, deleted bool) ([]string, error) {
 	var (
-		params       = make([]any, len(urlIDs)+1)
+		params       = make([]any, len(urlIDs)+2)
 		placeholders = strings.Builder{}
 	)
 	params[0] = userID
+	params[1] = deleted
 	for i, urlID := range urlIDs {
 		if i != 0 {
 			placeholders.WriteString(",")
 		}
-		placeholders.WriteString(fmt.Sprintf("$%d", i+2))
-		params[i+1] = urlID
+		placeholders.WriteString(fmt.Sprintf("$%d", i+3))
+		params[i+2] = urlID
 	}
 
-	_, err := p.db.ExecContext(ctx, `
+	rows, err := p.db.QueryContext(ctx, `
 update urls
-set deleted = true
+set deleted = $2
 from (select unnest(array[`+placeholders.String()+`]) as url_id) as id_table
 where user_id = $1
   and urls.url_id = id_table.url_id
+  and deleted <> $2
+returning urls.url_id
 	`, params...)
+	if err != nil {
+		return nil, err
+	}
 
-	return err
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	ids := make([]string, 0, len(urlIDs))
+	for rows.Next() {
+		id := ""
+		if err = rows.Scan(&id); err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
 }
 
-// GetStat возвращает количество сокращённых URL в сервисе и количество пользователей в сервисе.
-func (p *Pg) GetStat(ctx context.Context) (urlCount int, usersCount int, err error) {
-	err = p.db.
-		QueryRowContext(ctx, "select count(url), count(distinct user_id) from urls where deleted = false").
-		Scan(&urlCount, &usersCount)
+// TakeToken забирает токен из корзины key с ограничением l. Если токенов
+// не осталось, возвращает false и время до появления следующего токена.
+// Пополнение и списание выполняются одним запросом, поэтому корзина может
+// использоваться несколькими экземплярами сервиса одновременно.
+func (p *Pg) TakeToken(ctx context.Context, key string, l model.RateLimit, now time.Time) (bool, time.Duration, error) {
+	ctx, end := p.operation(ctx, "take_token")
+	defer end()
+
+	var (
+		allowed = false
+		tokens  = 0.0
+	)
+	err := p.db.QueryRowContext(ctx, `
+insert into rate_limits as r (key, tokens, allowed, burst, rate, updated_at)
+values ($1, $2::double precision - 1, true, $2, $4, $3)
+on conflict (key) do update
+set allowed    = least($2::double precision, r.tokens + greatest(extract(epoch from $3 - r.updated_at)::double precision, 0) * $4::double precision) >= 1,
+    tokens     = least($2::double precision, r.tokens + greatest(extract(epoch from $3 - r.updated_at)::double precision, 0) * $4::double precision) -
+                 case when least($2::double precision, r.tokens + greatest(extract(epoch from $3 - r.updated_at)::double precision, 0) * $4::double precision) >= 1 then 1 else 0 end,
+    burst      = $2,
+    rate       = $4,
+    updated_at = $3
+returning allowed, tokens
+	`, key, l.Burst, now, l.Rate).Scan(&allowed, &tokens)
 	if err != nil {
-		return 0, 0, err
+		return false, 0, err
 	}
 
-	return urlCount, usersCount, nil
+	return allowed, retryAfter(allowed, tokens, l), nil
+}
+
+// DeleteFullBuckets удаляет корзины, которые к моменту now пополнились
+// до максимального размера и не отличаются от новых.
+func (p *Pg) DeleteFullBuckets(ctx context.Context, now time.Time) error {
+	ctx, end := p.operation(ctx, "delete_full_buckets")
+	defer end()
+
+	_, err := p.db.ExecContext(ctx, `
+delete from rate_limits
+where tokens + greatest(extract(epoch from $1 - updated_at)::double precision, 0) * rate >= burst
+	`, now)
+
+	return err
 }
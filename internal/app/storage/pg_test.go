@@ -6,6 +6,7 @@ import (
 	"database/sql/driver"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/DATA-DOG/go-txdb"
@@ -17,6 +18,7 @@ import (
 
 	"github.com/ivanpodgorny/urlshortener/internal/app/config"
 	inerr "github.com/ivanpodgorny/urlshortener/internal/app/errors"
+	"github.com/ivanpodgorny/urlshortener/internal/app/model"
 	"github.com/ivanpodgorny/urlshortener/internal/app/security"
 )
 
@@ -42,112 +44,298 @@ func TestPgRealConnection(t *testing.T) {
 		_ = db.Close()
 	}(db)
 
-	insertedID, err := s.Add(ctx, id, url, userID)
+	insertedID, err := s.Add(ctx, model.Link{ID: id, URL: url, CanonicalURL: url, DisplayURL: url, UserID: userID})
 	assert.NoError(t, err, "добавление новой записи")
 	assert.Equal(t, id, insertedID, "добавление новой записи")
-	urlCount, usersCount, err := s.GetStat(ctx)
+	stats, err := s.GetStats(ctx, model.StatsOptions{Top: 10})
 	assert.NoError(t, err, "получение статистики")
-	assert.Equal(t, 1, urlCount, "получение статистики")
-	assert.Equal(t, 1, usersCount, "получение статистики")
+	assert.Equal(t, 1, stats.URLs, "получение статистики")
+	assert.Equal(t, 1, stats.Users, "получение статистики")
+	assert.Equal(t, []model.DomainCount{{Domain: "ya.ru", Count: 1}}, stats.TopDomains, "получение статистики")
 	stored, err := s.Get(ctx, id)
 	assert.NoError(t, err, "получение записи")
-	assert.Equal(t, url, stored, "получение записи")
+	assert.Equal(t, url, stored.CanonicalURL, "получение записи")
 	_, err = s.Get(ctx, wrongID)
 	assert.Error(t, err, "получение несуществующей записи записи")
-	urls := s.GetAllUser(ctx, userID)
-	assert.Equal(t, map[string]string{id: url}, urls, "получение URL пользователя")
-	urls = s.GetAllUser(ctx, userWithoutURLsID)
-	assert.Equal(t, map[string]string{}, urls, "получение URL пользователя, не добавлявшего URL")
-	_, _ = s.Add(ctx, idToDelete, urlToDelete, userID)
-	err = s.DeleteBatch(ctx, []string{idToDelete}, userWithoutURLsID)
+	links, err := s.GetUserLinks(ctx, userID)
+	assert.NoError(t, err, "получение URL пользователя")
+	assert.Len(t, links, 1, "получение URL пользователя")
+	links, err = s.GetUserLinks(ctx, userWithoutURLsID)
+	assert.NoError(t, err, "получение URL пользователя, не добавлявшего URL")
+	assert.Empty(t, links, "получение URL пользователя, не добавлявшего URL")
+	_, _ = s.Add(ctx, model.Link{ID: idToDelete, URL: urlToDelete, CanonicalURL: urlToDelete, DisplayURL: urlToDelete, UserID: userID})
+	_, err = s.DeleteBatch(ctx, []string{idToDelete}, userWithoutURLsID)
 	assert.NoError(t, err, "попытка удаления чужой записи")
 	notDeletedURL, err := s.Get(ctx, idToDelete)
 	assert.NoError(t, err, "попытка удаления чужой записи")
-	assert.Equal(t, urlToDelete, notDeletedURL, "попытка удаления чужой записи")
-	err = s.DeleteBatch(ctx, []string{idToDelete}, userID)
+	assert.Equal(t, urlToDelete, notDeletedURL.CanonicalURL, "попытка удаления чужой записи")
+	deleted, err := s.DeleteBatch(ctx, []string{idToDelete}, userID)
 	assert.NoError(t, err, "удаление записи")
+	assert.Equal(t, []string{idToDelete}, deleted, "удаление записи")
 	_, err = s.Get(ctx, idToDelete)
 	assert.ErrorIs(t, err, inerr.ErrURLIsDeleted, "получение удаленной записи")
-	_, err = s.Add(ctx, id, url, userID)
+	insertedID, err = s.Add(ctx, model.Link{ID: "id4", URL: url, CanonicalURL: url, DisplayURL: url, UserID: userWithoutURLsID})
+	assert.NoError(t, err, "добавление URL, сохраненного другим пользователем")
+	assert.Equal(t, "id4", insertedID, "URL другого пользователя не считается дубликатом")
+	_, err = s.Add(ctx, model.Link{ID: id, URL: url, CanonicalURL: url, DisplayURL: url, UserID: userID})
 	assert.Error(t, err, "добавление записи c существующим id")
 }
 
 func TestPgUniqueUrl(t *testing.T) {
 	var (
 		ctx           = context.Background()
-		url           = "https://ya.ru/"
+		url           = "HTTPS://YA.RU:443"
+		canonicalURL  = "https://ya.ru/"
 		urlIDInserted = "fE2ZNnnhOuYG7oMi"
 		urlIDExisted  = "6Qq362Ml98Y15zeb"
 		userID        = "438c4b98-fc98-45cf-ac63-c4a86fbd4ff4"
+		otherUserID   = "02872d15-5047-406c-a989-ee1b07465169"
+		title         = "Яндекс"
+		createdAt     = time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+		expiresAt     = time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
 	)
 
 	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
 	require.NoError(t, err)
 	s := NewPg(db)
 
-	mock.ExpectExec("insert into urls (user_id, url_id, url) values ($1, $2, $3)").
-		WithArgs(userID, urlIDInserted, url).
+	mock.ExpectExec("insert into urls (user_id, url_id, url, canonical_url, display_url, password_hash, title, created_at, expires_at) values ($1, $2, $3, $4, $5, $6, $7, $8, $9)").
+		WithArgs(userID, urlIDInserted, url, canonicalURL, canonicalURL, "", title, createdAt, nil).
 		WillReturnError(&pgconn.PgError{Code: pgerrcode.UniqueViolation})
-	mock.ExpectQuery("select url_id from urls where url = $1").
-		WithArgs(url).
+	mock.ExpectQuery("select url_id from urls where user_id = $1 and md5(canonical_url) = md5($2) and canonical_url = $2 and password_hash = '' and expires_at is null").
+		WithArgs(userID, canonicalURL).
 		WillReturnRows(sqlmock.NewRows([]string{"url"}).AddRow(urlIDExisted))
-	id, err := s.Add(ctx, urlIDInserted, url, userID)
+	id, err := s.Add(ctx, model.Link{
+		ID:           urlIDInserted,
+		URL:          url,
+		CanonicalURL: canonicalURL,
+		DisplayURL:   canonicalURL,
+		Title:        title,
+		UserID:       userID,
+		CreatedAt:    createdAt,
+	})
 	assert.NoError(t, err)
 	assert.Equal(t, urlIDExisted, id)
+
+	mock.ExpectExec("insert into urls (user_id, url_id, url, canonical_url, display_url, password_hash, title, created_at, expires_at) values ($1, $2, $3, $4, $5, $6, $7, $8, $9)").
+		WithArgs(userID, urlIDInserted, url, canonicalURL, canonicalURL, "hash", "", nil, nil).
+		WillReturnError(&pgconn.PgError{Code: pgerrcode.UniqueViolation})
+	_, err = s.Add(ctx, model.Link{ID: urlIDInserted, URL: url, CanonicalURL: canonicalURL, DisplayURL: canonicalURL, PasswordHash: "hash", UserID: userID})
+	assert.Error(t, err, "URL с паролем не заменяется существующим")
+
+	mock.ExpectExec("insert into urls (user_id, url_id, url, canonical_url, display_url, password_hash, title, created_at, expires_at) values ($1, $2, $3, $4, $5, $6, $7, $8, $9)").
+		WithArgs(userID, urlIDInserted, url, canonicalURL, canonicalURL, "", "", nil, expiresAt).
+		WillReturnError(&pgconn.PgError{Code: pgerrcode.UniqueViolation})
+	_, err = s.Add(ctx, model.Link{ID: urlIDInserted, URL: url, CanonicalURL: canonicalURL, DisplayURL: canonicalURL, UserID: userID, ExpiresAt: expiresAt})
+	assert.Error(t, err, "URL со сроком действия не заменяется существующим")
+
+	mock.ExpectExec("insert into urls (user_id, url_id, url, canonical_url, display_url, password_hash, title, created_at, expires_at) values ($1, $2, $3, $4, $5, $6, $7, $8, $9)").
+		WithArgs(otherUserID, urlIDInserted, url, canonicalURL, canonicalURL, "", "", nil, nil).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	id, err = s.Add(ctx, model.Link{ID: urlIDInserted, URL: url, CanonicalURL: canonicalURL, DisplayURL: canonicalURL, UserID: otherUserID})
+	assert.NoError(t, err)
+	assert.Equal(t, urlIDInserted, id, "URL другого пользователя не считается дубликатом")
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
-func TestPg_GetStatSuccess(t *testing.T) {
+func TestPg_GetStatsSuccess(t *testing.T) {
 	var (
-		urlCount   = 2
-		usersCount = 1
+		since = time.Date(2023, 5, 9, 0, 0, 0, 0, time.UTC)
+		top   = 2
 	)
 
-	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	db, mock, err := sqlmock.New()
 	require.NoError(t, err)
 	s := NewPg(db)
 
-	mock.ExpectQuery("select count(url), count(distinct user_id) from urls where deleted = false").
-		WillReturnRows(sqlmock.NewRows([]string{"count", "count"}).AddRow(urlCount, usersCount))
+	mock.ExpectQuery(`pg_database_size\(current_database\(\)\)\s+from urls`).
+		WillReturnRows(sqlmock.NewRows([]string{"urls", "users", "active", "deleted", "expired", "disabled", "size"}).
+			AddRow(4, 2, 2, 1, 1, 1, 8192))
+	mock.ExpectQuery(`from urls\s+where created_at >= \$1\s+group by day`).
+		WithArgs(since).
+		WillReturnRows(sqlmock.NewRows([]string{"day", "count"}).
+			AddRow("2023-05-09", 2).
+			AddRow("2023-05-10", 2))
+	mock.ExpectQuery(`join variant_clicks c on c.url_id = u.url_id`).
+		WithArgs(top).
+		WillReturnRows(sqlmock.NewRows([]string{"url_id", "url", "total"}).
+			AddRow("id2", "https://ya.ru/a", 2).
+			AddRow("id1", "https://ya.ru/", 1))
+	mock.ExpectQuery(`group by domain`).
+		WithArgs(top).
+		WillReturnRows(sqlmock.NewRows([]string{"domain", "total"}).
+			AddRow("ya.ru", 2).
+			AddRow("google.com", 1))
 
-	getURLCount, getUsersCount, err := s.GetStat(context.Background())
+	stats, err := s.GetStats(context.Background(), model.StatsOptions{Since: since, Top: top})
 	assert.NoError(t, err)
-	assert.Equal(t, urlCount, getURLCount)
-	assert.Equal(t, usersCount, getUsersCount)
+	assert.Equal(t, model.Stats{
+		URLs:     4,
+		Users:    2,
+		Active:   2,
+		Deleted:  1,
+		Expired:  1,
+		Disabled: 1,
+		CreatedPerDay: []model.DayCount{
+			{Date: "2023-05-09", Count: 2},
+			{Date: "2023-05-10", Count: 2},
+		},
+		TopLinks: []model.LinkClicks{
+			{ID: "id2", URL: "https://ya.ru/a", Clicks: 2},
+			{ID: "id1", URL: "https://ya.ru/", Clicks: 1},
+		},
+		TopDomains: []model.DomainCount{
+			{Domain: "ya.ru", Count: 2},
+			{Domain: "google.com", Count: 1},
+		},
+		StorageSize: 8192,
+	}, stats)
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
-func TestPg_GetStatError(t *testing.T) {
-	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+func TestPg_GetStatsError(t *testing.T) {
+	db, mock, err := sqlmock.New()
 	require.NoError(t, err)
 	s := NewPg(db)
 
-	mock.ExpectQuery("select count(url), count(distinct user_id) from urls where deleted = false").
+	mock.ExpectQuery(`pg_database_size`).
+		WillReturnRows(sqlmock.NewRows([]string{"urls", "users", "active", "deleted", "expired", "disabled", "size"}).
+			AddRow(0, 0, 0, 0, 0, 0, 0))
+	mock.ExpectQuery(`group by day`).
 		WillReturnError(errors.New(""))
 
-	_, _, err = s.GetStat(context.Background())
+	_, err = s.GetStats(context.Background(), model.StatsOptions{Top: 10})
 	assert.Error(t, err)
+
+	mock.ExpectQuery(`pg_database_size`).
+		WillReturnError(errors.New(""))
+
+	_, err = s.GetStats(context.Background(), model.StatsOptions{Top: 10})
+	assert.Error(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
-func BenchmarkPg_GetAllUser(b *testing.B) {
+func TestPg_Admin(t *testing.T) {
 	var (
-		db, mock, _ = sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
-		s           = NewPg(db)
-		ctx         = context.Background()
-		userID      = "1"
-		rows        = sqlmock.NewRows([]string{"url_id", "url"})
+		ctx     = context.Background()
+		id      = "fE2ZNnnhOuYG7oMi"
+		url     = "https://ya.ru/"
+		userID  = "438c4b98-fc98-45cf-ac63-c4a86fbd4ff4"
+		link    = model.Link{ID: id, URL: url, CanonicalURL: url, DisplayURL: url, UserID: userID, Disabled: true}
+		columns = []string{"url_id", "url", "canonical_url", "display_url", "password_hash", "title", "redirect_rules", "variants", "redirect_options", "metadata", "user_id", "deleted", "disabled", "created_at", "health_status", "health_error", "health_checked_at", "expires_at"}
+		action  = model.AdminAction{AdminID: "adminID", Action: "disable_link", Target: id, CreatedAt: time.Now()}
 	)
-	for i := 0; i < 1000; i++ {
-		id, _ := security.GenerateRandomString(16)
-		rows = rows.AddRow(id, "https://ya.ru/")
-	}
-	b.ResetTimer()
 
-	for i := 0; i < b.N; i++ {
-		mock.ExpectQuery("select url_id, url from urls where user_id = $1 and deleted = false").
-			WithArgs(userID).
-			WillReturnRows(rows)
-		s.GetAllUser(ctx, userID)
-	}
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	s := NewPg(db)
+
+	mock.ExpectQuery("select url_id, url, canonical_url, display_url, password_hash, title, redirect_rules, variants, redirect_options, metadata, user_id, deleted, disabled, created_at, health_status, health_error, health_checked_at, expires_at from urls where url_id = $1").
+		WithArgs(id).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(id, url, url, url, "", "", []byte("[]"), []byte("{}"), []byte("{}"), []byte("{}"), userID, false, true, nil, 0, "", nil, nil))
+	_, err = s.Get(ctx, id)
+	assert.ErrorIs(t, err, inerr.ErrURLIsDisabled, "получение заблокированного URL")
+
+	mock.ExpectQuery("select url_id, url, canonical_url, display_url, password_hash, title, redirect_rules, variants, redirect_options, metadata, user_id, deleted, disabled, created_at, health_status, health_error, health_checked_at, expires_at from urls where url_id = $1").
+		WithArgs(id).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(id, url, url, url, "", "", []byte("[]"), []byte("{}"), []byte("{}"), []byte("{}"), userID, false, false, nil, 0, "", nil, time.Now().Add(-time.Minute)))
+	_, err = s.Get(ctx, id)
+	assert.ErrorIs(t, err, inerr.ErrURLIsExpired, "получение URL с истекшим сроком действия")
+
+	mock.ExpectQuery("select url_id, url, canonical_url, display_url, password_hash, title, redirect_rules, variants, redirect_options, metadata, user_id, deleted, disabled, created_at, health_status, health_error, health_checked_at, expires_at from urls where url_id = $1").
+		WithArgs(id).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(id, url, url, url, "", "", []byte("[]"), []byte("{}"), []byte("{}"), []byte("{}"), userID, false, true, nil, 0, "", nil, nil))
+	l, err := s.GetLink(ctx, id)
+	assert.NoError(t, err, "получение URL")
+	assert.Equal(t, link, l, "получение URL")
+
+	mock.ExpectQuery("select url_id, url, canonical_url, display_url, password_hash, title, redirect_rules, variants, redirect_options, metadata, user_id, deleted, disabled, created_at, health_status, health_error, health_checked_at, expires_at from urls where url_id = $1").
+		WithArgs(id).
+		WillReturnError(sql.ErrNoRows)
+	_, err = s.GetLink(ctx, id)
+	assert.ErrorIs(t, err, inerr.ErrURLNotFound, "получение несуществующего URL")
+
+	mock.ExpectQuery("select url_id, url, canonical_url, display_url, password_hash, title, redirect_rules, variants, redirect_options, metadata, user_id, deleted, disabled, created_at, health_status, health_error, health_checked_at, expires_at from urls where url = $1 or canonical_url = $1 order by url_id").
+		WithArgs(url).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(id, url, url, url, "", "", []byte("[]"), []byte("{}"), []byte("{}"), []byte("{}"), userID, false, true, nil, 0, "", nil, nil))
+	links, err := s.FindLinksByURL(ctx, url)
+	assert.NoError(t, err, "поиск по адресу назначения")
+	assert.Equal(t, []model.Link{link}, links, "поиск по адресу назначения")
+
+	mock.ExpectQuery("select url_id, url, canonical_url, display_url, password_hash, title, redirect_rules, variants, redirect_options, metadata, user_id, deleted, disabled, created_at, health_status, health_error, health_checked_at, expires_at from urls where user_id = $1 order by id").
+		WithArgs(userID).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(id, url, url, url, "", "", []byte("[]"), []byte("{}"), []byte("{}"), []byte("{}"), userID, false, true, nil, 0, "", nil, nil))
+	links, err = s.GetUserLinks(ctx, userID)
+	assert.NoError(t, err, "получение всех URL пользователя")
+	assert.Equal(t, []model.Link{link}, links, "получение всех URL пользователя")
+
+	mock.ExpectExec("update urls set disabled = $2 where url_id = $1").
+		WithArgs(id, true).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	assert.NoError(t, s.SetDisabled(ctx, id, true), "блокировка URL")
+
+	mock.ExpectExec("update urls set disabled = $2 where url_id = $1").
+		WithArgs(id, false).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	assert.ErrorIs(t, s.SetDisabled(ctx, id, false), inerr.ErrURLNotFound, "разблокировка несуществующего URL")
+
+	mock.ExpectExec("insert into banned_users (user_id) values ($1) on conflict do nothing").
+		WithArgs(userID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	assert.NoError(t, s.SetUserBanned(ctx, userID, true), "блокировка пользователя")
+
+	mock.ExpectExec("delete from banned_users where user_id = $1").
+		WithArgs(userID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	assert.NoError(t, s.SetUserBanned(ctx, userID, false), "разблокировка пользователя")
+
+	mock.ExpectQuery("select exists(select 1 from banned_users where user_id = $1)").
+		WithArgs(userID).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	banned, err := s.IsUserBanned(ctx, userID)
+	assert.NoError(t, err, "проверка блокировки пользователя")
+	assert.True(t, banned, "проверка блокировки пользователя")
+
+	mock.ExpectExec("insert into admin_actions (admin_id, action, target, created_at) values ($1, $2, $3, $4)").
+		WithArgs(action.AdminID, action.Action, action.Target, action.CreatedAt).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	assert.NoError(t, s.AddAdminAction(ctx, action), "запись в журнал действий администратора")
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPg_DeleteRestoreBatch(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		id     = "fE2ZNnnhOuYG7oMi"
+		userID = "438c4b98-fc98-45cf-ac63-c4a86fbd4ff4"
+	)
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	s := NewPg(db)
+
+	mock.ExpectQuery("update urls").
+		WithArgs(userID, true, id).
+		WillReturnRows(sqlmock.NewRows([]string{"url_id"}).AddRow(id))
+	deleted, err := s.DeleteBatch(ctx, []string{id}, userID)
+	assert.NoError(t, err, "удаление записи")
+	assert.Equal(t, []string{id}, deleted, "удаление записи")
+
+	mock.ExpectQuery("update urls").
+		WithArgs(userID, false, id).
+		WillReturnRows(sqlmock.NewRows([]string{"url_id"}).AddRow(id))
+	restored, err := s.RestoreBatch(ctx, []string{id}, userID)
+	assert.NoError(t, err, "восстановление записи")
+	assert.Equal(t, []string{id}, restored, "восстановление записи")
+
+	mock.ExpectQuery("update urls").
+		WithArgs(userID, false, id).
+		WillReturnError(errors.New(""))
+	_, err = s.RestoreBatch(ctx, []string{id}, userID)
+	assert.Error(t, err, "ошибка восстановления записи")
+
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
 func BenchmarkPg_DeleteBatch(b *testing.B) {
@@ -157,37 +345,43 @@ func BenchmarkPg_DeleteBatch(b *testing.B) {
 		ctx         = context.Background()
 		userID      = "1"
 		urlIDs      = make([]string, 250)
-		args        = make([]driver.Value, 251)
+		args        = make([]driver.Value, 252)
 	)
 	args[0] = userID
+	args[1] = true
 	for i := range urlIDs {
 		id, _ := security.GenerateRandomString(16)
 		urlIDs[i] = id
-		args[i+1] = id
+		args[i+2] = id
 	}
 	b.ResetTimer()
 
 	for i := 0; i < b.N; i++ {
-		mock.ExpectExec("update urls").
+		mock.ExpectQuery("update urls").
 			WithArgs(args...).
-			WillReturnResult(sqlmock.NewResult(0, 1))
-		_ = s.DeleteBatch(ctx, urlIDs, userID)
+			WillReturnRows(sqlmock.NewRows([]string{"url_id"}))
+		_, _ = s.DeleteBatch(ctx, urlIDs, userID)
 	}
 }
 
-func BenchmarkPg_GetStat(b *testing.B) {
+func BenchmarkPg_GetStats(b *testing.B) {
 	var (
 		db, mock, _ = sqlmock.New()
 		s           = NewPg(db)
 		ctx         = context.Background()
-		urlCount    = 1
-		usersCount  = 1
 	)
 
 	for i := 0; i < b.N; i++ {
-		mock.ExpectQuery("select count(url), count(distinct user_id)").
-			WillReturnRows(sqlmock.NewRows([]string{"count", "count"}).AddRow(urlCount, usersCount))
-		_, _, _ = s.GetStat(ctx)
+		mock.ExpectQuery("pg_database_size").
+			WillReturnRows(sqlmock.NewRows([]string{"urls", "users", "active", "deleted", "disabled", "size"}).
+				AddRow(1, 1, 1, 0, 0, 8192))
+		mock.ExpectQuery("group by day").
+			WillReturnRows(sqlmock.NewRows([]string{"day", "count"}).AddRow("2023-05-10", 1))
+		mock.ExpectQuery("join variant_clicks").
+			WillReturnRows(sqlmock.NewRows([]string{"url_id", "url", "total"}))
+		mock.ExpectQuery("group by domain").
+			WillReturnRows(sqlmock.NewRows([]string{"domain", "total"}).AddRow("ya.ru", 1))
+		_, _ = s.GetStats(ctx, model.StatsOptions{Top: 10})
 	}
 }
 
@@ -204,3 +398,318 @@ func setupTestDB(t *testing.T) (*sql.DB, error) {
 
 	return db, nil
 }
+
+func TestPg_Audit(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		now   = time.Now().UTC()
+		event = model.AuditEvent{
+			LinkID:    "fE2ZNnnhOuYG7oMi",
+			Action:    model.AuditActionCreate,
+			UserID:    "438c4b98-fc98-45cf-ac63-c4a86fbd4ff4",
+			OwnerID:   "438c4b98-fc98-45cf-ac63-c4a86fbd4ff4",
+			ClientIP:  "127.0.0.1",
+			Transport: "http",
+			CreatedAt: now,
+		}
+		filter = model.AuditFilter{OwnerID: event.OwnerID, Limit: 10}
+	)
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	s := NewPg(db)
+
+	mock.ExpectExec("insert into audit_events").
+		WithArgs(event.LinkID, event.Action, event.UserID, event.OwnerID, event.ClientIP, event.Transport, event.CreatedAt).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	assert.NoError(t, s.AddAuditEvents(ctx, []model.AuditEvent{event}), "добавление записи")
+	assert.NoError(t, s.AddAuditEvents(ctx, nil), "добавление пустого списка записей")
+
+	mock.ExpectQuery("select (.+) from audit_events").
+		WithArgs(filter.OwnerID, filter.LinkID, filter.Limit).
+		WillReturnRows(sqlmock.NewRows([]string{"link_id", "action", "user_id", "owner_id", "client_ip", "transport", "created_at"}).
+			AddRow(event.LinkID, event.Action, event.UserID, event.OwnerID, event.ClientIP, event.Transport, event.CreatedAt))
+	events, err := s.GetAuditEvents(ctx, filter)
+	assert.NoError(t, err, "получение записей")
+	assert.Equal(t, []model.AuditEvent{event}, events, "получение записей")
+
+	mock.ExpectExec("delete from audit_events").
+		WithArgs(now).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	assert.NoError(t, s.DeleteAuditEventsBefore(ctx, now), "удаление устаревших записей")
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPg_TakeToken(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		key   = "create:user:438c4b98-fc98-45cf-ac63-c4a86fbd4ff4"
+		limit = model.RateLimit{Rate: 2, Burst: 10}
+		now   = time.Now()
+	)
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	s := NewPg(db)
+
+	mock.ExpectQuery("insert into rate_limits").
+		WithArgs(key, limit.Burst, now, limit.Rate).
+		WillReturnRows(sqlmock.NewRows([]string{"allowed", "tokens"}).AddRow(true, 9.0))
+	allowed, retryAfter, err := s.TakeToken(ctx, key, limit, now)
+	assert.NoError(t, err)
+	assert.True(t, allowed, "токен получен")
+	assert.Zero(t, retryAfter)
+
+	mock.ExpectQuery("insert into rate_limits").
+		WithArgs(key, limit.Burst, now, limit.Rate).
+		WillReturnRows(sqlmock.NewRows([]string{"allowed", "tokens"}).AddRow(false, 0.5))
+	allowed, retryAfter, err = s.TakeToken(ctx, key, limit, now)
+	assert.NoError(t, err)
+	assert.False(t, allowed, "токены закончились")
+	assert.Equal(t, 250*time.Millisecond, retryAfter)
+
+	mock.ExpectQuery("insert into rate_limits").
+		WithArgs(key, limit.Burst, now, limit.Rate).
+		WillReturnError(errors.New(""))
+	_, _, err = s.TakeToken(ctx, key, limit, now)
+	assert.Error(t, err, "ошибка запроса")
+
+	mock.ExpectExec("delete from rate_limits").
+		WithArgs(now).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	assert.NoError(t, s.DeleteFullBuckets(ctx, now), "удаление заполненных корзин")
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPg_Rules(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		id     = "fE2ZNnnhOuYG7oMi"
+		userID = "438c4b98-fc98-45cf-ac63-c4a86fbd4ff4"
+		rules  = []model.RedirectRule{{Platforms: []string{"android"}, URL: "https://play.google.com/"}}
+		data   = []byte(`[{"platforms":["android"],"url":"https://play.google.com/"}]`)
+	)
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	s := NewPg(db)
+
+	mock.ExpectExec("update urls set redirect_rules = $3 where url_id = $1 and user_id = $2").
+		WithArgs(id, userID, data).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	assert.NoError(t, s.SetRules(ctx, id, userID, rules), "сохранение правил")
+
+	mock.ExpectExec("update urls set redirect_rules = $3 where url_id = $1 and user_id = $2").
+		WithArgs(id, userID, []byte("[]")).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	assert.ErrorIs(t, s.SetRules(ctx, id, userID, nil), inerr.ErrURLNotFound, "удаление правил чужого URL")
+
+	mock.ExpectQuery("select redirect_rules from urls where url_id = $1 and user_id = $2").
+		WithArgs(id, userID).
+		WillReturnRows(sqlmock.NewRows([]string{"redirect_rules"}).AddRow(data))
+	stored, err := s.GetRules(ctx, id, userID)
+	assert.NoError(t, err, "получение правил")
+	assert.Equal(t, rules, stored, "получение правил")
+
+	mock.ExpectQuery("select redirect_rules from urls where url_id = $1 and user_id = $2").
+		WithArgs(id, userID).
+		WillReturnError(sql.ErrNoRows)
+	_, err = s.GetRules(ctx, id, userID)
+	assert.ErrorIs(t, err, inerr.ErrURLNotFound, "получение правил чужого URL")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPg_Variants(t *testing.T) {
+	var (
+		ctx      = context.Background()
+		id       = "fE2ZNnnhOuYG7oMi"
+		userID   = "438c4b98-fc98-45cf-ac63-c4a86fbd4ff4"
+		variants = model.Variants{Sticky: true, Items: []model.Variant{{Name: "a", URL: "https://example.com/a", Weight: 1}}}
+		data     = []byte(`{"sticky":true,"variants":[{"name":"a","url":"https://example.com/a","weight":1}]}`)
+		clicks   = `
+select c.variant, c.clicks
+from urls u
+         left join variant_clicks c on c.url_id = u.url_id
+where u.url_id = $1
+  and u.user_id = $2
+	`
+	)
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	s := NewPg(db)
+
+	mock.ExpectExec("update urls set variants = $3 where url_id = $1 and user_id = $2").
+		WithArgs(id, userID, data).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	assert.NoError(t, s.SetVariants(ctx, id, userID, variants), "сохранение вариантов")
+
+	mock.ExpectExec("update urls set variants = $3 where url_id = $1 and user_id = $2").
+		WithArgs(id, userID, []byte(`{"sticky":false,"variants":[]}`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	assert.ErrorIs(t, s.SetVariants(ctx, id, userID, model.Variants{}), inerr.ErrURLNotFound, "удаление вариантов чужого URL")
+
+	mock.ExpectQuery("select variants from urls where url_id = $1 and user_id = $2").
+		WithArgs(id, userID).
+		WillReturnRows(sqlmock.NewRows([]string{"variants"}).AddRow(data))
+	stored, err := s.GetVariants(ctx, id, userID)
+	assert.NoError(t, err, "получение вариантов")
+	assert.Equal(t, variants, stored, "получение вариантов")
+
+	mock.ExpectQuery("select variants from urls where url_id = $1 and user_id = $2").
+		WithArgs(id, userID).
+		WillReturnError(sql.ErrNoRows)
+	_, err = s.GetVariants(ctx, id, userID)
+	assert.ErrorIs(t, err, inerr.ErrURLNotFound, "получение вариантов чужого URL")
+
+	mock.ExpectExec(`
+insert into variant_clicks (url_id, variant, clicks)
+values ($1, $2, $3),($4, $5, $6)
+on conflict (url_id, variant) do update set clicks = variant_clicks.clicks + excluded.clicks
+	`).
+		WithArgs(id, "a", int64(2), id, "b", int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	assert.NoError(t, s.AddVariantClicks(ctx, []model.VariantClicks{
+		{URLID: id, Variant: "a", Clicks: 2},
+		{URLID: id, Variant: "b", Clicks: 1},
+	}), "учет переходов")
+	assert.NoError(t, s.AddVariantClicks(ctx, nil), "пустой пакет переходов")
+
+	mock.ExpectQuery(clicks).
+		WithArgs(id, userID).
+		WillReturnRows(sqlmock.NewRows([]string{"variant", "clicks"}).AddRow("a", 2).AddRow("b", 1))
+	counts, err := s.GetVariantClicks(ctx, id, userID)
+	assert.NoError(t, err, "получение переходов")
+	assert.Equal(t, map[string]int64{"a": 2, "b": 1}, counts, "получение переходов")
+
+	mock.ExpectQuery(clicks).
+		WithArgs(id, userID).
+		WillReturnRows(sqlmock.NewRows([]string{"variant", "clicks"}).AddRow(nil, nil))
+	counts, err = s.GetVariantClicks(ctx, id, userID)
+	assert.NoError(t, err, "URL без переходов")
+	assert.Empty(t, counts, "URL без переходов")
+
+	mock.ExpectQuery(clicks).
+		WithArgs(id, userID).
+		WillReturnRows(sqlmock.NewRows([]string{"variant", "clicks"}))
+	_, err = s.GetVariantClicks(ctx, id, userID)
+	assert.ErrorIs(t, err, inerr.ErrURLNotFound, "переходы чужого URL")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPg_RedirectOptions(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		id     = "fE2ZNnnhOuYG7oMi"
+		userID = "438c4b98-fc98-45cf-ac63-c4a86fbd4ff4"
+		opts   = model.RedirectOptions{Query: model.QueryPassthroughOverride, Path: true}
+		data   = []byte(`{"query":"override","path":true}`)
+	)
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	s := NewPg(db)
+
+	mock.ExpectExec("update urls set redirect_options = $3 where url_id = $1 and user_id = $2").
+		WithArgs(id, userID, data).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	assert.NoError(t, s.SetRedirectOptions(ctx, id, userID, opts), "сохранение параметров")
+
+	mock.ExpectExec("update urls set redirect_options = $3 where url_id = $1 and user_id = $2").
+		WithArgs(id, userID, []byte("{}")).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	err = s.SetRedirectOptions(ctx, id, userID, model.RedirectOptions{})
+	assert.ErrorIs(t, err, inerr.ErrURLNotFound, "сброс параметров чужого URL")
+
+	mock.ExpectQuery("select redirect_options from urls where url_id = $1 and user_id = $2").
+		WithArgs(id, userID).
+		WillReturnRows(sqlmock.NewRows([]string{"redirect_options"}).AddRow(data))
+	stored, err := s.GetRedirectOptions(ctx, id, userID)
+	assert.NoError(t, err, "получение параметров")
+	assert.Equal(t, opts, stored, "получение параметров")
+
+	mock.ExpectQuery("select redirect_options from urls where url_id = $1 and user_id = $2").
+		WithArgs(id, userID).
+		WillReturnError(sql.ErrNoRows)
+	_, err = s.GetRedirectOptions(ctx, id, userID)
+	assert.ErrorIs(t, err, inerr.ErrURLNotFound, "получение параметров чужого URL")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPg_SetMetadata(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		id    = "fE2ZNnnhOuYG7oMi"
+		md    = model.Metadata{Title: "Яндекс", SiteName: "Yandex"}
+		data  = []byte(`{"title":"Яндекс","site_name":"Yandex"}`)
+		query = "update urls set metadata = $2, title = case when title = '' then $3 else title end where url_id = $1"
+	)
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	s := NewPg(db)
+
+	mock.ExpectExec(query).
+		WithArgs(id, data, md.Title).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	assert.NoError(t, s.SetMetadata(ctx, id, md, md.Title), "сохранение метаданных")
+
+	mock.ExpectExec(query).
+		WithArgs(id, data, md.Title).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	err = s.SetMetadata(ctx, id, md, md.Title)
+	assert.ErrorIs(t, err, inerr.ErrURLNotFound, "сохранение метаданных несуществующего URL")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPg_Health(t *testing.T) {
+	var (
+		ctx       = context.Background()
+		id        = "fE2ZNnnhOuYG7oMi"
+		url       = "https://ya.ru/"
+		userID    = "438c4b98-fc98-45cf-ac63-c4a86fbd4ff4"
+		checkedAt = time.Now()
+		h         = model.LinkHealth{Status: 404, CheckedAt: checkedAt}
+		columns   = []string{"url_id", "url", "canonical_url", "display_url", "password_hash", "title", "redirect_rules", "variants", "redirect_options", "metadata", "user_id", "deleted", "disabled", "created_at", "health_status", "health_error", "health_checked_at", "expires_at"}
+		update    = "update urls set health_status = $2, health_error = $3, health_checked_at = $4 where url_id = $1"
+	)
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	s := NewPg(db)
+
+	mock.ExpectQuery("select url_id, url, canonical_url, display_url, password_hash, title, redirect_rules, variants, redirect_options, metadata, user_id, deleted, disabled, created_at, health_status, health_error, health_checked_at, expires_at from urls where deleted = false and (health_checked_at is null or health_checked_at < $1) order by health_checked_at nulls first, id limit $2").
+		WithArgs(checkedAt, 10).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(id, url, url, url, "", "", []byte("[]"), []byte("{}"), []byte("{}"), []byte("{}"), userID, false, false, nil, 404, "", checkedAt, nil))
+	links, err := s.GetLinksToCheck(ctx, checkedAt, 10)
+	assert.NoError(t, err, "получение URL для проверки")
+	assert.Equal(t, []model.Link{{ID: id, URL: url, CanonicalURL: url, DisplayURL: url, UserID: userID, Health: h}}, links)
+
+	mock.ExpectExec(update).
+		WithArgs(id, h.Status, h.Error, h.CheckedAt).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	assert.NoError(t, s.SetHealth(ctx, id, h), "сохранение результата проверки")
+
+	mock.ExpectExec(update).
+		WithArgs(id, h.Status, h.Error, h.CheckedAt).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	assert.ErrorIs(t, s.SetHealth(ctx, id, h), inerr.ErrURLNotFound, "сохранение результата проверки несуществующего URL")
+
+	mock.ExpectQuery(`
+select health_checked_at is not null, health_status, count(*)
+from urls
+where deleted = false
+group by 1, 2
+	`).
+		WillReturnRows(sqlmock.NewRows([]string{"checked", "health_status", "count"}).
+			AddRow(false, 0, 3).
+			AddRow(true, 200, 5).
+			AddRow(true, 404, 2))
+	total, statuses, err := s.CountHealthStatuses(ctx)
+	assert.NoError(t, err, "подсчет результатов проверки")
+	assert.Equal(t, 10, total)
+	assert.Equal(t, map[int]int{200: 5, 404: 2}, statuses)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
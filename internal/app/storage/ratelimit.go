@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/ivanpodgorny/urlshortener/internal/app/model"
+)
+
+// TokenBuckets реализует интерфейс service.RateLimitStorage для хранения
+// состояния ограничений частоты запросов в памяти. Подходит для работы
+// одного экземпляра сервиса.
+type TokenBuckets struct {
+	buckets   map[string]bucket
+	lastSweep time.Time
+	mu        sync.Mutex
+}
+
+type bucket struct {
+	tokens    float64
+	updatedAt time.Time
+	limit     model.RateLimit
+}
+
+// bucketsSweepInterval периодичность удаления заполненных корзин,
+// которые не отличаются от новых.
+const bucketsSweepInterval = time.Minute
+
+// NewTokenBuckets возвращает указатель на новый экземпляр TokenBuckets.
+func NewTokenBuckets() *TokenBuckets {
+	return &TokenBuckets{
+		buckets: map[string]bucket{},
+	}
+}
+
+// TakeToken забирает токен из корзины key с ограничением l. Если токенов
+// не осталось, возвращает false и время до появления следующего токена.
+func (b *TokenBuckets) TakeToken(_ context.Context, key string, l model.RateLimit, now time.Time) (bool, time.Duration, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.sweep(now)
+
+	tokens := float64(l.Burst)
+	if stored, ok := b.buckets[key]; ok {
+		tokens = refill(stored.tokens, stored.updatedAt, l, now)
+	}
+
+	allowed := tokens >= 1
+	if allowed {
+		tokens--
+	}
+	b.buckets[key] = bucket{tokens: tokens, updatedAt: now, limit: l}
+
+	return allowed, retryAfter(allowed, tokens, l), nil
+}
+
+// DeleteFullBuckets удаляет корзины, которые к моменту now пополнились
+// до максимального размера и не отличаются от новых.
+func (b *TokenBuckets) DeleteFullBuckets(_ context.Context, now time.Time) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.deleteFull(now)
+
+	return nil
+}
+
+func (b *TokenBuckets) sweep(now time.Time) {
+	if now.Sub(b.lastSweep) < bucketsSweepInterval {
+		return
+	}
+
+	b.deleteFull(now)
+}
+
+func (b *TokenBuckets) deleteFull(now time.Time) {
+	for key, stored := range b.buckets {
+		if refill(stored.tokens, stored.updatedAt, stored.limit, now) >= float64(stored.limit.Burst) {
+			delete(b.buckets, key)
+		}
+	}
+	b.lastSweep = now
+}
+
+func refill(tokens float64, updatedAt time.Time, l model.RateLimit, now time.Time) float64 {
+	elapsed := now.Sub(updatedAt).Seconds()
+	if elapsed < 0 {
+		elapsed = 0
+	}
+
+	return math.Min(float64(l.Burst), tokens+elapsed*l.Rate)
+}
+
+func retryAfter(allowed bool, tokens float64, l model.RateLimit) time.Duration {
+	if allowed {
+		return 0
+	}
+
+	return time.Duration((1 - tokens) / l.Rate * float64(time.Second))
+}
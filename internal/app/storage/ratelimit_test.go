@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ivanpodgorny/urlshortener/internal/app/model"
+)
+
+func TestTokenBuckets(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		key   = "create:ip:127.0.0.1"
+		limit = model.RateLimit{Rate: 1, Burst: 2}
+		now   = time.Now()
+		b     = NewTokenBuckets()
+	)
+
+	for i := 0; i < limit.Burst; i++ {
+		allowed, retryAfter, err := b.TakeToken(ctx, key, limit, now)
+		require.NoError(t, err)
+		assert.True(t, allowed, "токены в новой корзине")
+		assert.Zero(t, retryAfter)
+	}
+
+	allowed, retryAfter, err := b.TakeToken(ctx, key, limit, now)
+	require.NoError(t, err)
+	assert.False(t, allowed, "токены закончились")
+	assert.Equal(t, time.Second, retryAfter, "время до появления токена")
+
+	allowed, _, err = b.TakeToken(ctx, "create:ip:127.0.0.2", limit, now)
+	require.NoError(t, err)
+	assert.True(t, allowed, "корзины разных ключей независимы")
+
+	allowed, _, err = b.TakeToken(ctx, key, limit, now.Add(1500*time.Millisecond))
+	require.NoError(t, err)
+	assert.True(t, allowed, "корзина пополнилась")
+	allowed, retryAfter, err = b.TakeToken(ctx, key, limit, now.Add(1500*time.Millisecond))
+	require.NoError(t, err)
+	assert.False(t, allowed)
+	assert.Equal(t, 500*time.Millisecond, retryAfter, "время до появления токена при частичном пополнении")
+
+	_, _, err = b.TakeToken(ctx, "create:ip:127.0.0.3", limit, now.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Len(t, b.buckets, 1, "заполненные корзины удаляются")
+
+	_, _, err = b.TakeToken(ctx, key, limit, now.Add(time.Hour))
+	require.NoError(t, err)
+	require.NoError(t, b.DeleteFullBuckets(ctx, now.Add(time.Hour+500*time.Millisecond)))
+	assert.Len(t, b.buckets, 2, "корзины пополнились не полностью")
+	require.NoError(t, b.DeleteFullBuckets(ctx, now.Add(time.Hour+time.Second)))
+	assert.Empty(t, b.buckets, "удаление заполненных корзин")
+}
@@ -0,0 +1,7 @@
+package storage
+
+import "go.opentelemetry.io/otel"
+
+// tracer трассировщик запросов к хранилищу. Спаны передаются глобальному
+// поставщику трассировщиков OpenTelemetry.
+var tracer = otel.Tracer("github.com/ivanpodgorny/urlshortener/internal/app/storage")
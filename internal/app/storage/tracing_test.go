@@ -0,0 +1,48 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestPg_Tracing(t *testing.T) {
+	var (
+		recorder = tracetest.NewSpanRecorder()
+		tp       = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+		observer = &OperationObserverMock{}
+	)
+	otel.SetTracerProvider(tp)
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	s := NewPg(db)
+	s.SetObserver(observer)
+	observer.On("ObserveStorageOperation", "is_user_banned").Once()
+	mock.ExpectQuery("select exists(select 1 from banned_users where user_id = $1)").
+		WithArgs("userID").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "parent")
+	_, err = s.IsUserBanned(ctx, "userID")
+	require.NoError(t, err)
+	parent.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	assert.Equal(t, "pg.is_user_banned", spans[0].Name())
+	assert.Equal(t, trace.SpanKindClient, spans[0].SpanKind())
+	assert.Equal(t, parent.SpanContext().SpanID(), spans[0].Parent().SpanID(), "дочерний спан")
+	assert.Subset(t, spans[0].Attributes(), []attribute.KeyValue{
+		attribute.String("db.system", "postgresql"),
+		attribute.String("db.operation", "is_user_banned"),
+	})
+	observer.AssertExpectations(t)
+}
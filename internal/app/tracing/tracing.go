@@ -0,0 +1,96 @@
+// Package tracing настраивает экспорт трассировок OpenTelemetry.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/url"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+)
+
+// Экспортеры трассировок.
+const (
+	ExporterNone   = ""
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+// ServiceName имя сервиса в трассировках.
+const ServiceName = "urlshortener"
+
+// ErrUnknownExporter неизвестный экспортер трассировок.
+var ErrUnknownExporter = errors.New("tracing exporter must be one of: stdout, otlp")
+
+// IsExporter возвращает true, если name — допустимое имя экспортера трассировок:
+// stdout, otlp или пустая строка, если трассировки не экспортируются.
+func IsExporter(name string) bool {
+	return name == ExporterNone || name == ExporterStdout || name == ExporterOTLP
+}
+
+// NewPropagator возвращает распространитель контекста трассировки в формате
+// W3C Trace Context и W3C Baggage.
+func NewPropagator() propagation.TextMapPropagator {
+	return propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+}
+
+// NewProvider возвращает поставщика трассировщиков, экспортирующего спаны
+// экспортером exporter. Экспортер stdout записывает спаны в w в формате JSON,
+// экспортер otlp отправляет их по OTLP/HTTP на адрес endpoint вида
+// http://localhost:4318. Версия version указывается в ресурсе сервиса.
+func NewProvider(exporter, endpoint, version string, w io.Writer) (*sdktrace.TracerProvider, error) {
+	var (
+		exp sdktrace.SpanExporter
+		err error
+	)
+	switch exporter {
+	case ExporterStdout:
+		exp, err = stdouttrace.New(stdouttrace.WithWriter(w))
+	case ExporterOTLP:
+		exp, err = newOTLPExporter(endpoint)
+	default:
+		return nil, ErrUnknownExporter
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	res := resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceNameKey.String(ServiceName),
+		semconv.ServiceVersionKey.String(version),
+	)
+
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exp),
+		sdktrace.WithResource(res),
+	), nil
+}
+
+// newOTLPExporter возвращает экспортер, отправляющий спаны по OTLP/HTTP на адрес endpoint.
+// Если адрес не указан, используются переменные окружения OTEL_EXPORTER_OTLP_*.
+func newOTLPExporter(endpoint string) (sdktrace.SpanExporter, error) {
+	var opts []otlptracehttp.Option
+	if endpoint != "" {
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			return nil, err
+		}
+
+		opts = append(opts, otlptracehttp.WithEndpoint(u.Host))
+		if u.Scheme == "http" {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		if u.Path != "" && u.Path != "/" {
+			opts = append(opts, otlptracehttp.WithURLPath(u.Path))
+		}
+	}
+
+	return otlptracehttp.New(context.Background(), opts...)
+}
@@ -0,0 +1,56 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+func TestNewProvider(t *testing.T) {
+	b := &bytes.Buffer{}
+	tp, err := NewProvider(ExporterStdout, "", "v1.0.0", b)
+	require.NoError(t, err)
+
+	_, span := tp.Tracer("test").Start(context.Background(), "operation")
+	span.End()
+	require.NoError(t, tp.Shutdown(context.Background()))
+
+	exported := struct {
+		Name     string
+		Resource []struct {
+			Key   string
+			Value struct{ Value interface{} }
+		}
+	}{}
+	require.NoError(t, json.Unmarshal(b.Bytes(), &exported))
+	assert.Equal(t, "operation", exported.Name)
+	resource := map[string]interface{}{}
+	for _, kv := range exported.Resource {
+		resource[kv.Key] = kv.Value.Value
+	}
+	assert.Equal(t, ServiceName, resource["service.name"])
+	assert.Equal(t, "v1.0.0", resource["service.version"])
+
+	tp, err = NewProvider(ExporterOTLP, "http://localhost:4318", "", b)
+	require.NoError(t, err)
+	require.NoError(t, tp.Shutdown(context.Background()), "нет спанов для отправки")
+
+	_, err = NewProvider("jaeger", "", "", b)
+	assert.ErrorIs(t, err, ErrUnknownExporter)
+}
+
+func TestNewPropagator(t *testing.T) {
+	const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	p := NewPropagator()
+	ctx := p.Extract(context.Background(), propagation.MapCarrier{"traceparent": traceparent, "baggage": "tenant=a"})
+	carrier := propagation.MapCarrier{}
+	p.Inject(ctx, carrier)
+	assert.Equal(t, traceparent, carrier.Get("traceparent"))
+	assert.Equal(t, "tenant=a", carrier.Get("baggage"))
+}
@@ -0,0 +1,245 @@
+package validator
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// Canonicalizer приводит URL к каноническому виду, по которому определяются
+// дубликаты: схема и хост в нижнем регистре, интернационализированный домен
+// в punycode, без порта по умолчанию, с нормализованным percent-encoding.
+// Параметры запроса могут сортироваться и отбрасываться по списку шаблонов.
+type Canonicalizer struct {
+	sortQuery   bool
+	stripParams []string
+}
+
+// defaultPorts порты по умолчанию, которые удаляются из канонического URL.
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// NewCanonicalizer возвращает указатель на новый экземпляр Canonicalizer.
+// Если sortQuery равен true, параметры запроса сортируются по имени.
+// Параметры, имена которых совпадают с одним из stripParams, удаляются.
+// Шаблон, оканчивающийся на *, совпадает со всеми именами с заданным префиксом,
+// например utm_*. Имена сравниваются без учета регистра.
+func NewCanonicalizer(sortQuery bool, stripParams []string) *Canonicalizer {
+	c := &Canonicalizer{
+		sortQuery:   sortQuery,
+		stripParams: make([]string, 0, len(stripParams)),
+	}
+	for _, p := range stripParams {
+		if p = strings.ToLower(strings.TrimSpace(p)); p != "" {
+			c.stripParams = append(c.stripParams, p)
+		}
+	}
+
+	return c
+}
+
+// Canonicalize возвращает канонический вид URL или IRI rawURL, состоящий только
+// из символов ASCII. Если URL не удается разобрать, возвращает RejectionError
+// с причиной RejectionInvalidURL.
+func (c Canonicalizer) Canonicalize(rawURL string) (string, error) {
+	u, err := ParseIRI(rawURL)
+	if err != nil {
+		return "", NewRejectionError(RejectionInvalidURL, err.Error())
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	if u.Opaque != "" || u.Host == "" {
+		return u.String(), nil
+	}
+
+	host, err := canonicalHost(u.Scheme, u.Hostname(), u.Port())
+	if err != nil {
+		return "", NewRejectionError(RejectionInvalidURL, err.Error())
+	}
+
+	var b strings.Builder
+	b.WriteString(u.Scheme)
+	b.WriteString("://")
+	if u.User != nil {
+		b.WriteString(u.User.String())
+		b.WriteByte('@')
+	}
+	b.WriteString(host)
+
+	// RawPath сохраняет исходное экранирование, например %2F, которое EscapedPath
+	// может потерять, если в исходном пути есть недопустимые символы.
+	path := u.RawPath
+	if path == "" {
+		path = u.EscapedPath()
+	}
+	path = normalizePercentEncoding(path, "/:@")
+	if path == "" {
+		path = "/"
+	}
+	b.WriteString(path)
+
+	if query := c.canonicalQuery(u.RawQuery); query != "" {
+		b.WriteByte('?')
+		b.WriteString(query)
+	}
+
+	if u.Fragment != "" {
+		b.WriteByte('#')
+		b.WriteString(normalizePercentEncoding(u.EscapedFragment(), "/:@?"))
+	}
+
+	return b.String(), nil
+}
+
+func canonicalHost(scheme, hostname, port string) (string, error) {
+	host := strings.ToLower(hostname)
+	if !isASCII(host) {
+		var err error
+		if host, err = idna.Lookup.ToASCII(host); err != nil {
+			return "", fmt.Errorf("invalid host %q: %w", hostname, err)
+		}
+	}
+
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+
+	if port != "" && port != defaultPorts[scheme] {
+		host = net.JoinHostPort(strings.Trim(host, "[]"), port)
+	}
+
+	return host, nil
+}
+
+func (c Canonicalizer) canonicalQuery(rawQuery string) string {
+	type param struct {
+		name string
+		raw  string
+	}
+	params := make([]param, 0)
+	for _, raw := range strings.Split(rawQuery, "&") {
+		if raw == "" {
+			continue
+		}
+
+		raw = normalizePercentEncoding(raw, "/:@?")
+		name, _, _ := strings.Cut(raw, "=")
+		if unescaped, err := url.QueryUnescape(name); err == nil {
+			name = unescaped
+		}
+
+		if c.isStripped(name) {
+			continue
+		}
+
+		params = append(params, param{name: name, raw: raw})
+	}
+
+	if c.sortQuery {
+		sort.SliceStable(params, func(i, j int) bool {
+			return params[i].name < params[j].name
+		})
+	}
+
+	parts := make([]string, 0, len(params))
+	for _, p := range params {
+		parts = append(parts, p.raw)
+	}
+
+	return strings.Join(parts, "&")
+}
+
+func (c Canonicalizer) isStripped(name string) bool {
+	name = strings.ToLower(name)
+	for _, p := range c.stripParams {
+		if prefix, ok := strings.CutSuffix(p, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if name == p {
+			return true
+		}
+	}
+
+	return false
+}
+
+// normalizePercentEncoding декодирует экранированные незарезервированные символы,
+// приводит шестнадцатеричные цифры к верхнему регистру и экранирует символы,
+// которые не могут встречаться в компоненте URL. Кроме незарезервированных
+// символов и sub-delims без экранирования остаются символы из allowed.
+func normalizePercentEncoding(s, allowed string) string {
+	const upperHex = "0123456789ABCDEF"
+
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		if ch == '%' && i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2]) {
+			decoded := unhex(s[i+1])<<4 | unhex(s[i+2])
+			if isUnreserved(decoded) {
+				b.WriteByte(decoded)
+			} else {
+				b.WriteByte('%')
+				b.WriteByte(upperHex[decoded>>4])
+				b.WriteByte(upperHex[decoded&15])
+			}
+			i += 2
+
+			continue
+		}
+
+		if isUnreserved(ch) || strings.IndexByte("!$&'()*+,;=", ch) >= 0 || strings.IndexByte(allowed, ch) >= 0 {
+			b.WriteByte(ch)
+
+			continue
+		}
+
+		b.WriteByte('%')
+		b.WriteByte(upperHex[ch>>4])
+		b.WriteByte(upperHex[ch&15])
+	}
+
+	return b.String()
+}
+
+func isUnreserved(ch byte) bool {
+	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || '0' <= ch && ch <= '9' ||
+		ch == '-' || ch == '.' || ch == '_' || ch == '~'
+}
+
+func isHex(ch byte) bool {
+	return '0' <= ch && ch <= '9' || 'a' <= ch && ch <= 'f' || 'A' <= ch && ch <= 'F'
+}
+
+func unhex(ch byte) byte {
+	switch {
+	case '0' <= ch && ch <= '9':
+		return ch - '0'
+	case 'a' <= ch && ch <= 'f':
+		return ch - 'a' + 10
+	default:
+		return ch - 'A' + 10
+	}
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Display возвращает отображаемую форму URL canonicalURL, см. DisplayURL.
+func (c Canonicalizer) Display(canonicalURL string) string {
+	return DisplayURL(canonicalURL)
+}
@@ -0,0 +1,100 @@
+package validator
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	inerr "github.com/ivanpodgorny/urlshortener/internal/app/errors"
+)
+
+func TestCanonicalizer_Canonicalize(t *testing.T) {
+	c := NewCanonicalizer(true, []string{"utm_*", " FBCLID "})
+	tests := []struct {
+		name string
+		url  string
+		want string
+	}{
+		{
+			name: "схема и хост в нижнем регистре",
+			url:  "HTTP://Example.COM/Path",
+			want: "http://example.com/Path",
+		},
+		{
+			name: "порт по умолчанию",
+			url:  "https://example.com:443/a",
+			want: "https://example.com/a",
+		},
+		{
+			name: "порт не по умолчанию",
+			url:  "http://example.com:8080/a",
+			want: "http://example.com:8080/a",
+		},
+		{
+			name: "IPv6 с портом по умолчанию",
+			url:  "http://[::1]:80/",
+			want: "http://[::1]/",
+		},
+		{
+			name: "IDN",
+			url:  "http://Пример.рф/",
+			want: "http://xn--e1afmkfd.xn--p1ai/",
+		},
+		{
+			name: "пустой путь",
+			url:  "http://example.com",
+			want: "http://example.com/",
+		},
+		{
+			name: "percent-encoding",
+			url:  "http://example.com/%7euser/%2f%41/путь",
+			want: "http://example.com/~user/%2FA/%D0%BF%D1%83%D1%82%D1%8C",
+		},
+		{
+			name: "сортировка запроса",
+			url:  "http://Example.com/a?b=1&a=2",
+			want: "http://example.com/a?a=2&b=1",
+		},
+		{
+			name: "повторяющиеся параметры сохраняют порядок",
+			url:  "http://example.com/?b=2&a=1&b=1",
+			want: "http://example.com/?a=1&b=2&b=1",
+		},
+		{
+			name: "удаление трекинговых параметров",
+			url:  "http://example.com/?utm_source=x&id=1&UTM_Medium=y&fbclid=z&&",
+			want: "http://example.com/?id=1",
+		},
+		{
+			name: "пустой запрос",
+			url:  "http://example.com/?",
+			want: "http://example.com/",
+		},
+		{
+			name: "фрагмент",
+			url:  "http://example.com/#%7eSection",
+			want: "http://example.com/#~Section",
+		},
+		{
+			name: "URL без хоста",
+			url:  "MAILTO:user@example.com",
+			want: "mailto:user@example.com",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := c.Canonicalize(tt.url)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	got, err := NewCanonicalizer(false, nil).Canonicalize("http://example.com/?b=1&utm_source=x&a=2")
+	require.NoError(t, err)
+	assert.Equal(t, "http://example.com/?b=1&utm_source=x&a=2", got, "политика без сортировки и удаления параметров")
+
+	_, err = c.Canonicalize("http://example.com:port/")
+	assert.True(t, errors.Is(err, inerr.ErrDestinationRejected), "некорректный URL")
+}
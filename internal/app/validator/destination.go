@@ -0,0 +1,186 @@
+package validator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	inerr "github.com/ivanpodgorny/urlshortener/internal/app/errors"
+)
+
+// Причины отказа в сокращении URL.
+const (
+	RejectionInvalidURL        = "invalid_url"
+	RejectionSchemeNotAllowed  = "scheme_not_allowed"
+	RejectionDomainBlocked     = "domain_blocked"
+	RejectionDomainNotAllowed  = "domain_not_allowed"
+	RejectionPrivateAddress    = "private_address"
+	RejectionUnresolvableHost  = "unresolvable_host"
+	RejectionReputationFailure = "bad_reputation"
+)
+
+// RejectionError ошибка проверки адреса назначения с причиной отказа.
+type RejectionError struct {
+	Reason string
+	Detail string
+}
+
+// NewRejectionError возвращает ошибку проверки адреса назначения.
+func NewRejectionError(reason, detail string) *RejectionError {
+	return &RejectionError{
+		Reason: reason,
+		Detail: detail,
+	}
+}
+
+func (e *RejectionError) Error() string {
+	return fmt.Sprintf("%v: %s: %s", inerr.ErrDestinationRejected, e.Reason, e.Detail)
+}
+
+// Unwrap возвращает errors.ErrDestinationRejected.
+func (e *RejectionError) Unwrap() error {
+	return inerr.ErrDestinationRejected
+}
+
+// Resolver интерфейс для получения IP-адресов хоста. Реализуется net.Resolver.
+type Resolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// ReputationChecker интерфейс внешнего сервиса проверки репутации адреса назначения.
+// Check возвращает ошибку, если адрес признан вредоносным. Для указания причины
+// отказа можно вернуть RejectionError, остальные ошибки возвращаются клиенту
+// с причиной RejectionReputationFailure.
+type ReputationChecker interface {
+	Check(ctx context.Context, u *url.URL) error
+}
+
+// ReputationCheckerFunc позволяет использовать функцию как ReputationChecker.
+type ReputationCheckerFunc func(ctx context.Context, u *url.URL) error
+
+// Check вызывает f(ctx, u).
+func (f ReputationCheckerFunc) Check(ctx context.Context, u *url.URL) error {
+	return f(ctx, u)
+}
+
+// DestinationPolicy проверяет, что адрес назначения сокращаемого URL безопасен:
+// схема входит в разрешенные, домен не заблокирован и входит в список разрешенных,
+// если он задан, хост не указывает на локальную или частную сеть, а внешние
+// сервисы проверки репутации не считают адрес вредоносным.
+type DestinationPolicy struct {
+	schemes   map[string]struct{}
+	blocklist *DomainList
+	allowlist *DomainList
+	resolver  Resolver
+	checkers  []ReputationChecker
+}
+
+// NewDestinationPolicy возвращает указатель на новый экземпляр DestinationPolicy.
+// blocklist и allowlist могут быть nil. Если resolver равен nil, IP-адреса хоста
+// не проверяются, кроме указанных в URL явно.
+func NewDestinationPolicy(
+	schemes []string,
+	blocklist, allowlist *DomainList,
+	resolver Resolver,
+	checkers ...ReputationChecker,
+) *DestinationPolicy {
+	p := &DestinationPolicy{
+		schemes:   make(map[string]struct{}, len(schemes)),
+		blocklist: blocklist,
+		allowlist: allowlist,
+		resolver:  resolver,
+		checkers:  checkers,
+	}
+	for _, s := range schemes {
+		p.schemes[strings.ToLower(s)] = struct{}{}
+	}
+
+	return p
+}
+
+// Check проверяет адрес назначения rawURL. Если адрес запрещен, возвращает
+// RejectionError с причиной отказа.
+func (p DestinationPolicy) Check(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return NewRejectionError(RejectionInvalidURL, "url must be absolute")
+	}
+
+	if _, ok := p.schemes[strings.ToLower(u.Scheme)]; !ok {
+		return NewRejectionError(RejectionSchemeNotAllowed, fmt.Sprintf("scheme %q is not allowed", u.Scheme))
+	}
+
+	host := normalizeHost(u.Hostname())
+	if p.blocklist != nil && p.blocklist.Contains(host) {
+		return NewRejectionError(RejectionDomainBlocked, fmt.Sprintf("domain %s is blocked", host))
+	}
+
+	if p.allowlist != nil && !p.allowlist.Contains(host) {
+		return NewRejectionError(RejectionDomainNotAllowed, fmt.Sprintf("domain %s is not allowed", host))
+	}
+
+	if err = p.checkAddress(ctx, host); err != nil {
+		return err
+	}
+
+	for _, c := range p.checkers {
+		if err = c.Check(ctx, u); err != nil {
+			var rejection *RejectionError
+			if errors.As(err, &rejection) {
+				return rejection
+			}
+
+			return NewRejectionError(RejectionReputationFailure, err.Error())
+		}
+	}
+
+	return nil
+}
+
+func (p DestinationPolicy) checkAddress(ctx context.Context, host string) error {
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return NewRejectionError(RejectionPrivateAddress, fmt.Sprintf("host %s points to local network", host))
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		return p.checkIP(host, ip)
+	}
+
+	if p.resolver == nil {
+		return nil
+	}
+
+	addrs, err := p.resolver.LookupIPAddr(ctx, host)
+	if err != nil || len(addrs) == 0 {
+		return NewRejectionError(RejectionUnresolvableHost, fmt.Sprintf("host %s cannot be resolved", host))
+	}
+
+	for _, addr := range addrs {
+		if err = p.checkIP(host, addr.IP); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sharedAddressSpace подсеть операторского NAT (RFC 6598).
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+func (p DestinationPolicy) checkIP(host string, ip net.IP) error {
+	if IsPrivateIP(ip) {
+		return NewRejectionError(RejectionPrivateAddress, fmt.Sprintf("host %s points to private address %s", host, ip))
+	}
+
+	return nil
+}
+
+// IsPrivateIP проверяет, что IP-адрес относится к локальной или частной сети,
+// не предназначен для адресации конкретного узла или является групповым.
+func IsPrivateIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || sharedAddressSpace.Contains(ip)
+}
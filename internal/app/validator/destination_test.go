@@ -0,0 +1,116 @@
+package validator
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	inerr "github.com/ivanpodgorny/urlshortener/internal/app/errors"
+)
+
+type staticResolver map[string][]string
+
+func (r staticResolver) LookupIPAddr(_ context.Context, host string) ([]net.IPAddr, error) {
+	ips, ok := r[host]
+	if !ok {
+		return nil, errors.New("no such host")
+	}
+
+	addrs := make([]net.IPAddr, 0, len(ips))
+	for _, ip := range ips {
+		addrs = append(addrs, net.IPAddr{IP: net.ParseIP(ip)})
+	}
+
+	return addrs, nil
+}
+
+func TestDestinationPolicy_Check(t *testing.T) {
+	var (
+		dir       = t.TempDir()
+		blockPath = filepath.Join(dir, "blocklist")
+		resolver  = staticResolver{
+			"ya.ru":            {"77.88.55.242"},
+			"phishing.example": {"203.0.113.1"},
+			"internal.example": {"203.0.113.2", "10.0.0.1"},
+			"mapped.example":   {"::ffff:127.0.0.1"},
+		}
+		reputation = ReputationCheckerFunc(func(_ context.Context, u *url.URL) error {
+			if u.Hostname() == "phishing.example" {
+				return errors.New("listed as phishing")
+			}
+
+			return nil
+		})
+	)
+	require.NoError(t, os.WriteFile(blockPath, []byte("blocked.example\n"), 0600))
+	blocklist, err := NewDomainList(blockPath)
+	require.NoError(t, err)
+	p := NewDestinationPolicy([]string{"http", "https"}, blocklist, nil, resolver, reputation)
+
+	tests := []struct {
+		name       string
+		url        string
+		wantReason string
+	}{
+		{name: "разрешенный адрес", url: "https://ya.ru/path"},
+		{name: "схема в верхнем регистре", url: "HTTPS://ya.ru/"},
+		{name: "относительный URL", url: "/path", wantReason: RejectionInvalidURL},
+		{name: "схема javascript", url: "javascript://ya.ru/%0Aalert(1)", wantReason: RejectionSchemeNotAllowed},
+		{name: "схема file", url: "file://ya.ru/etc/passwd", wantReason: RejectionSchemeNotAllowed},
+		{name: "заблокированный домен", url: "https://blocked.example/", wantReason: RejectionDomainBlocked},
+		{name: "поддомен заблокированного домена", url: "https://www.blocked.example/", wantReason: RejectionDomainBlocked},
+		{name: "localhost", url: "http://localhost:8080/", wantReason: RejectionPrivateAddress},
+		{name: "loopback IPv4", url: "http://127.0.0.1/", wantReason: RejectionPrivateAddress},
+		{name: "loopback IPv6", url: "http://[::1]/", wantReason: RejectionPrivateAddress},
+		{name: "частная сеть", url: "http://192.168.1.1/", wantReason: RejectionPrivateAddress},
+		{name: "link-local", url: "http://169.254.169.254/latest/meta-data", wantReason: RejectionPrivateAddress},
+		{name: "операторский NAT", url: "http://100.64.0.1/", wantReason: RejectionPrivateAddress},
+		{name: "публичный IP-адрес", url: "http://203.0.113.10/"},
+		{name: "хост разрешается в частный адрес", url: "https://internal.example/", wantReason: RejectionPrivateAddress},
+		{name: "хост разрешается в IPv4-mapped loopback", url: "https://mapped.example/", wantReason: RejectionPrivateAddress},
+		{name: "хост не разрешается", url: "https://unknown.example/", wantReason: RejectionUnresolvableHost},
+		{name: "плохая репутация", url: "https://phishing.example/", wantReason: RejectionReputationFailure},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := p.Check(context.Background(), tt.url)
+			if tt.wantReason == "" {
+				assert.NoError(t, err)
+
+				return
+			}
+
+			var rejection *RejectionError
+			require.ErrorAs(t, err, &rejection)
+			assert.Equal(t, tt.wantReason, rejection.Reason)
+			assert.ErrorIs(t, err, inerr.ErrDestinationRejected)
+		})
+	}
+}
+
+func TestDestinationPolicy_Allowlist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "allowlist")
+	require.NoError(t, os.WriteFile(path, []byte("ya.ru\n"), 0600))
+	allowlist, err := NewDomainList(path)
+	require.NoError(t, err)
+	reputation := ReputationCheckerFunc(func(context.Context, *url.URL) error {
+		return NewRejectionError("malware", "listed as malware")
+	})
+	p := NewDestinationPolicy([]string{"https"}, nil, allowlist, nil)
+
+	assert.NoError(t, p.Check(context.Background(), "https://mail.ya.ru/"), "домен из списка разрешенных")
+	var rejection *RejectionError
+	require.ErrorAs(t, p.Check(context.Background(), "https://google.com/"), &rejection)
+	assert.Equal(t, RejectionDomainNotAllowed, rejection.Reason, "домен не из списка разрешенных")
+
+	p = NewDestinationPolicy([]string{"https"}, nil, nil, nil, reputation)
+	require.ErrorAs(t, p.Check(context.Background(), "https://google.com/"), &rejection)
+	assert.Equal(t, "malware", rejection.Reason, "причина отказа от сервиса проверки репутации")
+}
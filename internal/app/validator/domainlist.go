@@ -0,0 +1,136 @@
+package validator
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/idna"
+
+	"github.com/ivanpodgorny/urlshortener/internal/app/logger"
+)
+
+// DomainList список доменов, загружаемый из файла. Каждая строка файла содержит
+// один домен, пустые строки и строки, начинающиеся с #, пропускаются.
+// Домен из списка соответствует также всем своим поддоменам. Интернационализированные
+// домены можно указывать как в Unicode, так и в punycode.
+type DomainList struct {
+	path    string
+	domains map[string]struct{}
+	modTime time.Time
+	mu      sync.RWMutex
+}
+
+// NewDomainList возвращает указатель на новый экземпляр DomainList,
+// загруженный из файла path.
+func NewDomainList(path string) (*DomainList, error) {
+	l := &DomainList{
+		path:    path,
+		domains: map[string]struct{}{},
+	}
+	if err := l.Reload(); err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+// Reload перечитывает файл со списком доменов, если он изменился
+// с момента предыдущей загрузки.
+func (l *DomainList) Reload() error {
+	info, err := os.Stat(l.path)
+	if err != nil {
+		return err
+	}
+
+	l.mu.RLock()
+	unchanged := info.ModTime().Equal(l.modTime)
+	l.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	file, err := os.Open(l.path)
+	if err != nil {
+		return err
+	}
+
+	defer func(file *os.File) {
+		_ = file.Close()
+	}(file)
+
+	domains := map[string]struct{}{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		domains[normalizeHost(line)] = struct{}{}
+	}
+	if err = scanner.Err(); err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	l.domains, l.modTime = domains, info.ModTime()
+	l.mu.Unlock()
+
+	return nil
+}
+
+// Watch проверяет изменение файла с периодичностью interval и перечитывает его,
+// пока не будет отменен контекст ctx. При ошибке чтения остается
+// ранее загруженный список.
+func (l *DomainList) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := l.Reload(); err != nil {
+				logger.FromContext(ctx).ErrorCtx(ctx, "Error while reloading domain list", "path", l.path, "error", err)
+			}
+		}
+	}
+}
+
+// Contains возвращает true, если host или один из его родительских доменов
+// входит в список.
+func (l *DomainList) Contains(host string) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	host = normalizeHost(host)
+	for host != "" {
+		if _, ok := l.domains[host]; ok {
+			return true
+		}
+
+		_, parent, found := strings.Cut(host, ".")
+		if !found {
+			break
+		}
+		host = parent
+	}
+
+	return false
+}
+
+func normalizeHost(host string) string {
+	host = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(host)), ".")
+	if !isASCII(host) {
+		if ascii, err := idna.Lookup.ToASCII(host); err == nil {
+			host = ascii
+		}
+	}
+
+	return host
+}
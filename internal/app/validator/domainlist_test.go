@@ -0,0 +1,38 @@
+package validator
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDomainList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocklist")
+	require.NoError(t, os.WriteFile(path, []byte("# phishing\nEvil.example\n\nbad.test.\nПлохой.рф\n"), 0600))
+
+	l, err := NewDomainList(path)
+	require.NoError(t, err)
+	assert.True(t, l.Contains("evil.example"), "домен из списка")
+	assert.True(t, l.Contains("login.EVIL.example."), "поддомен домена из списка")
+	assert.True(t, l.Contains("bad.test"), "домен с точкой в конце")
+	assert.True(t, l.Contains("xn--i1adjac2b.xn--p1ai"), "интернационализированный домен")
+	assert.False(t, l.Contains("notevil.example"), "домен с похожим именем")
+	assert.False(t, l.Contains("example"), "родительский домен")
+
+	require.NoError(t, os.WriteFile(path, []byte("good.example\n"), 0600))
+	require.NoError(t, os.Chtimes(path, time.Now().Add(time.Minute), time.Now().Add(time.Minute)))
+	require.NoError(t, l.Reload())
+	assert.False(t, l.Contains("evil.example"), "список перечитан после изменения файла")
+	assert.True(t, l.Contains("good.example"), "список перечитан после изменения файла")
+
+	require.NoError(t, os.Remove(path))
+	assert.Error(t, l.Reload(), "файл удален")
+	assert.True(t, l.Contains("good.example"), "при ошибке чтения остается прежний список")
+
+	_, err = NewDomainList(path)
+	assert.Error(t, err, "файл не существует")
+}
@@ -0,0 +1,245 @@
+package validator
+
+import (
+	"net/url"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+)
+
+// Предупреждения о возможной подмене домена похожими символами.
+const (
+	WarningMixedScript = "mixed_script"
+	WarningConfusable  = "whole_script_confusable"
+)
+
+// scripts письменности, различаемые при проверке доменов на смешение алфавитов.
+var scripts = []struct {
+	name  string
+	table *unicode.RangeTable
+}{
+	{name: "Latin", table: unicode.Latin},
+	{name: "Cyrillic", table: unicode.Cyrillic},
+	{name: "Greek", table: unicode.Greek},
+	{name: "Armenian", table: unicode.Armenian},
+	{name: "Georgian", table: unicode.Georgian},
+	{name: "Hebrew", table: unicode.Hebrew},
+	{name: "Arabic", table: unicode.Arabic},
+	{name: "Devanagari", table: unicode.Devanagari},
+	{name: "Thai", table: unicode.Thai},
+	{name: "Han", table: unicode.Han},
+	{name: "Hiragana", table: unicode.Hiragana},
+	{name: "Katakana", table: unicode.Katakana},
+	{name: "Hangul", table: unicode.Hangul},
+	{name: "Bopomofo", table: unicode.Bopomofo},
+}
+
+// allowedScriptSets допустимые сочетания письменностей в одной метке домена.
+var allowedScriptSets = [][]string{
+	{"Latin", "Han", "Hiragana", "Katakana"},
+	{"Latin", "Han", "Hangul"},
+	{"Latin", "Han", "Bopomofo"},
+}
+
+// latinConfusables буквы кириллицы и греческого алфавита, неотличимые от латинских.
+const latinConfusables = "аеорсухіјѕԁӏԛԝһүɡвкмнтαβικνορτυχ"
+
+// ParseIRI разбирает URL или IRI. В отличие от url.Parse допускает одиночные символы %,
+// не образующие экранированную последовательность: они экранируются как %25.
+func ParseIRI(rawURL string) (*url.URL, error) {
+	return url.Parse(escapeStrayPercents(strings.TrimSpace(rawURL)))
+}
+
+// DisplayURL возвращает отображаемую форму URL: домен в Unicode, экранированные
+// последовательности UTF-8 в пути, запросе и фрагменте декодированы. Домены,
+// для которых HostWarnings возвращает предупреждения, остаются в punycode.
+// Если URL не удается разобрать, возвращает его без изменений.
+func DisplayURL(rawURL string) string {
+	u, err := ParseIRI(rawURL)
+	if err != nil || u.Opaque != "" || u.Host == "" {
+		return rawURL
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if len(HostWarnings(host)) == 0 {
+		if unicodeHost, err := idna.Display.ToUnicode(host); err == nil {
+			host = unicodeHost
+		}
+	}
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	if port := u.Port(); port != "" {
+		host += ":" + port
+	}
+
+	var b strings.Builder
+	b.WriteString(u.Scheme)
+	b.WriteString("://")
+	if u.User != nil {
+		b.WriteString(u.User.String())
+		b.WriteByte('@')
+	}
+	b.WriteString(host)
+
+	path := u.RawPath
+	if path == "" {
+		path = u.EscapedPath()
+	}
+	b.WriteString(decodeUTF8Escapes(path))
+	if u.RawQuery != "" {
+		b.WriteByte('?')
+		b.WriteString(decodeUTF8Escapes(u.RawQuery))
+	}
+	if u.Fragment != "" {
+		b.WriteByte('#')
+		b.WriteString(decodeUTF8Escapes(u.EscapedFragment()))
+	}
+
+	return b.String()
+}
+
+// URLWarnings возвращает предупреждения о возможной подмене домена URL rawURL.
+func URLWarnings(rawURL string) []string {
+	u, err := ParseIRI(rawURL)
+	if err != nil {
+		return nil
+	}
+
+	return HostWarnings(u.Hostname())
+}
+
+// HostWarnings возвращает предупреждения о возможной подмене домена host
+// похожими символами: WarningMixedScript, если в одной метке домена смешаны
+// разные письменности, и WarningConfusable, если метка целиком состоит
+// из кириллических или греческих букв, неотличимых от латинских, а домен
+// верхнего уровня записан латиницей. Домен может быть в Unicode или punycode.
+func HostWarnings(host string) []string {
+	host, err := idna.Display.ToUnicode(strings.ToLower(host))
+	if err != nil || isASCII(host) {
+		return nil
+	}
+
+	labels := strings.Split(strings.TrimSuffix(host, "."), ".")
+	latinTLD := isASCII(labels[len(labels)-1])
+	warnings := make([]string, 0)
+	seen := map[string]bool{}
+	for _, label := range labels {
+		for _, w := range labelWarnings(label, latinTLD) {
+			if !seen[w] {
+				seen[w] = true
+				warnings = append(warnings, w)
+			}
+		}
+	}
+
+	return warnings
+}
+
+func labelWarnings(label string, latinTLD bool) []string {
+	var (
+		found       = map[string]bool{}
+		confusables = true
+	)
+	for _, r := range label {
+		if r == '-' || unicode.IsDigit(r) || unicode.In(r, unicode.Common, unicode.Inherited) {
+			continue
+		}
+
+		found[scriptOf(r)] = true
+		if !strings.ContainsRune(latinConfusables, r) {
+			confusables = false
+		}
+	}
+
+	if len(found) > 1 && !isAllowedScriptSet(found) {
+		return []string{WarningMixedScript}
+	}
+
+	if latinTLD && confusables && len(found) == 1 && (found["Cyrillic"] || found["Greek"]) {
+		return []string{WarningConfusable}
+	}
+
+	return nil
+}
+
+func scriptOf(r rune) string {
+	for _, s := range scripts {
+		if unicode.Is(s.table, r) {
+			return s.name
+		}
+	}
+
+	return "Other"
+}
+
+func isAllowedScriptSet(found map[string]bool) bool {
+	for _, set := range allowedScriptSets {
+		matched := 0
+		for _, name := range set {
+			if found[name] {
+				matched++
+			}
+		}
+		if matched == len(found) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// escapeStrayPercents экранирует символы %, за которыми не следуют две шестнадцатеричные цифры.
+func escapeStrayPercents(s string) string {
+	if !strings.Contains(s, "%") {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '%' && (i+2 >= len(s) || !isHex(s[i+1]) || !isHex(s[i+2])) {
+			b.WriteString("%25")
+
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+
+	return b.String()
+}
+
+// decodeUTF8Escapes декодирует экранированные последовательности, образующие
+// печатные символы UTF-8 за пределами ASCII. Остальные последовательности не меняются.
+func decodeUTF8Escapes(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		if s[i] != '%' || i+2 >= len(s) || !isHex(s[i+1]) || !isHex(s[i+2]) || unhex(s[i+1]) < 8 {
+			b.WriteByte(s[i])
+			i++
+
+			continue
+		}
+
+		decoded := make([]byte, 0, utf8.UTFMax)
+		j := i
+		for j+2 < len(s) && s[j] == '%' && isHex(s[j+1]) && isHex(s[j+2]) && len(decoded) < utf8.UTFMax {
+			decoded = append(decoded, unhex(s[j+1])<<4|unhex(s[j+2]))
+			j += 3
+			if utf8.FullRune(decoded) {
+				break
+			}
+		}
+
+		r, size := utf8.DecodeRune(decoded)
+		if r == utf8.RuneError || size != len(decoded) || !unicode.IsPrint(r) {
+			b.WriteString(s[i:j])
+		} else {
+			b.WriteRune(r)
+		}
+		i = j
+	}
+
+	return b.String()
+}
@@ -0,0 +1,55 @@
+package validator
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIRICorpus(t *testing.T) {
+	data, err := os.ReadFile("testdata/iri_corpus.json")
+	require.NoError(t, err)
+	corpus := make([]struct {
+		Name      string   `json:"name"`
+		URL       string   `json:"url"`
+		Valid     bool     `json:"valid"`
+		Canonical string   `json:"canonical"`
+		Display   string   `json:"display"`
+		Warnings  []string `json:"warnings"`
+	}, 0)
+	require.NoError(t, json.Unmarshal(data, &corpus))
+
+	c := NewCanonicalizer(false, nil)
+	for _, tt := range corpus {
+		t.Run(tt.Name, func(t *testing.T) {
+			if !tt.Valid {
+				assert.Error(t, IsURL(tt.URL))
+
+				return
+			}
+
+			require.NoError(t, IsURL(tt.URL))
+			canonical, err := c.Canonicalize(tt.URL)
+			require.NoError(t, err)
+			assert.Equal(t, tt.Canonical, canonical, "канонический вид")
+			assert.True(t, isASCII(canonical), "канонический вид в ASCII")
+			assert.Equal(t, tt.Display, c.Display(canonical), "отображаемая форма")
+			if len(tt.Warnings) == 0 {
+				assert.Empty(t, URLWarnings(tt.URL), "предупреждения")
+			} else {
+				assert.Equal(t, tt.Warnings, URLWarnings(tt.URL), "предупреждения")
+				assert.Equal(t, tt.Warnings, URLWarnings(canonical), "предупреждения для punycode")
+			}
+		})
+	}
+}
+
+func TestDisplayURL(t *testing.T) {
+	assert.Equal(t, "http://[::1]:8080/%2F", DisplayURL("http://[::1]:8080/%2F"), "IPv6 и экранированные символы ASCII")
+	assert.Equal(t, "https://ya.ru/%FF%D0", DisplayURL("https://ya.ru/%FF%D0"), "некорректный UTF-8")
+	assert.Equal(t, "https://ya.ru/%E2%80%8B", DisplayURL("https://ya.ru/%E2%80%8B"), "непечатный символ")
+	assert.Equal(t, "mailto:user@example.com", DisplayURL("mailto:user@example.com"), "URL без хоста")
+}
@@ -2,7 +2,9 @@ package validator
 
 import (
 	"fmt"
-	"net/url"
+
+	"github.com/google/uuid"
+	"golang.org/x/net/idna"
 )
 
 // Validator функция валидации.
@@ -19,14 +21,30 @@ func Validate[T any](val T, validators ...Validator[T]) (bool, error) {
 	return true, nil
 }
 
-// IsURL проверяет, что строка является валидным URL.
+// IsURL проверяет, что строка является валидным абсолютным URL или IRI.
+// Интернационализированный домен должен соответствовать IDNA.
 func IsURL(val string) error {
-	u, err := url.Parse(val)
-	if err == nil && u.Scheme != "" && u.Host != "" {
-		return nil
+	u, err := ParseIRI(val)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("%s is not valid url", val)
+	}
+
+	if host := u.Hostname(); !isASCII(host) {
+		if _, err = idna.Lookup.ToASCII(host); err != nil {
+			return fmt.Errorf("%s is not valid url: %w", val, err)
+		}
+	}
+
+	return nil
+}
+
+// IsUUID проверяет, что строка является UUID.
+func IsUUID(val string) error {
+	if _, err := uuid.Parse(val); err != nil {
+		return fmt.Errorf("%s is not valid uuid", val)
 	}
 
-	return fmt.Errorf("%s is not valid url", val)
+	return nil
 }
 
 // Length возвращает валидатор, который проверяет, что строка не превышает длину l.
@@ -28,6 +28,16 @@ func TestIsURL(t *testing.T) {
 			url:   "/path/test",
 			valid: false,
 		},
+		{
+			name:  "IRI",
+			url:   "https://пример.рф/путь?ключ=значение",
+			valid: true,
+		},
+		{
+			name:  "домен, не соответствующий IDNA",
+			url:   "https://abcعربي.com/",
+			valid: false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -41,6 +51,11 @@ func TestIsURL(t *testing.T) {
 	}
 }
 
+func TestIsUUID(t *testing.T) {
+	assert.NoError(t, IsUUID("438c4b98-fc98-45cf-ac63-c4a86fbd4ff4"), "корректный UUID")
+	assert.Error(t, IsUUID("userID"), "некорректный UUID")
+}
+
 func TestLength(t *testing.T) {
 	var (
 		l         = 10
@@ -25,6 +25,9 @@ const (
 	Shortener_GetURL_FullMethodName          = "/shortener.Shortener/GetURL"
 	Shortener_GetAllURL_FullMethodName       = "/shortener.Shortener/GetAllURL"
 	Shortener_DeleteURLBatch_FullMethodName  = "/shortener.Shortener/DeleteURLBatch"
+	Shortener_GetVariantStats_FullMethodName = "/shortener.Shortener/GetVariantStats"
+	Shortener_GetQRCode_FullMethodName       = "/shortener.Shortener/GetQRCode"
+	Shortener_GetStats_FullMethodName        = "/shortener.Shortener/GetStats"
 )
 
 // ShortenerClient is the client API for Shortener service.
@@ -33,9 +36,13 @@ const (
 type ShortenerClient interface {
 	CreateLink(ctx context.Context, in *CreateLinkRequest, opts ...grpc.CallOption) (*CreateLinkResponse, error)
 	CreateLinkBatch(ctx context.Context, in *CreateLinkBatchRequest, opts ...grpc.CallOption) (*CreateLinkBatchResponse, error)
+	// В REST API пароль передается в теле запроса, чтобы не попадать в журналы запросов.
 	GetURL(ctx context.Context, in *GetURLRequest, opts ...grpc.CallOption) (*GetURLResponse, error)
 	GetAllURL(ctx context.Context, in *GetAllURLRequest, opts ...grpc.CallOption) (*GetAllURLResponse, error)
 	DeleteURLBatch(ctx context.Context, in *DeleteURLBatchRequest, opts ...grpc.CallOption) (*DeleteURLBatchResponse, error)
+	GetVariantStats(ctx context.Context, in *GetVariantStatsRequest, opts ...grpc.CallOption) (*GetVariantStatsResponse, error)
+	GetQRCode(ctx context.Context, in *GetQRCodeRequest, opts ...grpc.CallOption) (*GetQRCodeResponse, error)
+	GetStats(ctx context.Context, in *GetStatsRequest, opts ...grpc.CallOption) (*GetStatsResponse, error)
 }
 
 type shortenerClient struct {
@@ -91,15 +98,46 @@ func (c *shortenerClient) DeleteURLBatch(ctx context.Context, in *DeleteURLBatch
 	return out, nil
 }
 
+func (c *shortenerClient) GetVariantStats(ctx context.Context, in *GetVariantStatsRequest, opts ...grpc.CallOption) (*GetVariantStatsResponse, error) {
+	out := new(GetVariantStatsResponse)
+	err := c.cc.Invoke(ctx, Shortener_GetVariantStats_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shortenerClient) GetQRCode(ctx context.Context, in *GetQRCodeRequest, opts ...grpc.CallOption) (*GetQRCodeResponse, error) {
+	out := new(GetQRCodeResponse)
+	err := c.cc.Invoke(ctx, Shortener_GetQRCode_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shortenerClient) GetStats(ctx context.Context, in *GetStatsRequest, opts ...grpc.CallOption) (*GetStatsResponse, error) {
+	out := new(GetStatsResponse)
+	err := c.cc.Invoke(ctx, Shortener_GetStats_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // ShortenerServer is the server API for Shortener service.
 // All implementations must embed UnimplementedShortenerServer
 // for forward compatibility
 type ShortenerServer interface {
 	CreateLink(context.Context, *CreateLinkRequest) (*CreateLinkResponse, error)
 	CreateLinkBatch(context.Context, *CreateLinkBatchRequest) (*CreateLinkBatchResponse, error)
+	// В REST API пароль передается в теле запроса, чтобы не попадать в журналы запросов.
 	GetURL(context.Context, *GetURLRequest) (*GetURLResponse, error)
 	GetAllURL(context.Context, *GetAllURLRequest) (*GetAllURLResponse, error)
 	DeleteURLBatch(context.Context, *DeleteURLBatchRequest) (*DeleteURLBatchResponse, error)
+	GetVariantStats(context.Context, *GetVariantStatsRequest) (*GetVariantStatsResponse, error)
+	GetQRCode(context.Context, *GetQRCodeRequest) (*GetQRCodeResponse, error)
+	GetStats(context.Context, *GetStatsRequest) (*GetStatsResponse, error)
 	mustEmbedUnimplementedShortenerServer()
 }
 
@@ -122,6 +160,15 @@ func (UnimplementedShortenerServer) GetAllURL(context.Context, *GetAllURLRequest
 func (UnimplementedShortenerServer) DeleteURLBatch(context.Context, *DeleteURLBatchRequest) (*DeleteURLBatchResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method DeleteURLBatch not implemented")
 }
+func (UnimplementedShortenerServer) GetVariantStats(context.Context, *GetVariantStatsRequest) (*GetVariantStatsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetVariantStats not implemented")
+}
+func (UnimplementedShortenerServer) GetQRCode(context.Context, *GetQRCodeRequest) (*GetQRCodeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetQRCode not implemented")
+}
+func (UnimplementedShortenerServer) GetStats(context.Context, *GetStatsRequest) (*GetStatsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetStats not implemented")
+}
 func (UnimplementedShortenerServer) mustEmbedUnimplementedShortenerServer() {}
 
 // UnsafeShortenerServer may be embedded to opt out of forward compatibility for this service.
@@ -225,6 +272,60 @@ func _Shortener_DeleteURLBatch_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Shortener_GetVariantStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetVariantStatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShortenerServer).GetVariantStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Shortener_GetVariantStats_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShortenerServer).GetVariantStats(ctx, req.(*GetVariantStatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Shortener_GetQRCode_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetQRCodeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShortenerServer).GetQRCode(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Shortener_GetQRCode_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShortenerServer).GetQRCode(ctx, req.(*GetQRCodeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Shortener_GetStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetStatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShortenerServer).GetStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Shortener_GetStats_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShortenerServer).GetStats(ctx, req.(*GetStatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // Shortener_ServiceDesc is the grpc.ServiceDesc for Shortener service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -252,6 +353,18 @@ var Shortener_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "DeleteURLBatch",
 			Handler:    _Shortener_DeleteURLBatch_Handler,
 		},
+		{
+			MethodName: "GetVariantStats",
+			Handler:    _Shortener_GetVariantStats_Handler,
+		},
+		{
+			MethodName: "GetQRCode",
+			Handler:    _Shortener_GetQRCode_Handler,
+		},
+		{
+			MethodName: "GetStats",
+			Handler:    _Shortener_GetStats_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "pkg/proto/shortener.proto",